@@ -0,0 +1,24 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+// Hub marks v1 as the version that all other versions of CurveCluster are converted to and from.
+// v1 is also the storage version, so the operator only reconciles v1 objects.
+func (*CurveCluster) Hub() {}
+
+// Hub marks v1 as the hub of CurveClusterList
+func (*CurveClusterList) Hub() {}
@@ -18,23 +18,33 @@ package v1
 
 import (
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 const CustomResourceGroup = "curve.opencurve.io"
 
+// PriorityClassNameAll is the key of priorityClassNames for all daemons
+const PriorityClassNameAll = "all"
+
 // ConditionType represents a resource's status
 type ConditionType string
 
 const (
-	// ClusterPhasePending indicates the cluster is running to create.
-	ClusterPhasePending ConditionType = "Pending"
+	// ClusterPhaseProvisioning indicates the daemons of the cluster are being created.
+	ClusterPhaseProvisioning ConditionType = "Provisioning"
+	// ClusterPhaseFormatting indicates the devices are being formatted and the chunk files are being prepared.
+	ClusterPhaseFormatting ConditionType = "Formatting"
+	// ClusterPhaseDeployingChunkservers indicates the chunkservers are being started.
+	ClusterPhaseDeployingChunkservers ConditionType = "DeployingChunkservers"
+	// ClusterPhaseCreatingPools indicates the physical or logical pool is being created.
+	ClusterPhaseCreatingPools ConditionType = "CreatingPools"
 	// ClusterPhaseReady indicates the cluster has been created successfully.
-	ClusterPhaseReady ConditionType = "Ready" //nolint:unused
+	ClusterPhaseReady ConditionType = "Ready"
 	// ClusterPhaseDeleting indicates the cluster is running to delete.
 	ClusterPhaseDeleting ConditionType = "Deleting"
 	// ClusterPhaseError indicates the cluster created failed because of some reason.
-	ClusterPhaseError ConditionType = "Failed" //nolint:unused
+	ClusterPhaseError ConditionType = "Failed"
 	// ClusterPhaseUnknown is unknown phase
 	ClusterPhaseUnknown ConditionType = "Unknown" //nolint:unused
 )
@@ -44,6 +54,9 @@ const (
 	ConditionTypeEtcdReady ConditionType = "EtcdReady"
 	// ConditionTypeMdsReady indicates the mds is ready
 	ConditionTypeMdsReady ConditionType = "MdsReady"
+	// ConditionTypeMdsLeaderReady indicates the leader of mds is elected, which is checked by the dummy ports of mds
+	// before the pools are created and the chunkservers are started
+	ConditionTypeMdsLeaderReady ConditionType = "MdsLeaderReady"
 	// ConditionTypeFormatedReady indicates the formated job is ready
 	ConditionTypeFormatedReady ConditionType = "formatedReady"
 	// ConditionTypeChunkServerReady indicates the chunk server is ready
@@ -58,13 +71,29 @@ const (
 	ConditionTypeFailure ConditionType = "Failed"
 	// ConditionTypeUnknown is unknown condition
 	ConditionTypeUnknown ConditionType = "Unknown" //nolint:unused
+	// ConditionTypeClockSkew is a warning that the clocks of the nodes are skewed more than timeSync.maxSkewMilliseconds
+	// or are not synchronized, it doesn't change the phase of the cluster
+	ConditionTypeClockSkew ConditionType = "ClockSkew"
+	// ConditionTypeUpgradeBlocked is a warning that the curve image can't be upgraded to because the upgrade check
+	// failed or the canary chunkservers degraded, the daemons keep running the old image except the canaries
+	ConditionTypeUpgradeBlocked ConditionType = "UpgradeBlocked"
+	// ConditionTypePhysicalPoolReady indicates the physical pool is created by the topology of the chunkservers
+	ConditionTypePhysicalPoolReady ConditionType = "PhysicalPoolReady"
+	// ConditionTypeLogicalPoolReady indicates the logical pool is created on the chunkservers
+	ConditionTypeLogicalPoolReady ConditionType = "LogicalPoolReady"
+	// ConditionTypeDestructiveBlocked is a warning that an operation removing data from the pools such as retiring
+	// the chunkservers of removed devices waits for the confirmation by ConfirmDestructiveAnnotation
+	ConditionTypeDestructiveBlocked ConditionType = "DestructiveBlocked"
+	// ConditionTypeStaleTopology is a warning that the topology has chunkservers or servers left by the renamed or
+	// removed nodes that can't be retired by the operator and have to be removed manually
+	ConditionTypeStaleTopology ConditionType = "StaleTopology"
 )
 
 type ConditionStatus string
 
 const (
 	ConditionTrue    ConditionStatus = "True"
-	ConditionFalse   ConditionStatus = "False"   //nolint:unused
+	ConditionFalse   ConditionStatus = "False"
 	ConditionUnknown ConditionStatus = "Unknown" //nolint:unused
 )
 
@@ -81,6 +110,31 @@ const (
 	ConditionReconcileSucceeded                ConditionReason = "ReconcileSucceeded"
 	ConditionReconcileFailed                   ConditionReason = "ReconcileFailed"
 	ConditionDeletingClusterReason             ConditionReason = "Deleting"
+	ConditionVersionSkewReason                 ConditionReason = "VersionSkew"
+	ConditionUnknownNodesReason                ConditionReason = "UnknownNodes"
+	ConditionPreflightFailedReason             ConditionReason = "PreflightFailed"
+	ConditionClockSkewedReason                 ConditionReason = "ClockSkewed"
+	ConditionClockSynchronizedReason           ConditionReason = "ClockSynchronized"
+	ConditionIncompatibleVersionReason         ConditionReason = "IncompatibleVersion"
+	ConditionUpgradeCheckFailedReason          ConditionReason = "UpgradeCheckFailed"
+	ConditionUpgradeAllowedReason              ConditionReason = "UpgradeAllowed"
+	ConditionCanaryDegradedReason              ConditionReason = "CanaryDegraded"
+	ConditionMissingS3ConfigReason             ConditionReason = "MissingS3Config"
+	ConditionImageVerificationFailedReason     ConditionReason = "ImageVerificationFailed"
+	ConditionCreatingPoolReason                ConditionReason = "CreatingPool"
+	ConditionPoolCreatedReason                 ConditionReason = "PoolCreated"
+	ConditionPoolCreationFailedReason          ConditionReason = "PoolCreationFailed"
+	ConditionInvalidTopologyReason             ConditionReason = "InvalidTopology"
+	ConditionTopologyUnmanagedReason           ConditionReason = "TopologyUnmanaged"
+	ConditionConfirmationRequiredReason        ConditionReason = "ConfirmationRequired"
+	ConditionDestructiveConfirmedReason        ConditionReason = "DestructiveConfirmed"
+	ConditionWaitingMdsLeaderReason            ConditionReason = "WaitingMdsLeader"
+	ConditionMdsLeaderElectedReason            ConditionReason = "MdsLeaderElected"
+	ConditionMdsLeaderTimeoutReason            ConditionReason = "MdsLeaderTimeout"
+	ConditionManualActionRequiredReason        ConditionReason = "ManualActionRequired"
+	ConditionStaleTopologyRemovedReason        ConditionReason = "StaleTopologyRemoved"
+	ConditionWaitingChunkServersReason         ConditionReason = "WaitingChunkServers"
+	ConditionChunkServersRegisterTimeoutReason ConditionReason = "ChunkServersRegisterTimeout"
 )
 
 type ClusterCondition struct {
@@ -101,6 +155,26 @@ type ClusterCondition struct {
 	Message string `json:"message,omitempty"`
 }
 
+// ClusterTransition is a transition of the phase of the cluster or the status of a condition
+type ClusterTransition struct {
+	// Time is when the transition happened
+	Time metav1.Time `json:"time"`
+	// Phase is the phase of the cluster after the transition
+	Phase ConditionType `json:"phase,omitempty"`
+	// Type is the type of the condition that transitioned, it's empty if only the phase changed
+	// +optional
+	Type ConditionType `json:"type,omitempty"`
+	// Status is the status of the condition after the transition
+	// +optional
+	Status ConditionStatus `json:"status,omitempty"`
+	// Reason is the reason of the condition after the transition
+	// +optional
+	Reason ConditionReason `json:"reason,omitempty"`
+	// Message is the message of the transition
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
 type ClusterVersion struct {
 	Image string `json:"image,omitempty"`
 }
@@ -116,6 +190,8 @@ type CurveClusterSpec struct {
 	// +optional
 	Nodes []string `json:"nodes,omitempty"`
 
+	// HostDataDir is the directory on the hosts that the data, logs and conf of the daemons are under, it is
+	// CURVE_HOST_DATA_DIR of the operator settings if not set
 	// +optional
 	HostDataDir string `json:"hostDataDir,omitempty"`
 
@@ -131,147 +207,2415 @@ type CurveClusterSpec struct {
 	// +optional
 	Storage StorageScopeSpec `json:"storage,omitempty"`
 
+	// +optional
+	Logging LoggingSpec `json:"logging,omitempty"`
+
+	// +optional
+	Tools ToolsSpec `json:"tools,omitempty"`
+
+	// +optional
+	Dashboard DashboardSpec `json:"dashboard,omitempty"`
+
+	// +optional
+	Topology TopologySpec `json:"topology,omitempty"`
+
+	// +optional
+	UpdateStrategy UpdateStrategySpec `json:"updateStrategy,omitempty"`
+
+	// +optional
+	Network NetworkSpec `json:"network,omitempty"`
+
+	// +optional
+	Monitoring MonitoringSpec `json:"monitoring,omitempty"`
+
+	// +optional
+	TimeSync TimeSyncSpec `json:"timeSync,omitempty"`
+
+	// +optional
+	Maintenance MaintenanceSpec `json:"maintenance,omitempty"`
+
+	// MaintenanceWindow restricts the disruptive operations to the windows, they are deferred until the next
+	// window opens if the spec is changed outside of the windows. They run at any time if it's not set.
+	// +optional
+	MaintenanceWindow *MaintenanceWindowSpec `json:"maintenanceWindow,omitempty"`
+
+	// +optional
+	Cleanup CleanupSpec `json:"cleanup,omitempty"`
+
+	// Connection is how the connection info of the cluster is exposed to the clients
+	// +optional
+	Connection ConnectionSpec `json:"connection,omitempty"`
+
+	// Notifications pushes the major events of the cluster to external systems
+	// +optional
+	Notifications NotificationsSpec `json:"notifications,omitempty"`
+
+	// HistoryLimit is the number of the last transitions of the phase and the conditions kept in status.history.
+	// Default is 20
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	HistoryLimit int `json:"historyLimit,omitempty"`
+
+	// PriorityClassNames are the priority classes of the daemon pods keyed by etcd, mds, chunkserver or
+	// snapshotclone, the class keyed by 'all' is used for the daemons not set. The classes must exist.
+	// +optional
+	PriorityClassNames map[string]string `json:"priorityClassNames,omitempty"`
+
+	// SchedulerName is the scheduler of all the pods created by the operator, such as a topology-aware or batch
+	// scheduler. The default scheduler is used if it's not set.
+	// +optional
+	SchedulerName string `json:"schedulerName,omitempty"`
+
+	// Env are the environment variables added to all the containers created by the operator, such as proxy
+	// settings and timezone
+	// +optional
+	Env []v1.EnvVar `json:"env,omitempty"`
+
+	// EnvFrom are the sources of environment variables added to all the containers created by the operator
+	// +optional
+	EnvFrom []v1.EnvFromSource `json:"envFrom,omitempty"`
+
+	// Annotations are added to all the deployments, pods, jobs, services and configmaps created by the operator
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Labels are added to all the deployments, pods, jobs, services and configmaps created by the operator,
+	// the labels set by the operator can not be overridden
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// DNS is the DNS policy, DNS config and host aliases of all the pods created by the operator. The daemons
+	// use host network with DNS policy ClusterFirstWithHostNet by default.
+	// +optional
+	DNS *DNSSpec `json:"dns,omitempty"`
+
+	// Adoption imports an existing curve cluster that was deployed without the operator. The chunkservers of
+	// the cluster are recorded as formatted so their devices are not formatted again, and the operator manages
+	// the cluster from then on. Nothing is deployed until the cluster is adopted.
+	// +optional
+	Adoption *AdoptionSpec `json:"adoption,omitempty"`
+
+	// TopologyImport reproduces the layout of a cluster exported by the export-topology annotation, such as when
+	// the cluster is restored or adopted. The chunkservers of the devices in the export take the same ports and
+	// ids in topology. It's imported before any device is formatted.
+	// +optional
+	TopologyImport *TopologyImportSpec `json:"topologyImport,omitempty"`
+
+	// DevMode runs the cluster without real devices for development and CI. The devices are formatted into
+	// sparse files by a mock script without the pre-flight checks, and the chunkservers are mock containers
+	// that do nothing, so the logical pool is not created. It must not be used in production.
+	// +optional
+	DevMode bool `json:"devMode,omitempty"`
+
 	// Indicates user intent when deleting a cluster; blocks orchestration and should not be set if cluster
-	// deletion is not imminent.
+	// deletion is not imminent. The hosts are wiped only if the annotation curve.opencurve.io/confirm-destructive
+	// is set to the name of the cluster too, the deleted cluster waits for it until cleanupConfirm is cleared.
 	// +optional
 	// +nullable
 	CleanupConfirm string `json:"cleanupConfirm,omitempty"`
 }
 
+// PriorityClassName returns the priority class of the daemon pods
+func (s *CurveClusterSpec) PriorityClassName(daemon string) string {
+	if name, ok := s.PriorityClassNames[daemon]; ok {
+		return name
+	}
+	return s.PriorityClassNames[PriorityClassNameAll]
+}
+
+// DaemonEnv returns the environment variables and their sources of the daemon, which is etcd, mds,
+// chunkserver or snapshotclone. The ones of spec.env are returned for other daemons.
+func (s *CurveClusterSpec) DaemonEnv(daemon string) ([]v1.EnvVar, []v1.EnvFromSource) {
+	env := append([]v1.EnvVar{}, s.Env...)
+	envFrom := append([]v1.EnvFromSource{}, s.EnvFrom...)
+	switch daemon {
+	case "etcd":
+		env, envFrom = append(env, s.Etcd.Env...), append(envFrom, s.Etcd.EnvFrom...)
+	case "mds":
+		env, envFrom = append(env, s.Mds.Env...), append(envFrom, s.Mds.EnvFrom...)
+	case "chunkserver":
+		env, envFrom = append(env, s.Storage.Env...), append(envFrom, s.Storage.EnvFrom...)
+	case "snapshotclone":
+		env, envFrom = append(env, s.SnapShotClone.Env...), append(envFrom, s.SnapShotClone.EnvFrom...)
+	}
+	return env, envFrom
+}
+
+// DaemonMetadata returns the annotations and labels of the resources of the daemon, which is etcd, mds,
+// chunkserver or snapshotclone. The ones of the daemon override the ones of spec.annotations and spec.labels.
+func (s *CurveClusterSpec) DaemonMetadata(daemon string) (map[string]string, map[string]string) {
+	annotations, labels := map[string]string{}, map[string]string{}
+	merge := func(a, l map[string]string) {
+		for k, v := range a {
+			annotations[k] = v
+		}
+		for k, v := range l {
+			labels[k] = v
+		}
+	}
+	merge(s.Annotations, s.Labels)
+	switch daemon {
+	case "etcd":
+		merge(s.Etcd.Annotations, s.Etcd.Labels)
+	case "mds":
+		merge(s.Mds.Annotations, s.Mds.Labels)
+	case "chunkserver":
+		merge(s.Storage.Annotations, s.Storage.Labels)
+	case "snapshotclone":
+		merge(s.SnapShotClone.Annotations, s.SnapShotClone.Labels)
+	}
+	return annotations, labels
+}
+
+// DaemonDNS returns the DNS settings of the pods of the daemon, which is etcd, mds, chunkserver or snapshotclone.
+// The policy and config of the daemon override the ones of spec.dns, and the host aliases are added after them.
+// The ones of spec.dns are returned for other daemons.
+func (s *CurveClusterSpec) DaemonDNS(daemon string) DNSSpec {
+	dns := DNSSpec{}
+	merge := func(d *DNSSpec) {
+		if d == nil {
+			return
+		}
+		if d.DNSPolicy != "" {
+			dns.DNSPolicy = d.DNSPolicy
+		}
+		if d.DNSConfig != nil {
+			dns.DNSConfig = d.DNSConfig
+		}
+		dns.HostAliases = append(dns.HostAliases, d.HostAliases...)
+	}
+	merge(s.DNS)
+	switch daemon {
+	case "etcd":
+		merge(s.Etcd.DNS)
+	case "mds":
+		merge(s.Mds.DNS)
+	case "chunkserver":
+		merge(s.Storage.DNS)
+	case "snapshotclone":
+		merge(s.SnapShotClone.DNS)
+	}
+	return dns
+}
+
+// EtcdNodes returns the nodes to run etcd on
+func (s *CurveClusterSpec) EtcdNodes() []string {
+	if len(s.Etcd.Nodes) > 0 {
+		return s.Etcd.Nodes
+	}
+	return s.Nodes
+}
+
+// EtcdMembers returns the number of etcd members
+func (s *CurveClusterSpec) EtcdMembers() int {
+	if s.Etcd.StatefulSet != nil {
+		return int(s.Etcd.StatefulSet.GetReplicas())
+	}
+	return len(s.EtcdNodes())
+}
+
+// MdsNodes returns the nodes to run mds on
+func (s *CurveClusterSpec) MdsNodes() []string {
+	if len(s.Mds.Nodes) > 0 {
+		return s.Mds.Nodes
+	}
+	return s.Nodes
+}
+
+// SnapShotCloneNodes returns the nodes to run snapshotclone on
+func (s *CurveClusterSpec) SnapShotCloneNodes() []string {
+	if len(s.SnapShotClone.Nodes) > 0 {
+		return s.SnapShotClone.Nodes
+	}
+	return s.Nodes
+}
+
+// SnapShotCloneReplicas returns the number of the snapshotclone servers, 3 if not set
+func (s *CurveClusterSpec) SnapShotCloneReplicas() int {
+	if s.SnapShotClone.Replicas == 0 {
+		return 3
+	}
+	return s.SnapShotClone.Replicas
+}
+
+// DaemonNodes returns all the nodes to run etcd, mds and snapshotclone on without duplicates
+func (s *CurveClusterSpec) DaemonNodes() []string {
+	var nodes []string
+	seen := map[string]struct{}{}
+	for _, list := range [][]string{s.EtcdNodes(), s.MdsNodes(), s.SnapShotCloneNodes()} {
+		for _, n := range list {
+			if _, ok := seen[n]; !ok {
+				seen[n] = struct{}{}
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	return nodes
+}
+
+// StorageNodes returns the nodes to run chunkserver on
+func (s *CurveClusterSpec) StorageNodes() []string {
+	if !s.Storage.UseSelectedNodes {
+		return s.Storage.Nodes
+	}
+	var nodes []string
+	for _, n := range s.Storage.SelectedNodes {
+		nodes = append(nodes, n.Node)
+	}
+	return nodes
+}
+
 // CurveClusterStatus defines the observed state of CurveCluster
 type CurveClusterStatus struct {
-	// Phase is a summary of cluster state.
+	// Phase is a summary of cluster state, which is the step that the cluster is being created at such as
+	// Provisioning, Formatting, DeployingChunkservers and CreatingPools, or Ready, Failed and Deleting.
 	// It can be translated from the last conditiontype
 	Phase ConditionType `json:"phase,omitempty"`
 
+	// ObservedGeneration is the generation of the spec that is reconciled successfully or failed last time,
+	// the cluster is still being reconciled if it's less than the generation
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
 	// Condition contains current service state of cluster such as progressing/Ready/Failure...
 	Conditions []ClusterCondition `json:"conditions,omitempty"`
 
+	// History is the last transitions of the phase and the conditions from the oldest, the conditions only keep
+	// the latest one
+	// +optional
+	History []ClusterTransition `json:"history,omitempty"`
+
 	// Message shows summary message of cluster from ClusterState
 	// such as 'Curve Cluster Created successfully'
 	Message string `json:"message,omitempty"`
 
 	// CurveVersion shows curve version info on status field
 	CurveVersion ClusterVersion `json:"curveVersion,omitempty"`
-}
 
-// +kubebuilder:object:root=true
-// +kubebuilder:subresource:status
-// +kubebuilder:printcolumn:name="HostDataDir",JSONPath=".spec.hostDataDir",type=string
-// +kubebuilder:printcolumn:name="Version",JSONPath=".spec.curveVersion.image",type=string
-// +kubebuilder:printcolumn:name="Phase",JSONPath=".status.phase",type=string
+	// OperatorVersion is the version of curve-operator that reconciled the cluster successfully last time
+	// +optional
+	OperatorVersion string `json:"operatorVersion,omitempty"`
 
-// CurveCluster is the Schema for the curveclusters API
-type CurveCluster struct {
-	metav1.TypeMeta   `json:",inline"`
-	metav1.ObjectMeta `json:"metadata,omitempty"`
+	// HostPathLayoutVersion is the version of the layout of the directories under spec.hostDataDir on the nodes,
+	// the directories are migrated by jobs when the operator uses a newer layout
+	// +optional
+	HostPathLayoutVersion int `json:"hostPathLayoutVersion,omitempty"`
 
-	Spec   *CurveClusterSpec  `json:"spec,omitempty"`
-	Status CurveClusterStatus `json:"status,omitempty"`
-}
+	// HostDirNodes are the nodes that have the directories of the cluster under spec.hostDataDir, a node removed
+	// from the cluster is kept until its log and conf directories are pruned
+	// +optional
+	HostDirNodes []string `json:"hostDirNodes,omitempty"`
 
-// +kubebuilder:object:root=true
+	// Images are the images that the daemons are running with the digests resolved by their pods
+	// +optional
+	Images []ComponentImageStatus `json:"images,omitempty"`
 
-// CurveClusterList contains a list of CurveCluster
-type CurveClusterList struct {
-	metav1.TypeMeta `json:",inline"`
-	metav1.ListMeta `json:"metadata,omitempty"`
-	Items           []CurveCluster `json:"items"`
-}
+	// Versions shows the versions that the running daemons report
+	// +optional
+	Versions *VersionsStatus `json:"versions,omitempty"`
 
-// CurveVersionSpec represents the settings for the Curve version
-type CurveVersionSpec struct {
+	// ChunkServers shows the chunkservers that are not healthy because their nodes are NotReady or their devices are to be replaced
 	// +optional
-	Image string `json:"image,omitempty"`
+	ChunkServers []ChunkServerStatus `json:"chunkServers,omitempty"`
 
-	// +kubebuilder:validation:Enum=IfNotPresent;Always;Never;""
+	// DiskHealth shows the result of the last SMART check of each chunkserver device
 	// +optional
-	ImagePullPolicy v1.PullPolicy `json:"imagePullPolicy,omitempty"`
-}
+	DiskHealth []DiskHealthStatus `json:"diskHealth,omitempty"`
 
-// EtcdSpec is the spec of etcd
-type EtcdSpec struct {
+	// Capacity shows the capacity of the cluster and its logical pools reported by mds
 	// +optional
-	PeerPort int `json:"peerPort,omitempty"`
+	Capacity *CapacityStatus `json:"capacity,omitempty"`
 
+	// Copysets shows the health of the copysets reported by curve_ops_tool
 	// +optional
-	ClientPort int `json:"clientPort,omitempty"`
+	Copysets *CopysetsStatus `json:"copysets,omitempty"`
 
+	// StaleTopology shows the chunkservers and the servers left in topology by the renamed or removed nodes
 	// +optional
-	Config map[string]string `json:"config,omitempty"`
-}
+	StaleTopology *StaleTopologyStatus `json:"staleTopology,omitempty"`
 
-// MdsSpec is the spec of mds
-type MdsSpec struct {
+	// CopysetPlan shows how the copysets of the logical pool were computed
 	// +optional
-	Port int `json:"port,omitempty"`
+	CopysetPlan *CopysetPlanStatus `json:"copysetPlan,omitempty"`
 
+	// PoolCopysetPlans shows how the copysets of the logical pools of storage.pools were computed
 	// +optional
-	DummyPort int `json:"dummyPort,omitempty"`
+	PoolCopysetPlans []CopysetPlanStatus `json:"poolCopysetPlans,omitempty"`
 
+	// MdsReady is the number of the ready mds and all of them such as '3/3'
 	// +optional
-	Config map[string]string `json:"config,omitempty"`
+	MdsReady string `json:"mdsReady,omitempty"`
+
+	// ChunkServersReady is the number of the ready chunkservers and all of them such as '6/6'
+	// +optional
+	ChunkServersReady string `json:"chunkServersReady,omitempty"`
+
+	// LastFailure shows the last failure of the prepare-chunkfile or create-pool jobs
+	// +optional
+	LastFailure *JobFailureStatus `json:"lastFailure,omitempty"`
+
+	// NodeCapacity shows the effective size of the chunk file pools on each storage node
+	// +optional
+	NodeCapacity []NodeCapacityStatus `json:"nodeCapacity,omitempty"`
+
+	// MdsFlags shows the values of spec.mds.flags applied to the running mds and how they were applied
+	// +optional
+	MdsFlags []MdsFlagStatus `json:"mdsFlags,omitempty"`
+
+	// PendingStorageNodes shows the storage nodes that are not Ready or schedulable and have not joined the
+	// cluster, their chunkservers are started when they become Ready
+	// +optional
+	PendingStorageNodes []string `json:"pendingStorageNodes,omitempty"`
+
+	// Errors are the errors of the last reconcile that failed by category, they are cleared when a reconcile
+	// succeeds
+	// +optional
+	Errors []ClusterError `json:"errors,omitempty"`
+
+	// ConfigDump shows the last dump of the config requested by the dump-config annotation
+	// +optional
+	ConfigDump *ConfigDumpStatus `json:"configDump,omitempty"`
+
+	// Provisioning shows when each phase of the last provisioning of new devices ended
+	// +optional
+	Provisioning *ProvisioningStatus `json:"provisioning,omitempty"`
+
+	// DeviceProvisioning shows the format job of each device that was formatted by the operator, the devices
+	// formatted before are kept until they are removed from the spec
+	// +optional
+	DeviceProvisioning []DeviceProvisioningStatus `json:"deviceProvisioning,omitempty"`
+
+	// ChunkServerRegistration shows how many chunkservers of each zone have registered to mds, the logical pool
+	// is created after all of them registered
+	// +optional
+	ChunkServerRegistration []ZoneRegistrationStatus `json:"chunkServerRegistration,omitempty"`
+
+	// SelfTest shows the result of the last self test of maintenance.selfTest
+	// +optional
+	SelfTest *SelfTestStatus `json:"selfTest,omitempty"`
+
+	// Profile shows the last profile of chunkserver requested by the profile annotation
+	// +optional
+	Profile *ProfileStatus `json:"profile,omitempty"`
+
+	// Adoption shows the progress of adopting the existing cluster of spec.adoption
+	// +optional
+	Adoption *AdoptionStatus `json:"adoption,omitempty"`
+
+	// TopologyExport shows the last topology exported by the export-topology annotation
+	// +optional
+	TopologyExport *TopologyExportStatus `json:"topologyExport,omitempty"`
+
+	// TopologyImport shows the topology imported from spec.topologyImport
+	// +optional
+	TopologyImport *TopologyImportStatus `json:"topologyImport,omitempty"`
+
+	// Upgrade shows the curve image that the daemons are rolled to and the upgrade that is blocked or paused
+	// +optional
+	Upgrade *UpgradeStatus `json:"upgrade,omitempty"`
+
+	// Rollout shows the last batch of chunkservers restarted by spec.updateStrategy
+	// +optional
+	Rollout *RolloutStatus `json:"rollout,omitempty"`
 }
 
-// SnapShotCloneSpec is the spec of snapshot clone
-type SnapShotCloneSpec struct {
+// RolloutStatus is the rollout of the chunkservers by spec.updateStrategy
+type RolloutStatus struct {
+	// LastBatchTime is the time that the last batch of chunkservers restarted, the next batch is restarted after
+	// updateStrategy.pauseBetweenPods from it
+	LastBatchTime metav1.Time `json:"lastBatchTime,omitempty"`
+}
+
+// UpgradeStatus is the state of the upgrade of the curve image, it's kept in the status so that the daemons are not
+// rolled to a blocked image or past a paused canary after the operator restarts
+type UpgradeStatus struct {
+	// Image is the curve image that all the daemons were rolled to last time
 	// +optional
-	Enable bool `json:"enable,omitempty"`
+	Image string `json:"image,omitempty"`
+	// BlockedImage is the curve image that the upgrade check refused to upgrade to, it's not checked again until
+	// the image is changed
+	// +optional
+	BlockedImage string `json:"blockedImage,omitempty"`
+	// PausedImage is the curve image that the upgrade to is paused after the canary chunkservers degraded, until
+	// the canary is disabled or the image is reverted
+	// +optional
+	PausedImage string `json:"pausedImage,omitempty"`
+	// Canary shows the soak of the canary chunkservers of the upgrade in progress
+	// +optional
+	Canary *CanarySoakStatus `json:"canary,omitempty"`
+}
 
+// CanarySoakStatus is the soak of the canary chunkservers rolled to a curve image, the reconcile is requeued to
+// check the canaries until the soak duration passes
+type CanarySoakStatus struct {
+	// Image is the curve image that the canaries are rolled to
+	Image string `json:"image,omitempty"`
+	// StartTime is the time that the canaries were rolled and began to soak
+	StartTime metav1.Time `json:"startTime,omitempty"`
+	// Passed is true if the canaries soaked without degradation, the other chunkservers are being rolled then
 	// +optional
-	Port int `json:"port,omitempty"`
+	Passed bool `json:"passed,omitempty"`
+}
+
+// TopologyImportSpec is where the exported topology to import is
+type TopologyImportSpec struct {
+	// ConfigMap is the configmap in the cluster namespace that has the exported topology, which is the
+	// configmap written by the export-topology annotation of the exported cluster
+	ConfigMap string `json:"configMap"`
+}
 
+// TopologyExportStatus is a topology exported by the export-topology annotation
+type TopologyExportStatus struct {
+	// Request is the value of the export-topology annotation that was exported
+	Request string `json:"request,omitempty"`
+	// ConfigMap is the configmap that the topology is written to
+	ConfigMap string `json:"configMap,omitempty"`
+	// ExportedTime is the time that the topology was exported
+	ExportedTime metav1.Time `json:"exportedTime,omitempty"`
+	// Omitted are the configmaps that were not exported as the export is too large, the topology.json, the
+	// inventory and the cluster info are never omitted
 	// +optional
-	DummyPort int `json:"dummyPort,omitempty"`
+	Omitted []string `json:"omitted,omitempty"`
+}
 
+// TopologyImportStatus is the topology imported from spec.topologyImport
+type TopologyImportStatus struct {
+	// ConfigMap is the configmap that the topology was imported from
+	ConfigMap string `json:"configMap,omitempty"`
+	// ImportedTime is the time that the topology was imported, it's not set if the import failed
 	// +optional
-	ProxyPort int `json:"proxyPort,omitempty"`
+	ImportedTime *metav1.Time `json:"importedTime,omitempty"`
+	// ChunkServers is the number of the chunkservers whose bindings were imported
+	// +optional
+	ChunkServers int `json:"chunkServers,omitempty"`
+	// Message is why the import failed
+	// +optional
+	Message string `json:"message,omitempty"`
+}
 
+// AdoptionSpec is the existing cluster to adopt and where its daemons run in k8s. The daemons must use the
+// ports of the spec and the data directories under hostDataDir, they are replaced by the pods of the operator
+// after they are stopped by the admin.
+type AdoptionSpec struct {
+	// EtcdEndpoints are the client addresses ip:port of the etcd of the existing cluster
+	// +kubebuilder:validation:MinItems=1
+	EtcdEndpoints []string `json:"etcdEndpoints"`
+	// MdsEndpoints are the addresses ip:port of the mds of the existing cluster
+	// +kubebuilder:validation:MinItems=1
+	MdsEndpoints []string `json:"mdsEndpoints"`
+	// Hosts maps the hosts of the existing chunkservers to the nodes of k8s and their devices, every chunkserver
+	// in the topology that is not retired must be mapped
+	Hosts []AdoptedHostSpec `json:"hosts"`
+}
+
+// AdoptedHostSpec is a host of the existing cluster
+type AdoptedHostSpec struct {
+	// HostIP is the ip of the host in the topology of the existing cluster
+	HostIP string `json:"hostIP"`
+	// NodeName is the node of k8s that the host is
+	NodeName string `json:"nodeName"`
+	// ChunkServers are the devices of the chunkservers on the host, the devices must be in storage
 	// +optional
-	S3Config S3ConfigSpec `json:"s3Config,omitempty"`
+	ChunkServers []AdoptedChunkServerSpec `json:"chunkServers,omitempty"`
 }
 
-// S3ConfigSpec is the spec of s3 config
-type S3ConfigSpec struct {
-	AK                 string `json:"ak,omitempty"`
-	SK                 string `json:"sk,omitempty"`
-	NosAddress         string `json:"nosAddress,omitempty"`
-	SnapShotBucketName string `json:"bucketName,omitempty"`
+// AdoptedChunkServerSpec is an existing chunkserver and its device
+type AdoptedChunkServerSpec struct {
+	// Port is the port of the chunkserver in the topology
+	Port int `json:"port"`
+	// Device is the device of the chunkserver, such as /dev/sdb
+	Device string `json:"device"`
 }
 
-// StorageScopeSpec is the spec of storage scope
-type StorageScopeSpec struct {
+// AdoptionPhase is the phase of adopting an existing cluster
+type AdoptionPhase string
+
+const (
+	AdoptionDiscovering AdoptionPhase = "Discovering"
+	AdoptionAdopted     AdoptionPhase = "Adopted"
+	AdoptionFailed      AdoptionPhase = "Failed"
+)
+
+// AdoptionStatus is the progress of adopting the existing cluster of spec.adoption
+type AdoptionStatus struct {
+	// Phase is Discovering, Adopted or Failed
+	Phase AdoptionPhase `json:"phase,omitempty"`
+	// Message is why the adoption failed, such as the chunkservers that are not mapped
 	// +optional
-	UseSelectedNodes bool `json:"useSelectedNodes,omitempty"`
+	Message string `json:"message,omitempty"`
+	// ChunkServers is the number of the chunkservers adopted
+	// +optional
+	ChunkServers int `json:"chunkServers,omitempty"`
+	// AdoptedTime is the time that the cluster was adopted
+	// +optional
+	AdoptedTime *metav1.Time `json:"adoptedTime,omitempty"`
+}
+
+// SelfTestResult is the result of a self test
+type SelfTestResult string
+
+const (
+	SelfTestPassed SelfTestResult = "Passed"
+	SelfTestFailed SelfTestResult = "Failed"
+)
 
+// SelfTestStatus is the result of the last self test
+type SelfTestStatus struct {
+	// Result is Passed or Failed
+	Result SelfTestResult `json:"result,omitempty"`
+	// Message is the step that failed and its error, or the steps that passed
 	// +optional
-	Nodes []string `json:"nodes,omitempty"`
+	Message string `json:"message,omitempty"`
+	// LastRunTime is the time that the last test finished
+	LastRunTime metav1.Time `json:"lastRunTime,omitempty"`
+	// DurationSeconds is how long the last test took
+	// +optional
+	DurationSeconds int64 `json:"durationSeconds,omitempty"`
+	// ConsecutiveFailures is the number of the tests that failed in a row
+	// +optional
+	ConsecutiveFailures int32 `json:"consecutiveFailures,omitempty"`
+}
 
+// ProvisioningStatus is the timing of provisioning the chunkservers, the phases that have not ended are not set
+type ProvisioningStatus struct {
+	// FormatStartedAt is the time that the format jobs of the new devices were created
+	FormatStartedAt *metav1.Time `json:"formatStartedAt,omitempty"`
+	// FormatCompletedAt is the time that all the format jobs succeeded
 	// +optional
-	Port int `json:"port,omitempty"`
+	FormatCompletedAt *metav1.Time `json:"formatCompletedAt,omitempty"`
+	// ChunkServersReadyAt is the time that all the chunkservers were online
+	// +optional
+	ChunkServersReadyAt *metav1.Time `json:"chunkServersReadyAt,omitempty"`
+	// PoolCreatedAt is the time that the logical pool was created
+	// +optional
+	PoolCreatedAt *metav1.Time `json:"poolCreatedAt,omitempty"`
+}
+
+// ZoneRegistrationStatus is the number of the chunkservers of a zone registered to mds and all of them
+type ZoneRegistrationStatus struct {
+	PhysicalPool string `json:"physicalPool,omitempty"`
+	Zone         string `json:"zone,omitempty"`
+	Registered   int    `json:"registered"`
+	Expected     int    `json:"expected"`
+}
+
+// DeviceProvisioningState is the state of formatting a device
+type DeviceProvisioningState string
 
+const (
+	// DeviceProvisioningQueued is a device waiting for a slot of storage.maxConcurrentFormats
+	DeviceProvisioningQueued DeviceProvisioningState = "Queued"
+	// DeviceProvisioningFormatting is a device whose format job is running
+	DeviceProvisioningFormatting DeviceProvisioningState = "Formatting"
+	// DeviceProvisioningCompleted is a device whose format job succeeded
+	DeviceProvisioningCompleted DeviceProvisioningState = "Completed"
+	// DeviceProvisioningFailed is a device whose format job failed
+	DeviceProvisioningFailed DeviceProvisioningState = "Failed"
+)
+
+// DeviceProvisioningStatus is the format job of a device
+type DeviceProvisioningStatus struct {
+	NodeName string `json:"nodeName"`
+	Device   string `json:"device"`
+	// JobName is the prepare-chunkfile job of the device, it's empty if the device is queued
 	// +optional
-	CopySets int `json:"copySets,omitempty"`
+	JobName string `json:"jobName,omitempty"`
+	// State is Queued, Formatting, Completed or Failed
+	State DeviceProvisioningState `json:"state"`
+	// StartedAt is the time that the job started
+	// +optional
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+	// CompletedAt is the time that the job succeeded or failed
+	// +optional
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+	// Message is the reason that the job failed
+	// +optional
+	Message string `json:"message,omitempty"`
+}
 
+// ConfigDumpStatus is a dump of the config of the daemons
+type ConfigDumpStatus struct {
+	// Request is the value of the dump-config annotation that was dumped
+	Request string `json:"request,omitempty"`
+	// ConfigMap is the configmap that the desired config and the diffs are written to
+	ConfigMap string `json:"configMap,omitempty"`
+	// DumpedTime is the time that the config was dumped
+	DumpedTime metav1.Time `json:"dumpedTime,omitempty"`
+	// Differences are the pods that were started with an older config, and the config files of the pods that
+	// differ from the desired config such as 'curve-mds-a-xxx:/curvebs/mds/conf/mds.conf'
 	// +optional
-	Devices []DevicesSpec `json:"devices,omitempty"`
+	Differences []string `json:"differences,omitempty"`
+}
 
+// ProfileStatus is a profile collected from a chunkserver
+type ProfileStatus struct {
+	// ChunkServer is the name of the chunkserver deployment that was profiled
+	ChunkServer string `json:"chunkServer,omitempty"`
+	// Type is cpu, heap, growth, contention or rpcz
+	Type string `json:"type,omitempty"`
+	// ConfigMap is the configmap that the profile is written to, it's empty if the profile failed
 	// +optional
-	SelectedNodes []SelectedNodesSpec `json:"selectedNodes,omitempty"`
+	ConfigMap string `json:"configMap,omitempty"`
+	// CollectedTime is the time that the profile finished
+	CollectedTime metav1.Time `json:"collectedTime,omitempty"`
+	// Message is the error if the profile failed
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
-// DevicesSpec represents a disk to use in the cluster
-type DevicesSpec struct {
+// ErrorCategory is the category of an error of reconciling the cluster, for the automation to know whether
+// retrying helps
+// +kubebuilder:validation:Enum=Transient;NodeFailure;ConfigError
+type ErrorCategory string
+
+const (
+	// ErrorCategoryTransient is an error that is retried by the operator, such as a failed request to the api
+	// server or the daemons that are not ready yet
+	ErrorCategoryTransient ErrorCategory = "Transient"
+	// ErrorCategoryNodeFailure is an error of a node, such as a failed pre-flight check or a job that can't be
+	// created on it, it's retried but usually needs the node to be fixed
+	ErrorCategoryNodeFailure ErrorCategory = "NodeFailure"
+	// ErrorCategoryConfigError is an error of the spec, retrying doesn't help until the spec is fixed
+	ErrorCategoryConfigError ErrorCategory = "ConfigError"
+)
+
+// ClusterError is an error of reconciling the cluster
+type ClusterError struct {
+	Category ErrorCategory `json:"category"`
+	// Node is the node of the NodeFailure error
 	// +optional
-	Name string `json:"name,omitempty"`
+	Node    string `json:"node,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Count is the number of the reconciles that failed by the error in a row
+	// +optional
+	Count int32 `json:"count,omitempty"`
+	// LastSeen is the time of the last reconcile that failed by the error
+	// +optional
+	LastSeen metav1.Time `json:"lastSeen,omitempty"`
+}
 
+// ComponentImageStatus is an image that the pods of a daemon are running
+type ComponentImageStatus struct {
+	// Component is etcd, mds, chunkserver or snapshotclone
+	Component string `json:"component"`
+	Image     string `json:"image"`
+	// Digest is the digest of the image pulled by the pods, such as sha256:<digest>
 	// +optional
-	MountPath string `json:"mountPath,omitempty"`
+	Digest string `json:"digest,omitempty"`
+	// Verified is true if the signature of the image was verified by curveVersion.verification and its digest
+	// is the one verified
+	// +optional
+	Verified bool `json:"verified,omitempty"`
+}
 
+// VersionsStatus is the versions of the running daemons of each component
+type VersionsStatus struct {
+	// Components are the versions of each component and the number of the daemons running them
 	// +optional
-	Percentage int `json:"percentage,omitempty"`
+	Components []ComponentVersionStatus `json:"components,omitempty"`
+	// MixedComponents are the components whose daemons run different versions, such as after a partial upgrade
+	// +optional
+	MixedComponents []string `json:"mixedComponents,omitempty"`
+	// MixedSince is the time since some components have been running mixed versions
+	// +optional
+	MixedSince *metav1.Time `json:"mixedSince,omitempty"`
+	// LastUpdateTime is the time that the versions were updated, it's updated only if they changed
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
+// ComponentVersionStatus is a version that the daemons of a component are running
+type ComponentVersionStatus struct {
+	// Component is etcd, mds, chunkserver or snapshotclone
+	Component string `json:"component"`
+	// Version is the version exported by the metrics of the daemons, or the tag of their image if the metrics
+	// don't export it
+	Version string `json:"version"`
+	// Daemons is the number of the running daemons of the version
+	Daemons int `json:"daemons"`
+}
+
+// MdsFlagStatus is a flag of mds applied by the operator
+type MdsFlagStatus struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+	// HotReloaded is true if the value was set on the running mds, or false if mds was restarted to apply it
+	// +optional
+	HotReloaded bool `json:"hotReloaded,omitempty"`
+	// AppliedTime is the time that the value was applied
+	AppliedTime metav1.Time `json:"appliedTime,omitempty"`
+}
+
+// NodeCapacityStatus is the total size of the chunk file pools of the devices on a node, the devices without
+// capacity are not counted
+type NodeCapacityStatus struct {
+	Node      string `json:"node,omitempty"`
+	PoolBytes int64  `json:"poolBytes,omitempty"`
+}
+
+// CopysetPlanStatus is the copysets of the logical pool and how they were computed
+type CopysetPlanStatus struct {
+	// Pool is the name of the logical pool
+	Pool string `json:"pool,omitempty"`
+	// Auto is true if the copysets were computed by storage.autoCopySets
+	Auto bool `json:"auto,omitempty"`
+	// PoolBytes is the total size of the chunk file pools of the chunkservers
+	PoolBytes int64 `json:"poolBytes,omitempty"`
+	// ChunkSize is the size of the chunks in bytes
+	ChunkSize int64 `json:"chunkSize,omitempty"`
+	// Replicas is the number of the replicas of each copyset
+	Replicas int `json:"replicas,omitempty"`
+	// ChunkServerCopysets is the sum of the copysets of all the chunkservers
+	ChunkServerCopysets int `json:"chunkServerCopysets,omitempty"`
+	// Copysets is the number of the copysets of the logical pool, that is chunkServerCopysets / replicas
+	Copysets int `json:"copysets,omitempty"`
+}
+
+// JobFailureStatus is a failed container of a job and its last log lines
+type JobFailureStatus struct {
+	// Job is the name of the failed job
+	Job string `json:"job,omitempty"`
+	// Pod is the name of the pod of the job
+	Pod string `json:"pod,omitempty"`
+	// Container is the name of the failed container
+	Container string `json:"container,omitempty"`
+	// ExitCode is the exit code of the container
+	ExitCode int32 `json:"exitCode,omitempty"`
+	// Reason is the reason of the termination of the container
+	Reason string `json:"reason,omitempty"`
+	// Logs are the last log lines of the container
+	Logs string `json:"logs,omitempty"`
+	// Time is the time that the container terminated, it's the last of the identical failures
+	Time metav1.Time `json:"time,omitempty"`
+	// Count is the number of the identical failures, which are of the same job and container with the same
+	// exit code and reason
+	// +optional
+	Count int32 `json:"count,omitempty"`
+	// FirstTime is the time of the first of the identical failures
+	// +optional
+	FirstTime metav1.Time `json:"firstTime,omitempty"`
+}
+
+// CapacityStatus is the capacity of the cluster, the total and used bytes are of the physical pools
+type CapacityStatus struct {
+	TotalBytes  int64 `json:"totalBytes,omitempty"`
+	UsedBytes   int64 `json:"usedBytes,omitempty"`
+	UsedPercent int   `json:"usedPercent,omitempty"`
+	// Usage is the used and total bytes in binary units such as '1.5Ti/10.0Ti'
+	// +optional
+	Usage string `json:"usage,omitempty"`
+	// Pools are the logical pools
+	// +optional
+	Pools []PoolCapacityStatus `json:"pools,omitempty"`
+	// Zones are the capacity and the copyset replicas of the zones of the physical pools
+	// +optional
+	Zones []ZoneCapacityStatus `json:"zones,omitempty"`
+	// ZoneWarnings are the physical pools that can't keep the replicas of their copysets if a zone is lost, and
+	// the zones much smaller than the others
+	// +optional
+	ZoneWarnings []string `json:"zoneWarnings,omitempty"`
+	// ReservationWarnings are the devices overcommitted over storage.reservedPercentage, and the cluster and the
+	// pools whose used percent reaches the limit of it
+	// +optional
+	ReservationWarnings []string `json:"reservationWarnings,omitempty"`
+	// LastUpdateTime is the time that the capacity was updated, it's updated only if the used percent changed
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
+// CopysetsStatus is the number of all the copysets and the unhealthy ones, such as the copysets without leader
+// or with replicas lagging behind
+type CopysetsStatus struct {
+	Total     int `json:"total,omitempty"`
+	Unhealthy int `json:"unhealthy,omitempty"`
+	// LastUpdateTime is the time that the numbers were updated, it's updated only if they changed
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
+// StaleTopologyStatus is the chunkservers and the servers in topology that no daemon serves anymore, such as the
+// chunkservers registered again with new ids after their nodes are renamed
+type StaleTopologyStatus struct {
+	ChunkServers []StaleChunkServerStatus `json:"chunkServers,omitempty"`
+	// Servers are the servers of the hosts not in topology anymore as 'id hostname ip', they can't be removed by
+	// curve_ops_tool
+	Servers []string `json:"servers,omitempty"`
+	// LastCheckTime is the time that the topology was checked, it's updated only if the stale entries changed
+	LastCheckTime metav1.Time `json:"lastCheckTime,omitempty"`
+}
+
+// StaleChunkServerStatus is a stale chunkserver and what the operator did with it
+type StaleChunkServerStatus struct {
+	ID      int    `json:"id,omitempty"`
+	Address string `json:"address,omitempty"`
+	// Reason is Duplicate if a newer chunkserver has the same address, or HostRemoved if its host is not in topology
+	Reason string `json:"reason,omitempty"`
+	// Action is Retired or Pendding if the operator set the status of the chunkserver, Blocked if it waits for
+	// the confirmation, or Manual if it's online and has to be stopped and removed manually
+	Action string `json:"action,omitempty"`
+}
+
+// PoolCapacityStatus is the capacity of a logical pool
+type PoolCapacityStatus struct {
+	Name        string `json:"name,omitempty"`
+	TotalBytes  int64  `json:"totalBytes,omitempty"`
+	UsedBytes   int64  `json:"usedBytes,omitempty"`
+	UsedPercent int    `json:"usedPercent,omitempty"`
+}
+
+// ZoneCapacityStatus is the capacity of the chunkservers of a zone and the copyset replicas on them
+type ZoneCapacityStatus struct {
+	PhysicalPool string `json:"physicalPool,omitempty"`
+	Name         string `json:"name,omitempty"`
+	ChunkServers int    `json:"chunkServers,omitempty"`
+	TotalBytes   int64  `json:"totalBytes,omitempty"`
+	UsedBytes    int64  `json:"usedBytes,omitempty"`
+	UsedPercent  int    `json:"usedPercent,omitempty"`
+	// Replicas is the number of the copyset replicas on the chunkservers of the zone
+	Replicas int `json:"replicas,omitempty"`
+}
+
+// ChunkServerState represents the state of a chunkserver on a failed node or a device to be replaced
+type ChunkServerState string
+
+const (
+	// ChunkServerStateDegraded indicates the node of chunkserver is NotReady and in the grace period
+	ChunkServerStateDegraded ChunkServerState = "Degraded"
+	// ChunkServerStateOffline indicates the chunkserver has been set offline in topology to recover its copysets
+	ChunkServerStateOffline ChunkServerState = "Offline"
+	// ChunkServerStateReplacing indicates the chunkserver has been set offline to migrate its copysets for replacing its device
+	ChunkServerStateReplacing ChunkServerState = "Replacing"
+	// ChunkServerStatePendingReplacement indicates the device of chunkserver is predicted to fail by SMART and should be replaced
+	ChunkServerStatePendingReplacement ChunkServerState = "PendingReplacement"
+	// ChunkServerStateRetiring indicates the device of chunkserver has been removed from spec and its copysets are being migrated
+	ChunkServerStateRetiring ChunkServerState = "Retiring"
+)
+
+// DiskHealth is the overall SMART health of a device
+type DiskHealth string
+
+const (
+	DiskHealthPassed  DiskHealth = "Passed"
+	DiskHealthFailing DiskHealth = "Failing"
+	DiskHealthUnknown DiskHealth = "Unknown"
+)
+
+// DiskHealthStatus is the result of the last SMART check of a device
+type DiskHealthStatus struct {
+	// NodeName is the node that the device is on
+	NodeName string `json:"nodeName,omitempty"`
+	// Device is the name of device such as /dev/sdb
+	Device string `json:"device,omitempty"`
+	// Health is Passed, Failing or Unknown
+	Health DiskHealth `json:"health,omitempty"`
+	// LastCheckTime is the time that the device was checked
+	LastCheckTime metav1.Time `json:"lastCheckTime,omitempty"`
+	// Message is the output of smartctl
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	// PauseReconcileAnnotation stops the operator reconciling the cluster if it's "true"
+	PauseReconcileAnnotation = "operator.curve.io/pause-reconcile"
+	// ReplaceDeviceAnnotation requests to replace a device, the value is "<node>:<device>" such as "node1:/dev/sdb"
+	ReplaceDeviceAnnotation = "operator.curve.io/replace-device"
+	// DumpConfigAnnotation requests to write the config rendered by the operator for each daemon and its diff
+	// against the config files in the running pods into a configmap, the config is dumped again when the value
+	// changes such as a timestamp
+	DumpConfigAnnotation = "curve.opencurve.io/dump-config"
+	// ExportTopologyAnnotation requests to write the topology.json, the chunkserver inventory, the cluster info and
+	// the config rendered by the operator into a configmap, which spec.topologyImport of another cluster imports.
+	// The topology is exported again when the value changes such as a timestamp.
+	ExportTopologyAnnotation = "operator.curve.io/export-topology"
+	// ProfileAnnotation requests a profile of a chunkserver, the value is "<node>:<device>:<type>[:<seconds>]" on
+	// the cluster such as "node1:/dev/sdb:cpu:30", or "<type>[:<seconds>]" on a chunkserver deployment. The type
+	// is cpu, heap, growth, contention or rpcz. The annotation is removed after the profile is collected.
+	ProfileAnnotation = "operator.curve.io/profile"
+	// ConfirmDestructiveAnnotation confirms the operations that remove data from the pools, such as retiring the
+	// chunkservers of the devices removed from the spec or wiping the hosts by cleanupConfirm, if it's set to the
+	// name of the cluster. It guards the data against an errant edit of the spec.
+	ConfirmDestructiveAnnotation = "curve.opencurve.io/confirm-destructive"
+	// NodeIPAnnotation on a node is the ip used for the daemons on it instead of the node addresses, unless
+	// network.nodeAddresses has the node
+	NodeIPAnnotation = "curve.opencurve.io/ip"
+	// ConnectionInjectLabel on a namespace requests the connection secrets of all clusters to be copied to it if
+	// it's "enabled"
+	ConnectionInjectLabel = "curve.opencurve.io/inject"
+)
+
+// ChunkServerStatus is the status of a chunkserver on a failed node or a device to be replaced
+type ChunkServerStatus struct {
+	// Name is the name of chunkserver deployment
+	Name string `json:"name,omitempty"`
+	// NodeName is the node that the chunkserver is running on
+	NodeName string `json:"nodeName,omitempty"`
+	// State is Degraded, Offline, Replacing, PendingReplacement or Retiring
+	State ChunkServerState `json:"state,omitempty"`
+	// LastTransitionTime specifies last time the state changed
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Message is a human readable message indicating details about the state
+	Message string `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:storageversion
+// +kubebuilder:resource:shortName=curve,categories=curves
+// +kubebuilder:printcolumn:name="Phase",JSONPath=".status.phase",type=string
+// +kubebuilder:printcolumn:name="MDS",JSONPath=".status.mdsReady",type=string
+// +kubebuilder:printcolumn:name="ChunkServers",JSONPath=".status.chunkServersReady",type=string
+// +kubebuilder:printcolumn:name="Capacity",JSONPath=".status.capacity.usage",type=string
+// +kubebuilder:printcolumn:name="Version",JSONPath=".spec.curveVersion.image",type=string
+// +kubebuilder:printcolumn:name="HostDataDir",JSONPath=".spec.hostDataDir",type=string,priority=1
+// +kubebuilder:printcolumn:name="Age",JSONPath=".metadata.creationTimestamp",type=date
+
+// CurveCluster is the Schema for the curveclusters API
+type CurveCluster struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *CurveClusterSpec  `json:"spec,omitempty"`
+	Status CurveClusterStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// CurveClusterList contains a list of CurveCluster
+type CurveClusterList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CurveCluster `json:"items"`
+}
+
+// CurveVersionSpec represents the settings for the Curve version
+type CurveVersionSpec struct {
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// +kubebuilder:validation:Enum=IfNotPresent;Always;Never;""
+	// +optional
+	ImagePullPolicy v1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// Architectures are the architectures of nodes that the image supports, such as amd64 and arm64. The pods
+	// are only scheduled on the nodes of these architectures, and all the nodes of the cluster are checked to
+	// be one of them. Any architecture is allowed if not set
+	// +optional
+	Architectures []string `json:"architectures,omitempty"`
+
+	// ToolsImage is the image of the jobs that format the devices, create the pools and clean up the hosts,
+	// which is lighter than the curve image to pull. It must have bash, curve_format and curvebs-tool of the
+	// same version as the curve image, and can be pinned by digest such as "curvebs-tools@sha256:<digest>".
+	// Default is the curve image
+	// +optional
+	ToolsImage string `json:"toolsImage,omitempty"`
+
+	// Verification verifies the signatures of the curve image and the tools image by cosign before the
+	// workloads are created or updated with them
+	// +optional
+	Verification *ImageVerificationSpec `json:"verification,omitempty"`
+}
+
+// ImageVerificationSpec is how the signatures of the images are verified
+type ImageVerificationSpec struct {
+	// PublicKeySecret is the secret in the namespace of the cluster that has the cosign public key in cosign.pub
+	PublicKeySecret string `json:"publicKeySecret"`
+
+	// Image is the image of cosign. Default is gcr.io/projectsigstore/cosign:v1.13.1
+	// +optional
+	Image string `json:"image,omitempty"`
+}
+
+// JobImage returns the image of the format, create pool and cleanup jobs
+func (s *CurveVersionSpec) JobImage() string {
+	if s.ToolsImage != "" {
+		return s.ToolsImage
+	}
+	return s.Image
+}
+
+// EtcdSpec is the spec of etcd
+type EtcdSpec struct {
+	// +optional
+	PeerPort int `json:"peerPort,omitempty"`
+
+	// +optional
+	ClientPort int `json:"clientPort,omitempty"`
+
+	// +optional
+	Config map[string]string `json:"config,omitempty"`
+
+	// Nodes are the nodes to run etcd on, spec.nodes is used if not set
+	// +optional
+	Nodes []string `json:"nodes,omitempty"`
+
+	// LivenessProbe overrides the default liveness probe of etcd
+	// +optional
+	LivenessProbe *ProbeSpec `json:"livenessProbe,omitempty"`
+
+	// ReadinessProbe overrides the default readiness probe of etcd
+	// +optional
+	ReadinessProbe *ProbeSpec `json:"readinessProbe,omitempty"`
+
+	// LogLevel is the log level of etcd, the daemon uses its default level if not set.
+	// Changing it restarts the etcd pods one by one.
+	// +kubebuilder:validation:Enum=debug;info;warn;error;""
+	// +optional
+	LogLevel string `json:"logLevel,omitempty"`
+
+	// Env are the environment variables added to the containers of etcd, after the ones of spec.env
+	// +optional
+	Env []v1.EnvVar `json:"env,omitempty"`
+
+	// EnvFrom are the sources of environment variables added to the containers of etcd, after the ones of spec.envFrom
+	// +optional
+	EnvFrom []v1.EnvFromSource `json:"envFrom,omitempty"`
+
+	// Annotations are added to the resources of etcd, after the ones of spec.annotations
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Labels are added to the resources of etcd, after the ones of spec.labels
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// DNS overrides the DNS policy and DNS config of spec.dns for the pods of etcd, the host aliases are
+	// added after the ones of spec.dns
+	// +optional
+	DNS *DNSSpec `json:"dns,omitempty"`
+
+	// StatefulSet runs etcd as a StatefulSet with persistent volume claims instead of a deployment with host
+	// path on each of etcd.nodes. The members have stable names resolved by DNS, so they survive rescheduling
+	// and node renames. Switching an existing cluster between the modes is not supported.
+	// +optional
+	StatefulSet *EtcdStatefulSetSpec `json:"statefulSet,omitempty"`
+
+	// Backup saves periodic snapshots of etcd to a volume claim or an S3 bucket
+	// +optional
+	Backup EtcdBackupSpec `json:"backup,omitempty"`
+}
+
+// EtcdBackupSpec is the spec of the periodic etcd snapshots taken by a cron job, either persistentVolumeClaim
+// or s3 must be set as the destination
+type EtcdBackupSpec struct {
+	// Enable runs a cron job to save the snapshots of etcd by etcdctl
+	// +optional
+	Enable bool `json:"enable,omitempty"`
+
+	// Schedule is the cron schedule of the snapshots. Default is "0 0 * * *"
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
+
+	// Retention is the number of the newest snapshots kept at the destination, the older ones are pruned.
+	// Default is 7
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Retention int `json:"retention,omitempty"`
+
+	// PersistentVolumeClaim is the name of an existing volume claim in the cluster namespace to save the
+	// snapshots in
+	// +optional
+	PersistentVolumeClaim string `json:"persistentVolumeClaim,omitempty"`
+
+	// S3 uploads the snapshots to a bucket of an S3 compatible object store
+	// +optional
+	S3 *EtcdBackupS3Spec `json:"s3,omitempty"`
+
+	// Image is the image that prunes the snapshots and uploads them to S3, it must have curl 7.75 or later
+	// for S3. Default is the curve image
+	// +optional
+	Image string `json:"image,omitempty"`
+}
+
+// EtcdBackupS3Spec is the S3 bucket that keeps the etcd snapshots
+type EtcdBackupS3Spec struct {
+	// Endpoint is the URL of the object store, such as https://s3.us-east-1.amazonaws.com
+	Endpoint string `json:"endpoint"`
+
+	// Bucket is the bucket to upload the snapshots to
+	Bucket string `json:"bucket"`
+
+	// Prefix is the prefix of the object keys. Default is the cluster namespace followed by "/"
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// Region is the region to sign the requests for. Default is us-east-1
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// SecretName is the secret in the cluster namespace that has the keys AWS_ACCESS_KEY_ID and
+	// AWS_SECRET_ACCESS_KEY
+	SecretName string `json:"secretName"`
+}
+
+// EtcdStatefulSetSpec is the spec of etcd running as a StatefulSet
+type EtcdStatefulSetSpec struct {
+	// Replicas is the number of etcd members. Default is 3. Scaling adds or removes members one by one.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// StorageClassName is the storage class of the data volume claims, the default class is used if not set
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+
+	// Size is the size of the data volume of each member. Default is 10Gi
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+}
+
+// GetReplicas returns the number of etcd members, 3 if not set
+func (s *EtcdStatefulSetSpec) GetReplicas() int32 {
+	if s.Replicas == 0 {
+		return 3
+	}
+	return s.Replicas
+}
+
+// GetSize returns the size of the data volume of each member, 10Gi if not set
+func (s *EtcdStatefulSetSpec) GetSize() resource.Quantity {
+	if s.Size == nil {
+		return resource.MustParse("10Gi")
+	}
+	return *s.Size
+}
+
+// MdsSpec is the spec of mds
+type MdsSpec struct {
+	// +optional
+	Port int `json:"port,omitempty"`
+
+	// +optional
+	DummyPort int `json:"dummyPort,omitempty"`
+
+	// +optional
+	Config map[string]string `json:"config,omitempty"`
+
+	// Flags are the gflags of curvebs-mds passed by a flagfile. A changed flag is set on the running mds by the
+	// flags service of brpc on the dummy port if it's reloadable, mds is restarted one by one to apply the flags
+	// that are not reloadable or removed.
+	// +optional
+	Flags map[string]string `json:"flags,omitempty"`
+
+	// Nodes are the nodes to run mds on, spec.nodes is used if not set
+	// +optional
+	Nodes []string `json:"nodes,omitempty"`
+
+	// LivenessProbe overrides the default liveness probe of mds
+	// +optional
+	LivenessProbe *ProbeSpec `json:"livenessProbe,omitempty"`
+
+	// ReadinessProbe overrides the default readiness probe of mds
+	// +optional
+	ReadinessProbe *ProbeSpec `json:"readinessProbe,omitempty"`
+
+	// TerminationGracePeriodSeconds is how long mds is given to quit after its preStop hook syncs the dirty
+	// pages and stops it by SIGTERM, it's killed when the period ends. Default is 30
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TerminationGracePeriodSeconds int `json:"terminationGracePeriodSeconds,omitempty"`
+
+	// LogLevel is the log level of mds, the daemon uses its default level if not set.
+	// Changing it restarts the mds pods one by one.
+	// +kubebuilder:validation:Enum=debug;info;warn;error;""
+	// +optional
+	LogLevel string `json:"logLevel,omitempty"`
+
+	// Env are the environment variables added to the containers of mds, after the ones of spec.env
+	// +optional
+	Env []v1.EnvVar `json:"env,omitempty"`
+
+	// EnvFrom are the sources of environment variables added to the containers of mds, after the ones of spec.envFrom
+	// +optional
+	EnvFrom []v1.EnvFromSource `json:"envFrom,omitempty"`
+
+	// Annotations are added to the resources of mds, after the ones of spec.annotations
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Labels are added to the resources of mds, after the ones of spec.labels
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// DNS overrides the DNS policy and DNS config of spec.dns for the pods of mds, the host aliases are
+	// added after the ones of spec.dns
+	// +optional
+	DNS *DNSSpec `json:"dns,omitempty"`
+}
+
+// SnapShotCloneSpec is the spec of snapshot clone
+type SnapShotCloneSpec struct {
+	// +optional
+	Enable bool `json:"enable,omitempty"`
+
+	// +optional
+	Port int `json:"port,omitempty"`
+
+	// +optional
+	DummyPort int `json:"dummyPort,omitempty"`
+
+	// +optional
+	ProxyPort int `json:"proxyPort,omitempty"`
+
+	// +optional
+	S3Config S3ConfigSpec `json:"s3Config,omitempty"`
+
+	// Exposure exposes the http api of snapshotclone on the proxy port by the service curve-snapshotclone-api,
+	// and optionally an ingress or an openshift route, for the tools and the dashboard outside of the cluster
+	// +optional
+	Exposure *ExposureSpec `json:"exposure,omitempty"`
+
+	// Nodes are the nodes to run snapshotclone on, spec.nodes is used if not set
+	// +optional
+	Nodes []string `json:"nodes,omitempty"`
+
+	// NodeSelector selects the Ready nodes to run snapshotclone on instead of nodes, it's resolved at every
+	// reconcile. The nodes that run the snapshotclone servers are kept first so that the servers don't move.
+	// +optional
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+
+	// Replicas is the number of the snapshotclone servers, they run on the first replicas of the nodes one on
+	// each. Default is 3.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Replicas int `json:"replicas,omitempty"`
+
+	// LogLevel is the log level of snapshotclone, the daemon uses its default level if not set.
+	// Changing it restarts the snapshotclone pods one by one.
+	// +kubebuilder:validation:Enum=debug;info;warn;error;""
+	// +optional
+	LogLevel string `json:"logLevel,omitempty"`
+
+	// Env are the environment variables added to the containers of snapshotclone, after the ones of spec.env
+	// +optional
+	Env []v1.EnvVar `json:"env,omitempty"`
+
+	// EnvFrom are the sources of environment variables added to the containers of snapshotclone, after the ones of spec.envFrom
+	// +optional
+	EnvFrom []v1.EnvFromSource `json:"envFrom,omitempty"`
+
+	// Annotations are added to the resources of snapshotclone, after the ones of spec.annotations
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Labels are added to the resources of snapshotclone, after the ones of spec.labels
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// DNS overrides the DNS policy and DNS config of spec.dns for the pods of snapshotclone, the host aliases are
+	// added after the ones of spec.dns
+	// +optional
+	DNS *DNSSpec `json:"dns,omitempty"`
+}
+
+// ExposureSpec exposes the http endpoint of a component by a stable service, and optionally an ingress or an
+// openshift route of it
+type ExposureSpec struct {
+	// ServiceType is the type of the service, ClusterIP(default), NodePort or LoadBalancer
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer;""
+	// +optional
+	ServiceType v1.ServiceType `json:"serviceType,omitempty"`
+
+	// NodePort is the node port of the service of type NodePort or LoadBalancer, it's allocated by kubernetes
+	// if not set
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	NodePort int32 `json:"nodePort,omitempty"`
+
+	// ServiceAnnotations are added to the service, such as the settings of the load balancer
+	// +optional
+	ServiceAnnotations map[string]string `json:"serviceAnnotations,omitempty"`
+
+	// Ingress creates an ingress of the service if set
+	// +optional
+	Ingress *IngressSpec `json:"ingress,omitempty"`
+
+	// Route creates an openshift route of the service if set, it's skipped if routes are not supported
+	// +optional
+	Route *RouteSpec `json:"route,omitempty"`
+}
+
+// IngressSpec is the ingress of an exposed service
+type IngressSpec struct {
+	// Host is the host name of the ingress rule
+	Host string `json:"host"`
+
+	// IngressClassName is the class of the ingress controller that serves the ingress, the default class is
+	// used if not set
+	// +optional
+	IngressClassName string `json:"ingressClassName,omitempty"`
+
+	// TLSSecretName is the secret of the tls certificate of the host, the ingress serves plain http if not set
+	// +optional
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+
+	// Annotations are added to the ingress, such as the settings of the ingress controller
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// RouteSpec is the openshift route of an exposed service
+type RouteSpec struct {
+	// Host is the host name of the route, the router generates one if not set
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// TLSTermination terminates tls at the router by edge, the route serves plain http if not set
+	// +kubebuilder:validation:Enum=edge;""
+	// +optional
+	TLSTermination string `json:"tlsTermination,omitempty"`
+}
+
+// ProbeSpec is the settings of a liveness or readiness probe, the default value is used if a field is not set
+type ProbeSpec struct {
+	// Disabled disables the probe
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	InitialDelaySeconds int32 `json:"initialDelaySeconds,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	PeriodSeconds int32 `json:"periodSeconds,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+}
+
+// LoggingSpec is the logging settings of curve daemons
+type LoggingSpec struct {
+	// ToStdout makes mds, chunkserver and snapshotclone log to stderr instead of the files in log directory,
+	// so the logs can be collected from container logs by cluster-level log collectors such as Fluent Bit
+	// +optional
+	ToStdout bool `json:"toStdout,omitempty"`
+
+	// Rotate runs a sidecar in each daemon pod to remove the old log files in log directory
+	// +optional
+	Rotate LogRotateSpec `json:"rotate,omitempty"`
+}
+
+// LogRotateSpec is the settings of log rotation
+type LogRotateSpec struct {
+	// +optional
+	Enable bool `json:"enable,omitempty"`
+
+	// MaxAgeDays is the days to keep a log file after its last modification, default is 7
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxAgeDays int `json:"maxAgeDays,omitempty"`
+
+	// MaxSizeMB is the size of a log file at which the daemon rotates it to a new file, default is 1800 of glog
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxSizeMB int `json:"maxSizeMB,omitempty"`
+}
+
+// ZoneStrategy is the strategy to assign chunkserver nodes to zones
+type ZoneStrategy string
+
+const (
+	// ZoneStrategyRoundRobin assigns the nodes to zones in turn
+	ZoneStrategyRoundRobin ZoneStrategy = "RoundRobin"
+	// ZoneStrategyNodeLabel uses the value of node label as the zone of node
+	ZoneStrategyNodeLabel ZoneStrategy = "NodeLabel"
+)
+
+// TopologySpec is the layout of physical pool and zones in topology
+type TopologySpec struct {
+	// PhysicalPoolName is the name of physical pool, default is pool1
+	// +optional
+	PhysicalPoolName string `json:"physicalPoolName,omitempty"`
+
+	// Zones is the number of zones for RoundRobin strategy, it's at least 3 for three replicas, default is 3
+	// +kubebuilder:validation:Minimum=3
+	// +optional
+	Zones int `json:"zones,omitempty"`
+
+	// ZoneStrategy is RoundRobin or NodeLabel, default is RoundRobin
+	// +kubebuilder:validation:Enum=RoundRobin;NodeLabel
+	// +optional
+	ZoneStrategy ZoneStrategy `json:"zoneStrategy,omitempty"`
+
+	// ZoneLabel is the node label for NodeLabel strategy, default is topology.kubernetes.io/zone
+	// +optional
+	ZoneLabel string `json:"zoneLabel,omitempty"`
+
+	// Managed is false if the physical and logical pools are created and updated by the admin with curve tools,
+	// the operator still formats devices and runs the daemons but never registers the topology. The topology it
+	// would register is kept in the configmap topology-json-conf for reference. Default is true.
+	// +optional
+	Managed *bool `json:"managed,omitempty"`
+}
+
+// IsManaged returns true if the operator creates the pools
+func (t *TopologySpec) IsManaged() bool {
+	return t.Managed == nil || *t.Managed
+}
+
+// PodRestartOrder is the order to restart chunkservers
+type PodRestartOrder string
+
+const (
+	// PodRestartOrderSequential restarts chunkservers in the order of their names
+	PodRestartOrderSequential PodRestartOrder = "Sequential"
+	// PodRestartOrderNodeByNode restarts chunkservers node by node, the chunkservers restarted at the same time
+	// are always on the same node
+	PodRestartOrderNodeByNode PodRestartOrder = "NodeByNode"
+)
+
+// UpdateStrategySpec is how the operator rolls chunkserver deployments when their config or image changes,
+// the other daemons are always restarted one by one
+type UpdateStrategySpec struct {
+	// MaxUnavailable is the number of chunkservers restarted at the same time, default is 1
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxUnavailable int `json:"maxUnavailable,omitempty"`
+
+	// PodRestartOrder is Sequential(default) or NodeByNode
+	// +kubebuilder:validation:Enum=Sequential;NodeByNode
+	// +optional
+	PodRestartOrder PodRestartOrder `json:"podRestartOrder,omitempty"`
+
+	// PauseBetweenPods is the time to wait after a batch of chunkservers started before restarting the next batch,
+	// such as '30s'
+	// +optional
+	PauseBetweenPods metav1.Duration `json:"pauseBetweenPods,omitempty"`
+
+	// GracefulRestart transfers the copyset leaders away from the chunkservers before they are restarted
+	// +optional
+	GracefulRestart GracefulRestartSpec `json:"gracefulRestart,omitempty"`
+
+	// Canary upgrades some chunkservers to a new curve image first and the others after the canaries run it for
+	// a soak period, it doesn't apply to the restarts for the config changes
+	// +optional
+	Canary CanarySpec `json:"canary,omitempty"`
+}
+
+// CanarySpec is the canary upgrade of chunkservers. The upgrade is paused with the UpgradeBlocked condition if the
+// canaries restart or are not ready during the soak period, or the copysets are not healthy at the end of it.
+type CanarySpec struct {
+	// +optional
+	Enable bool `json:"enable,omitempty"`
+
+	// Chunkservers is the number of the canary chunkservers, default is 1
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Chunkservers int `json:"chunkservers,omitempty"`
+
+	// SoakDuration is how long the canaries run the new image before the others are upgraded, such as '30m'.
+	// Default is 10m
+	// +optional
+	SoakDuration metav1.Duration `json:"soakDuration,omitempty"`
+}
+
+// GracefulRestartSpec is the leader transfer of a chunkserver before it's restarted, the IO of the copysets
+// it leads doesn't wait for the election of a new leader
+type GracefulRestartSpec struct {
+	// Enable transfers the leaders of the copysets on the chunkservers by curve_ops_tool before restarting them
+	// +optional
+	Enable bool `json:"enable,omitempty"`
+
+	// TimeoutSeconds is how long to wait for the chunkservers to lead no copyset, they are restarted anyway
+	// after it. Default is 60
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+}
+
+// DrainProtectionSpec guards each chunkserver by a PodDisruptionBudget that allows no eviction. When a node is
+// cordoned such as by kubectl drain, the leaders of the copysets on its chunkservers are transferred away in the
+// way of updateStrategy.gracefulRestart, then their budgets are deleted so that the evictions retried by the drain
+// succeed. The budgets are created again when the node is uncordoned.
+type DrainProtectionSpec struct {
+	// +optional
+	Enable bool `json:"enable,omitempty"`
+}
+
+// NetworkSpec is how the daemons are addressed by each other and the clients
+type NetworkSpec struct {
+	// UseServiceDNS registers the DNS names of the mds and snapshotclone services instead of node IPs
+	// in the generated configs, the curve image must resolve host names of the endpoints
+	// +optional
+	UseServiceDNS bool `json:"useServiceDNS,omitempty"`
+
+	// PreferredAddressType is the type of node address used for the daemons, the other type is used if a node
+	// has no such address. Default is InternalIP
+	// +kubebuilder:validation:Enum=InternalIP;ExternalIP
+	// +optional
+	PreferredAddressType v1.NodeAddressType `json:"preferredAddressType,omitempty"`
+
+	// CIDRs filter the node addresses for the nodes with multiple addresses, such as the addresses of the
+	// storage network. The first address of the preferred type in any of the CIDRs is used
+	// +optional
+	CIDRs []string `json:"cidrs,omitempty"`
+
+	// NodeAddresses maps the node names to the ips used for the daemons on them, such as the nodes whose kubelets
+	// register only their hostnames. It overrides the curve.opencurve.io/ip annotation of the node and the node
+	// addresses
+	// +optional
+	NodeAddresses map[string]string `json:"nodeAddresses,omitempty"`
+}
+
+// DNSSpec is the DNS settings of the pods
+type DNSSpec struct {
+	// DNSPolicy is the DNS policy of the pods, the policy set by the operator is used if not set. Default and None
+	// use the DNS of the nodes or DNSConfig only instead of the cluster DNS.
+	// +kubebuilder:validation:Enum=ClusterFirstWithHostNet;ClusterFirst;Default;None
+	// +optional
+	DNSPolicy v1.DNSPolicy `json:"dnsPolicy,omitempty"`
+
+	// DNSConfig is the name servers, searches and options added to the DNS of the pods, it's required if
+	// DNSPolicy is None
+	// +optional
+	DNSConfig *v1.PodDNSConfig `json:"dnsConfig,omitempty"`
+
+	// HostAliases are the entries added to the hosts file of the pods
+	// +optional
+	HostAliases []v1.HostAlias `json:"hostAliases,omitempty"`
+}
+
+// TimeSyncSpec is the check of the clocks of the nodes before the cluster is created. Curve is sensitive to clock
+// skew, the offset of each node to its NTP source is read from chrony or ntpd of the node, and the ClockSkew
+// condition of the cluster is set True if the skew between the nodes exceeds MaxSkewMilliseconds or some node is
+// not synchronized.
+type TimeSyncSpec struct {
+	// Check runs a job on each node to read its clock offset before the cluster is created
+	// +optional
+	Check bool `json:"check,omitempty"`
+
+	// MaxSkewMilliseconds is the maximum skew between the clocks of the nodes. Default is 500
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxSkewMilliseconds int `json:"maxSkewMilliseconds,omitempty"`
+}
+
+// CleanupSpec is the policy to prune the directories left on the nodes by the removed daemons
+type CleanupSpec struct {
+	// RetainLogsDays is how many days the log directories of the removed daemons are kept after they were last
+	// written, a cron job on each node prunes them daily. The conf directory of a node removed from the cluster
+	// is pruned with its logs. The data directories are never pruned. Default is 0 that keeps them forever.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	RetainLogsDays int `json:"retainLogsDays,omitempty"`
+}
+
+// ConnectionSpec exposes the connection info of the cluster to the clients such as the CSI driver. The secret
+// curve-cluster-connection in the namespace of the cluster has the cluster name, the endpoints of etcd, mds and
+// snapshotclone, and the snippets of client.conf and tools.conf. The configmap curve-client-conf has the full
+// client.conf to be mounted by the clients and nebd-server. They are updated when the endpoints change.
+type ConnectionSpec struct {
+	// ExportNamespaces are the namespaces that the secret and the configmap are copied to as
+	// curve-cluster-connection-<namespace of the cluster> and curve-client-conf-<namespace of the cluster> besides
+	// the namespaces labeled curve.opencurve.io/inject=enabled, the copies are deleted when the namespaces are
+	// removed or the cluster is deleted
+	// +optional
+	ExportNamespaces []string `json:"exportNamespaces,omitempty"`
+
+	// ClientConfig overrides the options of client.conf, such as mds.maxRetryMS. The client tries the mds in the
+	// order of mds.listen.addr, which is the order of the mds nodes, and changes to the next one after
+	// mds.maxFailedTimesBeforeChangeMDS failures. mds.listen.addr can't be overridden.
+	// +optional
+	ClientConfig map[string]string `json:"clientConfig,omitempty"`
+}
+
+// NotificationEvent is a major event of the cluster that is pushed to the webhooks
+type NotificationEvent string
+
+const (
+	// NotificationEventClusterReady is pushed when the cluster becomes Ready from another phase
+	NotificationEventClusterReady NotificationEvent = "ClusterReady"
+	// NotificationEventFormatFailed is pushed when a prepare-chunkfile job failed to format a device
+	NotificationEventFormatFailed NotificationEvent = "FormatFailed"
+	// NotificationEventChunkServerOffline is pushed when the chunkservers of a NotReady node are set offline
+	NotificationEventChunkServerOffline NotificationEvent = "ChunkServerOffline"
+	// NotificationEventUpgradeFinished is pushed when all daemons are rolled to the new curve image
+	NotificationEventUpgradeFinished NotificationEvent = "UpgradeFinished"
+)
+
+// NotificationFormat is the payload posted to a webhook
+type NotificationFormat string
+
+const (
+	// NotificationFormatJSON posts the event in json with the fields event, cluster, namespace, message and time
+	NotificationFormatJSON NotificationFormat = "json"
+	// NotificationFormatSlack posts {"text": "..."} that is accepted by the incoming webhooks of Slack and the
+	// compatible ones such as Mattermost
+	NotificationFormatSlack NotificationFormat = "slack"
+)
+
+// NotificationsSpec is the spec of the notifications of the major events of the cluster. The events are posted
+// to the webhooks once when they happen, a failed post is logged and not retried.
+type NotificationsSpec struct {
+	// +optional
+	Webhooks []NotificationWebhookSpec `json:"webhooks,omitempty"`
+}
+
+// NotificationWebhookSpec is a webhook that receives the events of the cluster
+type NotificationWebhookSpec struct {
+	// URL is the url that the events are posted to
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// URLSecret is the key of a secret in the namespace of the cluster that holds the url, it's used instead of
+	// url if the url is a credential such as the one of a Slack webhook
+	// +optional
+	URLSecret *v1.SecretKeySelector `json:"urlSecret,omitempty"`
+
+	// Format is the payload posted to the url. Default is json
+	// +kubebuilder:validation:Enum=json;slack
+	// +optional
+	Format NotificationFormat `json:"format,omitempty"`
+
+	// Events are the events posted to the url. Default is all of ClusterReady, FormatFailed, ChunkServerOffline
+	// and UpgradeFinished
+	// +optional
+	Events []NotificationEvent `json:"events,omitempty"`
+}
+
+// Subscribed returns true if the event is posted to the webhook
+func (w *NotificationWebhookSpec) Subscribed(event NotificationEvent) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// MaintenanceSpec is the spec of the periodic maintenance of the cluster
+type MaintenanceSpec struct {
+	// +optional
+	Scrub ScrubSpec `json:"scrub,omitempty"`
+
+	// +optional
+	SelfTest SelfTestSpec `json:"selfTest,omitempty"`
+}
+
+// MaintenanceWindowSpec is the windows of the disruptive operations, which are the restarts of the daemons to apply
+// a new image, log level, mds flags or chunkserver args, and the migration of the copysets of the chunkservers
+// whose devices are removed or to be replaced. The operations started in a window are not interrupted when it ends.
+type MaintenanceWindowSpec struct {
+	// Schedule is the cron schedule of the start of the windows in UTC, such as "0 2 * * 6" for 2:00 every Saturday
+	Schedule string `json:"schedule"`
+
+	// WindowMinutes is the length of each window. Default is 240
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	WindowMinutes int `json:"windowMinutes,omitempty"`
+}
+
+// ScrubSpec schedules the consistency scrub of the copysets. A cron job turns on the scan of the logical pools at
+// the start of each window and turns it off at the end, the chunkservers compare the chunks of the replicas of
+// each copyset during the scan and the inconsistent copysets are reported by curve_ops_tool scan-status.
+type ScrubSpec struct {
+	// +optional
+	Enable bool `json:"enable,omitempty"`
+
+	// Schedule is the cron schedule of the start of the windows. Default is "0 2 * * *"
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
+
+	// WindowMinutes is the length of each window, the scan is turned off at the end of it. Default is 240
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	WindowMinutes int `json:"windowMinutes,omitempty"`
+
+	// LogicalPoolIDs are the ids of the logical pools to scan. Default is 1, the logical pool created by
+	// the operator
+	// +optional
+	LogicalPoolIDs []int `json:"logicalPoolIDs,omitempty"`
+}
+
+// SelfTestSpec schedules the end-to-end test of the storage path. A cron job creates a small test volume, writes
+// and reads back the data through curve-nbd, snapshots the volume if snapshotclone is enabled and deletes them,
+// the result is shown in status.selfTest and the metrics of operator. The nodes must have the nbd kernel module.
+type SelfTestSpec struct {
+	// +optional
+	Enable bool `json:"enable,omitempty"`
+
+	// Schedule is the cron schedule of the test. Default is "30 * * * *"
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
+
+	// TimeoutSeconds is how long a test runs before it fails. Default is 600
+	// +kubebuilder:validation:Minimum=60
+	// +optional
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+}
+
+// MonitoringSpec is the spec of the monitoring of cluster by prometheus
+type MonitoringSpec struct {
+	// Alerts generates the alerting rules of the cluster
+	// +optional
+	Alerts AlertsSpec `json:"alerts,omitempty"`
+}
+
+// AlertsSpec is the spec of the PrometheusRule generated for the common failure modes. The prometheus operator
+// must be installed, and the alerts are based on the metrics of kube-state-metrics and curve-operator.
+type AlertsSpec struct {
+	// +optional
+	Enable bool `json:"enable,omitempty"`
+
+	// ChunkServerDownMinutes is how long a chunkserver is unavailable before alerting. Default is 5
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	ChunkServerDownMinutes int `json:"chunkServerDownMinutes,omitempty"`
+
+	// PoolUsagePercent is the used percent of a logical pool to alert. Default is 85
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	PoolUsagePercent int `json:"poolUsagePercent,omitempty"`
+
+	// Labels are added to the PrometheusRule so that it's selected by the prometheus
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// ToolsSpec is the spec of the tools pod that runs curve_ops_tool and curve client against the cluster
+type ToolsSpec struct {
+	// Enable deploys a long-running tools pod for diagnostics by kubectl exec
+	// +optional
+	Enable bool `json:"enable,omitempty"`
+}
+
+// DashboardSpec is the spec of the curve web dashboard, which manages the cluster through its mds, snapshotclone
+// and etcd
+type DashboardSpec struct {
+	// Enable deploys the dashboard, it's deleted if disabled
+	// +optional
+	Enable bool `json:"enable,omitempty"`
+
+	// Image is the image of the dashboard. Default is opencurvedocker/curve-manager:latest
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Port is the port that the dashboard listens on. Default is 8080
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Port int `json:"port,omitempty"`
+
+	// CredentialsSecret is the secret of the admin login of the dashboard with the keys username and password.
+	// If not set, the secret curve-dashboard-credentials of the user admin with a random password is created.
+	// +optional
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+
+	// Exposure exposes the dashboard by the service curve-dashboard, and optionally an ingress or an openshift
+	// route. A ClusterIP service is created if not set.
+	// +optional
+	Exposure *ExposureSpec `json:"exposure,omitempty"`
+}
+
+// DiskHealthSpec is the spec of the periodic SMART check of chunkserver devices. The chunkserver
+// of a device that is predicted to fail is marked PendingReplacement in status.
+type DiskHealthSpec struct {
+	// Enable runs a cron job on each storage node to check the devices by smartctl
+	// +optional
+	Enable bool `json:"enable,omitempty"`
+
+	// Schedule is the cron schedule of the check. Default is "0 * * * *"
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
+
+	// Image is the image that has smartctl. Default is the curve image
+	// +optional
+	Image string `json:"image,omitempty"`
+}
+
+// PrepareJobSpec is the job level settings of the prepare-chunkfile jobs, formatting competes with
+// the workload pods on busy nodes
+type PrepareJobSpec struct {
+	// ActiveDeadlineSeconds is how long the job may run before it's terminated. Default is no deadline
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+
+	// BackoffLimit is the number of retries before the job is marked failed. Default is 6
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	BackoffLimit *int32 `json:"backoffLimit,omitempty"`
+
+	// PriorityClassName is the priority class of the job pods
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// Tolerations of the job pods, such as to format devices on tainted storage nodes
+	// +optional
+	Tolerations []v1.Toleration `json:"tolerations,omitempty"`
+}
+
+// IOLimitsSpec limits the disk IO of a chunkserver pod. Kubernetes has no IO limits of pods, so they are written to
+// the cgroup of the pod by a privileged init container, in io.weight and io.max of cgroup v2, or blkio.weight and
+// blkio.throttle of cgroup v1. The rates apply to the block device of the chunkserver, they are skipped for the
+// path and loop devices. The limits are not applied to the spdk engine which bypasses the kernel.
+type IOLimitsSpec struct {
+	// Weight is the proportional IO weight of the pod from 1 to 10000 with 100 as the default of cgroup v2, it's
+	// scaled to 10 to 1000 of blkio.weight on cgroup v1. It works with the IO schedulers that support it such as bfq.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=10000
+	// +optional
+	Weight int `json:"weight,omitempty"`
+
+	// ReadBytesPerSecond limits the read throughput of the device
+	// +optional
+	ReadBytesPerSecond *resource.Quantity `json:"readBytesPerSecond,omitempty"`
+
+	// WriteBytesPerSecond limits the write throughput of the device
+	// +optional
+	WriteBytesPerSecond *resource.Quantity `json:"writeBytesPerSecond,omitempty"`
+
+	// ReadIOPS limits the read operations per second of the device
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	ReadIOPS int64 `json:"readIOPS,omitempty"`
+
+	// WriteIOPS limits the write operations per second of the device
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	WriteIOPS int64 `json:"writeIOPS,omitempty"`
+}
+
+// CPUPinningSpec binds chunkservers to cpus, chunkserver is sensitive to the scheduling latency of its threads
+type CPUPinningSpec struct {
+	// CPUs is the integer number of cpus requested and limited for each chunkserver. With memory, the
+	// chunkserver pods get the Guaranteed QoS class, so that kubelet with the static CPU manager policy
+	// assigns exclusive cpus to them
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	CPUs int `json:"cpus,omitempty"`
+
+	// Memory is the memory requested and limited for each chunkserver, it's required if cpus is set. The
+	// chunkservers with 16Gi or more lock their buffers in memory with the IPC_LOCK capability.
+	// +optional
+	Memory *resource.Quantity `json:"memory,omitempty"`
+
+	// CPUSet is the cpu list such as '0-7,16-23' that chunkservers are bound to by taskset. The cpus must be
+	// allowed to the pod, otherwise chunkserver runs unbound.
+	// +optional
+	CPUSet string `json:"cpuSet,omitempty"`
+
+	// NUMAAligned binds the chunkserver of a block device to the cpus of the NUMA node that the device is
+	// attached to, which is read from sysfs of each node. It's ignored if a cpu set is given.
+	// +optional
+	NUMAAligned bool `json:"numaAligned,omitempty"`
+}
+
+// S3ConfigSpec is the spec of s3 config
+type S3ConfigSpec struct {
+	AK                 string `json:"ak,omitempty"`
+	SK                 string `json:"sk,omitempty"`
+	NosAddress         string `json:"nosAddress,omitempty"`
+	SnapShotBucketName string `json:"bucketName,omitempty"`
+}
+
+// StorageScopeSpec is the spec of storage scope
+type StorageScopeSpec struct {
+	// +optional
+	UseSelectedNodes bool `json:"useSelectedNodes,omitempty"`
+
+	// +optional
+	Nodes []string `json:"nodes,omitempty"`
+
+	// NodeSelector selects the Ready nodes to run chunkservers on instead of nodes, it's resolved at every
+	// reconcile. The nodes that stop matching keep their chunkservers until the chunkservers are removed manually
+	// +optional
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+
+	// +optional
+	Port int `json:"port,omitempty"`
+
+	// +optional
+	CopySets int `json:"copySets,omitempty"`
+
+	// AutoCopySets computes the copysets of each chunkserver from the size of its chunk file pool, the chunk size
+	// and the replicas instead of copySets when the logical pool is created. The chunkservers of the devices
+	// without capacity get copySets.
+	// +optional
+	AutoCopySets bool `json:"autoCopySets,omitempty"`
+
+	// Pools put the chunkservers of their devices in a physical pool and a logical pool of their own, with the
+	// chunk size of the pool. The devices not in any pool are in the physical pool of topology and the default
+	// logical pool with 16Mi chunks. The pools are registered when the cluster is created.
+	// +optional
+	Pools []PoolSpec `json:"pools,omitempty"`
+
+	// +optional
+	Devices []DevicesSpec `json:"devices,omitempty"`
+
+	// +optional
+	SelectedNodes []SelectedNodesSpec `json:"selectedNodes,omitempty"`
+
+	// MinPoolSize is the minimum size of the chunk file pool of each device, which is the capacity of the
+	// device multiplied by its percentage. The devices without capacity are not checked.
+	// +optional
+	MinPoolSize *resource.Quantity `json:"minPoolSize,omitempty"`
+
+	// ReservedPercentage is the percentage of each device kept out of its chunk file pool for the filesystem and
+	// the growth of the cluster, the percentage of the devices is limited to 100 minus it by overcommitPolicy. The
+	// capacity of topology is computed by the limited percentage. Default is 0 that reserves nothing.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=90
+	// +optional
+	ReservedPercentage int `json:"reservedPercentage,omitempty"`
+
+	// OvercommitPolicy is what to do with the devices whose percentage is over 100 minus reservedPercentage, Cap
+	// (default) formats them by the limit, Reject refuses the spec and Allow formats them by their percentage. The
+	// overcommitted devices and the usage over the limit are warned in status.capacity.
+	// +kubebuilder:validation:Enum=Cap;Reject;Allow;""
+	// +optional
+	OvercommitPolicy OvercommitPolicy `json:"overcommitPolicy,omitempty"`
+
+	// FailoverGracePeriodSeconds is how long a node can be NotReady before the chunkservers on it are
+	// set offline in topology to recover their copysets on other chunkservers. Default is 300.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	FailoverGracePeriodSeconds int `json:"failoverGracePeriodSeconds,omitempty"`
+
+	// DrainProtection makes kubectl drain safe on the storage nodes
+	// +optional
+	DrainProtection DrainProtectionSpec `json:"drainProtection,omitempty"`
+
+	// AllowDeviceReformat allows to format the devices that have existing filesystem or partition table
+	// signatures. Otherwise the pre-flight checks refuse to format them to protect their data
+	// +optional
+	AllowDeviceReformat bool `json:"allowDeviceReformat,omitempty"`
+
+	// WipeRemovedDevices erases the signatures of a block device after it's removed from the device list
+	// and its chunkserver is retired, so that it can be reused without allowDeviceReformat
+	// +optional
+	WipeRemovedDevices bool `json:"wipeRemovedDevices,omitempty"`
+
+	// DiskHealth checks the SMART health of devices periodically
+	// +optional
+	DiskHealth DiskHealthSpec `json:"diskHealth,omitempty"`
+
+	// IntegrityCheck checks the device before a chunkserver restarts from an unclean shutdown
+	// +optional
+	IntegrityCheck IntegrityCheckSpec `json:"integrityCheck,omitempty"`
+
+	// PrepareJob tunes the jobs that format devices and prepare chunk files
+	// +optional
+	PrepareJob PrepareJobSpec `json:"prepareJob,omitempty"`
+
+	// MaxConcurrentFormats is the number of the prepare-chunkfile jobs running at the same time, the other devices
+	// are queued and formatted as the running jobs finish. Default is 0 that formats all the devices at once.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxConcurrentFormats int `json:"maxConcurrentFormats,omitempty"`
+
+	// FormatOrder are the nodes or the devices such as node1:/dev/sdb whose devices are formatted first in the
+	// order, the other nodes follow by their annotation operator.curve.io/format-priority, the higher the earlier.
+	// It's to get a part of the cluster ready early with maxConcurrentFormats.
+	// +optional
+	FormatOrder []string `json:"formatOrder,omitempty"`
+
+	// MinReadyNodes is the number of the Ready and schedulable storage nodes required to create the cluster, the
+	// chunkservers are created on them and the other storage nodes join the cluster when they become Ready.
+	// Default is 0 that creates the cluster on the ready storage nodes, at least one.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinReadyNodes int `json:"minReadyNodes,omitempty"`
+
+	// CPUPinning binds chunkservers to cpus
+	// +optional
+	CPUPinning CPUPinningSpec `json:"cpuPinning,omitempty"`
+
+	// Engine is the io engine of chunkservers, aio(default) on the filesystem of devices, or spdk on NVMe
+	// devices bound to vfio-pci. The chunkserver image must support the engine. It can't be changed after
+	// the devices are formatted.
+	// +kubebuilder:validation:Enum=aio;spdk;""
+	// +optional
+	Engine StorageEngine `json:"engine,omitempty"`
+
+	// SPDK is the settings of the spdk engine
+	// +optional
+	SPDK SPDKSpec `json:"spdk,omitempty"`
+
+	// ExtraArgs are the flags passed to the chunkserver binary keyed by flag name without the leading dash,
+	// such as raft_sync: "false". A flag overrides the one of the same name set by the start script, the
+	// others are appended. Changing it restarts the chunkservers by updateStrategy.
+	// +optional
+	ExtraArgs map[string]string `json:"extraArgs,omitempty"`
+
+	// IO limits the disk IO of each chunkserver pod, keeping chunkservers from starving the workloads on the same
+	// nodes
+	// +optional
+	IO IOLimitsSpec `json:"io,omitempty"`
+
+	// Sysctls are the kernel parameters of the storage nodes keyed by name, such as fs.aio-max-nr: "1048576" and
+	// vm.swappiness: "1". They are set and verified by the pre-flight job before the devices are formatted, which
+	// fails if some of them can't be set, and by a privileged init container of each chunkserver so that they are
+	// set again after the node reboots.
+	// +optional
+	Sysctls map[string]string `json:"sysctls,omitempty"`
+
+	// LivenessProbe overrides the default liveness probe of chunkserver
+	// +optional
+	LivenessProbe *ProbeSpec `json:"livenessProbe,omitempty"`
+
+	// ReadinessProbe overrides the default readiness probe of chunkserver
+	// +optional
+	ReadinessProbe *ProbeSpec `json:"readinessProbe,omitempty"`
+
+	// TerminationGracePeriodSeconds is how long chunkserver is given to quit after its preStop hook syncs the
+	// dirty pages and stops it by SIGTERM, such as when the node is drained. It's killed with dirty state when the
+	// period ends, which is found by the integrity check at the next start. Default is 120
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TerminationGracePeriodSeconds int `json:"terminationGracePeriodSeconds,omitempty"`
+
+	// LogLevel is the log level of chunkserver, the daemon uses its default level if not set.
+	// Changing it restarts the chunkserver pods one by one.
+	// +kubebuilder:validation:Enum=debug;info;warn;error;""
+	// +optional
+	LogLevel string `json:"logLevel,omitempty"`
+
+	// Env are the environment variables added to the containers of chunkserver and its jobs, after the ones of spec.env
+	// +optional
+	Env []v1.EnvVar `json:"env,omitempty"`
+
+	// EnvFrom are the sources of environment variables added to the containers of chunkserver and its jobs, after the ones of spec.envFrom
+	// +optional
+	EnvFrom []v1.EnvFromSource `json:"envFrom,omitempty"`
+
+	// Annotations are added to the resources of chunkserver and its jobs, after the ones of spec.annotations
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Labels are added to the resources of chunkserver and its jobs, after the ones of spec.labels
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// DNS overrides the DNS policy and DNS config of spec.dns for the pods of chunkserver and its jobs, the host aliases are
+	// added after the ones of spec.dns
+	// +optional
+	DNS *DNSSpec `json:"dns,omitempty"`
+
+	// PodTemplateOverrides are the volumes and containers added to the pod of each chunkserver deployment, such
+	// as a log shipper or a debug sidecar. Changing it restarts the chunkservers by updateStrategy.
+	// +optional
+	PodTemplateOverrides *PodTemplateOverridesSpec `json:"podTemplateOverrides,omitempty"`
+}
+
+// PodTemplateOverridesSpec is the volumes and containers merged into a generated pod template, their names must
+// not conflict with the generated ones
+type PodTemplateOverridesSpec struct {
+	// Volumes are added to the pod, the containers of chunkserver and the extra containers can mount them
+	// +optional
+	Volumes []v1.Volume `json:"volumes,omitempty"`
+
+	// InitContainers run after the generated init containers
+	// +optional
+	InitContainers []v1.Container `json:"initContainers,omitempty"`
+
+	// Containers run beside the chunkserver container. With cpuPinning, their resources are set like the
+	// other helper containers so that the pod keeps the Guaranteed QoS class.
+	// +optional
+	Containers []v1.Container `json:"containers,omitempty"`
+}
+
+// IsSPDK returns true if chunkservers run on NVMe devices by spdk
+func (s *StorageScopeSpec) IsSPDK() bool {
+	return s.Engine == StorageEngineSPDK
+}
+
+// NodeDevices returns the devices of the node. In selected-nodes mode they are the devices of the node in
+// selectedNodes, and a device without percentage takes the one of the device of the same name in devices. The
+// percentage over MaxPercentage is limited to it unless overcommitPolicy is Reject or Allow.
+func (s *StorageScopeSpec) NodeDevices(nodeName string) []DevicesSpec {
+	devices := s.nodeDevices(nodeName)
+	if s.GetOvercommitPolicy() != OvercommitCap || s.ReservedPercentage == 0 {
+		return devices
+	}
+	limited := make([]DevicesSpec, 0, len(devices))
+	for _, device := range devices {
+		if device.Percentage > s.MaxPercentage() {
+			device.Percentage = s.MaxPercentage()
+		}
+		limited = append(limited, device)
+	}
+	return limited
+}
+
+// OvercommittedDevices returns the devices of the node whose percentage is over MaxPercentage and not limited to it
+func (s *StorageScopeSpec) OvercommittedDevices(nodeName string) []DevicesSpec {
+	if s.GetOvercommitPolicy() == OvercommitCap {
+		return nil
+	}
+	var devices []DevicesSpec
+	for _, device := range s.nodeDevices(nodeName) {
+		if device.Percentage > s.MaxPercentage() {
+			devices = append(devices, device)
+		}
+	}
+	return devices
+}
+
+// MaxPercentage returns the largest percentage of the devices that keeps reservedPercentage of them
+func (s *StorageScopeSpec) MaxPercentage() int {
+	return 100 - s.ReservedPercentage
+}
+
+// GetOvercommitPolicy returns the overcommit policy, Cap if not set
+func (s *StorageScopeSpec) GetOvercommitPolicy() OvercommitPolicy {
+	if s.OvercommitPolicy == "" {
+		return OvercommitCap
+	}
+	return s.OvercommitPolicy
+}
+
+func (s *StorageScopeSpec) nodeDevices(nodeName string) []DevicesSpec {
+	if !s.UseSelectedNodes {
+		return s.Devices
+	}
+	for _, node := range s.SelectedNodes {
+		if node.Node != nodeName {
+			continue
+		}
+		devices := make([]DevicesSpec, 0, len(node.Devices))
+		for _, device := range node.Devices {
+			if device.Percentage == 0 {
+				for _, d := range s.Devices {
+					if d.Name == device.Name {
+						device.Percentage = d.Percentage
+					}
+				}
+			}
+			devices = append(devices, device)
+		}
+		return devices
+	}
+	return nil
+}
+
+// PoolOf returns the pool that the device is in, nil if it's in the default pool
+func (s *StorageScopeSpec) PoolOf(deviceName string) *PoolSpec {
+	for i := range s.Pools {
+		for _, device := range s.Pools[i].Devices {
+			if device == deviceName {
+				return &s.Pools[i]
+			}
+		}
+	}
+	return nil
+}
+
+// PoolSpec is a pool of the devices whose chunkservers share the chunk size
+type PoolSpec struct {
+	// Name is the name of the physical pool and the logical pool
+	Name string `json:"name"`
+
+	// Devices are the names of the devices in the pool such as /dev/sdb, the devices of this name on all the
+	// storage nodes are in the pool
+	// +kubebuilder:validation:MinItems=1
+	Devices []string `json:"devices"`
+
+	// ChunkSize is the size of the chunks of the pool, such as 4Mi for small io. It must be a multiple of 4Ki
+	// and can't be changed after the devices are formatted. Default is 16Mi.
+	// +optional
+	ChunkSize *resource.Quantity `json:"chunkSize,omitempty"`
+}
+
+// OvercommitPolicy is how the devices whose percentage is over the limit of storage.reservedPercentage are formatted
+type OvercommitPolicy string
+
+const (
+	// OvercommitCap formats the devices by the limit of reservedPercentage
+	OvercommitCap OvercommitPolicy = "Cap"
+	// OvercommitReject refuses the spec that has devices over the limit
+	OvercommitReject OvercommitPolicy = "Reject"
+	// OvercommitAllow formats the devices by their percentage over the limit
+	OvercommitAllow OvercommitPolicy = "Allow"
+)
+
+// StorageEngine is the io engine of chunkservers
+type StorageEngine string
+
+const (
+	// StorageEngineAIO is the default engine that runs on the filesystem of devices
+	StorageEngineAIO StorageEngine = "aio"
+	// StorageEngineSPDK runs on NVMe devices bound to vfio-pci in user space
+	StorageEngineSPDK StorageEngine = "spdk"
+)
+
+// SPDKSpec is the settings of the spdk engine
+type SPDKSpec struct {
+	// HugePageSize is the size of hugepages used by spdk, 2Mi(default) or 1Gi. The hugepages must be
+	// pre-allocated on the storage nodes.
+	// +kubebuilder:validation:Enum=2Mi;1Gi;""
+	// +optional
+	HugePageSize string `json:"hugePageSize,omitempty"`
+
+	// HugePages is the amount of hugepages requested by each chunkserver, such as '2Gi'. Default is 2Gi. The
+	// hugepages are mounted and locked by the chunkserver with the IPC_LOCK capability.
+	// +optional
+	HugePages *resource.Quantity `json:"hugePages,omitempty"`
+}
+
+// GetHugePageSize returns the size of hugepages, 2Mi if not set
+func (s *SPDKSpec) GetHugePageSize() string {
+	if s.HugePageSize == "" {
+		return "2Mi"
+	}
+	return s.HugePageSize
+}
+
+// GetHugePages returns the amount of hugepages of each chunkserver, 2Gi if not set
+func (s *SPDKSpec) GetHugePages() resource.Quantity {
+	if s.HugePages == nil {
+		return resource.MustParse("2Gi")
+	}
+	return *s.HugePages
+}
+
+// DeviceType represents the kind of storage that backs a chunkserver
+type DeviceType string
+
+const (
+	// DeviceTypeBlock is a raw block device that will be formatted and mounted by the operator
+	DeviceTypeBlock DeviceType = "block"
+	// DeviceTypePath is an existing directory on the node, e.g. a pre-provisioned xfs mount
+	DeviceTypePath DeviceType = "path"
+	// DeviceTypeLoop is a sparse file on the data dir of the node attached as a loop device, for the test
+	// clusters without spare disks
+	DeviceTypeLoop DeviceType = "loop"
+)
+
+const (
+	// FilesystemExt4 is the default filesystem to format block device
+	FilesystemExt4 = "ext4"
+	// FilesystemXfs formats block device with xfs
+	FilesystemXfs = "xfs"
+)
+
+const (
+	// CacheModeWritethrough is the default cache mode of bcache that writes to the cache and the device
+	CacheModeWritethrough = "writethrough"
+	// CacheModeWriteback acknowledges the writes once they are in the cache
+	CacheModeWriteback = "writeback"
+	// CacheModeWritearound writes to the device only and caches the reads
+	CacheModeWritearound = "writearound"
+)
+
+// DevicesSpec represents a disk to use in the cluster
+type DevicesSpec struct {
+	// Name is the block device such as '/dev/sdb', the host directory when type is path, or the name of the
+	// backing file such as 'chunkserver0' when type is loop. The block device can be given by a stable link under
+	// /dev/disk/by-id or /dev/disk/by-path such as '/dev/disk/by-id/wwn-0x5000c500a1b2c3d4'. The kernel name of a
+	// block device may change after the node reboots, so the stable link of it is resolved on the node when it's
+	// formatted and used by the chunkserver.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Type is block(default), path or loop. A path device is used as it is without mkfs and mount. The prepare
+	// job of a loop device creates a sparse file of Size under dataDirHostPath and attaches a loop device to it
+	// before formatting, the loop device is attached again by the chunkserver after the node reboots.
+	// +kubebuilder:validation:Enum=block;path;loop;""
+	// +optional
+	Type DeviceType `json:"type,omitempty"`
+
+	// Size is the size of the backing file of loop device such as '20Gi', it's required when type is loop
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+
+	// Filesystem is the filesystem to make on block device, ext4(default) or xfs
+	// +kubebuilder:validation:Enum=ext4;xfs;""
+	// +optional
+	Filesystem string `json:"filesystem,omitempty"`
+
+	// MountOptions are passed to mount by '-o' when the block device is mounted, such as 'noatime'
+	// +optional
+	MountOptions []string `json:"mountOptions,omitempty"`
+
+	// +optional
+	MountPath string `json:"mountPath,omitempty"`
+
+	// +optional
+	Percentage int `json:"percentage,omitempty"`
+
+	// Capacity is the size of device such as '4Ti'. If all devices have the capacity, the chunkservers are
+	// weighted by it in topology so that data distribution is proportional to the size of devices.
+	// +optional
+	Capacity *resource.Quantity `json:"capacity,omitempty"`
+
+	// CPUSet is the cpu list that the chunkserver of the device is bound to, such as the cpus of the NUMA node
+	// of the device. It overrides storage.cpuPinning.cpuSet.
+	// +optional
+	CPUSet string `json:"cpuSet,omitempty"`
+
+	// Encrypted sets up dm-crypt LUKS on the block device before it's formatted, and the chunkserver opens the
+	// mapped device by the passphrase in KeySecret. It only takes effect when the device is formatted. The
+	// cryptsetup of the node is used.
+	// +optional
+	Encrypted bool `json:"encrypted,omitempty"`
+
+	// KeySecret is the key of a Secret in the namespace of the cluster that holds the passphrase of the
+	// encrypted device
+	// +optional
+	KeySecret *v1.SecretKeySelector `json:"keySecret,omitempty"`
+
+	// CacheDevice is a fast device such as a partition of NVMe that caches the block device by bcache, the
+	// chunk file pool is built on the bcache device. Each device needs its own cache device. It only takes
+	// effect when the device is formatted. The bcache module must be available on the node.
+	// +optional
+	CacheDevice string `json:"cacheDevice,omitempty"`
+
+	// CacheMode is the cache mode of bcache, writethrough(default), writeback or writearound
+	// +kubebuilder:validation:Enum=writethrough;writeback;writearound;""
+	// +optional
+	CacheMode string `json:"cacheMode,omitempty"`
+}
+
+// IsPath returns true if the device is a directory on the host instead of a block device
+func (d *DevicesSpec) IsPath() bool {
+	return d.Type == DeviceTypePath
+}
+
+// IsLoop returns true if the device is a loop device backed by a sparse file on the host
+func (d *DevicesSpec) IsLoop() bool {
+	return d.Type == DeviceTypeLoop
+}
+
+// PoolBytes returns the size of the chunk file pool on the device, 0 if the device has no capacity
+func (d *DevicesSpec) PoolBytes() int64 {
+	if d.Capacity == nil {
+		return 0
+	}
+	return d.Capacity.Value() / 100 * int64(d.Percentage)
+}
+
+// GetCacheMode returns the cache mode of bcache, writethrough if not set
+func (d *DevicesSpec) GetCacheMode() string {
+	if d.CacheMode == "" {
+		return CacheModeWritethrough
+	}
+	return d.CacheMode
+}
+
+// GetFilesystem returns the filesystem to format the block device, ext4 if not set
+func (d *DevicesSpec) GetFilesystem() string {
+	if d.Filesystem == "" {
+		return FilesystemExt4
+	}
+	return d.Filesystem
+}
+
+// IntegrityCheckSpec is the settings of the check before a chunkserver restarts from an unclean shutdown.
+// The filesystem of block device is checked by e2fsck or xfs_repair without repair, and the metadata files
+// of path device are checked to be readable. The chunkserver won't start until the check succeeds.
+type IntegrityCheckSpec struct {
+	// +optional
+	Enable bool `json:"enable,omitempty"`
 }
 
+// SelectedNodesSpec is a node and its devices in selected-nodes mode. The percentage of a device overrides
+// the one of the device of the same name in storage.devices.
 type SelectedNodesSpec struct {
 	Node    string        `json:"node,omitempty"`
 	Devices []DevicesSpec `json:"devices,omitempty"`
@@ -0,0 +1,118 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CurveFleetSpec defines the desired state of CurveFleet
+type CurveFleetSpec struct {
+	// Namespaces are the namespaces of the CurveClusters that are summarized, all the clusters managed by the
+	// operator are summarized if it's empty
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// CurveFleetStatus defines the observed state of CurveFleet
+type CurveFleetStatus struct {
+	// Clusters is the number of the clusters
+	// +optional
+	Clusters int `json:"clusters,omitempty"`
+
+	// ReadyClusters is the number of the clusters in phase Ready
+	// +optional
+	ReadyClusters int `json:"readyClusters,omitempty"`
+
+	// TotalBytes and UsedBytes are the sum of the capacity of the clusters reported by their mds
+	// +optional
+	TotalBytes int64 `json:"totalBytes,omitempty"`
+	// +optional
+	UsedBytes int64 `json:"usedBytes,omitempty"`
+
+	// UnhealthyCopysets is the sum of the unhealthy copysets of the clusters
+	// +optional
+	UnhealthyCopysets int `json:"unhealthyCopysets,omitempty"`
+
+	// Images are the distinct curve images that the clusters run, there is more than one while they are
+	// being upgraded
+	// +optional
+	Images []string `json:"images,omitempty"`
+
+	// ClusterSummaries are the health, capacity and version of each cluster sorted by namespace and name
+	// +optional
+	ClusterSummaries []FleetClusterStatus `json:"clusterSummaries,omitempty"`
+
+	// LastUpdateTime is the time that the summaries were updated, they are updated when a cluster changes
+	// +optional
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
+// FleetClusterStatus is the summary of a CurveCluster in the fleet
+type FleetClusterStatus struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Phase is the phase of the cluster
+	Phase ConditionType `json:"phase,omitempty"`
+	// Message is the message of the cluster, such as the reason of its failure
+	// +optional
+	Message string `json:"message,omitempty"`
+	// Image is the curve image in spec.curveVersion of the cluster
+	Image string `json:"image,omitempty"`
+	// OperatorVersion is the version of curve-operator that reconciled the cluster successfully last time
+	// +optional
+	OperatorVersion string `json:"operatorVersion,omitempty"`
+	// +optional
+	TotalBytes int64 `json:"totalBytes,omitempty"`
+	// +optional
+	UsedBytes int64 `json:"usedBytes,omitempty"`
+	// +optional
+	UsedPercent int `json:"usedPercent,omitempty"`
+	// UnhealthyCopysets is the number of the unhealthy copysets of the cluster
+	// +optional
+	UnhealthyCopysets int `json:"unhealthyCopysets,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,categories=curves
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Clusters",JSONPath=".status.clusters",type=integer
+// +kubebuilder:printcolumn:name="Ready",JSONPath=".status.readyClusters",type=integer
+// +kubebuilder:printcolumn:name="UnhealthyCopysets",JSONPath=".status.unhealthyCopysets",type=integer
+
+// CurveFleet is the Schema for the curvefleets API, it's a cluster-scoped summary of the health, capacity and
+// version of the CurveClusters managed by the operator
+type CurveFleet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CurveFleetSpec   `json:"spec,omitempty"`
+	Status CurveFleetStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// CurveFleetList contains a list of CurveFleet
+type CurveFleetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CurveFleet `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CurveFleet{}, &CurveFleetList{})
+}
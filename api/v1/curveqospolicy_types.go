@@ -0,0 +1,137 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ThrottleType is the type of a throttle limit of curve volume
+type ThrottleType string
+
+const (
+	ThrottleTypeIOPSTotal ThrottleType = "IOPS_TOTAL"
+	ThrottleTypeIOPSRead  ThrottleType = "IOPS_READ"
+	ThrottleTypeIOPSWrite ThrottleType = "IOPS_WRITE"
+	ThrottleTypeBPSTotal  ThrottleType = "BPS_TOTAL"
+	ThrottleTypeBPSRead   ThrottleType = "BPS_READ"
+	ThrottleTypeBPSWrite  ThrottleType = "BPS_WRITE"
+)
+
+// QoSPolicyPhase is the phase of a CurveQoSPolicy
+type QoSPolicyPhase string
+
+const (
+	// QoSPolicyPhaseApplied indicates the limits are applied to all the volumes of the policy
+	QoSPolicyPhaseApplied QoSPolicyPhase = "Applied"
+	// QoSPolicyPhaseFailed indicates the limits failed to be applied to some volumes of the policy
+	QoSPolicyPhaseFailed QoSPolicyPhase = "Failed"
+)
+
+// CurveQoSPolicySpec defines the desired state of CurveQoSPolicy
+type CurveQoSPolicySpec struct {
+	// Cluster is the name of the CurveCluster in the namespace of the policy whose volumes are throttled
+	Cluster string `json:"cluster"`
+
+	// User is the owner of the volumes
+	User string `json:"user"`
+
+	// PasswordSecret is the key of a Secret in the namespace of the policy that holds the password of the user,
+	// it's not needed if the user has no password
+	// +optional
+	PasswordSecret *v1.SecretKeySelector `json:"passwordSecret,omitempty"`
+
+	// Volumes are the paths of the volumes of the user to throttle, such as /test. All the volumes of the user
+	// under the root directory are throttled if it's empty.
+	// +optional
+	Volumes []string `json:"volumes,omitempty"`
+
+	// Limits are the throttle limits of each volume
+	// +kubebuilder:validation:MinItems=1
+	Limits []ThrottleLimit `json:"limits"`
+}
+
+// ThrottleLimit is a throttle limit of curve volume applied by 'curve update_throttle'
+type ThrottleLimit struct {
+	// +kubebuilder:validation:Enum=IOPS_TOTAL;IOPS_READ;IOPS_WRITE;BPS_TOTAL;BPS_READ;BPS_WRITE
+	Type ThrottleType `json:"type"`
+
+	// Limit is the IOPS or the bytes per second of the volume, 0 means no limit
+	// +kubebuilder:validation:Minimum=0
+	Limit int64 `json:"limit"`
+
+	// Burst is the IOPS or the bytes per second that the volume can burst to, it must be more than the limit
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Burst int64 `json:"burst,omitempty"`
+
+	// BurstLength is the seconds that the volume can keep bursting
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	BurstLength int64 `json:"burstLength,omitempty"`
+}
+
+// CurveQoSPolicyStatus defines the observed state of CurveQoSPolicy
+type CurveQoSPolicyStatus struct {
+	// Phase is Applied if the limits are applied to all the volumes, or Failed
+	// +optional
+	Phase QoSPolicyPhase `json:"phase,omitempty"`
+
+	// Message is the reason of the failure
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// ObservedGeneration is the generation of the policy that is applied
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// AppliedVolumes are the volumes that the limits are applied to, they are restored to no limit when they
+	// are removed from the policy or the policy is deleted
+	// +optional
+	AppliedVolumes []string `json:"appliedVolumes,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:categories=curves
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",JSONPath=".spec.cluster",type=string
+// +kubebuilder:printcolumn:name="User",JSONPath=".spec.user",type=string
+// +kubebuilder:printcolumn:name="Phase",JSONPath=".status.phase",type=string
+
+// CurveQoSPolicy is the Schema for the curveqospolicies API, it throttles the IOPS and bandwidth of the volumes
+// of a user in a curve cluster
+type CurveQoSPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CurveQoSPolicySpec   `json:"spec,omitempty"`
+	Status CurveQoSPolicyStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// CurveQoSPolicyList contains a list of CurveQoSPolicy
+type CurveQoSPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CurveQoSPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CurveQoSPolicy{}, &CurveQoSPolicyList{})
+}
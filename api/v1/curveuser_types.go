@@ -0,0 +1,153 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// UserDeletePolicy is what to do with the directory of a curve user when the CurveUser is deleted
+type UserDeletePolicy string
+
+const (
+	// UserDeletePolicyRetain keeps the directory of the user and its volumes
+	UserDeletePolicyRetain UserDeletePolicy = "Retain"
+	// UserDeletePolicyDelete deletes the directory of the user, which fails until all the volumes in it are deleted
+	UserDeletePolicyDelete UserDeletePolicy = "Delete"
+)
+
+// UserPhase is the phase of a CurveUser
+type UserPhase string
+
+const (
+	// UserPhaseReady indicates the directory of the user is created and its usage is updated
+	UserPhaseReady UserPhase = "Ready"
+	// UserPhaseFailed indicates the user job failed
+	UserPhaseFailed UserPhase = "Failed"
+	// UserPhaseDeleting indicates the directory of the user is being deleted
+	UserPhaseDeleting UserPhase = "Deleting"
+)
+
+// CurveUserSpec defines the desired state of CurveUser
+type CurveUserSpec struct {
+	// Cluster is the name of the CurveCluster in the namespace of the user
+	Cluster string `json:"cluster"`
+
+	// Owner is the name of the curve user, the owner of the volumes under its directory /<owner>. It's the name
+	// of the CurveUser if not set.
+	// +optional
+	Owner string `json:"owner,omitempty"`
+
+	// PasswordSecret is the key of a Secret in the namespace of the user that holds the password of the user,
+	// it's not needed if the user has no password
+	// +optional
+	PasswordSecret *v1.SecretKeySelector `json:"passwordSecret,omitempty"`
+
+	// Quota is the quota of the volumes of the user. Curve doesn't limit the volumes of a user, the usage exceeding
+	// the quota is only reported by status.quotaExceeded.
+	// +optional
+	Quota *UserQuotaSpec `json:"quota,omitempty"`
+
+	// DeletePolicy is Retain to keep the directory of the user when the CurveUser is deleted, or Delete to delete
+	// it once all the volumes in it are deleted
+	// +kubebuilder:validation:Enum=Retain;Delete;""
+	// +optional
+	DeletePolicy UserDeletePolicy `json:"deletePolicy,omitempty"`
+}
+
+// GetOwner returns the name of the curve user
+func (u *CurveUser) GetOwner() string {
+	if u.Spec.Owner == "" {
+		return u.Name
+	}
+	return u.Spec.Owner
+}
+
+// UserQuotaSpec is the quota of the volumes of a curve user, 0 means no limit
+type UserQuotaSpec struct {
+	// MaxVolumes is the number of the volumes of the user
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxVolumes int `json:"maxVolumes,omitempty"`
+
+	// MaxCapacity is the total size of the volumes of the user
+	// +optional
+	MaxCapacity *resource.Quantity `json:"maxCapacity,omitempty"`
+}
+
+// CurveUserStatus defines the observed state of CurveUser
+type CurveUserStatus struct {
+	// +optional
+	Phase UserPhase `json:"phase,omitempty"`
+
+	// Message is the reason of the failure or the quota exceeded
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// ObservedGeneration is the generation of the user that is synced
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Volumes is the number of the volumes of the user
+	// +optional
+	Volumes int `json:"volumes,omitempty"`
+
+	// ProvisionedBytes is the total size of the volumes of the user
+	// +optional
+	ProvisionedBytes int64 `json:"provisionedBytes,omitempty"`
+
+	// QuotaExceeded is true if the volumes of the user exceed its quota
+	// +optional
+	QuotaExceeded bool `json:"quotaExceeded,omitempty"`
+
+	// LastSyncTime is the time that the last job of the user finished
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:categories=curves
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",JSONPath=".spec.cluster",type=string
+// +kubebuilder:printcolumn:name="Volumes",JSONPath=".status.volumes",type=integer
+// +kubebuilder:printcolumn:name="QuotaExceeded",JSONPath=".status.quotaExceeded",type=boolean
+// +kubebuilder:printcolumn:name="Phase",JSONPath=".status.phase",type=string
+
+// CurveUser is the Schema for the curveusers API, it's a tenant of the block storage that owns the volumes
+// under its directory
+type CurveUser struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CurveUserSpec   `json:"spec,omitempty"`
+	Status CurveUserStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// CurveUserList contains a list of CurveUser
+type CurveUserList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CurveUser `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CurveUser{}, &CurveUserList{})
+}
@@ -0,0 +1,604 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+// hubFieldsAnnotation keeps the v1 only fields when a v1 object is converted to v1beta1,
+// so that the fields are not lost when it's converted back.
+const hubFieldsAnnotation = "operator.curve.io/v1-fields"
+
+// hubOnlyFields are the fields of v1 spec that can't be represented in v1beta1
+type hubOnlyFields struct {
+	// Devices are keyed by device name
+	Devices                    map[string]curvev1.DevicesSpec `json:"devices,omitempty"`
+	EtcdNodes                  []string                       `json:"etcdNodes,omitempty"`
+	EtcdStatefulSet            *curvev1.EtcdStatefulSetSpec   `json:"etcdStatefulSet,omitempty"`
+	EtcdBackup                 *curvev1.EtcdBackupSpec        `json:"etcdBackup,omitempty"`
+	MdsNodes                   []string                       `json:"mdsNodes,omitempty"`
+	MdsFlags                   map[string]string              `json:"mdsFlags,omitempty"`
+	SnapShotCloneNodes         []string                       `json:"snapShotCloneNodes,omitempty"`
+	SnapShotCloneExposure      *curvev1.ExposureSpec          `json:"snapShotCloneExposure,omitempty"`
+	SnapShotCloneNodeSelector  *metav1.LabelSelector          `json:"snapShotCloneNodeSelector,omitempty"`
+	SnapShotCloneReplicas      int                            `json:"snapShotCloneReplicas,omitempty"`
+	FailoverGracePeriodSeconds int                            `json:"failoverGracePeriodSeconds,omitempty"`
+	DrainProtection            bool                           `json:"drainProtection,omitempty"`
+	// TerminationGracePeriodSeconds are keyed by mds and chunkserver
+	TerminationGracePeriodSeconds map[string]int `json:"terminationGracePeriodSeconds,omitempty"`
+	// Probes are keyed by daemon and probe type such as 'etcd.liveness'
+	Probes  map[string]*curvev1.ProbeSpec `json:"probes,omitempty"`
+	Logging *curvev1.LoggingSpec          `json:"logging,omitempty"`
+	// LogLevels are keyed by daemon
+	LogLevels         map[string]string              `json:"logLevels,omitempty"`
+	Tools             *curvev1.ToolsSpec             `json:"tools,omitempty"`
+	Dashboard         *curvev1.DashboardSpec         `json:"dashboard,omitempty"`
+	Topology          *curvev1.TopologySpec          `json:"topology,omitempty"`
+	UpdateStrategy    *curvev1.UpdateStrategySpec    `json:"updateStrategy,omitempty"`
+	Network           *curvev1.NetworkSpec           `json:"network,omitempty"`
+	Monitoring        *curvev1.MonitoringSpec        `json:"monitoring,omitempty"`
+	TimeSync          *curvev1.TimeSyncSpec          `json:"timeSync,omitempty"`
+	Cleanup           *curvev1.CleanupSpec           `json:"cleanup,omitempty"`
+	MaintenanceWindow *curvev1.MaintenanceWindowSpec `json:"maintenanceWindow,omitempty"`
+	Connection        *curvev1.ConnectionSpec        `json:"connection,omitempty"`
+	Notifications     *curvev1.NotificationsSpec     `json:"notifications,omitempty"`
+	HistoryLimit      int                            `json:"historyLimit,omitempty"`
+	Maintenance       *curvev1.MaintenanceSpec       `json:"maintenance,omitempty"`
+	// PriorityClassNames are keyed by daemon
+	PriorityClassNames map[string]string           `json:"priorityClassNames,omitempty"`
+	SchedulerName      string                      `json:"schedulerName,omitempty"`
+	Adoption           *curvev1.AdoptionSpec       `json:"adoption,omitempty"`
+	TopologyImport     *curvev1.TopologyImportSpec `json:"topologyImport,omitempty"`
+	// Env and EnvFrom are keyed by daemon, the ones of spec are keyed by 'all'
+	Env     map[string][]corev1.EnvVar        `json:"env,omitempty"`
+	EnvFrom map[string][]corev1.EnvFromSource `json:"envFrom,omitempty"`
+	// Annotations and Labels are keyed by daemon, the ones of spec are keyed by 'all'
+	Annotations map[string]map[string]string `json:"annotations,omitempty"`
+	Labels      map[string]map[string]string `json:"labels,omitempty"`
+	// DNS is keyed by daemon, the one of spec is keyed by 'all'
+	DNS map[string]*curvev1.DNSSpec `json:"dns,omitempty"`
+	// IntegrityCheck, NodeSelector, AllowDeviceReformat, WipeRemovedDevices, DiskHealth, PrepareJob,
+	// MaxConcurrentFormats, FormatOrder, MinReadyNodes, CPUPinning, Engine, SPDK, ExtraArgs, IO, Sysctls,
+	// MinPoolSize, ReservedPercentage, OvercommitPolicy, PodTemplateOverrides, AutoCopySets and Pools are of
+	// storage
+	IntegrityCheck       *curvev1.IntegrityCheckSpec       `json:"integrityCheck,omitempty"`
+	NodeSelector         *metav1.LabelSelector             `json:"nodeSelector,omitempty"`
+	AllowDeviceReformat  bool                              `json:"allowDeviceReformat,omitempty"`
+	WipeRemovedDevices   bool                              `json:"wipeRemovedDevices,omitempty"`
+	DiskHealth           *curvev1.DiskHealthSpec           `json:"diskHealth,omitempty"`
+	PrepareJob           *curvev1.PrepareJobSpec           `json:"prepareJob,omitempty"`
+	MaxConcurrentFormats int                               `json:"maxConcurrentFormats,omitempty"`
+	FormatOrder          []string                          `json:"formatOrder,omitempty"`
+	MinReadyNodes        int                               `json:"minReadyNodes,omitempty"`
+	CPUPinning           *curvev1.CPUPinningSpec           `json:"cpuPinning,omitempty"`
+	Engine               curvev1.StorageEngine             `json:"engine,omitempty"`
+	SPDK                 *curvev1.SPDKSpec                 `json:"spdk,omitempty"`
+	ExtraArgs            map[string]string                 `json:"extraArgs,omitempty"`
+	IO                   *curvev1.IOLimitsSpec             `json:"io,omitempty"`
+	Sysctls              map[string]string                 `json:"sysctls,omitempty"`
+	MinPoolSize          *resource.Quantity                `json:"minPoolSize,omitempty"`
+	ReservedPercentage   int                               `json:"reservedPercentage,omitempty"`
+	OvercommitPolicy     curvev1.OvercommitPolicy          `json:"overcommitPolicy,omitempty"`
+	PodTemplateOverrides *curvev1.PodTemplateOverridesSpec `json:"podTemplateOverrides,omitempty"`
+	AutoCopySets         bool                              `json:"autoCopySets,omitempty"`
+	Pools                []curvev1.PoolSpec                `json:"pools,omitempty"`
+	// Architectures are of curveVersion
+	Architectures []string                       `json:"architectures,omitempty"`
+	ToolsImage    string                         `json:"toolsImage,omitempty"`
+	Verification  *curvev1.ImageVerificationSpec `json:"verification,omitempty"`
+	DevMode       bool                           `json:"devMode,omitempty"`
+}
+
+// ConvertTo converts this CurveCluster to the Hub version (v1).
+func (src *CurveCluster) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*curvev1.CurveCluster)
+
+	dst.ObjectMeta = src.ObjectMeta
+	// the fields of v1beta1 are a subset of v1 with the same json name, convert them by json
+	if err := convertByJSON(src.Spec, &dst.Spec); err != nil {
+		return errors.Wrap(err, "failed to convert spec to v1")
+	}
+	if err := convertByJSON(src.Status, &dst.Status); err != nil {
+		return errors.Wrap(err, "failed to convert status to v1")
+	}
+
+	// restore the v1 only fields
+	raw, ok := dst.Annotations[hubFieldsAnnotation]
+	if !ok {
+		return nil
+	}
+	annotations := map[string]string{}
+	for k, v := range dst.Annotations {
+		if k != hubFieldsAnnotation {
+			annotations[k] = v
+		}
+	}
+	dst.Annotations = annotations
+
+	saved := &hubOnlyFields{}
+	if err := json.Unmarshal([]byte(raw), saved); err != nil {
+		return errors.Wrapf(err, "failed to unmarshal annotation %q", hubFieldsAnnotation)
+	}
+	if dst.Spec != nil {
+		saved.restore(dst.Spec)
+	}
+
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1) to this version.
+func (dst *CurveCluster) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*curvev1.CurveCluster)
+
+	dst.ObjectMeta = src.ObjectMeta
+	if err := convertByJSON(src.Spec, &dst.Spec); err != nil {
+		return errors.Wrap(err, "failed to convert spec from v1")
+	}
+	if err := convertByJSON(src.Status, &dst.Status); err != nil {
+		return errors.Wrap(err, "failed to convert status from v1")
+	}
+
+	// save the v1 only fields that can't be represented in v1beta1
+	if src.Spec == nil {
+		return nil
+	}
+	saved := &hubOnlyFields{}
+	if !saved.save(src.Spec) {
+		return nil
+	}
+	raw, err := json.Marshal(saved)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal annotation %q", hubFieldsAnnotation)
+	}
+	annotations := map[string]string{}
+	for k, v := range dst.Annotations {
+		annotations[k] = v
+	}
+	annotations[hubFieldsAnnotation] = string(raw)
+	dst.Annotations = annotations
+
+	return nil
+}
+
+// convertByJSON converts between the versions that have compatible json representation,
+// the fields that don't exist in out are dropped.
+func convertByJSON(in interface{}, out interface{}) error {
+	raw, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// save records the v1 only fields of spec and returns false if there is nothing to save
+func (f *hubOnlyFields) save(spec *curvev1.CurveClusterSpec) bool {
+	f.Devices = map[string]curvev1.DevicesSpec{}
+	saveDevices(spec.Storage.Devices, f.Devices)
+	for _, n := range spec.Storage.SelectedNodes {
+		saveDevices(n.Devices, f.Devices)
+	}
+	f.EtcdNodes = spec.Etcd.Nodes
+	f.EtcdStatefulSet = spec.Etcd.StatefulSet
+	if !reflect.DeepEqual(spec.Etcd.Backup, curvev1.EtcdBackupSpec{}) {
+		etcdBackup := spec.Etcd.Backup
+		f.EtcdBackup = &etcdBackup
+	}
+	f.MdsNodes = spec.Mds.Nodes
+	f.MdsFlags = spec.Mds.Flags
+	f.SnapShotCloneNodes = spec.SnapShotClone.Nodes
+	f.SnapShotCloneExposure = spec.SnapShotClone.Exposure
+	f.SnapShotCloneNodeSelector = spec.SnapShotClone.NodeSelector
+	f.SnapShotCloneReplicas = spec.SnapShotClone.Replicas
+	f.FailoverGracePeriodSeconds = spec.Storage.FailoverGracePeriodSeconds
+	f.DrainProtection = spec.Storage.DrainProtection.Enable
+	f.TerminationGracePeriodSeconds = map[string]int{}
+	for key, seconds := range terminationGracePeriodsOf(spec) {
+		if *seconds > 0 {
+			f.TerminationGracePeriodSeconds[key] = *seconds
+		}
+	}
+	f.Probes = map[string]*curvev1.ProbeSpec{}
+	for key, probe := range probesOf(spec) {
+		if *probe != nil {
+			f.Probes[key] = *probe
+		}
+	}
+
+	if spec.Logging != (curvev1.LoggingSpec{}) {
+		logging := spec.Logging
+		f.Logging = &logging
+	}
+
+	f.LogLevels = map[string]string{}
+	for key, level := range logLevelsOf(spec) {
+		if *level != "" {
+			f.LogLevels[key] = *level
+		}
+	}
+
+	if spec.Tools != (curvev1.ToolsSpec{}) {
+		tools := spec.Tools
+		f.Tools = &tools
+	}
+
+	if !reflect.DeepEqual(spec.Dashboard, curvev1.DashboardSpec{}) {
+		dashboard := spec.Dashboard
+		f.Dashboard = &dashboard
+	}
+
+	if spec.Topology != (curvev1.TopologySpec{}) {
+		topology := spec.Topology
+		f.Topology = &topology
+	}
+
+	if spec.Storage.IntegrityCheck != (curvev1.IntegrityCheckSpec{}) {
+		check := spec.Storage.IntegrityCheck
+		f.IntegrityCheck = &check
+	}
+
+	if spec.Storage.DiskHealth != (curvev1.DiskHealthSpec{}) {
+		health := spec.Storage.DiskHealth
+		f.DiskHealth = &health
+	}
+
+	if spec.UpdateStrategy != (curvev1.UpdateStrategySpec{}) {
+		strategy := spec.UpdateStrategy
+		f.UpdateStrategy = &strategy
+	}
+
+	if !reflect.DeepEqual(spec.Network, curvev1.NetworkSpec{}) {
+		network := spec.Network
+		f.Network = &network
+	}
+
+	if spec.TimeSync != (curvev1.TimeSyncSpec{}) {
+		timeSync := spec.TimeSync
+		f.TimeSync = &timeSync
+	}
+	if spec.Cleanup != (curvev1.CleanupSpec{}) {
+		cleanup := spec.Cleanup
+		f.Cleanup = &cleanup
+	}
+	f.MaintenanceWindow = spec.MaintenanceWindow
+	if !reflect.DeepEqual(spec.Connection, curvev1.ConnectionSpec{}) {
+		connection := spec.Connection
+		f.Connection = &connection
+	}
+	if !reflect.DeepEqual(spec.Notifications, curvev1.NotificationsSpec{}) {
+		notifications := spec.Notifications
+		f.Notifications = &notifications
+	}
+	f.HistoryLimit = spec.HistoryLimit
+
+	if !reflect.DeepEqual(spec.Maintenance, curvev1.MaintenanceSpec{}) {
+		maintenance := spec.Maintenance
+		f.Maintenance = &maintenance
+	}
+	f.SchedulerName = spec.SchedulerName
+	f.Adoption = spec.Adoption
+	f.TopologyImport = spec.TopologyImport
+
+	if !reflect.DeepEqual(spec.Monitoring, curvev1.MonitoringSpec{}) {
+		monitoring := spec.Monitoring
+		f.Monitoring = &monitoring
+	}
+
+	if !reflect.DeepEqual(spec.Storage.PrepareJob, curvev1.PrepareJobSpec{}) {
+		prepareJob := spec.Storage.PrepareJob
+		f.PrepareJob = &prepareJob
+	}
+
+	if !reflect.DeepEqual(spec.Storage.CPUPinning, curvev1.CPUPinningSpec{}) {
+		cpuPinning := spec.Storage.CPUPinning
+		f.CPUPinning = &cpuPinning
+	}
+
+	if !reflect.DeepEqual(spec.Storage.SPDK, curvev1.SPDKSpec{}) {
+		spdk := spec.Storage.SPDK
+		f.SPDK = &spdk
+	}
+
+	if !reflect.DeepEqual(spec.Storage.IO, curvev1.IOLimitsSpec{}) {
+		io := spec.Storage.IO
+		f.IO = &io
+	}
+	f.MaxConcurrentFormats = spec.Storage.MaxConcurrentFormats
+	f.FormatOrder = spec.Storage.FormatOrder
+	f.MinReadyNodes = spec.Storage.MinReadyNodes
+	f.ExtraArgs = spec.Storage.ExtraArgs
+	f.Sysctls = spec.Storage.Sysctls
+	f.MinPoolSize = spec.Storage.MinPoolSize
+	f.ReservedPercentage = spec.Storage.ReservedPercentage
+	f.OvercommitPolicy = spec.Storage.OvercommitPolicy
+	f.PodTemplateOverrides = spec.Storage.PodTemplateOverrides
+	f.AutoCopySets = spec.Storage.AutoCopySets
+	f.Pools = spec.Storage.Pools
+
+	f.PriorityClassNames = spec.PriorityClassNames
+	f.Env = map[string][]corev1.EnvVar{}
+	f.EnvFrom = map[string][]corev1.EnvFromSource{}
+	for key, env := range envOf(spec) {
+		if len(*env.env) > 0 {
+			f.Env[key] = *env.env
+		}
+		if len(*env.envFrom) > 0 {
+			f.EnvFrom[key] = *env.envFrom
+		}
+	}
+	f.Annotations = map[string]map[string]string{}
+	f.Labels = map[string]map[string]string{}
+	for key, metadata := range metadataOf(spec) {
+		if len(*metadata.annotations) > 0 {
+			f.Annotations[key] = *metadata.annotations
+		}
+		if len(*metadata.labels) > 0 {
+			f.Labels[key] = *metadata.labels
+		}
+	}
+	f.DNS = map[string]*curvev1.DNSSpec{}
+	for key, dns := range dnsOf(spec) {
+		if *dns != nil {
+			f.DNS[key] = *dns
+		}
+	}
+	f.NodeSelector = spec.Storage.NodeSelector
+	f.AllowDeviceReformat = spec.Storage.AllowDeviceReformat
+	f.WipeRemovedDevices = spec.Storage.WipeRemovedDevices
+	f.Engine = spec.Storage.Engine
+	f.Architectures = spec.CurveVersion.Architectures
+	f.ToolsImage = spec.CurveVersion.ToolsImage
+	f.Verification = spec.CurveVersion.Verification
+	f.DevMode = spec.DevMode
+
+	return len(f.Annotations) > 0 || len(f.Labels) > 0 || len(f.Env) > 0 || len(f.EnvFrom) > 0 || len(f.PriorityClassNames) > 0 || f.PrepareJob != nil || f.MaxConcurrentFormats > 0 || len(f.FormatOrder) > 0 || f.MinReadyNodes > 0 || f.CPUPinning != nil || f.Engine != "" || f.SPDK != nil || len(f.ExtraArgs) > 0 || f.IO != nil || len(f.Sysctls) > 0 || f.MinPoolSize != nil || f.ReservedPercentage > 0 || f.OvercommitPolicy != "" || f.PodTemplateOverrides != nil || f.AutoCopySets || len(f.Pools) > 0 || f.Monitoring != nil || f.DiskHealth != nil || f.AllowDeviceReformat || f.WipeRemovedDevices || f.NodeSelector != nil || f.Network != nil || f.UpdateStrategy != nil || f.IntegrityCheck != nil || f.Topology != nil || f.Tools != nil || f.Dashboard != nil || len(f.LogLevels) > 0 || len(f.Devices) > 0 || len(f.EtcdNodes) > 0 || f.EtcdStatefulSet != nil || f.EtcdBackup != nil || len(f.MdsNodes) > 0 || len(f.MdsFlags) > 0 ||
+		len(f.SnapShotCloneNodes) > 0 || f.SnapShotCloneExposure != nil || f.SnapShotCloneNodeSelector != nil || f.SnapShotCloneReplicas > 0 || f.FailoverGracePeriodSeconds > 0 || f.DrainProtection || len(f.TerminationGracePeriodSeconds) > 0 || len(f.Probes) > 0 ||
+		f.Logging != nil || len(f.Architectures) > 0 || f.ToolsImage != "" || f.Verification != nil || f.TimeSync != nil || f.Cleanup != nil || f.MaintenanceWindow != nil || f.Connection != nil || f.Notifications != nil || f.HistoryLimit > 0 || f.Maintenance != nil || len(f.DNS) > 0 ||
+		f.SchedulerName != "" || f.Adoption != nil || f.TopologyImport != nil || f.DevMode
+}
+
+// restore sets the v1 only fields to spec
+func (f *hubOnlyFields) restore(spec *curvev1.CurveClusterSpec) {
+	restoreDevices(spec.Storage.Devices, f.Devices)
+	for i := range spec.Storage.SelectedNodes {
+		restoreDevices(spec.Storage.SelectedNodes[i].Devices, f.Devices)
+	}
+	spec.CurveVersion.Architectures = f.Architectures
+	spec.CurveVersion.ToolsImage = f.ToolsImage
+	spec.CurveVersion.Verification = f.Verification
+	spec.DevMode = f.DevMode
+	spec.Etcd.Nodes = f.EtcdNodes
+	spec.Etcd.StatefulSet = f.EtcdStatefulSet
+	if f.EtcdBackup != nil {
+		spec.Etcd.Backup = *f.EtcdBackup
+	}
+	spec.Mds.Nodes = f.MdsNodes
+	spec.Mds.Flags = f.MdsFlags
+	spec.SnapShotClone.Nodes = f.SnapShotCloneNodes
+	spec.SnapShotClone.Exposure = f.SnapShotCloneExposure
+	spec.SnapShotClone.NodeSelector = f.SnapShotCloneNodeSelector
+	spec.SnapShotClone.Replicas = f.SnapShotCloneReplicas
+	spec.Storage.FailoverGracePeriodSeconds = f.FailoverGracePeriodSeconds
+	spec.Storage.DrainProtection.Enable = f.DrainProtection
+	for key, seconds := range terminationGracePeriodsOf(spec) {
+		*seconds = f.TerminationGracePeriodSeconds[key]
+	}
+	for key, probe := range probesOf(spec) {
+		*probe = f.Probes[key]
+	}
+	if f.Logging != nil {
+		spec.Logging = *f.Logging
+	}
+	if f.Tools != nil {
+		spec.Tools = *f.Tools
+	}
+	if f.Dashboard != nil {
+		spec.Dashboard = *f.Dashboard
+	}
+	if f.Topology != nil {
+		spec.Topology = *f.Topology
+	}
+	if f.IntegrityCheck != nil {
+		spec.Storage.IntegrityCheck = *f.IntegrityCheck
+	}
+	if f.DiskHealth != nil {
+		spec.Storage.DiskHealth = *f.DiskHealth
+	}
+	if f.PrepareJob != nil {
+		spec.Storage.PrepareJob = *f.PrepareJob
+	}
+	if f.CPUPinning != nil {
+		spec.Storage.CPUPinning = *f.CPUPinning
+	}
+	if f.SPDK != nil {
+		spec.Storage.SPDK = *f.SPDK
+	}
+	if f.IO != nil {
+		spec.Storage.IO = *f.IO
+	}
+	spec.Storage.MaxConcurrentFormats = f.MaxConcurrentFormats
+	spec.Storage.FormatOrder = f.FormatOrder
+	spec.Storage.MinReadyNodes = f.MinReadyNodes
+	spec.Storage.ExtraArgs = f.ExtraArgs
+	spec.Storage.Sysctls = f.Sysctls
+	spec.Storage.MinPoolSize = f.MinPoolSize
+	spec.Storage.ReservedPercentage = f.ReservedPercentage
+	spec.Storage.OvercommitPolicy = f.OvercommitPolicy
+	spec.Storage.PodTemplateOverrides = f.PodTemplateOverrides
+	spec.Storage.AutoCopySets = f.AutoCopySets
+	spec.Storage.Pools = f.Pools
+	if f.UpdateStrategy != nil {
+		spec.UpdateStrategy = *f.UpdateStrategy
+	}
+	if f.Network != nil {
+		spec.Network = *f.Network
+	}
+	if f.Monitoring != nil {
+		spec.Monitoring = *f.Monitoring
+	}
+	if f.TimeSync != nil {
+		spec.TimeSync = *f.TimeSync
+	}
+	if f.Cleanup != nil {
+		spec.Cleanup = *f.Cleanup
+	}
+	spec.MaintenanceWindow = f.MaintenanceWindow
+	if f.Connection != nil {
+		spec.Connection = *f.Connection
+	}
+	if f.Notifications != nil {
+		spec.Notifications = *f.Notifications
+	}
+	spec.HistoryLimit = f.HistoryLimit
+	if f.Maintenance != nil {
+		spec.Maintenance = *f.Maintenance
+	}
+	spec.PriorityClassNames = f.PriorityClassNames
+	spec.SchedulerName = f.SchedulerName
+	spec.Adoption = f.Adoption
+	spec.TopologyImport = f.TopologyImport
+	for key, env := range envOf(spec) {
+		*env.env = f.Env[key]
+		*env.envFrom = f.EnvFrom[key]
+	}
+	for key, metadata := range metadataOf(spec) {
+		*metadata.annotations = f.Annotations[key]
+		*metadata.labels = f.Labels[key]
+	}
+	for key, dns := range dnsOf(spec) {
+		*dns = f.DNS[key]
+	}
+	spec.Storage.NodeSelector = f.NodeSelector
+	spec.Storage.AllowDeviceReformat = f.AllowDeviceReformat
+	spec.Storage.WipeRemovedDevices = f.WipeRemovedDevices
+	spec.Storage.Engine = f.Engine
+	for key, level := range logLevelsOf(spec) {
+		*level = f.LogLevels[key]
+	}
+}
+
+// terminationGracePeriodsOf returns the pointers to termination grace period fields of spec keyed by daemon
+func terminationGracePeriodsOf(spec *curvev1.CurveClusterSpec) map[string]*int {
+	return map[string]*int{
+		"mds":         &spec.Mds.TerminationGracePeriodSeconds,
+		"chunkserver": &spec.Storage.TerminationGracePeriodSeconds,
+	}
+}
+
+// logLevelsOf returns the pointers to log level fields of spec keyed by daemon
+func logLevelsOf(spec *curvev1.CurveClusterSpec) map[string]*string {
+	return map[string]*string{
+		"etcd":          &spec.Etcd.LogLevel,
+		"mds":           &spec.Mds.LogLevel,
+		"chunkserver":   &spec.Storage.LogLevel,
+		"snapshotclone": &spec.SnapShotClone.LogLevel,
+	}
+}
+
+type envFields struct {
+	env     *[]corev1.EnvVar
+	envFrom *[]corev1.EnvFromSource
+}
+
+// envOf returns the pointers to env and envFrom fields of spec keyed by daemon
+func envOf(spec *curvev1.CurveClusterSpec) map[string]envFields {
+	return map[string]envFields{
+		"all":           {&spec.Env, &spec.EnvFrom},
+		"etcd":          {&spec.Etcd.Env, &spec.Etcd.EnvFrom},
+		"mds":           {&spec.Mds.Env, &spec.Mds.EnvFrom},
+		"chunkserver":   {&spec.Storage.Env, &spec.Storage.EnvFrom},
+		"snapshotclone": {&spec.SnapShotClone.Env, &spec.SnapShotClone.EnvFrom},
+	}
+}
+
+type metadataFields struct {
+	annotations *map[string]string
+	labels      *map[string]string
+}
+
+// metadataOf returns the pointers to annotations and labels fields of spec keyed by daemon
+func metadataOf(spec *curvev1.CurveClusterSpec) map[string]metadataFields {
+	return map[string]metadataFields{
+		"all":           {&spec.Annotations, &spec.Labels},
+		"etcd":          {&spec.Etcd.Annotations, &spec.Etcd.Labels},
+		"mds":           {&spec.Mds.Annotations, &spec.Mds.Labels},
+		"chunkserver":   {&spec.Storage.Annotations, &spec.Storage.Labels},
+		"snapshotclone": {&spec.SnapShotClone.Annotations, &spec.SnapShotClone.Labels},
+	}
+}
+
+// dnsOf returns the pointers to dns fields of spec keyed by daemon
+func dnsOf(spec *curvev1.CurveClusterSpec) map[string]**curvev1.DNSSpec {
+	return map[string]**curvev1.DNSSpec{
+		"all":           &spec.DNS,
+		"etcd":          &spec.Etcd.DNS,
+		"mds":           &spec.Mds.DNS,
+		"chunkserver":   &spec.Storage.DNS,
+		"snapshotclone": &spec.SnapShotClone.DNS,
+	}
+}
+
+// probesOf returns the pointers to probe fields of spec keyed by daemon and probe type
+func probesOf(spec *curvev1.CurveClusterSpec) map[string]**curvev1.ProbeSpec {
+	return map[string]**curvev1.ProbeSpec{
+		"etcd.liveness":         &spec.Etcd.LivenessProbe,
+		"etcd.readiness":        &spec.Etcd.ReadinessProbe,
+		"mds.liveness":          &spec.Mds.LivenessProbe,
+		"mds.readiness":         &spec.Mds.ReadinessProbe,
+		"chunkserver.liveness":  &spec.Storage.LivenessProbe,
+		"chunkserver.readiness": &spec.Storage.ReadinessProbe,
+	}
+}
+
+func saveDevices(devices []curvev1.DevicesSpec, saved map[string]curvev1.DevicesSpec) {
+	for _, d := range devices {
+		if d.Type == "" && d.Size == nil && d.Filesystem == "" && len(d.MountOptions) == 0 && d.Capacity == nil && d.CPUSet == "" && !d.Encrypted && d.KeySecret == nil &&
+			d.CacheDevice == "" && d.CacheMode == "" {
+			continue
+		}
+		saved[d.Name] = curvev1.DevicesSpec{Type: d.Type, Size: d.Size, Filesystem: d.Filesystem, MountOptions: d.MountOptions, Capacity: d.Capacity, CPUSet: d.CPUSet,
+			Encrypted: d.Encrypted, KeySecret: d.KeySecret, CacheDevice: d.CacheDevice, CacheMode: d.CacheMode}
+	}
+}
+
+func restoreDevices(devices []curvev1.DevicesSpec, saved map[string]curvev1.DevicesSpec) {
+	for i := range devices {
+		s, ok := saved[devices[i].Name]
+		if !ok {
+			continue
+		}
+		devices[i].Type = s.Type
+		devices[i].Size = s.Size
+		devices[i].Filesystem = s.Filesystem
+		devices[i].MountOptions = s.MountOptions
+		devices[i].Capacity = s.Capacity
+		devices[i].CPUSet = s.CPUSet
+		devices[i].Encrypted = s.Encrypted
+		devices[i].KeySecret = s.KeySecret
+		devices[i].CacheDevice = s.CacheDevice
+		devices[i].CacheMode = s.CacheMode
+	}
+}
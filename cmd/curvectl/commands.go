@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	operatorv1 "github.com/opencurve/curve-operator/api/v1"
+	operatorv1beta1 "github.com/opencurve/curve-operator/api/v1beta1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver"
+	"github.com/opencurve/curve-operator/pkg/validation"
+)
+
+// getCluster gets the cluster by name, the only cluster in namespace is used if name is not given
+func (c *clients) getCluster(args []string) (*operatorv1.CurveCluster, error) {
+	if len(args) > 0 {
+		cluster := &operatorv1.CurveCluster{}
+		err := c.client.Get(context.TODO(), types.NamespacedName{Namespace: c.namespace, Name: args[0]}, cluster)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get cluster %q", args[0])
+		}
+		return cluster, nil
+	}
+
+	clusters, err := c.listClusters()
+	if err != nil {
+		return nil, err
+	}
+	if len(clusters) != 1 {
+		return nil, errors.Errorf("found %d clusters in namespace %q, specify the cluster name", len(clusters), c.namespace)
+	}
+	return &clusters[0], nil
+}
+
+func (c *clients) listClusters() ([]operatorv1.CurveCluster, error) {
+	clusters := &operatorv1.CurveClusterList{}
+	if err := c.client.List(context.TODO(), clusters, client.InNamespace(c.namespace)); err != nil {
+		return nil, errors.Wrapf(err, "failed to list clusters in namespace %q", c.namespace)
+	}
+	return clusters.Items, nil
+}
+
+// setAnnotation sets the annotation of cluster, the annotation is removed if value is empty
+func (c *clients) setAnnotation(cluster *operatorv1.CurveCluster, key, value string) error {
+	patch := client.MergeFrom(cluster.DeepCopy())
+	if value == "" {
+		delete(cluster.Annotations, key)
+	} else {
+		if cluster.Annotations == nil {
+			cluster.Annotations = map[string]string{}
+		}
+		cluster.Annotations[key] = value
+	}
+	if err := c.client.Patch(context.TODO(), cluster, patch); err != nil {
+		return errors.Wrapf(err, "failed to set annotation %s of cluster %q", key, cluster.Name)
+	}
+	return nil
+}
+
+func statusCommand() *command {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	return &command{
+		flags: flags,
+		run: func(c *clients, args []string) error {
+			var clusters []operatorv1.CurveCluster
+			if len(args) > 0 {
+				cluster, err := c.getCluster(args)
+				if err != nil {
+					return err
+				}
+				clusters = append(clusters, *cluster)
+			} else {
+				var err error
+				if clusters, err = c.listClusters(); err != nil {
+					return err
+				}
+			}
+			for i := range clusters {
+				printStatus(&clusters[i])
+			}
+			return nil
+		},
+	}
+}
+
+func printStatus(cluster *operatorv1.CurveCluster) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintf(w, "Cluster:\t%s/%s\n", cluster.Namespace, cluster.Name)
+	fmt.Fprintf(w, "Phase:\t%s\n", cluster.Status.Phase)
+	fmt.Fprintf(w, "Message:\t%s\n", cluster.Status.Message)
+	fmt.Fprintf(w, "Curve image:\t%s\n", cluster.Status.CurveVersion.Image)
+	fmt.Fprintf(w, "Operator version:\t%s\n", cluster.Status.OperatorVersion)
+	if cluster.Annotations[operatorv1.PauseReconcileAnnotation] == "true" {
+		fmt.Fprintf(w, "Reconcile:\tpaused\n")
+	}
+
+	fmt.Fprintf(w, "\nCONDITION\tSTATUS\tREASON\tLAST TRANSITION\tMESSAGE\n")
+	for _, cond := range cluster.Status.Conditions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", cond.Type, cond.Status, cond.Reason,
+			cond.LastTransitionTime.Format(time.RFC3339), cond.Message)
+	}
+
+	if len(cluster.Status.ChunkServers) > 0 {
+		fmt.Fprintf(w, "\nCHUNKSERVER\tNODE\tSTATE\tLAST TRANSITION\tMESSAGE\n")
+		for _, cs := range cluster.Status.ChunkServers {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", cs.Name, cs.NodeName, cs.State,
+				cs.LastTransitionTime.Format(time.RFC3339), cs.Message)
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+func pauseCommand(pause bool) *command {
+	name := "resume"
+	if pause {
+		name = "pause"
+	}
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	return &command{
+		flags: flags,
+		run: func(c *clients, args []string) error {
+			cluster, err := c.getCluster(args)
+			if err != nil {
+				return err
+			}
+			value := ""
+			if pause {
+				value = "true"
+			}
+			if err := c.setAnnotation(cluster, operatorv1.PauseReconcileAnnotation, value); err != nil {
+				return err
+			}
+			fmt.Printf("reconciliation of cluster %s/%s is %sd\n", cluster.Namespace, cluster.Name, name)
+			return nil
+		},
+	}
+}
+
+func replaceDeviceCommand() *command {
+	flags := flag.NewFlagSet("replace-device", flag.ExitOnError)
+	node := flags.String("node", "", "node of the device")
+	device := flags.String("device", "", "device to replace, such as /dev/sdb")
+	return &command{
+		flags: flags,
+		run: func(c *clients, args []string) error {
+			if *node == "" || *device == "" {
+				return errors.New("both -node and -device must be specified")
+			}
+			cluster, err := c.getCluster(args)
+			if err != nil {
+				return err
+			}
+			value := fmt.Sprintf("%s:%s", *node, *device)
+			if err := c.setAnnotation(cluster, operatorv1.ReplaceDeviceAnnotation, value); err != nil {
+				return err
+			}
+			fmt.Printf("chunkserver %s will be set offline, check 'curvectl status' for progress\n",
+				chunkserver.DeploymentName(*node, *device))
+			return nil
+		},
+	}
+}
+
+func formatProgressCommand() *command {
+	flags := flag.NewFlagSet("format-progress", flag.ExitOnError)
+	watch := flags.Bool("w", false, "watch the progress until all jobs are completed")
+	return &command{
+		flags: flags,
+		run: func(c *clients, args []string) error {
+			for {
+				done, err := c.printFormatProgress()
+				if err != nil || done || !*watch {
+					return err
+				}
+				time.Sleep(10 * time.Second)
+			}
+		},
+	}
+}
+
+// printFormatProgress prints the status of format jobs and returns true if all of them are completed
+func (c *clients) printFormatProgress() (bool, error) {
+	jobs, err := c.clientset.BatchV1().Jobs(c.namespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=%s", chunkserver.PrepareJobName),
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "failed to list format jobs")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintf(w, "%s\nJOB\tNODE\tACTIVE\tSUCCEEDED\tFAILED\n", time.Now().Format(time.RFC3339))
+	completed := 0
+	for _, job := range jobs.Items {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\n", job.Name, job.Labels["node"],
+			job.Status.Active, job.Status.Succeeded, job.Status.Failed)
+		if job.Status.Succeeded > 0 {
+			completed++
+		}
+	}
+	fmt.Fprintf(w, "%d/%d completed\n\n", completed, len(jobs.Items))
+	return completed == len(jobs.Items), nil
+}
+
+func validateCommand() *command {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	file := flags.String("f", "", "manifest of the clusters to validate, '-' reads it from stdin")
+	return &command{
+		flags:   flags,
+		offline: true,
+		run: func(c *clients, args []string) error {
+			if *file == "" {
+				return errors.New("-f must be specified")
+			}
+			in := os.Stdin
+			if *file != "-" {
+				f, err := os.Open(*file)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				in = f
+			}
+			clusters, err := readClusters(in)
+			if err != nil {
+				return errors.Wrapf(err, "failed to read %s", *file)
+			}
+			if len(clusters) == 0 {
+				return errors.Errorf("no CurveCluster found in %s", *file)
+			}
+			invalid := 0
+			for _, cluster := range clusters {
+				errs := validation.ValidateCluster(cluster)
+				if len(errs) == 0 {
+					fmt.Printf("CurveCluster %s is valid\n", cluster.Name)
+					continue
+				}
+				invalid++
+				fmt.Printf("CurveCluster %s is invalid:\n", cluster.Name)
+				for _, err := range errs {
+					fmt.Printf("  - %v\n", err)
+				}
+			}
+			if invalid > 0 {
+				return errors.Errorf("%d of %d CurveClusters in %s are invalid", invalid, len(clusters), *file)
+			}
+			return nil
+		},
+	}
+}
+
+// readClusters returns the CurveClusters of the yaml or json documents, the v1beta1 ones are converted to v1 like
+// the conversion webhook and the other kinds are skipped. The unknown fields are errors since they are dropped by
+// the api server silently, such as the fields of a typo.
+func readClusters(in io.Reader) ([]*operatorv1.CurveCluster, error) {
+	var clusters []*operatorv1.CurveCluster
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(in))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return clusters, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		typeMeta := &metav1.TypeMeta{}
+		if err := yaml.Unmarshal(doc, typeMeta); err != nil {
+			return nil, err
+		}
+		if typeMeta.Kind != "CurveCluster" {
+			continue
+		}
+		cluster := &operatorv1.CurveCluster{}
+		switch typeMeta.APIVersion {
+		case operatorv1.GroupVersion.String():
+			if err := yaml.UnmarshalStrict(doc, cluster); err != nil {
+				return nil, err
+			}
+		case operatorv1beta1.GroupVersion.String():
+			old := &operatorv1beta1.CurveCluster{}
+			if err := yaml.UnmarshalStrict(doc, old); err != nil {
+				return nil, err
+			}
+			if err := old.ConvertTo(cluster); err != nil {
+				return nil, errors.Wrapf(err, "failed to convert CurveCluster %q to %s", old.Name, operatorv1.GroupVersion)
+			}
+		default:
+			return nil, errors.Errorf("unknown apiVersion %q of CurveCluster", typeMeta.APIVersion)
+		}
+		clusters = append(clusters, cluster)
+	}
+}
@@ -0,0 +1,123 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// curvectl shows the status of curve clusters and operates them through the CurveCluster CRs
+// that curve-operator reconciles.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+
+	operatorv1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+const usage = `curvectl shows the status of curve clusters and operates them.
+
+Usage:
+  curvectl [--kubeconfig=<path>] <command> [flags] [cluster]
+
+Commands:
+  status           show the health of clusters
+  pause            stop the operator reconciling the cluster
+  resume           resume the reconciliation of the cluster
+  replace-device   migrate the data off a device so that it can be replaced
+  format-progress  show the progress of chunkfile pool formatting
+  validate         check the CurveClusters of a manifest by the rules of the webhook without a k8s cluster
+
+Run 'curvectl <command> -h' for the flags of a command.
+`
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = operatorv1.AddToScheme(scheme)
+}
+
+// command is a subcommand of curvectl, the offline ones don't talk to the k8s cluster
+type command struct {
+	flags   *flag.FlagSet
+	offline bool
+	run     func(c *clients, args []string) error
+}
+
+// clients are the clients to talk to the k8s cluster
+type clients struct {
+	client    client.Client
+	clientset kubernetes.Interface
+	namespace string
+}
+
+func main() {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c := &clients{}
+	commands := map[string]*command{
+		"status":          statusCommand(),
+		"pause":           pauseCommand(true),
+		"resume":          pauseCommand(false),
+		"replace-device":  replaceDeviceCommand(),
+		"format-progress": formatProgressCommand(),
+		"validate":        validateCommand(),
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(2)
+	}
+	cmd.flags.StringVar(&c.namespace, "n", "curvebs", "namespace of the cluster")
+	_ = cmd.flags.Parse(flag.Args()[1:])
+
+	if !cmd.offline {
+		if err := c.init(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if err := cmd.run(c, cmd.flags.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// init creates the clients by kubeconfig
+func (c *clients) init() error {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return err
+	}
+	c.client, err = client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+	c.clientset, err = kubernetes.NewForConfig(cfg)
+	return err
+}
@@ -0,0 +1,10 @@
+// Package crd embeds the CustomResourceDefinitions of curve-operator, which the operator installs and upgrades
+// at startup with --install-crds
+package crd
+
+import "embed"
+
+// Bases are the CRDs generated by controller-gen
+//
+//go:embed bases/*.yaml
+var Bases embed.FS
@@ -0,0 +1,36 @@
+#!/usr/bin/env bash
+# Run the e2e tests of the chunkserver provisioning on a kind cluster. The cluster is deleted afterwards unless
+# KEEP_CLUSTER is set, E2E_IMAGE and E2E_DEVICES are passed to the tests.
+set -euo pipefail
+
+CLUSTER_NAME=${CLUSTER_NAME:-curve-e2e}
+E2E_DEVICES=${E2E_DEVICES:-/curve-e2e/chunkserver0,/curve-e2e/chunkserver1}
+export E2E_DEVICES
+
+cd "$(dirname "$0")/.."
+
+if ! kind get clusters | grep -qx "$CLUSTER_NAME"; then
+  kind create cluster --name "$CLUSTER_NAME" --config - <<KIND
+kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha4
+nodes:
+- role: control-plane
+- role: worker
+- role: worker
+- role: worker
+KIND
+fi
+if [ -z "${KEEP_CLUSTER:-}" ]; then
+  trap 'kind delete cluster --name "$CLUSTER_NAME"' EXIT
+fi
+
+# the path devices are directories on the worker nodes
+for node in $(kind get nodes --name "$CLUSTER_NAME" | grep -v control-plane); do
+  for dir in ${E2E_DEVICES//,/ }; do
+    docker exec "$node" mkdir -p "$dir"
+  done
+done
+
+kubectl --context "kind-$CLUSTER_NAME" apply -f config/crd/bases
+kubectl config use-context "kind-$CLUSTER_NAME"
+go test -tags e2e ./pkg/chunkserver/ -run E2E -v -count=1
@@ -19,20 +19,34 @@ package main
 import (
 	"flag"
 	"os"
+	"time"
 
 	"github.com/spf13/pflag"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	operatorv1 "github.com/opencurve/curve-operator/api/v1"
+	operatorv1beta1 "github.com/opencurve/curve-operator/api/v1beta1"
+	"github.com/opencurve/curve-operator/config/crd"
+	"github.com/opencurve/curve-operator/pkg/audit"
 	"github.com/opencurve/curve-operator/pkg/clusterd"
+	curveconfig "github.com/opencurve/curve-operator/pkg/config"
 	"github.com/opencurve/curve-operator/pkg/controllers"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+	"github.com/opencurve/curve-operator/pkg/validation"
 )
 
+// leaderElectionID is the configmap that the leader of the operators is elected by
+const leaderElectionID = "aa88fc6c.curve.io"
+
 var (
 	scheme   = runtime.NewScheme()
 	setupLog = ctrl.Log.WithName("setup")
@@ -41,6 +55,8 @@ var (
 func init() {
 	_ = clientgoscheme.AddToScheme(scheme)
 	_ = operatorv1.AddToScheme(scheme)
+	_ = operatorv1beta1.AddToScheme(scheme)
+	_ = apiextensionsv1beta1.AddToScheme(scheme)
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -81,10 +97,36 @@ func main() {
 
 	var metricsAddr string
 	var enableLeaderElection bool
+	var enableConversionWebhook bool
+	var enableValidatingWebhook bool
+	var maxConcurrentReconciles int
+	var resyncPeriod time.Duration
+	var reconcileQPS float64
+	var reconcileBurst int
+	var installCRDs bool
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&enableConversionWebhook, "enable-conversion-webhook", false,
+		"Enable the conversion webhook of CurveCluster between v1beta1 and v1. "+
+			"The webhook certificates must be mounted and the CRD must be patched with config/crd/patches/webhook_in_curveclusters.yaml.")
+	flag.BoolVar(&enableValidatingWebhook, "enable-validating-webhook", false,
+		"Enable the validating webhook of CurveCluster that denies the invalid spec by the rules of 'curvectl validate'. "+
+			"The webhook certificates must be mounted and the webhook must be registered with config/webhook/manifests.yaml.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1,
+		"The number of CurveClusters in different namespaces that can be reconciled concurrently.")
+	flag.DurationVar(&resyncPeriod, "resync-period", 30*time.Minute,
+		"The period that the ready CurveClusters are reconciled again without the changes of spec to retry the updates "+
+			"that depend on the state of the cluster such as joining the pending storage nodes, 0 disables it. "+
+			"The children are not applied again by it.")
+	flag.Float64Var(&reconcileQPS, "cluster-reconcile-qps", 0,
+		"The reconciles per second of each CurveCluster, the reconciles over it are requeued. 0 doesn't limit them.")
+	flag.IntVar(&reconcileBurst, "cluster-reconcile-burst", 10,
+		"The reconciles of each CurveCluster allowed in a burst over cluster-reconcile-qps.")
+	flag.BoolVar(&installCRDs, "install-crds", false,
+		"Create or update the CRDs to the ones of the operator at startup, so the CRDs are upgraded with the operator without Helm. "+
+			"The CRDs installed by a newer operator are not downgraded.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
@@ -92,6 +134,18 @@ func main() {
 	// get config file and create clientSet that pass to context
 
 	config := ctrl.GetConfigOrDie()
+	// the manager of the fields updated without apply is the user agent, so the updates of the operator are told
+	// from the ones of others by the same manager as its applies
+	config.UserAgent = k8sutil.FieldManager
+
+	// the changes of the operator are recorded to the audit sink of the operator settings, the recorder uses the
+	// config that is not audited to write the sink
+	auditClientSet, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		setupLog.Error(err, "create clientset failed")
+		os.Exit(1)
+	}
+	config.Wrap(audit.NewWrapper(audit.NewRecorder(auditClientSet), "configmaps/"+leaderElectionID))
 
 	clientSet, err := kubernetes.NewForConfig(config)
 	if err != nil {
@@ -99,10 +153,31 @@ func main() {
 		os.Exit(1)
 	}
 
+	// the nodes are looked up in the shared cache by the provisioning of all the clusters
+	nodeCache := clusterd.NewNodeCache(clientSet)
+
 	// Create context
 	context := clusterd.Context{
 		KubeConfig: config,
-		Clientset:  clientSet,
+		Clientset:  nodeCache.Clientset(clientSet),
+		NodeCache:  nodeCache,
+	}
+
+	// the CRDs are installed before the manager maps the kinds of the controllers
+	if installCRDs {
+		c, err := client.New(config, client.Options{Scheme: scheme})
+		if err != nil {
+			setupLog.Error(err, "create client to install CRDs failed")
+			os.Exit(1)
+		}
+		names, err := k8sutil.InstallCRDs(c, crd.Bases)
+		if err == nil {
+			err = k8sutil.WaitForCRDsEstablished(c, names, time.Minute)
+		}
+		if err != nil {
+			setupLog.Error(err, "unable to install CRDs")
+			os.Exit(1)
+		}
 	}
 
 	mgr, err := ctrl.NewManager(config, ctrl.Options{
@@ -110,22 +185,192 @@ func main() {
 		MetricsBindAddress: metricsAddr,
 		Port:               9443,
 		LeaderElection:     enableLeaderElection,
-		LeaderElectionID:   "aa88fc6c.curve.io",
+		LeaderElectionID:   leaderElectionID,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	if err = (controllers.NewCurveClusterReconciler(
+	// the nodes are looked up in the api server until the cache is synced
+	err = mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+		if err := nodeCache.Start(stop); err != nil {
+			return err
+		}
+		<-stop
+		return nil
+	}))
+	if err != nil {
+		setupLog.Error(err, "unable to add node cache")
+		os.Exit(1)
+	}
+
+	// the operator settings are reloaded from the configmap in the namespace of the operator when it changes
+	if namespace := os.Getenv(curveconfig.OperatorNamespaceEnv); namespace != "" {
+		if err := controllers.LoadOperatorConfig(mgr.GetAPIReader(), namespace); err != nil {
+			setupLog.Error(err, "unable to load operator settings, the defaults are used")
+		}
+		if err = (controllers.NewOperatorConfigReconciler(
+			mgr.GetClient(),
+			ctrl.Log.WithName("controllers").WithName("OperatorConfig"),
+			mgr.GetScheme(),
+			namespace,
+		)).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "OperatorConfig")
+			os.Exit(1)
+		}
+	} else {
+		setupLog.Info("operator namespace is unknown, the default operator settings are used", "env", curveconfig.OperatorNamespaceEnv)
+	}
+
+	// repeated identical events are recorded once per interval with the count
+	recorder := k8sutil.NewDedupRecorder(mgr.GetEventRecorderFor("curve-operator"), k8sutil.DefaultDedupInterval)
+	curveClusterReconciler := controllers.NewCurveClusterReconciler(
 		mgr.GetClient(),
 		ctrl.Log.WithName("controllers").WithName("CurveCluster"),
 		mgr.GetScheme(),
+		recorder,
 		context,
-	)).SetupWithManager(mgr); err != nil {
+	)
+	curveClusterReconciler.MaxConcurrentReconciles = maxConcurrentReconciles
+	curveClusterReconciler.ResyncPeriod = resyncPeriod
+	curveClusterReconciler.ReconcileQPS = reconcileQPS
+	curveClusterReconciler.ReconcileBurst = reconcileBurst
+	if err = curveClusterReconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "CurveCluster")
 		os.Exit(1)
 	}
+	if enableConversionWebhook {
+		if err = (&operatorv1.CurveCluster{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "CurveCluster")
+			os.Exit(1)
+		}
+	}
+	if enableValidatingWebhook {
+		mgr.GetWebhookServer().Register(validation.WebhookPath, &webhook.Admission{Handler: &validation.Webhook{}})
+	}
+	if err = (controllers.NewNodeReconciler(
+		mgr.GetClient(),
+		ctrl.Log.WithName("controllers").WithName("Node"),
+		mgr.GetScheme(),
+		recorder,
+		context,
+	)).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Node")
+		os.Exit(1)
+	}
+	if err = (controllers.NewDrainReconciler(
+		mgr.GetClient(),
+		ctrl.Log.WithName("controllers").WithName("Drain"),
+		mgr.GetScheme(),
+		recorder,
+		context,
+	)).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Drain")
+		os.Exit(1)
+	}
+	if err = (controllers.NewDiskHealthReconciler(
+		mgr.GetClient(),
+		ctrl.Log.WithName("controllers").WithName("DiskHealth"),
+		mgr.GetScheme(),
+		recorder,
+		context,
+	)).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "DiskHealth")
+		os.Exit(1)
+	}
+	if err = (controllers.NewSelfTestReconciler(
+		mgr.GetClient(),
+		ctrl.Log.WithName("controllers").WithName("SelfTest"),
+		mgr.GetScheme(),
+		recorder,
+		context,
+	)).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "SelfTest")
+		os.Exit(1)
+	}
+	if err = (controllers.NewProfileReconciler(
+		mgr.GetClient(),
+		ctrl.Log.WithName("controllers").WithName("Profile"),
+		mgr.GetScheme(),
+		recorder,
+		context,
+	)).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Profile")
+		os.Exit(1)
+	}
+	if err = (controllers.NewJobFailureReconciler(
+		mgr.GetClient(),
+		ctrl.Log.WithName("controllers").WithName("JobFailure"),
+		mgr.GetScheme(),
+		recorder,
+		context,
+	)).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "JobFailure")
+		os.Exit(1)
+	}
+	if err = (controllers.NewCapacityReconciler(
+		mgr.GetClient(),
+		ctrl.Log.WithName("controllers").WithName("Capacity"),
+		mgr.GetScheme(),
+		context,
+	)).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Capacity")
+		os.Exit(1)
+	}
+	if err = (controllers.NewStaleTopologyReconciler(
+		mgr.GetClient(),
+		ctrl.Log.WithName("controllers").WithName("StaleTopology"),
+		mgr.GetScheme(),
+		context,
+	)).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "StaleTopology")
+		os.Exit(1)
+	}
+	if err = (controllers.NewGarbageCollectorReconciler(
+		mgr.GetClient(),
+		ctrl.Log.WithName("controllers").WithName("GarbageCollector"),
+		mgr.GetScheme(),
+		context,
+	)).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GarbageCollector")
+		os.Exit(1)
+	}
+	if err = (controllers.NewCurveQoSPolicyReconciler(
+		mgr.GetClient(),
+		ctrl.Log.WithName("controllers").WithName("CurveQoSPolicy"),
+		mgr.GetScheme(),
+		context,
+	)).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "CurveQoSPolicy")
+		os.Exit(1)
+	}
+	if err = (controllers.NewCurveUserReconciler(
+		mgr.GetClient(),
+		ctrl.Log.WithName("controllers").WithName("CurveUser"),
+		mgr.GetScheme(),
+		context,
+	)).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "CurveUser")
+		os.Exit(1)
+	}
+	if err = (controllers.NewCurveFleetReconciler(
+		mgr.GetClient(),
+		ctrl.Log.WithName("controllers").WithName("CurveFleet"),
+		mgr.GetScheme(),
+	)).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "CurveFleet")
+		os.Exit(1)
+	}
+	if err = (controllers.NewNamespaceReconciler(
+		mgr.GetClient(),
+		ctrl.Log.WithName("controllers").WithName("Namespace"),
+		mgr.GetScheme(),
+		context,
+	)).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Namespace")
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
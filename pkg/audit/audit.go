@@ -0,0 +1,170 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/pkg/capnslog"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/opencurve/curve-operator/pkg/config"
+)
+
+const (
+	// ConfigMapName is the append-only log of the changes of the operator in the namespace of the changed objects
+	ConfigMapName = "curve-audit-log"
+	// ConfigMapDataKey is the key of the log, an entry of json per line from the oldest to the newest
+	ConfigMapDataKey = "audit.log"
+
+	webhookTimeout = 10 * time.Second
+)
+
+var logger = capnslog.NewPackageLogger("github.com/opencurve/curve-operator", "audit")
+
+// Entry is a change of an object made by the operator
+type Entry struct {
+	Time time.Time `json:"time"`
+	// ReconcileID is the reconcile of the cluster in the namespace that made the change, it's empty if the change
+	// is made by other controllers
+	ReconcileID string `json:"reconcileID,omitempty"`
+	// Verb is create, update, patch or delete
+	Verb string `json:"verb"`
+	// Resource is the resource of the object such as deployments.apps
+	Resource  string `json:"resource"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	// Summary is the fields that are changed by an update such as spec.template
+	Summary string `json:"summary,omitempty"`
+}
+
+var reconcileIDs sync.Map
+
+// StartReconcile returns a new id of the reconcile of the cluster in the namespace, the changes in the namespace
+// are recorded with it until EndReconcile
+func StartReconcile(namespace string) string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	id := hex.EncodeToString(b)
+	reconcileIDs.Store(namespace, id)
+	return id
+}
+
+// EndReconcile ends the reconcile of the cluster in the namespace
+func EndReconcile(namespace string) {
+	reconcileIDs.Delete(namespace)
+}
+
+func reconcileID(namespace string) string {
+	if id, ok := reconcileIDs.Load(namespace); ok {
+		return id.(string)
+	}
+	return ""
+}
+
+// Recorder writes the entries to the sink of the operator settings
+type Recorder struct {
+	clientset kubernetes.Interface
+	client    *http.Client
+
+	// the entries of a configmap are appended one by one
+	mutex sync.Mutex
+}
+
+// NewRecorder returns a recorder that writes the configmaps by the clientset, its requests must not be audited
+func NewRecorder(clientset kubernetes.Interface) *Recorder {
+	return &Recorder{
+		clientset: clientset,
+		client:    &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// Record writes the entry to the sink, the failure is logged only and doesn't fail the change
+func (r *Recorder) Record(entry Entry) {
+	settings := config.GetOperatorSettings()
+	var err error
+	switch settings.AuditSink {
+	case config.AuditSinkConfigMap:
+		err = r.appendConfigMap(entry, settings.AuditMaxEntries)
+	case config.AuditSinkWebhook:
+		err = r.postWebhook(entry, settings.AuditWebhookURL)
+	default:
+		return
+	}
+	if err != nil {
+		logger.Errorf("failed to record %s %s %s/%s to audit %s. %v", entry.Verb, entry.Resource, entry.Namespace, entry.Name,
+			settings.AuditSink, err)
+	}
+}
+
+// appendConfigMap appends the entry to the configmap of the namespace, the oldest entries beyond maxEntries are
+// dropped. The changes of the cluster-scoped objects are not recorded to the configmaps.
+func (r *Recorder) appendConfigMap(entry Entry, maxEntries int) error {
+	if entry.Namespace == "" {
+		return nil
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMaps := r.clientset.CoreV1().ConfigMaps(entry.Namespace)
+		cm, err := configMaps.Get(ConfigMapName, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			cm = &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: ConfigMapName, Namespace: entry.Namespace},
+				Data:       map[string]string{ConfigMapDataKey: string(line) + "\n"},
+			}
+			_, err = configMaps.Create(cm)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		lines := strings.SplitAfter(cm.Data[ConfigMapDataKey], "\n")
+		if lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+		lines = append(lines, string(line)+"\n")
+		if len(lines) > maxEntries {
+			lines = lines[len(lines)-maxEntries:]
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[ConfigMapDataKey] = strings.Join(lines, "")
+		_, err = configMaps.Update(cm)
+		return err
+	})
+}
+
+// postWebhook posts the entry in json to the url
+func (r *Recorder) postWebhook(entry Entry, url string) error {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	resp, err := r.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrapf(err, "failed to post %s", url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("failed to post %s, status %s", url, resp.Status)
+	}
+	return nil
+}
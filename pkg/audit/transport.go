@@ -0,0 +1,256 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/transport"
+
+	"github.com/opencurve/curve-operator/pkg/config"
+)
+
+// fields that are changed by the API server on every update, they are not the changes of the operator
+var ignoredFields = map[string]bool{
+	"metadata.resourceVersion": true,
+	"metadata.generation":      true,
+	"metadata.managedFields":   true,
+	"status":                   true,
+}
+
+// resources that are not the infrastructure of the clusters
+var ignoredResources = map[string]bool{
+	"events":                             true,
+	"events.events.k8s.io":               true,
+	"leases.coordination.k8s.io":         true,
+	"tokenreviews.authentication.k8s.io": true,
+}
+
+// request is the object of an API request
+type request struct {
+	verb        string
+	resource    string
+	namespace   string
+	name        string
+	subresource string
+}
+
+// Transport records the creates, updates and deletes of the objects sent by the operator. An update is recorded
+// with the changed fields by the object got before it, the updates that change nothing such as applying the same
+// object are not recorded. The requests of subresources such as status are not recorded.
+type Transport struct {
+	next     http.RoundTripper
+	recorder *Recorder
+	// ignored are the objects that are changed all the time such as the lock of leader election, keyed by
+	// resource/name
+	ignored map[string]bool
+}
+
+// NewWrapper returns the wrapper of the transport of the rest config to audit the changes
+func NewWrapper(recorder *Recorder, ignored ...string) transport.WrapperFunc {
+	ignoredObjects := map[string]bool{}
+	for _, name := range ignored {
+		ignoredObjects[name] = true
+	}
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &Transport{next: rt, recorder: recorder, ignored: ignoredObjects}
+	}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if config.GetOperatorSettings().AuditSink == "" {
+		return t.next.RoundTrip(req)
+	}
+	r, ok := parseRequest(req)
+	if !ok || r.subresource != "" || ignoredResources[r.resource] || t.ignored[r.resource+"/"+r.name] ||
+		(r.resource == "configmaps" && r.name == ConfigMapName) {
+		return t.next.RoundTrip(req)
+	}
+
+	var before map[string]interface{}
+	if r.verb == "update" || r.verb == "patch" {
+		before = t.get(req)
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp, err
+	}
+
+	var after map[string]interface{}
+	if r.verb != "delete" {
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			return resp, nil
+		}
+		_ = json.Unmarshal(body, &after)
+	}
+
+	entry := Entry{
+		Time:        time.Now(),
+		ReconcileID: reconcileID(r.namespace),
+		Verb:        r.verb,
+		Resource:    r.resource,
+		Namespace:   r.namespace,
+		Name:        r.name,
+	}
+	switch {
+	case r.verb == "create":
+		entry.Name = nestedString(after, "metadata", "name")
+	case r.verb == "delete":
+	case before == nil:
+		// it's created by apply
+		entry.Verb = "create"
+	default:
+		if nestedString(before, "metadata", "resourceVersion") == nestedString(after, "metadata", "resourceVersion") {
+			return resp, nil
+		}
+		entry.Summary = strings.Join(summarize(before, after), ", ")
+	}
+	t.recorder.Record(entry)
+	return resp, nil
+}
+
+// get returns the object of the request before it's changed, or nil if it's not found
+func (t *Transport) get(req *http.Request) map[string]interface{} {
+	u := *req.URL
+	u.RawQuery = ""
+	get, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil
+	}
+	get = get.WithContext(req.Context())
+	for k, v := range req.Header {
+		if k != "Content-Type" {
+			get.Header[k] = v
+		}
+	}
+	get.Header.Set("Accept", "application/json")
+	resp, err := t.next.RoundTrip(get)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	var obj map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&obj); err != nil {
+		return nil
+	}
+	return obj
+}
+
+// parseRequest returns the object of the request that changes it, such as
+// /api/v1/namespaces/curvebs/configmaps/mds-conf or /apis/apps/v1/namespaces/curvebs/deployments/curve-mds-a
+func parseRequest(req *http.Request) (request, bool) {
+	r := request{}
+	switch req.Method {
+	case http.MethodPost:
+		r.verb = "create"
+	case http.MethodPut:
+		r.verb = "update"
+	case http.MethodPatch:
+		r.verb = "patch"
+		if req.Header.Get("Content-Type") == string(types.ApplyPatchType) {
+			r.verb = "update"
+		}
+	case http.MethodDelete:
+		r.verb = "delete"
+	default:
+		return r, false
+	}
+
+	segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	group := ""
+	switch {
+	case len(segments) >= 2 && segments[0] == "api":
+		segments = segments[2:]
+	case len(segments) >= 3 && segments[0] == "apis":
+		group = segments[1]
+		segments = segments[3:]
+	default:
+		return r, false
+	}
+	// the namespaced objects, but not a namespace itself
+	if len(segments) >= 3 && segments[0] == "namespaces" {
+		r.namespace = segments[1]
+		segments = segments[2:]
+	}
+	if len(segments) == 0 {
+		return r, false
+	}
+	r.resource = segments[0]
+	if group != "" {
+		r.resource += "." + group
+	}
+	if len(segments) >= 2 {
+		r.name = segments[1]
+	}
+	if len(segments) >= 3 {
+		r.subresource = segments[2]
+	}
+	return r, true
+}
+
+// summarize returns the fields that are different in the objects, the fields of the second level such as
+// spec.replicas or data.mds.conf
+func summarize(before, after map[string]interface{}) []string {
+	var changed []string
+	for _, key := range unionKeys(before, after) {
+		if ignoredFields[key] {
+			continue
+		}
+		b, bok := before[key].(map[string]interface{})
+		a, aok := after[key].(map[string]interface{})
+		if !bok || !aok {
+			if !reflect.DeepEqual(before[key], after[key]) {
+				changed = append(changed, key)
+			}
+			continue
+		}
+		for _, field := range unionKeys(b, a) {
+			path := key + "." + field
+			if !ignoredFields[path] && !reflect.DeepEqual(b[field], a[field]) {
+				changed = append(changed, path)
+			}
+		}
+	}
+	return changed
+}
+
+func unionKeys(a, b map[string]interface{}) []string {
+	keys := map[string]bool{}
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	var sorted []string
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+func nestedString(obj map[string]interface{}, fields ...string) string {
+	var value interface{} = obj
+	for _, field := range fields {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		value = m[field]
+	}
+	s, _ := value.(string)
+	return s
+}
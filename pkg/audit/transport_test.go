@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/opencurve/curve-operator/pkg/config"
+)
+
+func TestTransport(t *testing.T) {
+	settings := config.DefaultOperatorSettings()
+	settings.AuditSink = config.AuditSinkConfigMap
+	settings.AuditMaxEntries = 2
+	config.SetOperatorSettings(settings)
+	defer config.SetOperatorSettings(config.DefaultOperatorSettings())
+
+	// the API server serves a configmap whose data is changed by every patch
+	version := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPatch && r.URL.Query().Get("noop") == "" {
+			version++
+		}
+		name := "mds-conf"
+		if r.Method == http.MethodPost {
+			name = "new-conf"
+		}
+		fmt.Fprintf(w, `{"metadata":{"name":%q,"resourceVersion":"%d"},"data":{"mds.conf":"v%d"}}`, name, version, version)
+	}))
+	defer server.Close()
+
+	clientset := fake.NewSimpleClientset()
+	client := &http.Client{Transport: NewWrapper(NewRecorder(clientset))(http.DefaultTransport)}
+	send := func(method, path, contentType string) {
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", contentType)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	id := StartReconcile("curvebs")
+	send(http.MethodPatch, "/api/v1/namespaces/curvebs/configmaps/mds-conf", "application/apply-patch+yaml")
+	EndReconcile("curvebs")
+	// the patch that changes nothing and the changes of the status are not recorded
+	send(http.MethodPatch, "/api/v1/namespaces/curvebs/configmaps/mds-conf?noop=true", "application/apply-patch+yaml")
+	send(http.MethodPut, "/apis/apps/v1/namespaces/curvebs/deployments/curve-mds-a/status", "application/json")
+	send(http.MethodPost, "/api/v1/namespaces/curvebs/configmaps", "application/json")
+
+	cm, err := clientset.CoreV1().ConfigMaps("curvebs").Get(ConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(cm.Data[ConfigMapDataKey]), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 entries, got %q", cm.Data[ConfigMapDataKey])
+	}
+	var entries []Entry
+	for _, line := range lines {
+		entry := Entry{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+	if e := entries[0]; e.Verb != "update" || e.Resource != "configmaps" || e.Name != "mds-conf" || e.ReconcileID != id ||
+		e.Summary != "data.mds.conf" {
+		t.Errorf("unexpected entry of update %+v", e)
+	}
+	if e := entries[1]; e.Verb != "create" || e.Name != "new-conf" || e.ReconcileID != "" {
+		t.Errorf("unexpected entry of create %+v", e)
+	}
+
+	// the oldest entries beyond the limit are dropped
+	send(http.MethodDelete, "/apis/apps/v1/namespaces/curvebs/deployments/curve-mds-a", "application/json")
+	cm, _ = clientset.CoreV1().ConfigMaps("curvebs").Get(ConfigMapName, metav1.GetOptions{})
+	lines = strings.Split(strings.TrimSpace(cm.Data[ConfigMapDataKey]), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "new-conf") || !strings.Contains(lines[1], `"resource":"deployments.apps"`) {
+		t.Errorf("unexpected entries after the limit %q", cm.Data[ConfigMapDataKey])
+	}
+}
@@ -0,0 +1,68 @@
+package chunkserver
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// AdoptedChunkServer is a chunkserver of the adopted cluster and the device on the node that it runs on
+type AdoptedChunkServer struct {
+	NodeName   string
+	DeviceName string
+	Port       int
+}
+
+// AdoptChunkServers records the devices of the chunkservers of the adopted cluster as formatted, so they are not
+// formatted again and the chunkservers keep their ports. The devices must be in the storage spec.
+func (c *Cluster) AdoptChunkServers(adopted []AdoptedChunkServer) error {
+	records, err := c.loadInventory()
+	if err != nil {
+		return err
+	}
+
+	nodeDevices, err := c.resolvedNodeDevices()
+	if err != nil {
+		return err
+	}
+	now := time.Now().Format(time.RFC3339)
+	for _, cs := range adopted {
+		key := inventoryKey(cs.NodeName, cs.DeviceName)
+		reserved, ok := records[key]
+		if ok && !reserved.Reserved {
+			continue
+		}
+		var found bool
+		r := DeviceRecord{
+			NodeName:    cs.NodeName,
+			DeviceName:  cs.DeviceName,
+			ChunkServer: DeploymentName(cs.NodeName, cs.DeviceName),
+			Port:        cs.Port,
+			FormattedAt: now,
+		}
+		for _, device := range nodeDevices[cs.NodeName] {
+			if device.Name != cs.DeviceName {
+				continue
+			}
+			found = true
+			_, _, chunkSize := c.devicePool(device.Name)
+			r.DeviceType = string(device.Type)
+			r.MountPath = device.MountPath
+			r.Encrypted = device.Encrypted
+			r.CacheDevice = device.CacheDevice
+			r.Percentage = device.Percentage
+			r.ChunkFileSize = int(chunkSize)
+		}
+		if !found {
+			return errors.Errorf("device %s of the chunkserver on port %d of node %s is not in storage", cs.DeviceName, cs.Port, cs.NodeName)
+		}
+		// the sequences are bound by the ports like the records of old operators, unless they were imported for
+		// the chunkserver on the same port
+		if ok && reserved.Port == cs.Port {
+			r.HostSequence, r.ReplicasSequence = reserved.HostSequence, reserved.ReplicasSequence
+		}
+		records[key] = r
+	}
+
+	return c.saveInventory(records)
+}
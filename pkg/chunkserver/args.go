@@ -0,0 +1,29 @@
+package chunkserver
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// flagName is the name of a gflags flag
+var flagName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// extraArgs returns the flags of storage.extraArgs in order of name
+func (c *Cluster) extraArgs() ([]string, error) {
+	names := make([]string, 0, len(c.spec.Storage.ExtraArgs))
+	for name := range c.spec.Storage.ExtraArgs {
+		if !flagName.MatchString(name) {
+			return nil, errors.Errorf("storage.extraArgs has invalid flag name %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := make([]string, 0, len(names))
+	for _, name := range names {
+		args = append(args, "-"+name+"="+c.spec.Storage.ExtraArgs[name])
+	}
+	return args, nil
+}
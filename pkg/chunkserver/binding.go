@@ -0,0 +1,96 @@
+package chunkserver
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+// chunkserverBinding is the identity of the chunkserver of a device. It's kept in the inventory once the device is
+// formatted, so that reordering the nodes or the devices in the spec doesn't move the chunkservers to other ports
+// or other names in topology.
+type chunkserverBinding struct {
+	HostSequence     int
+	ReplicasSequence int
+	Port             int
+}
+
+// bindChunkServers returns the bindings of the devices on the nodes keyed by inventoryKey. The formatted devices keep
+// the bindings of their records, and so do the reserved ones, the others take the lowest free sequences and ports in
+// order of the spec. The records of old operators have only the port, the replicas sequence is derived from it.
+func (c *Cluster) bindChunkServers(nodes []v1.Node, records map[string]DeviceRecord) map[string]chunkserverBinding {
+	// the sequences of the nodes that are not valid now are kept for them too
+	hostSequences := map[string]int{}
+	usedHostSequences := map[int]bool{}
+	for _, r := range records {
+		if r.HostSequence == nil {
+			continue
+		}
+		if _, ok := hostSequences[r.NodeName]; !ok {
+			hostSequences[r.NodeName] = *r.HostSequence
+			usedHostSequences[*r.HostSequence] = true
+		}
+	}
+	for _, node := range nodes {
+		if _, ok := hostSequences[node.Name]; ok {
+			continue
+		}
+		hostSequences[node.Name] = lowestFree(usedHostSequences, 0)
+	}
+
+	bindings := map[string]chunkserverBinding{}
+	for _, node := range nodes {
+		devices := c.nodeDevices[node.Name]
+		usedSequences, usedPorts := map[int]bool{}, map[int]bool{}
+		bound := map[string]chunkserverBinding{}
+		for _, device := range devices {
+			r, ok := records[inventoryKey(node.Name, device.Name)]
+			if !ok || r.Port == 0 {
+				continue
+			}
+			b := chunkserverBinding{HostSequence: hostSequences[node.Name], ReplicasSequence: -1, Port: r.Port}
+			usedPorts[r.Port] = true
+			if r.ReplicasSequence != nil {
+				b.ReplicasSequence = *r.ReplicasSequence
+				usedSequences[b.ReplicasSequence] = true
+			}
+			bound[device.Name] = b
+		}
+		// the port of a chunkserver was the port of storage plus its replicas sequence
+		for _, device := range devices {
+			b, ok := bound[device.Name]
+			if !ok || b.ReplicasSequence >= 0 {
+				continue
+			}
+			if sequence := b.Port - c.spec.Storage.Port; sequence >= 0 && !usedSequences[sequence] {
+				b.ReplicasSequence = sequence
+				usedSequences[sequence] = true
+				bound[device.Name] = b
+			}
+		}
+		for _, device := range devices {
+			b, ok := bound[device.Name]
+			if !ok {
+				b = chunkserverBinding{HostSequence: hostSequences[node.Name], ReplicasSequence: -1}
+				b.Port = lowestFree(usedPorts, c.spec.Storage.Port)
+				if sequence := b.Port - c.spec.Storage.Port; !usedSequences[sequence] {
+					b.ReplicasSequence = sequence
+					usedSequences[sequence] = true
+				}
+			}
+			if b.ReplicasSequence < 0 {
+				b.ReplicasSequence = lowestFree(usedSequences, 0)
+			}
+			bindings[inventoryKey(node.Name, device.Name)] = b
+		}
+	}
+	return bindings
+}
+
+// lowestFree returns the lowest value from start that is not used, and marks it used
+func lowestFree(used map[int]bool, start int) int {
+	v := start
+	for used[v] {
+		v++
+	}
+	used[v] = true
+	return v
+}
@@ -0,0 +1,60 @@
+package chunkserver
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pkg/errors"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// updateNodeCapacity records the size of the chunk file pools on each storage node in the cluster status
+func (c *Cluster) updateNodeCapacity() error {
+	var nodes []curvev1.NodeCapacityStatus
+	for _, nodeName := range c.spec.StorageNodes() {
+		status := curvev1.NodeCapacityStatus{Node: nodeName}
+		for _, device := range c.spec.Storage.NodeDevices(nodeName) {
+			status.PoolBytes += device.PoolBytes()
+		}
+		nodes = append(nodes, status)
+	}
+
+	clusterObj := &curvev1.CurveCluster{}
+	if err := c.context.Client.Get(context.TODO(), c.namespacedName, clusterObj); err != nil {
+		return errors.Wrapf(err, "failed to get curvecluster %q", c.namespacedName)
+	}
+	if reflect.DeepEqual(clusterObj.Status.NodeCapacity, nodes) {
+		return nil
+	}
+	clusterObj.Status.NodeCapacity = nodes
+	return k8sutil.UpdateStatus(c.context.Client, c.namespacedName, clusterObj)
+}
+
+// updateCopysetPlan records how the copysets of the logical pools were computed in the cluster status
+func (c *Cluster) updateCopysetPlan() error {
+	if c.copysetPlans == nil {
+		return nil
+	}
+	var plan *curvev1.CopysetPlanStatus
+	var poolPlans []curvev1.CopysetPlanStatus
+	for i := range c.copysetPlans {
+		if c.copysetPlans[i].Pool == DEFAULT_LOGICAL_POOL_NAME {
+			plan = &c.copysetPlans[i]
+		} else {
+			poolPlans = append(poolPlans, c.copysetPlans[i])
+		}
+	}
+
+	clusterObj := &curvev1.CurveCluster{}
+	if err := c.context.Client.Get(context.TODO(), c.namespacedName, clusterObj); err != nil {
+		return errors.Wrapf(err, "failed to get curvecluster %q", c.namespacedName)
+	}
+	if reflect.DeepEqual(clusterObj.Status.CopysetPlan, plan) && reflect.DeepEqual(clusterObj.Status.PoolCopysetPlans, poolPlans) {
+		return nil
+	}
+	clusterObj.Status.CopysetPlan = plan
+	clusterObj.Status.PoolCopysetPlans = poolPlans
+	return k8sutil.UpdateStatus(c.context.Client, c.namespacedName, clusterObj)
+}
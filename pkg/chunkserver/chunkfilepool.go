@@ -10,11 +10,13 @@ import (
 	v1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	curvev1 "github.com/opencurve/curve-operator/api/v1"
 	"github.com/opencurve/curve-operator/pkg/chunkserver/script"
 	"github.com/opencurve/curve-operator/pkg/config"
 	"github.com/opencurve/curve-operator/pkg/k8sutil"
+	"github.com/opencurve/curve-operator/pkg/snapshotclone"
 )
 
 const (
@@ -24,6 +26,10 @@ const (
 	formatConfigMapName     = "format-chunkfile-conf"
 	formatScriptFileDataKey = "format.sh"
 	formatScriptMountPath   = "/curvebs/tools/sbin/format.sh"
+
+	// maxDeviceBaseNameLength keeps the names of the chunkserver resources in the limit of labels, it fits a
+	// wwn such as wwn-0x5000c500a1b2c3d4
+	maxDeviceBaseNameLength = 24
 )
 
 type Job2DeviceInfo struct {
@@ -32,146 +38,299 @@ type Job2DeviceInfo struct {
 	nodeName string
 }
 
-// global variables
-var job2DeviceInfos []*Job2DeviceInfo
-var chunkserverConfigs []chunkserverConfig
-
 // startProvisioningOverNodes format device and provision chunk files
 func (c *Cluster) startProvisioningOverNodes(nodeNameIP map[string]string) error {
-	if !c.spec.Storage.UseSelectedNodes {
-		// clear slice
-		job2DeviceInfos = []*Job2DeviceInfo{}
-		chunkserverConfigs = []chunkserverConfig{}
+	// clear slice
+	c.job2DeviceInfos = []*Job2DeviceInfo{}
+	c.queuedFormats = []*Job2DeviceInfo{}
+	c.chunkserverConfigs = []chunkserverConfig{}
+	c.nodeDevices = map[string][]curvev1.DevicesSpec{}
 
-		hostnameMap, err := k8sutil.GetNodeHostNames(c.context.Clientset)
-		if err != nil {
-			return errors.Wrap(err, "failed to get node hostnames")
-		}
+	resolved, err := k8sutil.ResolveNodeNames(c.context.Clientset, c.spec.StorageNodes())
+	if err != nil {
+		return errors.Wrap(err, "failed to resolve storage nodes")
+	}
 
-		var storageNodes []string
-		for _, nodeName := range c.spec.Storage.Nodes {
-			storageNodes = append(storageNodes, hostnameMap[nodeName])
-		}
+	var storageNodes []string
+	for _, nodeName := range c.spec.StorageNodes() {
+		storageNodes = append(storageNodes, resolved[nodeName])
+		c.nodeDevices[resolved[nodeName]] = c.spec.Storage.NodeDevices(nodeName)
+	}
 
-		// get valid nodes that ready status and is schedulable
-		validNodes, _ := k8sutil.GetValidNodes(c.context, storageNodes)
-		if len(validNodes) == 0 {
-			logger.Warningf("no valid nodes available to run chunkservers on nodes in namespace %q", c.namespacedName.Namespace)
-			return nil
+	// get valid nodes that ready status and is schedulable
+	validNodes, _ := k8sutil.GetValidNodes(c.context, storageNodes)
+	logger.Infof("%d of the %d storage nodes are valid", len(validNodes), len(storageNodes))
+
+	// the devices that have been formatted, and the bindings imported for the devices to format
+	records, err := c.loadInventory()
+	if err != nil {
+		return errors.Wrap(err, "failed to load formatted device inventory")
+	}
+	formatted := formattedRecords(records)
+
+	// the cluster is created once enough storage nodes are ready, the others join it later
+	minReadyNodes := c.spec.Storage.MinReadyNodes
+	if minReadyNodes == 0 {
+		minReadyNodes = 1
+	}
+	if err := c.updatePendingNodes(storageNodes, validNodes); err != nil {
+		logger.Warningf("failed to update pending storage nodes in status. %v", err)
+	}
+	if len(formatted) == 0 && len(validNodes) < minReadyNodes {
+		return errors.Errorf("%d of the %d storage nodes are ready and schedulable, waiting for minReadyNodes %d",
+			len(validNodes), len(storageNodes), minReadyNodes)
+	}
+	if len(validNodes) == 0 {
+		logger.Warningf("no valid nodes available to run chunkservers on nodes in namespace %q", c.namespacedName.Namespace)
+		return nil
+	}
+
+	// the chunkservers keep their sequences and ports whatever the order of nodes and devices
+	c.bindings = c.bindChunkServers(validNodes, records)
+
+	// check the nodes before any device on them is formatted
+	toFormat := map[string][]int{}
+	for _, node := range validNodes {
+		if indexes := c.devicesToFormat(node.Name, formatted); len(indexes) > 0 {
+			toFormat[node.Name] = indexes
 		}
+	}
+	// there is nothing to check for the mock devices of dev mode
+	if len(toFormat) > 0 && !c.spec.DevMode {
+		if err := c.runPreflightChecks(toFormat); err != nil {
+			return err
+		}
+	}
 
-		logger.Infof("%d of the %d storage nodes are valid", len(validNodes), len(c.spec.Storage.Nodes))
+	// create FORMAT configmap
+	err = c.createFormatConfigMap()
+	if err != nil {
+		return errors.Wrap(err, "failed to create format ConfigMap")
+	}
 
-		// create FORMAT configmap
-		err = c.createFormatConfigMap()
-		if err != nil {
-			return errors.Wrap(err, "failed to create format ConfigMap")
+	// get ClusterEtcdAddr and ClusterMdsAddr
+	clusterInfo, err := config.GetClusterInfo(&c.context, c.namespacedName.Namespace)
+	if err != nil {
+		return err
+	}
+	clusterEtcdAddr := clusterInfo.EtcdAddr
+	clusterMdsAddr := clusterInfo.MdsAddr
+	if clusterEtcdAddr == "" || clusterMdsAddr == "" {
+		return errors.Errorf("endpoints of etcd %q and mds %q are not recorded in configmap %q yet",
+			clusterEtcdAddr, clusterMdsAddr, config.ClusterInfoConfigMapName)
+	}
+
+	// get clusterMdsDummyPort
+	dummyPort := strconv.Itoa(c.spec.Mds.DummyPort)
+	clusterMdsDummyPort := dummyPort + "," + dummyPort + "," + dummyPort
+
+	// get clusterSnapCloneAddr and clusterSnapShotCloneDummyPort
+	var clusterSnapCloneAddr string
+	var clusterSnapShotCloneDummyPort string
+	if c.spec.SnapShotClone.Enable {
+		clusterSnapCloneAddr = snapshotclone.Endpoints(c.spec, c.namespacedName.Namespace, nodeNameIP)
+
+		dummyPort := strconv.Itoa(c.spec.SnapShotClone.DummyPort)
+		clusterSnapShotCloneDummyPort = fmt.Sprintf("%s,%s,%s", dummyPort, dummyPort, dummyPort)
+	}
+
+	// start the jobs by the format order, the ones out of the slots of maxConcurrentFormats are queued
+	failed := map[string]bool{}
+	for _, target := range c.orderFormats(validNodes, resolved, formatted) {
+		target := target
+		if c.formatSlots() == 0 {
+			logger.Infof("queueing job for device %s on %s", target.device.Name, target.nodeName)
+			c.queuedFormats = append(c.queuedFormats, &Job2DeviceInfo{nil, &target.device, target.nodeName})
+			continue
 		}
+		logger.Infof("creating job for device %s on %s", target.device.Name, target.nodeName)
 
-		// get ClusterEtcdAddr
-		etcdOverrideCM, err := c.context.Clientset.CoreV1().ConfigMaps(c.namespacedName.Namespace).Get(config.EtcdOverrideConfigMapName, metav1.GetOptions{})
+		job, err := c.runPrepareJob(target.nodeName, target.device)
 		if err != nil {
-			return errors.Wrap(err, "failed to get etcd override endoints configmap")
+			logger.Errorf("failed to create job for device %s on %s-%v", target.device.Name, target.nodeName, err)
+			failed[inventoryKey(target.nodeName, target.device.Name)] = true
+			c.nodeErrors = append(c.nodeErrors, k8sutil.NewNodeError(target.nodeName,
+				errors.Wrapf(err, "failed to create job for device %s", target.device.Name)))
+			continue
 		}
-		clusterEtcdAddr := etcdOverrideCM.Data[config.ClusterEtcdAddr]
 
-		// get ClusterMdsAddr
-		mdsOverrideCM, err := c.context.Clientset.CoreV1().ConfigMaps(c.namespacedName.Namespace).Get(config.MdsOverrideConfigMapName, metav1.GetOptions{})
-		if err != nil {
-			return errors.Wrap(err, "failed to get mds override endoints configmap")
+		jobInfo := &Job2DeviceInfo{
+			job,
+			&target.device,
+			target.nodeName,
 		}
-		clusterMdsAddr := mdsOverrideCM.Data[config.MdsOvverideConfigMapDataKey]
-
-		// get clusterMdsDummyPort
-		dummyPort := strconv.Itoa(c.spec.Mds.DummyPort)
-		clusterMdsDummyPort := dummyPort + "," + dummyPort + "," + dummyPort
-
-		// get clusterSnapCloneAddr and clusterSnapShotCloneDummyPort
-		var clusterSnapCloneAddr string
-		var clusterSnapShotCloneDummyPort string
-		if c.spec.SnapShotClone.Enable {
-			for _, ipAddr := range nodeNameIP {
-				clusterSnapCloneAddr = fmt.Sprint(clusterSnapCloneAddr, ipAddr, ":", c.spec.SnapShotClone.Port, ",")
+		// jobsArr record all the job that have started, to determine whether the format is completed
+		c.job2DeviceInfos = append(c.job2DeviceInfos, jobInfo)
+	}
+
+	// travel all valid nodes to construct chunkserverConfig
+	for _, node := range validNodes {
+		nodeIP := nodeNameIP[node.Name]
+
+		// travel all device to construct chunkserverConfig
+		devices := c.nodeDevices[node.Name]
+		for _, device := range devices {
+			device := device
+			name := deviceBaseName(device.Name)
+			resourceName := DeploymentName(node.Name, device.Name)
+			currentConfigMapName := configMapName(node.Name, device.Name)
+
+			cpuSet := device.CPUSet
+			if cpuSet == "" {
+				cpuSet = c.spec.Storage.CPUPinning.CPUSet
 			}
-			clusterSnapCloneAddr = strings.TrimRight(clusterSnapCloneAddr, ",")
 
-			dummyPort := strconv.Itoa(c.spec.SnapShotClone.DummyPort)
-			clusterSnapShotCloneDummyPort = fmt.Sprintf("%s,%s,%s", dummyPort, dummyPort, dummyPort)
-		}
+			// a path device is backed by the host directory itself, the mount path of a spdk device
+			// keeps the metadata of chunkserver, and the mock chunk file pool is created at the mount path in dev mode
+			hostDataDir := ""
+			if device.IsPath() {
+				hostDataDir = device.Name
+			} else if c.spec.Storage.IsSPDK() || c.spec.DevMode {
+				hostDataDir = device.MountPath
+			}
+			hostLoopDir := ""
+			if device.IsLoop() {
+				hostLoopDir = c.loopHostDir()
+			}
 
-		hostSequence := 0
-		// travel all valid nodes to start job to prepare chunkfiles
-		for _, node := range validNodes {
-			nodeIP := nodeNameIP[node.Name]
-			portBase := c.spec.Storage.Port
-			replicasSequence := 0
-
-			// travel all device to run format job and construct chunkserverConfig
-			for _, device := range c.spec.Storage.Devices {
-				name := strings.TrimSpace(device.Name)
-				name = strings.TrimRight(name, "/")
-				nameArr := strings.Split(name, "/")
-				name = nameArr[len(nameArr)-1]
-				resourceName := fmt.Sprintf("%s-%s-%s", AppName, node.Name, name)
-				currentConfigMapName := fmt.Sprintf("%s-%s-%s", ConfigMapNamePrefix, node.Name, name)
-
-				logger.Infof("creating job for device %s on %s", device.Name, node.Name)
-
-				job, err := c.runPrepareJob(node.Name, device)
-				if err != nil {
-					logger.Errorf("failed to create job for device %s on %s-%v", device.Name, node.Name, err)
-					continue // do not record the failed job in jobsArr and do not create chunkserverConfig for this device
+			encrypted := device.Encrypted
+			cacheDevice := device.CacheDevice
+			stableName := ""
+			logicalPool, physicalPool, chunkSize := c.devicePool(device.Name)
+			if record, ok := formatted[inventoryKey(node.Name, device.Name)]; ok {
+				// formatting again destroys the data on it
+				if record.Percentage != device.Percentage {
+					logger.Warningf("percentage of device %s on %s is changed from %d to %d, but it won't be formatted again",
+						device.Name, node.Name, record.Percentage, device.Percentage)
 				}
-
-				jobInfo := &Job2DeviceInfo{
-					job,
-					&device,
-					node.Name,
+				if record.Encrypted != device.Encrypted {
+					logger.Warningf("encrypted of device %s on %s is changed from %t to %t, but it won't be formatted again",
+						device.Name, node.Name, record.Encrypted, device.Encrypted)
+				}
+				if record.CacheDevice != device.CacheDevice {
+					logger.Warningf("cacheDevice of device %s on %s is changed from %q to %q, but it won't be formatted again",
+						device.Name, node.Name, record.CacheDevice, device.CacheDevice)
 				}
-				// jobsArr record all the job that have started, to determine whether the format is completed
-				job2DeviceInfos = append(job2DeviceInfos, jobInfo)
-
-				// create chunkserver config for each device of every node
-				chunkserverConfig := chunkserverConfig{
-					Prefix:                        Prefix,
-					Port:                          portBase,
-					ClusterMdsAddr:                clusterMdsAddr,
-					ClusterMdsDummyPort:           clusterMdsDummyPort,
-					ClusterEtcdAddr:               clusterEtcdAddr,
-					ClusterSnapshotcloneAddr:      clusterSnapCloneAddr,
-					ClusterSnapshotcloneDummyPort: clusterSnapShotCloneDummyPort,
-
-					ResourceName:         resourceName,
-					CurrentConfigMapName: currentConfigMapName,
-					DataPathMap: &chunkserverDataPathMap{
-						HostDevice:       device.Name,
-						HostLogDir:       c.logDirHostPath + "/chunkserver-" + node.Name + "-" + name,
-						ContainerDataDir: ChunkserverContainerDataDir,
-						ContainerLogDir:  ChunkserverContainerLogDir,
-					},
-					NodeName:         node.Name,
-					NodeIP:           nodeIP,
-					DeviceName:       device.Name,
-					HostSequence:     hostSequence,
-					ReplicasSequence: replicasSequence,
-					Replicas:         len(c.spec.Storage.Devices),
+				// the chunk files of the other size can't be used by the chunkserver of the pool
+				if record.ChunkFileSize != 0 && int64(record.ChunkFileSize) != chunkSize {
+					return k8sutil.NewConfigError(errors.Errorf("device %s on %s is formatted with chunk size %d, it can't be in pool %s of chunk size %d",
+						device.Name, node.Name, record.ChunkFileSize, logicalPool, chunkSize))
 				}
-				chunkserverConfigs = append(chunkserverConfigs, chunkserverConfig)
-				portBase++
-				replicasSequence++
+				encrypted = record.Encrypted
+				cacheDevice = record.CacheDevice
+				stableName = record.StableName
+				if encrypted && device.KeySecret == nil {
+					return k8sutil.NewConfigError(errors.Errorf("device %s on %s is encrypted but has no keySecret", device.Name, node.Name))
+				}
+				logger.Infof("device %s on %s has been formatted at %s, skip formatting", device.Name, node.Name, record.FormattedAt)
+			} else if failed[inventoryKey(node.Name, device.Name)] {
+				continue // do not create chunkserverConfig for the device whose job failed to be created
+			}
+
+			// create chunkserver config for each device of every node
+			binding := c.bindings[inventoryKey(node.Name, device.Name)]
+			chunkserverConfig := chunkserverConfig{
+				Prefix:                        Prefix,
+				Port:                          binding.Port,
+				ClusterMdsAddr:                clusterMdsAddr,
+				ClusterMdsDummyPort:           clusterMdsDummyPort,
+				ClusterEtcdAddr:               clusterEtcdAddr,
+				ClusterSnapshotcloneAddr:      clusterSnapCloneAddr,
+				ClusterSnapshotcloneDummyPort: clusterSnapShotCloneDummyPort,
+
+				ResourceName:         resourceName,
+				CurrentConfigMapName: currentConfigMapName,
+				DataPathMap: &chunkserverDataPathMap{
+					HostDevice:       device.Name,
+					HostDataDir:      hostDataDir,
+					HostLoopDir:      hostLoopDir,
+					HostLogDir:       c.logDirHostPath + "/chunkserver-" + node.Name + "-" + name,
+					ContainerDataDir: ChunkserverContainerDataDir,
+					ContainerLogDir:  ChunkserverContainerLogDir,
+				},
+				NodeName:         node.Name,
+				NodeIP:           nodeIP,
+				DeviceName:       device.Name,
+				StableName:       stableName,
+				DeviceType:       device.Type,
+				Filesystem:       device.GetFilesystem(),
+				MountOptions:     strings.Join(device.MountOptions, ","),
+				Encrypted:        encrypted,
+				KeySecret:        device.KeySecret,
+				CacheDevice:      cacheDevice,
+				CPUSet:           cpuSet,
+				HostSequence:     binding.HostSequence,
+				ReplicasSequence: binding.ReplicasSequence,
+				Replicas:         len(devices),
+				LogicalPool:      logicalPool,
+				PhysicalPool:     physicalPool,
+				ChunkSize:        chunkSize,
 			}
-			hostSequence++
+			chunkserverConfig.DataPathMap.HostDevice = chunkserverConfig.devicePath()
+			c.chunkserverConfigs = append(c.chunkserverConfigs, chunkserverConfig)
 		}
 	}
+	if len(c.queuedFormats) > 0 {
+		logger.Infof("%d format jobs are queued by maxConcurrentFormats %d", len(c.queuedFormats), c.maxConcurrentFormats())
+	}
 
 	return nil
 }
 
+// maxConcurrentFormats returns storage.maxConcurrentFormats, or the one of the operator settings if it's not set
+func (c *Cluster) maxConcurrentFormats() int {
+	if c.spec.Storage.MaxConcurrentFormats > 0 {
+		return c.spec.Storage.MaxConcurrentFormats
+	}
+	return config.GetOperatorSettings().MaxConcurrentFormats
+}
+
+// formatSlots returns the number of the format jobs that can be started now, the started jobs that have neither
+// succeeded nor failed take the slots of storage.maxConcurrentFormats. It's -1 if there is no limit.
+func (c *Cluster) formatSlots() int {
+	limit := c.maxConcurrentFormats()
+	if limit <= 0 {
+		return -1
+	}
+	running := 0
+	for _, info := range c.job2DeviceInfos {
+		if info.job.Status.Succeeded == 0 && jobFailedCondition(info.job) == nil {
+			running++
+		}
+	}
+	if running >= limit {
+		return 0
+	}
+	return limit - running
+}
+
+// startQueuedFormats starts the queued format jobs in the free slots, the status of the started jobs must be
+// refreshed before to count the running ones
+func (c *Cluster) startQueuedFormats() error {
+	for len(c.queuedFormats) > 0 && c.formatSlots() != 0 {
+		info := c.queuedFormats[0]
+		logger.Infof("creating job for device %s on %s, %d jobs are still queued", info.device.Name, info.nodeName, len(c.queuedFormats)-1)
+		// the chunkserver of the device is to be started, so the job can't be skipped like the ones failed at first
+		job, err := c.runPrepareJob(info.nodeName, *info.device)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create job for device %s on %s", info.device.Name, info.nodeName)
+		}
+		info.job = job
+		c.job2DeviceInfos = append(c.job2DeviceInfos, info)
+		c.queuedFormats = c.queuedFormats[1:]
+	}
+	return nil
+}
+
 // createConfigMap create configmap to store format.sh script
 func (c *Cluster) createFormatConfigMap() error {
 	// create configmap data with only one key of "format.sh"
+	formatScript := script.FORMAT
+	if c.spec.DevMode {
+		formatScript = script.MOCK_FORMAT
+	}
 	formatConfigMapData := map[string]string{
-		formatScriptFileDataKey: script.FORMAT,
+		formatScriptFileDataKey: formatScript,
 	}
 
 	cm := &v1.ConfigMap{
@@ -182,14 +341,15 @@ func (c *Cluster) createFormatConfigMap() error {
 		Data: formatConfigMapData,
 	}
 
+	k8sutil.InjectMetadata(c.spec, "chunkserver", cm)
 	err := c.ownerInfo.SetControllerReference(cm)
 	if err != nil {
 		return errors.Wrapf(err, "failed to set owner reference to format configmap %q", formatConfigMapName)
 	}
 
 	// Create format.sh configmap in cluster
-	_, err = c.context.Clientset.CoreV1().ConfigMaps(c.namespacedName.Namespace).Create(cm)
-	if err != nil && !kerrors.IsAlreadyExists(err) {
+	err = k8sutil.Apply(c.context.Client, cm)
+	if err != nil {
 		return errors.Wrapf(err, "failed to create override configmap %s", c.namespacedName.Namespace)
 	}
 
@@ -213,7 +373,7 @@ func (c *Cluster) runPrepareJob(nodeName string, device curvev1.DevicesSpec) (*b
 	}
 
 	// job is not found or job is not active status, so create or recreate it here
-	_, err = c.context.Clientset.BatchV1().Jobs(job.Namespace).Create(job)
+	err = k8sutil.Apply(c.context.Client, job)
 
 	return job, err
 }
@@ -221,12 +381,7 @@ func (c *Cluster) runPrepareJob(nodeName string, device curvev1.DevicesSpec) (*b
 func (c *Cluster) makeJob(nodeName string, device curvev1.DevicesSpec) (*batch.Job, error) {
 	volumes, volumeMounts := c.createFormatVolumeAndMount(device)
 
-	name := strings.TrimSpace(device.Name)
-	name = strings.TrimRight(name, "/")
-	nameArr := strings.Split(name, "/")
-	name = nameArr[len(nameArr)-1]
-
-	jobName := PrepareJobName + "-" + nodeName + "-" + name
+	jobName := prepareJobName(nodeName, device.Name)
 	podName := PrepareJobName + "-" + nodeName
 
 	runAsUser := int64(0)
@@ -241,17 +396,24 @@ func (c *Cluster) makeJob(nodeName string, device curvev1.DevicesSpec) (*batch.J
 			Containers: []v1.Container{
 				c.makeFormatContainer(device, volumeMounts),
 			},
-			NodeName:      nodeName,
-			RestartPolicy: v1.RestartPolicyOnFailure,
-			HostNetwork:   true,
-			DNSPolicy:     v1.DNSClusterFirstWithHostNet,
-			Volumes:       volumes,
+			NodeName:          nodeName,
+			RestartPolicy:     v1.RestartPolicyOnFailure,
+			HostNetwork:       true,
+			HostPID:           device.Encrypted,
+			DNSPolicy:         v1.DNSClusterFirstWithHostNet,
+			Volumes:           volumes,
+			PriorityClassName: c.spec.Storage.PrepareJob.PriorityClassName,
+			Tolerations:       c.spec.Storage.PrepareJob.Tolerations,
 			SecurityContext: &v1.PodSecurityContext{
 				RunAsUser:    &runAsUser,
 				RunAsNonRoot: &runAsNonRoot,
 			},
 		},
 	}
+	k8sutil.InjectEnv(&podSpec.Spec, c.spec, "chunkserver")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, c.spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, c.spec)
+	k8sutil.InjectDNS(&podSpec.Spec, c.spec, "chunkserver")
 
 	job := &batch.Job{
 		ObjectMeta: metav1.ObjectMeta{
@@ -260,11 +422,14 @@ func (c *Cluster) makeJob(nodeName string, device curvev1.DevicesSpec) (*batch.J
 			Labels:    c.getPodLabels(nodeName, device.Name),
 		},
 		Spec: batch.JobSpec{
-			Template: podSpec,
+			ActiveDeadlineSeconds: c.spec.Storage.PrepareJob.ActiveDeadlineSeconds,
+			BackoffLimit:          c.spec.Storage.PrepareJob.BackoffLimit,
+			Template:              podSpec,
 		},
 	}
 
 	// set ownerReference
+	k8sutil.InjectMetadata(c.spec, "chunkserver", job, &job.Spec.Template)
 	err := c.ownerInfo.SetControllerReference(job)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to set owner reference to job %q", job.Name)
@@ -280,25 +445,39 @@ func (c *Cluster) makeFormatContainer(device curvev1.DevicesSpec, volumeMounts [
 	readOnlyRootFilesystem := false
 
 	argsPercent := strconv.Itoa(device.Percentage)
-	argsFileSize := strconv.Itoa(DEFAULT_CHUNKFILE_SIZE)
+	_, _, chunkSize := c.devicePool(device.Name)
+	argsFileSize := strconv.FormatInt(chunkSize, 10)
 	argsFilePoolDir := ChunkserverContainerDataDir + "/chunkfilepool"
 	argsFilePoolMetaPath := ChunkserverContainerDataDir + "/chunkfilepool.meta"
+	argsLoopSize := ""
+	if device.IsLoop() {
+		argsLoopSize = strconv.FormatInt(device.Size.Value(), 10)
+	}
 
 	container := v1.Container{
 		Name: "format",
 		Args: []string{
-			device.Name,
+			deviceArg(device.Name, device.Type),
 			ChunkserverContainerDataDir,
 			argsPercent,
 			argsFileSize,
 			argsFilePoolDir,
 			argsFilePoolMetaPath,
+			string(device.Type),
+			device.GetFilesystem(),
+			strings.Join(device.MountOptions, ","),
+			string(c.spec.Storage.Engine),
+			strconv.FormatBool(device.Encrypted),
+			argsLoopSize,
+			device.CacheDevice,
+			device.GetCacheMode(),
 		},
 		Command: []string{
 			"/bin/bash",
 			formatScriptMountPath,
 		},
-		Image:           c.spec.CurveVersion.Image,
+		Env:             deviceKeyEnv(device.Encrypted, device.KeySecret),
+		Image:           c.spec.CurveVersion.JobImage(),
 		ImagePullPolicy: c.spec.CurveVersion.ImagePullPolicy,
 		VolumeMounts:    volumeMounts,
 		SecurityContext: &v1.SecurityContext{
@@ -315,15 +494,39 @@ func (c *Cluster) makeFormatContainer(device curvev1.DevicesSpec, volumeMounts [
 func (c *Cluster) getPodLabels(nodeName, deviceName string) map[string]string {
 	labels := make(map[string]string)
 	labels["app"] = PrepareJobName
-	labels["node"] = nodeName
-	s := strings.Split(deviceName, "/")
-	if len(s) > 1 {
-		deviceName = s[1]
-	} else {
-		// not occur
-		deviceName = nodeName
-	}
-	labels["device"] = deviceName
+	labels["node"] = k8sutil.LabelValue(nodeName)
+	labels["device"] = deviceBaseName(deviceName)
 	labels["curve_cluster"] = c.namespacedName.Namespace
 	return labels
 }
+
+// prepareJobName returns the name of the job that formats the device on the node
+func prepareJobName(nodeName, deviceName string) string {
+	return k8sutil.ResourceName(k8sutil.MaxJobNameLength, PrepareJobName, nodeName, deviceBaseName(deviceName))
+}
+
+// deviceBaseName returns the last element of device name, such as sdb of /dev/sdb. It's made a valid part of
+// the names of resources, such as pci-0000-00-1f.2-ata-1 of /dev/disk/by-path/pci-0000:00:1f.2-ata-1, and the
+// long one such as a link of /dev/disk/by-id is shortened with a hash of it.
+func deviceBaseName(deviceName string) string {
+	name := strings.TrimRight(strings.TrimSpace(deviceName), "/")
+	nameArr := strings.Split(name, "/")
+	name = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '.' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(nameArr[len(nameArr)-1]))
+	return k8sutil.ResourceName(maxDeviceBaseNameLength, name)
+}
+
+// DeploymentName returns the name of chunkserver deployment of the device on the node, it's the name of its
+// service too so it's in the limit of service names
+func DeploymentName(nodeName, deviceName string) string {
+	return k8sutil.ResourceName(validation.DNS1035LabelMaxLength, AppName, nodeName, deviceBaseName(deviceName))
+}
+
+// configMapName returns the name of the config configmap of the chunkserver of the device on the node
+func configMapName(nodeName, deviceName string) string {
+	return k8sutil.ResourceName(validation.DNS1123SubdomainMaxLength, ConfigMapNamePrefix, nodeName, deviceBaseName(deviceName))
+}
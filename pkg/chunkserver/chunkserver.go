@@ -11,6 +11,7 @@ import (
 	curvev1 "github.com/opencurve/curve-operator/api/v1"
 	"github.com/opencurve/curve-operator/pkg/clusterd"
 	"github.com/opencurve/curve-operator/pkg/k8sutil"
+	"github.com/opencurve/curve-operator/pkg/validation"
 )
 
 const (
@@ -21,6 +22,17 @@ const (
 	Prefix                      = "/curvebs/chunkserver"
 	ChunkserverContainerDataDir = "/curvebs/chunkserver/data"
 	ChunkserverContainerLogDir  = "/curvebs/chunkserver/logs"
+	// ChunkserverContainerLoopDir keeps the backing files of loop devices
+	ChunkserverContainerLoopDir = "/curvebs/chunkserver/loop"
+
+	// uncleanShutdownMarker is left in log dir if chunkserver is not shut down cleanly,
+	// it's a hidden file that is not removed by log rotation
+	uncleanShutdownMarker = ChunkserverContainerLogDir + "/.chunkserver.running"
+	// pidFile is written by the start script for the preStop hook to stop chunkserver
+	pidFile = "/tmp/curvebs-chunkserver.pid"
+	// defaultTerminationGracePeriodSeconds is the termination grace period if storage.terminationGracePeriodSeconds
+	// is not set, chunkserver flushes its copysets before it quits
+	defaultTerminationGracePeriodSeconds = 120
 
 	// start.sh
 	startChunkserverConfigMapName     = "start-chunkserver-conf"
@@ -36,10 +48,27 @@ type Cluster struct {
 	logDirHostPath  string
 	confDirHostPath string
 	ownerInfo       *k8sutil.OwnerInfo
+
+	// job2DeviceInfos are the started format jobs, queuedFormats are the devices waiting for a slot of
+	// storage.maxConcurrentFormats, chunkserverConfigs are the chunkservers to start and nodeDevices are the
+	// devices of each storage node keyed by node name, they are generated by startProvisioningOverNodes
+	job2DeviceInfos    []*Job2DeviceInfo
+	queuedFormats      []*Job2DeviceInfo
+	chunkserverConfigs []chunkserverConfig
+	nodeDevices        map[string][]curvev1.DevicesSpec
+	// bindings are the sequences and ports of the chunkservers keyed by node and device name
+	bindings map[string]chunkserverBinding
+	// nodeErrors are the failures of the devices that are skipped without stopping the other nodes
+	nodeErrors []error
+	// copysetPlans are how the copysets of the logical pools are computed by the generated topology
+	copysetPlans []curvev1.CopysetPlanStatus
 }
 
 var logger = capnslog.NewPackageLogger("github.com/opencurve/curve-operator", "chunkserver")
 
+// formatCheckInterval is the interval to check the progress of the format jobs
+var formatCheckInterval = 20 * time.Second
+
 func New(context clusterd.Context,
 	namespacedName types.NamespacedName,
 	spec curvev1.CurveClusterSpec,
@@ -58,16 +87,54 @@ func New(context clusterd.Context,
 	}
 }
 
+// validateSpec checks the storage spec before provisioning, the rules of validation.ValidateStorage are checked
+// first since they are the same as the webhook
+func (c *Cluster) validateSpec() error {
+	if err := validation.ValidateStorage(&c.spec); err != nil {
+		return err
+	}
+	if len(c.spec.StorageNodes()) == 0 {
+		return errors.New("no storage node matches storage.nodeSelector")
+	}
+
+	// the names of the resources of a chunkserver are made of the names of its node and device, which must not be
+	// the same for different devices such as by-path links that differ only in the characters invalid in names
+	chunkservers := map[string]string{}
+	for _, nodeName := range c.spec.StorageNodes() {
+		devices := c.spec.Storage.NodeDevices(nodeName)
+		for _, device := range devices {
+			name := DeploymentName(nodeName, device.Name)
+			if other, ok := chunkservers[name]; ok {
+				return errors.Errorf("devices %s and %s:%s have the same chunkserver name %q, use other links of the devices",
+					other, nodeName, device.Name, name)
+			}
+			chunkservers[name] = nodeName + ":" + device.Name
+		}
+		if err := c.checkNodeHugePages(nodeName, len(devices)); err != nil {
+			return err
+		}
+	}
+	if err := c.validatePools(); err != nil {
+		return err
+	}
+	if _, err := c.extraArgs(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// NodeErrors returns the failures of the devices that were skipped by Start, the chunkservers on the other
+// devices are started regardless
+func (c *Cluster) NodeErrors() error {
+	return k8sutil.Aggregate(c.nodeErrors)
+}
+
 // Start begins the chunkserver daemon
 func (c *Cluster) Start(nodeNameIP map[string]string) error {
 	logger.Infof("start running chunkserver in namespace %q", c.namespacedName.Namespace)
 
-	if !c.spec.Storage.UseSelectedNodes && (len(c.spec.Storage.Nodes) == 0 || len(c.spec.Storage.Devices) == 0) {
-		return errors.New("useSelectedNodes is set to false but no node specified")
-	}
-
-	if c.spec.Storage.UseSelectedNodes && len(c.spec.Storage.SelectedNodes) == 0 {
-		return errors.New("useSelectedNodes is set to false but selectedNodes not be specified")
+	if err := c.validateSpec(); err != nil {
+		return k8sutil.NewConfigError(err)
 	}
 
 	logger.Info("starting to prepare the chunk file")
@@ -77,49 +144,86 @@ func (c *Cluster) Start(nodeNameIP map[string]string) error {
 	if err != nil {
 		return errors.Wrap(err, "failed to provision chunkfilepool")
 	}
+	timer := c.startProvisioningTimer()
+	if err := c.updateDeviceProvisioning(); err != nil {
+		logger.Warningf("failed to update provisioning of devices in status. %v", err)
+	}
 
-	// 2. wait all job finish to complete format and wait MDS election success.
-	k8sutil.UpdateCondition(context.TODO(), &c.context, c.namespacedName, curvev1.ConditionTypeFormatedReady, curvev1.ConditionTrue, curvev1.ConditionFormatingChunkfilePoolReason, "Formating chunkfilepool")
-	oneMinuteTicker := time.NewTicker(20 * time.Second)
-	defer oneMinuteTicker.Stop()
+	// 2. wait all job finish to complete format
+	k8sutil.SetProgressing(context.TODO(), &c.context, c.namespacedName, curvev1.ConditionTypeFormatedReady, curvev1.ConditionFormatingChunkfilePoolReason, "Formatting chunkfilepool")
+	formatTicker := time.NewTicker(formatCheckInterval)
+	defer formatTicker.Stop()
 
-	chn := make(chan bool, 1)
+	chn := make(chan error, 1)
 	ctx, canf := context.WithTimeout(context.Background(), time.Duration(24*60*60*time.Second))
 	defer canf()
-	go c.checkJobStatus(ctx, oneMinuteTicker, chn)
+	go c.checkJobStatus(ctx, formatTicker, chn)
 
-	// block here unitl timeout(24 hours) or all jobs has been successed.
-	flag := <-chn
-	if !flag {
+	// block here unitl timeout(24 hours), a job failed or all jobs has been successed.
+	if err := <-chn; err != nil {
 		// TODO: delete all jobs that has created.
-		return errors.New("Format job is not completed in 24 hours and exit with -1")
+		return err
+	}
+	k8sutil.SetReady(context.TODO(), &c.context, c.namespacedName, curvev1.ConditionTypeFormatedReady, curvev1.ConditionFormatChunkfilePoolReason, "Formating chunkfilepool successed")
+	timer.phaseDone(phaseFormat, &timer.status.FormatCompletedAt)
+
+	err = c.updateInventory()
+	if err != nil {
+		return errors.Wrap(err, "failed to record formatted devices")
+	}
+	if err := c.updateNodeCapacity(); err != nil {
+		logger.Warningf("failed to update capacity of storage nodes in status. %v", err)
 	}
-	k8sutil.UpdateCondition(context.TODO(), &c.context, c.namespacedName, curvev1.ConditionTypeFormatedReady, curvev1.ConditionTrue, curvev1.ConditionFormatChunkfilePoolReason, "Formating chunkfilepool successed")
 
 	logger.Info("all jobs run completed in 24 hours")
 
+	// 2. wait MDS election success, the pools and the chunkservers register to the leader of mds
+	if err := c.waitMdsLeader(); err != nil {
+		return err
+	}
+
 	// 2. create physical pool
-	_, err = c.runCreatePoolJob(nodeNameIP, "physical_pool")
+	err = c.createPool(nodeNameIP, "physical_pool", curvev1.ConditionTypePhysicalPoolReady)
 	if err != nil {
-		return errors.Wrap(err, "failed to create physical pool")
+		return err
 	}
 	logger.Info("create physical pool successed")
+	timer.phaseDone(phasePhysicalPool, nil)
 
 	// 3. startChunkServers start all chunkservers for each device of every node
 	// 4. wait all chunkservers online before create logical pool
+	k8sutil.SetPhase(context.TODO(), &c.context, c.namespacedName, curvev1.ClusterPhaseDeployingChunkservers, "Deploying chunkservers")
 	err = c.startChunkServers()
 	if err != nil {
 		return errors.Wrap(err, "failed to start chunkserver")
 	}
+	timer.phaseDone(phaseChunkservers, &timer.status.ChunkServersReadyAt)
 
 	// 5. create logical pool
-	_, err = c.runCreatePoolJob(nodeNameIP, "logical_pool")
+	// the mock chunkservers of dev mode never register to mds, so there is no chunkserver for the logical pool
+	if c.spec.DevMode {
+		logger.Info("skip creating logical pool in dev mode")
+		k8sutil.SetReady(context.TODO(), &c.context, c.namespacedName, curvev1.ConditionTypeChunkServerReady, curvev1.ConditionChunkServerClusterCreatedReason, "Chunkserver cluster has been created")
+		return nil
+	}
+	// the logical pool is created after the chunkservers of all the zones registered to mds
+	if err := c.waitChunkServersRegistered(); err != nil {
+		return err
+	}
+	err = c.createPool(nodeNameIP, "logical_pool", curvev1.ConditionTypeLogicalPoolReady)
 	if err != nil {
-		return errors.Wrap(err, "failed to create physical pool")
+		return err
 	}
 	logger.Info("create logical pool successed")
+	// the copysets of the pools created by the admin are unknown
+	if c.spec.Topology.IsManaged() {
+		if err := c.updateCopysetPlan(); err != nil {
+			logger.Warningf("failed to update copyset plan in status. %v", err)
+		}
+	}
+	timer.phaseDone(phaseLogicalPool, &timer.status.PoolCreatedAt)
 
-	k8sutil.UpdateCondition(context.TODO(), &c.context, c.namespacedName, curvev1.ConditionTypeChunkServerReady, curvev1.ConditionTrue, curvev1.ConditionChunkServerClusterCreatedReason, "Chunkserver cluster has been created")
+	k8sutil.SetReady(context.TODO(), &c.context, c.namespacedName, curvev1.ConditionTypeChunkServerReady, curvev1.ConditionChunkServerClusterCreatedReason, "Chunkserver cluster has been created")
 
 	return nil
 }
@@ -1,6 +1,12 @@
 package chunkserver
 
-import "strconv"
+import (
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
 
 // chunkserverConfig for a single chunkserver
 // chunkserverConfig implements config.ConfigInterface
@@ -25,6 +31,27 @@ type chunkserverConfig struct {
 	// device name represents the device name of the chunkserver, each device has one chunkserver.
 	DeviceName string
 
+	// stable name is the stable link of the kernel name of the block device resolved on the node, such as
+	// '/dev/disk/by-id/wwn-0x5000c500a1b2c3d4'. It's used instead of the device name in the pod if it's set.
+	StableName string
+
+	// device type represents whether the chunkserver is backed by a block device or a host directory.
+	DeviceType curvev1.DeviceType
+
+	// filesystem and mount options used to mount the block device in chunkserver pod
+	Filesystem   string
+	MountOptions string
+
+	// encrypted device is opened by the passphrase in the key secret in chunkserver pod
+	Encrypted bool
+	KeySecret *v1.SecretKeySelector
+
+	// cache device that caches the block device by bcache, empty if it's not cached
+	CacheDevice string
+
+	// cpu set that chunkserver is bound to, empty if it's not bound
+	CPUSet string
+
 	// node name represents the name of the node that the chunkserver is running on.
 	NodeName string
 
@@ -39,13 +66,25 @@ type chunkserverConfig struct {
 
 	// replicas represents the chunkserver replicas on the node.
 	Replicas int
+
+	// the logical pool and the physical pool of the chunkserver by storage.pools, and the size of the chunks
+	// formatted in its chunk file pool
+	LogicalPool  string
+	PhysicalPool string
+	ChunkSize    int64
 }
 
 // chunkserverDataPathMap represents the device on host and referred Mount Path in container
 type chunkserverDataPathMap struct {
-	// HostDevice is the device name such as '/dev/sdb'
+	// HostDevice is the device name such as '/dev/sdb', or its stable link if it's resolved
 	HostDevice string
 
+	// HostDataDir is the directory on host that backs the chunkserver, only set for path device
+	HostDataDir string
+
+	// HostLoopDir is the directory on host that keeps the backing file, only set for loop device
+	HostLoopDir string
+
 	// HostLogDir
 	HostLogDir string
 
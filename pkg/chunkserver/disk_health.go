@@ -0,0 +1,186 @@
+package chunkserver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	batch "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver/script"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+const (
+	// DiskHealthAppName is the app label of the disk health check jobs
+	DiskHealthAppName = "curve-disk-health"
+	// DiskHealthNodeLabel is the label of the node that the disk health check job runs on
+	DiskHealthNodeLabel = "node"
+
+	diskHealthCronJobNameFormat = "curve-disk-health-%s"
+	defaultDiskHealthSchedule   = "0 * * * *"
+)
+
+// DiskHealthResult is the result of SMART check of a device
+type DiskHealthResult struct {
+	Device  string
+	Health  curvev1.DiskHealth
+	Message string
+}
+
+// ParseDiskHealthResults parses the termination message of the disk health check job
+func ParseDiskHealthResults(message string) []DiskHealthResult {
+	var results []DiskHealthResult
+	for _, line := range strings.Split(message, "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), " ", 3)
+		if len(fields) < 2 {
+			continue
+		}
+		result := DiskHealthResult{Device: fields[0], Health: curvev1.DiskHealth(fields[1])}
+		if len(fields) == 3 {
+			result.Message = fields[2]
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// ReconcileDiskHealthCheckers creates a cron job on each storage node to check the SMART health of its
+// block devices, and deletes the cron jobs that are not needed any more.
+func (c *Cluster) ReconcileDiskHealthCheckers() error {
+	var devices []string
+	for _, device := range c.spec.Storage.Devices {
+		// a path device is a directory on the host and a loop device is a file that have no SMART, and a spdk
+		// device is bound to vfio-pci
+		if !device.IsPath() && !device.IsLoop() && !c.spec.Storage.IsSPDK() {
+			devices = append(devices, device.Name)
+		}
+	}
+
+	wanted := map[string]bool{}
+	if c.spec.Storage.DiskHealth.Enable && len(devices) > 0 {
+		resolved, err := k8sutil.ResolveNodeNames(c.context.Clientset, c.spec.Storage.Nodes)
+		if err != nil {
+			return errors.Wrap(err, "failed to resolve storage nodes")
+		}
+		for _, nodeName := range resolved {
+			cronJob, err := c.makeDiskHealthCronJob(nodeName, devices)
+			if err != nil {
+				return err
+			}
+			if err := k8sutil.Apply(c.context.Client, cronJob); err != nil {
+				return errors.Wrapf(err, "failed to apply disk health cron job %s", cronJob.Name)
+			}
+			wanted[cronJob.Name] = true
+		}
+	}
+
+	cronJobs, err := c.context.Clientset.BatchV1beta1().CronJobs(c.namespacedName.Namespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=%s", DiskHealthAppName),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to list disk health cron jobs")
+	}
+	propagation := metav1.DeletePropagationBackground
+	for _, cronJob := range cronJobs.Items {
+		if wanted[cronJob.Name] {
+			continue
+		}
+		err := c.context.Clientset.BatchV1beta1().CronJobs(cronJob.Namespace).Delete(cronJob.Name, &metav1.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil && !kerrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete disk health cron job %s", cronJob.Name)
+		}
+		logger.Infof("deleted disk health cron job %s", cronJob.Name)
+	}
+
+	return nil
+}
+
+func (c *Cluster) makeDiskHealthCronJob(nodeName string, devices []string) (*batchv1beta1.CronJob, error) {
+	name := k8sutil.TruncateNodeNameForJob(diskHealthCronJobNameFormat, nodeName)
+	labels := map[string]string{
+		"app":               DiskHealthAppName,
+		DiskHealthNodeLabel: k8sutil.LabelValue(nodeName),
+		"curve_cluster":     c.namespacedName.Namespace,
+	}
+
+	schedule := c.spec.Storage.DiskHealth.Schedule
+	if schedule == "" {
+		schedule = defaultDiskHealthSchedule
+	}
+	image := c.spec.Storage.DiskHealth.Image
+	if image == "" {
+		image = c.spec.CurveVersion.Image
+	}
+
+	privileged := true
+	backoffLimit := int32(0)
+	historyLimit := int32(1)
+
+	podSpec := v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: labels,
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:            "smart",
+					Command:         []string{"/bin/bash"},
+					Args:            append([]string{"-c", script.SMART, "smart"}, devices...),
+					Image:           image,
+					ImagePullPolicy: c.spec.CurveVersion.ImagePullPolicy,
+					VolumeMounts:    []v1.VolumeMount{{Name: "devices", MountPath: "/dev"}},
+					SecurityContext: &v1.SecurityContext{
+						Privileged: &privileged,
+					},
+				},
+			},
+			NodeName:      nodeName,
+			RestartPolicy: v1.RestartPolicyNever,
+			Volumes: []v1.Volume{
+				{Name: "devices", VolumeSource: v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: config.GetOperatorSettings().HostDevDir}}},
+			},
+		},
+	}
+	k8sutil.InjectEnv(&podSpec.Spec, c.spec, "chunkserver")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, c.spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, c.spec)
+	k8sutil.InjectDNS(&podSpec.Spec, c.spec, "chunkserver")
+
+	cronJob := &batchv1beta1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: c.namespacedName.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1beta1.CronJobSpec{
+			Schedule:                   schedule,
+			ConcurrencyPolicy:          batchv1beta1.ForbidConcurrent,
+			SuccessfulJobsHistoryLimit: &historyLimit,
+			FailedJobsHistoryLimit:     &historyLimit,
+			JobTemplate: batchv1beta1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: batch.JobSpec{
+					BackoffLimit: &backoffLimit,
+					Template:     podSpec,
+				},
+			},
+		},
+	}
+
+	k8sutil.InjectMetadata(c.spec, "chunkserver", cronJob, &cronJob.Spec.JobTemplate, &cronJob.Spec.JobTemplate.Spec.Template)
+	err := c.ownerInfo.SetControllerReference(cronJob)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to set owner reference to disk health cron job %q", cronJob.Name)
+	}
+
+	return cronJob, nil
+}
@@ -0,0 +1,66 @@
+package chunkserver
+
+import (
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// makeDisruptionBudget returns the PodDisruptionBudget of the chunkserver deployment that allows no eviction of its
+// pod, it's deleted with the deployment
+func makeDisruptionBudget(d *appsv1.Deployment) *policyv1beta1.PodDisruptionBudget {
+	maxUnavailable := intstr.FromInt(0)
+	return &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            d.Name,
+			Namespace:       d.Namespace,
+			Labels:          d.Spec.Selector.MatchLabels,
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(d, appsv1.SchemeGroupVersion.WithKind("Deployment"))},
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			Selector:       d.Spec.Selector,
+			MaxUnavailable: &maxUnavailable,
+		},
+	}
+}
+
+// ProtectFromDrain creates the budgets of the chunkserver deployments so that their pods can't be evicted
+func ProtectFromDrain(clientset kubernetes.Interface, deployments []appsv1.Deployment) error {
+	for i := range deployments {
+		budget := makeDisruptionBudget(&deployments[i])
+		_, err := clientset.PolicyV1beta1().PodDisruptionBudgets(budget.Namespace).Create(budget)
+		if err != nil && !kerrors.IsAlreadyExists(err) {
+			return errors.Wrapf(err, "failed to create disruption budget of chunkserver %q", budget.Name)
+		}
+	}
+	return nil
+}
+
+// DrainProtected returns true if the pod of any chunkserver deployment can't be evicted by its budget
+func DrainProtected(clientset kubernetes.Interface, deployments []appsv1.Deployment) (bool, error) {
+	for _, d := range deployments {
+		_, err := clientset.PolicyV1beta1().PodDisruptionBudgets(d.Namespace).Get(d.Name, metav1.GetOptions{})
+		if err == nil {
+			return true, nil
+		}
+		if !kerrors.IsNotFound(err) {
+			return false, errors.Wrapf(err, "failed to get disruption budget of chunkserver %q", d.Name)
+		}
+	}
+	return false, nil
+}
+
+// ReleaseDrainProtection deletes the budgets of the chunkserver deployments so that their pods can be evicted
+func ReleaseDrainProtection(clientset kubernetes.Interface, deployments []appsv1.Deployment) error {
+	for _, d := range deployments {
+		err := clientset.PolicyV1beta1().PodDisruptionBudgets(d.Namespace).Delete(d.Name, &metav1.DeleteOptions{})
+		if err != nil && !kerrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete disruption budget of chunkserver %q", d.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+//go:build e2e
+// +build e2e
+
+package chunkserver
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver/script"
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// mockFormat replaces format.sh in the e2e tests, it fills the chunk file pool of the path device with a few
+// small files instead of formatting it by the curve tools
+const mockFormat = `
+data_dir=$2
+file_size=4096
+pool_dir=$5
+meta_path=$6
+
+mkdir -p "$pool_dir"
+for i in 1 2 3; do
+  head -c "$file_size" /dev/zero > "$pool_dir/$i"
+done
+echo "chunkfilepool of $1 is formatted by the mock script" > "$meta_path"
+ls "$data_dir"
+`
+
+// TestE2EFormatOnKind runs the prepare jobs of the path devices on the worker nodes of the cluster in KUBECONFIG,
+// which is created by hack/e2e-kind.sh. The image is E2E_IMAGE that has bash, and the devices are the directories
+// in E2E_DEVICES created on each worker node.
+func TestE2EFormatOnKind(t *testing.T) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = curvev1.AddToScheme(scheme)
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := clusterd.Context{KubeConfig: cfg, Clientset: clientset, Client: c}
+
+	nodes, err := clientset.CoreV1().Nodes().List(metav1.ListOptions{LabelSelector: "!node-role.kubernetes.io/master"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := testSpec()
+	spec.CurveVersion.Image = envOrDefault("E2E_IMAGE", "bash:5")
+	spec.Storage.Nodes = nil
+	for _, node := range nodes.Items {
+		spec.Storage.Nodes = append(spec.Storage.Nodes, node.Name)
+	}
+	spec.Nodes = spec.Storage.Nodes
+	spec.Storage.Devices = nil
+	for _, dir := range strings.Split(envOrDefault("E2E_DEVICES", "/curve-e2e/chunkserver0"), ",") {
+		spec.Storage.Devices = append(spec.Storage.Devices, curvev1.DevicesSpec{Name: dir, Type: curvev1.DeviceTypePath, Percentage: 80})
+	}
+
+	namespace := envOrDefault("E2E_NAMESPACE", "curve-e2e")
+	if _, err := clientset.CoreV1().Namespaces().Create(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}); err != nil && !kerrors.IsAlreadyExists(err) {
+		t.Fatal(err)
+	}
+	cluster := &curvev1.CurveCluster{ObjectMeta: metav1.ObjectMeta{Name: "curve-e2e", Namespace: namespace}, Spec: spec}
+	if err := c.Create(context.TODO(), cluster); err != nil && !kerrors.IsAlreadyExists(err) {
+		t.Fatal(err)
+	}
+	namespacedName := types.NamespacedName{Namespace: namespace, Name: cluster.Name}
+	if err := c.Get(context.TODO(), namespacedName, cluster); err != nil {
+		t.Fatal(err)
+	}
+
+	format := script.FORMAT
+	script.FORMAT = mockFormat
+	defer func() { script.FORMAT = format }()
+
+	nodeNameIP, err := k8sutil.GetNodeInfoMap(spec, clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs := New(ctx, namespacedName, *spec, k8sutil.NewOwnerInfo(cluster, scheme), "/curve-e2e/data", "/curve-e2e/log", "/curve-e2e/conf")
+	if err := cs.startProvisioningOverNodes(nodeNameIP); err != nil {
+		t.Fatalf("failed to start the prepare jobs: %v", err)
+	}
+	if len(cs.job2DeviceInfos) != len(spec.Storage.Nodes)*len(spec.Storage.Devices) {
+		t.Fatalf("expected a prepare job for each device, got %d", len(cs.job2DeviceInfos))
+	}
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	chn := make(chan error, 1)
+	timeout, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	go cs.checkJobStatus(timeout, ticker, chn)
+	if err := <-chn; err != nil {
+		t.Fatalf("the prepare jobs are not completed in 5 minutes: %v", err)
+	}
+
+	if err := cs.updateInventory(); err != nil {
+		t.Fatal(err)
+	}
+	records, err := cs.loadInventory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(cs.job2DeviceInfos) {
+		t.Errorf("expected the formatted devices recorded in the inventory, got %v", records)
+	}
+}
+
+func envOrDefault(name, value string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return value
+}
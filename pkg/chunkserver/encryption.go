@@ -0,0 +1,16 @@
+package chunkserver
+
+import v1 "k8s.io/api/core/v1"
+
+// deviceKeyEnvName is the environment variable of the passphrase of the encrypted device used by the scripts
+const deviceKeyEnvName = "CURVE_DEVICE_KEY"
+
+// deviceKeyEnv returns the environment variable of the passphrase of the device if it's encrypted
+func deviceKeyEnv(encrypted bool, keySecret *v1.SecretKeySelector) []v1.EnvVar {
+	if !encrypted || keySecret == nil {
+		return nil
+	}
+	return []v1.EnvVar{
+		{Name: deviceKeyEnvName, ValueFrom: &v1.EnvVarSource{SecretKeyRef: keySecret}},
+	}
+}
@@ -0,0 +1,99 @@
+package chunkserver
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/pkg/errors"
+	batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/opencurve/curve-operator/pkg/chunkserver/script"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+const (
+	offlineJobNameFormat = "curve-chunkserver-offline-%s"
+)
+
+// RunOfflineJob creates a job to set the chunkservers on the failed node offline in topology,
+// then the copysets on them will be recovered on other chunkservers.
+func (c *Cluster) RunOfflineJob(nodeName, nodeIP string, ports []int) (*batch.Job, error) {
+	job, err := c.makeOfflineJob(nodeName, nodeIP, ports)
+	if err != nil {
+		return nil, err
+	}
+
+	// the job of last failure of the node is replaced
+	err = k8sutil.RunReplaceableJob(context.TODO(), c.context.Clientset, job, false)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to run job %s", job.Name)
+	}
+	logger.Infof("created job %s to set chunkservers on node %s offline", job.Name, nodeName)
+
+	return job, nil
+}
+
+func (c *Cluster) makeOfflineJob(nodeName, nodeIP string, ports []int) (*batch.Job, error) {
+	// tools.conf volume and volumemount
+	volumes, mounts := c.createTopoAndToolVolumeAndMount()
+
+	jobName := k8sutil.TruncateNodeNameForJob(offlineJobNameFormat, nodeName)
+	labels := map[string]string{
+		"app":           AppName,
+		"offline":       nodeName,
+		"curve_cluster": c.namespacedName.Namespace,
+	}
+
+	args := []string{"-c", script.OFFLINE, "offline", nodeIP}
+	for _, port := range ports {
+		args = append(args, strconv.Itoa(port))
+	}
+
+	podSpec := v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   jobName,
+			Labels: labels,
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:            "offline",
+					Command:         []string{"/bin/bash"},
+					Args:            args,
+					Image:           c.spec.CurveVersion.Image,
+					ImagePullPolicy: c.spec.CurveVersion.ImagePullPolicy,
+					VolumeMounts:    mounts,
+				},
+			},
+			RestartPolicy: v1.RestartPolicyOnFailure,
+			HostNetwork:   true,
+			DNSPolicy:     v1.DNSClusterFirstWithHostNet,
+			Volumes:       volumes,
+		},
+	}
+	k8sutil.InjectEnv(&podSpec.Spec, c.spec, "chunkserver")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, c.spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, c.spec)
+	k8sutil.InjectDNS(&podSpec.Spec, c.spec, "chunkserver")
+
+	job := &batch.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jobName,
+			Namespace: c.namespacedName.Namespace,
+			Labels:    labels,
+		},
+		Spec: batch.JobSpec{
+			Template: podSpec,
+		},
+	}
+
+	k8sutil.InjectMetadata(c.spec, "chunkserver", job, &job.Spec.Template)
+	err := c.ownerInfo.SetControllerReference(job)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to set owner reference to offline job %q", job.Name)
+	}
+
+	return job, nil
+}
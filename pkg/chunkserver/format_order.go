@@ -0,0 +1,88 @@
+package chunkserver
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+// FormatPriorityAnnotation is the priority of formatting the devices of the node, the nodes of a higher priority
+// are formatted first. The nodes in storage.formatOrder are formatted before the annotated ones.
+const FormatPriorityAnnotation = "operator.curve.io/format-priority"
+
+// formatTarget is a device to be formatted on a node
+type formatTarget struct {
+	nodeName string
+	device   curvev1.DevicesSpec
+}
+
+// orderFormats returns the devices of the nodes that have not been formatted, ordered by storage.formatOrder,
+// then the format priority annotation of the nodes, then the order of the nodes and devices in spec
+func (c *Cluster) orderFormats(nodes []v1.Node, resolved map[string]string, formatted map[string]DeviceRecord) []formatTarget {
+	// the entries of formatOrder are node names or '<node>:<device>', the node names are resolved like the
+	// storage nodes
+	ranks := map[string]int{}
+	for i, entry := range c.spec.Storage.FormatOrder {
+		nodeName, deviceName := entry, ""
+		if sep := strings.Index(entry, ":"); sep >= 0 {
+			nodeName, deviceName = entry[:sep], entry[sep+1:]
+		}
+		if name, ok := resolved[nodeName]; ok {
+			nodeName = name
+		}
+		key := inventoryKey(nodeName, deviceName)
+		if _, ok := ranks[key]; !ok {
+			ranks[key] = i
+		}
+	}
+	rank := func(nodeName, deviceName string) int {
+		r := len(c.spec.Storage.FormatOrder)
+		if i, ok := ranks[inventoryKey(nodeName, deviceName)]; ok && i < r {
+			r = i
+		}
+		if i, ok := ranks[inventoryKey(nodeName, "")]; ok && i < r {
+			r = i
+		}
+		return r
+	}
+
+	var targets []formatTarget
+	var ranksOf, priorities []int
+	for _, node := range nodes {
+		priority, err := strconv.Atoi(node.Annotations[FormatPriorityAnnotation])
+		if err != nil && node.Annotations[FormatPriorityAnnotation] != "" {
+			logger.Warningf("invalid annotation %s %q of node %s, it's ignored", FormatPriorityAnnotation,
+				node.Annotations[FormatPriorityAnnotation], node.Name)
+		}
+		for _, device := range c.nodeDevices[node.Name] {
+			if _, ok := formatted[inventoryKey(node.Name, device.Name)]; ok {
+				continue
+			}
+			targets = append(targets, formatTarget{nodeName: node.Name, device: device})
+			ranksOf = append(ranksOf, rank(node.Name, device.Name))
+			priorities = append(priorities, priority)
+		}
+	}
+
+	indexes := make([]int, len(targets))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		a, b := indexes[i], indexes[j]
+		if ranksOf[a] != ranksOf[b] {
+			return ranksOf[a] < ranksOf[b]
+		}
+		return priorities[a] > priorities[b]
+	})
+
+	ordered := make([]formatTarget, 0, len(targets))
+	for _, i := range indexes {
+		ordered = append(ordered, targets[i])
+	}
+	return ordered
+}
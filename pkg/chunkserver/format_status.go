@@ -12,6 +12,7 @@ import (
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/remotecommand"
 )
@@ -24,32 +25,41 @@ type device2Use struct {
 	usePercent    int
 }
 
-// checkJobStatus go routine to check all job's status
-func (c *Cluster) checkJobStatus(ctx context.Context, ticker *time.Ticker, chn chan bool) {
+// checkJobStatus go routine to check all job's status, nil is sent to chn when all jobs succeeded, or the error
+// of the failed jobs
+func (c *Cluster) checkJobStatus(ctx context.Context, ticker *time.Ticker, chn chan error) {
 	retry := 0
 	for {
 		select {
 		case <-ticker.C:
 			du, err := c.getJob2DeviceFormatProgress(chn)
 			if err != nil {
-				chn <- false
+				chn <- err
 				return
 			}
 			c.printProgress(retry, du)
 			retry++
 		case <-ctx.Done():
-			chn <- false
+			chn <- errors.New("format jobs are not completed in 24 hours")
 			logger.Error("go routinue exit because check time is more than 24 hours")
 			return
 		}
 	}
 }
 
-// getJobFormatStatus gets one device(one job) usage that represents format progress
-func (c *Cluster) getJob2DeviceFormatProgress(chn chan bool) ([]device2Use, error) {
+// getJobFormatStatus gets one device(one job) usage that represents format progress, an error is returned if a
+// job failed for good as it's never completed
+func (c *Cluster) getJob2DeviceFormatProgress(chn chan error) ([]device2Use, error) {
 	device2UseArr := []device2Use{}
 	completed := 0
-	for _, watchedJob2DeviceInfo := range job2DeviceInfos {
+	var failures []string
+	// all devices have been formatted before
+	if len(c.job2DeviceInfos) == 0 && len(c.queuedFormats) == 0 {
+		logger.Info("no format job is running.")
+		chn <- nil
+		return device2UseArr, nil
+	}
+	for _, watchedJob2DeviceInfo := range c.job2DeviceInfos {
 		watchedJob := watchedJob2DeviceInfo.job
 		watchedNodeName := watchedJob2DeviceInfo.nodeName
 		wathedDevice := watchedJob2DeviceInfo.device
@@ -57,25 +67,29 @@ func (c *Cluster) getJob2DeviceFormatProgress(chn chan bool) ([]device2Use, erro
 		if err != nil {
 			return []device2Use{}, errors.Wrapf(err, "failed to get job %q in cluster", watchedJob.Name)
 		}
+		// the status is used to count the running jobs
+		watchedJob2DeviceInfo.job = job
 
 		if job.Status.Succeeded > 0 {
 			completed++
-			if completed == len(job2DeviceInfos) {
-				logger.Info("all format jobs has finished.")
-				chn <- true
-				return device2UseArr, nil
-			}
+			continue
+		}
+		if condition := jobFailedCondition(job); condition != nil {
+			failures = append(failures, fmt.Sprintf("job %s of device %s on %s failed: %s",
+				job.Name, wathedDevice.Name, watchedNodeName, condition.Message))
 			continue
 		}
 
-		labels := c.getPodLabels(watchedNodeName, wathedDevice.Name)
-		var labelSelector []string
-		for k, v := range labels {
-			labelSelector = append(labelSelector, k+"="+v)
+		// the pods are selected by the selector that k8s generates for the job, which is unique to it
+		selector := labels.SelectorFromSet(job.Spec.Template.Labels)
+		if job.Spec.Selector != nil {
+			selector, err = metav1.LabelSelectorAsSelector(job.Spec.Selector)
+			if err != nil {
+				return []device2Use{}, errors.Wrapf(err, "invalid selector of job %q", job.Name)
+			}
 		}
-		selector := strings.Join(labelSelector, ",")
 		podList, _ := c.context.Clientset.CoreV1().Pods(watchedJob.Namespace).List(metav1.ListOptions{
-			LabelSelector: selector,
+			LabelSelector: selector.String(),
 		})
 		if len(podList.Items) < 1 {
 			// not occur
@@ -83,15 +97,42 @@ func (c *Cluster) getJob2DeviceFormatProgress(chn chan bool) ([]device2Use, erro
 			continue
 		}
 
+		// the progress of spdk devices is unknown as there is no filesystem, and nothing is mounted in dev mode
+		if c.spec.Storage.IsSPDK() || c.spec.DevMode {
+			continue
+		}
+
 		// one job one pod one container
 		pod := podList.Items[0]
-		du, err := c.getDevUsedbyExecRequest(&pod, watchedNodeName, wathedDevice.Name, wathedDevice.Percentage, "Formatting")
+		// the directory of path device is mounted at container data dir of format pod
+		dfTarget := wathedDevice.Name
+		if wathedDevice.IsPath() || wathedDevice.IsLoop() {
+			dfTarget = ChunkserverContainerDataDir
+		}
+		du, err := c.getDevUsedbyExecRequest(&pod, watchedNodeName, dfTarget, wathedDevice.Percentage, "Formatting")
 		if err != nil {
 			return []device2Use{}, errors.Wrap(err, "failed to get disk used percentage using exec request")
 		}
 		device2UseArr = append(device2UseArr, du)
 	}
 
+	if err := c.updateDeviceProvisioning(); err != nil {
+		logger.Warningf("failed to update provisioning of devices in status. %v", err)
+	}
+
+	if len(failures) > 0 {
+		return []device2Use{}, errors.Errorf("failed to format devices, %s", strings.Join(failures, "; "))
+	}
+	if completed == len(c.job2DeviceInfos) && len(c.queuedFormats) == 0 {
+		logger.Info("all format jobs has finished.")
+		chn <- nil
+		return device2UseArr, nil
+	}
+	// the queued jobs take the slots of the finished ones
+	if err := c.startQueuedFormats(); err != nil {
+		return []device2Use{}, err
+	}
+
 	return device2UseArr, nil
 }
 
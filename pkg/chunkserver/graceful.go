@@ -0,0 +1,183 @@
+package chunkserver
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/opencurve/curve-operator/pkg/chunkserver/script"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+const (
+	transferLeaderJobNameFormat = "curve-chunkserver-transfer-leader-%s"
+
+	defaultGracefulRestartTimeoutSeconds = 60
+)
+
+// TransferLeaders transfers the copyset leaders away from the chunkservers of the deployments before they are
+// restarted if spec.updateStrategy.gracefulRestart is enabled. It's best effort, a failed transfer is logged
+// and the chunkservers are restarted anyway.
+func (c *Cluster) TransferLeaders(deployments []appsv1.Deployment) {
+	if !c.spec.UpdateStrategy.GracefulRestart.Enable {
+		return
+	}
+
+	nodePorts := chunkserverPorts(deployments)
+	nodeNames := make([]string, 0, len(nodePorts))
+	for nodeName := range nodePorts {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+
+	for _, nodeName := range nodeNames {
+		if err := c.runTransferLeaderJob(nodeName, nodePorts[nodeName], c.gracefulRestartTimeout()); err != nil {
+			logger.Warningf("failed to transfer leaders of chunkservers on node %s, restarting them anyway. %v", nodeName, err)
+		}
+	}
+}
+
+// TransferLeadersOnNode transfers the copyset leaders away from the chunkservers of the deployments on the node
+// before they are evicted, whether spec.updateStrategy.gracefulRestart is enabled or not
+func (c *Cluster) TransferLeadersOnNode(nodeName string, deployments []appsv1.Deployment) error {
+	ports := chunkserverPorts(deployments)[nodeName]
+	if len(ports) == 0 {
+		return nil
+	}
+	return c.runTransferLeaderJob(nodeName, ports, c.gracefulRestartTimeout())
+}
+
+// gracefulRestartTimeout returns how long to wait for the chunkservers to lead no copyset in seconds
+func (c *Cluster) gracefulRestartTimeout() int {
+	if timeout := c.spec.UpdateStrategy.GracefulRestart.TimeoutSeconds; timeout > 0 {
+		return timeout
+	}
+	return defaultGracefulRestartTimeoutSeconds
+}
+
+// chunkserverPorts returns the ports of the chunkservers of the deployments keyed by node
+func chunkserverPorts(deployments []appsv1.Deployment) map[string][]int {
+	nodePorts := map[string][]int{}
+	for _, d := range deployments {
+		nodeName := d.Spec.Template.Spec.NodeName
+		for _, container := range d.Spec.Template.Spec.Containers {
+			if container.Name != "chunkserver" {
+				continue
+			}
+			for _, p := range container.Ports {
+				nodePorts[nodeName] = append(nodePorts[nodeName], int(p.ContainerPort))
+			}
+		}
+	}
+	return nodePorts
+}
+
+// runTransferLeaderJob runs the job to transfer the leaders of the chunkservers on the node and waits for it
+func (c *Cluster) runTransferLeaderJob(nodeName string, ports []int, timeout int) error {
+	node, err := c.context.Clientset.CoreV1().Nodes().Get(nodeName, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get node %q", nodeName)
+	}
+	nodeIP, err := k8sutil.NodeAddress(node, &c.spec.Network)
+	if err != nil {
+		return err
+	}
+
+	job, err := c.makeTransferLeaderJob(nodeName, nodeIP, ports, timeout)
+	if err != nil {
+		return err
+	}
+	// the job of the last restart is replaced
+	if err := k8sutil.RunReplaceableJob(context.TODO(), c.context.Clientset, job, true); err != nil {
+		return errors.Wrapf(err, "failed to run job %s", job.Name)
+	}
+	logger.Infof("created job %s to transfer leaders of chunkservers on node %s", job.Name, nodeName)
+
+	// the job gives up the transfer after timeout, the extra time is for pulling the image and starting
+	var succeeded bool
+	_ = wait.PollImmediate(3*time.Second, time.Duration(timeout)*time.Second+2*time.Minute, func() (bool, error) {
+		current, err := c.context.Clientset.BatchV1().Jobs(job.Namespace).Get(job.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+		succeeded = current.Status.Succeeded > 0
+		return succeeded || current.Status.Failed > 0, nil
+	})
+	if !succeeded {
+		return errors.Errorf("job %s did not succeed", job.Name)
+	}
+	return nil
+}
+
+func (c *Cluster) makeTransferLeaderJob(nodeName, nodeIP string, ports []int, timeout int) (*batch.Job, error) {
+	// tools.conf volume and volumemount
+	volumes, mounts := c.createTopoAndToolVolumeAndMount()
+
+	jobName := k8sutil.TruncateNodeNameForJob(transferLeaderJobNameFormat, nodeName)
+	labels := map[string]string{
+		"app":             AppName,
+		"transfer_leader": nodeName,
+		"curve_cluster":   c.namespacedName.Namespace,
+	}
+
+	args := []string{"-c", script.TRANSFER_LEADER, "transfer-leader", nodeIP, strconv.Itoa(timeout)}
+	for _, port := range ports {
+		args = append(args, strconv.Itoa(port))
+	}
+	backoffLimit := int32(0)
+
+	podSpec := v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   jobName,
+			Labels: labels,
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:            "transfer-leader",
+					Command:         []string{"/bin/bash"},
+					Args:            args,
+					Image:           c.spec.CurveVersion.Image,
+					ImagePullPolicy: c.spec.CurveVersion.ImagePullPolicy,
+					VolumeMounts:    mounts,
+				},
+			},
+			RestartPolicy: v1.RestartPolicyNever,
+			HostNetwork:   true,
+			DNSPolicy:     v1.DNSClusterFirstWithHostNet,
+			Volumes:       volumes,
+		},
+	}
+	k8sutil.InjectEnv(&podSpec.Spec, c.spec, "chunkserver")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, c.spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, c.spec)
+	k8sutil.InjectDNS(&podSpec.Spec, c.spec, "chunkserver")
+
+	job := &batch.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jobName,
+			Namespace: c.namespacedName.Namespace,
+			Labels:    labels,
+		},
+		Spec: batch.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template:     podSpec,
+		},
+	}
+
+	k8sutil.InjectMetadata(c.spec, "chunkserver", job, &job.Spec.Template)
+	err := c.ownerInfo.SetControllerReference(job)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to set owner reference to transfer leader job %q", job.Name)
+	}
+
+	return job, nil
+}
@@ -0,0 +1,412 @@
+package chunkserver
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/curvetool"
+	"github.com/opencurve/curve-operator/pkg/internal/testutil"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+	"github.com/opencurve/curve-operator/pkg/mds"
+)
+
+const testNamespace = "curvebs"
+
+// fakeEnv runs the provisioning flow of chunkservers in process. The clientset and the client of
+// controller-runtime share the objects like an api server does, and the jobs and deployments are finished as
+// soon as they are created like the kubelet would do, the jobs by runJob that mocks the scripts.
+type fakeEnv struct {
+	t         *testing.T
+	scheme    *runtime.Scheme
+	clientset *fake.Clientset
+	tracker   k8stesting.ObjectTracker
+	context   clusterd.Context
+	cluster   *curvev1.CurveCluster
+
+	// runJob mocks the script of the job, such as format.sh of the prepare jobs, the job fails if it returns false
+	runJob func(job *batch.Job) bool
+
+	mu sync.Mutex
+	// created is the jobs and deployments in the order they are created, such as Job/gen-physical-pool
+	created []string
+}
+
+// newFakeEnv returns the environment of a cluster whose storage nodes are ready, mds and etcd are running
+func newFakeEnv(t *testing.T, spec *curvev1.CurveClusterSpec) *fakeEnv {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := curvev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	env := &fakeEnv{
+		t:         t,
+		scheme:    scheme,
+		clientset: fake.NewSimpleClientset(),
+		runJob:    func(*batch.Job) bool { return true },
+	}
+	env.tracker = env.clientset.Tracker()
+	env.clientset.PrependReactor("create", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		env.finish(action.(k8stesting.CreateAction).GetObject())
+		// the object is saved by the default reactor
+		return false, nil, nil
+	})
+	env.context = clusterd.Context{Clientset: env.clientset, Client: &fakeClient{env: env}}
+
+	// the leader of mds is elected by the mds pod running on the dummy port of the vars server
+	mdsHost, mdsDummyPort := testutil.SplitAddr(t, testutil.NewVarsServer(t, "mds_status : leader\n"))
+	spec.Mds.DummyPort = mdsDummyPort
+
+	env.cluster = &curvev1.CurveCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "curvebs", Namespace: testNamespace, UID: "curvebs-uid"},
+		Spec:       spec,
+	}
+	env.create(env.cluster.DeepCopy())
+	for i, nodeName := range k8sutil.MergeNodeNames(spec.DaemonNodes(), spec.StorageNodes()) {
+		env.addNode(nodeName, fmt.Sprintf("10.0.0.%d", i+1))
+	}
+	env.create(&v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "curve-mds-a", Namespace: testNamespace, Labels: map[string]string{"app": mds.AppName}},
+		Status:     v1.PodStatus{Phase: v1.PodRunning, HostIP: mdsHost},
+	})
+	// the config templates read from the curve image by the operator
+	env.create(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: config.ChunkServerConfigMapTemp, Namespace: testNamespace},
+		Data:       map[string]string{"global.ip": "${service_addr}", "global.port": "${service_port}"},
+	})
+	env.create(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: config.CsClientConfigMapTemp, Namespace: testNamespace},
+		Data:       map[string]string{config.CSClientConfigMapDataKey: "mds.listen.addr=${cluster_mds_addr}\n"},
+	})
+	env.create(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: config.S3ConfigMapTemp, Namespace: testNamespace},
+		Data:       map[string]string{"s3.ak": "", "s3.sk": ""},
+	})
+	env.create(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: config.ToolsConfigMapTemp, Namespace: testNamespace},
+		Data:       map[string]string{config.ToolsConfigMapDataKey: "mdsAddr=${cluster_mds_addr}\n"},
+	})
+	err := config.UpdateClusterInfo(&env.context, testNamespace, env.ownerInfo(), *spec, func(info *config.ClusterInfo) {
+		info.EtcdAddr = "10.0.0.1:23790,10.0.0.2:23790,10.0.0.3:23790"
+		info.MdsAddr = "10.0.0.1:6700,10.0.0.2:6700,10.0.0.3:6700"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// all the started chunkservers are registered to mds
+	list := listRegisteredChunkServers
+	listRegisteredChunkServers = registeredChunkServers
+	t.Cleanup(func() { listRegisteredChunkServers = list })
+
+	// the format jobs are checked right after they are finished
+	interval := formatCheckInterval
+	formatCheckInterval = 10 * time.Millisecond
+	t.Cleanup(func() { formatCheckInterval = interval })
+	return env
+}
+
+// registeredChunkServers returns all the chunkservers of the cluster as registered to mds
+func registeredChunkServers(c *Cluster) ([]curvetool.ChunkServer, error) {
+	var chunkservers []curvetool.ChunkServer
+	for i, csConfig := range c.chunkserverConfigs {
+		chunkservers = append(chunkservers, curvetool.ChunkServer{
+			ID:          i + 1,
+			HostIP:      csConfig.NodeIP,
+			Port:        csConfig.Port,
+			RWStatus:    curvetool.ChunkServerReadWrite,
+			OnlineState: curvetool.ChunkServerOnline,
+		})
+	}
+	return chunkservers, nil
+}
+
+func (env *fakeEnv) ownerInfo() *k8sutil.OwnerInfo {
+	return k8sutil.NewOwnerInfo(env.cluster, env.scheme)
+}
+
+// newCluster returns the chunkserver cluster of the current spec
+func (env *fakeEnv) newCluster() *Cluster {
+	return New(env.context, types.NamespacedName{Namespace: testNamespace, Name: env.cluster.Name}, *env.cluster.Spec,
+		env.ownerInfo(), "/curvebs/data", "/curvebs/log", "/curvebs/conf")
+}
+
+// start runs the provisioning flow of the current spec
+func (env *fakeEnv) start() error {
+	nodeNameIP, err := k8sutil.GetNodeInfoMap(env.cluster.Spec, env.clientset)
+	if err != nil {
+		env.t.Fatal(err)
+	}
+	return env.newCluster().Start(nodeNameIP)
+}
+
+func (env *fakeEnv) addNode(nodeName, ip string) {
+	env.create(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: nodeName, Labels: map[string]string{v1.LabelHostname: nodeName}},
+		Status: v1.NodeStatus{
+			Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}},
+			Addresses:  []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: ip}},
+		},
+	})
+}
+
+func (env *fakeEnv) create(obj runtime.Object) {
+	if err := env.context.Client.Create(context.TODO(), obj); err != nil {
+		env.t.Fatal(err)
+	}
+}
+
+// getCluster returns the cluster object with the status updated by the flow
+func (env *fakeEnv) getCluster() *curvev1.CurveCluster {
+	cluster := &curvev1.CurveCluster{}
+	key := types.NamespacedName{Namespace: testNamespace, Name: env.cluster.Name}
+	if err := env.context.Client.Get(context.TODO(), key, cluster); err != nil {
+		env.t.Fatal(err)
+	}
+	return cluster
+}
+
+// finish completes the jobs and deployments like the kubelet and the controllers of kubernetes
+func (env *fakeEnv) finish(obj runtime.Object) {
+	switch o := obj.(type) {
+	case *batch.Job:
+		if o.Status.Succeeded > 0 || o.Status.Failed > 0 {
+			return
+		}
+		if env.runJob(o) {
+			o.Status.Succeeded = 1
+		} else {
+			o.Status.Failed = 1
+			o.Status.Conditions = append(o.Status.Conditions, batch.JobCondition{
+				Type: batch.JobFailed, Status: v1.ConditionTrue, Reason: "BackoffLimitExceeded", Message: "Job has reached the specified backoff limit",
+			})
+		}
+		env.record("Job/" + o.Name)
+	case *appsv1.Deployment:
+		// the deployments that have been rolled out before are updated
+		if o.Status.Replicas == 0 {
+			env.record("Deployment/" + o.Name)
+		}
+		replicas := int32(1)
+		if o.Spec.Replicas != nil {
+			replicas = *o.Spec.Replicas
+		}
+		o.Status.ObservedGeneration = o.Generation
+		o.Status.Replicas = replicas
+		o.Status.UpdatedReplicas = replicas
+		o.Status.ReadyReplicas = replicas
+		o.Status.AvailableReplicas = replicas
+	}
+}
+
+func (env *fakeEnv) record(name string) {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	env.created = append(env.created, name)
+}
+
+// createdWithPrefix returns the created jobs and deployments whose names start with prefix, such as
+// Job/prepare-chunkfile
+func (env *fakeEnv) createdWithPrefix(prefix string) []string {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	var names []string
+	for _, name := range env.created {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// indexOf returns the position of the first created object whose name starts with prefix, or -1
+func (env *fakeEnv) indexOf(prefix string) int {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	for i, name := range env.created {
+		if strings.HasPrefix(name, prefix) {
+			return i
+		}
+	}
+	return -1
+}
+
+// lastIndexOf returns the position of the last created object whose name starts with prefix, or -1
+func (env *fakeEnv) lastIndexOf(prefix string) int {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	for i := len(env.created) - 1; i >= 0; i-- {
+		if strings.HasPrefix(env.created[i], prefix) {
+			return i
+		}
+	}
+	return -1
+}
+
+// fakeClient is the client of controller-runtime on the objects of the fake clientset, the objects applied by
+// server-side apply replace the existing ones except the status
+type fakeClient struct {
+	env *fakeEnv
+}
+
+var _ client.Client = &fakeClient{}
+
+func (c *fakeClient) resource(obj runtime.Object) (schema.GroupVersionResource, schema.GroupVersionKind, error) {
+	gvk, err := apiutil.GVKForObject(obj, c.env.scheme)
+	if err != nil {
+		return schema.GroupVersionResource{}, gvk, err
+	}
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	gvr, _ := meta.UnsafeGuessKindToResource(gvk)
+	return gvr, gvk, nil
+}
+
+func (c *fakeClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	gvr, _, err := c.resource(obj)
+	if err != nil {
+		return err
+	}
+	o, err := c.env.tracker.Get(gvr, key.Namespace, key.Name)
+	if err != nil {
+		return err
+	}
+	return copyInto(o, obj)
+}
+
+func (c *fakeClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	gvr, gvk, err := c.resource(list)
+	if err != nil {
+		return err
+	}
+	options := &client.ListOptions{}
+	options.ApplyOptions(opts)
+	o, err := c.env.tracker.List(gvr, gvk, options.Namespace)
+	if err != nil {
+		return err
+	}
+	if options.LabelSelector != nil {
+		items, err := meta.ExtractList(o)
+		if err != nil {
+			return err
+		}
+		var selected []runtime.Object
+		for _, item := range items {
+			accessor, err := meta.Accessor(item)
+			if err != nil {
+				return err
+			}
+			if options.LabelSelector.Matches(labels.Set(accessor.GetLabels())) {
+				selected = append(selected, item)
+			}
+		}
+		if err := meta.SetList(o, selected); err != nil {
+			return err
+		}
+	}
+	return copyInto(o, list)
+}
+
+func (c *fakeClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	gvr, _, err := c.resource(obj)
+	if err != nil {
+		return err
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	c.env.finish(obj)
+	return c.env.tracker.Create(gvr, obj, accessor.GetNamespace())
+}
+
+func (c *fakeClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	gvr, _, err := c.resource(obj)
+	if err != nil {
+		return err
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	return c.env.tracker.Delete(gvr, accessor.GetNamespace(), accessor.GetName())
+}
+
+func (c *fakeClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	gvr, _, err := c.resource(obj)
+	if err != nil {
+		return err
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	return c.env.tracker.Update(gvr, obj, accessor.GetNamespace())
+}
+
+func (c *fakeClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return errors.Errorf("patch type %s is not supported by the fake client", patch.Type())
+	}
+	gvr, _, err := c.resource(obj)
+	if err != nil {
+		return err
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	existing, err := c.env.tracker.Get(gvr, accessor.GetNamespace(), accessor.GetName())
+	if err != nil {
+		return c.Create(ctx, obj)
+	}
+	// the status is not applied, the changed deployments are rolled out at once
+	if status := reflect.ValueOf(obj).Elem().FieldByName("Status"); status.IsValid() {
+		status.Set(reflect.ValueOf(existing).Elem().FieldByName("Status"))
+	}
+	c.env.finish(obj)
+	return c.env.tracker.Update(gvr, obj, accessor.GetNamespace())
+}
+
+func (c *fakeClient) DeleteAllOf(ctx context.Context, obj runtime.Object, opts ...client.DeleteAllOfOption) error {
+	return errors.New("DeleteAllOf is not supported by the fake client")
+}
+
+func (c *fakeClient) Status() client.StatusWriter {
+	return c
+}
+
+// copyInto sets obj to the object got from the tracker, they are of the same type
+func copyInto(from, obj runtime.Object) error {
+	src, dst := reflect.ValueOf(from), reflect.ValueOf(obj)
+	if src.Type() != dst.Type() {
+		return errors.Errorf("can't copy %T into %T", from, obj)
+	}
+	dst.Elem().Set(src.Elem())
+	return nil
+}
@@ -0,0 +1,92 @@
+package chunkserver
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// InventoryExportKey is the key of the inventory in the exported topology, the data of the configmaps are keyed
+// by '<configmap>.<key>'
+const InventoryExportKey = InventoryConfigMapName + "." + inventoryDataKey
+
+// ImportInventory reserves the bindings of the chunkservers in the exported inventory for the devices in storage,
+// so the chunkservers of the devices take the same sequences and ports as the exported cluster when they are
+// formatted or adopted. It refuses to import into a cluster that has formatted devices. It returns the number of
+// the reserved devices.
+func (c *Cluster) ImportInventory(data string) (int, error) {
+	var list []DeviceRecord
+	if err := json.Unmarshal([]byte(data), &list); err != nil {
+		return 0, errors.Wrap(err, "failed to parse the exported inventory")
+	}
+	formatted, err := c.HasFormattedDevices()
+	if err != nil {
+		return 0, err
+	}
+	if formatted {
+		return 0, errors.Errorf("the cluster has formatted devices in configmap %q, the topology can only be imported before any device is formatted",
+			InventoryConfigMapName)
+	}
+
+	nodeDevices, err := c.resolvedNodeDevices()
+	if err != nil {
+		return 0, err
+	}
+	records := map[string]DeviceRecord{}
+	for _, exported := range list {
+		var found bool
+		for _, device := range nodeDevices[exported.NodeName] {
+			found = found || device.Name == exported.DeviceName
+		}
+		if !found {
+			logger.Warningf("device %s on %s of the exported topology is not in storage, its binding is not imported",
+				exported.DeviceName, exported.NodeName)
+			continue
+		}
+		if exported.HostSequence == nil || exported.ReplicasSequence == nil {
+			return 0, errors.Errorf("device %s on %s of the exported topology has no sequences, it was exported from an older operator",
+				exported.DeviceName, exported.NodeName)
+		}
+		// the stable names and the format of the devices are of the exported hardware, only the identity of the
+		// chunkserver is kept
+		records[inventoryKey(exported.NodeName, exported.DeviceName)] = DeviceRecord{
+			NodeName:         exported.NodeName,
+			DeviceName:       exported.DeviceName,
+			ChunkServer:      DeploymentName(exported.NodeName, exported.DeviceName),
+			Port:             exported.Port,
+			HostSequence:     exported.HostSequence,
+			ReplicasSequence: exported.ReplicasSequence,
+			Reserved:         true,
+		}
+	}
+	if err := c.saveInventory(records); err != nil {
+		return 0, err
+	}
+	return len(records), nil
+}
+
+// HasFormattedDevices returns whether any device of the cluster has been formatted or adopted
+func (c *Cluster) HasFormattedDevices() (bool, error) {
+	records, err := c.loadInventory()
+	if err != nil {
+		return false, err
+	}
+	return len(formattedRecords(records)) > 0, nil
+}
+
+// resolvedNodeDevices returns the devices in storage keyed by the names of the node resources, which the records
+// of the inventory are keyed by
+func (c *Cluster) resolvedNodeDevices() (map[string][]curvev1.DevicesSpec, error) {
+	resolved, err := k8sutil.ResolveNodeNames(c.context.Clientset, c.spec.StorageNodes())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve storage nodes")
+	}
+	nodeDevices := map[string][]curvev1.DevicesSpec{}
+	for _, nodeName := range c.spec.StorageNodes() {
+		nodeDevices[resolved[nodeName]] = c.spec.Storage.NodeDevices(nodeName)
+	}
+	return nodeDevices, nil
+}
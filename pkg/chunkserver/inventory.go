@@ -0,0 +1,168 @@
+package chunkserver
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+const (
+	// InventoryConfigMapName is the configmap to record the formatted devices,
+	// the devices in it are not formatted again when the cluster is reconciled after operator restarts
+	InventoryConfigMapName = "curve-chunkserver-inventory"
+	inventoryDataKey       = "inventory.json"
+)
+
+// DeviceRecord is a formatted device and the chunkserver bound to it
+type DeviceRecord struct {
+	NodeName      string `json:"nodeName"`
+	DeviceName    string `json:"deviceName"`
+	StableName    string `json:"stableName,omitempty"`
+	DeviceType    string `json:"deviceType,omitempty"`
+	MountPath     string `json:"mountPath,omitempty"`
+	Encrypted     bool   `json:"encrypted,omitempty"`
+	CacheDevice   string `json:"cacheDevice,omitempty"`
+	Percentage    int    `json:"percentage"`
+	ChunkFileSize int    `json:"chunkFileSize"`
+	ChunkServer   string `json:"chunkServer"`
+	Port          int    `json:"port"`
+	FormattedAt   string `json:"formattedAt"`
+	// HostSequence and ReplicasSequence bind the chunkserver to its node and device, they are missing in the
+	// records of old operators
+	HostSequence     *int `json:"hostSequence,omitempty"`
+	ReplicasSequence *int `json:"replicasSequence,omitempty"`
+	// Reserved is the binding imported from an exported topology, the device is not formatted yet but its
+	// chunkserver takes the sequences and port of the record when it is
+	Reserved bool `json:"reserved,omitempty"`
+}
+
+func inventoryKey(nodeName, deviceName string) string {
+	return nodeName + ":" + deviceName
+}
+
+// loadInventory returns the formatted devices keyed by node and device name
+func (c *Cluster) loadInventory() (map[string]DeviceRecord, error) {
+	records := map[string]DeviceRecord{}
+	cm, err := c.context.Clientset.CoreV1().ConfigMaps(c.namespacedName.Namespace).Get(InventoryConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return records, nil
+		}
+		return nil, errors.Wrapf(err, "failed to get configmap %q", InventoryConfigMapName)
+	}
+
+	var list []DeviceRecord
+	if err := json.Unmarshal([]byte(cm.Data[inventoryDataKey]), &list); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse configmap %q", InventoryConfigMapName)
+	}
+	for _, r := range list {
+		records[inventoryKey(r.NodeName, r.DeviceName)] = r
+	}
+	return records, nil
+}
+
+// formattedRecords returns the records of the devices that have been formatted, without the reserved ones
+func formattedRecords(records map[string]DeviceRecord) map[string]DeviceRecord {
+	formatted := map[string]DeviceRecord{}
+	for key, r := range records {
+		if !r.Reserved {
+			formatted[key] = r
+		}
+	}
+	return formatted
+}
+
+// updateInventory records the devices of all chunkservers as formatted
+func (c *Cluster) updateInventory() error {
+	records, err := c.loadInventory()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	for i := range c.chunkserverConfigs {
+		csConfig := &c.chunkserverConfigs[i]
+		key := inventoryKey(csConfig.NodeName, csConfig.DeviceName)
+		hostSequence, replicasSequence := csConfig.HostSequence, csConfig.ReplicasSequence
+		if r, ok := records[key]; ok && !r.Reserved {
+			// the records of old operators are bound by the current chunkservers
+			if r.HostSequence == nil || r.ReplicasSequence == nil {
+				r.HostSequence, r.ReplicasSequence = &hostSequence, &replicasSequence
+				records[key] = r
+			}
+			continue
+		}
+		csConfig.StableName = c.resolveStableName(csConfig.NodeName, csConfig.DeviceName)
+		csConfig.DataPathMap.HostDevice = csConfig.devicePath()
+		r := DeviceRecord{
+			NodeName:      csConfig.NodeName,
+			DeviceName:    csConfig.DeviceName,
+			StableName:    csConfig.StableName,
+			ChunkFileSize: int(csConfig.ChunkSize),
+			ChunkServer:   csConfig.ResourceName,
+			Port:          csConfig.Port,
+			FormattedAt:   now,
+
+			HostSequence:     &hostSequence,
+			ReplicasSequence: &replicasSequence,
+		}
+		for _, device := range c.nodeDevices[csConfig.NodeName] {
+			if device.Name == csConfig.DeviceName {
+				r.DeviceType = string(device.Type)
+				r.MountPath = device.MountPath
+				r.Encrypted = device.Encrypted
+				r.CacheDevice = device.CacheDevice
+				r.Percentage = device.Percentage
+			}
+		}
+		records[key] = r
+	}
+
+	return c.saveInventory(records)
+}
+
+// removeInventory removes the record of the device on the node
+func (c *Cluster) removeInventory(nodeName, deviceName string) error {
+	records, err := c.loadInventory()
+	if err != nil {
+		return err
+	}
+	delete(records, inventoryKey(nodeName, deviceName))
+	return c.saveInventory(records)
+}
+
+func (c *Cluster) saveInventory(records map[string]DeviceRecord) error {
+	list := []DeviceRecord{}
+	for _, r := range records {
+		list = append(list, r)
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal inventory")
+	}
+
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      InventoryConfigMapName,
+			Namespace: c.namespacedName.Namespace,
+		},
+		Data: map[string]string{inventoryDataKey: string(data)},
+	}
+	k8sutil.InjectMetadata(c.spec, "chunkserver", cm)
+	err = c.ownerInfo.SetControllerReference(cm)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set owner reference to configmap %q", InventoryConfigMapName)
+	}
+
+	err = k8sutil.Apply(c.context.Client, cm)
+	if err != nil {
+		return errors.Wrapf(err, "failed to apply configmap %q", InventoryConfigMapName)
+	}
+	return nil
+}
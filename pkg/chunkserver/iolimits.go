@@ -0,0 +1,77 @@
+package chunkserver
+
+import (
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/opencurve/curve-operator/pkg/chunkserver/script"
+	"github.com/opencurve/curve-operator/pkg/config"
+)
+
+// containerCgroupDir is where the cgroup root of the host is mounted in the io-limits container
+const containerCgroupDir = "/host/sys/fs/cgroup"
+
+// ioRate returns the bytes of the rate, 0 is not limited
+func ioRate(rate *resource.Quantity) string {
+	if rate == nil {
+		return "0"
+	}
+	return strconv.FormatInt(rate.Value(), 10)
+}
+
+// makeIOLimitsContainers returns the init container that writes storage.io to the cgroup of the chunkserver pod
+// with the volume of the cgroup root of the host, they are nil if storage.io is not set or the IO bypasses the
+// kernel
+func (c *Cluster) makeIOLimitsContainers(csConfig *chunkserverConfig) ([]v1.Container, []v1.Volume) {
+	io := c.spec.Storage.IO
+	if io.Weight == 0 && io.ReadBytesPerSecond == nil && io.WriteBytesPerSecond == nil && io.ReadIOPS == 0 && io.WriteIOPS == 0 {
+		return nil, nil
+	}
+	if c.spec.Storage.IsSPDK() || c.spec.DevMode {
+		return nil, nil
+	}
+
+	privileged := true
+	runAsUser := int64(0)
+	cgroupPathType := v1.HostPathDirectory
+
+	containers := []v1.Container{
+		{
+			Name:    "io-limits",
+			Command: []string{"/bin/bash"},
+			Args: []string{
+				"-c", script.IO_LIMITS, "io-limits",
+				deviceArg(csConfig.devicePath(), csConfig.DeviceType),
+				string(csConfig.DeviceType),
+				strconv.Itoa(io.Weight),
+				ioRate(io.ReadBytesPerSecond),
+				ioRate(io.WriteBytesPerSecond),
+				strconv.FormatInt(io.ReadIOPS, 10),
+				strconv.FormatInt(io.WriteIOPS, 10),
+				containerCgroupDir,
+			},
+			Image:           c.spec.CurveVersion.Image,
+			ImagePullPolicy: c.spec.CurveVersion.ImagePullPolicy,
+			Env: []v1.EnvVar{
+				{Name: "POD_UID", ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.uid"}}},
+			},
+			VolumeMounts: []v1.VolumeMount{
+				{Name: "dev-volume", MountPath: "/dev"},
+				{Name: "cgroup-volume", MountPath: containerCgroupDir},
+			},
+			SecurityContext: &v1.SecurityContext{
+				Privileged: &privileged,
+				RunAsUser:  &runAsUser,
+			},
+		},
+	}
+	volumes := []v1.Volume{
+		{
+			Name:         "cgroup-volume",
+			VolumeSource: v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: config.GetOperatorSettings().HostCgroupDir, Type: &cgroupPathType}},
+		},
+	}
+	return containers, volumes
+}
@@ -0,0 +1,70 @@
+package chunkserver
+
+import (
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// largeChunkserverMemory is the pinned memory from which a chunkserver is large, it locks its buffers in memory
+// so that they are never swapped out
+var largeChunkserverMemory = resource.MustParse("16Gi")
+
+// locksMemory returns true if the chunkservers lock memory, the spdk engine locks the hugepages for dma and
+// the large chunkservers lock their buffers
+func (c *Cluster) locksMemory() bool {
+	if c.spec.Storage.IsSPDK() {
+		return true
+	}
+	memory := c.spec.Storage.CPUPinning.Memory
+	return c.spec.Storage.CPUPinning.CPUs > 0 && memory != nil && memory.Cmp(largeChunkserverMemory) >= 0
+}
+
+// setMemoryLock adds the IPC_LOCK capability to the chunkserver container that locks memory, which it still
+// needs if the container is not privileged
+func (c *Cluster) setMemoryLock(podSpec *v1.PodSpec) {
+	if !c.locksMemory() {
+		return
+	}
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+		if container.Name != "chunkserver" {
+			continue
+		}
+		if container.SecurityContext == nil {
+			container.SecurityContext = &v1.SecurityContext{}
+		}
+		if container.SecurityContext.Capabilities == nil {
+			container.SecurityContext.Capabilities = &v1.Capabilities{}
+		}
+		container.SecurityContext.Capabilities.Add = append(container.SecurityContext.Capabilities.Add, "IPC_LOCK")
+	}
+}
+
+// checkNodeHugePages checks that the node allocates enough hugepages for the spdk chunkservers of its devices,
+// otherwise the chunkserver pods are never scheduled to it
+func (c *Cluster) checkNodeHugePages(nodeName string, chunkservers int) error {
+	if !c.spec.Storage.IsSPDK() {
+		return nil
+	}
+	node, err := c.context.Clientset.CoreV1().Nodes().Get(nodeName, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		// the missing node is reported by the provisioning of its devices
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to get node %q", nodeName)
+	}
+
+	name := v1.ResourceName(v1.ResourceHugePagesPrefix + c.spec.Storage.SPDK.GetHugePageSize())
+	required := c.spec.Storage.SPDK.GetHugePages()
+	required.Set(required.Value() * int64(chunkservers))
+	allocatable := node.Status.Allocatable[name]
+	if allocatable.Cmp(required) < 0 {
+		return errors.Errorf("node %q allocates %s of %s but its %d spdk chunkservers require %s, the hugepages must be pre-allocated on the node",
+			nodeName, allocatable.String(), name, chunkservers, required.String())
+	}
+	return nil
+}
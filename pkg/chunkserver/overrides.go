@@ -0,0 +1,52 @@
+package chunkserver
+
+import (
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// setPodTemplateOverrides adds the volumes, init containers and containers of storage.podTemplateOverrides to the
+// pod of chunkserver. A name that conflicts with a generated volume or container is a config error.
+func (c *Cluster) setPodTemplateOverrides(podSpec *v1.PodSpec) error {
+	overrides := c.spec.Storage.PodTemplateOverrides
+	if overrides == nil {
+		return nil
+	}
+
+	volumes := map[string]bool{}
+	for _, volume := range podSpec.Volumes {
+		volumes[volume.Name] = true
+	}
+	for _, volume := range overrides.Volumes {
+		if volumes[volume.Name] {
+			return k8sutil.NewConfigError(errors.Errorf("volume %q of storage.podTemplateOverrides conflicts with the volumes of chunkserver", volume.Name))
+		}
+		volumes[volume.Name] = true
+		podSpec.Volumes = append(podSpec.Volumes, *volume.DeepCopy())
+	}
+
+	containers := map[string]bool{}
+	for _, list := range [][]v1.Container{podSpec.InitContainers, podSpec.Containers} {
+		for _, container := range list {
+			containers[container.Name] = true
+		}
+	}
+	for _, list := range []struct {
+		from []v1.Container
+		to   *[]v1.Container
+	}{
+		{overrides.InitContainers, &podSpec.InitContainers},
+		{overrides.Containers, &podSpec.Containers},
+	} {
+		for _, container := range list.from {
+			if containers[container.Name] {
+				return k8sutil.NewConfigError(errors.Errorf("container %q of storage.podTemplateOverrides conflicts with the containers of chunkserver", container.Name))
+			}
+			containers[container.Name] = true
+			*list.to = append(*list.to, *container.DeepCopy())
+		}
+	}
+	return nil
+}
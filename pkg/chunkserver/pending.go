@@ -0,0 +1,40 @@
+package chunkserver
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// updatePendingNodes records the storage nodes that are not valid to run chunkservers in status, they join the
+// cluster when they become Ready
+func (c *Cluster) updatePendingNodes(storageNodes []string, validNodes []v1.Node) error {
+	valid := map[string]bool{}
+	for _, node := range validNodes {
+		valid[node.Name] = true
+	}
+	var pending []string
+	for _, nodeName := range storageNodes {
+		if !valid[nodeName] {
+			pending = append(pending, nodeName)
+		}
+	}
+	if len(pending) > 0 {
+		logger.Warningf("storage nodes %v are not ready or schedulable, they join the cluster when they become Ready", pending)
+	}
+
+	clusterObj := &curvev1.CurveCluster{}
+	if err := c.context.Client.Get(context.TODO(), c.namespacedName, clusterObj); err != nil {
+		return errors.Wrapf(err, "failed to get curvecluster %q", c.namespacedName)
+	}
+	if reflect.DeepEqual(clusterObj.Status.PendingStorageNodes, pending) {
+		return nil
+	}
+	clusterObj.Status.PendingStorageNodes = pending
+	return k8sutil.UpdateStatus(c.context.Client, c.namespacedName, clusterObj)
+}
@@ -0,0 +1,44 @@
+package chunkserver
+
+import (
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// helperContainerResources are the resources of the init and sidecar containers of a guaranteed chunkserver pod,
+// every container must have equal requests and limits for the pod to be guaranteed
+var helperContainerResources = v1.ResourceList{
+	v1.ResourceCPU:    resource.MustParse("100m"),
+	v1.ResourceMemory: resource.MustParse("128Mi"),
+}
+
+// setGuaranteedResources gives the chunkserver pod the Guaranteed QoS class with integer cpus of
+// storage.cpuPinning, kubelet with the static CPU manager policy assigns exclusive cpus to chunkserver
+func (c *Cluster) setGuaranteedResources(podSpec *v1.PodSpec) error {
+	pinning := c.spec.Storage.CPUPinning
+	if pinning.CPUs == 0 {
+		return nil
+	}
+	if pinning.Memory == nil {
+		return errors.New("storage.cpuPinning.memory must be set with storage.cpuPinning.cpus")
+	}
+
+	chunkserver := v1.ResourceList{
+		v1.ResourceCPU:    *resource.NewQuantity(int64(pinning.CPUs), resource.DecimalSI),
+		v1.ResourceMemory: *pinning.Memory,
+	}
+	for _, containers := range [][]v1.Container{podSpec.InitContainers, podSpec.Containers} {
+		for i := range containers {
+			resources := helperContainerResources
+			if containers[i].Name == "chunkserver" {
+				resources = chunkserver
+			}
+			containers[i].Resources = v1.ResourceRequirements{
+				Requests: resources.DeepCopy(),
+				Limits:   resources.DeepCopy(),
+			}
+		}
+	}
+	return nil
+}
@@ -4,6 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/config"
 )
 
 //nolint:unused
@@ -13,11 +20,23 @@ const (
 	ROLE_CHUNKSERVER = "chunkserver"
 	ROLE_METASERVER  = "metaserver"
 
-	DEFAULT_CHUNKSERVER_COPYSETS = 100
 	DEFAULT_REPLICAS_PER_COPYSET = 3
 	DEFAULT_ZONES_PER_POOL       = 3
 	DEFAULT_TYPE                 = 0
 	DEFAULT_SCATTER_WIDTH        = 0
+
+	// the copysets of a chunkserver by storage.autoCopySets, each copyset has about 40GiB of 16MiB chunks on it,
+	// so a chunk file pool of 4TiB gets about 100 copysets like the default
+	AUTO_COPYSET_CHUNKS           = 2560
+	AUTO_MIN_CHUNKSERVER_COPYSETS = 10
+	AUTO_MAX_CHUNKSERVER_COPYSETS = 500
+
+	DEFAULT_LOGICAL_POOL_NAME  = "pool1"
+	DEFAULT_PHYSICAL_POOL_NAME = "pool1"
+	DEFAULT_ZONE_LABEL         = "topology.kubernetes.io/zone"
+
+	// the chunk size of storage.pools is a multiple of the page of chunk files
+	CHUNK_SIZE_ALIGNMENT = 4 * 1024
 )
 
 // Generate topology.json file below from curveadm
@@ -79,6 +98,7 @@ type (
 		ExternalIp   string `json:"externalip"`
 		ExternalPort int    `json:"externalport"`
 		Zone         string `json:"zone"`
+		Weight       int    `json:"weight,omitempty"`
 		PhysicalPool string `json:"physicalpool,omitempty"` // curvebs
 		Pool         string `json:"pool,omitempty"`         // curvefs
 	}
@@ -91,12 +111,155 @@ type (
 	}
 )
 
-func genNextZone(zones int) func() string {
-	idx := 0
-	return func() string {
-		idx++
-		return fmt.Sprintf("zone%d", (idx-1)%zones+1)
+// TopologyError is returned if the topology generated from the spec can't be registered to mds, the create pool
+// jobs are not submitted until the topology is corrected
+type TopologyError struct {
+	Problems []string
+}
+
+func (e *TopologyError) Error() string {
+	return "invalid topology: " + strings.Join(e.Problems, "; ")
+}
+
+// ClusterErrors returns a ConfigError, the topology is generated again from the fixed spec or nodes
+func (e *TopologyError) ClusterErrors() []curvev1.ClusterError {
+	return []curvev1.ClusterError{{Category: curvev1.ErrorCategoryConfigError, Message: e.Error()}}
+}
+
+// validateTopology checks the topology before it's registered by the create pool jobs, which fail with little
+// of the reason otherwise
+func validateTopology(topo *CurveClusterTopo) error {
+	var problems []string
+	if len(topo.Servers) == 0 {
+		problems = append(problems, "no chunkserver in the topology")
+	}
+
+	names := map[string]bool{}
+	endpoints := map[string]bool{}
+	// zones of each physical pool
+	physicalPools := map[string]map[string]bool{}
+	for _, server := range topo.Servers {
+		switch {
+		case server.Name == "":
+			problems = append(problems, "a chunkserver has no name")
+		case names[server.Name]:
+			problems = append(problems, fmt.Sprintf("chunkserver %s is duplicated", server.Name))
+		}
+		names[server.Name] = true
+		if server.InternalIp == "" || server.ExternalIp == "" {
+			problems = append(problems, fmt.Sprintf("chunkserver %s has no ip", server.Name))
+		}
+		// the chunkservers of replicas share the ip with port 0, see createLogicalPool
+		if server.InternalPort != 0 {
+			endpoint := fmt.Sprintf("%s:%d", server.InternalIp, server.InternalPort)
+			if endpoints[endpoint] {
+				problems = append(problems, fmt.Sprintf("chunkserver %s listens on %s of another chunkserver", server.Name, endpoint))
+			}
+			endpoints[endpoint] = true
+		}
+		if server.Zone == "" {
+			problems = append(problems, fmt.Sprintf("chunkserver %s has no zone", server.Name))
+		}
+		if server.PhysicalPool == "" {
+			problems = append(problems, fmt.Sprintf("chunkserver %s has no physical pool", server.Name))
+		}
+		if physicalPools[server.PhysicalPool] == nil {
+			physicalPools[server.PhysicalPool] = map[string]bool{}
+		}
+		physicalPools[server.PhysicalPool][server.Zone] = true
 	}
+
+	for _, lpool := range topo.LogicalPools {
+		zones := physicalPools[lpool.PhysicalPool]
+		if zones == nil {
+			problems = append(problems, fmt.Sprintf("physical pool %s of logical pool %s has no chunkserver", lpool.PhysicalPool, lpool.Name))
+		}
+		if lpool.Zones < lpool.Replicas {
+			problems = append(problems, fmt.Sprintf("logical pool %s has %d zones, less than its %d replicas", lpool.Name, lpool.Zones, lpool.Replicas))
+		}
+		if zones != nil && len(zones) < lpool.Zones {
+			problems = append(problems, fmt.Sprintf("chunkservers of physical pool %s are in %d zones, logical pool %s needs %d",
+				lpool.PhysicalPool, len(zones), lpool.Name, lpool.Zones))
+		}
+		if lpool.Copysets <= 0 {
+			problems = append(problems, fmt.Sprintf("logical pool %s has no copyset", lpool.Name))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &TopologyError{Problems: problems}
+	}
+	return nil
+}
+
+// genZoneOfNode returns the function to get the zone of next chunkserver node according to the zone strategy
+func (c *Cluster) genZoneOfNode() (func(nodeName string) (string, error), error) {
+	topology := c.spec.Topology
+	if topology.ZoneStrategy != curvev1.ZoneStrategyNodeLabel {
+		zones := DEFAULT_ZONES_PER_POOL
+		if topology.Zones != 0 {
+			zones = topology.Zones
+		}
+		if zones < DEFAULT_REPLICAS_PER_COPYSET {
+			return nil, errors.Errorf("zones %d is less than replicas %d", zones, DEFAULT_REPLICAS_PER_COPYSET)
+		}
+		// the zone of a node is by its host sequence, so it's kept when the other nodes are added or removed
+		hostSequences := map[string]int{}
+		for _, csConfig := range c.chunkserverConfigs {
+			hostSequences[csConfig.NodeName] = csConfig.HostSequence
+		}
+		return func(nodeName string) (string, error) {
+			return fmt.Sprintf("zone%d", hostSequences[nodeName]%zones+1), nil
+		}, nil
+	}
+
+	label := DEFAULT_ZONE_LABEL
+	if topology.ZoneLabel != "" {
+		label = topology.ZoneLabel
+	}
+	nodes := map[string]struct{}{}
+	for _, csConfig := range c.chunkserverConfigs {
+		nodes[csConfig.NodeName] = struct{}{}
+	}
+	nodeZone := map[string]string{}
+	zoneSet := map[string]struct{}{}
+	for nodeName := range nodes {
+		node, err := c.context.Clientset.CoreV1().Nodes().Get(nodeName, metav1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get node %q", nodeName)
+		}
+		zone, ok := node.Labels[label]
+		if !ok || zone == "" {
+			return nil, errors.Errorf("node %q has no label %q to derive zone", nodeName, label)
+		}
+		nodeZone[nodeName] = zone
+		zoneSet[zone] = struct{}{}
+	}
+	if len(zoneSet) < DEFAULT_REPLICAS_PER_COPYSET {
+		return nil, errors.Errorf("nodes are in %d zones by label %q, at least %d zones are needed",
+			len(zoneSet), label, DEFAULT_REPLICAS_PER_COPYSET)
+	}
+	return func(nodeName string) (string, error) { return nodeZone[nodeName], nil }, nil
+}
+
+// genDeviceWeights returns the weights of devices in percentage of the smallest device,
+// nil is returned if any device has no capacity
+func (c *Cluster) genDeviceWeights() map[string]int {
+	var min int64
+	for _, device := range c.spec.Storage.Devices {
+		if device.Capacity == nil || device.Capacity.Value() <= 0 {
+			return nil
+		}
+		if min == 0 || device.Capacity.Value() < min {
+			min = device.Capacity.Value()
+		}
+	}
+
+	weights := map[string]int{}
+	for _, device := range c.spec.Storage.Devices {
+		weights[device.Name] = int(device.Capacity.Value() * 100 / min)
+	}
+	return weights
 }
 
 func formatName(dc *chunkserverConfig) string {
@@ -104,9 +267,9 @@ func formatName(dc *chunkserverConfig) string {
 }
 
 // we should sort the "dcs" for generate correct zone number
-func SortDeployConfigs() {
-	sort.Slice(chunkserverConfigs, func(i, j int) bool {
-		csServer1, csServer2 := chunkserverConfigs[i], chunkserverConfigs[j]
+func (c *Cluster) sortDeployConfigs() {
+	sort.Slice(c.chunkserverConfigs, func(i, j int) bool {
+		csServer1, csServer2 := c.chunkserverConfigs[i], c.chunkserverConfigs[j]
 
 		if csServer1.HostSequence == csServer2.HostSequence {
 			return csServer1.ReplicasSequence < csServer2.ReplicasSequence
@@ -116,26 +279,142 @@ func SortDeployConfigs() {
 	})
 }
 
+// autoCopysets returns the copysets of the chunkserver by the size of its chunk file pool and the size, ok is
+// false if the device has no capacity
+func (c *Cluster) autoCopysets(csConfig *chunkserverConfig) (copysets int, poolBytes int64, ok bool) {
+	for _, device := range c.spec.Storage.NodeDevices(csConfig.NodeName) {
+		if device.Name != csConfig.DeviceName {
+			continue
+		}
+		poolBytes = device.PoolBytes()
+	}
+	if poolBytes <= 0 {
+		return 0, 0, false
+	}
+
+	// the copysets of a pool of smaller chunks get the same number of chunks
+	copysets = int(poolBytes / csConfig.ChunkSize / AUTO_COPYSET_CHUNKS)
+	if copysets < AUTO_MIN_CHUNKSERVER_COPYSETS {
+		copysets = AUTO_MIN_CHUNKSERVER_COPYSETS
+	}
+	if copysets > AUTO_MAX_CHUNKSERVER_COPYSETS {
+		copysets = AUTO_MAX_CHUNKSERVER_COPYSETS
+	}
+	return copysets, poolBytes, true
+}
+
+// defaultPhysicalPool returns the physical pool of the devices not in storage.pools
+func defaultPhysicalPool(topology curvev1.TopologySpec) string {
+	if topology.PhysicalPoolName != "" {
+		return topology.PhysicalPoolName
+	}
+	return DEFAULT_PHYSICAL_POOL_NAME
+}
+
+// devicePool returns the logical pool, the physical pool and the chunk size of the chunkserver of the device
+func (c *Cluster) devicePool(deviceName string) (logicalPool, physicalPool string, chunkSize int64) {
+	pool := c.spec.Storage.PoolOf(deviceName)
+	if pool == nil {
+		return DEFAULT_LOGICAL_POOL_NAME, defaultPhysicalPool(c.spec.Topology), DEFAULT_CHUNKFILE_SIZE
+	}
+	return pool.Name, pool.Name, poolChunkSize(pool)
+}
+
+// poolChunkSize returns the chunk size of the pool, default is 16MiB
+func poolChunkSize(pool *curvev1.PoolSpec) int64 {
+	if pool.ChunkSize == nil {
+		return DEFAULT_CHUNKFILE_SIZE
+	}
+	return pool.ChunkSize.Value()
+}
+
+// validatePools checks the devices of storage.pools are in storage and in one pool only, the names of the pools
+// must not be the ones of the default pool
+func (c *Cluster) validatePools() error {
+	devices := map[string]bool{}
+	for _, device := range c.spec.Storage.Devices {
+		devices[device.Name] = true
+	}
+	for _, node := range c.spec.Storage.SelectedNodes {
+		for _, device := range node.Devices {
+			devices[device.Name] = true
+		}
+	}
+
+	names := map[string]bool{DEFAULT_LOGICAL_POOL_NAME: true, defaultPhysicalPool(c.spec.Topology): true}
+	poolOfDevice := map[string]string{}
+	for _, pool := range c.spec.Storage.Pools {
+		if pool.Name == "" {
+			return errors.New("a pool of storage.pools has no name")
+		}
+		if names[pool.Name] {
+			return errors.Errorf("pool %q is duplicated or is the name of the default pool", pool.Name)
+		}
+		names[pool.Name] = true
+		if len(pool.Devices) == 0 {
+			return errors.Errorf("pool %q has no device", pool.Name)
+		}
+		if pool.ChunkSize != nil && (pool.ChunkSize.Value() <= 0 || pool.ChunkSize.Value()%CHUNK_SIZE_ALIGNMENT != 0) {
+			return errors.Errorf("chunk size %s of pool %q is not a multiple of 4Ki", pool.ChunkSize.String(), pool.Name)
+		}
+		for _, device := range pool.Devices {
+			if !devices[device] {
+				return errors.Errorf("device %q of pool %q is not a device of storage", device, pool.Name)
+			}
+			if other, ok := poolOfDevice[device]; ok {
+				return errors.Errorf("device %q is in both pool %q and pool %q", device, other, pool.Name)
+			}
+			poolOfDevice[device] = pool.Name
+		}
+	}
+	return nil
+}
+
+// genNodeZones returns the zone of each chunkserver node, the chunkservers on a node are in the same zone in
+// all the pools
+func (c *Cluster) genNodeZones() (map[string]string, error) {
+	zoneOfNode, err := c.genZoneOfNode()
+	if err != nil {
+		return nil, err
+	}
+
+	// !important
+	c.sortDeployConfigs()
+
+	nodeZones := map[string]string{}
+	for _, csConfig := range c.chunkserverConfigs {
+		if _, ok := nodeZones[csConfig.NodeName]; ok {
+			continue
+		}
+		if nodeZones[csConfig.NodeName], err = zoneOfNode(csConfig.NodeName); err != nil {
+			return nil, err
+		}
+	}
+	return nodeZones, nil
+}
+
 // createLogicalPool
-func (c *Cluster) createLogicalPool(logicalPool string) (LogicalPool, []Server) {
-	var zone string
+func (c *Cluster) createLogicalPool(logicalPool, physicalPool string, chunkSize int64, nodeZones map[string]string) (LogicalPool, []Server) {
 	copysets := 0
 	servers := []Server{}
 	zones := DEFAULT_ZONES_PER_POOL
-	nextZone := genNextZone(zones)
-	physicalPool := logicalPool
 
 	// ensure the number of copysets on one node
-	copysetsPerChunkserver := DEFAULT_CHUNKSERVER_COPYSETS
+	copysetsPerChunkserver := config.GetOperatorSettings().ChunkserverCopysets
 	if c.spec.Storage.CopySets != 0 {
 		copysetsPerChunkserver = c.spec.Storage.CopySets
 	}
-	// !important
-	SortDeployConfigs()
+	weights := c.genDeviceWeights()
+	plan := curvev1.CopysetPlanStatus{
+		Pool:      logicalPool,
+		Auto:      c.spec.Storage.AutoCopySets,
+		ChunkSize: chunkSize,
+		Replicas:  DEFAULT_REPLICAS_PER_COPYSET,
+	}
 
-	for _, csConfig := range chunkserverConfigs {
-		if csConfig.ReplicasSequence == 0 {
-			zone = nextZone()
+	for _, csConfig := range c.chunkserverConfigs {
+		if csConfig.LogicalPool != logicalPool {
+			continue
 		}
 
 		// NOTE: if we deploy chunkservers with replica feature
@@ -157,22 +436,39 @@ func (c *Cluster) createLogicalPool(logicalPool string) (LogicalPool, []Server)
 			InternalPort: internalPort,
 			ExternalIp:   csConfig.NodeIP,
 			ExternalPort: externalPort,
-			Zone:         zone,
+			Zone:         nodeZones[csConfig.NodeName],
 		}
 
 		server.PhysicalPool = physicalPool
 
 		// copysets number ddefault value is 100
-		copysets += copysetsPerChunkserver
+		// the chunkservers of larger devices contribute more copysets if they are weighted
+		weight, weighted := weights[csConfig.DeviceName]
+		if weighted {
+			server.Weight = weight
+		}
+		// the copysets of storage.autoCopySets are proportional to the size of the pool without the weight
+		auto, poolBytes, ok := c.autoCopysets(&csConfig)
+		plan.PoolBytes += poolBytes
+		if ok && c.spec.Storage.AutoCopySets {
+			copysets += auto
+		} else if weighted {
+			copysets += copysetsPerChunkserver * weight / 100
+		} else {
+			copysets += copysetsPerChunkserver
+		}
 		servers = append(servers, server)
 
 	}
 
 	// copysets
+	plan.ChunkServerCopysets = copysets
 	copysets = copysets / DEFAULT_REPLICAS_PER_COPYSET
 	if copysets == 0 {
 		copysets = 1
 	}
+	plan.Copysets = copysets
+	c.copysetPlans = append(c.copysetPlans, plan)
 
 	// logical pool field in topology.json file
 	lpool := LogicalPool{
@@ -189,23 +485,57 @@ func (c *Cluster) createLogicalPool(logicalPool string) (LogicalPool, []Server)
 	return lpool, servers
 }
 
-func (c *Cluster) genClusterPool() string {
-	// create CurveClusterTopo object by call createLogicalPool
-	lpool, servers := c.createLogicalPool("pool1")
-	topo := CurveClusterTopo{Servers: servers, NPools: 1}
+func (c *Cluster) genClusterPool() (string, error) {
+	nodeZones, err := c.genNodeZones()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create logical pool")
+	}
+
+	// the default pool is left out if all the devices are in storage.pools
+	type pool struct {
+		logicalPool, physicalPool string
+		chunkSize                 int64
+	}
+	pools := []pool{{DEFAULT_LOGICAL_POOL_NAME, defaultPhysicalPool(c.spec.Topology), DEFAULT_CHUNKFILE_SIZE}}
+	if len(c.spec.Storage.Pools) > 0 {
+		pools = pools[:0]
+		for _, csConfig := range c.chunkserverConfigs {
+			if csConfig.LogicalPool == DEFAULT_LOGICAL_POOL_NAME {
+				pools = append(pools, pool{DEFAULT_LOGICAL_POOL_NAME, defaultPhysicalPool(c.spec.Topology), DEFAULT_CHUNKFILE_SIZE})
+				break
+			}
+		}
+		for i := range c.spec.Storage.Pools {
+			p := &c.spec.Storage.Pools[i]
+			pools = append(pools, pool{p.Name, p.Name, poolChunkSize(p)})
+		}
+	}
 
-	// curvebs
-	topo.LogicalPools = []LogicalPool{lpool}
+	// create CurveClusterTopo object by call createLogicalPool
+	c.copysetPlans = nil
+	topo := CurveClusterTopo{NPools: len(pools)}
+	for _, p := range pools {
+		lpool, servers := c.createLogicalPool(p.logicalPool, p.physicalPool, p.chunkSize, nodeZones)
+		topo.Servers = append(topo.Servers, servers...)
+		// curvebs
+		topo.LogicalPools = append(topo.LogicalPools, lpool)
+	}
+	// the topology is only a reference if it's not managed by the operator
+	if c.spec.Topology.IsManaged() {
+		if err := validateTopology(&topo); err != nil {
+			return "", err
+		}
+	}
 
 	// generate the topology.json
 	var bytes []byte
-	bytes, err := json.Marshal(topo)
+	bytes, err = json.Marshal(topo)
 	if err != nil {
-		return ""
+		return "", errors.Wrap(err, "failed to marshal topology")
 	}
 	clusterPoolJson := string(bytes)
 	logger.Info(clusterPoolJson)
-	return clusterPoolJson
+	return clusterPoolJson, nil
 }
 
 func (c *Cluster) getRegisterJobLabel(poolType string) map[string]string {
@@ -0,0 +1,251 @@
+package chunkserver
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver/script"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+const (
+	preflightJobNameFormat = "curve-chunkserver-preflight-%s"
+	preflightTimeout       = 10 * time.Minute
+	preflightInterval      = 5 * time.Second
+)
+
+// PreflightError is returned if the pre-flight checks fail on some nodes, it maps the node name
+// to the failures on it.
+type PreflightError struct {
+	Results map[string]string
+}
+
+func (e *PreflightError) Error() string {
+	var nodes []string
+	for nodeName := range e.Results {
+		nodes = append(nodes, nodeName)
+	}
+	sort.Strings(nodes)
+
+	var results []string
+	for _, nodeName := range nodes {
+		results = append(results, fmt.Sprintf("%s: %s", nodeName, strings.TrimSpace(e.Results[nodeName])))
+	}
+	return "pre-flight checks failed on nodes " + strings.Join(results, " ")
+}
+
+// ClusterErrors returns a NodeFailure for each of the failed nodes
+func (e *PreflightError) ClusterErrors() []curvev1.ClusterError {
+	var nodes []string
+	for nodeName := range e.Results {
+		nodes = append(nodes, nodeName)
+	}
+	sort.Strings(nodes)
+
+	var errs []curvev1.ClusterError
+	for _, nodeName := range nodes {
+		errs = append(errs, curvev1.ClusterError{
+			Category: curvev1.ErrorCategoryNodeFailure,
+			Node:     nodeName,
+			Message:  strings.TrimSpace(e.Results[nodeName]),
+		})
+	}
+	return errs
+}
+
+// runPreflightChecks runs a check job on each node for the devices that will be formatted on it, and
+// waits for all of them. No device is formatted unless the checks pass on all nodes.
+func (c *Cluster) runPreflightChecks(nodeDevices map[string][]int) error {
+	jobs := map[string]*batch.Job{}
+	for nodeName, indexes := range nodeDevices {
+		job, err := c.makePreflightJob(nodeName, indexes)
+		if err != nil {
+			return err
+		}
+
+		err = k8sutil.RunReplaceableJob(context.TODO(), c.context.Clientset, job, true)
+		if err != nil {
+			return errors.Wrapf(err, "failed to run pre-flight job %s", job.Name)
+		}
+		logger.Infof("created pre-flight job %s on node %s", job.Name, nodeName)
+		jobs[nodeName] = job
+	}
+
+	results := map[string]string{}
+	for nodeName, job := range jobs {
+		result, err := c.waitForPreflightJob(job)
+		if err != nil {
+			results[nodeName] = err.Error()
+			continue
+		}
+		if result != "" {
+			results[nodeName] = result
+		}
+	}
+	if len(results) > 0 {
+		return &PreflightError{Results: results}
+	}
+
+	logger.Infof("pre-flight checks passed on %d nodes", len(jobs))
+	return nil
+}
+
+// waitForPreflightJob returns the failures reported by the job, it is empty if the job succeeded
+func (c *Cluster) waitForPreflightJob(job *batch.Job) (string, error) {
+	var finished *batch.Job
+	err := wait.PollImmediate(preflightInterval, preflightTimeout, func() (bool, error) {
+		j, err := c.context.Clientset.BatchV1().Jobs(job.Namespace).Get(job.Name, metav1.GetOptions{})
+		if err != nil {
+			logger.Warningf("failed to get pre-flight job %s. %v", job.Name, err)
+			return false, nil
+		}
+		if j.Status.Succeeded > 0 || j.Status.Failed > 0 {
+			finished = j
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		return "", errors.Errorf("pre-flight job %s is not finished in %s", job.Name, preflightTimeout)
+	}
+	if finished.Status.Succeeded > 0 {
+		return "", nil
+	}
+
+	message, err := k8sutil.GetJobTerminationMessage(c.context.Clientset, job)
+	if err != nil {
+		return "", err
+	}
+	if message != "" {
+		return message, nil
+	}
+
+	return fmt.Sprintf("pre-flight job %s failed, see the logs of its pod", job.Name), nil
+}
+
+func (c *Cluster) makePreflightJob(nodeName string, indexes []int) (*batch.Job, error) {
+	jobName := k8sutil.TruncateNodeNameForJob(preflightJobNameFormat, nodeName)
+	labels := map[string]string{
+		"app":           AppName,
+		"preflight":     nodeName,
+		"curve_cluster": c.namespacedName.Namespace,
+	}
+
+	var ports []string
+	var devices []string
+	for _, i := range indexes {
+		device := c.nodeDevices[nodeName][i]
+		ports = append(ports, strconv.Itoa(c.bindings[inventoryKey(nodeName, device.Name)].Port))
+		name := device.Name
+		if device.IsLoop() {
+			name = path.Join(c.loopHostDir(), device.Name)
+		}
+		devices = append(devices, fmt.Sprintf("%s,%s,%s", name, device.Type, device.GetFilesystem()))
+		if device.CacheDevice != "" {
+			devices = append(devices, fmt.Sprintf("%s,cache,", device.CacheDevice))
+		}
+	}
+	sysctls, err := c.sysctls()
+	if err != nil {
+		return nil, err
+	}
+	settings := config.GetOperatorSettings()
+	hostDirs := []string{c.dataDirHostPath, c.logDirHostPath, c.confDirHostPath}
+	systemDirs := []string{settings.HostDevDir, settings.HostCgroupDir, settings.HostModulesDir}
+	args := []string{"-c", script.PREFLIGHT, "preflight", strings.Join(hostDirs, ","), strings.Join(ports, ","),
+		strconv.FormatBool(c.spec.Storage.AllowDeviceReformat), sysctls, strings.Join(systemDirs, ","), settings.HostModulesDir}
+	args = append(args, devices...)
+
+	privileged := true
+	runAsUser := int64(0)
+	backoffLimit := int32(0)
+
+	podSpec := v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   jobName,
+			Labels: labels,
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:            "preflight",
+					Command:         []string{"/bin/bash"},
+					Args:            args,
+					Image:           c.spec.CurveVersion.Image,
+					ImagePullPolicy: c.spec.CurveVersion.ImagePullPolicy,
+					VolumeMounts: []v1.VolumeMount{
+						{Name: "rootfs", MountPath: "/rootfs"},
+						{Name: "devices", MountPath: "/dev"},
+					},
+					SecurityContext: &v1.SecurityContext{
+						Privileged: &privileged,
+						RunAsUser:  &runAsUser,
+					},
+				},
+			},
+			NodeName:      nodeName,
+			RestartPolicy: v1.RestartPolicyNever,
+			HostNetwork:   true,
+			HostPID:       true,
+			DNSPolicy:     v1.DNSClusterFirstWithHostNet,
+			Volumes: []v1.Volume{
+				{Name: "rootfs", VolumeSource: v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: "/"}}},
+				{Name: "devices", VolumeSource: v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: settings.HostDevDir}}},
+			},
+		},
+	}
+	k8sutil.InjectEnv(&podSpec.Spec, c.spec, "chunkserver")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, c.spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, c.spec)
+	k8sutil.InjectDNS(&podSpec.Spec, c.spec, "chunkserver")
+
+	job := &batch.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jobName,
+			Namespace: c.namespacedName.Namespace,
+			Labels:    labels,
+		},
+		Spec: batch.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template:     podSpec,
+		},
+	}
+
+	k8sutil.InjectMetadata(c.spec, "chunkserver", job, &job.Spec.Template)
+	err = c.ownerInfo.SetControllerReference(job)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to set owner reference to pre-flight job %q", job.Name)
+	}
+
+	return job, nil
+}
+
+// devicesToFormat returns the indexes of the devices on the node that are neither formatted
+// nor being formatted
+func (c *Cluster) devicesToFormat(nodeName string, formatted map[string]DeviceRecord) []int {
+	var indexes []int
+	for i, device := range c.nodeDevices[nodeName] {
+		if _, ok := formatted[inventoryKey(nodeName, device.Name)]; ok {
+			continue
+		}
+		_, err := c.context.Clientset.BatchV1().Jobs(c.namespacedName.Namespace).Get(prepareJobName(nodeName, device.Name), metav1.GetOptions{})
+		if err == nil {
+			continue
+		}
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
@@ -0,0 +1,123 @@
+package chunkserver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultProfileSeconds = 30
+	maxProfileSeconds     = 300
+	// MaxProfileSize keeps the profile under the size limit of configmap
+	MaxProfileSize = 900 * 1024
+)
+
+// profileType is a profiler of brpc served by chunkserver on its listen port
+type profileType struct {
+	// path is the page of the profiler, the profile is collected in the seconds of the query
+	path string
+	// flags are the reloadable flags enabled during the profile, they are set to the values after it
+	flags map[string][2]string
+}
+
+// profileTypes are the profiles that can be collected from chunkserver. cpu, heap and growth need the
+// chunkserver linked with tcmalloc, contention is of the locks, and rpcz is the traces of the io requests.
+var profileTypes = map[string]profileType{
+	"cpu":        {path: "/hotspots/cpu"},
+	"heap":       {path: "/hotspots/heap"},
+	"growth":     {path: "/hotspots/growth"},
+	"contention": {path: "/hotspots/contention"},
+	"rpcz": {
+		path:  "/rpcz",
+		flags: map[string][2]string{"enable_rpcz": {"true", "false"}},
+	},
+}
+
+// ProfileRequest is a profile requested by the profile annotation
+type ProfileRequest struct {
+	Type    string
+	Seconds int
+}
+
+// ParseProfileRequest parses the profile in the form of '<type>[:<seconds>]'
+func ParseProfileRequest(value string) (ProfileRequest, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) > 2 {
+		return ProfileRequest{}, errors.Errorf("invalid profile %q, it should be <type>[:<seconds>]", value)
+	}
+	if _, ok := profileTypes[parts[0]]; !ok {
+		return ProfileRequest{}, errors.Errorf("unknown profile type %q, it should be cpu, heap, growth, contention or rpcz", parts[0])
+	}
+	request := ProfileRequest{Type: parts[0], Seconds: defaultProfileSeconds}
+	if len(parts) == 2 {
+		seconds, err := strconv.Atoi(parts[1])
+		if err != nil || seconds <= 0 || seconds > maxProfileSeconds {
+			return ProfileRequest{}, errors.Errorf("invalid profile seconds %q, it should be 1 to %d", parts[1], maxProfileSeconds)
+		}
+		request.Seconds = seconds
+	}
+	return request, nil
+}
+
+// CollectProfile collects the profile from the chunkserver on the addr ip:port. The flags of the profiler are
+// enabled before it and reverted after it, so the chunkserver is not edited or restarted.
+func CollectProfile(addr string, request ProfileRequest) ([]byte, error) {
+	profile := profileTypes[request.Type]
+	client := &http.Client{Timeout: time.Duration(request.Seconds)*time.Second + 30*time.Second}
+
+	for name, values := range profile.flags {
+		if err := setProfileFlag(client, addr, name, values[0]); err != nil {
+			return nil, err
+		}
+		defer func(name, value string) {
+			if err := setProfileFlag(client, addr, name, value); err != nil {
+				logger.Warningf("failed to revert flag %q of chunkserver %s after profile. %v", name, addr, err)
+			}
+		}(name, values[1])
+	}
+
+	u := fmt.Sprintf("http://%s%s?seconds=%d", addr, profile.path, request.Seconds)
+	if len(profile.flags) > 0 {
+		// the traces are recorded from now on, the page shows the recorded ones at once
+		time.Sleep(time.Duration(request.Seconds) * time.Second)
+		u = fmt.Sprintf("http://%s%s", addr, profile.path)
+	}
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get %s", u)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", u)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("failed to get %s, status %s: %s", u, resp.Status, strings.TrimSpace(string(body)))
+	}
+	if len(body) > MaxProfileSize {
+		return nil, errors.Errorf("profile of %d bytes is larger than %d bytes, try fewer seconds", len(body), MaxProfileSize)
+	}
+	return body, nil
+}
+
+// setProfileFlag sets the flag of chunkserver by the flags service of brpc
+func setProfileFlag(client *http.Client, addr, name, value string) error {
+	u := fmt.Sprintf("http://%s/flags/%s?setvalue=%s", addr, url.PathEscape(name), url.QueryEscape(value))
+	resp, err := client.Get(u)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get %s", u)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return errors.Errorf("failed to set flag %q, status %s: %s", name, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
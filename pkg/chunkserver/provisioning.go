@@ -0,0 +1,182 @@
+package chunkserver
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// the phases of provisioning the chunkservers in the metric of their durations
+const (
+	phaseFormat       = "format"
+	phasePhysicalPool = "physical_pool"
+	phaseChunkservers = "chunkservers"
+	phaseLogicalPool  = "logical_pool"
+)
+
+var provisioningPhaseSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name: "curve_provisioning_phase_duration_seconds",
+	Help: "Seconds spent in each phase of provisioning the chunkservers of curve cluster",
+	// from 10 seconds up to about 11 hours for formatting the chunk file pools of large devices
+	Buckets: prometheus.ExponentialBuckets(10, 2, 13),
+}, []string{"namespace", "cluster", "phase"})
+
+func init() {
+	metrics.Registry.MustRegister(provisioningPhaseSeconds)
+}
+
+// provisioningTimer records when each phase of provisioning the chunkservers ends in status and the time spent
+// in it in metrics. It only records the reconciles that format devices, the ones that find all the devices
+// formatted return in seconds and would hide the timing of the provisioning.
+type provisioningTimer struct {
+	c         *Cluster
+	enabled   bool
+	lastPhase time.Time
+	status    curvev1.ProvisioningStatus
+}
+
+// startProvisioningTimer starts the timer when the format jobs are created
+func (c *Cluster) startProvisioningTimer() *provisioningTimer {
+	now := time.Now()
+	t := &provisioningTimer{
+		c:         c,
+		enabled:   len(c.job2DeviceInfos) > 0 || len(c.queuedFormats) > 0,
+		lastPhase: now,
+	}
+	if !t.enabled {
+		return t
+	}
+	startedAt := metav1.NewTime(now)
+	t.status.FormatStartedAt = &startedAt
+	if err := t.update(); err != nil {
+		logger.Warningf("failed to update provisioning status. %v", err)
+	}
+	return t
+}
+
+// phaseDone records the end of the phase, the time is set to the field of status if it's not nil
+func (t *provisioningTimer) phaseDone(phase string, field **metav1.Time) {
+	if !t.enabled {
+		return
+	}
+	now := time.Now()
+	provisioningPhaseSeconds.WithLabelValues(t.c.namespacedName.Namespace, t.c.namespacedName.Name, phase).
+		Observe(now.Sub(t.lastPhase).Seconds())
+	t.lastPhase = now
+	if field == nil {
+		return
+	}
+	doneAt := metav1.NewTime(now)
+	*field = &doneAt
+	if err := t.update(); err != nil {
+		logger.Warningf("failed to update provisioning status. %v", err)
+	}
+}
+
+func (t *provisioningTimer) update() error {
+	clusterObj := &curvev1.CurveCluster{}
+	if err := t.c.context.Client.Get(context.TODO(), t.c.namespacedName, clusterObj); err != nil {
+		return errors.Wrapf(err, "failed to get curvecluster %q", t.c.namespacedName)
+	}
+	clusterObj.Status.Provisioning = t.status.DeepCopy()
+	return k8sutil.UpdateStatus(t.c.context.Client, t.c.namespacedName, clusterObj)
+}
+
+// updateDeviceProvisioning records the state of the format job of each device in status, so that the devices
+// still formatting and the failed ones are known after the operator restarts. The records of the devices
+// formatted by the previous reconciles are kept unless the devices are removed from the spec.
+func (c *Cluster) updateDeviceProvisioning() error {
+	clusterObj := &curvev1.CurveCluster{}
+	if err := c.context.Client.Get(context.TODO(), c.namespacedName, clusterObj); err != nil {
+		return errors.Wrapf(err, "failed to get curvecluster %q", c.namespacedName)
+	}
+
+	tracked := map[string]bool{}
+	var devices []curvev1.DeviceProvisioningStatus
+	for _, info := range c.job2DeviceInfos {
+		tracked[inventoryKey(info.nodeName, info.device.Name)] = true
+		devices = append(devices, jobProvisioningStatus(info))
+	}
+	for _, info := range c.queuedFormats {
+		tracked[inventoryKey(info.nodeName, info.device.Name)] = true
+		devices = append(devices, curvev1.DeviceProvisioningStatus{
+			NodeName: info.nodeName,
+			Device:   info.device.Name,
+			State:    curvev1.DeviceProvisioningQueued,
+		})
+	}
+	for _, existing := range clusterObj.Status.DeviceProvisioning {
+		if !tracked[inventoryKey(existing.NodeName, existing.Device)] && c.hasDevice(existing.NodeName, existing.Device) {
+			devices = append(devices, existing)
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		if devices[i].NodeName != devices[j].NodeName {
+			return devices[i].NodeName < devices[j].NodeName
+		}
+		return devices[i].Device < devices[j].Device
+	})
+
+	if equality.Semantic.DeepEqual(devices, clusterObj.Status.DeviceProvisioning) {
+		return nil
+	}
+	clusterObj.Status.DeviceProvisioning = devices
+	return k8sutil.UpdateStatus(c.context.Client, c.namespacedName, clusterObj)
+}
+
+// jobProvisioningStatus returns the state of the device by its format job, the status of the job must be
+// refreshed before
+func jobProvisioningStatus(info *Job2DeviceInfo) curvev1.DeviceProvisioningStatus {
+	job := info.job
+	status := curvev1.DeviceProvisioningStatus{
+		NodeName:  info.nodeName,
+		Device:    info.device.Name,
+		JobName:   job.Name,
+		State:     curvev1.DeviceProvisioningFormatting,
+		StartedAt: job.Status.StartTime,
+	}
+	if job.Status.Succeeded > 0 {
+		status.State = curvev1.DeviceProvisioningCompleted
+		status.CompletedAt = job.Status.CompletionTime
+		return status
+	}
+	if condition := jobFailedCondition(job); condition != nil {
+		status.State = curvev1.DeviceProvisioningFailed
+		status.CompletedAt = &condition.LastTransitionTime
+		status.Message = condition.Message
+	}
+	return status
+}
+
+// jobFailedCondition returns the JobFailed condition of the job if it failed for good, such as its pods failed more
+// times than the backoff limit, or nil if it's running or succeeded
+func jobFailedCondition(job *batch.Job) *batch.JobCondition {
+	for i := range job.Status.Conditions {
+		condition := &job.Status.Conditions[i]
+		if condition.Type == batch.JobFailed && condition.Status == v1.ConditionTrue {
+			return condition
+		}
+	}
+	return nil
+}
+
+// hasDevice returns true if the device of the node is in the spec
+func (c *Cluster) hasDevice(nodeName, deviceName string) bool {
+	for _, device := range c.nodeDevices[nodeName] {
+		if device.Name == deviceName {
+			return true
+		}
+	}
+	return false
+}
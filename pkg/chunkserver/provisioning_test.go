@@ -0,0 +1,875 @@
+package chunkserver
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver/script"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/curvetool"
+	"github.com/opencurve/curve-operator/pkg/internal/testutil"
+)
+
+func testSpec() *curvev1.CurveClusterSpec {
+	return &curvev1.CurveClusterSpec{
+		CurveVersion: curvev1.CurveVersionSpec{Image: "opencurvedocker/curvebs:v1.2"},
+		Nodes:        []string{"node1", "node2", "node3"},
+		Mds:          curvev1.MdsSpec{Port: 6700, DummyPort: 7700},
+		Storage: curvev1.StorageScopeSpec{
+			Nodes: []string{"node1", "node2", "node3"},
+			Port:  8200,
+			Devices: []curvev1.DevicesSpec{
+				{Name: "/dev/vdb", MountPath: "/data/chunkserver0", Percentage: 80},
+				{Name: "/dev/vdc", MountPath: "/data/chunkserver1", Percentage: 80},
+			},
+		},
+	}
+}
+
+func findClusterCondition(cluster *curvev1.CurveCluster, conditionType curvev1.ConditionType) *curvev1.ClusterCondition {
+	for i := range cluster.Status.Conditions {
+		if cluster.Status.Conditions[i].Type == conditionType {
+			return &cluster.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+func TestProvisioningFlow(t *testing.T) {
+	env := newFakeEnv(t, testSpec())
+	if err := env.start(); err != nil {
+		t.Fatalf("failed to provision chunkservers: %v", err)
+	}
+
+	// the devices are formatted after the pre-flight checks, the chunkservers are started on the physical pool and
+	// the logical pool is created on the chunkservers
+	if preflights := env.createdWithPrefix("Job/curve-chunkserver-preflight-"); len(preflights) != 3 {
+		t.Errorf("expected a pre-flight job on each node, got %v", preflights)
+	}
+	if jobs := env.createdWithPrefix("Job/" + PrepareJobName); len(jobs) != 6 {
+		t.Errorf("expected a prepare job for each device, got %v", jobs)
+	}
+	if deployments := env.createdWithPrefix("Deployment/" + AppName); len(deployments) != 6 {
+		t.Errorf("expected a chunkserver for each device, got %v", deployments)
+	}
+	services, err := env.clientset.CoreV1().Services(testNamespace).List(metav1.ListOptions{LabelSelector: "app=" + AppName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services.Items) != 6 || services.Items[0].Spec.Ports[0].Name != MetricsPortName {
+		t.Errorf("expected a service with the metrics port for each chunkserver, got %+v", services.Items)
+	}
+	steps := []string{
+		"Job/curve-chunkserver-preflight-",
+		"Job/" + PrepareJobName,
+		"Job/gen-physical-pool",
+		"Deployment/" + AppName,
+		"Job/gen-logical-pool",
+	}
+	for i := 1; i < len(steps); i++ {
+		if env.indexOf(steps[i]) < 0 || env.lastIndexOf(steps[i-1]) > env.indexOf(steps[i]) {
+			t.Fatalf("expected all of %s created before %s, got %v", steps[i-1], steps[i], env.created)
+		}
+	}
+
+	records, err := env.newCluster().loadInventory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 6 {
+		t.Errorf("expected the formatted devices recorded in the inventory, got %v", records)
+	}
+	info, err := config.GetClusterInfo(&env.context, testNamespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.ChunkServerAddrs) != 6 || info.ChunkServerAddrs[0] != "10.0.0.1:8200" {
+		t.Errorf("expected the endpoints of chunkservers recorded in the cluster info, got %v", info.ChunkServerAddrs)
+	}
+
+	cluster := env.getCluster()
+	for _, conditionType := range []curvev1.ConditionType{curvev1.ConditionTypeFormatedReady, curvev1.ConditionTypeChunkServerReady} {
+		condition := findClusterCondition(cluster, conditionType)
+		if condition == nil || condition.Status != curvev1.ConditionTrue {
+			t.Errorf("expected condition %s True, got %+v", conditionType, condition)
+		}
+	}
+	if len(cluster.Status.DeviceProvisioning) != 6 {
+		t.Fatalf("expected the format job of each device recorded in status, got %+v", cluster.Status.DeviceProvisioning)
+	}
+	for _, device := range cluster.Status.DeviceProvisioning {
+		if device.State != curvev1.DeviceProvisioningCompleted || !strings.HasPrefix(device.JobName, PrepareJobName) {
+			t.Errorf("expected the format job of device completed, got %+v", device)
+		}
+	}
+}
+
+func TestProvisioningFlowSkipsFormattedDevices(t *testing.T) {
+	env := newFakeEnv(t, testSpec())
+	if err := env.start(); err != nil {
+		t.Fatalf("failed to provision chunkservers: %v", err)
+	}
+	formatted := len(env.createdWithPrefix("Job/" + PrepareJobName))
+
+	// a device is added to the spec, only it is formatted and its chunkserver started
+	env.cluster.Spec.Storage.Devices = append(env.cluster.Spec.Storage.Devices,
+		curvev1.DevicesSpec{Name: "/dev/vdd", MountPath: "/data/chunkserver2", Percentage: 80})
+	if err := env.start(); err != nil {
+		t.Fatalf("failed to provision chunkservers again: %v", err)
+	}
+	jobs := env.createdWithPrefix("Job/" + PrepareJobName)[formatted:]
+	if len(jobs) != 3 {
+		t.Fatalf("expected only the new device formatted on each node, got %v", jobs)
+	}
+	for _, job := range jobs {
+		if !strings.HasSuffix(job, "-vdd") {
+			t.Errorf("expected the formatted devices not formatted again, got %s", job)
+		}
+	}
+	if deployments := env.createdWithPrefix("Deployment/" + AppName); len(deployments) != 9 {
+		t.Errorf("expected the chunkservers of the new device started, got %v", deployments)
+	}
+	if devices := env.getCluster().Status.DeviceProvisioning; len(devices) != 9 {
+		t.Errorf("expected the devices formatted before kept in status, got %+v", devices)
+	}
+}
+
+func TestProvisioningFlowStableBinding(t *testing.T) {
+	env := newFakeEnv(t, testSpec())
+	if err := env.start(); err != nil {
+		t.Fatalf("failed to provision chunkservers: %v", err)
+	}
+	topologyServers := func() map[string]Server {
+		cm, err := env.context.Clientset.CoreV1().ConfigMaps(testNamespace).Get(config.TopoJsonConfigMapName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var topo CurveClusterTopo
+		if err := json.Unmarshal([]byte(cm.Data[config.TopoJsonConfigmapDataKey]), &topo); err != nil {
+			t.Fatal(err)
+		}
+		servers := map[string]Server{}
+		for _, server := range topo.Servers {
+			servers[server.Name] = server
+		}
+		return servers
+	}
+	bound, err := env.newCluster().loadInventory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	servers := topologyServers()
+
+	// the nodes and devices are reordered and a device is added before the others
+	spec := env.cluster.Spec
+	spec.Storage.Nodes = []string{"node3", "node2", "node1"}
+	spec.Storage.Devices = []curvev1.DevicesSpec{
+		{Name: "/dev/vda", MountPath: "/data/chunkserver2", Percentage: 80},
+		spec.Storage.Devices[1],
+		spec.Storage.Devices[0],
+	}
+	if err := env.start(); err != nil {
+		t.Fatalf("failed to provision chunkservers again: %v", err)
+	}
+	records, err := env.newCluster().loadInventory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, r := range bound {
+		if records[key].Port != r.Port || *records[key].HostSequence != *r.HostSequence || *records[key].ReplicasSequence != *r.ReplicasSequence {
+			t.Errorf("expected the chunkserver of %s kept port %d and sequences %d/%d, got %+v", key, r.Port,
+				*r.HostSequence, *r.ReplicasSequence, records[key])
+		}
+	}
+	if r := records[inventoryKey("node1", "/dev/vda")]; r.Port != 8202 || r.ReplicasSequence == nil || *r.ReplicasSequence != 2 {
+		t.Errorf("expected the new device bound to the free port and sequence, got %+v", r)
+	}
+	moved := topologyServers()
+	for name, server := range servers {
+		if moved[name].Zone != server.Zone {
+			t.Errorf("expected chunkserver %s kept in %s, got %+v", name, server.Zone, moved[name])
+		}
+	}
+}
+
+func TestProvisioningFlowFormatOrder(t *testing.T) {
+	spec := testSpec()
+	spec.Storage.FormatOrder = []string{"node3:/dev/vdc", "node2"}
+	spec.Storage.MaxConcurrentFormats = 1
+	env := newFakeEnv(t, spec)
+	if err := env.start(); err != nil {
+		t.Fatalf("failed to provision chunkservers: %v", err)
+	}
+
+	jobs := env.createdWithPrefix("Job/" + PrepareJobName)
+	expected := []string{"node3-vdc", "node2-vdb", "node2-vdc", "node1-vdb", "node1-vdc", "node3-vdb"}
+	if len(jobs) != len(expected) {
+		t.Fatalf("expected %d prepare jobs, got %v", len(expected), jobs)
+	}
+	for i, job := range jobs {
+		if job != "Job/"+PrepareJobName+"-"+expected[i] {
+			t.Errorf("expected prepare job %d of %s, got %s", i, expected[i], job)
+		}
+	}
+}
+
+func TestProvisioningFlowFormatFailure(t *testing.T) {
+	spec := testSpec()
+	spec.Storage.MaxConcurrentFormats = 1
+	env := newFakeEnv(t, spec)
+	failedJob := prepareJobName("node1", "/dev/vdb")
+	env.runJob = func(job *batch.Job) bool {
+		return job.Name != failedJob
+	}
+
+	// the failed job doesn't hold the slot, the error of it is returned instead of waiting for it
+	err := env.start()
+	if err == nil || !strings.Contains(err.Error(), failedJob) {
+		t.Fatalf("expected the provisioning failed by job %s, got %v", failedJob, err)
+	}
+	if jobs := env.createdWithPrefix("Job/" + PrepareJobName); len(jobs) != 6 {
+		t.Errorf("expected the queued jobs started after the failed one, got %v", jobs)
+	}
+}
+
+func TestProvisioningFlowPreflightFailure(t *testing.T) {
+	env := newFakeEnv(t, testSpec())
+	env.runJob = func(job *batch.Job) bool {
+		return job.Name != "curve-chunkserver-preflight-node2"
+	}
+
+	err := env.start()
+	if err == nil {
+		t.Fatal("expected the provisioning failed by the pre-flight checks")
+	}
+	preflightErr, ok := errors.Cause(err).(*PreflightError)
+	if !ok {
+		t.Fatalf("expected a PreflightError, got %v", err)
+	}
+	errs := preflightErr.ClusterErrors()
+	if len(errs) != 1 || errs[0].Node != "node2" || errs[0].Category != curvev1.ErrorCategoryNodeFailure {
+		t.Errorf("expected a NodeFailure of node2, got %+v of %v", errs, err)
+	}
+	if jobs := env.createdWithPrefix("Job/" + PrepareJobName); len(jobs) != 0 {
+		t.Errorf("expected no device formatted, got %v", jobs)
+	}
+}
+
+func TestProvisioningFlowWaitMdsLeader(t *testing.T) {
+	env := newFakeEnv(t, testSpec())
+	// the mds pod is a follower, no leader is elected
+	_, env.cluster.Spec.Mds.DummyPort = testutil.SplitAddr(t, testutil.NewVarsServer(t, "mds_status : follower\n"))
+	timeout := mdsLeaderTimeout
+	mdsLeaderTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { mdsLeaderTimeout = timeout })
+	interval := mdsLeaderInterval
+	mdsLeaderInterval = 10 * time.Millisecond
+	t.Cleanup(func() { mdsLeaderInterval = interval })
+
+	if err := env.start(); err == nil {
+		t.Fatal("expected the provisioning failed without mds leader")
+	}
+	condition := findClusterCondition(env.getCluster(), curvev1.ConditionTypeMdsLeaderReady)
+	if condition == nil || condition.Status != curvev1.ConditionFalse || condition.Reason != curvev1.ConditionMdsLeaderTimeoutReason {
+		t.Errorf("expected condition MdsLeaderReady timed out, got %+v", condition)
+	}
+	if jobs := env.createdWithPrefix("Job/gen-"); len(jobs) != 0 {
+		t.Errorf("expected no pool created, got %v", jobs)
+	}
+	if deployments := env.createdWithPrefix("Deployment/" + AppName); len(deployments) != 0 {
+		t.Errorf("expected no chunkserver started, got %v", deployments)
+	}
+}
+
+func TestProvisioningFlowWaitChunkServersRegistered(t *testing.T) {
+	env := newFakeEnv(t, testSpec())
+	interval := registrationInterval
+	registrationInterval = 10 * time.Millisecond
+	t.Cleanup(func() { registrationInterval = interval })
+	// the chunkserver of the last node registers on the third poll
+	polls := 0
+	listRegisteredChunkServers = func(c *Cluster) ([]curvetool.ChunkServer, error) {
+		chunkservers, _ := registeredChunkServers(c)
+		if polls++; polls < 3 {
+			chunkservers = chunkservers[:len(chunkservers)-1]
+		}
+		return chunkservers, nil
+	}
+
+	if err := env.start(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if polls != 3 {
+		t.Errorf("expected the registration polled 3 times, got %d", polls)
+	}
+	if env.indexOf("Job/gen-logical-pool") < env.lastIndexOf("Deployment/"+AppName) {
+		t.Errorf("expected the logical pool created after the chunkservers started, got %v", env.created)
+	}
+	zones := env.getCluster().Status.ChunkServerRegistration
+	if len(zones) == 0 {
+		t.Fatal("expected the registration of the zones in status")
+	}
+	for _, zone := range zones {
+		if zone.Registered != zone.Expected {
+			t.Errorf("expected all chunkservers of zone %s registered, got %+v", zone.Zone, zone)
+		}
+	}
+
+	// the logical pool is not created if the chunkservers don't all register
+	env = newFakeEnv(t, testSpec())
+	timeout := registrationTimeout
+	registrationTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { registrationTimeout = timeout })
+	listRegisteredChunkServers = func(c *Cluster) ([]curvetool.ChunkServer, error) {
+		chunkservers, _ := registeredChunkServers(c)
+		return chunkservers[1:], nil
+	}
+	if err := env.start(); err == nil {
+		t.Fatal("expected the provisioning failed without all chunkservers registered")
+	}
+	condition := findClusterCondition(env.getCluster(), curvev1.ConditionTypeLogicalPoolReady)
+	if condition == nil || condition.Status != curvev1.ConditionFalse || condition.Reason != curvev1.ConditionChunkServersRegisterTimeoutReason {
+		t.Errorf("expected condition LogicalPoolReady timed out, got %+v", condition)
+	}
+	if jobs := env.createdWithPrefix("Job/gen-logical-pool"); len(jobs) != 0 {
+		t.Errorf("expected no logical pool created, got %v", jobs)
+	}
+}
+
+func TestProvisioningFlowRetryCreatePool(t *testing.T) {
+	env := newFakeEnv(t, testSpec())
+	failed := false
+	env.runJob = func(job *batch.Job) bool {
+		if job.Name == "gen-logical-pool" && !failed {
+			failed = true
+			return false
+		}
+		return true
+	}
+
+	err := env.start()
+	if _, ok := errors.Cause(err).(*PoolCreationError); !ok {
+		t.Fatalf("expected a PoolCreationError, got %v", err)
+	}
+	condition := findClusterCondition(env.getCluster(), curvev1.ConditionTypeLogicalPoolReady)
+	if condition == nil || condition.Status != curvev1.ConditionFalse || condition.Reason != curvev1.ConditionPoolCreationFailedReason {
+		t.Errorf("expected condition LogicalPoolReady failed, got %+v", condition)
+	}
+	if _, err := env.clientset.BatchV1().Jobs(testNamespace).Get("gen-logical-pool", metav1.GetOptions{}); err == nil {
+		t.Error("expected the failed job deleted to be generated again")
+	}
+
+	// the job is generated again by the next reconcile
+	if err := env.start(); err != nil {
+		t.Fatalf("failed to provision chunkservers again: %v", err)
+	}
+	if jobs := env.createdWithPrefix("Job/gen-logical-pool"); len(jobs) != 2 {
+		t.Errorf("expected the logical pool job created again, got %v", jobs)
+	}
+	for _, conditionType := range []curvev1.ConditionType{curvev1.ConditionTypePhysicalPoolReady, curvev1.ConditionTypeLogicalPoolReady} {
+		condition := findClusterCondition(env.getCluster(), conditionType)
+		if condition == nil || condition.Status != curvev1.ConditionTrue {
+			t.Errorf("expected condition %s True, got %+v", conditionType, condition)
+		}
+	}
+}
+
+func TestProvisioningFlowAutoCopySets(t *testing.T) {
+	spec := testSpec()
+	spec.Storage.AutoCopySets = true
+	// a pool of 4Ti gets 102 copysets, and the device without capacity gets the default
+	capacity := resource.MustParse("5Ti")
+	spec.Storage.Devices[0].Capacity = &capacity
+	env := newFakeEnv(t, spec)
+	if err := env.start(); err != nil {
+		t.Fatalf("failed to provision chunkservers: %v", err)
+	}
+
+	cm, err := env.clientset.CoreV1().ConfigMaps(testNamespace).Get(config.TopoJsonConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	topo := CurveClusterTopo{}
+	if err := json.Unmarshal([]byte(cm.Data[config.TopoJsonConfigmapDataKey]), &topo); err != nil {
+		t.Fatal(err)
+	}
+	if len(topo.LogicalPools) != 1 || topo.LogicalPools[0].Copysets != 202 {
+		t.Errorf("expected 202 copysets of the logical pool, got %+v", topo.LogicalPools)
+	}
+	plan := env.getCluster().Status.CopysetPlan
+	if plan == nil || !plan.Auto || plan.Copysets != 202 || plan.ChunkServerCopysets != 606 || plan.PoolBytes != 3*spec.Storage.Devices[0].PoolBytes() {
+		t.Errorf("expected the copyset plan recorded in status, got %+v", plan)
+	}
+}
+
+func TestProvisioningFlowPools(t *testing.T) {
+	spec := testSpec()
+	chunkSize := resource.MustParse("4Mi")
+	spec.Storage.Pools = []curvev1.PoolSpec{{Name: "small", Devices: []string{"/dev/vdc"}, ChunkSize: &chunkSize}}
+	env := newFakeEnv(t, spec)
+	if err := env.start(); err != nil {
+		t.Fatalf("failed to provision chunkservers: %v", err)
+	}
+
+	// the devices of the pool are formatted in its chunk size, and their chunkservers are in its physical pool
+	job, err := env.context.Clientset.BatchV1().Jobs(testNamespace).Get(prepareJobName("node1", "/dev/vdc"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args := job.Spec.Template.Spec.Containers[0].Args; args[3] != "4194304" {
+		t.Errorf("expected the chunk size of the pool passed to the format script, got %v", args)
+	}
+	cm, err := env.clientset.CoreV1().ConfigMaps(testNamespace).Get(ConfigMapNamePrefix+"-node1-vdc", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(cm.Data[config.ChunkserverConfigMapDataKey], "global.chunk_size=4194304\n") {
+		t.Errorf("expected the chunk size of the pool in the chunkserver config, got %s", cm.Data[config.ChunkserverConfigMapDataKey])
+	}
+
+	cm, err = env.clientset.CoreV1().ConfigMaps(testNamespace).Get(config.TopoJsonConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	topo := CurveClusterTopo{}
+	if err := json.Unmarshal([]byte(cm.Data[config.TopoJsonConfigmapDataKey]), &topo); err != nil {
+		t.Fatal(err)
+	}
+	if topo.NPools != 2 || len(topo.LogicalPools) != 2 || topo.LogicalPools[1].Name != "small" || topo.LogicalPools[1].PhysicalPool != "small" {
+		t.Errorf("expected a logical pool and a physical pool of the pool, got %+v", topo)
+	}
+	physicalPools := map[string]int{}
+	for _, server := range topo.Servers {
+		physicalPools[server.PhysicalPool]++
+	}
+	if physicalPools[DEFAULT_PHYSICAL_POOL_NAME] != 3 || physicalPools["small"] != 3 {
+		t.Errorf("expected the chunkservers in the physical pools of their devices, got %v", physicalPools)
+	}
+	plans := env.getCluster().Status.PoolCopysetPlans
+	if len(plans) != 1 || plans[0].Pool != "small" || plans[0].ChunkSize != chunkSize.Value() {
+		t.Errorf("expected the copyset plan of the pool recorded in status, got %+v", plans)
+	}
+
+	// the formatted device can't join a pool of another chunk size
+	env.cluster.Spec.Storage.Pools[0].Devices = []string{"/dev/vdb", "/dev/vdc"}
+	if err := env.start(); err == nil || !strings.Contains(err.Error(), "is formatted with chunk size") {
+		t.Errorf("expected the formatted device refused by the pool, got %v", err)
+	}
+
+	// a device of a pool must be a device of storage
+	spec.Storage.Pools[0].Devices = []string{"/dev/vdd"}
+	if err := newFakeEnv(t, spec).start(); err == nil || !strings.Contains(err.Error(), "is not a device of storage") {
+		t.Errorf("expected the unknown device of the pool refused, got %v", err)
+	}
+}
+
+func TestValidateTopology(t *testing.T) {
+	server := func(name, ip, zone string) Server {
+		return Server{Name: name, InternalIp: ip, InternalPort: 8200, ExternalIp: ip, ExternalPort: 8200, Zone: zone, PhysicalPool: "pool1"}
+	}
+	lpool := LogicalPool{Name: "pool1", PhysicalPool: "pool1", Replicas: 3, Zones: 3, Copysets: 100}
+
+	topo := &CurveClusterTopo{
+		Servers:      []Server{server("node1_0", "10.0.0.1", "zone1"), server("node2_0", "10.0.0.2", "zone2"), server("node3_0", "10.0.0.3", "zone3")},
+		LogicalPools: []LogicalPool{lpool},
+	}
+	if err := validateTopology(topo); err != nil {
+		t.Fatalf("expected the topology valid, got %v", err)
+	}
+
+	topo.Servers[2] = server("node2_0", "10.0.0.2", "zone2")
+	err := validateTopology(topo)
+	topoErr, ok := err.(*TopologyError)
+	if !ok {
+		t.Fatalf("expected a TopologyError, got %v", err)
+	}
+	// the duplicated name, the duplicated endpoint and the missing zone
+	if len(topoErr.Problems) != 3 {
+		t.Errorf("expected 3 problems, got %v", topoErr.Problems)
+	}
+	if errs := topoErr.ClusterErrors(); len(errs) != 1 || errs[0].Category != curvev1.ErrorCategoryConfigError {
+		t.Errorf("expected a ConfigError, got %+v", errs)
+	}
+}
+
+func TestProvisioningFlowDevMode(t *testing.T) {
+	spec := testSpec()
+	spec.DevMode = true
+	env := newFakeEnv(t, spec)
+	if err := env.start(); err != nil {
+		t.Fatalf("failed to provision chunkservers: %v", err)
+	}
+
+	// the mock devices are formatted without the pre-flight checks, and no logical pool is created on the mock
+	// chunkservers
+	if preflights := env.createdWithPrefix("Job/curve-chunkserver-preflight-"); len(preflights) != 0 {
+		t.Errorf("expected no pre-flight job in dev mode, got %v", preflights)
+	}
+	if jobs := env.createdWithPrefix("Job/" + PrepareJobName); len(jobs) != 6 {
+		t.Errorf("expected a prepare job for each device, got %v", jobs)
+	}
+	if pools := env.createdWithPrefix("Job/gen-logical-pool"); len(pools) != 0 {
+		t.Errorf("expected no logical pool created in dev mode, got %v", pools)
+	}
+
+	cm, err := env.context.Clientset.CoreV1().ConfigMaps(testNamespace).Get(formatConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cm.Data[formatScriptFileDataKey] != script.MOCK_FORMAT {
+		t.Errorf("expected the devices formatted by the mock script")
+	}
+	deployments, err := env.context.Clientset.AppsV1().Deployments(testNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range deployments.Items {
+		container := d.Spec.Template.Spec.Containers[0]
+		if container.ReadinessProbe != nil || len(container.Command) < 3 || container.Command[2] != script.MOCK_CHUNKSERVER {
+			t.Errorf("expected the mock chunkserver in deployment %s, got %v", d.Name, container.Command)
+		}
+	}
+}
+
+func TestProvisioningFlowUnmanagedTopology(t *testing.T) {
+	spec := testSpec()
+	managed := false
+	spec.Topology.Managed = &managed
+	env := newFakeEnv(t, spec)
+	if err := env.start(); err != nil {
+		t.Fatalf("failed to provision chunkservers: %v", err)
+	}
+
+	// the devices are formatted and the chunkservers run, but no pool is created
+	if deployments := env.createdWithPrefix("Deployment/" + AppName); len(deployments) != 6 {
+		t.Errorf("expected a chunkserver for each device, got %v", deployments)
+	}
+	if pools := env.createdWithPrefix("Job/gen-"); len(pools) != 0 {
+		t.Errorf("expected no pool created, got %v", pools)
+	}
+	// chunkservers mount the reference topology and tools.conf
+	for _, name := range []string{config.TopoJsonConfigMapName, config.ToolsConfigMapName} {
+		if _, err := env.context.Clientset.CoreV1().ConfigMaps(testNamespace).Get(name, metav1.GetOptions{}); err != nil {
+			t.Errorf("expected configmap %s created, got %v", name, err)
+		}
+	}
+	cluster := env.getCluster()
+	for _, conditionType := range []curvev1.ConditionType{curvev1.ConditionTypePhysicalPoolReady, curvev1.ConditionTypeLogicalPoolReady} {
+		condition := findClusterCondition(cluster, conditionType)
+		if condition == nil || condition.Reason != curvev1.ConditionTopologyUnmanagedReason {
+			t.Errorf("expected %s left to the admin, got %+v", conditionType, condition)
+		}
+	}
+}
+
+func TestProvisioningFlowLoopDevice(t *testing.T) {
+	size := resource.MustParse("20Gi")
+	spec := testSpec()
+	spec.Storage.Devices = []curvev1.DevicesSpec{
+		{Name: "chunkserver0", Type: curvev1.DeviceTypeLoop, Size: &size, MountPath: "/data/chunkserver0", Percentage: 80},
+	}
+	env := newFakeEnv(t, spec)
+	if err := env.start(); err != nil {
+		t.Fatalf("failed to provision chunkservers: %v", err)
+	}
+
+	// the backing file under the data dir of the node is passed to the scripts in place of the device
+	job, err := env.context.Clientset.BatchV1().Jobs(testNamespace).Get(prepareJobName("node1", "chunkserver0"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := job.Spec.Template.Spec.Containers[0].Args
+	if args[0] != ChunkserverContainerLoopDir+"/chunkserver0" || args[11] != strconv.FormatInt(size.Value(), 10) {
+		t.Errorf("expected the backing file and its size passed to the format script, got %v", args)
+	}
+	d, err := env.context.Clientset.AppsV1().Deployments(testNamespace).Get(DeploymentName("node1", "chunkserver0"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, podSpec := range []v1.PodSpec{job.Spec.Template.Spec, d.Spec.Template.Spec} {
+		found := false
+		for _, vol := range podSpec.Volumes {
+			if vol.HostPath != nil && vol.HostPath.Path == "/curvebs/data/loop" {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected the directory of backing files mounted, got %v", podSpec.Volumes)
+		}
+	}
+
+	// a loop device must have a size
+	spec.Storage.Devices[0].Size = nil
+	if err := newFakeEnv(t, spec).start(); err == nil || !strings.Contains(err.Error(), "no size") {
+		t.Errorf("expected a loop device without size refused, got %v", err)
+	}
+}
+
+func TestProvisioningFlowReservedPercentage(t *testing.T) {
+	spec := testSpec()
+	spec.Storage.ReservedPercentage = 30
+	env := newFakeEnv(t, spec)
+	if err := env.start(); err != nil {
+		t.Fatalf("failed to provision chunkservers: %v", err)
+	}
+
+	// the percentage of the devices is limited to keep the reserved space by default
+	job, err := env.context.Clientset.BatchV1().Jobs(testNamespace).Get(prepareJobName("node1", "/dev/vdb"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args := job.Spec.Template.Spec.Containers[0].Args; args[2] != "70" {
+		t.Errorf("expected the device formatted by the limit of reservedPercentage, got %v", args)
+	}
+
+	spec.Storage.OvercommitPolicy = curvev1.OvercommitReject
+	if err := newFakeEnv(t, spec).start(); err == nil || !strings.Contains(err.Error(), "reservedPercentage") {
+		t.Errorf("expected the overcommitted devices refused, got %v", err)
+	}
+	spec.Storage.OvercommitPolicy = curvev1.OvercommitAllow
+	if err := newFakeEnv(t, spec).start(); err != nil {
+		t.Errorf("expected the overcommitted devices allowed, got %v", err)
+	}
+}
+
+func TestProvisioningFlowCacheDevice(t *testing.T) {
+	spec := testSpec()
+	spec.Storage.Devices[0].CacheDevice = "/dev/nvme0n1p1"
+	spec.Storage.Devices[1].CacheDevice = "/dev/nvme0n1p2"
+	spec.Storage.Devices[1].CacheMode = curvev1.CacheModeWriteback
+	env := newFakeEnv(t, spec)
+	if err := env.start(); err != nil {
+		t.Fatalf("failed to provision chunkservers: %v", err)
+	}
+
+	// the cache device is checked by the pre-flight job, made into bcache by the prepare job, and registered by
+	// the chunkserver
+	preflight, err := env.context.Clientset.BatchV1().Jobs(testNamespace).Get("curve-chunkserver-preflight-node1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args := strings.Join(preflight.Spec.Template.Spec.Containers[0].Args, " "); !strings.Contains(args, "/dev/nvme0n1p2,cache,") {
+		t.Errorf("expected the cache device checked by the pre-flight job, got %s", args)
+	}
+	job, err := env.context.Clientset.BatchV1().Jobs(testNamespace).Get(prepareJobName("node1", "/dev/vdc"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args := job.Spec.Template.Spec.Containers[0].Args; args[12] != "/dev/nvme0n1p2" || args[13] != curvev1.CacheModeWriteback {
+		t.Errorf("expected the cache device and mode passed to the format script, got %v", args)
+	}
+	d, err := env.context.Clientset.AppsV1().Deployments(testNamespace).Get(DeploymentName("node1", "/dev/vdb"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args := d.Spec.Template.Spec.Containers[0].Args; args[len(args)-1] != "/dev/nvme0n1p1" {
+		t.Errorf("expected the cache device passed to the start script, got %v", args)
+	}
+
+	// a cache device can't be shared by the devices
+	spec.Storage.Devices[1].CacheDevice = "/dev/nvme0n1p1"
+	if err := newFakeEnv(t, spec).start(); err == nil || !strings.Contains(err.Error(), "is used by") {
+		t.Errorf("expected a shared cache device refused, got %v", err)
+	}
+}
+
+func TestProvisioningFlowIOLimits(t *testing.T) {
+	spec := testSpec()
+	writeRate := resource.MustParse("200Mi")
+	spec.Storage.IO = curvev1.IOLimitsSpec{Weight: 50, WriteBytesPerSecond: &writeRate, ReadIOPS: 5000}
+	env := newFakeEnv(t, spec)
+	if err := env.start(); err != nil {
+		t.Fatalf("failed to provision chunkservers: %v", err)
+	}
+
+	// the limits are written to the cgroup of the pod by an init container before chunkserver starts
+	d, err := env.context.Clientset.AppsV1().Deployments(testNamespace).Get(DeploymentName("node1", "/dev/vdb"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var limits *v1.Container
+	for i, c := range d.Spec.Template.Spec.InitContainers {
+		if c.Name == "io-limits" {
+			limits = &d.Spec.Template.Spec.InitContainers[i]
+		}
+	}
+	if limits == nil {
+		t.Fatalf("expected the io-limits init container, got %v", d.Spec.Template.Spec.InitContainers)
+	}
+	if args := strings.Join(limits.Args[3:], " "); args != "/dev/vdb  50 0 209715200 5000 0 "+containerCgroupDir {
+		t.Errorf("expected the limits passed to the io-limits script, got %s", args)
+	}
+
+	spec.Storage.IO = curvev1.IOLimitsSpec{}
+	env = newFakeEnv(t, spec)
+	if err := env.start(); err != nil {
+		t.Fatalf("failed to provision chunkservers: %v", err)
+	}
+	d, err = env.context.Clientset.AppsV1().Deployments(testNamespace).Get(DeploymentName("node1", "/dev/vdb"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range d.Spec.Template.Spec.InitContainers {
+		if c.Name == "io-limits" {
+			t.Errorf("expected no io-limits init container without storage.io")
+		}
+	}
+
+	zero := resource.MustParse("0")
+	spec.Storage.IO = curvev1.IOLimitsSpec{ReadBytesPerSecond: &zero}
+	if err := newFakeEnv(t, spec).start(); err == nil || !strings.Contains(err.Error(), "must be positive") {
+		t.Errorf("expected a zero rate refused, got %v", err)
+	}
+}
+
+func TestProvisioningFlowStableDeviceName(t *testing.T) {
+	spec := testSpec()
+	spec.Storage.Devices[1].Name = "/dev/disk/by-path/pci-0000:00:1f.2-ata-1"
+	env := newFakeEnv(t, spec)
+	// the prepare job of the kernel name resolves its stable link on the node
+	env.runJob = func(job *batch.Job) bool {
+		if job.Name == prepareJobName("node1", "/dev/vdb") {
+			pod := &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: job.Name + "-x", Namespace: testNamespace, Labels: map[string]string{"job-name": job.Name}},
+				Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{{
+					State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Message: "/dev/disk/by-id/wwn-0x5000c500a1b2c3d4\n"}},
+				}}},
+			}
+			if err := env.tracker.Add(pod); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return true
+	}
+	if err := env.start(); err != nil {
+		t.Fatalf("failed to provision chunkservers: %v", err)
+	}
+
+	// the chunkserver mounts the device by the stable link, and it's kept in the inventory for the next reconcile
+	d, err := env.context.Clientset.AppsV1().Deployments(testNamespace).Get(DeploymentName("node1", "/dev/vdb"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args := d.Spec.Template.Spec.Containers[0].Args; args[0] != "/dev/disk/by-id/wwn-0x5000c500a1b2c3d4" {
+		t.Errorf("expected the stable link passed to the start script, got %v", args)
+	}
+	records, err := env.newCluster().loadInventory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := records[inventoryKey("node1", "/dev/vdb")]; r.StableName != "/dev/disk/by-id/wwn-0x5000c500a1b2c3d4" {
+		t.Errorf("expected the stable link recorded in the inventory, got %+v", r)
+	}
+	if r := records[inventoryKey("node2", "/dev/vdb")]; r.StableName != "" {
+		t.Errorf("expected no stable link of the device without one, got %+v", r)
+	}
+
+	// the device given by a stable link is used as it is, with a valid name of its chunkserver
+	d, err = env.context.Clientset.AppsV1().Deployments(testNamespace).Get("curve-chunkserver-node1-pci-0000-00-1f.2-ata-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args := d.Spec.Template.Spec.Containers[0].Args; args[0] != spec.Storage.Devices[1].Name {
+		t.Errorf("expected the stable link in spec passed to the start script, got %v", args)
+	}
+	if name := deviceBaseName("/dev/disk/by-id/ata-Samsung_SSD_860_EVO_500GB_S3Z1NB0K123456X"); len(name) > maxDeviceBaseNameLength || strings.ToLower(name) != name {
+		t.Errorf("expected a long link shortened to a valid name, got %s", name)
+	}
+
+	// the links that change when the device is formatted are refused
+	spec.Storage.Devices[1].Name = "/dev/disk/by-uuid/0b7c7a4e-3f2a-4a7e-9d0c-3c1f6f7e2a11"
+	if err := newFakeEnv(t, spec).start(); err == nil || !strings.Contains(err.Error(), "by-id or") {
+		t.Errorf("expected a device by uuid refused, got %v", err)
+	}
+}
+
+func TestProvisioningFlowAdoptedDevices(t *testing.T) {
+	env := newFakeEnv(t, testSpec())
+	var adopted []AdoptedChunkServer
+	for i, node := range []string{"node1", "node2", "node3"} {
+		adopted = append(adopted,
+			AdoptedChunkServer{NodeName: node, DeviceName: "/dev/vdb", Port: 8300 + 2*i},
+			AdoptedChunkServer{NodeName: node, DeviceName: "/dev/vdc", Port: 8301 + 2*i})
+	}
+	if err := env.newCluster().AdoptChunkServers(adopted); err != nil {
+		t.Fatalf("failed to adopt chunkservers: %v", err)
+	}
+	if err := env.start(); err != nil {
+		t.Fatalf("failed to provision chunkservers: %v", err)
+	}
+
+	// the devices of the adopted chunkservers are not formatted, and their chunkservers keep the ports
+	if jobs := env.createdWithPrefix("Job/" + PrepareJobName); len(jobs) != 0 {
+		t.Errorf("expected the adopted devices not formatted, got %v", jobs)
+	}
+	d, err := env.clientset.AppsV1().Deployments(testNamespace).Get(DeploymentName("node3", "/dev/vdc"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(strings.Join(d.Spec.Template.Spec.Containers[0].Args, " "), "8305") {
+		t.Errorf("expected the adopted chunkserver on port 8305, got %v", d.Spec.Template.Spec.Containers[0].Args)
+	}
+
+	err = env.newCluster().AdoptChunkServers([]AdoptedChunkServer{{NodeName: "node1", DeviceName: "/dev/vdz", Port: 8210}})
+	if err == nil {
+		t.Errorf("expected the device not in storage refused")
+	}
+}
+
+func TestProvisioningFlowImportedTopology(t *testing.T) {
+	exported := newFakeEnv(t, testSpec())
+	if err := exported.start(); err != nil {
+		t.Fatalf("failed to provision chunkservers: %v", err)
+	}
+	inventory, err := exported.clientset.CoreV1().ConfigMaps(testNamespace).Get(InventoryConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the devices are listed in the other order in the restored cluster, the chunkservers take the ports of the
+	// exported ones anyway
+	spec := testSpec()
+	spec.Storage.Devices[0], spec.Storage.Devices[1] = spec.Storage.Devices[1], spec.Storage.Devices[0]
+	env := newFakeEnv(t, spec)
+	imported, err := env.newCluster().ImportInventory(inventory.Data[inventoryDataKey])
+	if err != nil {
+		t.Fatalf("failed to import inventory: %v", err)
+	}
+	if imported != 6 {
+		t.Errorf("expected the bindings of 6 chunkservers imported, got %d", imported)
+	}
+	if err := env.start(); err != nil {
+		t.Fatalf("failed to provision chunkservers: %v", err)
+	}
+	if jobs := env.createdWithPrefix("Job/" + PrepareJobName); len(jobs) != 6 {
+		t.Errorf("expected the reserved devices formatted, got %v", jobs)
+	}
+	for _, name := range env.createdWithPrefix("Deployment/" + AppName) {
+		name = strings.TrimPrefix(name, "Deployment/")
+		want, err := exported.clientset.AppsV1().Deployments(testNamespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := env.clientset.AppsV1().Deployments(testNamespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(got.Spec.Template.Spec.Containers[0].Args, " ") != strings.Join(want.Spec.Template.Spec.Containers[0].Args, " ") {
+			t.Errorf("expected chunkserver %s started as exported %v, got %v", name, want.Spec.Template.Spec.Containers[0].Args, got.Spec.Template.Spec.Containers[0].Args)
+		}
+	}
+
+	if _, err := env.newCluster().ImportInventory(inventory.Data[inventoryDataKey]); err == nil {
+		t.Errorf("expected the import refused after the devices are formatted")
+	}
+}
@@ -1,67 +1,208 @@
 package chunkserver
 
 import (
+	"context"
 	"fmt"
 	"path"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	batch "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
 	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+	"github.com/opencurve/curve-operator/pkg/mds"
 )
 
 const RegisterJobName = "register-topo"
 
-// runCreatePoolJob create Job to register topology.json
-func (c *Cluster) runCreatePoolJob(nodeNameIP map[string]string, poolType string) (*batch.Job, error) {
-	// 1. create topology-json-conf configmap in cluster
-	err := c.createTopoConfigMap()
+// TopologyHashAnnotation is the hash of the topology registered by the create pool job, the job is run again
+// when the topology changes
+const TopologyHashAnnotation = "operator.curve.io/topology-hash"
+
+// createPoolBackoffLimit is how many times the job retries before it's regenerated by the next reconcile
+const createPoolBackoffLimit = 2
+
+var (
+	createPoolTimeout  = 10 * time.Minute
+	createPoolInterval = 5 * time.Second
+
+	// mdsLeaderTimeout is how long the election of the leader of mds is waited for before the pools are created
+	mdsLeaderTimeout  = 5 * time.Minute
+	mdsLeaderInterval = 5 * time.Second
+)
+
+// PoolCreationError is returned if the create pool job failed, the job is deleted so it's generated again with
+// the current topology by the next reconcile
+type PoolCreationError struct {
+	PoolType string
+	Message  string
+}
+
+func (e *PoolCreationError) Error() string {
+	return fmt.Sprintf("job to create %s failed: %s", poolDescription(e.PoolType), e.Message)
+}
+
+// poolDescription returns the readable name of the pool type, such as "logical pool" of logical_pool
+func poolDescription(poolType string) string {
+	return strings.Replace(poolType, "_", " ", -1)
+}
+
+// createPool runs the job to create the pool and reports its progress in the condition of the pool. The job is
+// not run if the topology is not managed by the operator, only the configmaps that chunkservers mount are created.
+func (c *Cluster) createPool(nodeNameIP map[string]string, poolType string, conditionType curvev1.ConditionType) error {
+	pool := poolDescription(poolType)
+	if !c.spec.Topology.IsManaged() {
+		if _, err := c.createPoolConfigMaps(nodeNameIP); err != nil {
+			return err
+		}
+		k8sutil.SetReady(context.TODO(), &c.context, c.namespacedName, conditionType, curvev1.ConditionTopologyUnmanagedReason,
+			fmt.Sprintf("The %s is created and updated by the admin with curve tools, the operator formats devices and runs chunkservers only", pool))
+		return nil
+	}
+	k8sutil.SetProgressing(context.TODO(), &c.context, c.namespacedName, conditionType, curvev1.ConditionCreatingPoolReason, "Creating "+pool)
+	if _, err := c.runCreatePoolJob(nodeNameIP, poolType); err != nil {
+		reason := curvev1.ConditionPoolCreationFailedReason
+		if _, ok := errors.Cause(err).(*TopologyError); ok {
+			reason = curvev1.ConditionInvalidTopologyReason
+		}
+		k8sutil.SetProgressing(context.TODO(), &c.context, c.namespacedName, conditionType, reason, err.Error())
+		return errors.Wrapf(err, "failed to create %s", pool)
+	}
+	k8sutil.SetReady(context.TODO(), &c.context, c.namespacedName, conditionType, curvev1.ConditionPoolCreatedReason, "Created "+pool)
+	return nil
+}
+
+// waitMdsLeader waits for the leader of mds to be elected by the dummy ports of the mds pods, the pool jobs and the
+// chunkservers register to the leader only
+func (c *Cluster) waitMdsLeader() error {
+	k8sutil.SetProgressing(context.TODO(), &c.context, c.namespacedName, curvev1.ConditionTypeMdsLeaderReady, curvev1.ConditionWaitingMdsLeaderReason, "Waiting for the leader of mds to be elected")
+	leader, err := mds.WaitForLeader(c.context.Clientset, c.namespacedName.Namespace, c.spec.Mds.DummyPort, mdsLeaderInterval, mdsLeaderTimeout)
 	if err != nil {
-		return &batch.Job{}, errors.Wrap(err, "failed to create topology-json-conf configmap in cluster")
+		k8sutil.SetProgressing(context.TODO(), &c.context, c.namespacedName, curvev1.ConditionTypeMdsLeaderReady, curvev1.ConditionMdsLeaderTimeoutReason, err.Error())
+		return err
 	}
-	logger.Infof("created ConfigMap %s success", config.TopoJsonConfigMapName)
+	logger.Infof("mds leader %s is elected", leader)
+	k8sutil.SetReady(context.TODO(), &c.context, c.namespacedName, curvev1.ConditionTypeMdsLeaderReady, curvev1.ConditionMdsLeaderElectedReason, fmt.Sprintf("The leader of mds is %s", leader))
+	return nil
+}
 
-	// 2. create tool-conf configmap in cluster
-	err = c.createToolConfigMap(nodeNameIP)
+// runCreatePoolJob create Job to register topology.json, the topology is validated before the job is submitted.
+// The job that failed or registered another topology is replaced, and the job is waited for to complete.
+func (c *Cluster) runCreatePoolJob(nodeNameIP map[string]string, poolType string) (*batch.Job, error) {
+	clusterPoolJson, err := c.createPoolConfigMaps(nodeNameIP)
 	if err != nil {
-		return &batch.Job{}, errors.Wrap(err, "failed to create tool-conf configmap in cluster")
+		return &batch.Job{}, err
 	}
-	logger.Infof("created ConfigMap %s success", config.ToolsConfigMapName)
 
 	// 3. make job to register topology.json to curve cluster
-	job := &batch.Job{}
-	if poolType == "physical_pool" {
-		job, _ = c.makeCreatePoolJob(poolType, "gen-physical-pool")
-	} else if poolType == "logical_pool" {
-		job, _ = c.makeCreatePoolJob(poolType, "gen-logical-pool")
+	var job *batch.Job
+	switch poolType {
+	case "physical_pool":
+		job, err = c.makeCreatePoolJob(poolType, "gen-physical-pool")
+	case "logical_pool":
+		job, err = c.makeCreatePoolJob(poolType, "gen-logical-pool")
+	default:
+		return &batch.Job{}, errors.Errorf("unknown pool type %q", poolType)
+	}
+	if err != nil {
+		return &batch.Job{}, err
 	}
+	topologyHash := k8sutil.Hash(clusterPoolJson)
+	job.Annotations = map[string]string{TopologyHashAnnotation: topologyHash}
 
 	// check whether job is exist
 	existingJob, err := c.context.Clientset.BatchV1().Jobs(job.Namespace).Get(job.Name, metav1.GetOptions{})
 	if err != nil && !kerrors.IsNotFound(err) {
-		logger.Warningf("failed to detect job %s. %+v", job.Name, err)
-	} else if err == nil {
-		// if the job is still running
-		if existingJob.Status.Active > 0 {
-			logger.Infof("Found previous job %s. Status=%+v", job.Name, existingJob.Status)
-			return existingJob, nil
+		return &batch.Job{}, errors.Wrapf(err, "failed to detect job %s", job.Name)
+	}
+	if err == nil && existingJob.Annotations[TopologyHashAnnotation] == topologyHash && existingJob.Status.Failed == 0 {
+		logger.Infof("Found previous job %s of the same topology. Status=%+v", job.Name, existingJob.Status)
+	} else {
+		// job is not found, failed or registered another topology, so create or recreate it here
+		if err == nil {
+			logger.Infof("Removing previous job %s to register the current topology", job.Name)
+			if err := k8sutil.DeleteBatchJob(context.TODO(), c.context.Clientset, job.Namespace, job.Name, true); err != nil {
+				return &batch.Job{}, err
+			}
 		}
+		if _, err := c.context.Clientset.BatchV1().Jobs(job.Namespace).Create(job); err != nil {
+			return &batch.Job{}, errors.Wrapf(err, "failed to create job %s", job.Name)
+		}
+		logger.Infof("creaded job to generate %s", poolType)
 	}
 
-	// job is not found or job is not active status, so create or recreate it here
-	_, err = c.context.Clientset.BatchV1().Jobs(job.Namespace).Create(job)
+	return c.waitCreatePoolJob(job, poolType)
+}
+
+// createPoolConfigMaps creates the configmaps of topology.json and tools.conf, and returns topology.json
+func (c *Cluster) createPoolConfigMaps(nodeNameIP map[string]string) (string, error) {
+	// 1. create topology-json-conf configmap in cluster
+	clusterPoolJson, err := c.genClusterPool()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate topology.json")
+	}
+	err = c.createTopoConfigMap(clusterPoolJson)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create topology-json-conf configmap in cluster")
+	}
+	logger.Infof("created ConfigMap %s success", config.TopoJsonConfigMapName)
+
+	// 2. create tool-conf configmap in cluster
+	err = c.createToolConfigMap(nodeNameIP)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create tool-conf configmap in cluster")
+	}
+	logger.Infof("created ConfigMap %s success", config.ToolsConfigMapName)
+	return clusterPoolJson, nil
+}
 
-	logger.Infof("creaded job to generate %s", poolType)
+// waitCreatePoolJob waits for the create pool job to complete. The failed job is deleted, so a new one is created
+// with the topology of the next reconcile.
+func (c *Cluster) waitCreatePoolJob(job *batch.Job, poolType string) (*batch.Job, error) {
+	var finished *batch.Job
+	err := wait.PollImmediate(createPoolInterval, createPoolTimeout, func() (bool, error) {
+		j, err := c.context.Clientset.BatchV1().Jobs(job.Namespace).Get(job.Name, metav1.GetOptions{})
+		if err != nil {
+			logger.Warningf("failed to get job %s. %v", job.Name, err)
+			return false, nil
+		}
+		if j.Status.Succeeded > 0 || j.Status.Failed > 0 {
+			finished = j
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		return &batch.Job{}, errors.Errorf("job %s is not completed in %s", job.Name, createPoolTimeout)
+	}
+	if finished.Status.Succeeded > 0 {
+		return finished, nil
+	}
 
-	return &batch.Job{}, err
+	message, err := k8sutil.GetJobTerminationMessage(c.context.Clientset, finished)
+	if err != nil {
+		logger.Warningf("failed to get the termination message of job %s. %v", job.Name, err)
+	}
+	if message == "" {
+		message = fmt.Sprintf("see the logs of the pods of job %s", job.Name)
+	}
+	if err := k8sutil.DeleteBatchJob(context.TODO(), c.context.Clientset, job.Namespace, job.Name, false); err != nil {
+		logger.Warningf("failed to delete failed job %s. %v", job.Name, err)
+	}
+	return &batch.Job{}, &PoolCreationError{PoolType: poolType, Message: strings.TrimSpace(message)}
 }
 
 func (c *Cluster) makeCreatePoolJob(poolType string, jobName string) (*batch.Job, error) {
 	// topology.json and tools.conf volume and volumemount
 	volumes, mounts := c.createTopoAndToolVolumeAndMount()
+	backoffLimit := int32(createPoolBackoffLimit)
 
 	podSpec := v1.PodTemplateSpec{
 		ObjectMeta: metav1.ObjectMeta{
@@ -78,6 +219,10 @@ func (c *Cluster) makeCreatePoolJob(poolType string, jobName string) (*batch.Job
 			Volumes:       volumes,
 		},
 	}
+	k8sutil.InjectEnv(&podSpec.Spec, c.spec, "chunkserver")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, c.spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, c.spec)
+	k8sutil.InjectDNS(&podSpec.Spec, c.spec, "chunkserver")
 
 	job := &batch.Job{
 		ObjectMeta: metav1.ObjectMeta{
@@ -86,11 +231,13 @@ func (c *Cluster) makeCreatePoolJob(poolType string, jobName string) (*batch.Job
 			Labels:    c.getRegisterJobLabel(poolType),
 		},
 		Spec: batch.JobSpec{
-			Template: podSpec,
+			BackoffLimit: &backoffLimit,
+			Template:     podSpec,
 		},
 	}
 
 	// set ownerReference
+	k8sutil.InjectMetadata(c.spec, "chunkserver", job, &job.Spec.Template)
 	err := c.ownerInfo.SetControllerReference(job)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to set owner reference to mon deployment %q", job.Name)
@@ -127,9 +274,11 @@ func (c *Cluster) makeCreatePoolContainer(poolType string, mounts []v1.VolumeMou
 			"/curvebs/tools/sbin/curvebs-tool",
 			// "/bin/sh",
 		},
-		Image:           c.spec.CurveVersion.Image,
+		Image:           c.spec.CurveVersion.JobImage(),
 		ImagePullPolicy: c.spec.CurveVersion.ImagePullPolicy,
 		VolumeMounts:    mounts,
+		// the output of curvebs-tool is the reason of the failure
+		TerminationMessagePolicy: v1.TerminationMessageFallbackToLogsOnError,
 		SecurityContext: &v1.SecurityContext{
 			Privileged:             &privileged,
 			RunAsUser:              &runAsUser,
@@ -142,10 +291,7 @@ func (c *Cluster) makeCreatePoolContainer(poolType string, mounts []v1.VolumeMou
 }
 
 // createTopoConfigMap create topology configmap
-func (c *Cluster) createTopoConfigMap() error {
-	// get topology.json string
-	clusterPoolJson := c.genClusterPool()
-
+func (c *Cluster) createTopoConfigMap(clusterPoolJson string) error {
 	topoConfigMap := map[string]string{
 		config.TopoJsonConfigmapDataKey: clusterPoolJson,
 	}
@@ -158,14 +304,15 @@ func (c *Cluster) createTopoConfigMap() error {
 		Data: topoConfigMap,
 	}
 
+	k8sutil.InjectMetadata(c.spec, "chunkserver", cm)
 	err := c.ownerInfo.SetControllerReference(cm)
 	if err != nil {
 		return errors.Wrapf(err, "failed to set owner reference to topology.json configmap %q", config.TopoJsonConfigMapName)
 	}
 
 	// Create topology-json-conf configmap in cluster
-	_, err = c.context.Clientset.CoreV1().ConfigMaps(c.namespacedName.Namespace).Create(cm)
-	if err != nil && !kerrors.IsAlreadyExists(err) {
+	err = k8sutil.Apply(c.context.Client, cm)
+	if err != nil {
 		return errors.Wrapf(err, "failed to create topology-json-conf configmap in namespace %s", c.namespacedName.Namespace)
 	}
 	return nil
@@ -183,7 +330,7 @@ func (c *Cluster) createToolConfigMap(nodeNameIP map[string]string) error {
 		return errors.Wrapf(err, "failed to get configmap %s from cluster", config.ToolsConfigMapTemp)
 	}
 	toolsCMData := toolsCMTemplate.Data[config.ToolsConfigMapDataKey]
-	replacedToolsData, err := config.ReplaceConfigVars(toolsCMData, &chunkserverConfigs[0])
+	replacedToolsData, err := config.ReplaceConfigVars(toolsCMData, &c.chunkserverConfigs[0])
 	if err != nil {
 		return errors.Wrap(err, "failed to Replace tools config template to generate a new mds configmap to start server.")
 	}
@@ -200,14 +347,15 @@ func (c *Cluster) createToolConfigMap(nodeNameIP map[string]string) error {
 		Data: toolConfigMap,
 	}
 
+	k8sutil.InjectMetadata(c.spec, "chunkserver", cm)
 	err = c.ownerInfo.SetControllerReference(cm)
 	if err != nil {
 		return errors.Wrapf(err, "failed to set owner reference to tools.conf configmap %q", config.ToolsConfigMapName)
 	}
 
 	// Create topology-json-conf configmap in cluster
-	_, err = c.context.Clientset.CoreV1().ConfigMaps(c.namespacedName.Namespace).Create(cm)
-	if err != nil && !kerrors.IsAlreadyExists(err) {
+	err = k8sutil.Apply(c.context.Client, cm)
+	if err != nil {
 		return errors.Wrapf(err, "failed to create tools-conf configmap in namespace %s", c.namespacedName.Namespace)
 	}
 
@@ -0,0 +1,137 @@
+package chunkserver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/curvetool"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+	"github.com/opencurve/curve-operator/pkg/mds"
+)
+
+var (
+	// registrationTimeout is how long the chunkservers are waited for to register to mds before the logical pool
+	// is created, the reconcile is retried after it
+	registrationTimeout  = 10 * time.Minute
+	registrationInterval = 10 * time.Second
+
+	// listRegisteredChunkServers lists the chunkservers registered to mds by curve_ops_tool in a running mds pod
+	listRegisteredChunkServers = func(c *Cluster) ([]curvetool.ChunkServer, error) {
+		return mds.ListChunkServers(&c.context, c.namespacedName.Namespace, c.chunkserverConfigs[0].ClusterMdsAddr)
+	}
+)
+
+// waitChunkServersRegistered waits for all the chunkservers of each zone to register to mds before the logical
+// pool is created, or the copysets of the pool are spread over the chunkservers registered so far, which may be
+// too few or all in some of the zones. The progress of each zone is shown in status.chunkServerRegistration.
+func (c *Cluster) waitChunkServersRegistered() error {
+	if !c.spec.Topology.IsManaged() || len(c.chunkserverConfigs) == 0 {
+		return nil
+	}
+	nodeZones, err := c.genNodeZones()
+	if err != nil {
+		return err
+	}
+	expected := map[mds.HostZone]map[string]bool{}
+	for _, csConfig := range c.chunkserverConfigs {
+		zone := mds.HostZone{PhysicalPool: csConfig.PhysicalPool, Zone: nodeZones[csConfig.NodeName]}
+		if expected[zone] == nil {
+			expected[zone] = map[string]bool{}
+		}
+		expected[zone][fmt.Sprintf("%s:%d", csConfig.NodeIP, csConfig.Port)] = true
+	}
+
+	var zones []curvev1.ZoneRegistrationStatus
+	var lastErr error
+	err = wait.PollImmediate(registrationInterval, registrationTimeout, func() (bool, error) {
+		chunkservers, err := listRegisteredChunkServers(c)
+		if err != nil {
+			lastErr = err
+			logger.Warningf("failed to list the registered chunkservers. %v", err)
+			return false, nil
+		}
+		current := registrationStatus(expected, chunkservers)
+		if !equality.Semantic.DeepEqual(current, zones) {
+			zones = current
+			if err := c.updateRegistration(zones); err != nil {
+				logger.Warningf("failed to update registration of chunkservers in status. %v", err)
+			}
+		}
+		if pending := pendingZones(zones); len(pending) > 0 {
+			k8sutil.SetProgressing(context.TODO(), &c.context, c.namespacedName, curvev1.ConditionTypeLogicalPoolReady, curvev1.ConditionWaitingChunkServersReason,
+				"Waiting for the chunkservers to register to mds: "+strings.Join(pending, ", "))
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		message := fmt.Sprintf("chunkservers are not all registered to mds in %s: %s", registrationTimeout, strings.Join(pendingZones(zones), ", "))
+		if zones == nil && lastErr != nil {
+			message = fmt.Sprintf("failed to list the registered chunkservers in %s: %v", registrationTimeout, lastErr)
+		}
+		k8sutil.SetProgressing(context.TODO(), &c.context, c.namespacedName, curvev1.ConditionTypeLogicalPoolReady, curvev1.ConditionChunkServersRegisterTimeoutReason, message)
+		return errors.New(message)
+	}
+	logger.Infof("all %d chunkservers are registered to mds", len(c.chunkserverConfigs))
+	return nil
+}
+
+// registrationStatus counts the expected chunkservers of each zone that are registered, by their ip:port. The
+// retired chunkservers are not counted, they are left by the retired devices.
+func registrationStatus(expected map[mds.HostZone]map[string]bool, chunkservers []curvetool.ChunkServer) []curvev1.ZoneRegistrationStatus {
+	registered := map[string]bool{}
+	for _, cs := range chunkservers {
+		if cs.RWStatus != curvetool.ChunkServerRetired {
+			registered[fmt.Sprintf("%s:%d", cs.HostIP, cs.Port)] = true
+		}
+	}
+	zones := []curvev1.ZoneRegistrationStatus{}
+	for zone, addrs := range expected {
+		status := curvev1.ZoneRegistrationStatus{PhysicalPool: zone.PhysicalPool, Zone: zone.Zone, Expected: len(addrs)}
+		for addr := range addrs {
+			if registered[addr] {
+				status.Registered++
+			}
+		}
+		zones = append(zones, status)
+	}
+	sort.Slice(zones, func(i, j int) bool {
+		if zones[i].PhysicalPool != zones[j].PhysicalPool {
+			return zones[i].PhysicalPool < zones[j].PhysicalPool
+		}
+		return zones[i].Zone < zones[j].Zone
+	})
+	return zones
+}
+
+// pendingZones returns the zones that have chunkservers not registered as 'pool/zone 1/3'
+func pendingZones(zones []curvev1.ZoneRegistrationStatus) []string {
+	var pending []string
+	for _, zone := range zones {
+		if zone.Registered < zone.Expected {
+			pending = append(pending, fmt.Sprintf("%s/%s %d/%d", zone.PhysicalPool, zone.Zone, zone.Registered, zone.Expected))
+		}
+	}
+	return pending
+}
+
+// updateRegistration records the registration of the chunkservers of each zone in the status of the cluster
+func (c *Cluster) updateRegistration(zones []curvev1.ZoneRegistrationStatus) error {
+	clusterObj := &curvev1.CurveCluster{}
+	if err := c.context.Client.Get(context.TODO(), c.namespacedName, clusterObj); err != nil {
+		return errors.Wrapf(err, "failed to get curvecluster %q", c.namespacedName)
+	}
+	if equality.Semantic.DeepEqual(zones, clusterObj.Status.ChunkServerRegistration) {
+		return nil
+	}
+	clusterObj.Status.ChunkServerRegistration = zones
+	return k8sutil.UpdateStatus(c.context.Client, c.namespacedName, clusterObj)
+}
@@ -0,0 +1,36 @@
+package chunkserver
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// WantedResources returns the names of the deployments, configmaps and prepare jobs of the chunkservers of the
+// devices in spec, and the ones of the removed devices that are still to be retired
+func (c *Cluster) WantedResources() (map[string]bool, error) {
+	resolved, err := k8sutil.ResolveNodeNames(c.context.Clientset, c.spec.StorageNodes())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve storage nodes")
+	}
+	records, err := c.loadInventory()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load formatted device inventory")
+	}
+
+	wanted := map[string]bool{}
+	add := func(nodeName, deviceName string) {
+		wanted[DeploymentName(nodeName, deviceName)] = true
+		wanted[configMapName(nodeName, deviceName)] = true
+		wanted[prepareJobName(nodeName, deviceName)] = true
+	}
+	for _, nodeName := range c.spec.StorageNodes() {
+		for _, device := range c.spec.Storage.NodeDevices(nodeName) {
+			add(resolved[nodeName], device.Name)
+		}
+	}
+	for _, r := range records {
+		add(r.NodeName, r.DeviceName)
+	}
+	return wanted, nil
+}
@@ -0,0 +1,34 @@
+package script
+
+// setupBcache defines setup_bcache that sets device_name to the bcache device of the backing device cached by the
+// cache device. The devices are made into bcache with the cache mode if format is true, or they are registered
+// again if the bcache device is gone, such as after the node reboots without the udev rules of bcache.
+const setupBcache = `
+setup_bcache() {
+  local backing=$1 cache=$2 mode=$3 format=$4 base dev
+  base=$(basename $(readlink -f $backing))
+  if [ "$format" == "true" ]; then
+    make-bcache --wipe-bcache -B $backing -C $cache || return 1
+  fi
+  if [ ! -e /sys/class/block/$base/bcache/dev ]; then
+    for dev in $backing $cache; do
+      echo $(readlink -f $dev) > /sys/fs/bcache/register 2> /dev/null
+    done
+  fi
+  for i in $(seq 1 30); do
+    if [ -e /sys/class/block/$base/bcache/dev ]; then
+      break
+    fi
+    sleep 1
+  done
+  if [ ! -e /sys/class/block/$base/bcache/dev ]; then
+    echo "bcache device of $backing is not found"
+    return 1
+  fi
+  device_name=/dev/$(basename $(readlink -f /sys/class/block/$base/bcache/dev))
+  if [ "$format" == "true" ] && [ -n "$mode" ]; then
+    echo $mode > /sys/class/block/$base/bcache/cache_mode || return 1
+  fi
+  echo "device $backing is cached by $cache as $device_name"
+}
+`
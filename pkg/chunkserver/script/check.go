@@ -0,0 +1,49 @@
+package script
+
+// CHECK checks the data of chunkserver if it was not shut down cleanly, the marker is left by START
+// if chunkserver exits abnormally
+var CHECK = openLUKS + attachLoop + setupBcache + `
+device_name=$1
+device_type=$2
+filesystem=$3
+data_dir=$4
+marker=$5
+encrypted=$6
+cache_device=$7
+
+if [ ! -f "$marker" ]; then
+  echo "chunkserver was shut down cleanly, skip check"
+  exit 0
+fi
+
+echo "chunkserver was not shut down cleanly at $(cat $marker), checking ${device_name}"
+
+if [ "$device_type" == "loop" ]; then
+  attach_loop $device_name || exit 1
+fi
+if [ -n "$cache_device" ]; then
+  setup_bcache $device_name $cache_device "" false || exit 1
+fi
+
+if [ "$device_type" != "path" ] && [ "$encrypted" == "true" ]; then
+  open_luks $device_name false || exit 1
+  device_name=/dev/mapper/curve-$(basename $(readlink -f $device_name))
+fi
+
+if [ "$device_type" == "path" ]; then
+  # the filesystem of a path device is managed by the host, check the metadata of chunkserver only
+  for f in chunkserver.dat chunkfilepool.meta walfilepool.meta; do
+    if [ -e "${data_dir}/${f}" ] && ! cat "${data_dir}/${f}" > /dev/null; then
+      echo "failed to read ${data_dir}/${f}"
+      exit 1
+    fi
+  done
+elif [ "$filesystem" == "xfs" ]; then
+  xfs_repair -n $device_name || exit 1
+else
+  e2fsck -n -f $device_name || exit 1
+fi
+
+echo "check ${device_name} successfully"
+rm -f "$marker"
+`
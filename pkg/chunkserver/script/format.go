@@ -1,15 +1,58 @@
 package script
 
-var FORMAT = `
+var FORMAT = bindVFIO + openLUKS + attachLoop + setupBcache + stableLink + `
 device_name=$1
 device_mount_path=$2
 percent=$3
 chunkfile_size=$4
 chunkfile_pool_dir=$5
 chunkfile_pool_meta_path=$6
+device_type=$7
+filesystem=$8
+mount_options=$9
+engine=${10}
+encrypted=${11}
+loop_size=${12}
+cache_device=${13}
+cache_mode=${14}
 
-mkfs.ext4 $device_name
-mount $device_name $device_mount_path
+# the kernel name of the device may change after the node reboots, its stable link is written to the termination
+# message for the chunkserver to use it instead
+if [ "$device_type" != "path" ] && [ "$device_type" != "loop" ]; then
+  stable_link $device_name > /dev/termination-log
+fi
+
+# spdk allocates the chunks on the NVMe device itself, there is no filesystem and chunk file pool to prepare
+if [ "$engine" == "spdk" ]; then
+  bind_vfio $device_name $device_mount_path/spdk.pci
+  exit $?
+fi
+
+# a path device is an existing directory on the host that has been mounted at $device_mount_path
+if [ "$device_type" != "path" ]; then
+  if [ "$device_type" == "loop" ]; then
+    attach_loop $device_name $loop_size || exit 1
+  fi
+  if [ -n "$cache_device" ]; then
+    setup_bcache $device_name $cache_device $cache_mode true || exit 1
+  fi
+  if [ "$encrypted" == "true" ]; then
+    open_luks $device_name true || exit 1
+    device_name=/dev/mapper/curve-$(basename $(readlink -f $device_name))
+  fi
+
+  if [ "$filesystem" == "xfs" ]; then
+    mkfs.xfs -f $device_name
+  else
+    mkfs.ext4 $device_name
+  fi
+
+  if [ -n "$mount_options" ]; then
+    mount -t $filesystem -o $mount_options $device_name $device_mount_path
+  else
+    mount -t $filesystem $device_name $device_mount_path
+  fi
+fi
 
 cd /curvebs/tools/sbin
 
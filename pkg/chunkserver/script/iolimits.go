@@ -0,0 +1,98 @@
+package script
+
+// IO_LIMITS writes the IO limits of storage.io to the cgroup of the chunkserver pod before chunkserver starts, so
+// they apply to all the containers of the pod. The cgroup root of the host is mounted at $8 and the pod is found by
+// its uid in $POD_UID, the dashes of which are replaced by underscores with the systemd cgroup driver. The rates
+// of 0 are not limited.
+var IO_LIMITS = `
+device_name=$1
+device_type=$2
+weight=$3
+rbps=$4
+wbps=$5
+riops=$6
+wiops=$7
+cgroup_root=$8
+
+find_pod_cgroup() {
+  find $1 -maxdepth 6 -type d \( -name "pod${POD_UID}" -o -name "*pod${POD_UID//-/_}.slice" \) 2>/dev/null | head -n 1
+}
+
+# device_number prints major:minor of the whole disk of the device
+device_number() {
+  local base
+  base=$(basename $(readlink -f $1))
+  if [ -e /sys/class/block/$base/partition ]; then
+    base=$(basename $(dirname $(readlink -f /sys/class/block/$base)))
+  fi
+  cat /sys/class/block/$base/dev 2>/dev/null
+}
+
+# write_limit writes the value to the cgroup file, it's skipped if the kernel doesn't support the file
+write_limit() {
+  local file=$1 value=$2
+  if [ ! -f "$file" ]; then
+    echo "$(basename $file) is not supported, skip"
+    return 0
+  fi
+  if ! echo "$value" > "$file"; then
+    echo "failed to write $value to $file"
+    exit 1
+  fi
+  echo "$(basename $file) is set to $value"
+}
+
+devno=""
+if [ "$device_type" != "path" ] && [ "$device_type" != "loop" ]; then
+  devno=$(device_number $device_name)
+  if [ -z "$devno" ]; then
+    echo "device number of $device_name is not found"
+    exit 1
+  fi
+elif [ $rbps -gt 0 ] || [ $wbps -gt 0 ] || [ $riops -gt 0 ] || [ $wiops -gt 0 ]; then
+  echo "device $device_name is type of $device_type, skip the rates"
+fi
+
+if [ -f $cgroup_root/cgroup.controllers ]; then
+  pod=$(find_pod_cgroup $cgroup_root)
+  if [ -z "$pod" ]; then
+    echo "cgroup of pod $POD_UID is not found in $cgroup_root"
+    exit 1
+  fi
+  if [ $weight -gt 0 ]; then
+    write_limit $pod/io.weight "default $weight"
+  fi
+  if [ -n "$devno" ]; then
+    limits=""
+    [ $rbps -gt 0 ] && limits="$limits rbps=$rbps"
+    [ $wbps -gt 0 ] && limits="$limits wbps=$wbps"
+    [ $riops -gt 0 ] && limits="$limits riops=$riops"
+    [ $wiops -gt 0 ] && limits="$limits wiops=$wiops"
+    if [ -n "$limits" ]; then
+      write_limit $pod/io.max "$devno$limits"
+    fi
+  fi
+else
+  pod=$(find_pod_cgroup $cgroup_root/blkio)
+  if [ -z "$pod" ]; then
+    echo "blkio cgroup of pod $POD_UID is not found in $cgroup_root"
+    exit 1
+  fi
+  if [ $weight -gt 0 ]; then
+    # scale 1-10000 of cgroup v2 to 10-1000 of cgroup v1
+    weight=$((10 + (weight - 1) * 990 / 9999))
+    if [ -f $pod/blkio.bfq.weight ]; then
+      write_limit $pod/blkio.bfq.weight $weight
+    else
+      write_limit $pod/blkio.weight $weight
+    fi
+  fi
+  if [ -n "$devno" ]; then
+    [ $rbps -gt 0 ] && write_limit $pod/blkio.throttle.read_bps_device "$devno $rbps"
+    [ $wbps -gt 0 ] && write_limit $pod/blkio.throttle.write_bps_device "$devno $wbps"
+    [ $riops -gt 0 ] && write_limit $pod/blkio.throttle.read_iops_device "$devno $riops"
+    [ $wiops -gt 0 ] && write_limit $pod/blkio.throttle.write_iops_device "$devno $wiops"
+  fi
+fi
+exit 0
+`
@@ -0,0 +1,59 @@
+package script
+
+// TRANSFER_LEADER transfers the leaders of the copysets on the chunkservers of the node to the other peers
+// before they are restarted, and waits until the chunkservers lead no copyset. The chunkservers are restarted
+// anyway after the timeout, so it always succeeds unless the arguments are wrong.
+var TRANSFER_LEADER = `
+node_ip=$1
+timeout=$2
+shift 2
+ports=$@
+
+cd /curvebs/tools/sbin
+
+# leaders prints 'group_id peer1 peer2 peer3' of each copyset led by the chunkserver, the raft_stat page
+# of the chunkserver has a section for each copyset in which state is before peers
+leaders() {
+  exec 3<>/dev/tcp/${node_ip}/$1 || return 1
+  printf "GET /raft_stat HTTP/1.0\r\n\r\n" >&3
+  awk '
+    /^\[/ { group = $0; sub(/^\[/, "", group); sub(/_.*/, "", group); state = ""; next }
+    /^state: / { state = $2 }
+    /^peers: / { if (state == "LEADER") { $1 = ""; print group $0 } }' <&3
+  exec 3<&-
+}
+
+deadline=$(($(date +%s) + timeout))
+for port in $ports; do
+  self=${node_ip}:${port}:0
+  while true; do
+    copysets=$(leaders $port)
+    if [ $? -ne 0 ]; then
+      echo "chunkserver ${self} is not serving, nothing to transfer"
+      break
+    fi
+    if [ -z "$copysets" ]; then
+      echo "chunkserver ${self} leads no copyset"
+      break
+    fi
+    if [ $(date +%s) -ge $deadline ]; then
+      echo "chunkserver ${self} still leads $(echo "$copysets" | wc -l) copysets after ${timeout}s"
+      break
+    fi
+
+    echo "$copysets" | while read group peers; do
+      conf=$(echo $peers | tr ' ' ',')
+      # the group id of a copyset is logical pool id << 32 | copyset id
+      for peer in $peers; do
+        if [ "$peer" == "$self" ]; then
+          continue
+        fi
+        ./curve_ops_tool transfer-leader -logicalPoolId=$((group >> 32)) -copysetId=$((group & 0xffffffff)) \
+          -peer=$peer -conf=$conf >/dev/null 2>&1 && break
+      done
+    done
+    sleep 2
+  done
+done
+exit 0
+`
@@ -0,0 +1,24 @@
+package script
+
+// attachLoop defines attach_loop that sets device_name to the loop device attached to the backing file, the file
+// is created as a sparse file of size if it doesn't exist. A loop device is attached to the file again if there
+// is none, such as after the node reboots.
+const attachLoop = `
+attach_loop() {
+  local file=$1 size=$2
+  if [ ! -f $file ]; then
+    if [ -z "$size" ]; then
+      echo "backing file $file of loop device does not exist"
+      return 1
+    fi
+    mkdir -p $(dirname $file)
+    truncate -s $size $file || return 1
+    echo "sparse file $file of $size bytes is created"
+  fi
+  device_name=$(losetup -j $file | head -n 1 | cut -d: -f1)
+  if [ -z "$device_name" ]; then
+    device_name=$(losetup -f --show $file) || return 1
+  fi
+  echo "backing file $file is attached as $device_name"
+}
+`
@@ -0,0 +1,24 @@
+package script
+
+// openLUKS defines open_luks that opens the dm-crypt LUKS device as /dev/mapper/curve-<device> by the passphrase
+// in CURVE_DEVICE_KEY, and sets up LUKS on the device before opening it if format is true. The <device> is the
+// kernel name even if the device is given by a stable link. The cryptsetup of the node is run by nsenter, so the
+// pod must share the pid namespace of the node.
+const openLUKS = `
+open_luks() {
+  local device=$1 format=$2 name=curve-$(basename $(readlink -f $1))
+  if [ -z "$CURVE_DEVICE_KEY" ]; then
+    echo "no passphrase of encrypted device $device"
+    return 1
+  fi
+  # the device is opened already if the pod is restarted
+  if [ -b /dev/mapper/$name ]; then
+    return 0
+  fi
+  if [ "$format" == "true" ]; then
+    printf '%s' "$CURVE_DEVICE_KEY" | nsenter -t 1 -m -- cryptsetup luksFormat --batch-mode --key-file=- $device || return 1
+  fi
+  printf '%s' "$CURVE_DEVICE_KEY" | nsenter -t 1 -m -- cryptsetup open --key-file=- $device $name || return 1
+  echo "encrypted device $device is opened as /dev/mapper/$name"
+}
+`
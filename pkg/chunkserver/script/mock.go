@@ -0,0 +1,27 @@
+package script
+
+// MOCK_FORMAT replaces FORMAT in dev mode. Nothing is formatted or mounted, a few sparse chunk files that take
+// no space are created under the mount path of the device instead, the args are the same as FORMAT.
+var MOCK_FORMAT = `
+device_name=$1
+chunkfile_size=$4
+chunkfile_pool_dir=$5
+chunkfile_pool_meta_path=$6
+
+mkdir -p $chunkfile_pool_dir
+for i in $(seq 1 8); do
+  truncate -s $chunkfile_size $chunkfile_pool_dir/$i
+done
+echo "mock chunk file pool of $device_name, 8 sparse files of $chunkfile_size bytes" > $chunkfile_pool_meta_path
+echo "device $device_name is formatted by the mock script"
+`
+
+// MOCK_CHUNKSERVER replaces the chunkserver in dev mode, it does nothing until it's stopped
+var MOCK_CHUNKSERVER = `
+echo "mock chunkserver of device $1 on $4:$5 is running"
+trap 'exit 0' TERM INT
+while true; do
+  sleep 1 &
+  wait $!
+done
+`
@@ -0,0 +1,25 @@
+package script
+
+var OFFLINE = `
+node_ip=$1
+shift
+ports=$@
+
+cd /curvebs/tools/sbin
+
+for port in $ports; do
+  # chunkserver-list prints 'chunkServerID = 1, diskType = nvme, hostIP = 127.0.0.1, port = 8200, ...'
+  id=$(./curve_ops_tool chunkserver-list | grep "hostIP = ${node_ip}, port = ${port}," | sed 's/^chunkServerID = \([0-9]*\),.*$/\1/')
+  if [ -z "$id" ]; then
+    echo "chunkserver ${node_ip}:${port} not found in topology"
+    continue
+  fi
+
+  # chunkserver in pendding status will be migrated to other chunkservers
+  ./curve_ops_tool set-chunkserver -chunkserver_id=$id -chunkserver_status=pendding
+  if [ $? -ne 0 ]; then
+    echo "failed to set chunkserver ${node_ip}:${port} offline"
+    exit 1
+  fi
+done
+`
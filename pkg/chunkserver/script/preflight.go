@@ -0,0 +1,144 @@
+package script
+
+// PREFLIGHT checks a node before any device on it is formatted. The host root is mounted at /rootfs.
+// The host_dirs separated by commas are the roots of the data, logs and conf that must be writable, and the
+// system_dirs are the devices, cgroups and kernel modules directories of the operator settings that must exist.
+// Each device is passed as "name,type,filesystem", the name of a loop device is the host path of its backing
+// file and the type of a cache device is cache, and a device that has existing signatures is refused unless
+// allow_reformat is true. The kernel parameters "name=value" in the lines of sysctls are set and
+// verified. The failures are written to the termination message of the container.
+var PREFLIGHT = setSysctls + `
+host_dirs=$1
+ports=$2
+allow_reformat=$3
+sysctls=$4
+system_dirs=$5
+modules_dir=$6
+shift 6
+
+failures=()
+fail() {
+  echo "FAIL: $*"
+  failures+=("$*")
+}
+
+check_writable() {
+  local dir="/rootfs$1"
+  local file="${dir}/.curve-preflight"
+  if [ ! -d "$dir" ] && ! mkdir -p "$dir" 2>/dev/null; then
+    fail "$1 can not be created"
+  elif ! touch "$file" 2>/dev/null; then
+    fail "$1 is not writable"
+  else
+    rm -f "$file"
+    echo "PASS: $1 is writable"
+  fi
+}
+
+for dir in ${host_dirs//,/ }; do
+  check_writable "$dir"
+done
+for dir in ${system_dirs//,/ }; do
+  if [ ! -d "/rootfs${dir}" ]; then
+    fail "directory $dir does not exist, set the operator settings to where it is on the host"
+  else
+    echo "PASS: directory $dir exists"
+  fi
+done
+set_sysctls "$sysctls"
+
+for device in "$@"; do
+  IFS=',' read -r name type filesystem <<< "$device"
+
+  # a path device is an existing directory on the host
+  if [ "$type" == "path" ]; then
+    if [ ! -d "/rootfs${name}" ]; then
+      fail "directory $name does not exist"
+    else
+      check_writable "$name"
+    fi
+    continue
+  fi
+
+  # the backing file of a loop device is created by the prepare job
+  if [ "$type" == "loop" ]; then
+    check_writable "$(dirname $name)"
+    if [ ! -e "/rootfs${name}" ]; then
+      echo "PASS: backing file $name does not exist"
+    elif [ "$allow_reformat" == "true" ]; then
+      echo "WARN: backing file $name exists and will be reformatted"
+    else
+      fail "backing file $name exists and allowDeviceReformat is not set"
+    fi
+    if ! command -v losetup > /dev/null; then
+      fail "losetup is not found"
+    fi
+    continue
+  fi
+
+  if [ ! -b "$name" ]; then
+    fail "block device $name does not exist"
+    continue
+  fi
+
+  real=$(readlink -f "$name")
+  base=$(basename "$real")
+  if awk '{print $1}' /proc/1/mounts | grep -qE "^(${name}|${real})p?[0-9]*$"; then
+    fail "$name or its partition is mounted"
+  elif grep -qE "^(${name}|${real})p?[0-9]* " /proc/swaps; then
+    fail "$name or its partition is used as swap"
+  elif [ -n "$(ls -A /sys/class/block/${base}/holders 2>/dev/null)" ]; then
+    fail "$name is held by $(ls /sys/class/block/${base}/holders | tr '\n' ' ')"
+  else
+    echo "PASS: $name is not in use"
+  fi
+
+  # the signatures of filesystem, partition table, raid or lvm mean the device has data on it
+  signatures=$(wipefs -n -p "$name" 2>/dev/null | grep -v '^#' | awk -F, '{print $4}' | sort -u | tr '\n' ' ')
+  if [ -z "$signatures" ]; then
+    echo "PASS: $name is empty"
+  elif [ "$allow_reformat" == "true" ]; then
+    echo "WARN: $name has signatures ${signatures}and will be reformatted"
+  else
+    fail "$name has signatures ${signatures}and allowDeviceReformat is not set"
+  fi
+
+  # a cache device is made into bcache with the device it caches, there is no filesystem on it
+  if [ "$type" == "cache" ]; then
+    if ! command -v make-bcache > /dev/null; then
+      fail "make-bcache is not found"
+    fi
+    if [ ! -d /sys/fs/bcache ] &&
+      [ -z "$(find /rootfs${modules_dir}/$(uname -r) -name "bcache.ko*" 2>/dev/null | head -1)" ]; then
+      fail "kernel module bcache is not available"
+    else
+      echo "PASS: kernel supports bcache"
+    fi
+    continue
+  fi
+
+  if ! command -v "mkfs.${filesystem}" > /dev/null; then
+    fail "mkfs.${filesystem} is not found"
+  fi
+  if ! grep -qw "$filesystem" /proc/filesystems &&
+    [ -z "$(find /rootfs${modules_dir}/$(uname -r) -name "${filesystem}.ko*" 2>/dev/null | head -1)" ]; then
+    fail "kernel module ${filesystem} is not available"
+  else
+    echo "PASS: kernel supports ${filesystem}"
+  fi
+done
+
+for port in ${ports//,/ }; do
+  hex=$(printf '%04X' "$port")
+  if awk '$4 == "0A" {print $2}' /proc/net/tcp /proc/net/tcp6 2>/dev/null | grep -qi ":${hex}$"; then
+    fail "port $port is in use"
+  else
+    echo "PASS: port $port is free"
+  fi
+done
+
+if [ ${#failures[@]} -gt 0 ]; then
+  printf '%s; ' "${failures[@]}" > /dev/termination-log
+  exit 1
+fi
+`
@@ -0,0 +1,76 @@
+package script
+
+// RETIRE sets the chunkserver pendding and waits for its copysets to be migrated to other chunkservers
+var RETIRE = `
+node_ip=$1
+port=$2
+
+cd /curvebs/tools/sbin
+
+# chunkserver-list prints 'chunkServerID = 1, diskType = nvme, hostIP = 127.0.0.1, port = 8200, ...'
+id=$(./curve_ops_tool chunkserver-list | grep "hostIP = ${node_ip}, port = ${port}," | sed 's/^chunkServerID = \([0-9]*\),.*$/\1/')
+if [ -z "$id" ]; then
+  echo "chunkserver ${node_ip}:${port} not found in topology"
+  exit 0
+fi
+
+./curve_ops_tool set-chunkserver -chunkserver_id=$id -chunkserver_status=pendding
+if [ $? -ne 0 ]; then
+  echo "failed to set chunkserver ${node_ip}:${port} pendding"
+  exit 1
+fi
+
+while true; do
+  line=$(./curve_ops_tool chunkserver-list | grep "hostIP = ${node_ip}, port = ${port},")
+  if [ -z "$line" ]; then
+    echo "chunkserver ${node_ip}:${port} not found in topology"
+    exit 0
+  fi
+  if echo "$line" | grep -qE "RETIRED|copysetNum = 0(,|$)"; then
+    echo "copysets of chunkserver ${node_ip}:${port} have been migrated"
+    exit 0
+  fi
+  echo "waiting for copysets of chunkserver ${node_ip}:${port} to be migrated"
+  sleep 30
+done
+`
+
+// WIPE erases the signatures of the device of a retired chunkserver, it fails while the device is still
+// mounted by the chunkserver pod and is retried by the job. The bcache of the device and its cache device is
+// stopped and the cache device is wiped too.
+var WIPE = `
+device_name=$1
+cache_device=$2
+mapper=/dev/mapper/curve-$(basename $(readlink -f $device_name))
+
+if [ -n "$cache_device" ]; then
+  base=$(basename $(readlink -f $device_name))
+  cache_base=$(basename $(readlink -f $cache_device))
+  if [ -e /sys/class/block/$base/bcache/dev ]; then
+    bcache=/dev/$(basename $(readlink -f /sys/class/block/$base/bcache/dev))
+    if grep -q "^${bcache} " /proc/1/mounts; then
+      nsenter -t 1 -m umount $bcache || exit 1
+    fi
+    echo 1 > /sys/class/block/$base/bcache/stop
+  fi
+  if [ -e /sys/class/block/$cache_base/bcache/set ]; then
+    echo 1 > /sys/class/block/$cache_base/bcache/set/unregister
+  fi
+  # the devices are released asynchronously
+  sleep 3
+  wipefs -a $cache_device || exit 1
+fi
+
+if grep -q "^${device_name} " /proc/1/mounts; then
+  nsenter -t 1 -m umount $device_name || exit 1
+fi
+# the encrypted device is closed before wiping
+if [ -b "$mapper" ]; then
+  if grep -q "^${mapper} " /proc/1/mounts; then
+    nsenter -t 1 -m umount $mapper || exit 1
+  fi
+  nsenter -t 1 -m -- cryptsetup close $(basename $mapper) || exit 1
+fi
+wipefs -a $device_name || exit 1
+echo "device ${device_name} has been wiped"
+`
@@ -0,0 +1,28 @@
+package script
+
+// SMART checks the overall health of the devices by smartctl. A line "<device> <Passed|Failing|Unknown> <message>"
+// is written to the termination message of the container for each device.
+var SMART = `
+result=/dev/termination-log
+: > $result
+
+for device in "$@"; do
+  if ! command -v smartctl > /dev/null; then
+    echo "$device Unknown smartctl is not found" | tee -a $result
+    continue
+  fi
+
+  output=$(smartctl -H "$device" 2>&1)
+  rc=$?
+  message=$(echo "$output" | grep -iE "overall-health|health status" | head -1 | tr -s ' ')
+
+  # bit 0-2 of the exit status are the errors of command or device, and bit 3-4 mean the disk is failing
+  if [ $((rc & 7)) -ne 0 ]; then
+    echo "$device Unknown $(echo "$output" | tail -1 | tr -s ' ')" | tee -a $result
+  elif [ $((rc & 24)) -ne 0 ]; then
+    echo "$device Failing ${message:-smartctl exit status $rc}" | tee -a $result
+  else
+    echo "$device Passed ${message}" | tee -a $result
+  fi
+done
+`
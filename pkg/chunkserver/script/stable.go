@@ -0,0 +1,20 @@
+package script
+
+// stableLink defines stable_link that prints the link of the block device under /dev/disk that doesn't change
+// after the node reboots, the wwn and eui of the disk are preferred over the other ids and the path of the port.
+// Nothing is printed if the device is given by such a link already or there is none.
+const stableLink = `
+stable_link() {
+  local device=$1 target link
+  case $device in
+    /dev/disk/*) return 0 ;;
+  esac
+  target=$(readlink -f $device)
+  for link in /dev/disk/by-id/wwn-* /dev/disk/by-id/nvme-eui.* /dev/disk/by-id/* /dev/disk/by-path/*; do
+    if [ -L "$link" ] && [ "$(readlink -f $link)" == "$target" ]; then
+      echo $link
+      return 0
+    fi
+  done
+}
+`
@@ -1,21 +1,103 @@
 package script
 
-var START = `
+import (
+	"fmt"
+	"strings"
+)
+
+// StartScript returns START that passes the extra flags to chunkserver after the flags of the script,
+// chunkserver takes the last one of a repeated flag so the extra flags override the ones of the script
+func StartScript(extraArgs []string) string {
+	lines := make([]string, 0, len(extraArgs))
+	for _, arg := range extraArgs {
+		// single quotes keep the arg as is, a single quote in it is closed, escaped and reopened
+		lines = append(lines, fmt.Sprintf("  '%s'\n", strings.ReplaceAll(arg, "'", `'\''`)))
+	}
+	return "extra_args=(\n" + strings.Join(lines, "") + ")\n" + START
+}
+
+var START = bindVFIO + openLUKS + attachLoop + setupBcache + `
 device_name=$1
 device_mount_path=$2
 data_dir=$3
 node_ip=$4
 service_port=$5
 conf_path=$6
+device_type=$7
+filesystem=$8
+mount_options=$9
+marker=${10}
+cpu_set=${11}
+numa_aligned=${12}
+engine=${13}
+encrypted=${14}
+cache_device=${15}
+
+if [ "$engine" == "spdk" ]; then
+  # the binding doesn't survive a reboot of the node
+  bind_vfio $device_name $device_mount_path/spdk.pci || exit 1
+  export SPDK_PCI_ADDRESS=$(cat $device_mount_path/spdk.pci)
+elif [ "$device_type" != "path" ]; then
+  # the loop device is detached when the node reboots
+  if [ "$device_type" == "loop" ]; then
+    attach_loop $device_name || exit 1
+  fi
+  if [ -n "$cache_device" ]; then
+    setup_bcache $device_name $cache_device "" false || exit 1
+  fi
+  if [ "$encrypted" == "true" ]; then
+    open_luks $device_name false || exit 1
+    device_name=/dev/mapper/curve-$(basename $(readlink -f $device_name))
+  fi
+  mkdir -p $device_mount_path
+  if [ -n "$mount_options" ]; then
+    mount -t $filesystem -o $mount_options $device_name $device_mount_path
+  else
+    mount -t $filesystem $device_name $device_mount_path
+  fi
+fi
 
-mkdir -p $device_mount_path
-mount $device_name $device_mount_path
 
+# bind to the cpus of the NUMA node that the device is attached to
+if [ -z "$cpu_set" ] && [ "$numa_aligned" == "true" ] && [ "$device_type" != "path" ]; then
+  numa_node=-1
+  if [ "$engine" == "spdk" ]; then
+    numa_node=$(cat /sys/bus/pci/devices/$SPDK_PCI_ADDRESS/numa_node)
+  else
+    dev=$(basename $(readlink -f $device_name))
+    # a partition is attached to the NUMA node of its disk
+    if [ -e /sys/class/block/$dev/partition ]; then
+      dev=$(basename $(dirname $(readlink -f /sys/class/block/$dev)))
+    fi
+    # nvme namespaces are under their controllers
+    for f in /sys/class/block/$dev/device/numa_node /sys/class/block/$dev/device/device/numa_node; do
+      if [ -r $f ]; then
+        numa_node=$(cat $f)
+        break
+      fi
+    done
+  fi
+  if [ "$numa_node" -ge 0 ]; then
+    cpu_set=$(cat /sys/devices/system/node/node$numa_node/cpulist)
+  else
+    echo "NUMA node of $device_name is unknown, chunkserver is not bound"
+  fi
+fi
+
+# chunkserver inherits the cpu affinity of the shell
+if [ -n "$cpu_set" ]; then
+  if ! taskset -pc "$cpu_set" $$; then
+    echo "failed to bind to cpus $cpu_set which may not be allowed to the pod, chunkserver is not bound"
+  fi
+fi
 
 # for test
 # while true; do echo hello; sleep 10;done
 
 cd /curvebs/chunkserver/sbin
+
+# the marker is removed only if chunkserver exits normally, so that an unclean shutdown can be detected
+date > "$marker"
 ./curvebs-chunkserver \
   -conf="${conf_path}" \
   -enableExternalServer=false \
@@ -39,5 +121,19 @@ cd /curvebs/chunkserver/sbin
   -chunkServerPort=${service_port} \
   -walFilePoolMetaPath="${data_dir}"/walfilepool.meta \
   -recycleUri=local://"${data_dir}"/recycler \
-  -graceful_quit_on_sigterm=true
+  -graceful_quit_on_sigterm=true \
+  "${extra_args[@]}" &
+
+# forward SIGTERM to chunkserver to quit gracefully, the preStop hook stops it by the pid file
+pid=$!
+echo $pid > /tmp/curvebs-chunkserver.pid
+trap 'kill -TERM $pid' TERM
+wait $pid
+# wait again if it's interrupted by the trap
+wait $pid
+code=$?
+if [ $code -eq 0 ]; then
+  rm -f "$marker"
+fi
+exit $code
 `
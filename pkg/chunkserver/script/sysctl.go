@@ -0,0 +1,44 @@
+package script
+
+// setSysctls defines set_sysctls that writes the kernel parameters "name=value" in the lines of $1 to /proc/sys
+// and reads them back, fail is called for each parameter that can't be set. The container must be privileged.
+const setSysctls = `
+set_sysctls() {
+  local name value path actual
+  while IFS='=' read -r name value; do
+    [ -z "$name" ] && continue
+    path="/proc/sys/${name//.//}"
+    if [ ! -f "$path" ]; then
+      fail "sysctl $name is not supported by the kernel"
+      continue
+    fi
+    if ! echo "$value" > "$path" 2>/dev/null; then
+      fail "sysctl $name can not be set to $value"
+      continue
+    fi
+    actual=$(tr -s '[:space:]' ' ' < "$path" | sed 's/ *$//')
+    if [ "$actual" != "$(echo $value)" ]; then
+      fail "sysctl $name is $actual instead of $value"
+    else
+      echo "PASS: sysctl $name is $value"
+    fi
+  done <<< "$1"
+}
+`
+
+// SYSCTL sets the kernel parameters of storage.sysctls before chunkserver starts, the failures are written to
+// the termination message of the container.
+var SYSCTL = setSysctls + `
+failures=()
+fail() {
+  echo "FAIL: $*"
+  failures+=("$*")
+}
+
+set_sysctls "$1"
+
+if [ ${#failures[@]} -gt 0 ]; then
+  printf '%s; ' "${failures[@]}" > /dev/termination-log
+  exit 1
+fi
+`
@@ -0,0 +1,36 @@
+package script
+
+// bindVFIO defines bind_vfio that binds the NVMe controller of a device to vfio-pci for the spdk engine.
+// The pci address is recorded in a file because the device node is gone after the binding.
+const bindVFIO = `
+bind_vfio() {
+  local device=$1 record=$2 pci driver
+  if [ -b "$device" ]; then
+    # the namespace block device is under its controller
+    pci=$(basename $(readlink -f /sys/class/block/$(basename $(readlink -f $device))/device/device))
+    echo $pci > $record
+  elif [ -f "$record" ]; then
+    pci=$(cat $record)
+  else
+    echo "device $device is not found and it was not bound to vfio-pci before"
+    return 1
+  fi
+
+  driver=$(basename $(readlink -f /sys/bus/pci/devices/$pci/driver))
+  if [ "$driver" == "vfio-pci" ]; then
+    return 0
+  fi
+  echo vfio-pci > /sys/bus/pci/devices/$pci/driver_override
+  if [ -e /sys/bus/pci/devices/$pci/driver ]; then
+    echo $pci > /sys/bus/pci/devices/$pci/driver/unbind
+  fi
+  echo $pci > /sys/bus/pci/drivers_probe
+
+  driver=$(basename $(readlink -f /sys/bus/pci/devices/$pci/driver))
+  if [ "$driver" != "vfio-pci" ]; then
+    echo "failed to bind $pci of $device to vfio-pci, the vfio-pci module must be loaded on the node"
+    return 1
+  fi
+  echo "$pci of $device is bound to vfio-pci"
+}
+`
@@ -0,0 +1,30 @@
+package chunkserver
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// MetricsPortName is the name of the port of the chunkserver services that serves the metrics, the chunkserver
+// serves them on its own port
+const MetricsPortName = "metrics"
+
+// createService creates the headless service of the chunkserver with the labels of its pod, so the scrapers find
+// every chunkserver by its stable DNS name whether or not it runs in host network
+func (c *Cluster) createService(csConfig *chunkserverConfig) error {
+	svc := k8sutil.MakeHeadlessService(csConfig.ResourceName, c.namespacedName.Namespace, c.getChunkServerPodLabels(csConfig),
+		map[string]int{MetricsPortName: csConfig.Port})
+
+	k8sutil.InjectMetadata(c.spec, "chunkserver", svc)
+	err := c.ownerInfo.SetControllerReference(svc)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set owner reference to chunkserver service %q", csConfig.ResourceName)
+	}
+
+	err = k8sutil.Apply(c.context.Client, svc)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create chunkserver service %q", csConfig.ResourceName)
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package chunkserver
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+	batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver/script"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+const (
+	retireJobNameFormat = "curve-chunkserver-retire-%s"
+	wipeJobNameFormat   = "curve-chunkserver-wipe-%s"
+)
+
+// RetireJobName returns the name of the job retiring the chunkserver of the device on the node
+func RetireJobName(nodeName, deviceName string) string {
+	return k8sutil.TruncateNodeNameForJob(retireJobNameFormat, nodeName+"-"+deviceBaseName(deviceName))
+}
+
+// RemovedDevices returns the formatted devices that have been removed from the device list of their nodes,
+// sorted by node and device. The devices of the nodes that are not storage nodes any more are not included.
+func (c *Cluster) RemovedDevices() ([]DeviceRecord, error) {
+	records, err := c.loadInventory()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load formatted device inventory")
+	}
+
+	nodeDevices := map[string][]curvev1.DevicesSpec{}
+	if c.spec.Storage.UseSelectedNodes {
+		for _, node := range c.spec.Storage.SelectedNodes {
+			nodeDevices[node.Node] = node.Devices
+		}
+	} else {
+		for _, nodeName := range c.spec.Storage.Nodes {
+			nodeDevices[nodeName] = c.spec.Storage.Devices
+		}
+	}
+
+	var names []string
+	for nodeName := range nodeDevices {
+		names = append(names, nodeName)
+	}
+	resolved, err := k8sutil.ResolveNodeNames(c.context.Clientset, names)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve storage nodes")
+	}
+
+	storageNodes := map[string]bool{}
+	wanted := map[string]bool{}
+	for nodeName, devices := range nodeDevices {
+		storageNodes[resolved[nodeName]] = true
+		for _, device := range devices {
+			wanted[inventoryKey(resolved[nodeName], device.Name)] = true
+		}
+	}
+
+	var removed []DeviceRecord
+	for key, r := range formattedRecords(records) {
+		if storageNodes[r.NodeName] && !wanted[key] {
+			removed = append(removed, r)
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool {
+		return inventoryKey(removed[i].NodeName, removed[i].DeviceName) < inventoryKey(removed[j].NodeName, removed[j].DeviceName)
+	})
+	return removed, nil
+}
+
+// RunRetireJob creates a job to set the chunkserver of the removed device pendding in topology
+// and wait for its copysets to be migrated to other chunkservers
+func (c *Cluster) RunRetireJob(r DeviceRecord, nodeIP string) (*batch.Job, error) {
+	jobName := RetireJobName(r.NodeName, r.DeviceName)
+	labels := map[string]string{
+		"app":           AppName,
+		"retire":        r.ChunkServer,
+		"curve_cluster": c.namespacedName.Namespace,
+	}
+
+	volumes, mounts := c.createTopoAndToolVolumeAndMount()
+	podSpec := v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   jobName,
+			Labels: labels,
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:            "retire",
+					Command:         []string{"/bin/bash"},
+					Args:            []string{"-c", script.RETIRE, "retire", nodeIP, strconv.Itoa(r.Port)},
+					Image:           c.spec.CurveVersion.Image,
+					ImagePullPolicy: c.spec.CurveVersion.ImagePullPolicy,
+					VolumeMounts:    mounts,
+				},
+			},
+			RestartPolicy: v1.RestartPolicyOnFailure,
+			HostNetwork:   true,
+			DNSPolicy:     v1.DNSClusterFirstWithHostNet,
+			Volumes:       volumes,
+		},
+	}
+	k8sutil.InjectEnv(&podSpec.Spec, c.spec, "chunkserver")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, c.spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, c.spec)
+	k8sutil.InjectDNS(&podSpec.Spec, c.spec, "chunkserver")
+
+	job := &batch.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jobName,
+			Namespace: c.namespacedName.Namespace,
+			Labels:    labels,
+		},
+		Spec: batch.JobSpec{
+			Template: podSpec,
+		},
+	}
+
+	k8sutil.InjectMetadata(c.spec, "chunkserver", job, &job.Spec.Template)
+	err := c.ownerInfo.SetControllerReference(job)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to set owner reference to retire job %q", job.Name)
+	}
+
+	err = k8sutil.RunReplaceableJob(context.TODO(), c.context.Clientset, job, false)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to run job %s", job.Name)
+	}
+	logger.Infof("created job %s to retire chunkserver %s", job.Name, r.ChunkServer)
+
+	return job, nil
+}
+
+// RemoveChunkServer deletes the deployment, service and configmap of the retired chunkserver and forgets its device,
+// the block device is wiped if wipeRemovedDevices is set. The other chunkservers on the node are not touched.
+func (c *Cluster) RemoveChunkServer(r DeviceRecord) error {
+	namespace := c.namespacedName.Namespace
+	clientset := c.context.Clientset
+
+	k8sutil.ExpectDelete("Deployment", namespace, r.ChunkServer)
+	err := clientset.AppsV1().Deployments(namespace).Delete(r.ChunkServer, &metav1.DeleteOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete chunkserver deployment %q", r.ChunkServer)
+	}
+	logger.Infof("deleted chunkserver deployment %q", r.ChunkServer)
+
+	k8sutil.ExpectDelete("Service", namespace, r.ChunkServer)
+	err = clientset.CoreV1().Services(namespace).Delete(r.ChunkServer, &metav1.DeleteOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete chunkserver service %q", r.ChunkServer)
+	}
+
+	cmName := configMapName(r.NodeName, r.DeviceName)
+	k8sutil.ExpectDelete("ConfigMap", namespace, cmName)
+	err = clientset.CoreV1().ConfigMaps(namespace).Delete(cmName, &metav1.DeleteOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete chunkserver configmap %q", cmName)
+	}
+
+	// the backing file of a loop device is kept until the cluster is cleaned up
+	if c.spec.Storage.WipeRemovedDevices && r.DeviceType != string(curvev1.DeviceTypePath) && r.DeviceType != string(curvev1.DeviceTypeLoop) && !c.spec.Storage.IsSPDK() {
+		job, err := c.makeWipeJob(r)
+		if err != nil {
+			return err
+		}
+		err = k8sutil.RunReplaceableJob(context.TODO(), clientset, job, true)
+		if err != nil {
+			return errors.Wrapf(err, "failed to run job %s", job.Name)
+		}
+		logger.Infof("created job %s to wipe device %s on node %s", job.Name, r.DeviceName, r.NodeName)
+	}
+
+	// the device is formatted again if it's added back
+	for _, jobName := range []string{prepareJobName(r.NodeName, r.DeviceName), RetireJobName(r.NodeName, r.DeviceName)} {
+		if err := k8sutil.DeleteBatchJob(context.TODO(), clientset, namespace, jobName, false); err != nil {
+			return err
+		}
+	}
+
+	return c.removeInventory(r.NodeName, r.DeviceName)
+}
+
+func (c *Cluster) makeWipeJob(r DeviceRecord) (*batch.Job, error) {
+	jobName := k8sutil.TruncateNodeNameForJob(wipeJobNameFormat, r.NodeName+"-"+deviceBaseName(r.DeviceName))
+	labels := map[string]string{
+		"app":           AppName,
+		"wipe":          r.ChunkServer,
+		"curve_cluster": c.namespacedName.Namespace,
+	}
+
+	privileged := true
+	runAsUser := int64(0)
+
+	podSpec := v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   jobName,
+			Labels: labels,
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:            "wipe",
+					Command:         []string{"/bin/bash"},
+					Args:            []string{"-c", script.WIPE, "wipe", r.devicePath(), r.CacheDevice},
+					Image:           c.spec.CurveVersion.Image,
+					ImagePullPolicy: c.spec.CurveVersion.ImagePullPolicy,
+					VolumeMounts: []v1.VolumeMount{
+						{Name: "devices", MountPath: "/dev"},
+					},
+					SecurityContext: &v1.SecurityContext{
+						Privileged: &privileged,
+						RunAsUser:  &runAsUser,
+					},
+				},
+			},
+			NodeName:      r.NodeName,
+			RestartPolicy: v1.RestartPolicyOnFailure,
+			HostPID:       true,
+			Volumes: []v1.Volume{
+				{Name: "devices", VolumeSource: v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: config.GetOperatorSettings().HostDevDir}}},
+			},
+		},
+	}
+	k8sutil.InjectEnv(&podSpec.Spec, c.spec, "chunkserver")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, c.spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, c.spec)
+	k8sutil.InjectDNS(&podSpec.Spec, c.spec, "chunkserver")
+
+	job := &batch.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jobName,
+			Namespace: c.namespacedName.Namespace,
+			Labels:    labels,
+		},
+		Spec: batch.JobSpec{
+			Template: podSpec,
+		},
+	}
+
+	k8sutil.InjectMetadata(c.spec, "chunkserver", job, &job.Spec.Template)
+	err := c.ownerInfo.SetControllerReference(job)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to set owner reference to wipe job %q", job.Name)
+	}
+
+	return job, nil
+}
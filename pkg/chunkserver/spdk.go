@@ -0,0 +1,62 @@
+package chunkserver
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	hugePagesVolumeName = "hugepages"
+	hugePagesMountPath  = "/dev/hugepages"
+)
+
+// spdkMemoryRequest is the memory requested by a spdk chunkserver besides hugepages,
+// kubernetes requires the hugepages to come with cpu or memory requests
+var spdkMemoryRequest = resource.MustParse("1Gi")
+
+// engineConfig returns the items of chunkserver.conf that are set by the engine
+func (c *Cluster) engineConfig() map[string]string {
+	if !c.spec.Storage.IsSPDK() {
+		return nil
+	}
+	// the chunks are allocated from the NVMe device by spdk
+	return map[string]string{
+		"fs.type": "spdk",
+		"chunkfilepool.enable_get_chunk_from_pool": "false",
+		"walfilepool.enable_get_segment_from_pool": "false",
+	}
+}
+
+// setHugePages requests the hugepages of storage.spdk for the chunkserver container and mounts them
+func (c *Cluster) setHugePages(podSpec *v1.PodSpec) {
+	if !c.spec.Storage.IsSPDK() {
+		return
+	}
+
+	name := v1.ResourceName(v1.ResourceHugePagesPrefix + c.spec.Storage.SPDK.GetHugePageSize())
+	hugePages := c.spec.Storage.SPDK.GetHugePages()
+	podSpec.Volumes = append(podSpec.Volumes, v1.Volume{
+		Name:         hugePagesVolumeName,
+		VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{Medium: v1.StorageMediumHugePages}},
+	})
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+		if container.Name != "chunkserver" {
+			continue
+		}
+		container.VolumeMounts = append(container.VolumeMounts, v1.VolumeMount{Name: hugePagesVolumeName, MountPath: hugePagesMountPath})
+
+		// hugepages can't be overcommitted, the requests must equal the limits
+		if container.Resources.Requests == nil {
+			container.Resources.Requests = v1.ResourceList{}
+		}
+		if container.Resources.Limits == nil {
+			container.Resources.Limits = v1.ResourceList{}
+		}
+		container.Resources.Requests[name] = hugePages
+		container.Resources.Limits[name] = hugePages
+		if _, ok := container.Resources.Requests[v1.ResourceMemory]; !ok {
+			container.Resources.Requests[v1.ResourceMemory] = spdkMemoryRequest
+		}
+	}
+}
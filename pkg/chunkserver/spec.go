@@ -1,7 +1,9 @@
 package chunkserver
 
 import (
+	"fmt"
 	"path"
+	"sort"
 	"strconv"
 	"time"
 
@@ -14,25 +16,16 @@ import (
 
 	"github.com/opencurve/curve-operator/pkg/chunkserver/script"
 	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/daemon"
 )
 
 // startChunkServers start all chunkservers for each device of every node
 func (c *Cluster) startChunkServers() error {
-	if len(job2DeviceInfos) == 0 {
-		logger.Errorf("no job to format device and provision chunk file")
-		return nil
-	}
-
-	if len(chunkserverConfigs) == 0 {
+	if len(c.chunkserverConfigs) == 0 {
 		logger.Errorf("no device need to start chunkserver")
 		return nil
 	}
 
-	if len(job2DeviceInfos) != len(chunkserverConfigs) {
-		logger.Errorf("no device need to start chunkserver")
-		return errors.New("failed to start chunkserver because of job numbers is not equal with chunkserver config")
-	}
-
 	_ = c.createStartCSConfigMap()
 
 	_ = c.createCSClientConfigMap()
@@ -40,7 +33,9 @@ func (c *Cluster) startChunkServers() error {
 	_ = c.CreateS3ConfigMap()
 
 	deploymentsToWaitFor := make([]*appsv1.Deployment, 0)
-	for _, csConfig := range chunkserverConfigs {
+	// the changed deployments are rolled by the update strategy after all chunkservers are created
+	var changed []appsv1.Deployment
+	for _, csConfig := range c.chunkserverConfigs {
 
 		err := c.createConfigMap(csConfig)
 		if err != nil {
@@ -52,20 +47,20 @@ func (c *Cluster) startChunkServers() error {
 			return errors.Wrap(err, "failed to create chunkserver Deployment")
 		}
 
-		newDeployment, err := c.context.Clientset.AppsV1().Deployments(c.namespacedName.Namespace).Create(d)
+		if err := c.createService(&csConfig); err != nil {
+			return err
+		}
+
+		existing, created, err := k8sutil.CreateOrGetDeployment(&c.context, d)
 		if err != nil {
-			if !kerrors.IsAlreadyExists(err) {
-				return errors.Wrapf(err, "failed to create chunkserver deployment %s", csConfig.ResourceName)
-			}
-			logger.Infof("deployment for chunkserver %s already exists. updating if needed", csConfig.ResourceName)
-
-			// TODO:Update the daemon Deployment
-			// if err := updateDeploymentAndWait(c.context, c.clusterInfo, d, config.MgrType, mgrConfig.DaemonID, c.spec.SkipUpgradeChecks, false); err != nil {
-			// 	logger.Errorf("failed to update mgr deployment %q. %v", resourceName, err)
-			// }
-		} else {
-			logger.Infof("Deployment %s has been created , waiting for startup", newDeployment.GetName())
-			deploymentsToWaitFor = append(deploymentsToWaitFor, newDeployment)
+			return errors.Wrapf(err, "failed to create chunkserver deployment %s", csConfig.ResourceName)
+		}
+		if created {
+			logger.Infof("Deployment %s has been created , waiting for startup", existing.GetName())
+			deploymentsToWaitFor = append(deploymentsToWaitFor, existing)
+		} else if k8sutil.DeploymentChanged(existing, d) {
+			logger.Infof("deployment for chunkserver %s changed, it will be updated", csConfig.ResourceName)
+			changed = append(changed, *d)
 		}
 		// update condition type and phase etc.
 	}
@@ -75,6 +70,56 @@ func (c *Cluster) startChunkServers() error {
 		deploymentsToWaitFor); err != nil {
 		return err
 	}
+
+	if err := c.updateChunkServers(changed); err != nil {
+		return err
+	}
+
+	addrs := make([]string, 0, len(c.chunkserverConfigs))
+	for _, csConfig := range c.chunkserverConfigs {
+		addrs = append(addrs, fmt.Sprintf("%s:%d", csConfig.NodeIP, csConfig.Port))
+	}
+	err := config.UpdateClusterInfo(&c.context, c.namespacedName.Namespace, c.ownerInfo, c.spec, func(info *config.ClusterInfo) {
+		info.ChunkServerAddrs = addrs
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to record chunkserver endpoints")
+	}
+	return nil
+}
+
+// updateChunkServers updates the changed chunkserver deployments in batches of the update strategy
+func (c *Cluster) updateChunkServers(changed []appsv1.Deployment) error {
+	if len(changed) == 0 {
+		return nil
+	}
+	open, next, err := k8sutil.MaintenanceWindowOpen(c.spec.MaintenanceWindow, time.Now())
+	if err != nil {
+		return err
+	}
+	if !open {
+		logger.Infof("update of %d chunkserver deployments is deferred to the maintenance window at %s", len(changed), next.Format(time.RFC3339))
+		return nil
+	}
+	for i, batch := range UpdateBatches(changed, c.spec.UpdateStrategy) {
+		if i > 0 && c.spec.UpdateStrategy.PauseBetweenPods.Duration > 0 {
+			time.Sleep(c.spec.UpdateStrategy.PauseBetweenPods.Duration)
+		}
+
+		c.TransferLeaders(batch)
+		var updated []*appsv1.Deployment
+		for j := range batch {
+			d, err := k8sutil.UpdateDeployment(&c.context, &batch[j])
+			if err != nil {
+				return err
+			}
+			updated = append(updated, d)
+		}
+		if err := k8sutil.WaitForDeploymentsToStart(c.context.Clientset, 3*time.Second, k8sutil.UpdateTimeout,
+			updated); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -93,7 +138,7 @@ func (c *Cluster) createCSClientConfigMap() error {
 	// 2. read configmap data (string)
 	csClientCMData := csClientCMTemplate.Data[config.CSClientConfigMapDataKey]
 	// 3. replace ${} to specific parameters
-	replacedCsClientData, err := config.ReplaceConfigVars(csClientCMData, &chunkserverConfigs[0])
+	replacedCsClientData, err := config.ReplaceConfigVars(csClientCMData, &c.chunkserverConfigs[0])
 	if err != nil {
 		return errors.Wrap(err, "failed to Replace cs_client config template to generate a new cs_client configmap to start server.")
 	}
@@ -110,14 +155,15 @@ func (c *Cluster) createCSClientConfigMap() error {
 		Data: csClientConfigMap,
 	}
 
+	k8sutil.InjectMetadata(c.spec, "chunkserver", cm)
 	err = c.ownerInfo.SetControllerReference(cm)
 	if err != nil {
 		return errors.Wrapf(err, "failed to set owner reference to cs_client.conf configmap %q", config.CSClientConfigMapName)
 	}
 
 	// Create cs_client configmap in cluster
-	_, err = c.context.Clientset.CoreV1().ConfigMaps(c.namespacedName.Namespace).Create(cm)
-	if err != nil && !kerrors.IsAlreadyExists(err) {
+	err = k8sutil.Apply(c.context.Client, cm)
+	if err != nil {
 		return errors.Wrapf(err, "failed to create cs_client configmap %s", c.namespacedName.Namespace)
 	}
 
@@ -161,14 +207,15 @@ func (c *Cluster) CreateS3ConfigMap() error {
 		Data: s3ConfigMap,
 	}
 
+	k8sutil.InjectMetadata(c.spec, "chunkserver", cm)
 	err = c.ownerInfo.SetControllerReference(cm)
 	if err != nil {
 		return errors.Wrapf(err, "failed to set owner reference to s3.conf configmap %q", config.S3ConfigMapName)
 	}
 
 	// Create s3 configmap in cluster
-	_, err = c.context.Clientset.CoreV1().ConfigMaps(c.namespacedName.Namespace).Create(cm)
-	if err != nil && !kerrors.IsAlreadyExists(err) {
+	err = k8sutil.Apply(c.context.Client, cm)
+	if err != nil {
 		return errors.Wrapf(err, "failed to create s3 configmap %s", c.namespacedName.Namespace)
 	}
 
@@ -177,9 +224,13 @@ func (c *Cluster) CreateS3ConfigMap() error {
 
 // createConfigMap create configmap to run start_chunkserver.sh script
 func (c *Cluster) createStartCSConfigMap() error {
-	// generate configmap data with only one key of "format.sh"
+	extraArgs, err := c.extraArgs()
+	if err != nil {
+		return err
+	}
+	// generate configmap data with only one key of "start_chunkserver.sh", the extra args are rendered into it
 	startCSConfigMap := map[string]string{
-		startChunkserverScriptFileDataKey: script.START,
+		startChunkserverScriptFileDataKey: script.StartScript(extraArgs),
 	}
 
 	cm := &v1.ConfigMap{
@@ -190,14 +241,15 @@ func (c *Cluster) createStartCSConfigMap() error {
 		Data: startCSConfigMap,
 	}
 
-	err := c.ownerInfo.SetControllerReference(cm)
+	k8sutil.InjectMetadata(c.spec, "chunkserver", cm)
+	err = c.ownerInfo.SetControllerReference(cm)
 	if err != nil {
 		return errors.Wrapf(err, "failed to set owner reference to cs.conf configmap %q", startChunkserverConfigMapName)
 	}
 
-	// Create format.sh configmap in cluster
-	_, err = c.context.Clientset.CoreV1().ConfigMaps(c.namespacedName.Namespace).Create(cm)
-	if err != nil && !kerrors.IsAlreadyExists(err) {
+	// Create start_chunkserver.sh configmap in cluster, it's updated when the script changes
+	err = k8sutil.Apply(c.context.Client, cm)
+	if err != nil {
 		return errors.Wrapf(err, "failed to create override configmap %s", c.namespacedName.Namespace)
 	}
 	return nil
@@ -216,9 +268,24 @@ func (c *Cluster) createConfigMap(csConfig chunkserverConfig) error {
 	}
 
 	// 2. read configmap data (string)
-	var chunkserverData string
+	// keys are sorted to render the same config every time
+	data := map[string]string{}
 	for k, v := range chunkserverCMTemplate.Data {
-		chunkserverData += k + "=" + v + "\n"
+		data[k] = v
+	}
+	for k, v := range c.engineConfig() {
+		data[k] = v
+	}
+	// the chunks of the chunk file pool are formatted in the size of the pool of the device
+	data["global.chunk_size"] = strconv.FormatInt(csConfig.ChunkSize, 10)
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var chunkserverData string
+	for _, k := range keys {
+		chunkserverData += k + "=" + data[k] + "\n"
 	}
 
 	// 3. replace ${} to specific parameters
@@ -242,14 +309,15 @@ func (c *Cluster) createConfigMap(csConfig chunkserverConfig) error {
 		Data: chunkserverConfigMap,
 	}
 
+	k8sutil.InjectMetadata(c.spec, "chunkserver", cm)
 	err = c.ownerInfo.SetControllerReference(cm)
 	if err != nil {
 		return errors.Wrapf(err, "failed to set owner reference to chunkserverconfig configmap %q", config.ChunkserverConfigMapName)
 	}
 
 	// Create chunkserver config in cluster
-	_, err = c.context.Clientset.CoreV1().ConfigMaps(c.namespacedName.Namespace).Create(cm)
-	if err != nil && !kerrors.IsAlreadyExists(err) {
+	err = k8sutil.Apply(c.context.Client, cm)
+	if err != nil {
 		return errors.Wrapf(err, "failed to create chunkserver configmap %s", c.namespacedName.Namespace)
 	}
 
@@ -261,22 +329,52 @@ func (c *Cluster) makeDeployment(csConfig *chunkserverConfig) (*appsv1.Deploymen
 	vols, _ := c.createTopoAndToolVolumeAndMount()
 	volumes = append(volumes, vols...)
 
+	// the kernel parameters are set before the devices are checked
+	initContainers, err := c.makeSysctlContainers()
+	if err != nil {
+		return nil, err
+	}
+	// the IO limits are set on the pod cgroup before the chunkserver container is created in it
+	ioContainers, ioVolumes := c.makeIOLimitsContainers(csConfig)
+	initContainers = append(initContainers, ioContainers...)
+	volumes = append(volumes, ioVolumes...)
+	initContainers = append(initContainers, c.makeCheckContainers(csConfig)...)
+
 	podSpec := v1.PodTemplateSpec{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:   csConfig.ResourceName,
 			Labels: c.getChunkServerPodLabels(csConfig),
 		},
 		Spec: v1.PodSpec{
-			Containers: []v1.Container{
+			// chunkserver registers itself to the mds leader when it starts
+			InitContainers: append(initContainers, daemon.WaitForEndpointsInitContainer("wait-mds", csConfig.ClusterMdsAddr, c.spec.CurveVersion.Image, c.spec.CurveVersion.ImagePullPolicy)),
+			Containers: append([]v1.Container{
 				c.makeCSDaemonContainer(csConfig),
-			},
-			NodeName:      csConfig.NodeName,
-			RestartPolicy: v1.RestartPolicyAlways,
-			HostNetwork:   true,
-			DNSPolicy:     v1.DNSClusterFirstWithHostNet,
-			Volumes:       volumes,
+			}, daemon.LogRotateContainers(c.spec.Logging, csConfig.DataPathMap.ContainerLogDir, c.spec.CurveVersion.Image, c.spec.CurveVersion.ImagePullPolicy)...),
+			NodeName:          csConfig.NodeName,
+			RestartPolicy:     v1.RestartPolicyAlways,
+			HostNetwork:       true,
+			HostPID:           csConfig.Encrypted,
+			DNSPolicy:         v1.DNSClusterFirstWithHostNet,
+			Volumes:           volumes,
+			PriorityClassName: c.spec.PriorityClassName("chunkserver"),
+			// the preStop hook needs the time to stop chunkserver gracefully
+			TerminationGracePeriodSeconds: daemon.TerminationGracePeriod(c.spec.Storage.TerminationGracePeriodSeconds,
+				defaultTerminationGracePeriodSeconds),
 		},
 	}
+	k8sutil.InjectEnv(&podSpec.Spec, c.spec, "chunkserver")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, c.spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, c.spec)
+	k8sutil.InjectDNS(&podSpec.Spec, c.spec, "chunkserver")
+	if err := c.setPodTemplateOverrides(&podSpec.Spec); err != nil {
+		return nil, err
+	}
+	if err := c.setGuaranteedResources(&podSpec.Spec); err != nil {
+		return nil, err
+	}
+	c.setHugePages(&podSpec.Spec)
+	c.setMemoryLock(&podSpec.Spec)
 
 	replicas := int32(1)
 
@@ -299,7 +397,8 @@ func (c *Cluster) makeDeployment(csConfig *chunkserverConfig) (*appsv1.Deploymen
 	}
 
 	// set ownerReference
-	err := c.ownerInfo.SetControllerReference(d)
+	k8sutil.InjectMetadata(c.spec, "chunkserver", d, &d.Spec.Template)
+	err = c.ownerInfo.SetControllerReference(d)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to set owner reference to chunkserver deployment %q", d.Name)
 	}
@@ -320,7 +419,7 @@ func (c *Cluster) makeCSDaemonContainer(csConfig *chunkserverConfig) v1.Containe
 	_, mounts := c.createTopoAndToolVolumeAndMount()
 	volMounts = append(volMounts, mounts...)
 
-	argsDeviceName := csConfig.DeviceName
+	argsDeviceName := deviceArg(csConfig.devicePath(), csConfig.DeviceType)
 	argsMountPath := ChunkserverContainerDataDir
 
 	argsDataDir := path.Join(csConfig.Prefix, "data")
@@ -342,6 +441,15 @@ func (c *Cluster) makeCSDaemonContainer(csConfig *chunkserverConfig) v1.Containe
 			argsChunkServerIp,
 			argsChunkserverPort,
 			argsConfigFileMountPath,
+			string(csConfig.DeviceType),
+			csConfig.Filesystem,
+			csConfig.MountOptions,
+			uncleanShutdownMarker,
+			csConfig.CPUSet,
+			strconv.FormatBool(c.spec.Storage.CPUPinning.NUMAAligned),
+			string(c.spec.Storage.Engine),
+			strconv.FormatBool(csConfig.Encrypted),
+			csConfig.CacheDevice,
 		},
 		Image:           c.spec.CurveVersion.Image,
 		ImagePullPolicy: c.spec.CurveVersion.ImagePullPolicy,
@@ -354,7 +462,11 @@ func (c *Cluster) makeCSDaemonContainer(csConfig *chunkserverConfig) v1.Containe
 				Protocol:      v1.ProtocolTCP,
 			},
 		},
-		Env: []v1.EnvVar{{Name: "TZ", Value: "Asia/Hangzhou"}},
+		LivenessProbe:  k8sutil.MakeProbe(k8sutil.TCPProbeHandler(csConfig.Port), c.spec.Storage.LivenessProbe, k8sutil.DefaultLivenessProbe),
+		ReadinessProbe: k8sutil.MakeProbe(k8sutil.TCPProbeHandler(csConfig.Port), c.spec.Storage.ReadinessProbe, k8sutil.DefaultReadinessProbe),
+		Env:            append(append(append([]v1.EnvVar{{Name: "TZ", Value: "Asia/Hangzhou"}}, daemon.LoggingEnv(c.spec.Logging)...), daemon.LogLevelEnv(c.spec.Storage.LogLevel)...), deviceKeyEnv(csConfig.Encrypted, csConfig.KeySecret)...),
+		// chunkserver is not PID 1 of the container but a child of the start script
+		Lifecycle: daemon.PreStopHook(pidFile),
 		SecurityContext: &v1.SecurityContext{
 			Privileged:             &privileged,
 			RunAsUser:              &runAsUser,
@@ -363,6 +475,13 @@ func (c *Cluster) makeCSDaemonContainer(csConfig *chunkserverConfig) v1.Containe
 		},
 	}
 
+	// the mock chunkserver listens on nothing, so there is no probe
+	if c.spec.DevMode {
+		container.Command = []string{"/bin/bash", "-c", script.MOCK_CHUNKSERVER, "chunkserver"}
+		container.LivenessProbe = nil
+		container.ReadinessProbe = nil
+	}
+
 	return container
 }
 
@@ -374,3 +493,40 @@ func (c *Cluster) getChunkServerPodLabels(csConfig *chunkserverConfig) map[strin
 	labels["curve_cluster"] = c.namespacedName.Namespace
 	return labels
 }
+
+// makeCheckContainers returns the init container to check the data of chunkserver after an unclean shutdown,
+// chunkserver won't start until the check succeeds
+func (c *Cluster) makeCheckContainers(csConfig *chunkserverConfig) []v1.Container {
+	// there is no filesystem to check on the devices of spdk and the mock devices of dev mode
+	if !c.spec.Storage.IntegrityCheck.Enable || c.spec.Storage.IsSPDK() || c.spec.DevMode {
+		return nil
+	}
+
+	privileged := true
+	runAsUser := int64(0)
+
+	return []v1.Container{
+		{
+			Name:    "check",
+			Command: []string{"/bin/bash"},
+			Args: []string{
+				"-c", script.CHECK, "check",
+				deviceArg(csConfig.devicePath(), csConfig.DeviceType),
+				string(csConfig.DeviceType),
+				csConfig.Filesystem,
+				csConfig.DataPathMap.ContainerDataDir,
+				uncleanShutdownMarker,
+				strconv.FormatBool(csConfig.Encrypted),
+				csConfig.CacheDevice,
+			},
+			Image:           c.spec.CurveVersion.Image,
+			ImagePullPolicy: c.spec.CurveVersion.ImagePullPolicy,
+			VolumeMounts:    CSDaemonVolumeMounts(csConfig),
+			Env:             deviceKeyEnv(csConfig.Encrypted, csConfig.KeySecret),
+			SecurityContext: &v1.SecurityContext{
+				Privileged: &privileged,
+				RunAsUser:  &runAsUser,
+			},
+		},
+	}
+}
@@ -0,0 +1,47 @@
+package chunkserver
+
+import (
+	"strings"
+
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+	"github.com/opencurve/curve-operator/pkg/validation"
+)
+
+// resolveStableName returns the stable link of the kernel name of the device resolved by its prepare job, such
+// as '/dev/disk/by-id/wwn-0x5000c500a1b2c3d4'. It's empty if the device is given by a stable link already or the
+// node has none for it.
+func (c *Cluster) resolveStableName(nodeName, deviceName string) string {
+	for _, info := range c.job2DeviceInfos {
+		if info.nodeName != nodeName || info.device.Name != deviceName {
+			continue
+		}
+		message, err := k8sutil.GetJobTerminationMessage(c.context.Clientset, info.job)
+		if err != nil {
+			logger.Warningf("failed to get the stable link of device %s on %s. %v", deviceName, nodeName, err)
+			return ""
+		}
+		message = strings.TrimSpace(message)
+		if !strings.HasPrefix(message, validation.StableDeviceDir) {
+			return ""
+		}
+		logger.Infof("device %s on %s is resolved to %s", deviceName, nodeName, message)
+		return message
+	}
+	return ""
+}
+
+// devicePath returns the stable link of the device if it's resolved, the chunkserver uses it because the kernel
+// name may refer to another device after the node reboots
+func (r DeviceRecord) devicePath() string {
+	if r.StableName != "" {
+		return r.StableName
+	}
+	return r.DeviceName
+}
+
+func (c chunkserverConfig) devicePath() string {
+	if c.StableName != "" {
+		return c.StableName
+	}
+	return c.DeviceName
+}
@@ -0,0 +1,62 @@
+package chunkserver
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/opencurve/curve-operator/pkg/chunkserver/script"
+)
+
+// sysctlName is the name of a kernel parameter such as fs.aio-max-nr
+var sysctlName = regexp.MustCompile(`^[a-z0-9_]+(\.[a-zA-Z0-9_-]+)+$`)
+
+// sysctls returns the kernel parameters of storage.sysctls as the lines of "name=value" in order of name
+func (c *Cluster) sysctls() (string, error) {
+	names := make([]string, 0, len(c.spec.Storage.Sysctls))
+	for name, value := range c.spec.Storage.Sysctls {
+		if !sysctlName.MatchString(name) {
+			return "", errors.Errorf("storage.sysctls has invalid name %q", name)
+		}
+		if strings.ContainsAny(value, "\n=") {
+			return "", errors.Errorf("storage.sysctls has invalid value %q of %q", value, name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		lines = append(lines, name+"="+c.spec.Storage.Sysctls[name])
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// makeSysctlContainers returns the init container that sets the kernel parameters before chunkserver starts,
+// it's nil if storage.sysctls is not set
+func (c *Cluster) makeSysctlContainers() ([]v1.Container, error) {
+	sysctls, err := c.sysctls()
+	if err != nil || sysctls == "" {
+		return nil, err
+	}
+
+	privileged := true
+	runAsUser := int64(0)
+
+	return []v1.Container{
+		{
+			Name:            "sysctl",
+			Command:         []string{"/bin/bash"},
+			Args:            []string{"-c", script.SYSCTL, "sysctl", sysctls},
+			Image:           c.spec.CurveVersion.Image,
+			ImagePullPolicy: c.spec.CurveVersion.ImagePullPolicy,
+			SecurityContext: &v1.SecurityContext{
+				Privileged: &privileged,
+				RunAsUser:  &runAsUser,
+			},
+		},
+	}, nil
+}
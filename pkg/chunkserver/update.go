@@ -0,0 +1,49 @@
+package chunkserver
+
+import (
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+// UpdateBatches splits the chunkserver deployments into batches by the update strategy,
+// the deployments in a batch are restarted at the same time
+func UpdateBatches(deployments []appsv1.Deployment, strategy curvev1.UpdateStrategySpec) [][]appsv1.Deployment {
+	maxUnavailable := strategy.MaxUnavailable
+	if maxUnavailable <= 0 {
+		maxUnavailable = 1
+	}
+
+	sorted := append([]appsv1.Deployment{}, deployments...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	groups := [][]appsv1.Deployment{sorted}
+	if strategy.PodRestartOrder == curvev1.PodRestartOrderNodeByNode {
+		groups = nil
+		index := map[string]int{}
+		for _, d := range sorted {
+			nodeName := d.Spec.Template.Spec.NodeName
+			if _, ok := index[nodeName]; !ok {
+				index[nodeName] = len(groups)
+				groups = append(groups, nil)
+			}
+			groups[index[nodeName]] = append(groups[index[nodeName]], d)
+		}
+	}
+
+	var batches [][]appsv1.Deployment
+	for _, group := range groups {
+		for start := 0; start < len(group); start += maxUnavailable {
+			end := start + maxUnavailable
+			if end > len(group) {
+				end = len(group)
+			}
+			batches = append(batches, group[start:end])
+		}
+	}
+	return batches
+}
@@ -50,24 +50,38 @@ func (c *Cluster) createFormatVolumeAndMount(device curvev1.DevicesSpec) ([]v1.V
 	vols = append(vols, configVol)
 	mounts = append(mounts, formatCMVolumeMount)
 
-	// 2. create hostpath volume and volume mount for device.MountPath
+	// 2. create hostpath volume and volume mount for device.MountPath,
+	// or for the directory itself that must already exist on host if it's a path device
+	hostPath := device.MountPath
 	hostPathType := v1.HostPathDirectoryOrCreate
-	volumeName := strings.TrimSpace(device.MountPath)
+	if device.IsPath() {
+		hostPath = device.Name
+		hostPathType = v1.HostPathDirectory
+	}
+	volumeName := strings.TrimSpace(hostPath)
 	volumeName = strings.TrimRight(volumeName, "/")
 	volumeNameArr := strings.Split(volumeName, "/")
 	volumeName = volumeNameArr[len(volumeNameArr)-1]
 	// volume name : chunkserver-data-chunkserver0
 	tmpVolumeName := chunkserverVolumeName + "-" + volumeName
 
-	src := v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: device.MountPath, Type: &hostPathType}}
+	src := v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: hostPath, Type: &hostPathType}}
 	vols = append(vols, v1.Volume{Name: tmpVolumeName, VolumeSource: src})
 	mounts = append(mounts, v1.VolumeMount{Name: tmpVolumeName, MountPath: ChunkserverContainerDataDir})
 
 	// 3. Create hostpath volume and volume mount for '/dev'
-	src = v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: "/dev"}}
+	src = v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: config.GetOperatorSettings().HostDevDir}}
 	vols = append(vols, v1.Volume{Name: "devices", VolumeSource: src})
 	mounts = append(mounts, v1.VolumeMount{Name: "devices", MountPath: "/dev"})
 
+	// 4. Create hostpath volume and volume mount for the backing file of loop device
+	if device.IsLoop() {
+		loopPathType := v1.HostPathDirectoryOrCreate
+		src = v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: c.loopHostDir(), Type: &loopPathType}}
+		vols = append(vols, v1.Volume{Name: "loop-volume", VolumeSource: src})
+		mounts = append(mounts, v1.VolumeMount{Name: "loop-volume", MountPath: ChunkserverContainerLoopDir})
+	}
+
 	return vols, mounts
 }
 
@@ -85,7 +99,7 @@ func CSDaemonVolumes(csConfig *chunkserverConfig) []v1.Volume {
 	vols = append(vols, configMapVolumes...)
 
 	// create hostpath volume for '/dev'
-	src := v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: "/dev"}}
+	src := v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: config.GetOperatorSettings().HostDevDir}}
 	vols = append(vols, v1.Volume{Name: "dev-volume", VolumeSource: src})
 
 	// create logs volume for
@@ -93,6 +107,20 @@ func CSDaemonVolumes(csConfig *chunkserverConfig) []v1.Volume {
 	src = v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: csConfig.DataPathMap.HostLogDir, Type: &hostPathType}}
 	vols = append(vols, v1.Volume{Name: "log-volume", VolumeSource: src})
 
+	// create data volume for path device, block device is mounted by start_chunkserver.sh
+	if csConfig.DataPathMap.HostDataDir != "" {
+		dataPathType := v1.HostPathDirectory
+		src = v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: csConfig.DataPathMap.HostDataDir, Type: &dataPathType}}
+		vols = append(vols, v1.Volume{Name: "data-volume", VolumeSource: src})
+	}
+
+	// create loop volume for the backing file of loop device
+	if csConfig.DataPathMap.HostLoopDir != "" {
+		loopPathType := v1.HostPathDirectory
+		src = v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: csConfig.DataPathMap.HostLoopDir, Type: &loopPathType}}
+		vols = append(vols, v1.Volume{Name: "loop-volume", VolumeSource: src})
+	}
+
 	return vols
 }
 
@@ -107,6 +135,12 @@ func CSDaemonVolumeMounts(csConfig *chunkserverConfig) []v1.VolumeMount {
 	// create data mount path and log mount path on container
 	mounts = append(mounts, v1.VolumeMount{Name: "dev-volume", MountPath: "/dev"})
 	mounts = append(mounts, v1.VolumeMount{Name: "log-volume", MountPath: csConfig.DataPathMap.ContainerLogDir})
+	if csConfig.DataPathMap.HostDataDir != "" {
+		mounts = append(mounts, v1.VolumeMount{Name: "data-volume", MountPath: csConfig.DataPathMap.ContainerDataDir})
+	}
+	if csConfig.DataPathMap.HostLoopDir != "" {
+		mounts = append(mounts, v1.VolumeMount{Name: "loop-volume", MountPath: ChunkserverContainerLoopDir})
+	}
 
 	return mounts
 }
@@ -259,3 +293,16 @@ func (c *Cluster) createTopoAndToolVolumeAndMount() ([]v1.Volume, []v1.VolumeMou
 
 	return vols, mounts
 }
+
+// loopHostDir returns the directory on host that keeps the backing files of loop devices
+func (c *Cluster) loopHostDir() string {
+	return path.Join(c.dataDirHostPath, "loop")
+}
+
+// deviceArg returns the device passed to the scripts, which is the backing file in container of a loop device
+func deviceArg(deviceName string, deviceType curvev1.DeviceType) string {
+	if deviceType == curvev1.DeviceTypeLoop {
+		return path.Join(ChunkserverContainerLoopDir, deviceName)
+	}
+	return deviceName
+}
@@ -0,0 +1,188 @@
+// Package client is the typed client of the operator.curve.io/v1 resources, so the services other than the
+// operator can create, update and watch CurveCluster objects without the unstructured client. The CurveClusters
+// can be watched by the informer and listed from its cache by the lister.
+package client
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+var (
+	// Scheme has the operator.curve.io/v1 types that the clientset encodes and decodes
+	Scheme = runtime.NewScheme()
+	// Codecs are the codecs of Scheme
+	Codecs         = serializer.NewCodecFactory(Scheme)
+	parameterCodec = runtime.NewParameterCodec(Scheme)
+)
+
+func init() {
+	if err := curvev1.AddToScheme(Scheme); err != nil {
+		panic(err)
+	}
+	// the list and delete options are encoded by the group version of the resources
+	metav1.AddToGroupVersion(Scheme, curvev1.GroupVersion)
+}
+
+// Interface is the clientset of the operator.curve.io/v1 resources
+type Interface interface {
+	RESTClient() rest.Interface
+	CurveClusters(namespace string) CurveClusterInterface
+	CurveFleets() CurveFleetInterface
+	CurveUsers(namespace string) CurveUserInterface
+	CurveQoSPolicies(namespace string) CurveQoSPolicyInterface
+}
+
+// Clientset is the clientset of the operator.curve.io/v1 resources
+type Clientset struct {
+	restClient rest.Interface
+}
+
+var _ Interface = &Clientset{}
+
+// NewForConfig returns the clientset of the config, such as the one got by ctrl.GetConfig or
+// clientcmd.BuildConfigFromFlags. The config is copied, the group version and the serializer are set in the copy.
+func NewForConfig(c *rest.Config) (*Clientset, error) {
+	config := *c
+	config.GroupVersion = &curvev1.GroupVersion
+	config.APIPath = "/apis"
+	config.NegotiatedSerializer = Codecs.WithoutConversion()
+	if config.UserAgent == "" {
+		config.UserAgent = rest.DefaultKubernetesUserAgent()
+	}
+	restClient, err := rest.RESTClientFor(&config)
+	if err != nil {
+		return nil, err
+	}
+	return New(restClient), nil
+}
+
+// NewForConfigOrDie returns the clientset of the config, it panics if the config is invalid
+func NewForConfigOrDie(c *rest.Config) *Clientset {
+	clientset, err := NewForConfig(c)
+	if err != nil {
+		panic(err)
+	}
+	return clientset
+}
+
+// New returns the clientset of the rest client
+func New(c rest.Interface) *Clientset {
+	return &Clientset{restClient: c}
+}
+
+// RESTClient returns the rest client of the operator.curve.io/v1 group version
+func (c *Clientset) RESTClient() rest.Interface {
+	return c.restClient
+}
+
+func (c *Clientset) CurveClusters(namespace string) CurveClusterInterface {
+	return &curveClusters{resourceClient{client: c.restClient, namespace: namespace, resource: "curveclusters"}}
+}
+
+func (c *Clientset) CurveFleets() CurveFleetInterface {
+	return &curveFleets{resourceClient{client: c.restClient, resource: "curvefleets"}}
+}
+
+func (c *Clientset) CurveUsers(namespace string) CurveUserInterface {
+	return &curveUsers{resourceClient{client: c.restClient, namespace: namespace, resource: "curveusers"}}
+}
+
+func (c *Clientset) CurveQoSPolicies(namespace string) CurveQoSPolicyInterface {
+	return &curveQoSPolicies{resourceClient{client: c.restClient, namespace: namespace, resource: "curveqospolicies"}}
+}
+
+// resourceClient sends the requests of a resource, the namespace is empty for the cluster scoped resources
+type resourceClient struct {
+	client    rest.Interface
+	namespace string
+	resource  string
+}
+
+func (c *resourceClient) get(name string, options metav1.GetOptions, result runtime.Object) error {
+	return c.client.Get().
+		NamespaceIfScoped(c.namespace, c.namespace != "").
+		Resource(c.resource).
+		Name(name).
+		VersionedParams(&options, parameterCodec).
+		Do().
+		Into(result)
+}
+
+func (c *resourceClient) list(opts metav1.ListOptions, result runtime.Object) error {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Get().
+		NamespaceIfScoped(c.namespace, c.namespace != "").
+		Resource(c.resource).
+		VersionedParams(&opts, parameterCodec).
+		Timeout(timeout).
+		Do().
+		Into(result)
+}
+
+func (c *resourceClient) watch(opts metav1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		NamespaceIfScoped(c.namespace, c.namespace != "").
+		Resource(c.resource).
+		VersionedParams(&opts, parameterCodec).
+		Timeout(timeout).
+		Watch()
+}
+
+func (c *resourceClient) create(obj, result runtime.Object) error {
+	return c.client.Post().
+		NamespaceIfScoped(c.namespace, c.namespace != "").
+		Resource(c.resource).
+		Body(obj).
+		Do().
+		Into(result)
+}
+
+// update updates the object, or its status if subresource is "status"
+func (c *resourceClient) update(name, subresource string, obj, result runtime.Object) error {
+	req := c.client.Put().
+		NamespaceIfScoped(c.namespace, c.namespace != "").
+		Resource(c.resource).
+		Name(name)
+	if subresource != "" {
+		req = req.SubResource(subresource)
+	}
+	return req.Body(obj).Do().Into(result)
+}
+
+func (c *resourceClient) delete(name string, options *metav1.DeleteOptions) error {
+	return c.client.Delete().
+		NamespaceIfScoped(c.namespace, c.namespace != "").
+		Resource(c.resource).
+		Name(name).
+		Body(options).
+		Do().
+		Error()
+}
+
+func (c *resourceClient) patch(name string, pt types.PatchType, data []byte, result runtime.Object, subresources ...string) error {
+	return c.client.Patch(pt).
+		NamespaceIfScoped(c.namespace, c.namespace != "").
+		Resource(c.resource).
+		SubResource(subresources...).
+		Name(name).
+		Body(data).
+		Do().
+		Into(result)
+}
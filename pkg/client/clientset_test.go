@@ -0,0 +1,111 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+func TestClientset(t *testing.T) {
+	cluster := curvev1.CurveCluster{
+		TypeMeta:   metav1.TypeMeta{APIVersion: curvev1.GroupVersion.String(), Kind: "CurveCluster"},
+		ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "curve"},
+		Status:     curvev1.CurveClusterStatus{Phase: curvev1.ClusterPhaseReady},
+	}
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/apis/operator.curve.io/v1/namespaces/curve/curveclusters":
+			_ = json.NewEncoder(w).Encode(curvev1.CurveClusterList{
+				TypeMeta: metav1.TypeMeta{APIVersion: curvev1.GroupVersion.String(), Kind: "CurveClusterList"},
+				Items:    []curvev1.CurveCluster{cluster},
+			})
+		case "/apis/operator.curve.io/v1/namespaces/curve/curveclusters/my-cluster",
+			"/apis/operator.curve.io/v1/namespaces/curve/curveclusters/my-cluster/status":
+			_ = json.NewEncoder(w).Encode(cluster)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	clientset, err := NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := clientset.CurveClusters("curve").Get("my-cluster", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Name != "my-cluster" || got.Status.Phase != curvev1.ClusterPhaseReady {
+		t.Errorf("expected cluster my-cluster Ready, got %+v", got)
+	}
+	list, err := clientset.CurveClusters("curve").List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(list.Items) != 1 {
+		t.Errorf("expected 1 cluster, got %d", len(list.Items))
+	}
+	if _, err := clientset.CurveClusters("curve").UpdateStatus(got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{
+		"GET /apis/operator.curve.io/v1/namespaces/curve/curveclusters/my-cluster",
+		"GET /apis/operator.curve.io/v1/namespaces/curve/curveclusters",
+		"PUT /apis/operator.curve.io/v1/namespaces/curve/curveclusters/my-cluster/status",
+	}
+	for i := range expected {
+		if i >= len(requests) || requests[i] != expected[i] {
+			t.Fatalf("expected requests %v, got %v", expected, requests)
+		}
+	}
+
+	if _, err := clientset.CurveFleets().Get("fleet", metav1.GetOptions{}); !kerrors.IsNotFound(err) {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+	if requests[len(requests)-1] != "GET /apis/operator.curve.io/v1/curvefleets/fleet" {
+		t.Errorf("expected the cluster scoped path of curvefleets, got %s", requests[len(requests)-1])
+	}
+}
+
+func TestCurveClusterLister(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, cluster := range []*curvev1.CurveCluster{
+		{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "curve", Labels: map[string]string{"env": "prod"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "curve"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "other"}},
+	} {
+		if err := indexer.Add(cluster); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	lister := NewCurveClusterLister(indexer)
+	if clusters, _ := lister.List(labels.Everything()); len(clusters) != 3 {
+		t.Errorf("expected 3 clusters, got %d", len(clusters))
+	}
+	if clusters, _ := lister.CurveClusters("curve").List(labels.Everything()); len(clusters) != 2 {
+		t.Errorf("expected 2 clusters of namespace curve, got %d", len(clusters))
+	}
+	if clusters, _ := lister.CurveClusters("curve").List(labels.SelectorFromSet(labels.Set{"env": "prod"})); len(clusters) != 1 {
+		t.Errorf("expected 1 cluster of env prod, got %d", len(clusters))
+	}
+	if _, err := lister.CurveClusters("curve").Get("a"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := lister.CurveClusters("curve").Get("c"); !kerrors.IsNotFound(err) {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+// CurveClusterInterface has the methods to work with the CurveCluster resources
+type CurveClusterInterface interface {
+	Create(*curvev1.CurveCluster) (*curvev1.CurveCluster, error)
+	Update(*curvev1.CurveCluster) (*curvev1.CurveCluster, error)
+	UpdateStatus(*curvev1.CurveCluster) (*curvev1.CurveCluster, error)
+	Delete(name string, options *metav1.DeleteOptions) error
+	Get(name string, options metav1.GetOptions) (*curvev1.CurveCluster, error)
+	List(opts metav1.ListOptions) (*curvev1.CurveClusterList, error)
+	Watch(opts metav1.ListOptions) (watch.Interface, error)
+	Patch(name string, pt types.PatchType, data []byte, subresources ...string) (*curvev1.CurveCluster, error)
+}
+
+type curveClusters struct {
+	resourceClient
+}
+
+func (c *curveClusters) Create(obj *curvev1.CurveCluster) (*curvev1.CurveCluster, error) {
+	result := &curvev1.CurveCluster{}
+	err := c.create(obj, result)
+	return result, err
+}
+
+func (c *curveClusters) Update(obj *curvev1.CurveCluster) (*curvev1.CurveCluster, error) {
+	result := &curvev1.CurveCluster{}
+	err := c.update(obj.Name, "", obj, result)
+	return result, err
+}
+
+// UpdateStatus updates the status of the object by the status subresource, the changes of the others are ignored
+func (c *curveClusters) UpdateStatus(obj *curvev1.CurveCluster) (*curvev1.CurveCluster, error) {
+	result := &curvev1.CurveCluster{}
+	err := c.update(obj.Name, "status", obj, result)
+	return result, err
+}
+
+func (c *curveClusters) Delete(name string, options *metav1.DeleteOptions) error {
+	return c.delete(name, options)
+}
+
+func (c *curveClusters) Get(name string, options metav1.GetOptions) (*curvev1.CurveCluster, error) {
+	result := &curvev1.CurveCluster{}
+	err := c.get(name, options, result)
+	return result, err
+}
+
+func (c *curveClusters) List(opts metav1.ListOptions) (*curvev1.CurveClusterList, error) {
+	result := &curvev1.CurveClusterList{}
+	err := c.list(opts, result)
+	return result, err
+}
+
+func (c *curveClusters) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	return c.watch(opts)
+}
+
+func (c *curveClusters) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (*curvev1.CurveCluster, error) {
+	result := &curvev1.CurveCluster{}
+	err := c.patch(name, pt, data, result, subresources...)
+	return result, err
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+// CurveFleetInterface has the methods to work with the CurveFleet resources, they are cluster scoped
+type CurveFleetInterface interface {
+	Create(*curvev1.CurveFleet) (*curvev1.CurveFleet, error)
+	Update(*curvev1.CurveFleet) (*curvev1.CurveFleet, error)
+	UpdateStatus(*curvev1.CurveFleet) (*curvev1.CurveFleet, error)
+	Delete(name string, options *metav1.DeleteOptions) error
+	Get(name string, options metav1.GetOptions) (*curvev1.CurveFleet, error)
+	List(opts metav1.ListOptions) (*curvev1.CurveFleetList, error)
+	Watch(opts metav1.ListOptions) (watch.Interface, error)
+	Patch(name string, pt types.PatchType, data []byte, subresources ...string) (*curvev1.CurveFleet, error)
+}
+
+type curveFleets struct {
+	resourceClient
+}
+
+func (c *curveFleets) Create(obj *curvev1.CurveFleet) (*curvev1.CurveFleet, error) {
+	result := &curvev1.CurveFleet{}
+	err := c.create(obj, result)
+	return result, err
+}
+
+func (c *curveFleets) Update(obj *curvev1.CurveFleet) (*curvev1.CurveFleet, error) {
+	result := &curvev1.CurveFleet{}
+	err := c.update(obj.Name, "", obj, result)
+	return result, err
+}
+
+// UpdateStatus updates the status of the object by the status subresource, the changes of the others are ignored
+func (c *curveFleets) UpdateStatus(obj *curvev1.CurveFleet) (*curvev1.CurveFleet, error) {
+	result := &curvev1.CurveFleet{}
+	err := c.update(obj.Name, "status", obj, result)
+	return result, err
+}
+
+func (c *curveFleets) Delete(name string, options *metav1.DeleteOptions) error {
+	return c.delete(name, options)
+}
+
+func (c *curveFleets) Get(name string, options metav1.GetOptions) (*curvev1.CurveFleet, error) {
+	result := &curvev1.CurveFleet{}
+	err := c.get(name, options, result)
+	return result, err
+}
+
+func (c *curveFleets) List(opts metav1.ListOptions) (*curvev1.CurveFleetList, error) {
+	result := &curvev1.CurveFleetList{}
+	err := c.list(opts, result)
+	return result, err
+}
+
+func (c *curveFleets) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	return c.watch(opts)
+}
+
+func (c *curveFleets) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (*curvev1.CurveFleet, error) {
+	result := &curvev1.CurveFleet{}
+	err := c.patch(name, pt, data, result, subresources...)
+	return result, err
+}
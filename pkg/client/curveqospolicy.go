@@ -0,0 +1,70 @@
+package client
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+// CurveQoSPolicyInterface has the methods to work with the CurveQoSPolicy resources
+type CurveQoSPolicyInterface interface {
+	Create(*curvev1.CurveQoSPolicy) (*curvev1.CurveQoSPolicy, error)
+	Update(*curvev1.CurveQoSPolicy) (*curvev1.CurveQoSPolicy, error)
+	UpdateStatus(*curvev1.CurveQoSPolicy) (*curvev1.CurveQoSPolicy, error)
+	Delete(name string, options *metav1.DeleteOptions) error
+	Get(name string, options metav1.GetOptions) (*curvev1.CurveQoSPolicy, error)
+	List(opts metav1.ListOptions) (*curvev1.CurveQoSPolicyList, error)
+	Watch(opts metav1.ListOptions) (watch.Interface, error)
+	Patch(name string, pt types.PatchType, data []byte, subresources ...string) (*curvev1.CurveQoSPolicy, error)
+}
+
+type curveQoSPolicies struct {
+	resourceClient
+}
+
+func (c *curveQoSPolicies) Create(obj *curvev1.CurveQoSPolicy) (*curvev1.CurveQoSPolicy, error) {
+	result := &curvev1.CurveQoSPolicy{}
+	err := c.create(obj, result)
+	return result, err
+}
+
+func (c *curveQoSPolicies) Update(obj *curvev1.CurveQoSPolicy) (*curvev1.CurveQoSPolicy, error) {
+	result := &curvev1.CurveQoSPolicy{}
+	err := c.update(obj.Name, "", obj, result)
+	return result, err
+}
+
+// UpdateStatus updates the status of the object by the status subresource, the changes of the others are ignored
+func (c *curveQoSPolicies) UpdateStatus(obj *curvev1.CurveQoSPolicy) (*curvev1.CurveQoSPolicy, error) {
+	result := &curvev1.CurveQoSPolicy{}
+	err := c.update(obj.Name, "status", obj, result)
+	return result, err
+}
+
+func (c *curveQoSPolicies) Delete(name string, options *metav1.DeleteOptions) error {
+	return c.delete(name, options)
+}
+
+func (c *curveQoSPolicies) Get(name string, options metav1.GetOptions) (*curvev1.CurveQoSPolicy, error) {
+	result := &curvev1.CurveQoSPolicy{}
+	err := c.get(name, options, result)
+	return result, err
+}
+
+func (c *curveQoSPolicies) List(opts metav1.ListOptions) (*curvev1.CurveQoSPolicyList, error) {
+	result := &curvev1.CurveQoSPolicyList{}
+	err := c.list(opts, result)
+	return result, err
+}
+
+func (c *curveQoSPolicies) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	return c.watch(opts)
+}
+
+func (c *curveQoSPolicies) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (*curvev1.CurveQoSPolicy, error) {
+	result := &curvev1.CurveQoSPolicy{}
+	err := c.patch(name, pt, data, result, subresources...)
+	return result, err
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+// CurveUserInterface has the methods to work with the CurveUser resources
+type CurveUserInterface interface {
+	Create(*curvev1.CurveUser) (*curvev1.CurveUser, error)
+	Update(*curvev1.CurveUser) (*curvev1.CurveUser, error)
+	UpdateStatus(*curvev1.CurveUser) (*curvev1.CurveUser, error)
+	Delete(name string, options *metav1.DeleteOptions) error
+	Get(name string, options metav1.GetOptions) (*curvev1.CurveUser, error)
+	List(opts metav1.ListOptions) (*curvev1.CurveUserList, error)
+	Watch(opts metav1.ListOptions) (watch.Interface, error)
+	Patch(name string, pt types.PatchType, data []byte, subresources ...string) (*curvev1.CurveUser, error)
+}
+
+type curveUsers struct {
+	resourceClient
+}
+
+func (c *curveUsers) Create(obj *curvev1.CurveUser) (*curvev1.CurveUser, error) {
+	result := &curvev1.CurveUser{}
+	err := c.create(obj, result)
+	return result, err
+}
+
+func (c *curveUsers) Update(obj *curvev1.CurveUser) (*curvev1.CurveUser, error) {
+	result := &curvev1.CurveUser{}
+	err := c.update(obj.Name, "", obj, result)
+	return result, err
+}
+
+// UpdateStatus updates the status of the object by the status subresource, the changes of the others are ignored
+func (c *curveUsers) UpdateStatus(obj *curvev1.CurveUser) (*curvev1.CurveUser, error) {
+	result := &curvev1.CurveUser{}
+	err := c.update(obj.Name, "status", obj, result)
+	return result, err
+}
+
+func (c *curveUsers) Delete(name string, options *metav1.DeleteOptions) error {
+	return c.delete(name, options)
+}
+
+func (c *curveUsers) Get(name string, options metav1.GetOptions) (*curvev1.CurveUser, error) {
+	result := &curvev1.CurveUser{}
+	err := c.get(name, options, result)
+	return result, err
+}
+
+func (c *curveUsers) List(opts metav1.ListOptions) (*curvev1.CurveUserList, error) {
+	result := &curvev1.CurveUserList{}
+	err := c.list(opts, result)
+	return result, err
+}
+
+func (c *curveUsers) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	return c.watch(opts)
+}
+
+func (c *curveUsers) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (*curvev1.CurveUser, error) {
+	result := &curvev1.CurveUser{}
+	err := c.patch(name, pt, data, result, subresources...)
+	return result, err
+}
@@ -0,0 +1,70 @@
+package client_test
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/client"
+)
+
+// The CurveCluster is created in namespace curve and its image is upgraded by a merge patch
+func ExampleNewForConfig() {
+	clientset, err := client.NewForConfig(ctrl.GetConfigOrDie())
+	if err != nil {
+		panic(err)
+	}
+
+	cluster, err := clientset.CurveClusters("curve").Create(&curvev1.CurveCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "curve"},
+		Spec: &curvev1.CurveClusterSpec{
+			CurveVersion: curvev1.CurveVersionSpec{Image: "opencurvedocker/curvebs:v1.2"},
+			Nodes:        []string{"node1", "node2", "node3"},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("created", cluster.Name, cluster.UID)
+
+	patch := []byte(`{"spec":{"curveVersion":{"image":"opencurvedocker/curvebs:v1.2.6"}}}`)
+	if _, err := clientset.CurveClusters("curve").Patch("my-cluster", types.MergePatchType, patch); err != nil {
+		panic(err)
+	}
+}
+
+// The phases of the CurveClusters of all namespaces are printed when they change, and listed from the cache
+func ExampleNewCurveClusterInformer() {
+	clientset := client.NewForConfigOrDie(ctrl.GetConfigOrDie())
+
+	informer := client.NewCurveClusterInformer(clientset, metav1.NamespaceAll, 10*time.Minute, nil)
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			old, cluster := oldObj.(*curvev1.CurveCluster), newObj.(*curvev1.CurveCluster)
+			if old.Status.Phase != cluster.Status.Phase {
+				fmt.Printf("%s/%s: %s\n", cluster.Namespace, cluster.Name, cluster.Status.Phase)
+			}
+		},
+	})
+	stop := make(chan struct{})
+	defer close(stop)
+	go informer.Run(stop)
+	if !cache.WaitForCacheSync(stop, informer.HasSynced) {
+		panic("failed to sync curveclusters")
+	}
+
+	lister := client.NewCurveClusterLister(informer.GetIndexer())
+	clusters, err := lister.CurveClusters("curve").List(labels.Everything())
+	if err != nil {
+		panic(err)
+	}
+	for _, cluster := range clusters {
+		fmt.Println(cluster.Name, cluster.Status.Phase)
+	}
+}
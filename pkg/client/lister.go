@@ -0,0 +1,94 @@
+package client
+
+import (
+	"time"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+// NewCurveClusterInformer returns the informer of the CurveClusters of the namespace, or of all namespaces if it's
+// empty. The informer is run by its Run, and the CurveClusters in its cache are listed by NewCurveClusterLister. The
+// indexers are added to cache.NamespaceIndex, which is used by the lister.
+func NewCurveClusterInformer(client Interface, namespace string, resync time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+	all := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+	for name, indexer := range indexers {
+		all[name] = indexer
+	}
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return client.CurveClusters(namespace).List(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.CurveClusters(namespace).Watch(options)
+		},
+	}
+	return cache.NewSharedIndexInformer(lw, &curvev1.CurveCluster{}, resync, all)
+}
+
+// CurveClusterLister lists the CurveClusters from the cache of an informer. The objects returned are the ones in
+// the cache, they must be copied by DeepCopy before they are modified.
+type CurveClusterLister interface {
+	// List lists the CurveClusters of all namespaces matching the selector
+	List(selector labels.Selector) ([]*curvev1.CurveCluster, error)
+	// CurveClusters returns the lister of the CurveClusters of the namespace
+	CurveClusters(namespace string) CurveClusterNamespaceLister
+}
+
+// CurveClusterNamespaceLister lists the CurveClusters of a namespace from the cache of an informer
+type CurveClusterNamespaceLister interface {
+	List(selector labels.Selector) ([]*curvev1.CurveCluster, error)
+	Get(name string) (*curvev1.CurveCluster, error)
+}
+
+// NewCurveClusterLister returns the lister of the indexer of the informer returned by NewCurveClusterInformer
+func NewCurveClusterLister(indexer cache.Indexer) CurveClusterLister {
+	return &curveClusterLister{indexer: indexer}
+}
+
+type curveClusterLister struct {
+	indexer cache.Indexer
+}
+
+func (l *curveClusterLister) List(selector labels.Selector) ([]*curvev1.CurveCluster, error) {
+	var clusters []*curvev1.CurveCluster
+	err := cache.ListAll(l.indexer, selector, func(obj interface{}) {
+		clusters = append(clusters, obj.(*curvev1.CurveCluster))
+	})
+	return clusters, err
+}
+
+func (l *curveClusterLister) CurveClusters(namespace string) CurveClusterNamespaceLister {
+	return &curveClusterNamespaceLister{indexer: l.indexer, namespace: namespace}
+}
+
+type curveClusterNamespaceLister struct {
+	indexer   cache.Indexer
+	namespace string
+}
+
+func (l *curveClusterNamespaceLister) List(selector labels.Selector) ([]*curvev1.CurveCluster, error) {
+	var clusters []*curvev1.CurveCluster
+	err := cache.ListAllByNamespace(l.indexer, l.namespace, selector, func(obj interface{}) {
+		clusters = append(clusters, obj.(*curvev1.CurveCluster))
+	})
+	return clusters, err
+}
+
+// Get returns the CurveCluster of the name, a NotFound error is returned like the api server if it's not in the cache
+func (l *curveClusterNamespaceLister) Get(name string) (*curvev1.CurveCluster, error) {
+	obj, exists, err := l.indexer.GetByKey(l.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, kerrors.NewNotFound(curvev1.GroupVersion.WithResource("curveclusters").GroupResource(), name)
+	}
+	return obj.(*curvev1.CurveCluster), nil
+}
@@ -15,4 +15,8 @@ type Context struct {
 
 	// Represents the Client provided by the controller-runtime package to interact with Kubernetes objects
 	Client client.Client
+
+	// NodeCache is the shared cache of the nodes, the nodes got and listed by Clientset are served by it if
+	// Clientset is returned by NodeCache.Clientset
+	NodeCache *NodeCache
 }
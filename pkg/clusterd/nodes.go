@@ -0,0 +1,202 @@
+package clusterd
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/flowcontrol"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+const (
+	// NodeHostnameIndex indexes the nodes by their hostname label
+	NodeHostnameIndex = "hostname"
+	// NodeAddressIndex indexes the nodes by their internal and external ips and the ip of their annotation
+	NodeAddressIndex = "address"
+
+	nodeCacheResync = 10 * time.Minute
+	// the lookups before the cache is synced go to the api server, they are limited so a reconcile of a large
+	// cluster doesn't flood it
+	nodeLookupQPS   = 5
+	nodeLookupBurst = 10
+)
+
+// NodeCache is the informer cache of the nodes shared by the controllers, the nodes are looked up in it instead
+// of the api server during provisioning. It's used by the clientset returned by Clientset.
+type NodeCache struct {
+	informer cache.SharedIndexInformer
+	limiter  flowcontrol.RateLimiter
+}
+
+// NewNodeCache returns the cache of the nodes watched by the clientset, it's filled after Start
+func NewNodeCache(clientset kubernetes.Interface) *NodeCache {
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return clientset.CoreV1().Nodes().List(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return clientset.CoreV1().Nodes().Watch(options)
+		},
+	}
+	informer := cache.NewSharedIndexInformer(lw, &v1.Node{}, nodeCacheResync, cache.Indexers{
+		NodeHostnameIndex: func(obj interface{}) ([]string, error) {
+			if hostname, ok := obj.(*v1.Node).Labels[v1.LabelHostname]; ok {
+				return []string{hostname}, nil
+			}
+			return nil, nil
+		},
+		NodeAddressIndex: func(obj interface{}) ([]string, error) {
+			node := obj.(*v1.Node)
+			var addresses []string
+			for _, address := range node.Status.Addresses {
+				if address.Type == v1.NodeInternalIP || address.Type == v1.NodeExternalIP {
+					addresses = append(addresses, address.Address)
+				}
+			}
+			if address, ok := node.Annotations[curvev1.NodeIPAnnotation]; ok {
+				addresses = append(addresses, address)
+			}
+			return addresses, nil
+		},
+	})
+	return &NodeCache{
+		informer: informer,
+		limiter:  flowcontrol.NewTokenBucketRateLimiter(nodeLookupQPS, nodeLookupBurst),
+	}
+}
+
+// Start runs the informer until stop is closed, it returns after the cache is synced
+func (c *NodeCache) Start(stop <-chan struct{}) error {
+	go c.informer.Run(stop)
+	if !cache.WaitForCacheSync(stop, c.informer.HasSynced) {
+		return errors.New("failed to sync node cache")
+	}
+	return nil
+}
+
+// Synced returns true if the cache has been filled by the informer
+func (c *NodeCache) Synced() bool {
+	return c.informer.HasSynced()
+}
+
+// Get returns a copy of the node, a NotFound error is returned like the api server if it's not in the cache
+func (c *NodeCache) Get(name string) (*v1.Node, error) {
+	obj, exists, err := c.informer.GetIndexer().GetByKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, kerrors.NewNotFound(v1.Resource("nodes"), name)
+	}
+	return obj.(*v1.Node).DeepCopy(), nil
+}
+
+// List returns copies of the nodes matching the selector, the selector of a hostname is looked up by the index
+func (c *NodeCache) List(selector labels.Selector) ([]v1.Node, error) {
+	var objs []interface{}
+	requirements, _ := selector.Requirements()
+	if len(requirements) == 1 && requirements[0].Key() == v1.LabelHostname &&
+		(requirements[0].Operator() == selection.Equals || requirements[0].Operator() == selection.DoubleEquals) {
+		var err error
+		if objs, err = c.informer.GetIndexer().ByIndex(NodeHostnameIndex, requirements[0].Values().List()[0]); err != nil {
+			return nil, err
+		}
+	} else {
+		objs = c.informer.GetIndexer().List()
+	}
+
+	var nodes []v1.Node
+	for _, obj := range objs {
+		node := obj.(*v1.Node)
+		if selector.Matches(labels.Set(node.Labels)) {
+			nodes = append(nodes, *node.DeepCopy())
+		}
+	}
+	return nodes, nil
+}
+
+// ByIndex returns copies of the nodes of the value of NodeHostnameIndex or NodeAddressIndex
+func (c *NodeCache) ByIndex(index, value string) ([]v1.Node, error) {
+	objs, err := c.informer.GetIndexer().ByIndex(index, value)
+	if err != nil {
+		return nil, err
+	}
+	var nodes []v1.Node
+	for _, obj := range objs {
+		nodes = append(nodes, *obj.(*v1.Node).DeepCopy())
+	}
+	return nodes, nil
+}
+
+// Clientset returns the clientset whose Get and List of the nodes are served by the cache once it's synced, the
+// other requests are sent to the api server by the clientset
+func (c *NodeCache) Clientset(clientset kubernetes.Interface) kubernetes.Interface {
+	return &cachedClientset{Interface: clientset, nodes: c}
+}
+
+// CachedNodes returns the node cache of the clientset returned by NodeCache.Clientset if it's synced
+func CachedNodes(clientset kubernetes.Interface) (*NodeCache, bool) {
+	cached, ok := clientset.(*cachedClientset)
+	if !ok || !cached.nodes.Synced() {
+		return nil, false
+	}
+	return cached.nodes, true
+}
+
+type cachedClientset struct {
+	kubernetes.Interface
+	nodes *NodeCache
+}
+
+func (c *cachedClientset) CoreV1() corev1client.CoreV1Interface {
+	return &cachedCoreV1{CoreV1Interface: c.Interface.CoreV1(), nodes: c.nodes}
+}
+
+type cachedCoreV1 struct {
+	corev1client.CoreV1Interface
+	nodes *NodeCache
+}
+
+func (c *cachedCoreV1) Nodes() corev1client.NodeInterface {
+	return &cachedNodeInterface{NodeInterface: c.CoreV1Interface.Nodes(), nodes: c.nodes}
+}
+
+type cachedNodeInterface struct {
+	corev1client.NodeInterface
+	nodes *NodeCache
+}
+
+func (n *cachedNodeInterface) Get(name string, options metav1.GetOptions) (*v1.Node, error) {
+	if !n.nodes.Synced() || options.ResourceVersion != "" {
+		n.nodes.limiter.Accept()
+		return n.NodeInterface.Get(name, options)
+	}
+	return n.nodes.Get(name)
+}
+
+func (n *cachedNodeInterface) List(opts metav1.ListOptions) (*v1.NodeList, error) {
+	if !n.nodes.Synced() || opts.FieldSelector != "" || opts.ResourceVersion != "" {
+		n.nodes.limiter.Accept()
+		return n.NodeInterface.List(opts)
+	}
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, kerrors.NewBadRequest(err.Error())
+	}
+	nodes, err := n.nodes.List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.NodeList{Items: nodes}, nil
+}
@@ -0,0 +1,211 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+const (
+	// ClusterInfoConfigMapName is the configmap to record the endpoints of all daemons of the cluster
+	ClusterInfoConfigMapName = "curve-cluster-info"
+	clusterInfoDataKey       = "clusterinfo.json"
+
+	// ClusterInfoVersion is the version of the data of cluster info configmap
+	ClusterInfoVersion = 1
+)
+
+// ClusterInfo is the endpoints of the daemons of the cluster, all addresses are comma separated ip:port
+type ClusterInfo struct {
+	Version int `json:"version"`
+
+	// EtcdPeerAddr is the peer addresses of etcd members
+	EtcdPeerAddr string `json:"etcdPeerAddr,omitempty"`
+	// EtcdAddr is the client addresses of etcd members
+	EtcdAddr string `json:"etcdAddr,omitempty"`
+	// MdsAddr is the addresses of mds
+	MdsAddr string `json:"mdsAddr,omitempty"`
+	// SnapShotCloneAddr is the addresses of snapshotclone
+	SnapShotCloneAddr string `json:"snapShotCloneAddr,omitempty"`
+	// ChunkServerAddrs is the address of each chunkserver
+	ChunkServerAddrs []string `json:"chunkServerAddrs,omitempty"`
+
+	// migrated is true if the info is read from the override configmaps of old versions
+	migrated bool
+}
+
+// the app labels of the daemons whose endpoints can be recovered from their running pods
+const (
+	etcdAppName = "curve-etcd"
+	mdsAppName  = "curve-mds"
+)
+
+// GetClusterInfo reads the cluster info of the namespace, the endpoints override configmaps of old versions
+// are migrated if it's not created yet. The endpoints of etcd and mds are recovered from their running pods
+// if none of the configmaps exists or the cluster info is corrupted, they are saved again by the next update.
+func GetClusterInfo(c *clusterd.Context, namespace string) (*ClusterInfo, error) {
+	cm, err := c.Clientset.CoreV1().ConfigMaps(namespace).Get(ClusterInfoConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if !kerrors.IsNotFound(err) {
+			return nil, errors.Wrapf(err, "failed to get configmap %q", ClusterInfoConfigMapName)
+		}
+		info, err := migrateClusterInfo(c, namespace)
+		if err != nil {
+			return nil, err
+		}
+		if !info.migrated {
+			if err := recoverClusterInfo(c, namespace, info); err != nil {
+				return nil, err
+			}
+		}
+		return info, nil
+	}
+
+	info := &ClusterInfo{}
+	if err := json.Unmarshal([]byte(cm.Data[clusterInfoDataKey]), info); err != nil {
+		logger.Warningf("configmap %q is corrupted, recovering the endpoints from the running daemons. %v", ClusterInfoConfigMapName, err)
+		info = &ClusterInfo{Version: ClusterInfoVersion}
+		if err := recoverClusterInfo(c, namespace, info); err != nil {
+			return nil, err
+		}
+		return info, nil
+	}
+	if info.Version > ClusterInfoVersion {
+		return nil, errors.Errorf("version %d of configmap %q is newer than %d of the operator",
+			info.Version, ClusterInfoConfigMapName, ClusterInfoVersion)
+	}
+	return info, nil
+}
+
+// UpdateClusterInfo changes the cluster info of the namespace by update and saves it
+func UpdateClusterInfo(c *clusterd.Context, namespace string, ownerInfo *k8sutil.OwnerInfo, spec curvev1.CurveClusterSpec, update func(info *ClusterInfo)) error {
+	info, err := GetClusterInfo(c, namespace)
+	if err != nil {
+		return err
+	}
+	update(info)
+	info.Version = ClusterInfoVersion
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal cluster info")
+	}
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ClusterInfoConfigMapName,
+			Namespace: namespace,
+		},
+		Data: map[string]string{clusterInfoDataKey: string(data)},
+	}
+	k8sutil.InjectMetadata(spec, "", cm)
+	if err := ownerInfo.SetControllerReference(cm); err != nil {
+		return errors.Wrapf(err, "failed to set owner reference to configmap %q", ClusterInfoConfigMapName)
+	}
+	if err := k8sutil.Apply(c.Client, cm); err != nil {
+		return err
+	}
+
+	if info.migrated {
+		for _, name := range []string{EtcdOverrideConfigMapName, MdsOverrideConfigMapName} {
+			k8sutil.ExpectDelete("ConfigMap", namespace, name)
+			err := c.Clientset.CoreV1().ConfigMaps(namespace).Delete(name, &metav1.DeleteOptions{})
+			if err != nil && !kerrors.IsNotFound(err) {
+				return errors.Wrapf(err, "failed to delete migrated configmap %q", name)
+			}
+		}
+		logger.Infof("endpoints override configmaps have been migrated to %q", ClusterInfoConfigMapName)
+	}
+	return nil
+}
+
+// migrateClusterInfo reads the cluster info from the endpoints override configmaps of old versions
+func migrateClusterInfo(c *clusterd.Context, namespace string) (*ClusterInfo, error) {
+	info := &ClusterInfo{Version: ClusterInfoVersion}
+
+	etcdCM, err := c.Clientset.CoreV1().ConfigMaps(namespace).Get(EtcdOverrideConfigMapName, metav1.GetOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return nil, errors.Wrapf(err, "failed to get configmap %q", EtcdOverrideConfigMapName)
+	}
+	if err == nil {
+		info.EtcdPeerAddr = etcdCM.Data[EtcdOvverideConfigMapDataKey]
+		info.EtcdAddr = etcdCM.Data[ClusterEtcdAddr]
+		info.migrated = true
+	}
+
+	mdsCM, err := c.Clientset.CoreV1().ConfigMaps(namespace).Get(MdsOverrideConfigMapName, metav1.GetOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return nil, errors.Wrapf(err, "failed to get configmap %q", MdsOverrideConfigMapName)
+	}
+	if err == nil {
+		info.MdsAddr = mdsCM.Data[MdsOvverideConfigMapDataKey]
+		info.migrated = true
+	}
+	return info, nil
+}
+
+// recoverClusterInfo fills the missing endpoints of etcd and mds of the info by the running pods of their
+// deployments, the daemons run on host network so the ip of a pod is the ip of its node.
+func recoverClusterInfo(c *clusterd.Context, namespace string, info *ClusterInfo) error {
+	if info.EtcdAddr == "" || info.EtcdPeerAddr == "" {
+		addrs, err := daemonAddrs(c, namespace, etcdAppName, "listen-port", "peer-port")
+		if err != nil {
+			return err
+		}
+		info.EtcdAddr, info.EtcdPeerAddr = addrs[0], addrs[1]
+	}
+	if info.MdsAddr == "" {
+		addrs, err := daemonAddrs(c, namespace, mdsAppName, "listen-port")
+		if err != nil {
+			return err
+		}
+		info.MdsAddr = addrs[0]
+	}
+	if info.EtcdAddr != "" || info.MdsAddr != "" {
+		logger.Infof("recovered etcd endpoints %q and mds endpoints %q from the running daemons", info.EtcdAddr, info.MdsAddr)
+	}
+	return nil
+}
+
+// daemonAddrs returns the comma separated addresses of the running host network pods of the app for each
+// of the named container ports, ordered by the names of the pods
+func daemonAddrs(c *clusterd.Context, namespace string, app string, portNames ...string) ([]string, error) {
+	pods, err := c.Clientset.CoreV1().Pods(namespace).List(metav1.ListOptions{LabelSelector: fmt.Sprintf("app=%s", app)})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list pods of %q", app)
+	}
+	sort.Slice(pods.Items, func(i, j int) bool { return pods.Items[i].Name < pods.Items[j].Name })
+
+	addrs := make([][]string, len(portNames))
+	for _, pod := range pods.Items {
+		if !pod.Spec.HostNetwork || pod.Status.Phase != v1.PodRunning || pod.Status.PodIP == "" {
+			continue
+		}
+		ports := map[string]int32{}
+		for _, container := range pod.Spec.Containers {
+			for _, port := range container.Ports {
+				ports[port.Name] = port.ContainerPort
+			}
+		}
+		for i, name := range portNames {
+			if port, ok := ports[name]; ok {
+				addrs[i] = append(addrs[i], fmt.Sprintf("%s:%d", pod.Status.PodIP, port))
+			}
+		}
+	}
+
+	joined := make([]string, len(portNames))
+	for i := range addrs {
+		joined[i] = strings.Join(addrs[i], ",")
+	}
+	return joined, nil
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+)
+
+func daemonPod(name, app, ip string, ports map[string]int32) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "curve", Labels: map[string]string{"app": app}},
+		Spec:       v1.PodSpec{HostNetwork: true, Containers: []v1.Container{{Name: app}}},
+		Status:     v1.PodStatus{Phase: v1.PodRunning, PodIP: ip},
+	}
+	for name, port := range ports {
+		pod.Spec.Containers[0].Ports = append(pod.Spec.Containers[0].Ports, v1.ContainerPort{Name: name, ContainerPort: port})
+	}
+	return pod
+}
+
+func TestGetClusterInfoRecovery(t *testing.T) {
+	etcdPorts := map[string]int32{"listen-port": 23790, "peer-port": 23800}
+	mdsPorts := map[string]int32{"listen-port": 6700, "dummy-port": 7700}
+	pending := daemonPod("curve-mds-c-0", mdsAppName, "10.0.0.3", mdsPorts)
+	pending.Status.Phase = v1.PodPending
+	objects := []*v1.Pod{
+		daemonPod("curve-etcd-b-0", etcdAppName, "10.0.0.2", etcdPorts),
+		daemonPod("curve-etcd-a-0", etcdAppName, "10.0.0.1", etcdPorts),
+		daemonPod("curve-mds-a-0", mdsAppName, "10.0.0.1", mdsPorts),
+		pending,
+	}
+
+	corrupted := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: ClusterInfoConfigMapName, Namespace: "curve"},
+		Data:       map[string]string{clusterInfoDataKey: "{\"etcdAddr\": "},
+	}
+	for name, cm := range map[string]*v1.ConfigMap{"missing": nil, "corrupted": corrupted} {
+		clientset := fake.NewSimpleClientset()
+		for _, pod := range objects {
+			if _, err := clientset.CoreV1().Pods("curve").Create(pod); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if cm != nil {
+			if _, err := clientset.CoreV1().ConfigMaps("curve").Create(cm); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		info, err := GetClusterInfo(&clusterd.Context{Clientset: clientset}, "curve")
+		if err != nil {
+			t.Fatalf("%s: expected the cluster info to be recovered, got %v", name, err)
+		}
+		if info.EtcdAddr != "10.0.0.1:23790,10.0.0.2:23790" || info.EtcdPeerAddr != "10.0.0.1:23800,10.0.0.2:23800" {
+			t.Errorf("%s: unexpected etcd endpoints %q and %q", name, info.EtcdAddr, info.EtcdPeerAddr)
+		}
+		if info.MdsAddr != "10.0.0.1:6700" {
+			t.Errorf("%s: unexpected mds endpoints %q", name, info.MdsAddr)
+		}
+	}
+}
@@ -5,12 +5,11 @@ import "github.com/coreos/pkg/capnslog"
 var logger = capnslog.NewPackageLogger("github.com/opencurve/curve-operator", "config")
 
 const (
-	// configmap to record the endpoints of etcd
+	// configmaps to record the endpoints of etcd and mds by old versions, they are migrated to ClusterInfo
 	EtcdOverrideConfigMapName    = "etcd-endpoints-override"
 	EtcdOvverideConfigMapDataKey = "etcdEndpoints"
 	ClusterEtcdAddr              = "clusterEtcdAddr"
 
-	// configmap to record the endpoints of mds
 	MdsOverrideConfigMapName    = "mds-endpoints-override"
 	MdsOvverideConfigMapDataKey = "mdsEndpoints"
 
@@ -1,5 +1,11 @@
 package config
 
+import (
+	"path"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
 // A DataPathMap is a struct which contains information about where Curve daemon data is stored in
 // containers and whether the data should be persisted to the host. If it is persisted to the host,
 // directory on the host where the specific daemon's data is stored is given.
@@ -29,3 +35,17 @@ func NewDaemonDataPathMap(hostDataDir string, hostLogDir string, containerDataDi
 		ContainerLogDir:  containerLogDir,
 	}
 }
+
+// HostDataDir returns spec.hostDataDir, or the directory of the operator settings if it's not set
+func HostDataDir(spec *curvev1.CurveClusterSpec) string {
+	if spec.HostDataDir != "" {
+		return spec.HostDataDir
+	}
+	return GetOperatorSettings().HostDataDir
+}
+
+// HostDaemonDirs returns the roots of the data, the logs and the conf of the daemons on the hosts
+func HostDaemonDirs(spec *curvev1.CurveClusterSpec) (string, string, string) {
+	hostDataDir := HostDataDir(spec)
+	return path.Join(hostDataDir, "data"), path.Join(hostDataDir, "logs"), path.Join(hostDataDir, "conf")
+}
@@ -0,0 +1,44 @@
+package config
+
+import "strings"
+
+// Diff returns the lines that differ between the running and desired config, the lines only in running are
+// prefixed by "-" and the lines only in desired by "+" in the order of the files. It's empty if they are the same.
+func Diff(running, desired string) string {
+	a := strings.Split(strings.TrimRight(running, "\n"), "\n")
+	b := strings.Split(strings.TrimRight(desired, "\n"), "\n")
+
+	// lcs[i][j] is the length of the longest common lines of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diff strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			diff.WriteString("-" + a[i] + "\n")
+			i++
+		default:
+			diff.WriteString("+" + b[j] + "\n")
+			j++
+		}
+	}
+	return diff.String()
+}
@@ -0,0 +1,16 @@
+package config
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	running := "a=1\nb=2\nc=3\n"
+	if diff := Diff(running, running); diff != "" {
+		t.Errorf("expected no diff of the same config, got %q", diff)
+	}
+
+	desired := "a=1\nb=20\nc=3\nd=4\n"
+	expected := "-b=2\n+b=20\n+d=4\n"
+	if diff := Diff(running, desired); diff != expected {
+		t.Errorf("expected diff %q, got %q", expected, diff)
+	}
+}
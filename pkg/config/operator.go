@@ -0,0 +1,204 @@
+package config
+
+import (
+	"path"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/coreos/pkg/capnslog"
+	"github.com/pkg/errors"
+)
+
+const (
+	// OperatorConfigMapName is the configmap of the operator-wide settings in the namespace of the operator, the
+	// settings are reloaded when it changes and the defaults are used for the keys that are not set
+	OperatorConfigMapName = "curve-operator-config"
+	// OperatorNamespaceEnv is the env of the namespace of the operator set by the downward API
+	OperatorNamespaceEnv = "POD_NAMESPACE"
+
+	OperatorLogLevelKey           = "CURVE_OPERATOR_LOG_LEVEL"
+	DashboardImageKey             = "CURVE_DASHBOARD_IMAGE"
+	DashboardPortKey              = "CURVE_DASHBOARD_PORT"
+	CosignImageKey                = "CURVE_COSIGN_IMAGE"
+	MaxConcurrentFormatsKey       = "CURVE_MAX_CONCURRENT_FORMATS"
+	ChunkserverCopysetsKey        = "CURVE_CHUNKSERVER_COPYSETS"
+	FailoverGracePeriodSecondsKey = "CURVE_FAILOVER_GRACE_PERIOD_SECONDS"
+	AuditSinkKey                  = "CURVE_AUDIT_SINK"
+	AuditWebhookURLKey            = "CURVE_AUDIT_WEBHOOK_URL"
+	AuditMaxEntriesKey            = "CURVE_AUDIT_MAX_ENTRIES"
+	HostDataDirKey                = "CURVE_HOST_DATA_DIR"
+	HostDevDirKey                 = "CURVE_HOST_DEV_DIR"
+	HostCgroupDirKey              = "CURVE_HOST_CGROUP_DIR"
+	HostModulesDirKey             = "CURVE_HOST_MODULES_DIR"
+
+	// AuditSinkConfigMap records the changes of the operator to the configmap curve-audit-log in the namespace
+	// of the changed objects
+	AuditSinkConfigMap = "configmap"
+	// AuditSinkWebhook posts the changes of the operator to CURVE_AUDIT_WEBHOOK_URL
+	AuditSinkWebhook = "webhook"
+
+	defaultOperatorLogLevel           = "INFO"
+	defaultDashboardImage             = "opencurvedocker/curve-manager:latest"
+	defaultDashboardPort              = 8080
+	defaultCosignImage                = "gcr.io/projectsigstore/cosign:v1.13.1"
+	defaultChunkserverCopysets        = 100
+	defaultFailoverGracePeriodSeconds = 300
+	defaultAuditMaxEntries            = 1000
+	defaultHostDataDir                = "/curvebs"
+	defaultHostDevDir                 = "/dev"
+	defaultHostCgroupDir              = "/sys/fs/cgroup"
+	defaultHostModulesDir             = "/lib/modules"
+)
+
+// OperatorSettings are the operator-wide settings, they are the defaults of the fields that are not set in the
+// spec of the clusters
+type OperatorSettings struct {
+	// LogLevel is the level of the logs of the operator
+	LogLevel string
+	// DashboardImage is the image of the dashboard if spec.dashboard.image is not set
+	DashboardImage string
+	// DashboardPort is the port of the dashboard if spec.dashboard.port is not set
+	DashboardPort int
+	// CosignImage is the image that verifies the signatures if spec.curveVersion.verification.image is not set
+	CosignImage string
+	// MaxConcurrentFormats is the number of the format jobs that run at the same time if
+	// spec.storage.maxConcurrentFormats is not set, 0 means no limit
+	MaxConcurrentFormats int
+	// ChunkserverCopysets is the copysets of a chunkserver if spec.storage.copySets is not set
+	ChunkserverCopysets int
+	// FailoverGracePeriodSeconds is the time that a node can be NotReady before the chunkservers on it are set
+	// offline if spec.storage.failoverGracePeriodSeconds is not set
+	FailoverGracePeriodSeconds int
+	// AuditSink is where the changes of the operator are recorded, configmap or webhook, it's not recorded if empty
+	AuditSink string
+	// AuditWebhookURL is the url that the changes are posted to by the webhook sink
+	AuditWebhookURL string
+	// AuditMaxEntries is the number of the newest changes kept in the configmap of a namespace
+	AuditMaxEntries int
+	// HostDataDir is the directory on the hosts that the data, logs and conf of the daemons are under if
+	// spec.hostDataDir is not set
+	HostDataDir string
+	// HostDevDir, HostCgroupDir and HostModulesDir are where the devices, the cgroups and the kernel modules
+	// are on the hosts, for the distros that relocate them
+	HostDevDir     string
+	HostCgroupDir  string
+	HostModulesDir string
+}
+
+// DefaultOperatorSettings returns the settings used if the configmap doesn't exist
+func DefaultOperatorSettings() OperatorSettings {
+	return OperatorSettings{
+		LogLevel:                   defaultOperatorLogLevel,
+		DashboardImage:             defaultDashboardImage,
+		DashboardPort:              defaultDashboardPort,
+		CosignImage:                defaultCosignImage,
+		ChunkserverCopysets:        defaultChunkserverCopysets,
+		FailoverGracePeriodSeconds: defaultFailoverGracePeriodSeconds,
+		AuditMaxEntries:            defaultAuditMaxEntries,
+		HostDataDir:                defaultHostDataDir,
+		HostDevDir:                 defaultHostDevDir,
+		HostCgroupDir:              defaultHostCgroupDir,
+		HostModulesDir:             defaultHostModulesDir,
+	}
+}
+
+var operatorSettings atomic.Value
+
+func init() {
+	operatorSettings.Store(DefaultOperatorSettings())
+}
+
+// GetOperatorSettings returns the settings loaded last, it's safe to call from the reconciles at any time
+func GetOperatorSettings() OperatorSettings {
+	return operatorSettings.Load().(OperatorSettings)
+}
+
+// SetOperatorSettings replaces the settings and sets the log level of all packages of the operator
+func SetOperatorSettings(settings OperatorSettings) {
+	level, err := capnslog.ParseLevel(settings.LogLevel)
+	if err != nil {
+		// it's validated by ParseOperatorSettings
+		level = capnslog.INFO
+	}
+	capnslog.SetGlobalLogLevel(level)
+	operatorSettings.Store(settings)
+}
+
+// ParseOperatorSettings returns the settings of the data of the configmap, the keys that are not set or empty
+// take the defaults
+func ParseOperatorSettings(data map[string]string) (OperatorSettings, error) {
+	settings := DefaultOperatorSettings()
+	if v := data[OperatorLogLevelKey]; v != "" {
+		if _, err := capnslog.ParseLevel(v); err != nil {
+			return settings, errors.Wrapf(err, "invalid %s", OperatorLogLevelKey)
+		}
+		settings.LogLevel = v
+	}
+	if v := data[DashboardImageKey]; v != "" {
+		settings.DashboardImage = v
+	}
+	if v := data[CosignImageKey]; v != "" {
+		settings.CosignImage = v
+	}
+	switch v := data[AuditSinkKey]; v {
+	case "", AuditSinkConfigMap:
+	case AuditSinkWebhook:
+		if data[AuditWebhookURLKey] == "" {
+			return settings, errors.Errorf("%s is required by %s %s", AuditWebhookURLKey, AuditSinkKey, v)
+		}
+	default:
+		return settings, errors.Errorf("invalid %s %q, it must be %s or %s", AuditSinkKey, v, AuditSinkConfigMap, AuditSinkWebhook)
+	}
+	settings.AuditSink = data[AuditSinkKey]
+	settings.AuditWebhookURL = data[AuditWebhookURLKey]
+
+	dirs := []struct {
+		key   string
+		value *string
+	}{
+		{HostDataDirKey, &settings.HostDataDir},
+		{HostDevDirKey, &settings.HostDevDir},
+		{HostCgroupDirKey, &settings.HostCgroupDir},
+		{HostModulesDirKey, &settings.HostModulesDir},
+	}
+	for _, d := range dirs {
+		v := data[d.key]
+		if v == "" {
+			continue
+		}
+		if !path.IsAbs(v) || path.Clean(v) != v || v == "/" {
+			return settings, errors.Errorf("invalid %s %q, it must be a clean absolute path other than /", d.key, v)
+		}
+		*d.value = v
+	}
+
+	ints := []struct {
+		key      string
+		value    *int
+		positive bool
+	}{
+		{DashboardPortKey, &settings.DashboardPort, true},
+		{MaxConcurrentFormatsKey, &settings.MaxConcurrentFormats, false},
+		{ChunkserverCopysetsKey, &settings.ChunkserverCopysets, true},
+		{FailoverGracePeriodSecondsKey, &settings.FailoverGracePeriodSeconds, true},
+		{AuditMaxEntriesKey, &settings.AuditMaxEntries, true},
+	}
+	for _, i := range ints {
+		v := data[i.key]
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return settings, errors.Wrapf(err, "invalid %s", i.key)
+		}
+		if n < 0 || (i.positive && n == 0) {
+			return settings, errors.Errorf("invalid %s %d", i.key, n)
+		}
+		*i.value = n
+	}
+	if settings.DashboardPort > 65535 {
+		return settings, errors.Errorf("invalid %s %d", DashboardPortKey, settings.DashboardPort)
+	}
+	return settings, nil
+}
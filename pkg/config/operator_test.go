@@ -0,0 +1,47 @@
+package config
+
+import "testing"
+
+func TestParseOperatorSettings(t *testing.T) {
+	settings, err := ParseOperatorSettings(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if settings != DefaultOperatorSettings() {
+		t.Errorf("expected the defaults without the configmap, got %+v", settings)
+	}
+
+	settings, err = ParseOperatorSettings(map[string]string{
+		OperatorLogLevelKey:     "DEBUG",
+		DashboardImageKey:       "curve-manager:v1",
+		MaxConcurrentFormatsKey: "2",
+		ChunkserverCopysetsKey:  "",
+		HostDataDirKey:          "/data/curvebs",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if settings.LogLevel != "DEBUG" || settings.DashboardImage != "curve-manager:v1" || settings.MaxConcurrentFormats != 2 ||
+		settings.HostDataDir != "/data/curvebs" {
+		t.Errorf("expected the settings of the configmap, got %+v", settings)
+	}
+	if settings.ChunkserverCopysets != defaultChunkserverCopysets || settings.DashboardPort != defaultDashboardPort ||
+		settings.HostDevDir != defaultHostDevDir {
+		t.Errorf("expected the defaults of the keys not set, got %+v", settings)
+	}
+
+	for _, data := range []map[string]string{
+		{OperatorLogLevelKey: "VERBOSE"},
+		{DashboardPortKey: "http"},
+		{DashboardPortKey: "70000"},
+		{ChunkserverCopysetsKey: "0"},
+		{MaxConcurrentFormatsKey: "-1"},
+		{HostDevDirKey: "dev"},
+		{HostModulesDirKey: "/lib/modules/"},
+		{HostDataDirKey: "/"},
+	} {
+		if _, err := ParseOperatorSettings(data); err == nil {
+			t.Errorf("expected error of %v", data)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	batch "k8s.io/api/batch/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/curvetool"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+	"github.com/opencurve/curve-operator/pkg/tools"
+	"github.com/opencurve/curve-operator/pkg/validation"
+)
+
+const (
+	adoptDiscoveryTimeout  = 5 * time.Minute
+	adoptDiscoveryInterval = 5 * time.Second
+	// adoptDiscoveryLogLines is how many lines of the chunkserver list are read, one line for a chunkserver
+	adoptDiscoveryLogLines = 10000
+)
+
+// reconcileAdoption adopts the existing cluster of spec.adoption before anything is deployed. The chunkservers in
+// its topology are listed by a job and mapped to the devices of spec.adoption.hosts, their devices are recorded
+// as formatted and the endpoints of etcd and mds are recorded as the ones of the cluster. It returns false if the
+// cluster is not adopted, the reconcile is not continued then. The adoption is retried when spec.adoption changes.
+func (r *CurveClusterReconciler) reconcileAdoption(clusterObj *curvev1.CurveCluster) (bool, error) {
+	adoption := clusterObj.Spec.Adoption
+	if adoption == nil {
+		return true, nil
+	}
+	if clusterObj.Status.Adoption != nil && clusterObj.Status.Adoption.Phase == curvev1.AdoptionAdopted {
+		return true, nil
+	}
+
+	namespacedName := types.NamespacedName{Namespace: clusterObj.Namespace, Name: clusterObj.Name}
+	if err := validation.ValidateAdoption(clusterObj.Spec); err != nil {
+		return false, r.setAdoptionStatus(clusterObj, &curvev1.AdoptionStatus{Phase: curvev1.AdoptionFailed, Message: err.Error()})
+	}
+
+	clusterContext := r.ClusterController.context
+	ownerInfo := k8sutil.NewOwnerInfo(clusterObj, r.Scheme)
+	discovery := tools.New(clusterContext, namespacedName, *clusterObj.Spec, ownerInfo)
+	if clusterObj.Status.Adoption == nil {
+		if err := r.setAdoptionStatus(clusterObj, &curvev1.AdoptionStatus{Phase: curvev1.AdoptionDiscovering}); err != nil {
+			return false, err
+		}
+	}
+	list, err := r.discoverChunkServers(discovery)
+	if err != nil {
+		return false, r.setAdoptionStatus(clusterObj, &curvev1.AdoptionStatus{Phase: curvev1.AdoptionFailed, Message: err.Error()})
+	}
+
+	hosts := map[string]curvev1.AdoptedHostSpec{}
+	for _, host := range adoption.Hosts {
+		hosts[host.HostIP] = host
+	}
+	var adopted []chunkserver.AdoptedChunkServer
+	var unmapped []string
+	for _, cs := range list {
+		if cs.RWStatus == curvetool.ChunkServerRetired {
+			continue
+		}
+		device := ""
+		host, ok := hosts[cs.HostIP]
+		for _, mapped := range host.ChunkServers {
+			if mapped.Port == cs.Port {
+				device = mapped.Device
+			}
+		}
+		if !ok || device == "" {
+			unmapped = append(unmapped, fmt.Sprintf("%s:%d", cs.HostIP, cs.Port))
+			continue
+		}
+		adopted = append(adopted, chunkserver.AdoptedChunkServer{NodeName: host.NodeName, DeviceName: device, Port: cs.Port})
+	}
+	if len(unmapped) > 0 {
+		message := fmt.Sprintf("chunkservers %s are not mapped to devices in adoption.hosts", strings.Join(unmapped, ", "))
+		return false, r.setAdoptionStatus(clusterObj, &curvev1.AdoptionStatus{Phase: curvev1.AdoptionFailed, Message: message})
+	}
+
+	dataDir, logDir, confDir := config.HostDaemonDirs(clusterObj.Spec)
+	chunkservers := chunkserver.New(clusterContext, namespacedName, *clusterObj.Spec, ownerInfo, dataDir, logDir, confDir)
+	if err := chunkservers.AdoptChunkServers(adopted); err != nil {
+		return false, r.setAdoptionStatus(clusterObj, &curvev1.AdoptionStatus{Phase: curvev1.AdoptionFailed, Message: err.Error()})
+	}
+	err = config.UpdateClusterInfo(&clusterContext, clusterObj.Namespace, ownerInfo, *clusterObj.Spec, func(info *config.ClusterInfo) {
+		info.EtcdAddr = strings.Join(adoption.EtcdEndpoints, ",")
+		info.MdsAddr = strings.Join(adoption.MdsEndpoints, ",")
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "failed to record the endpoints of the adopted cluster")
+	}
+
+	now := metav1.Now()
+	if err := r.setAdoptionStatus(clusterObj, &curvev1.AdoptionStatus{
+		Phase:        curvev1.AdoptionAdopted,
+		ChunkServers: len(adopted),
+		AdoptedTime:  &now,
+	}); err != nil {
+		return false, err
+	}
+	logger.Infof("cluster %q is adopted with %d chunkservers, the daemons deployed before are replaced after they are stopped",
+		clusterObj.Name, len(adopted))
+
+	propagation := metav1.DeletePropagationBackground
+	k8sutil.ExpectDelete("Job", clusterObj.Namespace, discovery.AdoptDiscoveryJobName())
+	err = clusterContext.Clientset.BatchV1().Jobs(clusterObj.Namespace).Delete(discovery.AdoptDiscoveryJobName(), &metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !kerrors.IsNotFound(err) {
+		logger.Warningf("failed to delete adopt discovery job. %v", err)
+	}
+	return true, nil
+}
+
+// discoverChunkServers runs the job to list the chunkservers of the cluster to adopt if it has not been run, and
+// returns the chunkservers that it listed. The job that failed is not run again until the mds are changed.
+func (r *CurveClusterReconciler) discoverChunkServers(discovery *tools.Cluster) ([]curvetool.ChunkServer, error) {
+	clientset := r.ClusterController.context.Clientset
+	job, err := discovery.MakeAdoptDiscoveryJob()
+	if err != nil {
+		return nil, err
+	}
+	_, err = clientset.BatchV1().Jobs(job.Namespace).Get(job.Name, metav1.GetOptions{})
+	if err != nil {
+		if !kerrors.IsNotFound(err) {
+			return nil, errors.Wrapf(err, "failed to get adopt discovery job %s", job.Name)
+		}
+		if _, err := clientset.BatchV1().Jobs(job.Namespace).Create(job); err != nil {
+			return nil, errors.Wrapf(err, "failed to create adopt discovery job %s", job.Name)
+		}
+		logger.Infof("created adopt discovery job %s", job.Name)
+	}
+
+	var finished *batch.Job
+	err = wait.PollImmediate(adoptDiscoveryInterval, adoptDiscoveryTimeout, func() (bool, error) {
+		j, err := clientset.BatchV1().Jobs(job.Namespace).Get(job.Name, metav1.GetOptions{})
+		if err != nil {
+			logger.Warningf("failed to get adopt discovery job %s. %v", job.Name, err)
+			return false, nil
+		}
+		if j.Status.Succeeded > 0 || j.Status.Failed > 0 {
+			finished = j
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, errors.Errorf("adopt discovery job %s is not finished in %s", job.Name, adoptDiscoveryTimeout)
+	}
+
+	pods, err := clientset.CoreV1().Pods(job.Namespace).List(metav1.ListOptions{LabelSelector: "job-name=" + job.Name})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list pods of adopt discovery job %s", job.Name)
+	}
+	var logs string
+	for _, pod := range pods.Items {
+		if logs, err = k8sutil.GetContainerLogs(clientset, pod.Namespace, pod.Name, "discovery", false, adoptDiscoveryLogLines); err == nil {
+			break
+		}
+	}
+	if finished.Status.Succeeded == 0 {
+		return nil, errors.Errorf("adopt discovery job %s failed to list the chunkservers of the mds, see the logs of its pod", job.Name)
+	}
+	list, err := curvetool.ParseChunkServerList(logs)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, errors.New("no chunkserver is listed in the topology of the cluster to adopt")
+	}
+	return list, nil
+}
+
+func (r *CurveClusterReconciler) setAdoptionStatus(clusterObj *curvev1.CurveCluster, status *curvev1.AdoptionStatus) error {
+	if status.Phase == curvev1.AdoptionFailed {
+		logger.Errorf("failed to adopt cluster %q. %s", clusterObj.Name, status.Message)
+	}
+	clusterObj.Status.Adoption = status
+	return k8sutil.UpdateStatus(r.Client, types.NamespacedName{Namespace: clusterObj.Namespace, Name: clusterObj.Name}, clusterObj)
+}
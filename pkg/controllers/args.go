@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"reflect"
+
+	"github.com/pkg/errors"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// updateExtraArgs renders the changed storage.extraArgs into the start script of chunkserver, the chunkservers
+// are restarted by the update strategy because the script is mounted
+func (c *cluster) updateExtraArgs(spec *curvev1.CurveClusterSpec) error {
+	if reflect.DeepEqual(spec.Storage.ExtraArgs, c.Spec.Storage.ExtraArgs) {
+		return nil
+	}
+	logger.Infof("extra args of chunkserver changed from %v to %v", c.Spec.Storage.ExtraArgs, spec.Storage.ExtraArgs)
+	if !disruptionAllowed(spec, "restart of chunkserver for the extra args") {
+		return nil
+	}
+
+	nodeNameIP, err := k8sutil.GetNodeInfoMap(c.Spec, c.context.Clientset)
+	if err != nil {
+		return errors.Wrap(err, "failed get all nodes specified in spec nodes")
+	}
+	c.Spec.Storage.ExtraArgs = spec.Storage.ExtraArgs
+	return c.startDaemon(chunkserver.AppName, nodeNameIP)
+}
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	// transientBackoffBase and transientBackoffMax bound the backoff of requeueing a cluster after transient
+	// failures, it's doubled on each failure
+	transientBackoffBase = 5 * time.Second
+	transientBackoffMax  = 5 * time.Minute
+
+	// transientFailureThreshold is the number of the transient failures in a row after which the cluster is
+	// marked Failed, it's still requeued with backoff
+	transientFailureThreshold = 10
+)
+
+// newTransientBackoff returns the backoff of the clusters keyed by their namespaced names
+func newTransientBackoff() workqueue.RateLimiter {
+	return workqueue.NewItemExponentialFailureRateLimiter(transientBackoffBase, transientBackoffMax)
+}
+
+// clusterRateLimiter limits the reconciles of each cluster by a token bucket, so a cluster whose status or
+// children change all the time doesn't take the api server from the others. The workqueue of the controller
+// can't be given a rate limiter by controller-runtime yet, the limited reconciles are requeued instead.
+type clusterRateLimiter struct {
+	qps   rate.Limit
+	burst int
+
+	lock     sync.Mutex
+	limiters map[types.NamespacedName]*rate.Limiter
+}
+
+// newClusterRateLimiter returns the limiter of qps reconciles of each cluster, it doesn't limit if qps is 0
+func newClusterRateLimiter(qps float64, burst int) *clusterRateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &clusterRateLimiter{
+		qps:      rate.Limit(qps),
+		burst:    burst,
+		limiters: map[types.NamespacedName]*rate.Limiter{},
+	}
+}
+
+// Delay takes a token of the cluster and returns 0 if it can be reconciled now, or returns how long to wait for
+// the next token without taking it
+func (l *clusterRateLimiter) Delay(namespacedName types.NamespacedName) time.Duration {
+	if l == nil || l.qps <= 0 {
+		return 0
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	limiter, ok := l.limiters[namespacedName]
+	if !ok {
+		limiter = rate.NewLimiter(l.qps, l.burst)
+		l.limiters[namespacedName] = limiter
+	}
+	reservation := limiter.Reserve()
+	delay := reservation.Delay()
+	if delay > 0 {
+		reservation.Cancel()
+	}
+	return delay
+}
+
+// Forget removes the bucket of the deleted cluster
+func (l *clusterRateLimiter) Forget(namespacedName types.NamespacedName) {
+	if l == nil {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	delete(l.limiters, namespacedName)
+}
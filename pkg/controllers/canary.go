@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/mds"
+)
+
+const (
+	defaultCanaryChunkservers = 1
+	defaultCanarySoakDuration = 10 * time.Minute
+	canaryCheckInterval       = 15 * time.Second
+)
+
+// rollChunkserversByCanary rolls the canary chunkservers to the image by update first, and the others after the
+// canaries run for the soak duration without degradation. The soak is kept in the upgrade status, a rolloutPause is
+// returned until it's over so that the worker is not held by it. The degradation is returned if the canaries
+// degraded, the others are not rolled then.
+func (c *cluster) rollChunkserversByCanary(image string, update func(d *appsv1.Deployment) error) (string, error) {
+	deployments, err := c.context.Clientset.AppsV1().Deployments(c.NameSpace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=%s", chunkserver.AppName),
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to list %s deployments", chunkserver.AppName)
+	}
+	sorted := append([]appsv1.Deployment{}, deployments.Items...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	canary := c.Spec.UpdateStrategy.Canary
+	n := canary.Chunkservers
+	if n <= 0 {
+		n = defaultCanaryChunkservers
+	}
+	if n > len(sorted) {
+		n = len(sorted)
+	}
+	canaries, others := sorted[:n], sorted[n:]
+
+	upgrade, err := c.upgradeStatus()
+	if err != nil {
+		return "", err
+	}
+	soak := upgrade.Canary
+	if soak == nil || soak.Image != image {
+		soak = nil
+	}
+	if soak == nil || !soak.Passed {
+		if err := c.rollDeployments(chunkserver.AppName, canaries, update); err != nil {
+			return "", errors.Wrap(err, "failed to roll canary chunkservers")
+		}
+		duration := canary.SoakDuration.Duration
+		if duration <= 0 {
+			duration = defaultCanarySoakDuration
+		}
+		if soak == nil {
+			soak = &curvev1.CanarySoakStatus{Image: image, StartTime: metav1.Now()}
+			if err := c.setCanarySoak(soak); err != nil {
+				return "", err
+			}
+			logger.Infof("canary chunkservers %s are rolled, soaking for %s", deploymentNames(canaries), duration)
+		}
+		degradation, remaining, err := c.soakCanaries(canaries, soak.StartTime.Time, duration)
+		if err != nil || degradation != "" {
+			return degradation, err
+		}
+		if remaining > 0 {
+			after := canaryCheckInterval
+			if remaining < after {
+				after = remaining
+			}
+			return "", &rolloutPause{after: after, message: fmt.Sprintf("canary chunkservers %s are soaking, %s left",
+				deploymentNames(canaries), remaining.Round(time.Second))}
+		}
+		soak.Passed = true
+		if err := c.setCanarySoak(soak); err != nil {
+			return "", err
+		}
+		logger.Infof("canary chunkservers %s are healthy, rolling the other %d chunkservers", deploymentNames(canaries), len(others))
+	}
+	return "", c.rollDeployments(chunkserver.AppName, others, update)
+}
+
+// soakCanaries checks the canaries that began to soak at start, and returns the degradation if some of them
+// restarted since then or is not ready, or the copysets are not healthy after the duration. The remaining duration
+// is returned if the soak is not over.
+func (c *cluster) soakCanaries(canaries []appsv1.Deployment, start time.Time, duration time.Duration) (string, time.Duration, error) {
+	degradation, err := c.canaryPodsStatus(canaries, start)
+	if err != nil {
+		return "", 0, err
+	}
+	if degradation != "" {
+		return degradation, 0, nil
+	}
+	if remaining := time.Until(start.Add(duration)); remaining > 0 {
+		return "", remaining, nil
+	}
+
+	clusterInfo, err := config.GetClusterInfo(&c.context, c.NameSpace)
+	if err != nil {
+		return "", 0, err
+	}
+	health, err := mds.GetCopysetsHealth(&c.context, c.NameSpace, clusterInfo.MdsAddr)
+	if err != nil {
+		return "", 0, errors.Wrap(err, "failed to get copysets health after soaking canary chunkservers")
+	}
+	if health.Unhealthy > 0 {
+		return fmt.Sprintf("%d of %d copysets are unhealthy after soaking canary chunkservers %s for %s",
+			health.Unhealthy, health.Total, deploymentNames(canaries), duration), 0, nil
+	}
+	return "", 0, nil
+}
+
+// canaryPodsStatus returns the degradation if some pod of the canaries is not ready, or is recreated or restarted
+// after since
+func (c *cluster) canaryPodsStatus(canaries []appsv1.Deployment, since time.Time) (string, error) {
+	var problems []string
+	for _, d := range canaries {
+		pods, err := c.context.Clientset.CoreV1().Pods(c.NameSpace).List(metav1.ListOptions{
+			LabelSelector: labels.SelectorFromSet(d.Spec.Selector.MatchLabels).String(),
+		})
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to list pods of canary chunkserver %q", d.Name)
+		}
+		if len(pods.Items) == 0 {
+			problems = append(problems, fmt.Sprintf("canary chunkserver %s has no pod", d.Name))
+		}
+		for _, pod := range pods.Items {
+			if pod.CreationTimestamp.Time.After(since) {
+				problems = append(problems, fmt.Sprintf("pod of canary chunkserver %s is recreated", d.Name))
+			}
+			for _, status := range pod.Status.ContainerStatuses {
+				if terminated := status.LastTerminationState.Terminated; terminated != nil && terminated.FinishedAt.Time.After(since) {
+					problems = append(problems, fmt.Sprintf("canary chunkserver %s restarted at %s", d.Name,
+						terminated.FinishedAt.Format(time.RFC3339)))
+				}
+			}
+			if !podReady(&pod) {
+				problems = append(problems, fmt.Sprintf("canary chunkserver %s is not ready", d.Name))
+			}
+		}
+	}
+	return strings.Join(problems, ", "), nil
+}
+
+// setCanarySoak records the soak of the canaries in the upgrade status
+func (c *cluster) setCanarySoak(soak *curvev1.CanarySoakStatus) error {
+	if err := c.updateUpgradeStatus(func(status *curvev1.UpgradeStatus) {
+		status.Canary = soak.DeepCopy()
+	}); err != nil {
+		return errors.Wrap(err, "failed to record soak of canary chunkservers")
+	}
+	return nil
+}
+
+func podReady(pod *v1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func deploymentNames(deployments []appsv1.Deployment) string {
+	var names []string
+	for _, d := range deployments {
+		names = append(names, d.Name)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,409 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver"
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+	"github.com/opencurve/curve-operator/pkg/mds"
+)
+
+// capacityPollInterval is the interval to poll the capacity from mds
+const capacityPollInterval = time.Minute
+
+var (
+	clusterCapacityTotalBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "curve_cluster_capacity_total_bytes",
+		Help: "Total bytes of the physical pools of curve cluster",
+	}, []string{"namespace", "cluster"})
+	clusterCapacityUsedBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "curve_cluster_capacity_used_bytes",
+		Help: "Used bytes of the physical pools of curve cluster",
+	}, []string{"namespace", "cluster"})
+	poolCapacityTotalBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "curve_pool_capacity_total_bytes",
+		Help: "Total bytes of the logical pool of curve cluster",
+	}, []string{"namespace", "cluster", "pool"})
+	poolCapacityUsedBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "curve_pool_capacity_used_bytes",
+		Help: "Used bytes of the logical pool of curve cluster",
+	}, []string{"namespace", "cluster", "pool"})
+	copysetsUnhealthy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "curve_copysets_unhealthy",
+		Help: "Number of unhealthy copysets of curve cluster",
+	}, []string{"namespace", "cluster"})
+	copysetsTotal = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "curve_copysets_total",
+		Help: "Number of copysets of curve cluster",
+	}, []string{"namespace", "cluster"})
+	zoneCapacityTotalBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "curve_zone_capacity_total_bytes",
+		Help: "Total bytes of the chunkservers of a zone of curve cluster",
+	}, []string{"namespace", "cluster", "physical_pool", "zone"})
+	zoneCapacityUsedBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "curve_zone_capacity_used_bytes",
+		Help: "Used bytes of the chunkservers of a zone of curve cluster",
+	}, []string{"namespace", "cluster", "physical_pool", "zone"})
+	zoneReplicas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "curve_zone_replicas",
+		Help: "Number of copyset replicas on the chunkservers of a zone of curve cluster",
+	}, []string{"namespace", "cluster", "physical_pool", "zone"})
+	zoneWarnings = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "curve_zone_warnings",
+		Help: "Number of the physical pools that can't keep their replicas if a zone is lost and the unbalanced zones of curve cluster",
+	}, []string{"namespace", "cluster"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(clusterCapacityTotalBytes, clusterCapacityUsedBytes, poolCapacityTotalBytes, poolCapacityUsedBytes, copysetsUnhealthy, copysetsTotal,
+		zoneCapacityTotalBytes, zoneCapacityUsedBytes, zoneReplicas, zoneWarnings)
+}
+
+// CapacityReconciler polls the capacity of the cluster and its logical pools from mds, and exposes
+// them in the cluster status and as metrics of operator. The numbers of all the copysets and the
+// unhealthy ones are exposed in the same way, and so are the capacity and the copyset replicas of each zone with the
+// warnings of the zones that can't be lost. The versions of the running daemons are recorded in the status
+// on the same poll.
+type CapacityReconciler struct {
+	Client client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+
+	context clusterd.Context
+	// pools are the logical pools exported of each cluster, to delete the metrics of the removed pools
+	pools map[types.NamespacedName][]string
+	// zones are the physical pool and zone pairs exported of each cluster, to delete the metrics of the removed zones
+	zones map[types.NamespacedName][]mds.HostZone
+}
+
+func NewCapacityReconciler(
+	client client.Client,
+	log logr.Logger,
+	scheme *runtime.Scheme,
+	context clusterd.Context,
+) *CapacityReconciler {
+	context.Client = client
+
+	return &CapacityReconciler{
+		Client:  client,
+		Log:     log,
+		Scheme:  scheme,
+		context: context,
+		pools:   map[types.NamespacedName][]string{},
+		zones:   map[types.NamespacedName][]mds.HostZone{},
+	}
+}
+
+func (r *CapacityReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("curvecluster", req.NamespacedName)
+
+	clusterObj := &curvev1.CurveCluster{}
+	err := r.Client.Get(ctx, req.NamespacedName, clusterObj)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			r.deleteMetrics(req.NamespacedName)
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, errors.Wrapf(err, "failed to get curvecluster %q", req.NamespacedName)
+	}
+	if clusterObj.Spec == nil || !clusterObj.GetDeletionTimestamp().IsZero() {
+		r.deleteMetrics(req.NamespacedName)
+		return reconcile.Result{}, nil
+	}
+
+	// the ready daemons are counted even if mds is not started yet
+	readinessChanged, err := setReadiness(r.context.Clientset, clusterObj)
+	if err != nil {
+		log.Info("failed to count the ready daemons", "error", err.Error())
+	}
+	versionsChanged, err := setVersions(r.context.Clientset, clusterObj)
+	if err != nil {
+		log.Info("failed to get the versions of the daemons", "error", err.Error())
+	}
+	readinessChanged = readinessChanged || versionsChanged
+
+	capacity, err := mds.GetCapacity(r.context.Clientset, clusterObj.Namespace, clusterObj.Spec.Mds.DummyPort)
+	if err != nil {
+		// mds may be not started yet
+		log.Info("failed to get capacity from mds", "error", err.Error())
+		if readinessChanged {
+			if err := k8sutil.UpdateStatus(r.Client, req.NamespacedName, clusterObj); err != nil {
+				return reconcile.Result{}, err
+			}
+		}
+		return reconcile.Result{RequeueAfter: capacityPollInterval}, nil
+	}
+
+	r.setMetrics(req.NamespacedName, capacity)
+
+	clusterInfo, err := config.GetClusterInfo(&r.context, clusterObj.Namespace)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	copysets := clusterObj.Status.Copysets
+	health, err := mds.GetCopysetsHealth(&r.context, clusterObj.Namespace, clusterInfo.MdsAddr)
+	if err != nil {
+		log.Info("failed to get copysets health", "error", err.Error())
+	} else {
+		copysetsUnhealthy.WithLabelValues(req.Namespace, req.Name).Set(float64(health.Unhealthy))
+		copysetsTotal.WithLabelValues(req.Namespace, req.Name).Set(float64(health.Total))
+		if copysets == nil || copysets.Total != health.Total || copysets.Unhealthy != health.Unhealthy {
+			copysets = &curvev1.CopysetsStatus{Total: health.Total, Unhealthy: health.Unhealthy, LastUpdateTime: metav1.Now()}
+		}
+	}
+
+	status := capacityStatus(capacity)
+	zones, warnings, err := r.zoneCapacity(clusterObj, clusterInfo.MdsAddr)
+	if err != nil {
+		log.Info("failed to get the capacity of zones", "error", err.Error())
+		if clusterObj.Status.Capacity != nil {
+			status.Zones, status.ZoneWarnings = clusterObj.Status.Capacity.Zones, clusterObj.Status.Capacity.ZoneWarnings
+		}
+	} else {
+		r.setZoneMetrics(req.NamespacedName, zones, warnings)
+		for _, zone := range zones {
+			status.Zones = append(status.Zones, curvev1.ZoneCapacityStatus{
+				PhysicalPool: zone.PhysicalPool,
+				Name:         zone.Name,
+				ChunkServers: zone.ChunkServers,
+				TotalBytes:   zone.TotalBytes,
+				UsedBytes:    zone.UsedBytes,
+				UsedPercent:  usedPercent(zone.UsedBytes, zone.TotalBytes),
+				Replicas:     zone.Replicas,
+			})
+		}
+		status.ZoneWarnings = warnings
+	}
+	status.ReservationWarnings = reservationWarnings(clusterObj.Spec, status)
+	changed := capacityChanged(clusterObj.Status.Capacity, status)
+	if !changed && copysets == clusterObj.Status.Copysets && !readinessChanged {
+		return reconcile.Result{RequeueAfter: capacityPollInterval}, nil
+	}
+	if changed {
+		clusterObj.Status.Capacity = status
+	}
+	clusterObj.Status.Copysets = copysets
+	if err := k8sutil.UpdateStatus(r.Client, req.NamespacedName, clusterObj); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	return reconcile.Result{RequeueAfter: capacityPollInterval}, nil
+}
+
+func (r *CapacityReconciler) setMetrics(namespacedName types.NamespacedName, capacity *mds.Capacity) {
+	clusterCapacityTotalBytes.WithLabelValues(namespacedName.Namespace, namespacedName.Name).Set(float64(capacity.TotalBytes))
+	clusterCapacityUsedBytes.WithLabelValues(namespacedName.Namespace, namespacedName.Name).Set(float64(capacity.UsedBytes))
+
+	current := map[string]bool{}
+	for _, pool := range capacity.Pools {
+		current[pool.Name] = true
+		poolCapacityTotalBytes.WithLabelValues(namespacedName.Namespace, namespacedName.Name, pool.Name).Set(float64(pool.TotalBytes))
+		poolCapacityUsedBytes.WithLabelValues(namespacedName.Namespace, namespacedName.Name, pool.Name).Set(float64(pool.UsedBytes))
+	}
+	var pools []string
+	for _, name := range r.pools[namespacedName] {
+		if !current[name] {
+			poolCapacityTotalBytes.DeleteLabelValues(namespacedName.Namespace, namespacedName.Name, name)
+			poolCapacityUsedBytes.DeleteLabelValues(namespacedName.Namespace, namespacedName.Name, name)
+		}
+	}
+	for name := range current {
+		pools = append(pools, name)
+	}
+	r.pools[namespacedName] = pools
+}
+
+func (r *CapacityReconciler) deleteMetrics(namespacedName types.NamespacedName) {
+	clusterCapacityTotalBytes.DeleteLabelValues(namespacedName.Namespace, namespacedName.Name)
+	clusterCapacityUsedBytes.DeleteLabelValues(namespacedName.Namespace, namespacedName.Name)
+	copysetsUnhealthy.DeleteLabelValues(namespacedName.Namespace, namespacedName.Name)
+	copysetsTotal.DeleteLabelValues(namespacedName.Namespace, namespacedName.Name)
+	for _, name := range r.pools[namespacedName] {
+		poolCapacityTotalBytes.DeleteLabelValues(namespacedName.Namespace, namespacedName.Name, name)
+		poolCapacityUsedBytes.DeleteLabelValues(namespacedName.Namespace, namespacedName.Name, name)
+	}
+	delete(r.pools, namespacedName)
+	r.setZoneMetrics(namespacedName, nil, nil)
+	zoneWarnings.DeleteLabelValues(namespacedName.Namespace, namespacedName.Name)
+	delete(r.zones, namespacedName)
+}
+
+// zoneCapacity returns the capacity of the zones of the physical pools in topology.json and the warnings of them.
+// The chunkservers are mapped to the zones by the ips of their hosts.
+func (r *CapacityReconciler) zoneCapacity(clusterObj *curvev1.CurveCluster, mdsAddr string) ([]mds.ZoneCapacity, []string, error) {
+	cm, err := r.context.Clientset.CoreV1().ConfigMaps(clusterObj.Namespace).Get(config.TopoJsonConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to get configmap %q", config.TopoJsonConfigMapName)
+	}
+	topology := &chunkserver.CurveClusterTopo{}
+	if err := json.Unmarshal([]byte(cm.Data[config.TopoJsonConfigmapDataKey]), topology); err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to parse configmap %q", config.TopoJsonConfigMapName)
+	}
+	hostZones := map[string]mds.HostZone{}
+	for _, server := range topology.Servers {
+		hostZones[server.InternalIp] = mds.HostZone{PhysicalPool: server.PhysicalPool, Zone: server.Zone}
+	}
+	replicas := map[string]int{}
+	for _, pool := range topology.LogicalPools {
+		if pool.Replicas > replicas[pool.PhysicalPool] {
+			replicas[pool.PhysicalPool] = pool.Replicas
+		}
+	}
+
+	chunkservers, err := mds.ListChunkServers(&r.context, clusterObj.Namespace, mdsAddr)
+	if err != nil {
+		return nil, nil, err
+	}
+	zones := mds.ZoneDistribution(chunkservers, hostZones)
+	return zones, mds.ZoneLossWarnings(zones, replicas), nil
+}
+
+func (r *CapacityReconciler) setZoneMetrics(namespacedName types.NamespacedName, zones []mds.ZoneCapacity, warnings []string) {
+	current := map[mds.HostZone]bool{}
+	for _, zone := range zones {
+		current[mds.HostZone{PhysicalPool: zone.PhysicalPool, Zone: zone.Name}] = true
+		labels := []string{namespacedName.Namespace, namespacedName.Name, zone.PhysicalPool, zone.Name}
+		zoneCapacityTotalBytes.WithLabelValues(labels...).Set(float64(zone.TotalBytes))
+		zoneCapacityUsedBytes.WithLabelValues(labels...).Set(float64(zone.UsedBytes))
+		zoneReplicas.WithLabelValues(labels...).Set(float64(zone.Replicas))
+	}
+	zoneWarnings.WithLabelValues(namespacedName.Namespace, namespacedName.Name).Set(float64(len(warnings)))
+	var exported []mds.HostZone
+	for _, zone := range r.zones[namespacedName] {
+		if !current[zone] {
+			labels := []string{namespacedName.Namespace, namespacedName.Name, zone.PhysicalPool, zone.Zone}
+			zoneCapacityTotalBytes.DeleteLabelValues(labels...)
+			zoneCapacityUsedBytes.DeleteLabelValues(labels...)
+			zoneReplicas.DeleteLabelValues(labels...)
+		}
+	}
+	for zone := range current {
+		exported = append(exported, zone)
+	}
+	r.zones[namespacedName] = exported
+}
+
+func capacityStatus(capacity *mds.Capacity) *curvev1.CapacityStatus {
+	status := &curvev1.CapacityStatus{
+		TotalBytes:     capacity.TotalBytes,
+		UsedBytes:      capacity.UsedBytes,
+		UsedPercent:    usedPercent(capacity.UsedBytes, capacity.TotalBytes),
+		Usage:          formatBytes(capacity.UsedBytes) + "/" + formatBytes(capacity.TotalBytes),
+		LastUpdateTime: metav1.Now(),
+	}
+	for _, pool := range capacity.Pools {
+		status.Pools = append(status.Pools, curvev1.PoolCapacityStatus{
+			Name:        pool.Name,
+			TotalBytes:  pool.TotalBytes,
+			UsedBytes:   pool.UsedBytes,
+			UsedPercent: usedPercent(pool.UsedBytes, pool.TotalBytes),
+		})
+	}
+	return status
+}
+
+// reservationWarnings returns the devices that are formatted over the limit of storage.reservedPercentage by the
+// Allow overcommit policy, and the cluster and the pools whose used percent reaches the limit, so the capacity is
+// expanded before the reserved space is used up
+func reservationWarnings(spec *curvev1.CurveClusterSpec, status *curvev1.CapacityStatus) []string {
+	var warnings []string
+	for _, nodeName := range spec.StorageNodes() {
+		for _, device := range spec.Storage.OvercommittedDevices(nodeName) {
+			warnings = append(warnings, fmt.Sprintf("device %q on node %q is overcommitted by percentage %d over the limit %d",
+				device.Name, nodeName, device.Percentage, spec.Storage.MaxPercentage()))
+		}
+	}
+	if spec.Storage.ReservedPercentage == 0 {
+		return warnings
+	}
+	limit := spec.Storage.MaxPercentage()
+	if status.UsedPercent >= limit {
+		warnings = append(warnings, fmt.Sprintf("cluster is %d%% used, reaching the limit %d%% of reservedPercentage %d",
+			status.UsedPercent, limit, spec.Storage.ReservedPercentage))
+	}
+	for _, pool := range status.Pools {
+		if pool.UsedPercent >= limit {
+			warnings = append(warnings, fmt.Sprintf("pool %q is %d%% used, reaching the limit %d%% of reservedPercentage %d",
+				pool.Name, pool.UsedPercent, limit, spec.Storage.ReservedPercentage))
+		}
+	}
+	return warnings
+}
+
+// formatBytes returns the bytes in binary units with one decimal, such as 1.5Ti
+func formatBytes(bytes int64) string {
+	value, suffix := float64(bytes), ""
+	for _, unit := range []string{"Ki", "Mi", "Gi", "Ti", "Pi", "Ei"} {
+		if value < 1024 {
+			break
+		}
+		value /= 1024
+		suffix = unit
+	}
+	if suffix == "" {
+		return strconv.FormatInt(bytes, 10)
+	}
+	return strconv.FormatFloat(value, 'f', 1, 64) + suffix
+}
+
+func usedPercent(used, total int64) int {
+	if total == 0 {
+		return 0
+	}
+	return int(used * 100 / total)
+}
+
+// capacityChanged returns true if the total or used percent of the cluster or any pool or zone changed, or the
+// replicas or the warnings of the zones or the reservation changed. The status is not updated on every poll because
+// the update of status triggers the reconcile of cluster
+func capacityChanged(old, new *curvev1.CapacityStatus) bool {
+	if old == nil || old.TotalBytes != new.TotalBytes || old.UsedPercent != new.UsedPercent || len(old.Pools) != len(new.Pools) {
+		return true
+	}
+	for i := range old.Pools {
+		if old.Pools[i].Name != new.Pools[i].Name || old.Pools[i].TotalBytes != new.Pools[i].TotalBytes ||
+			old.Pools[i].UsedPercent != new.Pools[i].UsedPercent {
+			return true
+		}
+	}
+	if len(old.Zones) != len(new.Zones) || strings.Join(old.ZoneWarnings, "\n") != strings.Join(new.ZoneWarnings, "\n") ||
+		strings.Join(old.ReservationWarnings, "\n") != strings.Join(new.ReservationWarnings, "\n") {
+		return true
+	}
+	for i := range old.Zones {
+		if old.Zones[i].PhysicalPool != new.Zones[i].PhysicalPool || old.Zones[i].Name != new.Zones[i].Name ||
+			old.Zones[i].ChunkServers != new.Zones[i].ChunkServers || old.Zones[i].TotalBytes != new.Zones[i].TotalBytes ||
+			old.Zones[i].UsedPercent != new.Zones[i].UsedPercent || old.Zones[i].Replicas != new.Zones[i].Replicas {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *CapacityReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&curvev1.CurveCluster{}).
+		Named("capacity").
+		Complete(r)
+}
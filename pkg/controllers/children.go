@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"reflect"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// childResourcesHandler enqueues the owner cluster when a child resource is deleted or modified by others than
+// the operator. The cluster is dropped from the cluster map so that it's created again like after the operator
+// restarts, which applies all the resources and brings the missing ones back. The deletes that the operator
+// recorded by k8sutil.ExpectDelete, such as the ones of the garbage collection and the shrink, are skipped.
+func childResourcesHandler(c *ClusterController) handler.EventHandler {
+	enqueue := func(meta metav1.Object, q workqueue.RateLimitingInterface) {
+		owner := metav1.GetControllerOf(meta)
+		if owner == nil || owner.Kind != "CurveCluster" || !strings.HasPrefix(owner.APIVersion, curvev1.GroupVersion.Group+"/") {
+			return
+		}
+		// the cluster is not created yet or is being deleted
+		if _, ok := c.getCluster(meta.GetNamespace()); !ok {
+			return
+		}
+		logger.Infof("%s %q of cluster %q is deleted or modified, reconciling the cluster", reflect.TypeOf(meta).Elem().Name(), meta.GetName(), owner.Name)
+		c.deleteCluster(meta.GetNamespace())
+		q.Add(reconcile.Request{NamespacedName: types.NamespacedName{Namespace: meta.GetNamespace(), Name: owner.Name}})
+	}
+
+	return handler.Funcs{
+		UpdateFunc: func(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+			if childModified(e) && !changedByOperator(e.MetaOld, e.MetaNew) {
+				enqueue(e.MetaNew, q)
+			}
+		},
+		DeleteFunc: func(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+			// a finished job is not run again
+			if job, ok := e.Object.(*batch.Job); ok && job.Status.Active == 0 {
+				return
+			}
+			if k8sutil.DeleteExpected(reflect.TypeOf(e.Object).Elem().Name(), e.Meta.GetNamespace(), e.Meta.GetName()) {
+				return
+			}
+			enqueue(e.Meta, q)
+		},
+	}
+}
+
+// childModified returns true if the desired state of the child resource changed, the changes of status are
+// not interested
+func childModified(e event.UpdateEvent) bool {
+	switch newObj := e.ObjectNew.(type) {
+	case *appsv1.Deployment, *appsv1.StatefulSet:
+		return e.MetaOld.GetGeneration() != e.MetaNew.GetGeneration()
+	case *v1.ConfigMap:
+		oldObj, ok := e.ObjectOld.(*v1.ConfigMap)
+		return ok && !reflect.DeepEqual(oldObj.Data, newObj.Data)
+	case *v1.Service:
+		oldObj, ok := e.ObjectOld.(*v1.Service)
+		return ok && (!reflect.DeepEqual(oldObj.Spec.Ports, newObj.Spec.Ports) ||
+			!reflect.DeepEqual(oldObj.Spec.Selector, newObj.Spec.Selector))
+	}
+	return false
+}
+
+// changedByOperator returns true if the change of the object is made by the operator only. The change is made by
+// the managers whose managed fields or time changed, the fields taken over from another manager move to the one
+// that changed them. So a change of others is found even if it's in the same second as an apply of the operator,
+// which the times of the entries can't tell.
+func changedByOperator(oldMeta, newMeta metav1.Object) bool {
+	old := map[string]metav1.ManagedFieldsEntry{}
+	for _, entry := range oldMeta.GetManagedFields() {
+		old[managedFieldsKey(entry)] = entry
+	}
+	changed := false
+	for _, entry := range newMeta.GetManagedFields() {
+		last, ok := old[managedFieldsKey(entry)]
+		if ok && reflect.DeepEqual(last.FieldsV1, entry.FieldsV1) && reflect.DeepEqual(last.Time, entry.Time) {
+			continue
+		}
+		if entry.Manager != k8sutil.FieldManager {
+			return false
+		}
+		changed = true
+	}
+	return changed
+}
+
+func managedFieldsKey(entry metav1.ManagedFieldsEntry) string {
+	return entry.Manager + "/" + string(entry.Operation) + "/" + entry.APIVersion
+}
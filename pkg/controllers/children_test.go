@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+func TestChildResourcesHandlerUpdate(t *testing.T) {
+	c := &ClusterController{clusterMap: map[string]*cluster{}}
+	controller := true
+	applied := metav1.NewTime(time.Date(2026, 10, 17, 2, 0, 0, 0, time.UTC))
+	old := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+		Name:       "curve-chunkserver-node1-vdb",
+		Namespace:  "curvebs",
+		Generation: 1,
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: curvev1.GroupVersion.String(), Kind: "CurveCluster", Name: "my-cluster", Controller: &controller,
+		}},
+		ManagedFields: []metav1.ManagedFieldsEntry{{
+			Manager: k8sutil.FieldManager, Operation: metav1.ManagedFieldsOperationApply, APIVersion: "apps/v1",
+			Time: &applied, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{}}`)},
+		}},
+	}}
+	// update changes the image of the deployment by the manager, such as the rollout of the image by Update
+	update := func(manager string) *appsv1.Deployment {
+		d := old.DeepCopy()
+		d.Generation = 2
+		updated := metav1.NewTime(applied.Add(time.Second))
+		d.ManagedFields = append(d.ManagedFields, metav1.ManagedFieldsEntry{
+			Manager: manager, Operation: metav1.ManagedFieldsOperationUpdate, APIVersion: "apps/v1",
+			Time: &updated, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:template":{}}}`)},
+		})
+		return d
+	}
+
+	for _, tc := range []struct {
+		manager  string
+		enqueued int
+	}{
+		{k8sutil.FieldManager, 0},
+		{"kubectl", 1},
+	} {
+		c.clusterMap["curvebs"] = &cluster{NamespacedName: types.NamespacedName{Namespace: "curvebs", Name: "my-cluster"}}
+		q := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+		updated := update(tc.manager)
+		childResourcesHandler(c).Update(event.UpdateEvent{MetaOld: old, ObjectOld: old, MetaNew: updated, ObjectNew: updated}, q)
+		if q.Len() != tc.enqueued {
+			t.Errorf("expected %d requests enqueued by the update of %s, got %d", tc.enqueued, tc.manager, q.Len())
+		}
+		if _, ok := c.getCluster("curvebs"); ok != (tc.enqueued == 0) {
+			t.Errorf("expected the cluster dropped only by the update of others, the update of %s dropped it: %v", tc.manager, !ok)
+		}
+		q.ShutDown()
+	}
+}
@@ -15,6 +15,7 @@ import (
 	curvev1 "github.com/opencurve/curve-operator/api/v1"
 	"github.com/opencurve/curve-operator/pkg/chunkserver"
 	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/config"
 	"github.com/opencurve/curve-operator/pkg/etcd"
 	"github.com/opencurve/curve-operator/pkg/k8sutil"
 	"github.com/opencurve/curve-operator/pkg/mds"
@@ -23,6 +24,8 @@ import (
 const (
 	CleanupAppName                    = "curve-cleanup"
 	clusterCleanUpPolicyRetryInterval = 5 * time.Second
+	// cleanupConfirmRetryInterval is the interval that the deleted cluster waits for the confirmation of cleanup
+	cleanupConfirmRetryInterval = 30 * time.Second
 
 	dataVolumeName     = "data-cleanup-volume"
 	dataDirHostPathEnv = "CURVE_DATA_DIR_HOST_PATH"
@@ -50,7 +53,7 @@ func (c *ClusterController) startCleanUpJobs(cluster *curvev1.CurveCluster, node
 	for _, node := range nodesForJob {
 		logger.Infof("starting clean up job on node %q", node.Name)
 		jobName := k8sutil.TruncateNodeNameForJob("cluster-cleanup-job-%s", node.Name)
-		labels := getCleanupLabels("cleanup", c.namespacedName.Namespace)
+		labels := getCleanupLabels("cleanup", cluster.Namespace)
 		podSpec := c.cleanUpJobTemplateSpec(cluster)
 		podSpec.Spec.NodeName = node.Name
 		job := &batch.Job{
@@ -63,6 +66,7 @@ func (c *ClusterController) startCleanUpJobs(cluster *curvev1.CurveCluster, node
 				Template: podSpec,
 			},
 		}
+		k8sutil.InjectMetadata(*cluster.Spec, "", job, &job.Spec.Template)
 
 		if err := k8sutil.RunReplaceableJob(context.TODO(), c.context.Clientset, job, true); err != nil {
 			logger.Errorf("failed to run cluster clean up job on node %q. %v", node.Name, err)
@@ -74,7 +78,7 @@ func (c *ClusterController) startCleanUpJobs(cluster *curvev1.CurveCluster, node
 
 func (c *ClusterController) cleanUpJobTemplateSpec(cluster *curvev1.CurveCluster) v1.PodTemplateSpec {
 	volumes := []v1.Volume{}
-	dataHostPathVolume := v1.Volume{Name: dataVolumeName, VolumeSource: v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: cluster.Spec.HostDataDir}}}
+	dataHostPathVolume := v1.Volume{Name: dataVolumeName, VolumeSource: v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: config.HostDataDir(cluster.Spec)}}}
 	volumes = append(volumes, dataHostPathVolume)
 
 	podSpec := v1.PodTemplateSpec{
@@ -89,6 +93,10 @@ func (c *ClusterController) cleanUpJobTemplateSpec(cluster *curvev1.CurveCluster
 			RestartPolicy: v1.RestartPolicyOnFailure,
 		},
 	}
+	k8sutil.InjectEnv(&podSpec.Spec, *cluster.Spec, "")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, *cluster.Spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, *cluster.Spec)
+	k8sutil.InjectDNS(&podSpec.Spec, *cluster.Spec, "")
 
 	return podSpec
 }
@@ -97,19 +105,19 @@ func (c *ClusterController) cleanUpJobContainer(cluster *curvev1.CurveCluster) v
 	volumeMounts := []v1.VolumeMount{}
 	envVars := []v1.EnvVar{}
 
-	dataHhostPathVolumeMount := v1.VolumeMount{Name: dataVolumeName, MountPath: cluster.Spec.HostDataDir}
+	dataHhostPathVolumeMount := v1.VolumeMount{Name: dataVolumeName, MountPath: config.HostDataDir(cluster.Spec)}
 	volumeMounts = append(volumeMounts, dataHhostPathVolumeMount)
 
 	securityContext := k8sutil.PrivilegedContext(true)
 
 	envVars = append(envVars, []v1.EnvVar{
-		{Name: dataDirHostPathEnv, Value: strings.TrimRight(cluster.Spec.HostDataDir, "/")},
+		{Name: dataDirHostPathEnv, Value: strings.TrimRight(config.HostDataDir(cluster.Spec), "/")},
 	}...)
 
 	commandLine := `rm -rf $(CURVE_DATA_DIR_HOST_PATH)/*;`
 	return v1.Container{
 		Name:            "host-cleanup",
-		Image:           cluster.Spec.CurveVersion.Image,
+		Image:           cluster.Spec.CurveVersion.JobImage(),
 		ImagePullPolicy: cluster.Spec.CurveVersion.ImagePullPolicy,
 		Command: []string{
 			"/bin/bash",
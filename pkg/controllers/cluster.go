@@ -11,10 +11,13 @@ import (
 	curvev1 "github.com/opencurve/curve-operator/api/v1"
 	"github.com/opencurve/curve-operator/pkg/chunkserver"
 	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/dashboard"
 	"github.com/opencurve/curve-operator/pkg/etcd"
 	"github.com/opencurve/curve-operator/pkg/k8sutil"
 	"github.com/opencurve/curve-operator/pkg/mds"
+	"github.com/opencurve/curve-operator/pkg/monitoring"
 	"github.com/opencurve/curve-operator/pkg/snapshotclone"
+	"github.com/opencurve/curve-operator/pkg/tools"
 )
 
 // cluster represent a instance of Curve Cluster
@@ -30,11 +33,25 @@ type cluster struct {
 	ownerInfo          *k8sutil.OwnerInfo
 	isUpgrade          bool
 	observedGeneration int64
+	// blockedImage is the curve image that the cluster can't be upgraded to, it's loaded from the upgrade status
+	blockedImage string
+	// pausedImage is the curve image that the upgrade to is paused after the canary chunkservers degraded, it's
+	// loaded from the upgrade status
+	pausedImage string
+	// allowedImage is the curve image that passed the upgrade check, it's not checked again while the daemons are
+	// rolled to it
+	allowedImage string
+	// verifiedImages are the digests of the images whose signatures are verified keyed by the verify jobs
+	verifiedImages map[string]string
 }
 
 var logger = capnslog.NewPackageLogger("github.com/opencurve/curve-operator", "controller")
 
 func newCluster(ctx clusterd.Context, c *curvev1.CurveCluster, ownerInfo *k8sutil.OwnerInfo) *cluster {
+	var blockedImage, pausedImage string
+	if c.Status.Upgrade != nil {
+		blockedImage, pausedImage = c.Status.Upgrade.BlockedImage, c.Status.Upgrade.PausedImage
+	}
 	return &cluster{
 		// at this phase of the cluster creation process, the identity components of the cluster are
 		// not yet established. we reserve this struct which is filled in as soon as the cluster's
@@ -48,6 +65,8 @@ func newCluster(ctx clusterd.Context, c *curvev1.CurveCluster, ownerInfo *k8suti
 		// because generation can be changed before reconcile got completed
 		// CR status will be updated at end of reconcile, so to reflect the reconcile has finished
 		observedGeneration: c.ObjectMeta.Generation,
+		blockedImage:       blockedImage,
+		pausedImage:        pausedImage,
 	}
 }
 
@@ -60,6 +79,12 @@ func (c *cluster) reconcileCurveDaemons() error {
 	}
 	logger.Infof("using %v to create curve cluster", nodeNameIP)
 
+	// 0. Migrate the host path layout written by a former operator
+	err = c.migrateHostPathLayout()
+	if err != nil {
+		return errors.Wrap(err, "failed to migrate host path layout")
+	}
+
 	// 1. Create a pod to get all config file from curve image
 	job, err := c.makeReadConfJob()
 	if err != nil {
@@ -91,16 +116,18 @@ func (c *cluster) reconcileCurveDaemons() error {
 	if err != nil {
 		return errors.Wrap(err, "failed to start curve etcd")
 	}
+	err = etcds.ReconcileBackup()
+	if err != nil {
+		return errors.Wrap(err, "failed to reconcile etcd backup")
+	}
 
-	// TODO: wait to etcd election finished
-
-	// 3. Start Mds cluster and wait it startup
+	// 3. Start Mds cluster and wait it startup, mds pods wait for etcd by themselves
 	mds := mds.New(c.context, c.NamespacedName, *c.Spec, c.ownerInfo, c.dataDirHostPath, c.logDirHostPath, c.confDirHostPath)
 	err = mds.Start(nodeNameIP)
 	if err != nil {
 		return errors.Wrap(err, "failed to start curve mds")
 	}
-	k8sutil.UpdateCondition(context.TODO(), &c.context, c.NamespacedName, curvev1.ConditionTypeMdsReady, curvev1.ConditionTrue, curvev1.ConditionMdsClusterCreatedReason, "MDS cluster has been created")
+	k8sutil.SetReady(context.TODO(), &c.context, c.NamespacedName, curvev1.ConditionTypeMdsReady, curvev1.ConditionMdsClusterCreatedReason, "MDS cluster has been created")
 
 	// 4. chunkserver
 	chunkservers := chunkserver.New(c.context, c.NamespacedName, *c.Spec, c.ownerInfo, c.dataDirHostPath, c.logDirHostPath, c.confDirHostPath)
@@ -108,7 +135,11 @@ func (c *cluster) reconcileCurveDaemons() error {
 	if err != nil {
 		return errors.Wrap(err, "failed to start curve chunkserver")
 	}
-	k8sutil.UpdateCondition(context.TODO(), &c.context, c.NamespacedName, curvev1.ConditionTypeChunkServerReady, curvev1.ConditionTrue, curvev1.ConditionChunkServerClusterCreatedReason, "Chunkserver cluster has been created")
+	err = chunkservers.ReconcileDiskHealthCheckers()
+	if err != nil {
+		return errors.Wrap(err, "failed to reconcile disk health checkers")
+	}
+	k8sutil.SetReady(context.TODO(), &c.context, c.NamespacedName, curvev1.ConditionTypeChunkServerReady, curvev1.ConditionChunkServerClusterCreatedReason, "Chunkserver cluster has been created")
 
 	// 5. snapshotclone
 	if c.Spec.SnapShotClone.Enable {
@@ -118,7 +149,36 @@ func (c *cluster) reconcileCurveDaemons() error {
 			return errors.Wrap(err, "failed to start curve snapshotclone")
 		}
 	}
-	k8sutil.UpdateCondition(context.TODO(), &c.context, c.NamespacedName, curvev1.ConditionTypeSnapShotCloneReady, curvev1.ConditionTrue, curvev1.ConditionSnapShotCloneClusterCreatedReason, "Snapshotclone cluster has been created")
+	k8sutil.SetReady(context.TODO(), &c.context, c.NamespacedName, curvev1.ConditionTypeSnapShotCloneReady, curvev1.ConditionSnapShotCloneClusterCreatedReason, "Snapshotclone cluster has been created")
+
+	// 6. tools pod for diagnostics and the scrub cron job
+	if c.Spec.Tools.Enable {
+		err = tools.New(c.context, c.NamespacedName, *c.Spec, c.ownerInfo).Start()
+		if err != nil {
+			return errors.Wrap(err, "failed to start curve tools")
+		}
+	}
+	err = tools.New(c.context, c.NamespacedName, *c.Spec, c.ownerInfo).ReconcileScrub()
+	if err != nil {
+		return errors.Wrap(err, "failed to reconcile scrub")
+	}
+
+	// 7. alerts
+	err = monitoring.New(c.context, c.NamespacedName, *c.Spec, c.ownerInfo).ReconcileAlerts()
+	if err != nil {
+		return errors.Wrap(err, "failed to reconcile alerts")
+	}
+
+	// 8. dashboard
+	err = dashboard.New(c.context, c.NamespacedName, *c.Spec, c.ownerInfo).Reconcile()
+	if err != nil {
+		return errors.Wrap(err, "failed to reconcile dashboard")
+	}
+
+	// the devices that failed are reported after the other daemons are started
+	if err := chunkservers.NodeErrors(); err != nil {
+		return errors.Wrap(err, "failed to provision some devices")
+	}
 
 	return nil
 }
@@ -27,7 +27,7 @@ func (c *cluster) makeReadConfJob() (*batch.Job, error) {
 		logger.Error("failed to get pod information by curve=operator label")
 		// return &batch.Job{}, errors.Wrap(err, "failed to get curve-operator pod information")
 		// for test, it will not appear because the operator must be dispatched to a certain ground
-		nodeName = c.Spec.Nodes[0]
+		nodeName = c.Spec.EtcdNodes()[0]
 	} else {
 		nodeName = pods.Items[0].Spec.NodeName
 	}
@@ -50,6 +50,10 @@ func (c *cluster) makeReadConfJob() (*batch.Job, error) {
 			Volumes:       c.makeConfigHostPathVolume(),
 		},
 	}
+	k8sutil.InjectEnv(&podSpec.Spec, *c.Spec, "")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, *c.Spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, *c.Spec)
+	k8sutil.InjectDNS(&podSpec.Spec, *c.Spec, "")
 
 	job := &batch.Job{
 		ObjectMeta: metav1.ObjectMeta{
@@ -63,6 +67,7 @@ func (c *cluster) makeReadConfJob() (*batch.Job, error) {
 	}
 
 	// set ownerReference
+	k8sutil.InjectMetadata(*c.Spec, "", job, &job.Spec.Template)
 	err = c.ownerInfo.SetControllerReference(job)
 	if err != nil {
 		return &batch.Job{}, errors.Wrapf(err, "failed to set owner reference to %q job", job.GetName())
@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// destructiveConfirmed tells whether the operations that remove data from the pools are confirmed by the
+// annotation set to the name of the cluster
+func destructiveConfirmed(clusterObj *curvev1.CurveCluster) bool {
+	return clusterObj.GetAnnotations()[curvev1.ConfirmDestructiveAnnotation] == clusterObj.Name
+}
+
+// confirmationMessage returns the message that tells how to confirm the operation
+func confirmationMessage(clusterObj *curvev1.CurveCluster, action string) string {
+	return fmt.Sprintf("%s waits for the confirmation, set annotation %s=%s to confirm it",
+		action, curvev1.ConfirmDestructiveAnnotation, clusterObj.Name)
+}
+
+// setDestructiveBlocked sets the DestructiveBlocked warning of the cluster if the operation is blocked, or clears it
+// if it's set. It returns true if the status of the cluster is updated.
+func setDestructiveBlocked(c *clusterd.Context, clusterObj *curvev1.CurveCluster, blocked bool, message string) bool {
+	var current *curvev1.ClusterCondition
+	for i := range clusterObj.Status.Conditions {
+		if clusterObj.Status.Conditions[i].Type == curvev1.ConditionTypeDestructiveBlocked {
+			current = &clusterObj.Status.Conditions[i]
+		}
+	}
+	active := current != nil && current.Status == curvev1.ConditionTrue
+	if (blocked && active && current.Message == message) || (!blocked && !active) {
+		return false
+	}
+
+	reason := curvev1.ConditionDestructiveConfirmedReason
+	if blocked {
+		reason = curvev1.ConditionConfirmationRequiredReason
+	}
+	namespacedName := types.NamespacedName{Namespace: clusterObj.Namespace, Name: clusterObj.Name}
+	k8sutil.SetWarning(context.TODO(), c, namespacedName, curvev1.ConditionTypeDestructiveBlocked, blocked, reason, message)
+	return true
+}
@@ -0,0 +1,257 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+const (
+	// ConnectionSecretName is the secret of the connection info of the cluster for the clients
+	ConnectionSecretName = "curve-cluster-connection"
+	// connectionAppName is the app label of the copies of the connection secret in other namespaces
+	connectionAppName = "curve-cluster-connection"
+	// connectionInjectEnabled is the value of the inject label to copy the connection secrets to the namespace
+	connectionInjectEnabled = "enabled"
+	// ClientConfigMapName is the configmap of the client.conf of the cluster for the clients to mount
+	ClientConfigMapName = "curve-client-conf"
+	clientConfDataKey   = "client.conf"
+)
+
+// defaultClientConfig are the options of the mds failover of client.conf, connection.clientConfig overrides them
+var defaultClientConfig = map[string]string{
+	"mds.registerToMDS":                     "true",
+	"mds.rpcTimeoutMS":                      "500",
+	"mds.maxRPCTimeoutMS":                   "2000",
+	"mds.maxRetryMS":                        "8000",
+	"mds.rpcRetryIntervalUS":                "100000",
+	"mds.maxFailedTimesBeforeChangeMDS":     "2",
+	"mds.normalRetryTimesBeforeTriggerWait": "3",
+	"mds.waitSleepMs":                       "10000",
+}
+
+// reconcileConnection writes the current endpoints of the cluster into the connection secret and the client
+// configmap, and copies them to connection.exportNamespaces. The copies in the namespaces not exported any more
+// are deleted.
+func (c *cluster) reconcileConnection(spec *curvev1.CurveClusterSpec) error {
+	info, err := config.GetClusterInfo(&c.context, c.NameSpace)
+	if err != nil {
+		return err
+	}
+	data := map[string][]byte{}
+	for key, value := range c.connectionData(spec, info) {
+		data[key] = []byte(value)
+	}
+	clientData := map[string]string{clientConfDataKey: clientConf(spec, info)}
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ConnectionSecretName,
+			Namespace: c.NameSpace,
+		},
+		Type: v1.SecretTypeOpaque,
+		Data: data,
+	}
+	k8sutil.InjectMetadata(*spec, "", secret)
+	if err := c.ownerInfo.SetControllerReference(secret); err != nil {
+		return errors.Wrapf(err, "failed to set owner reference to secret %q", secret.Name)
+	}
+	if err := k8sutil.Apply(c.context.Client, secret); err != nil {
+		return err
+	}
+
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ClientConfigMapName,
+			Namespace: c.NameSpace,
+		},
+		Data: clientData,
+	}
+	k8sutil.InjectMetadata(*spec, "", cm)
+	if err := c.ownerInfo.SetControllerReference(cm); err != nil {
+		return errors.Wrapf(err, "failed to set owner reference to configmap %q", cm.Name)
+	}
+	if err := k8sutil.Apply(c.context.Client, cm); err != nil {
+		return err
+	}
+
+	exported, err := connectionExportNamespaces(c.context.Clientset, c.NameSpace, spec)
+	if err != nil {
+		return err
+	}
+	for namespace := range exported {
+		if err := exportConnection(c.context.Client, c.NameSpace, namespace, data); err != nil {
+			return err
+		}
+		if err := exportClientConf(c.context.Client, c.NameSpace, namespace, clientData); err != nil {
+			return err
+		}
+	}
+	return deleteExportedConnections(c.context.Clientset, c.NameSpace, exported)
+}
+
+// connectionExportNamespaces returns the namespaces that the connection secret of the cluster is copied to, which
+// are connection.exportNamespaces and the namespaces labeled to inject it
+func connectionExportNamespaces(clientset kubernetes.Interface, clusterNamespace string, spec *curvev1.CurveClusterSpec) (map[string]bool, error) {
+	namespaces, err := clientset.CoreV1().Namespaces().List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", curvev1.ConnectionInjectLabel, connectionInjectEnabled),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list namespaces to inject connection secret")
+	}
+	exported := map[string]bool{}
+	for _, namespace := range namespaces.Items {
+		if namespace.DeletionTimestamp.IsZero() {
+			exported[namespace.Name] = true
+		}
+	}
+	for _, namespace := range spec.Connection.ExportNamespaces {
+		exported[namespace] = true
+	}
+	delete(exported, clusterNamespace)
+	return exported, nil
+}
+
+// exportConnection copies the connection secret of the cluster to the namespace, the copies can't be owned by
+// the cluster across namespaces so they are found by labels
+func exportConnection(c client.Client, clusterNamespace, namespace string, data map[string][]byte) error {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      exportedConnectionSecretName(clusterNamespace),
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app":           connectionAppName,
+				"curve_cluster": clusterNamespace,
+			},
+		},
+		Type: v1.SecretTypeOpaque,
+		Data: data,
+	}
+	if err := k8sutil.Apply(c, secret); err != nil {
+		return errors.Wrapf(err, "failed to export connection secret to namespace %q", namespace)
+	}
+	return nil
+}
+
+// exportClientConf copies the client configmap of the cluster to the namespace, it's labeled like the copies of
+// the connection secret
+func exportClientConf(c client.Client, clusterNamespace, namespace string, data map[string]string) error {
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      exportedClientConfigMapName(clusterNamespace),
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app":           connectionAppName,
+				"curve_cluster": clusterNamespace,
+			},
+		},
+		Data: data,
+	}
+	if err := k8sutil.Apply(c, cm); err != nil {
+		return errors.Wrapf(err, "failed to export client configmap to namespace %q", namespace)
+	}
+	return nil
+}
+
+// clientConf returns the client.conf of the cluster with all the mds in the order of failover, the options of
+// connection.clientConfig are sorted after the generated address
+func clientConf(spec *curvev1.CurveClusterSpec, info *config.ClusterInfo) string {
+	options := map[string]string{}
+	for key, value := range defaultClientConfig {
+		options[key] = value
+	}
+	for key, value := range spec.Connection.ClientConfig {
+		options[key] = value
+	}
+	delete(options, "mds.listen.addr")
+
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	conf := fmt.Sprintf("mds.listen.addr=%s\n", info.MdsAddr)
+	for _, key := range keys {
+		conf += fmt.Sprintf("%s=%s\n", key, options[key])
+	}
+	return conf
+}
+
+// connectionData returns the connection info of the cluster by the recorded endpoints
+func (c *cluster) connectionData(spec *curvev1.CurveClusterSpec, info *config.ClusterInfo) map[string]string {
+	data := map[string]string{
+		"clusterName":      c.NamespacedName.Name,
+		"clusterNamespace": c.NameSpace,
+		"etcdAddr":         info.EtcdAddr,
+		"mdsAddr":          info.MdsAddr,
+		"client.conf":      clientConf(spec, info),
+	}
+	tools := []string{
+		fmt.Sprintf("mdsAddr=%s", info.MdsAddr),
+		fmt.Sprintf("mdsDummyPort=%d", spec.Mds.DummyPort),
+		fmt.Sprintf("etcdAddr=%s", info.EtcdAddr),
+	}
+	if spec.SnapShotClone.Enable && info.SnapShotCloneAddr != "" {
+		data["snapshotCloneAddr"] = info.SnapShotCloneAddr
+		tools = append(tools, fmt.Sprintf("snapshotCloneAddr=%s", info.SnapShotCloneAddr))
+	}
+	data["tools.conf"] = strings.Join(tools, "\n") + "\n"
+	return data
+}
+
+func exportedConnectionSecretName(clusterNamespace string) string {
+	return fmt.Sprintf("%s-%s", ConnectionSecretName, clusterNamespace)
+}
+
+func exportedClientConfigMapName(clusterNamespace string) string {
+	return fmt.Sprintf("%s-%s", ClientConfigMapName, clusterNamespace)
+}
+
+// deleteExportedConnections deletes the copies of the connection secret and the client configmap of the cluster
+// in the namespaces not in exported
+func deleteExportedConnections(clientset kubernetes.Interface, clusterNamespace string, exported map[string]bool) error {
+	options := metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=%s,curve_cluster=%s", connectionAppName, clusterNamespace),
+	}
+	secrets, err := clientset.CoreV1().Secrets(metav1.NamespaceAll).List(options)
+	if err != nil {
+		return errors.Wrap(err, "failed to list exported connection secrets")
+	}
+	for _, secret := range secrets.Items {
+		if exported[secret.Namespace] {
+			continue
+		}
+		err := clientset.CoreV1().Secrets(secret.Namespace).Delete(secret.Name, &metav1.DeleteOptions{})
+		if err != nil && !kerrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete connection secret %s/%s", secret.Namespace, secret.Name)
+		}
+		logger.Infof("deleted connection secret %s/%s that is not exported any more", secret.Namespace, secret.Name)
+	}
+
+	configMaps, err := clientset.CoreV1().ConfigMaps(metav1.NamespaceAll).List(options)
+	if err != nil {
+		return errors.Wrap(err, "failed to list exported client configmaps")
+	}
+	for _, cm := range configMaps.Items {
+		if exported[cm.Namespace] {
+			continue
+		}
+		err := clientset.CoreV1().ConfigMaps(cm.Namespace).Delete(cm.Name, &metav1.DeleteOptions{})
+		if err != nil && !kerrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete client configmap %s/%s", cm.Namespace, cm.Name)
+		}
+		logger.Infof("deleted client configmap %s/%s that is not exported any more", cm.Namespace, cm.Name)
+	}
+	return nil
+}
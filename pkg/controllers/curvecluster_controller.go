@@ -18,76 +18,135 @@ package controllers
 
 import (
 	"context"
-	"path"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/audit"
+	"github.com/opencurve/curve-operator/pkg/chunkserver"
 	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/config"
 	"github.com/opencurve/curve-operator/pkg/k8sutil"
+	"github.com/opencurve/curve-operator/pkg/monitoring"
+	"github.com/opencurve/curve-operator/pkg/notify"
+	"github.com/opencurve/curve-operator/pkg/snapshotclone"
+	"github.com/opencurve/curve-operator/pkg/tools"
+	"github.com/opencurve/curve-operator/pkg/validation"
+	"github.com/opencurve/curve-operator/pkg/version"
 )
 
 // ClusterController controls an instance of a Curve Cluster
 type ClusterController struct {
-	context        clusterd.Context
-	namespacedName types.NamespacedName
+	context clusterd.Context
+	// clusterMap is keyed by namespace and guarded by clusterMapLock,
+	// because the clusters in different namespaces can be reconciled concurrently
 	clusterMap     map[string]*cluster
+	clusterMapLock sync.Mutex
 }
 
 // CurveClusterReconciler reconciles a CurveCluster object
 type CurveClusterReconciler struct {
-	Client client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Client   client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// MaxConcurrentReconciles is the number of CurveClusters that can be reconciled concurrently, default is 1
+	MaxConcurrentReconciles int
+	// ResyncPeriod is the period that the ready CurveClusters are reconciled again without the changes of spec, so
+	// that the updates that depend on the state of the cluster are retried, they are not resynced if it's 0
+	ResyncPeriod time.Duration
+	// ReconcileQPS and ReconcileBurst limit the reconciles of each CurveCluster, they are not limited if
+	// ReconcileQPS is 0
+	ReconcileQPS   float64
+	ReconcileBurst int
 
 	ClusterController *ClusterController
+
+	// transientBackoff is the backoff of requeueing the clusters that failed by transient errors
+	transientBackoff workqueue.RateLimiter
+	// rateLimiter is created of ReconcileQPS and ReconcileBurst by SetupWithManager
+	rateLimiter *clusterRateLimiter
 }
 
 func NewCurveClusterReconciler(
 	client client.Client,
 	log logr.Logger,
 	scheme *runtime.Scheme,
+	recorder record.EventRecorder,
 	context clusterd.Context,
 ) *CurveClusterReconciler {
+	context.Client = client
 
 	return &CurveClusterReconciler{
-		Client: client,
-		Log:    log,
-		Scheme: scheme,
+		Client:   client,
+		Log:      log,
+		Scheme:   scheme,
+		Recorder: recorder,
 		ClusterController: &ClusterController{
 			context:    context,
 			clusterMap: make(map[string]*cluster),
 		},
+		transientBackoff: newTransientBackoff(),
 	}
 }
 
 // +kubebuilder:rbac:groups=operator.curve.io,resources=curveclusters,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;create;update
 // +kubebuilder:rbac:groups=operator.curve.io,resources=curveclusters/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;delete
+// +kubebuilder:rbac:groups=core,resources=pods/exec,verbs=create
+// +kubebuilder:rbac:groups=core,resources=pods/log,verbs=get
 // +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch;update;patch
 // +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=events,verbs=get;list;watch;create;update;patch
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;delete
 // +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=scheduling.k8s.io,resources=priorityclasses,verbs=get;list;watch
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=prometheusrules,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=route.openshift.io,resources=routes,verbs=get;list;watch;create;update;patch;delete
 
 func (r *CurveClusterReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	// the cluster is requeued when its next token is available if it's reconciled too often
+	if delay := r.rateLimiter.Delay(req.NamespacedName); delay > 0 {
+		return reconcile.Result{RequeueAfter: delay}, nil
+	}
+
 	ctx := context.Background()
-	log := r.Log.WithValues("curvecluster", req.NamespacedName)
+	// the changes made by the reconcile are audited with its id
+	reconcileID := audit.StartReconcile(req.Namespace)
+	defer audit.EndReconcile(req.Namespace)
+	log := r.Log.WithValues("curvecluster", req.NamespacedName, "reconcileID", reconcileID)
 
 	// your logic here
 	log.Info("reconcileing CurveCluster")
 
-	r.ClusterController.context.Client = r.Client
-	r.ClusterController.namespacedName = req.NamespacedName
-
 	// Fetch the curveCluster instance
 	var curveCluster curvev1.CurveCluster
 	err := r.Client.Get(ctx, req.NamespacedName, &curveCluster)
@@ -95,6 +154,7 @@ func (r *CurveClusterReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error
 		if kerrors.IsNotFound(err) {
 			// Arrive it represent the cluster has been delete
 			log.Error(err, "curveCluster resource not found. Ignoring since object must be deleted.")
+			r.rateLimiter.Forget(req.NamespacedName)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
@@ -112,24 +172,139 @@ func (r *CurveClusterReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error
 		return r.reconcileDelete(&curveCluster)
 	}
 
+	// Refuse to reconcile the cluster if the versions are not compatible with this operator
+	if err := checkVersionSkew(&curveCluster); err != nil {
+		log.Error(err, "version check failed, set annotation to skip it", "annotation", version.SkipVersionCheckAnnotation)
+		k8sutil.SetError(context.TODO(), &r.ClusterController.context, req.NamespacedName, curvev1.ConditionVersionSkewReason, err.Error())
+		// the cluster will be reconciled again when the spec or annotation changed
+		return reconcile.Result{}, nil
+	}
+
+	if curveCluster.Annotations[curvev1.PauseReconcileAnnotation] == "true" {
+		log.Info("reconcile is paused, remove the annotation to resume it", "annotation", curvev1.PauseReconcileAnnotation)
+		return reconcile.Result{}, nil
+	}
+
+	if err := r.reconcileReplaceDevice(&curveCluster); err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "failed to replace device")
+	}
+
+	shrinking, err := r.reconcileShrink(&curveCluster)
+	if err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "failed to shrink chunkservers")
+	}
+
+	// nothing is deployed until the exported topology of spec.topologyImport is imported and the existing cluster
+	// of spec.adoption is adopted, the failed ones are tried again when the spec changes
+	ready, err := r.reconcileTopologyImport(&curveCluster)
+	if err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "failed to import topology")
+	}
+	if ready {
+		if ready, err = r.reconcileAdoption(&curveCluster); err != nil {
+			return reconcile.Result{}, errors.Wrap(err, "failed to adopt cluster")
+		}
+	}
+	if !ready {
+		return reconcile.Result{}, nil
+	}
+
 	ownerInfo := k8sutil.NewOwnerInfo(&curveCluster, r.Scheme)
 	// reconcileCurveCluster func to run reconcile curve cluster
-	if err := r.ClusterController.reconcileCurveCluster(&curveCluster, ownerInfo); err != nil {
-		k8sutil.UpdateCondition(context.TODO(), &r.ClusterController.context, r.ClusterController.namespacedName, curvev1.ConditionTypeFailure, curvev1.ConditionTrue, curvev1.ConditionReconcileFailed, "Reconcile curvecluster failed")
+	err = r.ClusterController.reconcileCurveCluster(&curveCluster, ownerInfo)
+	// the config is dumped even if the reconcile failed, which is when it's needed most
+	if dumpErr := r.reconcileConfigDump(&curveCluster, ownerInfo); dumpErr != nil {
+		log.Error(dumpErr, "failed to dump config", "annotation", curvev1.DumpConfigAnnotation)
+	}
+	if exportErr := r.reconcileTopologyExport(&curveCluster, ownerInfo); exportErr != nil {
+		log.Error(exportErr, "failed to export topology", "annotation", curvev1.ExportTopologyAnnotation)
+	}
+	// the roll waiting for the pause between the batches of chunkservers or the canaries goes on when the cluster
+	// is requeued
+	if pause, ok := errors.Cause(err).(*rolloutPause); ok {
+		log.Info("rollout is in progress, requeueing", "reason", pause.Error(), "after", pause.after.String())
+		return ctrl.Result{RequeueAfter: pause.after}, nil
+	}
+	if err != nil {
+		k8sutil.SetErrors(context.TODO(), &r.ClusterController.context, req.NamespacedName, err)
+		// the transient errors such as a configmap of the daemons not created yet are retried with backoff, the
+		// cluster is not marked Failed unless they keep failing
+		if k8sutil.IsTransient(err) {
+			failures := r.transientBackoff.NumRequeues(req.NamespacedName)
+			backoff := r.transientBackoff.When(req.NamespacedName)
+			log.Info("reconcile failed by a transient error, retrying", "error", err.Error(), "failures", failures+1, "after", backoff.String())
+			if failures+1 >= transientFailureThreshold {
+				reason, message := failureReason(err)
+				k8sutil.SetError(context.TODO(), &r.ClusterController.context, req.NamespacedName, reason, message)
+			}
+			return ctrl.Result{RequeueAfter: backoff}, nil
+		}
+		r.transientBackoff.Forget(req.NamespacedName)
+		reason, message := failureReason(err)
+		k8sutil.SetError(context.TODO(), &r.ClusterController.context, req.NamespacedName, reason, message)
 		return reconcile.Result{}, errors.Wrapf(err, "failed to reconcile cluster %q", curveCluster.Name)
 	}
+	r.transientBackoff.Forget(req.NamespacedName)
 
-	k8sutil.UpdateCondition(context.TODO(), &r.ClusterController.context, r.ClusterController.namespacedName, curvev1.ConditionTypeClusterReady, curvev1.ConditionTrue, curvev1.ConditionReconcileSucceeded, "Reconcile curvecluster successed")
+	k8sutil.SetErrors(context.TODO(), &r.ClusterController.context, req.NamespacedName, nil)
+	k8sutil.SetReady(context.TODO(), &r.ClusterController.context, req.NamespacedName, curvev1.ConditionTypeClusterReady, curvev1.ConditionReconcileSucceeded, "Reconcile curvecluster successed")
+	if curveCluster.Status.Phase != curvev1.ClusterPhaseReady {
+		notify.Notify(r.ClusterController.context.Clientset, req.NamespacedName, curveCluster.Spec, curvev1.NotificationEventClusterReady,
+			fmt.Sprintf("cluster is ready with curve image %s", curveCluster.Spec.CurveVersion.Image))
+	}
 
-	return ctrl.Result{}, nil
+	// the deferred disruptive operations are applied when the next maintenance window opens
+	result := ctrl.Result{RequeueAfter: untilMaintenanceWindow(curveCluster.Spec)}
+	if shrinking && (result.RequeueAfter == 0 || result.RequeueAfter > shrinkCheckInterval) {
+		result.RequeueAfter = shrinkCheckInterval
+	}
+	// the cluster is resynced periodically to run the updates of the existing cluster again, such as joining the
+	// pending storage nodes, the disk health checkers and the status of the images. The children are not applied
+	// again by it, the ones deleted or modified by others are brought back by their watches
+	if r.ResyncPeriod > 0 && (result.RequeueAfter == 0 || result.RequeueAfter > r.ResyncPeriod) {
+		result.RequeueAfter = r.ResyncPeriod
+	}
+	return result, nil
+}
+
+// failureReason returns the reason and message of the failure condition for the reconcile error
+func failureReason(err error) (curvev1.ConditionReason, string) {
+	switch cause := errors.Cause(err).(type) {
+	case *k8sutil.UnknownNodesError:
+		return curvev1.ConditionUnknownNodesReason, cause.Error()
+	case *chunkserver.PreflightError:
+		return curvev1.ConditionPreflightFailedReason, cause.Error()
+	case *chunkserver.TopologyError:
+		return curvev1.ConditionInvalidTopologyReason, cause.Error()
+	case *chunkserver.PoolCreationError:
+		return curvev1.ConditionPoolCreationFailedReason, cause.Error()
+	case *snapshotclone.MissingS3ConfigError:
+		return curvev1.ConditionMissingS3ConfigReason, cause.Error()
+	case *tools.ImageVerificationError:
+		return curvev1.ConditionImageVerificationFailedReason, cause.Error()
+	default:
+		return curvev1.ConditionReconcileFailed, "Reconcile curvecluster failed"
+	}
 }
 
 // reconcileDelete
 func (r *CurveClusterReconciler) reconcileDelete(curveCluster *curvev1.CurveCluster) (reconcile.Result, error) {
 	log.Log.Info("Delete the cluster CR now", "namespace", curveCluster.ObjectMeta.Name)
-	k8sutil.UpdateCondition(context.TODO(), &r.ClusterController.context, r.ClusterController.namespacedName, curvev1.ConditionTypeDeleting, curvev1.ConditionTrue, curvev1.ConditionDeletingClusterReason, "Reconcile curvecluster deleting")
-
-	if curveCluster.Spec.CleanupConfirm == "Confirm" || curveCluster.Spec.CleanupConfirm == "confirm" {
+	namespacedName := types.NamespacedName{Namespace: curveCluster.Namespace, Name: curveCluster.Name}
+	k8sutil.SetDeleting(context.TODO(), &r.ClusterController.context, namespacedName, "Reconcile curvecluster deleting")
+
+	cleanup := curveCluster.Spec.CleanupConfirm == "Confirm" || curveCluster.Spec.CleanupConfirm == "confirm"
+	if cleanup && !destructiveConfirmed(curveCluster) {
+		// the finalizer is kept until the annotation is set or cleanupConfirm is cleared, so that the cleanup asked
+		// by cleanupConfirm isn't dropped silently
+		message := fmt.Sprintf("%s, or clear cleanupConfirm to keep the data on the hosts",
+			confirmationMessage(curveCluster, "cleanup by cleanupConfirm"))
+		logger.Warningf("cluster %q is not deleted. %s", curveCluster.Name, message)
+		r.Recorder.Event(curveCluster, v1.EventTypeWarning, "CleanupConfirmationRequired", message)
+		setDestructiveBlocked(&r.ClusterController.context, curveCluster, true, message)
+		return reconcile.Result{RequeueAfter: cleanupConfirmRetryInterval}, nil
+	}
+	if cleanup {
 		daemonHosts, _ := k8sutil.GetValidDaemonHosts(r.ClusterController.context, curveCluster)
 		chunkserverHosts, _ := k8sutil.GetValidChunkserverHosts(r.ClusterController.context, curveCluster)
 		nodesForJob := k8sutil.MergeNodesOfDaemonAndChunk(daemonHosts, chunkserverHosts)
@@ -137,12 +312,15 @@ func (r *CurveClusterReconciler) reconcileDelete(curveCluster *curvev1.CurveClus
 		go r.ClusterController.startClusterCleanUp(r.ClusterController.context, curveCluster, nodesForJob)
 	}
 
-	// Delete it from clusterMap
-	if _, ok := r.ClusterController.clusterMap[curveCluster.Namespace]; ok {
-		delete(r.ClusterController.clusterMap, curveCluster.Namespace)
+	// the connection secret in the namespace of the cluster is deleted with it by the owner reference
+	if err := deleteExportedConnections(r.ClusterController.context.Clientset, curveCluster.Namespace, nil); err != nil {
+		return reconcile.Result{}, err
 	}
+
+	// Delete it from clusterMap
+	r.ClusterController.deleteCluster(curveCluster.Namespace)
 	// Remove finalizers
-	err := r.removeFinalizer(r.Client, r.ClusterController.namespacedName, curveCluster, "")
+	err := r.removeFinalizer(r.Client, namespacedName, curveCluster, "")
 	if err != nil {
 		return reconcile.Result{}, errors.Wrap(err, "failed to remove curvecluster cr finalizers")
 	}
@@ -154,66 +332,228 @@ func (r *CurveClusterReconciler) reconcileDelete(curveCluster *curvev1.CurveClus
 
 // reconcileCurveCluster
 func (c *ClusterController) reconcileCurveCluster(clusterObj *curvev1.CurveCluster, ownerInfo *k8sutil.OwnerInfo) error {
+	// the storage nodes resolved by node selector are not written back to the object
+	clusterObj = clusterObj.DeepCopy()
+	if err := resolveStorageNodes(c.context.Clientset, clusterObj.Namespace, clusterObj.Spec); err != nil {
+		return err
+	}
+	if err := resolveSnapShotCloneNodes(c.context.Clientset, clusterObj.Namespace, clusterObj.Spec); err != nil {
+		return err
+	}
+	if err := validatePriorityClasses(c.context.Clientset, clusterObj.Spec); err != nil {
+		return err
+	}
+	// snapshotclone can't start without s3, nothing is deployed until it's configured
+	if err := snapshotclone.ValidateS3Config(clusterObj.Spec); err != nil {
+		return err
+	}
+	if err := k8sutil.ValidateMaintenanceWindow(clusterObj.Spec.MaintenanceWindow); err != nil {
+		return err
+	}
+	if toolsImage := clusterObj.Spec.CurveVersion.ToolsImage; toolsImage != "" {
+		if err := version.CheckToolsImage(toolsImage, clusterObj.Spec.CurveVersion.Image); err != nil {
+			return k8sutil.NewConfigError(err)
+		}
+	}
+
+	skipCheck := clusterObj.GetAnnotations()[version.SkipVersionCheckAnnotation] == "true"
 	// one cr cluster in one namespace is allowed
-	cluster, ok := c.clusterMap[clusterObj.Namespace]
+	cluster, ok := c.getCluster(clusterObj.Namespace)
 	if !ok {
 		logger.Info("A new Cluster will be created!!!")
 		cluster = newCluster(c.context, clusterObj, ownerInfo)
-		// TODO: update cluster spec if the cluster has already exist!
 	} else {
-		logger.Info("Cluster has been exist but need configured but we don't apply it now, you need delete it and recreate it!!!", "namespace", cluster.NameSpace)
+		// log level, image, nodes of daemons, flags of mds and extra args of chunkserver can be changed on the fly,
+		// other changes are not applied now
+		cluster.Spec.UpdateStrategy = clusterObj.Spec.UpdateStrategy
+		cluster.Spec.MaintenanceWindow = clusterObj.Spec.MaintenanceWindow
+		if err := cluster.verifyImages(clusterObj.Spec); err != nil {
+			return errors.Wrap(err, "failed to verify images")
+		}
+		if err := cluster.updateImage(clusterObj.Spec, skipCheck); err != nil {
+			return errors.Wrap(err, "failed to update image")
+		}
+		if err := cluster.updateLogLevels(clusterObj.Spec); err != nil {
+			return errors.Wrap(err, "failed to update log level")
+		}
+		if err := cluster.updateMdsFlags(clusterObj.Spec); err != nil {
+			return errors.Wrap(err, "failed to update flags of mds")
+		}
+		if err := cluster.updateExtraArgs(clusterObj.Spec); err != nil {
+			return errors.Wrap(err, "failed to update extra args of chunkserver")
+		}
+		// the configs embedding the changed endpoints are regenerated
+		if err := cluster.reconcileEndpoints(clusterObj.Spec); err != nil {
+			return errors.Wrap(err, "failed to reconcile endpoints")
+		}
+		if err := cluster.joinPendingNodes(clusterObj.Status.PendingStorageNodes); err != nil {
+			return errors.Wrap(err, "failed to join pending storage nodes")
+		}
+		if err := cluster.reconcileDiskHealth(clusterObj.Spec); err != nil {
+			return errors.Wrap(err, "failed to reconcile disk health checkers")
+		}
+		if err := cluster.reconcileEtcdBackup(clusterObj.Spec); err != nil {
+			return errors.Wrap(err, "failed to reconcile etcd backup")
+		}
+		if err := cluster.reconcileScrub(clusterObj.Spec); err != nil {
+			return errors.Wrap(err, "failed to reconcile scrub")
+		}
+		if err := cluster.reconcileSelfTest(clusterObj.Spec); err != nil {
+			return errors.Wrap(err, "failed to reconcile self test")
+		}
+		if err := cluster.reconcileHostPrune(clusterObj.Spec); err != nil {
+			return errors.Wrap(err, "failed to reconcile host prune")
+		}
+		if err := cluster.reconcileConnection(clusterObj.Spec); err != nil {
+			return errors.Wrap(err, "failed to reconcile connection secret")
+		}
+		if err := cluster.recordImages(clusterObj.Spec); err != nil {
+			return errors.Wrap(err, "failed to record images")
+		}
+		if err := monitoring.New(c.context, cluster.NamespacedName, *clusterObj.Spec, cluster.ownerInfo).ReconcileAlerts(); err != nil {
+			return errors.Wrap(err, "failed to reconcile alerts")
+		}
+		logger.Infof("the changes of cluster in namespace %q are applied in place", cluster.NameSpace)
 		return nil
 	}
 
 	// Set the context and NameSpacedName
 	cluster.context = c.context
-	cluster.NamespacedName = c.namespacedName
-	cluster.NameSpace = c.namespacedName.Namespace
+	cluster.NamespacedName = types.NamespacedName{Namespace: clusterObj.Namespace, Name: clusterObj.Name}
+	cluster.NameSpace = clusterObj.Namespace
 	// Set the spec
 	cluster.Spec = clusterObj.Spec
-	cluster.dataDirHostPath = path.Join(clusterObj.Spec.HostDataDir, "data")
-	cluster.logDirHostPath = path.Join(clusterObj.Spec.HostDataDir, "logs")
-	cluster.confDirHostPath = path.Join(clusterObj.Spec.HostDataDir, "conf")
+	// the daemons are deployed with the image that they were rolled to before the operator restarted, and rolled to
+	// the image of the spec by updateImage after the cluster is initialized so that the upgrade is checked and a
+	// paused upgrade stays paused
+	if upgrade := clusterObj.Status.Upgrade; upgrade != nil && upgrade.Image != "" && upgrade.Image != clusterObj.Spec.CurveVersion.Image {
+		cluster.Spec = clusterObj.Spec.DeepCopy()
+		cluster.Spec.CurveVersion.Image = upgrade.Image
+	}
+	cluster.dataDirHostPath, cluster.logDirHostPath, cluster.confDirHostPath = config.HostDaemonDirs(clusterObj.Spec)
 
 	// updating observedGeneration in cluster if it's not the first reconcile
 	cluster.observedGeneration = clusterObj.ObjectMeta.Generation
 
-	c.clusterMap[cluster.NameSpace] = cluster
+	c.setCluster(cluster)
 
 	log.Log.Info("reconcileing CurveCluster in namespace", "namespace", cluster.NameSpace)
 
 	// Start the main Curve cluster orchestration
-	return c.initCluster(cluster)
+	if err := c.initCluster(cluster); err != nil {
+		return err
+	}
+	if err := cluster.verifyImages(clusterObj.Spec); err != nil {
+		return errors.Wrap(err, "failed to verify images")
+	}
+	if err := cluster.updateImage(clusterObj.Spec, skipCheck); err != nil {
+		return errors.Wrap(err, "failed to update image")
+	}
+	return nil
+}
+
+func (c *ClusterController) getCluster(namespace string) (*cluster, bool) {
+	c.clusterMapLock.Lock()
+	defer c.clusterMapLock.Unlock()
+	cluster, ok := c.clusterMap[namespace]
+	return cluster, ok
+}
+
+func (c *ClusterController) setCluster(cluster *cluster) {
+	c.clusterMapLock.Lock()
+	defer c.clusterMapLock.Unlock()
+	c.clusterMap[cluster.NameSpace] = cluster
+}
+
+func (c *ClusterController) deleteCluster(namespace string) {
+	c.clusterMapLock.Lock()
+	defer c.clusterMapLock.Unlock()
+	delete(c.clusterMap, namespace)
 }
 
 // initCluster initialize cluster info
 func (c *ClusterController) initCluster(cluster *cluster) error {
-	err := preClusterStartValidation(cluster)
+	err := validation.ValidateDaemonNodes(cluster.Spec)
 	if err != nil {
-		return errors.Wrap(err, "failed to preforem validation before cluster creation")
+		return errors.Wrap(k8sutil.NewConfigError(err), "failed to preforem validation before cluster creation")
+	}
+	err = k8sutil.CheckNodeArchitectures(c.context.Clientset, k8sutil.MergeNodeNames(cluster.Spec.DaemonNodes(), cluster.Spec.StorageNodes()),
+		cluster.Spec.CurveVersion.Architectures)
+	if err != nil {
+		return err
+	}
+	if cluster.Spec.TimeSync.Check {
+		c.checkTimeSync(cluster)
+	}
+	if err := cluster.verifyImages(cluster.Spec); err != nil {
+		return errors.Wrap(err, "failed to verify images")
 	}
 	err = cluster.reconcileCurveDaemons()
 	if err != nil {
 		return errors.Wrap(err, "failed to create cluster")
 	}
-	return nil
+	if err := cluster.reconcileConnection(cluster.Spec); err != nil {
+		return errors.Wrap(err, "failed to reconcile connection secret")
+	}
+	if err := cluster.recordImages(cluster.Spec); err != nil {
+		return errors.Wrap(err, "failed to record images")
+	}
+	return cluster.recordRolledImage()
 }
 
-// preClusterStartValidation Cluster Spec validation
-func preClusterStartValidation(cluster *cluster) error {
-	// Assert the node num is 3
-	nodesNum := len(cluster.Spec.Nodes)
-	if nodesNum < 3 {
-		return errors.Errorf("nodes count shoule at least 3, cannot start cluster %d", len(cluster.Spec.Nodes))
-	} else if nodesNum > 3 {
-		return errors.Errorf("nodes count more than 3, cannot start cluster temporary %d", len(cluster.Spec.Nodes))
+// checkVersionSkew checks the operator version that last reconciled the cluster and the curve version of the cluster,
+// it's skipped if the annotation SkipVersionCheckAnnotation is set to "true"
+func checkVersionSkew(clusterObj *curvev1.CurveCluster) error {
+	if clusterObj.GetAnnotations()[version.SkipVersionCheckAnnotation] == "true" {
+		logger.Warningf("version check of cluster %q is skipped by annotation %q", clusterObj.Name, version.SkipVersionCheckAnnotation)
+		return nil
+	}
+
+	if err := version.CheckOperatorVersion(clusterObj.Status.OperatorVersion); err != nil {
+		return err
+	}
+	if clusterObj.Spec != nil {
+		if err := version.CheckCurveImage(clusterObj.Spec.CurveVersion.Image); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
+// validatePriorityClasses checks the daemons of priority classes are known and the classes exist
+func validatePriorityClasses(clientset kubernetes.Interface, spec *curvev1.CurveClusterSpec) error {
+	if err := validation.ValidatePriorityClasses(spec); err != nil {
+		return k8sutil.NewConfigError(err)
+	}
+	for daemon, name := range spec.PriorityClassNames {
+		if name == "" {
+			continue
+		}
+		_, err := clientset.SchedulingV1().PriorityClasses().Get(name, metav1.GetOptions{})
+		if err != nil {
+			if kerrors.IsNotFound(err) {
+				return k8sutil.NewConfigError(errors.Errorf("priority class %q of %s does not exist", name, daemon))
+			}
+			return errors.Wrapf(err, "failed to get priority class %q", name)
+		}
+	}
 	return nil
 }
 
 func (r *CurveClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	maxConcurrentReconciles := r.MaxConcurrentReconciles
+	if maxConcurrentReconciles == 0 {
+		maxConcurrentReconciles = 1
+	}
+	r.rateLimiter = newClusterRateLimiter(r.ReconcileQPS, r.ReconcileBurst)
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&curvev1.CurveCluster{}).
+		Watches(&source.Kind{Type: &v1.Node{}}, storageNodesHandler(mgr.GetClient())).
+		Watches(&source.Kind{Type: &appsv1.Deployment{}}, childResourcesHandler(r.ClusterController)).
+		Watches(&source.Kind{Type: &appsv1.StatefulSet{}}, childResourcesHandler(r.ClusterController)).
+		Watches(&source.Kind{Type: &v1.ConfigMap{}}, childResourcesHandler(r.ClusterController)).
+		Watches(&source.Kind{Type: &v1.Service{}}, childResourcesHandler(r.ClusterController)).
+		Watches(&source.Kind{Type: &batch.Job{}}, childResourcesHandler(r.ClusterController)).
+		WithOptions(controller.Options{MaxConcurrentReconciles: maxConcurrentReconciles}).
 		Complete(r)
 }
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"reflect"
+
+	"github.com/pkg/errors"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/etcd"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+	"github.com/opencurve/curve-operator/pkg/mds"
+	"github.com/opencurve/curve-operator/pkg/snapshotclone"
+	"github.com/opencurve/curve-operator/pkg/tools"
+)
+
+// endpointDependents are the daemons whose configs embed the endpoints of the daemon,
+// chunkserver generates tools.conf and client.conf used by tools
+var endpointDependents = map[string][]string{
+	etcd.AppName:          {mds.AppName, chunkserver.AppName, snapshotclone.AppName},
+	mds.AppName:           {chunkserver.AppName, snapshotclone.AppName},
+	snapshotclone.AppName: {chunkserver.AppName},
+	chunkserver.AppName:   {tools.AppName},
+}
+
+// daemonStartOrder is the order to start daemons
+var daemonStartOrder = []string{etcd.AppName, mds.AppName, chunkserver.AppName, snapshotclone.AppName, tools.AppName}
+
+// affectedDaemons returns the changed daemons and all daemons depending on them in start order
+func affectedDaemons(changed []string) []string {
+	affected := map[string]bool{}
+	queue := append([]string{}, changed...)
+	for len(queue) > 0 {
+		appName := queue[0]
+		queue = queue[1:]
+		if affected[appName] {
+			continue
+		}
+		affected[appName] = true
+		queue = append(queue, endpointDependents[appName]...)
+	}
+
+	var daemons []string
+	for _, appName := range daemonStartOrder {
+		if affected[appName] {
+			daemons = append(daemons, appName)
+		}
+	}
+	return daemons
+}
+
+// reconcileEndpoints compares the endpoints of the new spec with the recorded ones, and restarts the daemons
+// whose endpoints changed and the daemons depending on them to regenerate their configs
+func (c *cluster) reconcileEndpoints(spec *curvev1.CurveClusterSpec) error {
+	nodeNameIP, err := k8sutil.GetNodeInfoMap(spec, c.context.Clientset)
+	if err != nil {
+		return errors.Wrap(err, "failed get all nodes specified in spec nodes")
+	}
+	info, err := config.GetClusterInfo(&c.context, c.NameSpace)
+	if err != nil {
+		return err
+	}
+
+	var changed []string
+	if _, etcdAddr := etcd.Endpoints(*spec, c.NameSpace, nodeNameIP); etcdAddr != info.EtcdAddr {
+		changed = append(changed, etcd.AppName)
+	}
+	if mds.Endpoints(*spec, c.NameSpace, nodeNameIP) != info.MdsAddr {
+		changed = append(changed, mds.AppName)
+	}
+	if spec.SnapShotClone.Enable && snapshotclone.Endpoints(*spec, c.NameSpace, nodeNameIP) != info.SnapShotCloneAddr {
+		changed = append(changed, snapshotclone.AppName)
+	}
+	// chunkservers are started on the nodes that begin matching the storage node selector
+	if !reflect.DeepEqual(spec.StorageNodes(), c.Spec.StorageNodes()) {
+		changed = append(changed, chunkserver.AppName)
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	daemons := affectedDaemons(changed)
+	logger.Infof("endpoints of %v changed, regenerating configs of %v", changed, daemons)
+	c.Spec = spec
+	for _, appName := range daemons {
+		if err := c.startDaemon(appName, nodeNameIP); err != nil {
+			return errors.Wrapf(err, "failed to regenerate %s", appName)
+		}
+	}
+	return nil
+}
+
+// startDaemon starts the daemon by the current spec, the configmaps and deployments that changed are updated
+func (c *cluster) startDaemon(appName string, nodeNameIP map[string]string) error {
+	switch appName {
+	case etcd.AppName:
+		return etcd.New(c.context, c.NamespacedName, *c.Spec, c.ownerInfo, c.dataDirHostPath, c.logDirHostPath, c.confDirHostPath).Start(nodeNameIP)
+	case mds.AppName:
+		return mds.New(c.context, c.NamespacedName, *c.Spec, c.ownerInfo, c.dataDirHostPath, c.logDirHostPath, c.confDirHostPath).Start(nodeNameIP)
+	case chunkserver.AppName:
+		return chunkserver.New(c.context, c.NamespacedName, *c.Spec, c.ownerInfo, c.dataDirHostPath, c.logDirHostPath, c.confDirHostPath).Start(nodeNameIP)
+	case snapshotclone.AppName:
+		if !c.Spec.SnapShotClone.Enable {
+			return nil
+		}
+		return snapshotclone.New(c.context, c.NamespacedName, *c.Spec, c.ownerInfo, c.dataDirHostPath, c.logDirHostPath, c.confDirHostPath).Start(nodeNameIP)
+	case tools.AppName:
+		if !c.Spec.Tools.Enable {
+			return nil
+		}
+		return tools.New(c.context, c.NamespacedName, *c.Spec, c.ownerInfo).Start()
+	}
+	return errors.Errorf("unknown daemon %q", appName)
+}
@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver"
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// reconcileDiskHealth creates or deletes the disk health checkers of the storage nodes
+func (c *cluster) reconcileDiskHealth(spec *curvev1.CurveClusterSpec) error {
+	chunkservers := chunkserver.New(c.context, c.NamespacedName, *spec, c.ownerInfo, c.dataDirHostPath, c.logDirHostPath, c.confDirHostPath)
+	return chunkservers.ReconcileDiskHealthCheckers()
+}
+
+// DiskHealthReconciler watches the finished disk health check jobs and records their results in the cluster
+// status, the chunkservers of the devices that are predicted to fail are marked PendingReplacement.
+type DiskHealthReconciler struct {
+	Client   client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	context clusterd.Context
+}
+
+func NewDiskHealthReconciler(
+	client client.Client,
+	log logr.Logger,
+	scheme *runtime.Scheme,
+	recorder record.EventRecorder,
+	context clusterd.Context,
+) *DiskHealthReconciler {
+	context.Client = client
+
+	return &DiskHealthReconciler{
+		Client:   client,
+		Log:      log,
+		Scheme:   scheme,
+		Recorder: recorder,
+		context:  context,
+	}
+}
+
+func (r *DiskHealthReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("job", req.NamespacedName)
+
+	job := &batch.Job{}
+	err := r.Client.Get(ctx, req.NamespacedName, job)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			log.Info("job not found, ignoring since it must be deleted")
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, errors.Wrapf(err, "failed to get job %q", req.Name)
+	}
+	if job.Status.Succeeded == 0 && job.Status.Failed == 0 {
+		return reconcile.Result{}, nil
+	}
+
+	clusters := &curvev1.CurveClusterList{}
+	if err := r.Client.List(ctx, clusters, client.InNamespace(job.Namespace)); err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "failed to list curveclusters")
+	}
+	var clusterObj *curvev1.CurveCluster
+	for i := range clusters.Items {
+		if clusters.Items[i].Spec != nil && clusters.Items[i].GetDeletionTimestamp().IsZero() {
+			clusterObj = &clusters.Items[i]
+		}
+	}
+	if clusterObj == nil {
+		return reconcile.Result{}, nil
+	}
+
+	// the node label is shortened for a long node name, the pod runs on the node by its name
+	nodeName := job.Spec.Template.Spec.NodeName
+	if nodeName == "" {
+		nodeName = job.Labels[chunkserver.DiskHealthNodeLabel]
+	}
+	message, err := k8sutil.GetJobTerminationMessage(r.context.Clientset, job)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	results := chunkserver.ParseDiskHealthResults(message)
+	if len(results) == 0 {
+		r.Recorder.Eventf(clusterObj, v1.EventTypeWarning, "DiskHealthCheckFailed", "disk health check job %s on node %s reported nothing", job.Name, nodeName)
+		return reconcile.Result{}, nil
+	}
+
+	checkTime := job.CreationTimestamp
+	if job.Status.CompletionTime != nil {
+		checkTime = *job.Status.CompletionTime
+	}
+
+	changed := false
+	for _, result := range results {
+		if setDiskHealth(clusterObj, nodeName, result, checkTime) {
+			changed = true
+		}
+
+		name := chunkserver.DeploymentName(nodeName, result.Device)
+		state := chunkServerState(clusterObj, name)
+		switch {
+		case result.Health == curvev1.DiskHealthFailing && state != curvev1.ChunkServerStateOffline && state != curvev1.ChunkServerStateReplacing &&
+			state != curvev1.ChunkServerStateRetiring:
+			msg := fmt.Sprintf("device %s on node %s is predicted to fail: %s", result.Device, nodeName, result.Message)
+			if setChunkServersState(clusterObj, nodeName, []string{name}, curvev1.ChunkServerStatePendingReplacement, msg) {
+				logger.Warningf("chunkserver %s of cluster %q is pending replacement because %s", name, clusterObj.Name, msg)
+				r.Recorder.Eventf(clusterObj, v1.EventTypeWarning, "DiskFailurePredicted", "chunkserver %s is pending replacement because %s", name, msg)
+				changed = true
+			}
+		case result.Health == curvev1.DiskHealthPassed && state == curvev1.ChunkServerStatePendingReplacement:
+			removeChunkServer(clusterObj, name)
+			r.Recorder.Eventf(clusterObj, v1.EventTypeNormal, "DiskHealthy", "device %s on node %s is healthy again", result.Device, nodeName)
+			changed = true
+		}
+	}
+	if !changed {
+		return reconcile.Result{}, nil
+	}
+
+	namespacedName := types.NamespacedName{Namespace: clusterObj.Namespace, Name: clusterObj.Name}
+	return reconcile.Result{}, k8sutil.UpdateStatus(r.Client, namespacedName, clusterObj)
+}
+
+// setDiskHealth records the result of the device and returns true if it changed
+func setDiskHealth(clusterObj *curvev1.CurveCluster, nodeName string, result chunkserver.DiskHealthResult, checkTime metav1.Time) bool {
+	status := curvev1.DiskHealthStatus{
+		NodeName:      nodeName,
+		Device:        result.Device,
+		Health:        result.Health,
+		LastCheckTime: checkTime,
+		Message:       result.Message,
+	}
+	for i := range clusterObj.Status.DiskHealth {
+		existing := &clusterObj.Status.DiskHealth[i]
+		if existing.NodeName != nodeName || existing.Device != result.Device {
+			continue
+		}
+		if existing.Health == status.Health && existing.Message == status.Message && existing.LastCheckTime.Equal(&checkTime) {
+			return false
+		}
+		*existing = status
+		return true
+	}
+	clusterObj.Status.DiskHealth = append(clusterObj.Status.DiskHealth, status)
+	return true
+}
+
+// chunkServerState returns the state of the chunkserver, it's empty if the chunkserver is healthy
+func chunkServerState(clusterObj *curvev1.CurveCluster, name string) curvev1.ChunkServerState {
+	for _, cs := range clusterObj.Status.ChunkServers {
+		if cs.Name == name {
+			return cs.State
+		}
+	}
+	return ""
+}
+
+// removeChunkServer removes the status of the chunkserver
+func removeChunkServer(clusterObj *curvev1.CurveCluster, name string) {
+	var chunkServers []curvev1.ChunkServerStatus
+	for _, cs := range clusterObj.Status.ChunkServers {
+		if cs.Name != name {
+			chunkServers = append(chunkServers, cs)
+		}
+	}
+	clusterObj.Status.ChunkServers = chunkServers
+}
+
+// isDiskHealthJob returns true if the object is a disk health check job
+func isDiskHealthJob(meta metav1.Object) bool {
+	return meta.GetLabels()["app"] == chunkserver.DiskHealthAppName
+}
+
+func (r *DiskHealthReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&batch.Job{}).
+		WithEventFilter(predicate.Funcs{
+			CreateFunc:  func(e event.CreateEvent) bool { return isDiskHealthJob(e.Meta) },
+			UpdateFunc:  func(e event.UpdateEvent) bool { return isDiskHealthJob(e.MetaNew) },
+			DeleteFunc:  func(e event.DeleteEvent) bool { return false },
+			GenericFunc: func(e event.GenericEvent) bool { return isDiskHealthJob(e.Meta) },
+		}).
+		Complete(r)
+}
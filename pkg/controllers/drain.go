@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver"
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// DrainReconciler protects the chunkservers from the evictions of kubectl drain if storage.drainProtection is
+// enabled. The chunkservers on a schedulable node are guarded by the budgets that allow no eviction, and the
+// budgets are deleted after the leaders of the copysets are transferred away when the node is cordoned.
+type DrainReconciler struct {
+	Client   client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	context clusterd.Context
+}
+
+func NewDrainReconciler(
+	client client.Client,
+	log logr.Logger,
+	scheme *runtime.Scheme,
+	recorder record.EventRecorder,
+	context clusterd.Context,
+) *DrainReconciler {
+	context.Client = client
+
+	return &DrainReconciler{
+		Client:   client,
+		Log:      log,
+		Scheme:   scheme,
+		Recorder: recorder,
+		context:  context,
+	}
+}
+
+// +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch
+// +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;delete
+
+func (r *DrainReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("node", req.Name)
+
+	node := &v1.Node{}
+	err := r.Client.Get(ctx, req.NamespacedName, node)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			log.Info("node not found, ignoring since it must be deleted")
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, errors.Wrapf(err, "failed to get node %q", req.Name)
+	}
+
+	clusters := &curvev1.CurveClusterList{}
+	if err := r.Client.List(ctx, clusters); err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "failed to list curveclusters")
+	}
+	for i := range clusters.Items {
+		clusterObj := &clusters.Items[i]
+		if clusterObj.Spec == nil || !clusterObj.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		if err := r.reconcileChunkServersOnNode(clusterObj, node); err != nil {
+			return reconcile.Result{}, errors.Wrapf(err, "failed to reconcile drain protection of cluster %q on node %q", clusterObj.Name, node.Name)
+		}
+	}
+	return reconcile.Result{}, nil
+}
+
+// reconcileChunkServersOnNode guards the chunkservers of the cluster on the schedulable node, or releases them
+// after their leaders are transferred if the node is cordoned
+func (r *DrainReconciler) reconcileChunkServersOnNode(clusterObj *curvev1.CurveCluster, node *v1.Node) error {
+	deployments := &appsv1.DeploymentList{}
+	if err := r.Client.List(context.TODO(), deployments, client.InNamespace(clusterObj.Namespace),
+		client.MatchingLabels{"app": chunkserver.AppName}); err != nil {
+		return errors.Wrap(err, "failed to list chunkserver deployments")
+	}
+	var onNode []appsv1.Deployment
+	for _, d := range deployments.Items {
+		if d.Spec.Template.Spec.NodeName == node.Name {
+			onNode = append(onNode, d)
+		}
+	}
+	if len(onNode) == 0 {
+		return nil
+	}
+
+	clientset := r.context.Clientset
+	if !clusterObj.Spec.Storage.DrainProtection.Enable {
+		return chunkserver.ReleaseDrainProtection(clientset, onNode)
+	}
+	if !node.Spec.Unschedulable {
+		return chunkserver.ProtectFromDrain(clientset, onNode)
+	}
+
+	// the node is cordoned, the leaders are transferred once before the budgets are deleted
+	protected, err := chunkserver.DrainProtected(clientset, onNode)
+	if err != nil || !protected {
+		return err
+	}
+	ownerInfo := k8sutil.NewOwnerInfo(clusterObj, r.Scheme)
+	namespacedName := types.NamespacedName{Namespace: clusterObj.Namespace, Name: clusterObj.Name}
+	dataDir, logDir, confDir := config.HostDaemonDirs(clusterObj.Spec)
+	chunkservers := chunkserver.New(r.context, namespacedName, *clusterObj.Spec, ownerInfo, dataDir, logDir, confDir)
+	// it's best effort like the graceful restart, the drain is not blocked by a failed transfer
+	if err := chunkservers.TransferLeadersOnNode(node.Name, onNode); err != nil {
+		logger.Warningf("failed to transfer leaders of chunkservers on cordoned node %s, allowing the evictions anyway. %v", node.Name, err)
+	}
+	if err := chunkserver.ReleaseDrainProtection(clientset, onNode); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(onNode))
+	for _, d := range onNode {
+		names = append(names, d.Name)
+	}
+	logger.Infof("chunkservers %v of cluster %q on cordoned node %s can be evicted", names, clusterObj.Name, node.Name)
+	r.Recorder.Eventf(clusterObj, v1.EventTypeNormal, "ChunkServerDrainable", "chunkservers %v on cordoned node %s can be evicted", names, node.Name)
+	return nil
+}
+
+// nodesOfChunkServers enqueues the nodes of the chunkserver deployments, or the nodes of all chunkservers of the
+// cluster when it's changed
+func (r *DrainReconciler) nodesOfChunkServers(obj handler.MapObject) []reconcile.Request {
+	var deployments []appsv1.Deployment
+	switch o := obj.Object.(type) {
+	case *appsv1.Deployment:
+		if o.Labels["app"] != chunkserver.AppName {
+			return nil
+		}
+		deployments = []appsv1.Deployment{*o}
+	case *curvev1.CurveCluster:
+		list := &appsv1.DeploymentList{}
+		if err := r.Client.List(context.TODO(), list, client.InNamespace(o.Namespace),
+			client.MatchingLabels{"app": chunkserver.AppName}); err != nil {
+			logger.Warningf("failed to list chunkserver deployments of cluster %q. %v", fmt.Sprintf("%s/%s", o.Namespace, o.Name), err)
+			return nil
+		}
+		deployments = list.Items
+	}
+
+	seen := map[string]bool{}
+	var requests []reconcile.Request
+	for _, d := range deployments {
+		nodeName := d.Spec.Template.Spec.NodeName
+		if nodeName != "" && !seen[nodeName] {
+			seen[nodeName] = true
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: nodeName}})
+		}
+	}
+	return requests
+}
+
+func (r *DrainReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	toNodes := &handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.nodesOfChunkServers)}
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("drain").
+		For(&v1.Node{}).
+		Watches(&source.Kind{Type: &appsv1.Deployment{}}, toNodes).
+		Watches(&source.Kind{Type: &curvev1.CurveCluster{}}, toNodes).
+		WithEventFilter(predicate.Funcs{
+			// only the cordon of the nodes, the creation of the chunkservers and the change of the spec are interested
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				switch o := e.ObjectNew.(type) {
+				case *v1.Node:
+					old, ok := e.ObjectOld.(*v1.Node)
+					return ok && old.Spec.Unschedulable != o.Spec.Unschedulable
+				case *appsv1.Deployment:
+					old, ok := e.ObjectOld.(*appsv1.Deployment)
+					return ok && old.Spec.Template.Spec.NodeName != o.Spec.Template.Spec.NodeName
+				}
+				return e.MetaOld.GetGeneration() != e.MetaNew.GetGeneration()
+			},
+			DeleteFunc: func(e event.DeleteEvent) bool { return false },
+		}).
+		Complete(r)
+}
@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/etcd"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+	"github.com/opencurve/curve-operator/pkg/mds"
+	"github.com/opencurve/curve-operator/pkg/snapshotclone"
+)
+
+const (
+	// ConfigDumpConfigMapName is the configmap that the config of the daemons is dumped to
+	ConfigDumpConfigMapName = "curve-config-dump"
+
+	// maxConfigDumpSize keeps the dump under the size limit of configmap, the desired config that doesn't fit
+	// is omitted
+	maxConfigDumpSize = 512 * 1024
+)
+
+// configFile is a config file mounted from a configmap in the container
+type configFile struct {
+	path      string
+	configMap string
+	key       string
+}
+
+// reconcileConfigDump writes the desired config of each daemon, which is the data of the configmaps rendered by
+// the operator, and its diff against the config files in the running pods into a configmap if the dump-config
+// annotation is set to a new value.
+func (r *CurveClusterReconciler) reconcileConfigDump(clusterObj *curvev1.CurveCluster, ownerInfo *k8sutil.OwnerInfo) error {
+	request := clusterObj.Annotations[curvev1.DumpConfigAnnotation]
+	if request == "" {
+		return nil
+	}
+	namespacedName := types.NamespacedName{Namespace: clusterObj.Namespace, Name: clusterObj.Name}
+	latest := &curvev1.CurveCluster{}
+	if err := r.Client.Get(context.TODO(), namespacedName, latest); err != nil {
+		return errors.Wrapf(err, "failed to get cluster %v", namespacedName)
+	}
+	if latest.Status.ConfigDump != nil && latest.Status.ConfigDump.Request == request {
+		return nil
+	}
+
+	clusterContext := r.ClusterController.context
+	pods, err := clusterContext.Clientset.CoreV1().Pods(clusterObj.Namespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app in (%s,%s,%s,%s),curve_cluster=%s",
+			etcd.AppName, mds.AppName, chunkserver.AppName, snapshotclone.AppName, clusterObj.Namespace),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to list the pods of daemons")
+	}
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[i].Name < pods.Items[j].Name
+	})
+
+	configMaps := map[string]*v1.ConfigMap{}
+	data := map[string]string{}
+	size := 0
+	var summary []string
+	var differences []string
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		// the jobs of chunkserver have the same app label
+		owner := metav1.GetControllerOf(pod)
+		if pod.Status.Phase != v1.PodRunning || owner == nil || (owner.Kind != "ReplicaSet" && owner.Kind != "StatefulSet") {
+			continue
+		}
+
+		// the config files mounted as a directory are updated in the running pods, the hash of the configmaps on
+		// the pod tells whether the daemon was started with the desired config
+		if hash, ok := pod.Annotations[k8sutil.ConfigHashAnnotation]; ok {
+			desiredHash, err := k8sutil.ConfigHash(clusterContext.Clientset, pod.Namespace, &pod.Spec)
+			if err != nil {
+				return err
+			}
+			if hash != desiredHash {
+				summary = append(summary, fmt.Sprintf("%s: started with an older config, the desired config is applied when it restarts", pod.Name))
+				differences = append(differences, pod.Name)
+			}
+		}
+
+		var diff strings.Builder
+		for _, f := range podConfigFiles(pod) {
+			cm, ok := configMaps[f.configMap]
+			if !ok {
+				cm, err = clusterContext.Clientset.CoreV1().ConfigMaps(clusterObj.Namespace).Get(f.configMap, metav1.GetOptions{})
+				if err != nil && !kerrors.IsNotFound(err) {
+					return errors.Wrapf(err, "failed to get configmap %q", f.configMap)
+				}
+				if err != nil {
+					cm = nil
+				}
+				configMaps[f.configMap] = cm
+			}
+			if cm == nil {
+				summary = append(summary, fmt.Sprintf("%s: %s: configmap %s is not found", pod.Name, f.path, f.configMap))
+				continue
+			}
+
+			desired := cm.Data[f.key]
+			dumpKey := f.configMap + "." + f.key
+			if _, ok := data[dumpKey]; !ok {
+				if size+len(desired) > maxConfigDumpSize {
+					summary = append(summary, fmt.Sprintf("%s: omitted as the dump is too large", dumpKey))
+					data[dumpKey] = ""
+				} else {
+					data[dumpKey] = desired
+					size += len(desired)
+				}
+			}
+
+			running, err := k8sutil.ExecInPod(&clusterContext, pod, []string{"cat", f.path})
+			if err != nil {
+				summary = append(summary, fmt.Sprintf("%s: %s: failed to read, %v", pod.Name, f.path, err))
+				continue
+			}
+			lines := config.Diff(running, desired)
+			if lines == "" {
+				summary = append(summary, fmt.Sprintf("%s: %s: in sync with %s", pod.Name, f.path, dumpKey))
+				continue
+			}
+			summary = append(summary, fmt.Sprintf("%s: %s: differs from %s", pod.Name, f.path, dumpKey))
+			differences = append(differences, pod.Name+":"+f.path)
+			diff.WriteString(fmt.Sprintf("--- %s (running)\n+++ %s (desired)\n%s", f.path, dumpKey, lines))
+		}
+		if diff.Len() > 0 {
+			data[pod.Name+".diff"] = diff.String()
+		}
+	}
+	data["summary"] = strings.Join(summary, "\n") + "\n"
+
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        ConfigDumpConfigMapName,
+			Namespace:   clusterObj.Namespace,
+			Annotations: map[string]string{curvev1.DumpConfigAnnotation: request},
+		},
+		Data: data,
+	}
+	k8sutil.InjectMetadata(*clusterObj.Spec, "", cm)
+	if err := ownerInfo.SetControllerReference(cm); err != nil {
+		return errors.Wrapf(err, "failed to set owner reference to configmap %q", cm.Name)
+	}
+	if err := k8sutil.Apply(clusterContext.Client, cm); err != nil {
+		return errors.Wrapf(err, "failed to write configmap %q", cm.Name)
+	}
+	logger.Infof("config of cluster %q is dumped to configmap %q with %d differences", clusterObj.Name, cm.Name, len(differences))
+
+	latest.Status.ConfigDump = &curvev1.ConfigDumpStatus{
+		Request:     request,
+		ConfigMap:   cm.Name,
+		DumpedTime:  metav1.Now(),
+		Differences: differences,
+	}
+	return k8sutil.UpdateStatus(r.Client, namespacedName, latest)
+}
+
+// podConfigFiles returns the config files mounted from configmaps in the first container of the pod
+func podConfigFiles(pod *v1.Pod) []configFile {
+	sources := map[string]*v1.ConfigMapVolumeSource{}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.ConfigMap != nil {
+			sources[volume.Name] = volume.ConfigMap
+		}
+	}
+
+	var files []configFile
+	for _, mount := range pod.Spec.Containers[0].VolumeMounts {
+		source, ok := sources[mount.Name]
+		if !ok {
+			continue
+		}
+		// the items map the keys of configmap to the paths in volume, all keys are mounted by their names if
+		// there is no item, which are unknown until the configmap is read, so only items are dumped
+		for _, item := range source.Items {
+			switch {
+			case mount.SubPath == item.Path:
+				files = append(files, configFile{path: mount.MountPath, configMap: source.Name, key: item.Key})
+			case mount.SubPath == "":
+				files = append(files, configFile{path: path.Join(mount.MountPath, item.Path), configMap: source.Name, key: item.Key})
+			}
+		}
+	}
+	return files
+}
@@ -0,0 +1,12 @@
+package controllers
+
+import (
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/etcd"
+)
+
+// reconcileEtcdBackup creates or deletes the cron job that saves the snapshots of etcd
+func (c *cluster) reconcileEtcdBackup(spec *curvev1.CurveClusterSpec) error {
+	etcds := etcd.New(c.context, c.NamespacedName, *spec, c.ownerInfo, c.dataDirHostPath, c.logDirHostPath, c.confDirHostPath)
+	return etcds.ReconcileBackup()
+}
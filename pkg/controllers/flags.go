@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+	"github.com/opencurve/curve-operator/pkg/mds"
+)
+
+// flagsRestartedAtAnnotation is set on the pod template of mds to restart it for the flags that are not reloadable
+const flagsRestartedAtAnnotation = "operator.curve.io/flags-restarted-at"
+
+// updateMdsFlags applies the changed mds.flags. The flagfile is updated for the mds to read when it restarts, and
+// the changed flags are set on the running mds if they are reloadable, or mds is restarted one by one. The applied
+// values are recorded in status.mdsFlags.
+func (c *cluster) updateMdsFlags(spec *curvev1.CurveClusterSpec) error {
+	if reflect.DeepEqual(spec.Mds.Flags, c.Spec.Mds.Flags) {
+		return nil
+	}
+	logger.Infof("flags of mds changed from %v to %v", c.Spec.Mds.Flags, spec.Mds.Flags)
+
+	newSpec := c.Spec.DeepCopy()
+	newSpec.Mds.Flags = spec.Mds.Flags
+	if err := mds.New(c.context, c.NamespacedName, *newSpec, c.ownerInfo, c.dataDirHostPath, c.logDirHostPath, c.confDirHostPath).UpdateFlags(); err != nil {
+		return err
+	}
+
+	// the removed flags can't be reset to their defaults without restarting
+	restart := false
+	for name := range c.Spec.Mds.Flags {
+		if _, ok := spec.Mds.Flags[name]; !ok {
+			restart = true
+		}
+	}
+	reloaded := map[string]bool{}
+	for name, value := range spec.Mds.Flags {
+		if old, ok := c.Spec.Mds.Flags[name]; restart || (ok && old == value) {
+			continue
+		}
+		ok, err := mds.SetFlag(c.context.Clientset, c.NameSpace, c.Spec.Mds.DummyPort, name, value)
+		if err != nil {
+			return errors.Wrapf(err, "failed to set flag %q of mds", name)
+		}
+		if !ok {
+			restart = true
+			continue
+		}
+		logger.Infof("flag %q of mds is set to %q", name, value)
+		reloaded[name] = true
+	}
+
+	if restart {
+		// the hot reloaded flags are set again with the others when mds is restarted in the window
+		if !disruptionAllowed(spec, "restart of mds for the flags that are not reloadable") {
+			return nil
+		}
+		logger.Info("restarting mds to apply the flags that are not reloadable")
+		restartedAt := time.Now().Format(time.RFC3339)
+		err := c.rollDaemon(mds.AppName, func(d *appsv1.Deployment) error {
+			// the deployments created before the flagfile was introduced mount it from now on
+			mds.SetFlagFile(&d.Spec.Template)
+			if d.Spec.Template.Annotations == nil {
+				d.Spec.Template.Annotations = map[string]string{}
+			}
+			d.Spec.Template.Annotations[flagsRestartedAtAnnotation] = restartedAt
+			return nil
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to restart mds")
+		}
+		// all the flags are applied by the restart
+		reloaded = map[string]bool{}
+	}
+	c.Spec.Mds.Flags = spec.Mds.Flags
+
+	return c.recordMdsFlags(reloaded, restart)
+}
+
+// recordMdsFlags records the flags of mds in status, the flags set on the running mds are marked hot reloaded.
+// The time of the unchanged flags is kept unless mds was restarted.
+func (c *cluster) recordMdsFlags(reloaded map[string]bool, restarted bool) error {
+	clusterObj := &curvev1.CurveCluster{}
+	if err := c.context.Client.Get(context.TODO(), c.NamespacedName, clusterObj); err != nil {
+		return errors.Wrapf(err, "failed to get cluster %v to record the flags of mds", c.NamespacedName)
+	}
+	applied := map[string]curvev1.MdsFlagStatus{}
+	for _, flag := range clusterObj.Status.MdsFlags {
+		applied[flag.Name] = flag
+	}
+
+	now := metav1.Now()
+	var flags []curvev1.MdsFlagStatus
+	for name, value := range c.Spec.Mds.Flags {
+		flag, ok := applied[name]
+		if !ok || flag.Value != value || restarted {
+			flag = curvev1.MdsFlagStatus{Name: name, Value: value, HotReloaded: reloaded[name], AppliedTime: now}
+		}
+		flags = append(flags, flag)
+	}
+	sort.Slice(flags, func(i, j int) bool {
+		return flags[i].Name < flags[j].Name
+	})
+
+	clusterObj.Status.MdsFlags = flags
+	return k8sutil.UpdateStatus(c.context.Client, c.NamespacedName, clusterObj)
+}
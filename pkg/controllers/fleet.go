@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+// CurveFleetReconciler summarizes the health, capacity and version of the CurveClusters in the status of the
+// cluster-scoped CurveFleet, all the fleets are updated when a cluster changes
+type CurveFleetReconciler struct {
+	Client client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+func NewCurveFleetReconciler(
+	client client.Client,
+	log logr.Logger,
+	scheme *runtime.Scheme,
+) *CurveFleetReconciler {
+	return &CurveFleetReconciler{
+		Client: client,
+		Log:    log,
+		Scheme: scheme,
+	}
+}
+
+// +kubebuilder:rbac:groups=operator.curve.io,resources=curvefleets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=operator.curve.io,resources=curvefleets/status,verbs=get;update;patch
+
+func (r *CurveFleetReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+
+	fleet := &curvev1.CurveFleet{}
+	err := r.Client.Get(ctx, req.NamespacedName, fleet)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, errors.Wrapf(err, "failed to get curvefleet %q", req.Name)
+	}
+
+	clusters := &curvev1.CurveClusterList{}
+	if err := r.Client.List(ctx, clusters); err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "failed to list curveclusters")
+	}
+	status := summarizeFleet(fleet.Spec, clusters.Items)
+	status.LastUpdateTime = fleet.Status.LastUpdateTime
+	if reflect.DeepEqual(fleet.Status, status) {
+		return reconcile.Result{}, nil
+	}
+	status.LastUpdateTime = metav1.Now()
+	fleet.Status = status
+	if err := r.Client.Status().Update(ctx, fleet); err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to update status of curvefleet %q", fleet.Name)
+	}
+	return reconcile.Result{}, nil
+}
+
+// summarizeFleet returns the status of the fleet of the clusters in the namespaces of spec, the clusters being
+// deleted are not counted
+func summarizeFleet(spec curvev1.CurveFleetSpec, clusters []curvev1.CurveCluster) curvev1.CurveFleetStatus {
+	namespaces := map[string]bool{}
+	for _, namespace := range spec.Namespaces {
+		namespaces[namespace] = true
+	}
+
+	status := curvev1.CurveFleetStatus{}
+	images := map[string]bool{}
+	for _, clusterObj := range clusters {
+		if len(namespaces) > 0 && !namespaces[clusterObj.Namespace] {
+			continue
+		}
+		if !clusterObj.GetDeletionTimestamp().IsZero() || clusterObj.Spec == nil {
+			continue
+		}
+		summary := curvev1.FleetClusterStatus{
+			Namespace:       clusterObj.Namespace,
+			Name:            clusterObj.Name,
+			Phase:           clusterObj.Status.Phase,
+			Message:         clusterObj.Status.Message,
+			Image:           clusterObj.Spec.CurveVersion.Image,
+			OperatorVersion: clusterObj.Status.OperatorVersion,
+		}
+		if capacity := clusterObj.Status.Capacity; capacity != nil {
+			summary.TotalBytes = capacity.TotalBytes
+			summary.UsedBytes = capacity.UsedBytes
+			summary.UsedPercent = capacity.UsedPercent
+		}
+		if copysets := clusterObj.Status.Copysets; copysets != nil {
+			summary.UnhealthyCopysets = copysets.Unhealthy
+		}
+
+		status.Clusters++
+		if summary.Phase == curvev1.ClusterPhaseReady {
+			status.ReadyClusters++
+		}
+		status.TotalBytes += summary.TotalBytes
+		status.UsedBytes += summary.UsedBytes
+		status.UnhealthyCopysets += summary.UnhealthyCopysets
+		if summary.Image != "" && !images[summary.Image] {
+			images[summary.Image] = true
+			status.Images = append(status.Images, summary.Image)
+		}
+		status.ClusterSummaries = append(status.ClusterSummaries, summary)
+	}
+
+	sort.Strings(status.Images)
+	sort.Slice(status.ClusterSummaries, func(i, j int) bool {
+		a, b := status.ClusterSummaries[i], status.ClusterSummaries[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return status
+}
+
+// fleetsOfCluster enqueues all the fleets when a cluster changes, there are only a few of them
+func (r *CurveFleetReconciler) fleetsOfCluster(handler.MapObject) []reconcile.Request {
+	fleets := &curvev1.CurveFleetList{}
+	if err := r.Client.List(context.TODO(), fleets); err != nil {
+		r.Log.Error(err, "failed to list curvefleets")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, fleet := range fleets.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: fleet.Name}})
+	}
+	return requests
+}
+
+func (r *CurveFleetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&curvev1.CurveFleet{}).
+		Watches(&source.Kind{Type: &curvev1.CurveCluster{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.fleetsOfCluster),
+		}).
+		Complete(r)
+}
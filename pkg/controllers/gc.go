@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver"
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+	"github.com/opencurve/curve-operator/pkg/snapshotclone"
+)
+
+// gcInterval is the interval to sweep the resources that are not wanted by the cluster
+const gcInterval = 10 * time.Minute
+
+// GarbageCollectorReconciler sweeps the resources labeled for a cluster that are not wanted by its spec any more
+// periodically, such as the deployments and configmaps of the chunkservers of the devices that are removed but
+// not formatted, the finished prepare jobs of them and the snapshotclone resources after snapshotclone is
+// disabled or of the servers beyond its replicas. The chunkservers of the removed devices that are formatted are
+// retired by shrink instead.
+type GarbageCollectorReconciler struct {
+	Client client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+
+	context clusterd.Context
+}
+
+func NewGarbageCollectorReconciler(
+	client client.Client,
+	log logr.Logger,
+	scheme *runtime.Scheme,
+	context clusterd.Context,
+) *GarbageCollectorReconciler {
+	context.Client = client
+
+	return &GarbageCollectorReconciler{
+		Client:  client,
+		Log:     log,
+		Scheme:  scheme,
+		context: context,
+	}
+}
+
+func (r *GarbageCollectorReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("curvecluster", req.NamespacedName)
+
+	clusterObj := &curvev1.CurveCluster{}
+	err := r.Client.Get(ctx, req.NamespacedName, clusterObj)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, errors.Wrapf(err, "failed to get curvecluster %q", req.NamespacedName)
+	}
+	if clusterObj.Spec == nil || !clusterObj.GetDeletionTimestamp().IsZero() {
+		return reconcile.Result{}, nil
+	}
+	// the resources are not swept while the cluster is being created or changed
+	if clusterObj.Annotations[curvev1.PauseReconcileAnnotation] == "true" || clusterObj.Status.Phase != curvev1.ConditionTypeClusterReady {
+		return reconcile.Result{RequeueAfter: gcInterval}, nil
+	}
+
+	// the storage nodes resolved by node selector are not written back to the object
+	spec := clusterObj.Spec.DeepCopy()
+	if err := resolveStorageNodes(r.context.Clientset, clusterObj.Namespace, spec); err != nil {
+		return reconcile.Result{}, err
+	}
+	dataDir, logDir, confDir := config.HostDaemonDirs(spec)
+	chunkservers := chunkserver.New(r.context, req.NamespacedName, *spec, k8sutil.NewOwnerInfo(clusterObj, r.Scheme), dataDir, logDir, confDir)
+	wanted, err := chunkservers.WantedResources()
+	if err != nil {
+		log.Info("failed to get the wanted chunkserver resources, skip sweeping", "error", err.Error())
+		return reconcile.Result{RequeueAfter: gcInterval}, nil
+	}
+
+	if err := r.sweep(clusterObj, spec, wanted); err != nil {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{RequeueAfter: gcInterval}, nil
+}
+
+// sweep deletes the deployments, configmaps and jobs controlled by the cluster that are not wanted
+func (r *GarbageCollectorReconciler) sweep(clusterObj *curvev1.CurveCluster, spec *curvev1.CurveClusterSpec, wanted map[string]bool) error {
+	namespace := clusterObj.Namespace
+	clientset := r.context.Clientset
+	options := metav1.ListOptions{LabelSelector: k8sutil.ClusterLabel + "=" + namespace}
+	propagation := metav1.DeletePropagationBackground
+	deleteOptions := &metav1.DeleteOptions{PropagationPolicy: &propagation}
+
+	deployments, err := clientset.AppsV1().Deployments(namespace).List(options)
+	if err != nil {
+		return errors.Wrap(err, "failed to list deployments")
+	}
+	for i := range deployments.Items {
+		d := &deployments.Items[i]
+		if !metav1.IsControlledBy(d, clusterObj) || !orphanDeployment(spec, wanted, d) {
+			continue
+		}
+		logger.Infof("deleting deployment %q that is not wanted by cluster %q", d.Name, clusterObj.Name)
+		k8sutil.ExpectDelete("Deployment", namespace, d.Name)
+		if err := clientset.AppsV1().Deployments(namespace).Delete(d.Name, deleteOptions); err != nil && !kerrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete deployment %q", d.Name)
+		}
+	}
+
+	configMaps, err := clientset.CoreV1().ConfigMaps(namespace).List(options)
+	if err != nil {
+		return errors.Wrap(err, "failed to list configmaps")
+	}
+	for i := range configMaps.Items {
+		cm := &configMaps.Items[i]
+		if !metav1.IsControlledBy(cm, clusterObj) || !orphanConfigMap(spec, wanted, cm) {
+			continue
+		}
+		logger.Infof("deleting configmap %q that is not wanted by cluster %q", cm.Name, clusterObj.Name)
+		k8sutil.ExpectDelete("ConfigMap", namespace, cm.Name)
+		if err := clientset.CoreV1().ConfigMaps(namespace).Delete(cm.Name, deleteOptions); err != nil && !kerrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete configmap %q", cm.Name)
+		}
+	}
+
+	jobs, err := clientset.BatchV1().Jobs(namespace).List(options)
+	if err != nil {
+		return errors.Wrap(err, "failed to list jobs")
+	}
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		// a running job is left to finish
+		if !metav1.IsControlledBy(job, clusterObj) || job.Status.Active > 0 ||
+			job.Labels["app"] != chunkserver.PrepareJobName || wanted[job.Name] {
+			continue
+		}
+		logger.Infof("deleting job %q that is not wanted by cluster %q", job.Name, clusterObj.Name)
+		k8sutil.ExpectDelete("Job", namespace, job.Name)
+		if err := clientset.BatchV1().Jobs(namespace).Delete(job.Name, deleteOptions); err != nil && !kerrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete job %q", job.Name)
+		}
+	}
+	return nil
+}
+
+// orphanDeployment returns true if the deployment of chunkserver or snapshotclone is not wanted by the cluster
+func orphanDeployment(spec *curvev1.CurveClusterSpec, wanted map[string]bool, d *appsv1.Deployment) bool {
+	switch d.Labels["app"] {
+	case chunkserver.AppName:
+		return !wanted[d.Name]
+	case snapshotclone.AppName:
+		return !snapshotclone.WantedResource(spec, d.Name)
+	}
+	return false
+}
+
+// orphanConfigMap returns true if the configmap of a chunkserver or snapshotclone is not wanted by the cluster,
+// they are recognized by name as they have no app label
+func orphanConfigMap(spec *curvev1.CurveClusterSpec, wanted map[string]bool, cm *v1.ConfigMap) bool {
+	switch {
+	case strings.HasPrefix(cm.Name, chunkserver.ConfigMapNamePrefix+"-"):
+		return !wanted[cm.Name]
+	case strings.HasPrefix(cm.Name, snapshotclone.ConfigMapNamePrefix+"-"):
+		return !snapshotclone.WantedResource(spec, cm.Name)
+	}
+	return false
+}
+
+func (r *GarbageCollectorReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&curvev1.CurveCluster{}).
+		Named("gc").
+		Complete(r)
+}
@@ -0,0 +1,298 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	batch "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+const (
+	HostPruneAppName = "curve-host-prune"
+	// hostPruneNodeLabel is the label of the node that the prune job runs on
+	hostPruneNodeLabel = "node"
+
+	hostPruneCronJobNameFormat = "curve-host-prune-%s"
+	hostPruneSchedule          = "30 3 * * *"
+	hostPruneVolumeName        = "host-data-volume"
+)
+
+// hostPruneScript removes the directories under the log directory $1 that are not kept by the comma separated
+// names $4 and have not been written in $3 days. If the node is removed from the cluster, which is told by $5,
+// the conf directory $2 is removed too after all the log directories are gone. The number of the directories
+// left is reported.
+const hostPruneScript = `
+logs=$1 conf=$2 days=$3 keep=",$4," removed=$5
+remaining=0
+for dir in "$logs"/*/; do
+  [ -d "$dir" ] || continue
+  name=$(basename "$dir")
+  if [[ "$keep" == *",$name,"* ]]; then
+    remaining=$((remaining + 1))
+  elif [ -n "$(find "$dir" -mtime -$days -print -quit)" ]; then
+    echo "keeping $dir that was written in $days days"
+    remaining=$((remaining + 1))
+  else
+    echo "pruning $dir"
+    rm -rf "$dir"
+  fi
+done
+if [ "$removed" == "true" ] && [ $remaining -eq 0 ] && [ -d "$conf" ]; then
+  if [ -n "$(find "$conf" -mtime -$days -print -quit)" ]; then
+    remaining=1
+  else
+    echo "pruning $conf"
+    rm -rf "$conf"
+  fi
+fi
+echo "remaining $remaining" | tee /dev/termination-log
+`
+
+// reconcileHostPrune records the nodes that have the directories of the cluster in its status, and creates a
+// cron job on each of them to prune the log directories of the removed daemons by spec.cleanup. A node removed
+// from the cluster is forgotten after all its directories are pruned.
+func (c *cluster) reconcileHostPrune(spec *curvev1.CurveClusterSpec) error {
+	clusterObj := &curvev1.CurveCluster{}
+	if err := c.context.Client.Get(context.TODO(), c.NamespacedName, clusterObj); err != nil {
+		return errors.Wrapf(err, "failed to get curvecluster %q", c.NamespacedName)
+	}
+
+	resolved, err := k8sutil.ResolveNodeNames(c.context.Clientset, k8sutil.MergeNodeNames(spec.DaemonNodes(), spec.StorageNodes()))
+	if err != nil {
+		return err
+	}
+	current := map[string]bool{}
+	for _, nodeName := range resolved {
+		current[nodeName] = true
+	}
+	nodes := sets.NewString(clusterObj.Status.HostDirNodes...)
+	for nodeName := range current {
+		nodes.Insert(nodeName)
+	}
+
+	kept, err := c.daemonLogDirs(resolved)
+	if err != nil {
+		return err
+	}
+	wanted := map[string]bool{}
+	retainDays := spec.Cleanup.RetainLogsDays
+	for _, nodeName := range nodes.List() {
+		if retainDays == 0 {
+			continue
+		}
+		if !current[nodeName] {
+			remaining, err := c.hostDirsRemaining(nodeName)
+			if err != nil {
+				return err
+			}
+			if remaining == 0 {
+				logger.Infof("directories of cluster %q on removed node %s have been pruned", c.NamespacedName, nodeName)
+				nodes.Delete(nodeName)
+				continue
+			}
+		}
+		cronJob, err := c.makeHostPruneCronJob(spec, nodeName, kept[nodeName], !current[nodeName])
+		if err != nil {
+			return err
+		}
+		if err := k8sutil.Apply(c.context.Client, cronJob); err != nil {
+			return errors.Wrapf(err, "failed to apply host prune cron job %s", cronJob.Name)
+		}
+		wanted[cronJob.Name] = true
+	}
+
+	cronJobs, err := c.context.Clientset.BatchV1beta1().CronJobs(c.NameSpace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=%s", HostPruneAppName),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to list host prune cron jobs")
+	}
+	propagation := metav1.DeletePropagationBackground
+	for _, cronJob := range cronJobs.Items {
+		if wanted[cronJob.Name] {
+			continue
+		}
+		err := c.context.Clientset.BatchV1beta1().CronJobs(cronJob.Namespace).Delete(cronJob.Name, &metav1.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil && !kerrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete host prune cron job %s", cronJob.Name)
+		}
+		logger.Infof("deleted host prune cron job %s", cronJob.Name)
+	}
+
+	if reflect.DeepEqual(nodes.List(), sets.NewString(clusterObj.Status.HostDirNodes...).List()) {
+		return nil
+	}
+	clusterObj.Status.HostDirNodes = nodes.List()
+	return k8sutil.UpdateStatus(c.context.Client, c.NamespacedName, clusterObj)
+}
+
+// daemonLogDirs returns the names of the log directories of the daemon deployments keyed by their nodes, the
+// nodes given by the names in spec are mapped to the node names by resolved
+func (c *cluster) daemonLogDirs(resolved map[string]string) (map[string][]string, error) {
+	deployments, err := c.context.Clientset.AppsV1().Deployments(c.NameSpace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list deployments")
+	}
+
+	dirs := map[string][]string{}
+	for _, d := range deployments.Items {
+		nodeName := templateNodeName(&d.Spec.Template.Spec)
+		if name, ok := resolved[nodeName]; ok {
+			nodeName = name
+		}
+		for _, volume := range d.Spec.Template.Spec.Volumes {
+			if volume.Name != "log-volume" || volume.HostPath == nil || path.Dir(volume.HostPath.Path) != c.logDirHostPath {
+				continue
+			}
+			dirs[nodeName] = append(dirs[nodeName], path.Base(volume.HostPath.Path))
+		}
+	}
+	for nodeName := range dirs {
+		sort.Strings(dirs[nodeName])
+	}
+	return dirs, nil
+}
+
+// templateNodeName returns the node that the pod is bound to by node name or the node affinity of the daemons
+func templateNodeName(podSpec *v1.PodSpec) string {
+	if podSpec.NodeName != "" {
+		return podSpec.NodeName
+	}
+	if podSpec.Affinity == nil || podSpec.Affinity.NodeAffinity == nil || podSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return ""
+	}
+	for _, term := range podSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		for _, field := range term.MatchFields {
+			if field.Key == "metadata.name" && field.Operator == v1.NodeSelectorOpIn && len(field.Values) == 1 {
+				return field.Values[0]
+			}
+		}
+	}
+	return ""
+}
+
+// hostDirsRemaining returns the number of the directories left on the node reported by its last prune job that
+// succeeded, it's -1 if no prune job has succeeded on the node
+func (c *cluster) hostDirsRemaining(nodeName string) (int, error) {
+	jobs, err := c.context.Clientset.BatchV1().Jobs(c.NameSpace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=%s,%s=%s", HostPruneAppName, hostPruneNodeLabel, k8sutil.LabelValue(nodeName)),
+	})
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to list host prune jobs on node %s", nodeName)
+	}
+	var last *batch.Job
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		if job.Status.Succeeded > 0 && (last == nil || last.CreationTimestamp.Before(&job.CreationTimestamp)) {
+			last = job
+		}
+	}
+	if last == nil {
+		return -1, nil
+	}
+
+	message, err := k8sutil.GetJobTerminationMessage(c.context.Clientset, last)
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(message)
+	if len(fields) != 2 || fields[0] != "remaining" {
+		return -1, nil
+	}
+	remaining, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return -1, nil
+	}
+	return remaining, nil
+}
+
+func (c *cluster) makeHostPruneCronJob(spec *curvev1.CurveClusterSpec, nodeName string, kept []string, removed bool) (*batchv1beta1.CronJob, error) {
+	name := k8sutil.TruncateNodeNameForJob(hostPruneCronJobNameFormat, nodeName)
+	labels := map[string]string{
+		"app":              HostPruneAppName,
+		hostPruneNodeLabel: k8sutil.LabelValue(nodeName),
+		"curve_cluster":    c.NameSpace,
+	}
+
+	hostDataDir := strings.TrimRight(config.HostDataDir(spec), "/")
+	hostPathType := v1.HostPathDirectoryOrCreate
+	backoffLimit := int32(0)
+	historyLimit := int32(1)
+
+	podSpec := v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: labels,
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:    "host-prune",
+					Command: []string{"/bin/bash"},
+					Args: []string{"-c", hostPruneScript, "host-prune", c.logDirHostPath, c.confDirHostPath,
+						strconv.Itoa(spec.Cleanup.RetainLogsDays), strings.Join(kept, ","), strconv.FormatBool(removed)},
+					Image:           spec.CurveVersion.Image,
+					ImagePullPolicy: spec.CurveVersion.ImagePullPolicy,
+					VolumeMounts: []v1.VolumeMount{
+						{Name: hostPruneVolumeName, MountPath: hostDataDir},
+					},
+					SecurityContext: k8sutil.PrivilegedContext(true),
+				},
+			},
+			NodeName:      nodeName,
+			RestartPolicy: v1.RestartPolicyNever,
+			Volumes: []v1.Volume{
+				{Name: hostPruneVolumeName, VolumeSource: v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: hostDataDir, Type: &hostPathType}}},
+			},
+		},
+	}
+	k8sutil.InjectEnv(&podSpec.Spec, *spec, "")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, *spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, *spec)
+	k8sutil.InjectDNS(&podSpec.Spec, *spec, "")
+
+	cronJob := &batchv1beta1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: c.NameSpace,
+			Labels:    labels,
+		},
+		Spec: batchv1beta1.CronJobSpec{
+			Schedule:                   hostPruneSchedule,
+			ConcurrencyPolicy:          batchv1beta1.ForbidConcurrent,
+			SuccessfulJobsHistoryLimit: &historyLimit,
+			FailedJobsHistoryLimit:     &historyLimit,
+			JobTemplate: batchv1beta1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: batch.JobSpec{
+					BackoffLimit: &backoffLimit,
+					Template:     podSpec,
+				},
+			},
+		},
+	}
+
+	k8sutil.InjectMetadata(*spec, "", cronJob, &cronJob.Spec.JobTemplate, &cronJob.Spec.JobTemplate.Spec.Template)
+	err := c.ownerInfo.SetControllerReference(cronJob)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to set owner reference to host prune cron job %q", cronJob.Name)
+	}
+
+	return cronJob, nil
+}
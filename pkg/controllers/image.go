@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	batch "k8s.io/api/batch/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver"
+	"github.com/opencurve/curve-operator/pkg/etcd"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+	"github.com/opencurve/curve-operator/pkg/mds"
+	"github.com/opencurve/curve-operator/pkg/snapshotclone"
+	"github.com/opencurve/curve-operator/pkg/tools"
+)
+
+const (
+	imageVerifyTimeout  = 5 * time.Minute
+	imageVerifyInterval = 5 * time.Second
+	// imageVerifyLogLines is how many lines of the log of cosign are read for the verified signatures
+	imageVerifyLogLines = 200
+)
+
+// imageComponents are the daemons whose images are recorded in status keyed by their app labels
+var imageComponents = []struct {
+	appName   string
+	component string
+}{
+	{etcd.AppName, "etcd"},
+	{mds.AppName, "mds"},
+	{chunkserver.AppName, "chunkserver"},
+	{snapshotclone.AppName, "snapshotclone"},
+}
+
+// verifyImages verifies the signatures of the curve image and the tools image by curveVersion.verification before
+// the workloads are created or updated with them. The digests of the verified images are kept, the image failed
+// the verification is not verified again until the image or the public key is changed, or the job is deleted.
+func (c *cluster) verifyImages(spec *curvev1.CurveClusterSpec) error {
+	if spec.CurveVersion.Verification == nil {
+		return nil
+	}
+	if c.verifiedImages == nil {
+		c.verifiedImages = map[string]string{}
+	}
+
+	verifier := tools.New(c.context, c.NamespacedName, *spec, c.ownerInfo)
+	for _, image := range []string{spec.CurveVersion.Image, spec.CurveVersion.JobImage()} {
+		name := verifier.ImageVerifyJobName(image)
+		if _, ok := c.verifiedImages[name]; ok {
+			continue
+		}
+		digest, err := c.runImageVerify(verifier, image)
+		if err != nil {
+			return err
+		}
+		logger.Infof("signature of image %q is verified, its digest is %s", image, digest)
+		c.verifiedImages[name] = digest
+	}
+	return nil
+}
+
+// runImageVerify runs the job to verify the image if it has not been run, and returns the digest of the image
+// whose signature is verified
+func (c *cluster) runImageVerify(verifier *tools.Cluster, image string) (string, error) {
+	job, err := verifier.MakeImageVerifyJob(image)
+	if err != nil {
+		return "", err
+	}
+	_, err = c.context.Clientset.BatchV1().Jobs(job.Namespace).Get(job.Name, metav1.GetOptions{})
+	if err != nil {
+		if !kerrors.IsNotFound(err) {
+			return "", errors.Wrapf(err, "failed to get image verify job %s", job.Name)
+		}
+		if _, err := c.context.Clientset.BatchV1().Jobs(job.Namespace).Create(job); err != nil {
+			return "", errors.Wrapf(err, "failed to create image verify job %s", job.Name)
+		}
+		logger.Infof("created image verify job %s for image %q", job.Name, image)
+	}
+
+	var finished *batch.Job
+	err = wait.PollImmediate(imageVerifyInterval, imageVerifyTimeout, func() (bool, error) {
+		j, err := c.context.Clientset.BatchV1().Jobs(job.Namespace).Get(job.Name, metav1.GetOptions{})
+		if err != nil {
+			logger.Warningf("failed to get image verify job %s. %v", job.Name, err)
+			return false, nil
+		}
+		if j.Status.Succeeded > 0 || j.Status.Failed > 0 {
+			finished = j
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		return "", errors.Errorf("image verify job %s is not finished in %s", job.Name, imageVerifyTimeout)
+	}
+
+	pods, err := c.context.Clientset.CoreV1().Pods(job.Namespace).List(metav1.ListOptions{
+		LabelSelector: "job-name=" + job.Name,
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to list pods of image verify job %s", job.Name)
+	}
+	var logs string
+	for _, pod := range pods.Items {
+		if logs, err = k8sutil.GetContainerLogs(c.context.Clientset, pod.Namespace, pod.Name, "cosign", false, imageVerifyLogLines); err == nil {
+			break
+		}
+	}
+	if finished.Status.Succeeded == 0 {
+		message := strings.TrimSpace(logs)
+		if message == "" {
+			message = fmt.Sprintf("image verify job %s failed, see the logs of its pod", job.Name)
+		}
+		return "", &tools.ImageVerificationError{Image: image, Message: message}
+	}
+	return tools.ParseVerifiedDigest(logs)
+}
+
+// recordImages records the images that the daemons are running and their digests resolved by the pods in status,
+// the images verified with the same digest are marked verified
+func (c *cluster) recordImages(spec *curvev1.CurveClusterSpec) error {
+	var verifier *tools.Cluster
+	if spec.CurveVersion.Verification != nil {
+		verifier = tools.New(c.context, c.NamespacedName, *spec, c.ownerInfo)
+	}
+
+	var images []curvev1.ComponentImageStatus
+	for _, d := range imageComponents {
+		pods, err := c.context.Clientset.CoreV1().Pods(c.NameSpace).List(metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("app=%s", d.appName),
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to list pods of %s", d.appName)
+		}
+		seen := map[curvev1.ComponentImageStatus]bool{}
+		for _, pod := range pods.Items {
+			if len(pod.Spec.Containers) == 0 {
+				continue
+			}
+			// the first container is the daemon, the others are sidecars
+			container := pod.Spec.Containers[0]
+			for _, status := range pod.Status.ContainerStatuses {
+				if status.Name != container.Name || status.ImageID == "" {
+					continue
+				}
+				image := curvev1.ComponentImageStatus{Component: d.component, Image: container.Image, Digest: imageDigest(status.ImageID)}
+				if verifier != nil {
+					digest, ok := c.verifiedImages[verifier.ImageVerifyJobName(image.Image)]
+					image.Verified = ok && digest == image.Digest
+				}
+				if !seen[image] {
+					seen[image] = true
+					images = append(images, image)
+				}
+			}
+		}
+	}
+	sort.SliceStable(images, func(i, j int) bool {
+		if images[i].Component != images[j].Component {
+			return images[i].Component < images[j].Component
+		}
+		return images[i].Image+images[i].Digest < images[j].Image+images[j].Digest
+	})
+
+	clusterObj := &curvev1.CurveCluster{}
+	if err := c.context.Client.Get(context.TODO(), c.NamespacedName, clusterObj); err != nil {
+		return errors.Wrapf(err, "failed to get curvecluster %q", c.NamespacedName)
+	}
+	if reflect.DeepEqual(images, clusterObj.Status.Images) {
+		return nil
+	}
+	clusterObj.Status.Images = images
+	return k8sutil.UpdateStatus(c.context.Client, c.NamespacedName, clusterObj)
+}
+
+// imageDigest returns the digest of the image id of a container status, such as
+// docker-pullable://opencurvedocker/curvebs@sha256:<digest>. The image id is returned as it is if it has no digest
+func imageDigest(imageID string) string {
+	if i := strings.LastIndex(imageID, "@"); i >= 0 {
+		return imageID[i+1:]
+	}
+	return imageID
+}
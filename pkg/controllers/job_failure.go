@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver"
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+	"github.com/opencurve/curve-operator/pkg/notify"
+)
+
+// failureLogLines is the number of the last log lines of the failed container that are captured
+const failureLogLines = 20
+
+// failureLogDedup logs an identical failure of a job once per interval
+var failureLogDedup = k8sutil.NewDeduplicator(k8sutil.DefaultDedupInterval)
+
+// JobFailureReconciler watches the pods of prepare-chunkfile and create-pool jobs, the last log lines of a
+// failed container are captured before the pod is gone and attached to an event and the status of the cluster.
+type JobFailureReconciler struct {
+	Client   client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	context clusterd.Context
+}
+
+func NewJobFailureReconciler(
+	client client.Client,
+	log logr.Logger,
+	scheme *runtime.Scheme,
+	recorder record.EventRecorder,
+	context clusterd.Context,
+) *JobFailureReconciler {
+	context.Client = client
+
+	return &JobFailureReconciler{
+		Client:   client,
+		Log:      log,
+		Scheme:   scheme,
+		Recorder: recorder,
+		context:  context,
+	}
+}
+
+func (r *JobFailureReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("pod", req.NamespacedName)
+
+	pod := &v1.Pod{}
+	err := r.Client.Get(ctx, req.NamespacedName, pod)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			log.Info("pod not found, ignoring since it must be deleted")
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, errors.Wrapf(err, "failed to get pod %q", req.Name)
+	}
+
+	failure, previous := lastContainerFailure(pod)
+	if failure == nil {
+		return reconcile.Result{}, nil
+	}
+
+	clusters := &curvev1.CurveClusterList{}
+	if err := r.Client.List(ctx, clusters, client.InNamespace(pod.Namespace)); err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "failed to list curveclusters")
+	}
+	var clusterObj *curvev1.CurveCluster
+	for i := range clusters.Items {
+		if clusters.Items[i].Spec != nil && clusters.Items[i].GetDeletionTimestamp().IsZero() {
+			clusterObj = &clusters.Items[i]
+		}
+	}
+	if clusterObj == nil {
+		return reconcile.Result{}, nil
+	}
+
+	// the failure has been captured
+	last := clusterObj.Status.LastFailure
+	if last != nil && last.Pod == failure.Pod && last.Container == failure.Container && last.Time.Equal(&failure.Time) {
+		return reconcile.Result{}, nil
+	}
+	// an identical failure of the job is counted
+	failure.Count, failure.FirstTime = 1, failure.Time
+	if last != nil && last.Job == failure.Job && last.Container == failure.Container &&
+		last.ExitCode == failure.ExitCode && last.Reason == failure.Reason {
+		failure.Count, failure.FirstTime = last.Count+1, last.FirstTime
+	}
+
+	logs, err := k8sutil.GetContainerLogs(r.context.Clientset, pod.Namespace, pod.Name, failure.Container, previous, failureLogLines)
+	if err != nil {
+		logger.Warningf("failed to capture logs of failed job %s. %v", failure.Job, err)
+	}
+	failure.Logs = strings.TrimSpace(logs)
+
+	key := fmt.Sprintf("%s/%s/%s/%d/%s", clusterObj.Namespace, failure.Job, failure.Container, failure.ExitCode, failure.Reason)
+	if report, _ := failureLogDedup.Observe(key); report {
+		logger.Warningf("job %s of cluster %q failed with exit code %d (%d times since %v): %s", failure.Job, clusterObj.Name,
+			failure.ExitCode, failure.Count, failure.FirstTime, failure.Logs)
+	}
+	r.Recorder.Eventf(clusterObj, v1.EventTypeWarning, "JobFailed", "job %s failed with exit code %d (%s): %s",
+		failure.Job, failure.ExitCode, failure.Reason, failure.Logs)
+
+	namespacedName := types.NamespacedName{Namespace: clusterObj.Namespace, Name: clusterObj.Name}
+	if pod.Labels["app"] == chunkserver.PrepareJobName {
+		notify.Notify(r.context.Clientset, namespacedName, clusterObj.Spec, curvev1.NotificationEventFormatFailed,
+			fmt.Sprintf("job %s failed with exit code %d (%s): %s", failure.Job, failure.ExitCode, failure.Reason, failure.Logs))
+	}
+
+	clusterObj.Status.LastFailure = failure
+	return reconcile.Result{}, k8sutil.UpdateStatus(r.Client, namespacedName, clusterObj)
+}
+
+// lastContainerFailure returns the last failed container of the job pod, previous is true if the container
+// has been restarted after the failure
+func lastContainerFailure(pod *v1.Pod) (failure *curvev1.JobFailureStatus, previous bool) {
+	for _, status := range pod.Status.ContainerStatuses {
+		terminated, restarted := status.State.Terminated, false
+		if terminated == nil {
+			terminated, restarted = status.LastTerminationState.Terminated, true
+		}
+		if terminated == nil || terminated.ExitCode == 0 {
+			continue
+		}
+		if failure != nil && !failure.Time.Before(&terminated.FinishedAt) {
+			continue
+		}
+		failure = &curvev1.JobFailureStatus{
+			Job:       pod.Labels["job-name"],
+			Pod:       pod.Name,
+			Container: status.Name,
+			ExitCode:  terminated.ExitCode,
+			Reason:    terminated.Reason,
+			Time:      terminated.FinishedAt,
+		}
+		previous = restarted
+	}
+	return failure, previous
+}
+
+// isWatchedJobPod returns true if the object is a pod of prepare-chunkfile or create-pool jobs
+func isWatchedJobPod(meta metav1.Object) bool {
+	app := meta.GetLabels()["app"]
+	return app == chunkserver.PrepareJobName || app == chunkserver.RegisterJobName
+}
+
+func (r *JobFailureReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1.Pod{}).
+		WithEventFilter(predicate.Funcs{
+			CreateFunc:  func(e event.CreateEvent) bool { return isWatchedJobPod(e.Meta) },
+			UpdateFunc:  func(e event.UpdateEvent) bool { return isWatchedJobPod(e.MetaNew) },
+			DeleteFunc:  func(e event.DeleteEvent) bool { return false },
+			GenericFunc: func(e event.GenericEvent) bool { return isWatchedJobPod(e.Meta) },
+		}).
+		Complete(r)
+}
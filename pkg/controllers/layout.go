@@ -0,0 +1,229 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+const (
+	// HostPathLayoutVersion is the version of the layout of the data, logs and conf directories under
+	// spec.hostDataDir, it must be increased with a migration whenever the layout is changed
+	HostPathLayoutVersion = 1
+
+	LayoutMigrateAppName       = "curve-layout-migrate"
+	layoutMigrateJobNameFormat = "curve-layout-migrate-%s"
+	layoutMarkerFile           = ".layout-version"
+	layoutVolumeName           = "host-data-volume"
+	layoutMigrateTimeout       = 10 * time.Minute
+	layoutMigrateInterval      = 5 * time.Second
+)
+
+// layoutMigrations are the commands run in spec.hostDataDir to migrate the layout from the version of the
+// key to the next one. Version 0 is the layout before the version marker is written, which is the same
+// as version 1.
+var layoutMigrations = map[int]string{
+	0: ":",
+}
+
+// layoutMigrateScript migrates the layout of the host data dir $1 to the version $2 step by step, the
+// marker is written after each step so that a failed migration is resumed from the failed step
+const layoutMigrateScript = `
+set -e
+marker="$1/%[1]s"
+target=$2
+version=0
+if [ -f "$marker" ]; then
+  version=$(cat "$marker")
+fi
+if [ "$version" -gt "$target" ]; then
+  echo "host path layout version $version of $1 is newer than $target of curve-operator" | tee /dev/termination-log
+  exit 1
+fi
+mkdir -p "$1"
+cd "$1"
+while [ "$version" -lt "$target" ]; do
+  echo "migrating host path layout of $1 from version $version"
+  case "$version" in
+%[2]s
+  *)
+    echo "no migration of host path layout from version $version" | tee /dev/termination-log
+    exit 1
+    ;;
+  esac
+  version=$((version + 1))
+  echo "$version" > "$marker"
+done
+`
+
+// migrateHostPathLayout runs a job on each node of the cluster to migrate the host path layout written by
+// a former operator to HostPathLayoutVersion, so that the directories of the old layout are not orphaned
+// silently. It's skipped if the layout version recorded in the cluster status is the current one.
+func (c *cluster) migrateHostPathLayout() error {
+	clusterObj := &curvev1.CurveCluster{}
+	if err := c.context.Client.Get(context.TODO(), c.NamespacedName, clusterObj); err != nil {
+		return errors.Wrapf(err, "failed to get curvecluster %q", c.NamespacedName)
+	}
+	if clusterObj.Status.HostPathLayoutVersion == HostPathLayoutVersion {
+		return nil
+	}
+
+	resolved, err := k8sutil.ResolveNodeNames(c.context.Clientset, k8sutil.MergeNodeNames(c.Spec.DaemonNodes(), c.Spec.StorageNodes()))
+	if err != nil {
+		return err
+	}
+	nodes := map[string]bool{}
+	for _, nodeName := range resolved {
+		nodes[nodeName] = true
+	}
+
+	jobs := map[string]*batch.Job{}
+	for nodeName := range nodes {
+		job, err := c.makeLayoutMigrateJob(nodeName)
+		if err != nil {
+			return err
+		}
+		if err := k8sutil.RunReplaceableJob(context.TODO(), c.context.Clientset, job, true); err != nil {
+			return errors.Wrapf(err, "failed to run layout migration job %s", job.Name)
+		}
+		logger.Infof("created layout migration job %s on node %s", job.Name, nodeName)
+		jobs[nodeName] = job
+	}
+
+	var failures []string
+	for nodeName, job := range jobs {
+		if err := c.waitForLayoutMigrateJob(job); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", nodeName, err.Error()))
+		}
+	}
+	if len(failures) > 0 {
+		sort.Strings(failures)
+		return errors.Errorf("failed to migrate host path layout to version %d on nodes %s", HostPathLayoutVersion, strings.Join(failures, " "))
+	}
+	logger.Infof("host path layout is migrated to version %d on %d nodes", HostPathLayoutVersion, len(jobs))
+
+	if err := c.context.Client.Get(context.TODO(), c.NamespacedName, clusterObj); err != nil {
+		return errors.Wrapf(err, "failed to get curvecluster %q", c.NamespacedName)
+	}
+	clusterObj.Status.HostPathLayoutVersion = HostPathLayoutVersion
+	return k8sutil.UpdateStatus(c.context.Client, c.NamespacedName, clusterObj)
+}
+
+// waitForLayoutMigrateJob returns an error with the failure reported by the job if it failed
+func (c *cluster) waitForLayoutMigrateJob(job *batch.Job) error {
+	var finished *batch.Job
+	err := wait.PollImmediate(layoutMigrateInterval, layoutMigrateTimeout, func() (bool, error) {
+		j, err := c.context.Clientset.BatchV1().Jobs(job.Namespace).Get(job.Name, metav1.GetOptions{})
+		if err != nil {
+			logger.Warningf("failed to get layout migration job %s. %v", job.Name, err)
+			return false, nil
+		}
+		if j.Status.Succeeded > 0 || j.Status.Failed > 0 {
+			finished = j
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		return errors.Errorf("layout migration job %s is not finished in %s", job.Name, layoutMigrateTimeout)
+	}
+	if finished.Status.Succeeded > 0 {
+		return nil
+	}
+
+	message, err := k8sutil.GetJobTerminationMessage(c.context.Clientset, job)
+	if err != nil {
+		return err
+	}
+	if message != "" {
+		return errors.New(strings.TrimSpace(message))
+	}
+	return errors.Errorf("layout migration job %s failed, see the logs of its pod", job.Name)
+}
+
+func (c *cluster) makeLayoutMigrateJob(nodeName string) (*batch.Job, error) {
+	jobName := k8sutil.TruncateNodeNameForJob(layoutMigrateJobNameFormat, nodeName)
+	labels := map[string]string{
+		"app":           LayoutMigrateAppName,
+		"curve_cluster": c.NameSpace,
+	}
+
+	var versions []int
+	for version := range layoutMigrations {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+	var cases []string
+	for _, version := range versions {
+		cases = append(cases, fmt.Sprintf("  %d)\n    %s\n    ;;", version, layoutMigrations[version]))
+	}
+	script := fmt.Sprintf(layoutMigrateScript, layoutMarkerFile, strings.Join(cases, "\n"))
+
+	hostDataDir := strings.TrimRight(config.HostDataDir(c.Spec), "/")
+	hostPathType := v1.HostPathDirectoryOrCreate
+	backoffLimit := int32(0)
+
+	podSpec := v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   jobName,
+			Labels: labels,
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:            "layout-migrate",
+					Command:         []string{"/bin/bash"},
+					Args:            []string{"-c", script, "layout-migrate", hostDataDir, strconv.Itoa(HostPathLayoutVersion)},
+					Image:           c.Spec.CurveVersion.Image,
+					ImagePullPolicy: c.Spec.CurveVersion.ImagePullPolicy,
+					VolumeMounts: []v1.VolumeMount{
+						{Name: layoutVolumeName, MountPath: hostDataDir},
+					},
+					SecurityContext: k8sutil.PrivilegedContext(true),
+				},
+			},
+			NodeName:      nodeName,
+			RestartPolicy: v1.RestartPolicyNever,
+			Volumes: []v1.Volume{
+				{Name: layoutVolumeName, VolumeSource: v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: hostDataDir, Type: &hostPathType}}},
+			},
+		},
+	}
+	k8sutil.InjectEnv(&podSpec.Spec, *c.Spec, "")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, *c.Spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, *c.Spec)
+	k8sutil.InjectDNS(&podSpec.Spec, *c.Spec, "")
+
+	job := &batch.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jobName,
+			Namespace: c.NameSpace,
+			Labels:    labels,
+		},
+		Spec: batch.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template:     podSpec,
+		},
+	}
+
+	k8sutil.InjectMetadata(*c.Spec, "", job, &job.Spec.Template)
+	err := c.ownerInfo.SetControllerReference(job)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to set owner reference to layout migration job %q", job.Name)
+	}
+
+	return job, nil
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/daemon"
+	"github.com/opencurve/curve-operator/pkg/etcd"
+	"github.com/opencurve/curve-operator/pkg/mds"
+	"github.com/opencurve/curve-operator/pkg/snapshotclone"
+)
+
+// updateLogLevels applies the changed log level of each daemon by restarting its deployments one by one
+func (c *cluster) updateLogLevels(spec *curvev1.CurveClusterSpec) error {
+	daemons := []struct {
+		appName  string
+		oldLevel *string
+		newLevel string
+	}{
+		{etcd.AppName, &c.Spec.Etcd.LogLevel, spec.Etcd.LogLevel},
+		{mds.AppName, &c.Spec.Mds.LogLevel, spec.Mds.LogLevel},
+		{chunkserver.AppName, &c.Spec.Storage.LogLevel, spec.Storage.LogLevel},
+		{snapshotclone.AppName, &c.Spec.SnapShotClone.LogLevel, spec.SnapShotClone.LogLevel},
+	}
+
+	for _, d := range daemons {
+		if *d.oldLevel == d.newLevel {
+			continue
+		}
+		logger.Infof("log level of %s changed from %q to %q", d.appName, *d.oldLevel, d.newLevel)
+		if !disruptionAllowed(spec, fmt.Sprintf("restart of %s for the log level", d.appName)) {
+			continue
+		}
+		if err := c.setDaemonLogLevel(d.appName, d.newLevel); err != nil {
+			return errors.Wrapf(err, "failed to set log level of %s", d.appName)
+		}
+		*d.oldLevel = d.newLevel
+	}
+	return nil
+}
+
+// setDaemonLogLevel updates the log level of all deployments of the daemon and waits for them to restart
+func (c *cluster) setDaemonLogLevel(appName string, level string) error {
+	if appName == etcd.AppName && c.Spec.Etcd.StatefulSet != nil {
+		// the members of the etcd StatefulSet take the log level by args, the StatefulSet rolls them one by one
+		spec := c.Spec.DeepCopy()
+		spec.Etcd.LogLevel = level
+		return etcd.New(c.context, c.NamespacedName, *spec, c.ownerInfo, c.dataDirHostPath, c.logDirHostPath, c.confDirHostPath).Start(nil)
+	}
+	return c.rollDaemon(appName, func(d *appsv1.Deployment) error {
+		if appName == etcd.AppName {
+			// etcd reads its log level from etcd.conf only
+			daemonID := d.Spec.Template.Labels["curve_daemon_id"]
+			if err := c.setEtcdConfLogLevel(fmt.Sprintf("%s-%s", etcd.ConfigMapNamePrefix, daemonID), level); err != nil {
+				return err
+			}
+		} else {
+			// the first container is the daemon, the others are sidecars
+			daemon.SetLogLevelEnv(&d.Spec.Template.Spec.Containers[0], level)
+		}
+		return nil
+	})
+}
+
+// setEtcdConfLogLevel sets the log level in etcd.conf of the configmap
+func (c *cluster) setEtcdConfLogLevel(configMapName string, level string) error {
+	cm, err := c.context.Clientset.CoreV1().ConfigMaps(c.NameSpace).Get(configMapName, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get configmap %q", configMapName)
+	}
+	cm.Data[config.EtcdConfigMapDataKey] = daemon.SetEtcdLogLevel(cm.Data[config.EtcdConfigMapDataKey], level)
+	if _, err := c.context.Clientset.CoreV1().ConfigMaps(c.NameSpace).Update(cm); err != nil {
+		return errors.Wrapf(err, "failed to update configmap %q", configMapName)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+)
+
+// NamespaceReconciler watches the namespaces and copies the connection secrets of all clusters to the namespaces
+// labeled curve.opencurve.io/inject=enabled, so the applications get the client configs without asking the
+// cluster admin. The copies are deleted when the label is removed unless the namespace is exported by the cluster.
+// The copies are updated with the endpoints by the reconcile of the clusters.
+type NamespaceReconciler struct {
+	Client client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+
+	context clusterd.Context
+}
+
+func NewNamespaceReconciler(
+	client client.Client,
+	log logr.Logger,
+	scheme *runtime.Scheme,
+	context clusterd.Context,
+) *NamespaceReconciler {
+	context.Client = client
+
+	return &NamespaceReconciler{
+		Client:  client,
+		Log:     log,
+		Scheme:  scheme,
+		context: context,
+	}
+}
+
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+
+func (r *NamespaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("namespace", req.Name)
+
+	namespace := &v1.Namespace{}
+	err := r.Client.Get(ctx, req.NamespacedName, namespace)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			log.Info("namespace not found, ignoring since it must be deleted")
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, errors.Wrapf(err, "failed to get namespace %q", req.Name)
+	}
+	// the copies are deleted with the namespace
+	if !namespace.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, nil
+	}
+
+	clusters := &curvev1.CurveClusterList{}
+	if err := r.Client.List(ctx, clusters); err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "failed to list curveclusters")
+	}
+	for i := range clusters.Items {
+		clusterObj := &clusters.Items[i]
+		if clusterObj.Spec == nil || !clusterObj.GetDeletionTimestamp().IsZero() || clusterObj.Namespace == namespace.Name {
+			continue
+		}
+		if err := r.injectConnection(clusterObj, namespace); err != nil {
+			return reconcile.Result{}, errors.Wrapf(err, "failed to inject connection secret of cluster %q to namespace %q",
+				clusterObj.Name, namespace.Name)
+		}
+	}
+	return reconcile.Result{}, nil
+}
+
+// injectConnection copies the connection secret of the cluster to the namespace if it's labeled or exported by the
+// cluster, or deletes the copy in it otherwise
+func (r *NamespaceReconciler) injectConnection(clusterObj *curvev1.CurveCluster, namespace *v1.Namespace) error {
+	name := exportedConnectionSecretName(clusterObj.Namespace)
+	exported := namespace.Labels[curvev1.ConnectionInjectLabel] == connectionInjectEnabled
+	for _, ns := range clusterObj.Spec.Connection.ExportNamespaces {
+		if ns == namespace.Name {
+			exported = true
+		}
+	}
+	if !exported {
+		err := r.context.Clientset.CoreV1().Secrets(namespace.Name).Delete(name, &metav1.DeleteOptions{})
+		if err != nil && !kerrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete connection secret %s/%s", namespace.Name, name)
+		}
+		if err == nil {
+			r.Log.Info("deleted connection secret that is not injected any more", "namespace", namespace.Name, "secret", name)
+		}
+		return nil
+	}
+
+	// the secret is created by the first reconcile of the cluster, which exports it to the namespace too
+	source, err := r.context.Clientset.CoreV1().Secrets(clusterObj.Namespace).Get(ConnectionSecretName, metav1.GetOptions{})
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to get connection secret of cluster %q", clusterObj.Name)
+	}
+	copied, err := r.context.Clientset.CoreV1().Secrets(namespace.Name).Get(name, metav1.GetOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to get connection secret %s/%s", namespace.Name, name)
+	}
+	if err == nil && reflect.DeepEqual(copied.Data, source.Data) {
+		return nil
+	}
+	r.Log.Info("injecting connection secret", "namespace", namespace.Name, "secret", name)
+	return exportConnection(r.Client, clusterObj.Namespace, namespace.Name, source.Data)
+}
+
+func (r *NamespaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1.Namespace{}).
+		WithEventFilter(predicate.Funcs{
+			// only the change of the inject label is interested
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				return e.MetaOld.GetLabels()[curvev1.ConnectionInjectLabel] != e.MetaNew.GetLabels()[curvev1.ConnectionInjectLabel]
+			},
+			DeleteFunc: func(e event.DeleteEvent) bool {
+				return false
+			},
+		}).
+		Complete(r)
+}
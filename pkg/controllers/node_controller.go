@@ -0,0 +1,286 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver"
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+	"github.com/opencurve/curve-operator/pkg/notify"
+)
+
+// NodeReconciler watches the nodes and marks the chunkservers on NotReady nodes degraded,
+// the chunkservers are set offline in topology if the node is still NotReady after the grace period.
+type NodeReconciler struct {
+	Client   client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	context clusterd.Context
+}
+
+func NewNodeReconciler(
+	client client.Client,
+	log logr.Logger,
+	scheme *runtime.Scheme,
+	recorder record.EventRecorder,
+	context clusterd.Context,
+) *NodeReconciler {
+	context.Client = client
+
+	return &NodeReconciler{
+		Client:   client,
+		Log:      log,
+		Scheme:   scheme,
+		Recorder: recorder,
+		context:  context,
+	}
+}
+
+// +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
+
+func (r *NodeReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("node", req.Name)
+
+	node := &v1.Node{}
+	err := r.Client.Get(ctx, req.NamespacedName, node)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			log.Info("node not found, ignoring since it must be deleted")
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, errors.Wrapf(err, "failed to get node %q", req.Name)
+	}
+
+	clusters := &curvev1.CurveClusterList{}
+	if err := r.Client.List(ctx, clusters); err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "failed to list curveclusters")
+	}
+
+	var requeueAfter time.Duration
+	for i := range clusters.Items {
+		clusterObj := &clusters.Items[i]
+		if clusterObj.Spec == nil || !clusterObj.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+
+		after, err := r.reconcileChunkServersOnNode(clusterObj, node)
+		if err != nil {
+			return reconcile.Result{}, errors.Wrapf(err, "failed to reconcile chunkservers of cluster %q on node %q", clusterObj.Name, node.Name)
+		}
+		if after > 0 && (requeueAfter == 0 || after < requeueAfter) {
+			requeueAfter = after
+		}
+	}
+
+	return reconcile.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// reconcileChunkServersOnNode updates the status of chunkservers on the node and returns the duration
+// after which the node should be checked again
+func (r *NodeReconciler) reconcileChunkServersOnNode(clusterObj *curvev1.CurveCluster, node *v1.Node) (time.Duration, error) {
+	deployments, err := r.context.Clientset.AppsV1().Deployments(clusterObj.Namespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=%s", chunkserver.AppName),
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to list chunkserver deployments")
+	}
+
+	var names []string
+	var ports []int
+	for _, d := range deployments.Items {
+		if d.Spec.Template.Spec.NodeName != node.Name {
+			continue
+		}
+		names = append(names, d.Name)
+		for _, c := range d.Spec.Template.Spec.Containers {
+			for _, p := range c.Ports {
+				ports = append(ports, int(p.ContainerPort))
+			}
+		}
+	}
+
+	ready, since := nodeReadyCondition(node)
+	if ready {
+		if removeChunkServersOnNode(clusterObj, node.Name) {
+			r.Recorder.Eventf(clusterObj, v1.EventTypeNormal, "NodeReady", "node %s is Ready again", node.Name)
+			return 0, r.updateStatus(clusterObj)
+		}
+		return 0, nil
+	}
+	if len(names) == 0 {
+		return 0, nil
+	}
+
+	gracePeriod := time.Duration(clusterObj.Spec.Storage.FailoverGracePeriodSeconds) * time.Second
+	if gracePeriod == 0 {
+		gracePeriod = time.Duration(config.GetOperatorSettings().FailoverGracePeriodSeconds) * time.Second
+	}
+	elapsed := time.Since(since)
+
+	// 1. the node is in the grace period, mark the chunkservers degraded and check it again when the grace period ends
+	if elapsed < gracePeriod {
+		msg := fmt.Sprintf("node %s is NotReady since %s", node.Name, since.Format(time.RFC3339))
+		if setChunkServersState(clusterObj, node.Name, names, curvev1.ChunkServerStateDegraded, msg) {
+			logger.Warningf("chunkservers %v of cluster %q are degraded because %s", names, clusterObj.Name, msg)
+			r.Recorder.Eventf(clusterObj, v1.EventTypeWarning, "ChunkServerDegraded", "chunkservers %v are degraded because %s", names, msg)
+			if err := r.updateStatus(clusterObj); err != nil {
+				return 0, err
+			}
+		}
+		return gracePeriod - elapsed, nil
+	}
+
+	// 2. the grace period is over, set the chunkservers offline to recover the copysets on them
+	if chunkServersInState(clusterObj, node.Name, names, curvev1.ChunkServerStateOffline) {
+		return 0, nil
+	}
+
+	nodeIP, err := k8sutil.NodeAddress(node, &clusterObj.Spec.Network)
+	if err != nil {
+		return 0, err
+	}
+
+	ownerInfo := k8sutil.NewOwnerInfo(clusterObj, r.Scheme)
+	namespacedName := types.NamespacedName{Namespace: clusterObj.Namespace, Name: clusterObj.Name}
+	dataDir, logDir, confDir := config.HostDaemonDirs(clusterObj.Spec)
+	chunkservers := chunkserver.New(r.context, namespacedName, *clusterObj.Spec, ownerInfo, dataDir, logDir, confDir)
+	job, err := chunkservers.RunOfflineJob(node.Name, nodeIP, ports)
+	if err != nil {
+		r.Recorder.Eventf(clusterObj, v1.EventTypeWarning, "ChunkServerOfflineFailed", "failed to set chunkservers %v offline: %v", names, err)
+		return 0, err
+	}
+
+	msg := fmt.Sprintf("node %s is NotReady for more than %s, set offline by job %s", node.Name, gracePeriod, job.Name)
+	setChunkServersState(clusterObj, node.Name, names, curvev1.ChunkServerStateOffline, msg)
+	logger.Warningf("chunkservers %v of cluster %q are offline because %s", names, clusterObj.Name, msg)
+	r.Recorder.Eventf(clusterObj, v1.EventTypeWarning, "ChunkServerOffline", "chunkservers %v are offline because %s", names, msg)
+	notify.Notify(r.context.Clientset, namespacedName, clusterObj.Spec, curvev1.NotificationEventChunkServerOffline,
+		fmt.Sprintf("chunkservers %v are offline because %s", names, msg))
+
+	return 0, r.updateStatus(clusterObj)
+}
+
+func (r *NodeReconciler) updateStatus(clusterObj *curvev1.CurveCluster) error {
+	namespacedName := types.NamespacedName{Namespace: clusterObj.Namespace, Name: clusterObj.Name}
+	return k8sutil.UpdateStatus(r.Client, namespacedName, clusterObj)
+}
+
+// nodeReadyCondition returns whether the node is ready and the last transition time of the ready condition
+func nodeReadyCondition(node *v1.Node) (bool, time.Time) {
+	for _, c := range node.Status.Conditions {
+		if c.Type == v1.NodeReady {
+			return c.Status == v1.ConditionTrue, c.LastTransitionTime.Time
+		}
+	}
+	// the node has not reported its status yet
+	return false, node.CreationTimestamp.Time
+}
+
+// setChunkServersState sets the state of chunkservers on the node and returns true if any of them changed
+func setChunkServersState(clusterObj *curvev1.CurveCluster, nodeName string, names []string, state curvev1.ChunkServerState, msg string) bool {
+	changed := false
+	for _, name := range names {
+		found := false
+		for i := range clusterObj.Status.ChunkServers {
+			cs := &clusterObj.Status.ChunkServers[i]
+			if cs.Name != name {
+				continue
+			}
+			found = true
+			if cs.State != state || cs.NodeName != nodeName {
+				cs.NodeName = nodeName
+				cs.State = state
+				cs.Message = msg
+				cs.LastTransitionTime = metav1.Now()
+				changed = true
+			}
+		}
+		if !found {
+			clusterObj.Status.ChunkServers = append(clusterObj.Status.ChunkServers, curvev1.ChunkServerStatus{
+				Name:               name,
+				NodeName:           nodeName,
+				State:              state,
+				Message:            msg,
+				LastTransitionTime: metav1.Now(),
+			})
+			changed = true
+		}
+	}
+	return changed
+}
+
+// chunkServersInState returns true if all the chunkservers on the node are in the state
+func chunkServersInState(clusterObj *curvev1.CurveCluster, nodeName string, names []string, state curvev1.ChunkServerState) bool {
+	for _, name := range names {
+		found := false
+		for _, cs := range clusterObj.Status.ChunkServers {
+			if cs.Name == name && cs.NodeName == nodeName && cs.State == state {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// removeChunkServersOnNode removes the status of chunkservers on the node and returns true if any of them removed
+func removeChunkServersOnNode(clusterObj *curvev1.CurveCluster, nodeName string) bool {
+	var chunkServers []curvev1.ChunkServerStatus
+	for _, cs := range clusterObj.Status.ChunkServers {
+		// the device replacement, failure and removal are not related to the node state
+		if cs.NodeName != nodeName || cs.State == curvev1.ChunkServerStateReplacing || cs.State == curvev1.ChunkServerStatePendingReplacement ||
+			cs.State == curvev1.ChunkServerStateRetiring {
+			chunkServers = append(chunkServers, cs)
+		}
+	}
+	removed := len(chunkServers) != len(clusterObj.Status.ChunkServers)
+	clusterObj.Status.ChunkServers = chunkServers
+	return removed
+}
+
+func (r *NodeReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1.Node{}).
+		WithEventFilter(predicate.Funcs{
+			// node status is updated by heartbeat, only the change of ready condition is interested
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				oldNode, ok := e.ObjectOld.(*v1.Node)
+				if !ok {
+					return false
+				}
+				newNode, ok := e.ObjectNew.(*v1.Node)
+				if !ok {
+					return false
+				}
+				oldReady, oldSince := nodeReadyCondition(oldNode)
+				newReady, newSince := nodeReadyCondition(newNode)
+				return oldReady != newReady || !oldSince.Equal(newSince)
+			},
+		}).
+		Complete(r)
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/opencurve/curve-operator/pkg/config"
+)
+
+// OperatorConfigReconciler reloads the operator settings when the configmap curve-operator-config in the namespace
+// of the operator changes, the clusters take the new defaults by their next reconcile. The settings are kept if
+// the configmap is invalid, and reset to the defaults if it's deleted.
+type OperatorConfigReconciler struct {
+	Client    client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	Namespace string
+}
+
+func NewOperatorConfigReconciler(
+	client client.Client,
+	log logr.Logger,
+	scheme *runtime.Scheme,
+	namespace string,
+) *OperatorConfigReconciler {
+	return &OperatorConfigReconciler{
+		Client:    client,
+		Log:       log,
+		Scheme:    scheme,
+		Namespace: namespace,
+	}
+}
+
+// LoadOperatorConfig loads the operator settings of the configmap before the controllers start
+func LoadOperatorConfig(c client.Reader, namespace string) error {
+	cm := &v1.ConfigMap{}
+	err := c.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: config.OperatorConfigMapName}, cm)
+	if kerrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to get configmap %s/%s", namespace, config.OperatorConfigMapName)
+	}
+	settings, err := config.ParseOperatorSettings(cm.Data)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse configmap %s/%s", namespace, config.OperatorConfigMapName)
+	}
+	config.SetOperatorSettings(settings)
+	return nil
+}
+
+func (r *OperatorConfigReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("configmap", req.NamespacedName)
+
+	settings := config.DefaultOperatorSettings()
+	cm := &v1.ConfigMap{}
+	err := r.Client.Get(context.TODO(), req.NamespacedName, cm)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to get configmap %q", req.NamespacedName)
+	}
+	if err == nil {
+		if settings, err = config.ParseOperatorSettings(cm.Data); err != nil {
+			// it's not retried until the configmap is fixed
+			log.Error(err, "invalid operator settings, the current ones are kept")
+			return reconcile.Result{}, nil
+		}
+	}
+
+	if reflect.DeepEqual(settings, config.GetOperatorSettings()) {
+		return reconcile.Result{}, nil
+	}
+	config.SetOperatorSettings(settings)
+	log.Info("reloaded operator settings", "settings", settings)
+	return reconcile.Result{}, nil
+}
+
+func (r *OperatorConfigReconciler) isOperatorConfig(namespace, name string) bool {
+	return namespace == r.Namespace && name == config.OperatorConfigMapName
+}
+
+func (r *OperatorConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("operatorconfig").
+		For(&v1.ConfigMap{}).
+		WithEventFilter(predicate.Funcs{
+			CreateFunc: func(e event.CreateEvent) bool {
+				return r.isOperatorConfig(e.Meta.GetNamespace(), e.Meta.GetName())
+			},
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				return r.isOperatorConfig(e.MetaNew.GetNamespace(), e.MetaNew.GetName())
+			},
+			DeleteFunc: func(e event.DeleteEvent) bool {
+				return r.isOperatorConfig(e.Meta.GetNamespace(), e.Meta.GetName())
+			},
+			GenericFunc: func(e event.GenericEvent) bool {
+				return false
+			},
+		}).
+		Complete(r)
+}
@@ -0,0 +1,235 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver"
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// profileConfigMapPrefix is the prefix of the configmaps that the profiles are written to, followed by the name
+// of the chunkserver deployment
+const profileConfigMapPrefix = "curve-profile-"
+
+// ProfileReconciler collects the profiles of chunkservers requested by the profile annotation on the cluster or
+// on the chunkserver deployments. The profilers of brpc are enabled on the running chunkserver and reverted
+// after the profile, so no pod is edited or restarted. It's apart from the reconcile of the cluster because the
+// profile takes a while.
+type ProfileReconciler struct {
+	Client   client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	context clusterd.Context
+}
+
+func NewProfileReconciler(
+	client client.Client,
+	log logr.Logger,
+	scheme *runtime.Scheme,
+	recorder record.EventRecorder,
+	context clusterd.Context,
+) *ProfileReconciler {
+	context.Client = client
+
+	return &ProfileReconciler{
+		Client:   client,
+		Log:      log,
+		Scheme:   scheme,
+		Recorder: recorder,
+		context:  context,
+	}
+}
+
+func (r *ProfileReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("curvecluster", req.NamespacedName)
+
+	clusterObj := &curvev1.CurveCluster{}
+	err := r.Client.Get(ctx, req.NamespacedName, clusterObj)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			log.Info("curvecluster not found, ignoring since it must be deleted")
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, errors.Wrapf(err, "failed to get curvecluster %q", req.NamespacedName)
+	}
+	if clusterObj.Spec == nil || !clusterObj.GetDeletionTimestamp().IsZero() {
+		return reconcile.Result{}, nil
+	}
+
+	if value, ok := clusterObj.Annotations[curvev1.ProfileAnnotation]; ok {
+		name, profile := "", value
+		parts := strings.SplitN(value, ":", 3)
+		if len(parts) == 3 && parts[0] != "" && parts[1] != "" {
+			name, profile = chunkserver.DeploymentName(parts[0], parts[1]), parts[2]
+		}
+		status := r.profile(clusterObj, name, profile)
+		delete(clusterObj.Annotations, curvev1.ProfileAnnotation)
+		if err := r.Client.Update(ctx, clusterObj); err != nil {
+			return reconcile.Result{}, errors.Wrapf(err, "failed to remove annotation %s", curvev1.ProfileAnnotation)
+		}
+		clusterObj.Status.Profile = status
+		if err := k8sutil.UpdateStatus(r.Client, req.NamespacedName, clusterObj); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	clientset := r.context.Clientset
+	deployments, err := clientset.AppsV1().Deployments(clusterObj.Namespace).List(metav1.ListOptions{LabelSelector: "app=" + chunkserver.AppName})
+	if err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "failed to list chunkserver deployments")
+	}
+	for i := range deployments.Items {
+		d := &deployments.Items[i]
+		value, ok := d.Annotations[curvev1.ProfileAnnotation]
+		if !ok {
+			continue
+		}
+		status := r.profile(clusterObj, d.Name, value)
+		delete(d.Annotations, curvev1.ProfileAnnotation)
+		if _, err := clientset.AppsV1().Deployments(d.Namespace).Update(d); err != nil {
+			return reconcile.Result{}, errors.Wrapf(err, "failed to remove annotation %s of chunkserver %q", curvev1.ProfileAnnotation, d.Name)
+		}
+		clusterObj.Status.Profile = status
+		if err := k8sutil.UpdateStatus(r.Client, req.NamespacedName, clusterObj); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+	return reconcile.Result{}, nil
+}
+
+// profile collects the profile of the chunkserver deployment into a configmap, the failure is reported in the
+// status and by an event rather than retried
+func (r *ProfileReconciler) profile(clusterObj *curvev1.CurveCluster, name, value string) *curvev1.ProfileStatus {
+	status := &curvev1.ProfileStatus{ChunkServer: name}
+	cm, err := r.collectProfile(clusterObj, name, value, status)
+	status.CollectedTime = metav1.Now()
+	if err != nil {
+		status.Message = err.Error()
+		logger.Warningf("failed to profile chunkserver %q of cluster %q. %v", name, clusterObj.Name, err)
+		r.Recorder.Eventf(clusterObj, v1.EventTypeWarning, "ProfileFailed", "failed to profile chunkserver %q: %v", name, err)
+		return status
+	}
+	status.ConfigMap = cm
+	logger.Infof("%s profile of chunkserver %q of cluster %q is written to configmap %q", status.Type, name, clusterObj.Name, cm)
+	r.Recorder.Eventf(clusterObj, v1.EventTypeNormal, "ProfileCollected", "%s profile of chunkserver %q is written to configmap %s", status.Type, name, cm)
+	return status
+}
+
+func (r *ProfileReconciler) collectProfile(clusterObj *curvev1.CurveCluster, name, value string, status *curvev1.ProfileStatus) (string, error) {
+	if name == "" {
+		return "", errors.Errorf("invalid annotation %s=%q, it should be <node>:<device>:<type>[:<seconds>]", curvev1.ProfileAnnotation, value)
+	}
+	request, err := chunkserver.ParseProfileRequest(value)
+	if err != nil {
+		return "", err
+	}
+	status.Type = request.Type
+
+	clientset := r.context.Clientset
+	d, err := clientset.AppsV1().Deployments(clusterObj.Namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return "", errors.Errorf("no chunkserver %q", name)
+		}
+		return "", errors.Wrapf(err, "failed to get chunkserver deployment %q", name)
+	}
+	if d.Status.ReadyReplicas == 0 {
+		return "", errors.Errorf("chunkserver %q is not ready", name)
+	}
+	port := containerPort(&d.Spec.Template.Spec.Containers[0], "listen-port")
+	nodeName := d.Spec.Template.Spec.NodeName
+	node, err := clientset.CoreV1().Nodes().Get(nodeName, metav1.GetOptions{})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get node %q", nodeName)
+	}
+	nodeIP, err := k8sutil.NodeAddress(node, &clusterObj.Spec.Network)
+	if err != nil {
+		return "", err
+	}
+
+	profile, err := chunkserver.CollectProfile(fmt.Sprintf("%s:%d", nodeIP, port), request)
+	if err != nil {
+		return "", err
+	}
+
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      profileConfigMapPrefix + name,
+			Namespace: clusterObj.Namespace,
+		},
+		// the profile of each type is kept until it's collected again
+		BinaryData: map[string][]byte{request.Type: profile},
+	}
+	k8sutil.InjectMetadata(*clusterObj.Spec, "", cm)
+	ownerInfo := k8sutil.NewOwnerInfo(clusterObj, r.Scheme)
+	if err := ownerInfo.SetControllerReference(cm); err != nil {
+		return "", errors.Wrapf(err, "failed to set owner reference to configmap %q", cm.Name)
+	}
+	if err := k8sutil.Apply(r.context.Client, cm); err != nil {
+		return "", errors.Wrapf(err, "failed to write configmap %q", cm.Name)
+	}
+	return cm.Name, nil
+}
+
+// clusterOfProfiledChunkServer enqueues the cluster of the chunkserver deployment that has the profile annotation
+func (r *ProfileReconciler) clusterOfProfiledChunkServer(obj handler.MapObject) []reconcile.Request {
+	if _, ok := obj.Meta.GetAnnotations()[curvev1.ProfileAnnotation]; !ok || obj.Meta.GetLabels()["app"] != chunkserver.AppName {
+		return nil
+	}
+	clusters := &curvev1.CurveClusterList{}
+	if err := r.Client.List(context.TODO(), clusters, client.InNamespace(obj.Meta.GetNamespace())); err != nil {
+		logger.Warningf("failed to list curveclusters in namespace %q. %v", obj.Meta.GetNamespace(), err)
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, c := range clusters.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: c.Namespace, Name: c.Name}})
+	}
+	return requests
+}
+
+func (r *ProfileReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("profile").
+		For(&curvev1.CurveCluster{}).
+		Watches(&source.Kind{Type: &appsv1.Deployment{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.clusterOfProfiledChunkServer),
+		}).
+		WithEventFilter(predicate.Funcs{
+			// only the objects with the profile annotation are interested
+			CreateFunc:  func(e event.CreateEvent) bool { return hasProfileAnnotation(e.Meta) },
+			UpdateFunc:  func(e event.UpdateEvent) bool { return hasProfileAnnotation(e.MetaNew) },
+			DeleteFunc:  func(e event.DeleteEvent) bool { return false },
+			GenericFunc: func(e event.GenericEvent) bool { return hasProfileAnnotation(e.Meta) },
+		}).
+		Complete(r)
+}
+
+func hasProfileAnnotation(meta metav1.Object) bool {
+	_, ok := meta.GetAnnotations()[curvev1.ProfileAnnotation]
+	return ok
+}
@@ -0,0 +1,252 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/tools"
+)
+
+const (
+	// qosResyncInterval is the interval to apply the limits to the new volumes of the user
+	qosResyncInterval = 10 * time.Minute
+	// qosRetryInterval is the interval to retry the policy that failed to be applied
+	qosRetryInterval = time.Minute
+)
+
+// throttleTypes are all the throttle types of curve volume, the types not in the limits of a policy are removed
+var throttleTypes = []curvev1.ThrottleType{
+	curvev1.ThrottleTypeIOPSTotal,
+	curvev1.ThrottleTypeIOPSRead,
+	curvev1.ThrottleTypeIOPSWrite,
+	curvev1.ThrottleTypeBPSTotal,
+	curvev1.ThrottleTypeBPSRead,
+	curvev1.ThrottleTypeBPSWrite,
+}
+
+// CurveQoSPolicyReconciler applies the throttle limits of the CurveQoSPolicy to the volumes by the curve client in
+// the tools pod of the cluster. The limits of the volumes are removed when they are removed from the policy or
+// the policy is deleted.
+type CurveQoSPolicyReconciler struct {
+	Client client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+
+	context clusterd.Context
+}
+
+func NewCurveQoSPolicyReconciler(
+	client client.Client,
+	log logr.Logger,
+	scheme *runtime.Scheme,
+	context clusterd.Context,
+) *CurveQoSPolicyReconciler {
+	context.Client = client
+
+	return &CurveQoSPolicyReconciler{
+		Client:  client,
+		Log:     log,
+		Scheme:  scheme,
+		context: context,
+	}
+}
+
+// +kubebuilder:rbac:groups=operator.curve.io,resources=curveqospolicies,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=operator.curve.io,resources=curveqospolicies/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get
+
+func (r *CurveQoSPolicyReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("curveqospolicy", req.NamespacedName)
+
+	policy := &curvev1.CurveQoSPolicy{}
+	err := r.Client.Get(ctx, req.NamespacedName, policy)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, errors.Wrapf(err, "failed to get curveqospolicy %q", req.NamespacedName)
+	}
+
+	finalizer := buildFinalizerName("CurveQoSPolicy")
+	if !policy.GetDeletionTimestamp().IsZero() {
+		if !contains(policy.Finalizers, finalizer) {
+			return reconcile.Result{}, nil
+		}
+		// the limits are kept if the cluster is gone with the volumes
+		if clusterExists(r.Client, policy.Namespace, policy.Spec.Cluster) {
+			password, err := r.getPassword(policy)
+			if err != nil {
+				return reconcile.Result{}, err
+			}
+			if _, failures := r.removeThrottles(policy, password, policy.Status.AppliedVolumes); len(failures) > 0 {
+				return reconcile.Result{}, errors.Errorf("failed to remove the limits of volumes: %s", strings.Join(failures, "; "))
+			}
+		}
+		return reconcile.Result{}, RemoveFinalizerWithName(ctx, r.Client, policy, req.NamespacedName, finalizer)
+	}
+
+	if !contains(policy.Finalizers, finalizer) {
+		policy.Finalizers = append(policy.Finalizers, finalizer)
+		if err := r.Client.Update(ctx, policy); err != nil {
+			return reconcile.Result{}, errors.Wrapf(err, "failed to add finalizer %q on %q", finalizer, policy.Name)
+		}
+	}
+
+	if err := r.apply(policy); err != nil {
+		log.Info("failed to apply the limits", "error", err.Error())
+		return reconcile.Result{RequeueAfter: qosRetryInterval}, r.setStatus(policy, curvev1.QoSPolicyPhaseFailed, err.Error())
+	}
+	return reconcile.Result{RequeueAfter: qosResyncInterval}, r.setStatus(policy, curvev1.QoSPolicyPhaseApplied, "")
+}
+
+// apply sets the limits of the policy to its volumes and removes the limits of the volumes that are not in
+// the policy any more. Only the new volumes are set if the policy is not changed since it's applied.
+func (r *CurveQoSPolicyReconciler) apply(policy *curvev1.CurveQoSPolicy) error {
+	clusterObj := &curvev1.CurveCluster{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: policy.Namespace, Name: policy.Spec.Cluster}, clusterObj)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get curvecluster %q", policy.Spec.Cluster)
+	}
+	if clusterObj.Status.Phase != curvev1.ConditionTypeClusterReady {
+		return errors.Errorf("curvecluster %q is not ready", policy.Spec.Cluster)
+	}
+
+	password, err := r.getPassword(policy)
+	if err != nil {
+		return err
+	}
+	volumes := policy.Spec.Volumes
+	if len(volumes) == 0 {
+		volumes, err = tools.ListVolumes(&r.context, policy.Namespace, policy.Spec.User, password)
+		if err != nil {
+			return err
+		}
+	}
+
+	applied := map[string]bool{}
+	for _, volume := range policy.Status.AppliedVolumes {
+		applied[volume] = true
+	}
+	unchanged := policy.Status.Phase == curvev1.QoSPolicyPhaseApplied && policy.Status.ObservedGeneration == policy.Generation
+
+	var failures, succeeded []string
+	wanted := map[string]bool{}
+	for _, volume := range volumes {
+		wanted[volume] = true
+		if unchanged && applied[volume] {
+			succeeded = append(succeeded, volume)
+			continue
+		}
+		ok := true
+		for _, limit := range throttleLimits(policy.Spec.Limits) {
+			if err := tools.UpdateThrottle(&r.context, policy.Namespace, policy.Spec.User, password, volume, limit); err != nil {
+				failures = append(failures, err.Error())
+				ok = false
+				break
+			}
+		}
+		// the volume applied before is kept to remove its limits when it's removed
+		if ok || applied[volume] {
+			succeeded = append(succeeded, volume)
+		}
+	}
+
+	var removed []string
+	for _, volume := range policy.Status.AppliedVolumes {
+		if !wanted[volume] {
+			removed = append(removed, volume)
+		}
+	}
+	// the volumes failed to be removed are kept to be removed again
+	kept, removeFailures := r.removeThrottles(policy, password, removed)
+	succeeded = append(succeeded, kept...)
+	failures = append(failures, removeFailures...)
+
+	sort.Strings(succeeded)
+	policy.Status.AppliedVolumes = succeeded
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// removeThrottles removes all the limits of the volumes, and returns the volumes failed to be removed with
+// the failures
+func (r *CurveQoSPolicyReconciler) removeThrottles(policy *curvev1.CurveQoSPolicy, password string, volumes []string) ([]string, []string) {
+	var kept, failures []string
+	for _, volume := range volumes {
+		for _, limit := range throttleLimits(nil) {
+			if err := tools.UpdateThrottle(&r.context, policy.Namespace, policy.Spec.User, password, volume, limit); err != nil {
+				kept = append(kept, volume)
+				failures = append(failures, err.Error())
+				break
+			}
+		}
+	}
+	return kept, failures
+}
+
+// throttleLimits returns the limits of all the throttle types, the types not in the limits are set to 0 to remove
+// their limits
+func throttleLimits(limits []curvev1.ThrottleLimit) []curvev1.ThrottleLimit {
+	var all []curvev1.ThrottleLimit
+	for _, t := range throttleTypes {
+		limit := curvev1.ThrottleLimit{Type: t}
+		for _, l := range limits {
+			if l.Type == t {
+				limit = l
+			}
+		}
+		all = append(all, limit)
+	}
+	return all
+}
+
+// getPassword returns the password of the user in the secret, or empty if the secret is not set
+func (r *CurveQoSPolicyReconciler) getPassword(policy *curvev1.CurveQoSPolicy) (string, error) {
+	selector := policy.Spec.PasswordSecret
+	if selector == nil {
+		return "", nil
+	}
+	secret, err := r.context.Clientset.CoreV1().Secrets(policy.Namespace).Get(selector.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get secret %q of the password", selector.Name)
+	}
+	password, ok := secret.Data[selector.Key]
+	if !ok {
+		return "", errors.Errorf("key %q is not found in secret %q", selector.Key, selector.Name)
+	}
+	return strings.TrimSpace(string(password)), nil
+}
+
+func (r *CurveQoSPolicyReconciler) setStatus(policy *curvev1.CurveQoSPolicy, phase curvev1.QoSPolicyPhase, message string) error {
+	policy.Status.Phase = phase
+	policy.Status.Message = message
+	policy.Status.ObservedGeneration = policy.Generation
+	if err := r.Client.Status().Update(context.TODO(), policy); err != nil {
+		return errors.Wrapf(err, "failed to update status of curveqospolicy %q", fmt.Sprintf("%s/%s", policy.Namespace, policy.Name))
+	}
+	return nil
+}
+
+func (r *CurveQoSPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&curvev1.CurveQoSPolicy{}).
+		Complete(r)
+}
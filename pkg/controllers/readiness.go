@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver"
+	"github.com/opencurve/curve-operator/pkg/mds"
+)
+
+// setReadiness sets the numbers of the ready mds and chunkservers shown by 'kubectl get curve' in the status of
+// the cluster, it returns true if they changed
+func setReadiness(clientset kubernetes.Interface, clusterObj *curvev1.CurveCluster) (bool, error) {
+	mdsReady, err := daemonsReady(clientset, clusterObj.Namespace, mds.AppName)
+	if err != nil {
+		return false, err
+	}
+	chunkServersReady, err := daemonsReady(clientset, clusterObj.Namespace, chunkserver.AppName)
+	if err != nil {
+		return false, err
+	}
+	if clusterObj.Status.MdsReady == mdsReady && clusterObj.Status.ChunkServersReady == chunkServersReady {
+		return false, nil
+	}
+	clusterObj.Status.MdsReady = mdsReady
+	clusterObj.Status.ChunkServersReady = chunkServersReady
+	return true, nil
+}
+
+// daemonsReady returns the number of the deployments of the daemon that have a ready pod and all of them, such
+// as '3/3'. It's empty if the daemon has not been deployed.
+func daemonsReady(clientset kubernetes.Interface, namespace, appName string) (string, error) {
+	deployments, err := clientset.AppsV1().Deployments(namespace).List(metav1.ListOptions{LabelSelector: "app=" + appName})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to list deployments of %s", appName)
+	}
+	if len(deployments.Items) == 0 {
+		return "", nil
+	}
+	ready := 0
+	for _, d := range deployments.Items {
+		if d.Status.ReadyReplicas > 0 {
+			ready++
+		}
+	}
+	return fmt.Sprintf("%d/%d", ready, len(deployments.Items)), nil
+}
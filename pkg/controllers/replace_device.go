@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// reconcileReplaceDevice sets the chunkserver of the device in replace-device annotation offline,
+// so that its copysets are migrated to other chunkservers before the device is replaced
+func (r *CurveClusterReconciler) reconcileReplaceDevice(clusterObj *curvev1.CurveCluster) error {
+	value, ok := clusterObj.Annotations[curvev1.ReplaceDeviceAnnotation]
+	if !ok || value == "" {
+		return nil
+	}
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return errors.Errorf("invalid annotation %s=%q, it should be <node>:<device>", curvev1.ReplaceDeviceAnnotation, value)
+	}
+	nodeName, deviceName := parts[0], parts[1]
+
+	name := chunkserver.DeploymentName(nodeName, deviceName)
+	for _, cs := range clusterObj.Status.ChunkServers {
+		if cs.Name == name && cs.State == curvev1.ChunkServerStateReplacing {
+			return nil
+		}
+	}
+	if !disruptionAllowed(clusterObj.Spec, fmt.Sprintf("migration of the copysets of chunkserver %q to replace the device", name)) {
+		return nil
+	}
+
+	clientset := r.ClusterController.context.Clientset
+	d, err := clientset.AppsV1().Deployments(clusterObj.Namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return errors.Errorf("no chunkserver for device %q on node %q", deviceName, nodeName)
+		}
+		return errors.Wrapf(err, "failed to get chunkserver deployment %q", name)
+	}
+	var ports []int
+	for _, p := range d.Spec.Template.Spec.Containers[0].Ports {
+		ports = append(ports, int(p.ContainerPort))
+	}
+
+	node, err := clientset.CoreV1().Nodes().Get(nodeName, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get node %q", nodeName)
+	}
+	nodeIP, err := k8sutil.NodeAddress(node, &clusterObj.Spec.Network)
+	if err != nil {
+		return err
+	}
+
+	ownerInfo := k8sutil.NewOwnerInfo(clusterObj, r.Scheme)
+	namespacedName := types.NamespacedName{Namespace: clusterObj.Namespace, Name: clusterObj.Name}
+	dataDir, logDir, confDir := config.HostDaemonDirs(clusterObj.Spec)
+	chunkservers := chunkserver.New(r.ClusterController.context, namespacedName, *clusterObj.Spec, ownerInfo, dataDir, logDir, confDir)
+	job, err := chunkservers.RunOfflineJob(nodeName, nodeIP, ports)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set chunkserver %q offline", name)
+	}
+
+	msg := fmt.Sprintf("device %s is to be replaced, set offline by job %s", deviceName, job.Name)
+	setChunkServersState(clusterObj, nodeName, []string{name}, curvev1.ChunkServerStateReplacing, msg)
+	logger.Infof("chunkserver %q of cluster %q is offline because %s", name, clusterObj.Name, msg)
+
+	return k8sutil.UpdateStatus(r.Client, namespacedName, clusterObj)
+}
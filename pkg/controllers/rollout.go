@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// rolloutPause is returned by the roll that waits for the pause between the batches of chunkservers or the soak of
+// the canary chunkservers, the cluster is requeued after the duration to go on with the roll instead of holding the
+// worker
+type rolloutPause struct {
+	after   time.Duration
+	message string
+}
+
+func (p *rolloutPause) Error() string {
+	return p.message
+}
+
+// rollDaemon updates all deployments of the daemon by update and waits for them to restart,
+// chunkservers are rolled by spec.updateStrategy and the other daemons are rolled one by one
+func (c *cluster) rollDaemon(appName string, update func(d *appsv1.Deployment) error) error {
+	deployments, err := c.context.Clientset.AppsV1().Deployments(c.NameSpace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=%s", appName),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to list %s deployments", appName)
+	}
+	return c.rollDeployments(appName, deployments.Items, update)
+}
+
+// rollDeployments updates the deployments of the daemon by update and waits for them to restart in the way of
+// rollDaemon. The chunkservers that are updated already are skipped, so a rolloutPause is returned to wait for
+// updateStrategy.pauseBetweenPods before the next batch and the roll goes on from it when the cluster is requeued.
+func (c *cluster) rollDeployments(appName string, deployments []appsv1.Deployment, update func(d *appsv1.Deployment) error) error {
+	var batches [][]appsv1.Deployment
+	var pause time.Duration
+	var chunkservers *chunkserver.Cluster
+	if appName == chunkserver.AppName {
+		pending, err := c.pendingDeployments(deployments, update)
+		if err != nil {
+			return err
+		}
+		batches = chunkserver.UpdateBatches(pending, c.Spec.UpdateStrategy)
+		pause = c.Spec.UpdateStrategy.PauseBetweenPods.Duration
+		chunkservers = chunkserver.New(c.context, c.NamespacedName, *c.Spec, c.ownerInfo, c.dataDirHostPath, c.logDirHostPath, c.confDirHostPath)
+	} else {
+		for _, d := range deployments {
+			batches = append(batches, []appsv1.Deployment{d})
+		}
+	}
+
+	for i, batch := range batches {
+		if pause > 0 {
+			wait, err := c.untilNextBatch(pause)
+			if err != nil {
+				return err
+			}
+			if wait > 0 {
+				return &rolloutPause{after: wait, message: fmt.Sprintf("pausing %s before restarting next batch of %s, %d batches left",
+					wait.Round(time.Second), appName, len(batches)-i)}
+			}
+		}
+
+		if chunkservers != nil {
+			chunkservers.TransferLeaders(batch)
+		}
+		var updated []*appsv1.Deployment
+		for j := range batch {
+			d := &batch[j]
+			// the pending chunkservers are updated already
+			if chunkservers == nil {
+				if err := update(d); err != nil {
+					return errors.Wrapf(err, "failed to update deployment %q", d.Name)
+				}
+				// a change of the mounted config such as etcd.conf restarts the pods too
+				if err := k8sutil.SetConfigHash(c.context.Clientset, c.NameSpace, &d.Spec.Template); err != nil {
+					return err
+				}
+			}
+			newDeployment, err := c.context.Clientset.AppsV1().Deployments(c.NameSpace).Update(d)
+			if err != nil {
+				return errors.Wrapf(err, "failed to update deployment %q", d.Name)
+			}
+			updated = append(updated, newDeployment)
+		}
+		if err := k8sutil.WaitForDeploymentsToStart(c.context.Clientset, 3*time.Second, k8sutil.UpdateTimeout, updated); err != nil {
+			return errors.Wrapf(err, "batch %d of %s is not restarted", i, appName)
+		}
+		logger.Infof("batch %d/%d of %s restarted", i+1, len(batches), appName)
+		if pause > 0 {
+			if err := c.recordBatchTime(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// pendingDeployments returns the deployments updated by update that are changed by it, the others are rolled
+// already
+func (c *cluster) pendingDeployments(deployments []appsv1.Deployment, update func(d *appsv1.Deployment) error) ([]appsv1.Deployment, error) {
+	var pending []appsv1.Deployment
+	for i := range deployments {
+		d := deployments[i].DeepCopy()
+		if err := update(d); err != nil {
+			return nil, errors.Wrapf(err, "failed to update deployment %q", d.Name)
+		}
+		// a change of the mounted config such as etcd.conf restarts the pods too
+		if err := k8sutil.SetConfigHash(c.context.Clientset, c.NameSpace, &d.Spec.Template); err != nil {
+			return nil, err
+		}
+		if !equality.Semantic.DeepEqual(d.Spec, deployments[i].Spec) {
+			pending = append(pending, *d)
+		}
+	}
+	return pending, nil
+}
+
+// untilNextBatch returns how long to wait before the next batch of chunkservers is restarted by the pause after
+// the last batch
+func (c *cluster) untilNextBatch(pause time.Duration) (time.Duration, error) {
+	clusterObj := &curvev1.CurveCluster{}
+	if err := c.context.Client.Get(context.TODO(), c.NamespacedName, clusterObj); err != nil {
+		return 0, errors.Wrapf(err, "failed to get curvecluster %q", c.NamespacedName)
+	}
+	if clusterObj.Status.Rollout == nil {
+		return 0, nil
+	}
+	return time.Until(clusterObj.Status.Rollout.LastBatchTime.Add(pause)), nil
+}
+
+// recordBatchTime records the time that the last batch of chunkservers restarted in the rollout status
+func (c *cluster) recordBatchTime() error {
+	clusterObj := &curvev1.CurveCluster{}
+	if err := c.context.Client.Get(context.TODO(), c.NamespacedName, clusterObj); err != nil {
+		return errors.Wrapf(err, "failed to get curvecluster %q", c.NamespacedName)
+	}
+	clusterObj.Status.Rollout = &curvev1.RolloutStatus{LastBatchTime: metav1.Now()}
+	if err := k8sutil.UpdateStatus(c.context.Client, c.NamespacedName, clusterObj); err != nil {
+		return errors.Wrap(err, "failed to record the time of the last batch of chunkservers")
+	}
+	return nil
+}
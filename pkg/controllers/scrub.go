@@ -0,0 +1,11 @@
+package controllers
+
+import (
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/tools"
+)
+
+// reconcileScrub creates or deletes the cron job that scrubs the copysets in the maintenance windows
+func (c *cluster) reconcileScrub(spec *curvev1.CurveClusterSpec) error {
+	return tools.New(c.context, c.NamespacedName, *spec, c.ownerInfo).ReconcileScrub()
+}
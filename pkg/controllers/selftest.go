@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+	"github.com/opencurve/curve-operator/pkg/tools"
+)
+
+var (
+	selfTestPassed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "curve_selftest_passed",
+		Help: "Whether the last self test of curve cluster passed, 1 for passed and 0 for failed",
+	}, []string{"namespace", "cluster"})
+	selfTestLastRunTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "curve_selftest_last_run_timestamp_seconds",
+		Help: "Unix time that the last self test of curve cluster finished",
+	}, []string{"namespace", "cluster"})
+	selfTestDurationSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "curve_selftest_duration_seconds",
+		Help: "Seconds that the last self test of curve cluster took",
+	}, []string{"namespace", "cluster"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(selfTestPassed, selfTestLastRunTimestamp, selfTestDurationSeconds)
+}
+
+// reconcileSelfTest creates or deletes the cron job of the self test, the test snapshots the volume by the
+// snapshotclone of the cluster if it's enabled
+func (c *cluster) reconcileSelfTest(spec *curvev1.CurveClusterSpec) error {
+	snapshotAddr := ""
+	if spec.Maintenance.SelfTest.Enable && spec.SnapShotClone.Enable {
+		clusterInfo, err := config.GetClusterInfo(&c.context, c.NameSpace)
+		if err != nil {
+			return err
+		}
+		snapshotAddr = clusterInfo.SnapShotCloneAddr
+	}
+	if !spec.Maintenance.SelfTest.Enable {
+		deleteSelfTestMetrics(c.NamespacedName)
+	}
+	return tools.New(c.context, c.NamespacedName, *spec, c.ownerInfo).ReconcileSelfTest(snapshotAddr)
+}
+
+// SelfTestReconciler watches the finished self test jobs and records their results in the cluster status and
+// the metrics of operator
+type SelfTestReconciler struct {
+	Client   client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	context clusterd.Context
+}
+
+func NewSelfTestReconciler(
+	client client.Client,
+	log logr.Logger,
+	scheme *runtime.Scheme,
+	recorder record.EventRecorder,
+	context clusterd.Context,
+) *SelfTestReconciler {
+	context.Client = client
+
+	return &SelfTestReconciler{
+		Client:   client,
+		Log:      log,
+		Scheme:   scheme,
+		Recorder: recorder,
+		context:  context,
+	}
+}
+
+func (r *SelfTestReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("job", req.NamespacedName)
+
+	job := &batch.Job{}
+	err := r.Client.Get(ctx, req.NamespacedName, job)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			log.Info("job not found, ignoring since it must be deleted")
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, errors.Wrapf(err, "failed to get job %q", req.Name)
+	}
+	finishTime := jobFinishTime(job)
+	if finishTime == nil {
+		return reconcile.Result{}, nil
+	}
+
+	clusters := &curvev1.CurveClusterList{}
+	if err := r.Client.List(ctx, clusters, client.InNamespace(job.Namespace)); err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "failed to list curveclusters")
+	}
+	var clusterObj *curvev1.CurveCluster
+	for i := range clusters.Items {
+		if clusters.Items[i].Spec != nil && clusters.Items[i].GetDeletionTimestamp().IsZero() {
+			clusterObj = &clusters.Items[i]
+		}
+	}
+	if clusterObj == nil {
+		return reconcile.Result{}, nil
+	}
+	last := clusterObj.Status.SelfTest
+	// the job was recorded, or it's an older one listed again such as when the operator restarts
+	if last != nil && !last.LastRunTime.Before(finishTime) {
+		return reconcile.Result{}, nil
+	}
+
+	message, err := k8sutil.GetJobTerminationMessage(r.context.Clientset, job)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	result, resultMessage := tools.ParseSelfTestResult(message)
+	if job.Status.Succeeded == 0 && result == curvev1.SelfTestPassed {
+		// the pod passed the test but the job failed, such as by its deadline
+		result, resultMessage = curvev1.SelfTestFailed, "the job of the test failed"
+	}
+
+	status := &curvev1.SelfTestStatus{
+		Result:      result,
+		Message:     resultMessage,
+		LastRunTime: *finishTime,
+	}
+	if job.Status.StartTime != nil {
+		status.DurationSeconds = int64(finishTime.Sub(job.Status.StartTime.Time).Seconds())
+	}
+	if result == curvev1.SelfTestFailed {
+		status.ConsecutiveFailures = 1
+		if last != nil {
+			status.ConsecutiveFailures = last.ConsecutiveFailures + 1
+		}
+		logger.Warningf("self test of cluster %q failed. %s", clusterObj.Name, resultMessage)
+		r.Recorder.Eventf(clusterObj, v1.EventTypeWarning, "SelfTestFailed", "self test failed: %s", resultMessage)
+	} else if last != nil && last.Result == curvev1.SelfTestFailed {
+		r.Recorder.Eventf(clusterObj, v1.EventTypeNormal, "SelfTestPassed", "self test passed again after %d failures", last.ConsecutiveFailures)
+	}
+
+	namespacedName := types.NamespacedName{Namespace: clusterObj.Namespace, Name: clusterObj.Name}
+	setSelfTestMetrics(namespacedName, status)
+	clusterObj.Status.SelfTest = status
+	return reconcile.Result{}, k8sutil.UpdateStatus(r.Client, namespacedName, clusterObj)
+}
+
+// jobFinishTime returns the time that the job succeeded or failed, or nil if it's still running
+func jobFinishTime(job *batch.Job) *metav1.Time {
+	if job.Status.CompletionTime != nil {
+		return job.Status.CompletionTime
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batch.JobFailed && condition.Status == v1.ConditionTrue {
+			return &condition.LastTransitionTime
+		}
+	}
+	return nil
+}
+
+func setSelfTestMetrics(namespacedName types.NamespacedName, status *curvev1.SelfTestStatus) {
+	passed := 0.0
+	if status.Result == curvev1.SelfTestPassed {
+		passed = 1
+	}
+	selfTestPassed.WithLabelValues(namespacedName.Namespace, namespacedName.Name).Set(passed)
+	selfTestLastRunTimestamp.WithLabelValues(namespacedName.Namespace, namespacedName.Name).Set(float64(status.LastRunTime.Unix()))
+	selfTestDurationSeconds.WithLabelValues(namespacedName.Namespace, namespacedName.Name).Set(float64(status.DurationSeconds))
+}
+
+func deleteSelfTestMetrics(namespacedName types.NamespacedName) {
+	selfTestPassed.DeleteLabelValues(namespacedName.Namespace, namespacedName.Name)
+	selfTestLastRunTimestamp.DeleteLabelValues(namespacedName.Namespace, namespacedName.Name)
+	selfTestDurationSeconds.DeleteLabelValues(namespacedName.Namespace, namespacedName.Name)
+}
+
+// isSelfTestJob returns true if the object is a self test job
+func isSelfTestJob(meta metav1.Object) bool {
+	return meta.GetLabels()["app"] == tools.SelfTestAppName
+}
+
+func (r *SelfTestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&batch.Job{}).
+		Named("selftest").
+		WithEventFilter(predicate.Funcs{
+			CreateFunc:  func(e event.CreateEvent) bool { return isSelfTestJob(e.Meta) },
+			UpdateFunc:  func(e event.UpdateEvent) bool { return isSelfTestJob(e.MetaNew) },
+			DeleteFunc:  func(e event.DeleteEvent) bool { return false },
+			GenericFunc: func(e event.GenericEvent) bool { return isSelfTestJob(e.Meta) },
+		}).
+		Complete(r)
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// shrinkCheckInterval is how often the retiring chunkserver is checked
+const shrinkCheckInterval = 30 * time.Second
+
+// reconcileShrink retires the chunkservers of the devices removed from the device list of their nodes one by one.
+// The chunkserver is set pendding to migrate its copysets, then its deployment and configmap are deleted after
+// the migration finished. It returns true if the cluster should be reconciled again to check the migration.
+func (r *CurveClusterReconciler) reconcileShrink(clusterObj *curvev1.CurveCluster) (bool, error) {
+	clientset := r.ClusterController.context.Clientset
+
+	// the storage nodes resolved by node selector are not written back to the object
+	spec := clusterObj.Spec.DeepCopy()
+	if err := resolveStorageNodes(clientset, clusterObj.Namespace, spec); err != nil {
+		return false, err
+	}
+
+	ownerInfo := k8sutil.NewOwnerInfo(clusterObj, r.Scheme)
+	namespacedName := types.NamespacedName{Namespace: clusterObj.Namespace, Name: clusterObj.Name}
+	dataDir, logDir, confDir := config.HostDaemonDirs(spec)
+	chunkservers := chunkserver.New(r.ClusterController.context, namespacedName, *spec, ownerInfo, dataDir, logDir, confDir)
+	removed, err := chunkservers.RemovedDevices()
+	if err != nil {
+		return false, err
+	}
+	if len(removed) == 0 {
+		setDestructiveBlocked(&r.ClusterController.context, clusterObj, false, "no chunkserver is waiting to be retired")
+		return false, nil
+	}
+
+	record := removed[0]
+	name := record.ChunkServer
+	job, err := clientset.BatchV1().Jobs(clusterObj.Namespace).Get(chunkserver.RetireJobName(record.NodeName, record.DeviceName), metav1.GetOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return false, errors.Wrapf(err, "failed to get retire job of chunkserver %q", name)
+	}
+
+	// 1. set the chunkserver pendding to migrate its copysets
+	if chunkServerState(clusterObj, name) != curvev1.ChunkServerStateRetiring || kerrors.IsNotFound(err) {
+		// the devices may be removed by an errant edit of the spec, the retirement removes the chunkserver from
+		// the pools and wipes the device if wipeRemovedDevices is set
+		if !destructiveConfirmed(clusterObj) {
+			msg := confirmationMessage(clusterObj, fmt.Sprintf("retirement of chunkserver %s of removed device %s on node %s",
+				name, record.DeviceName, record.NodeName))
+			if setDestructiveBlocked(&r.ClusterController.context, clusterObj, true, msg) {
+				logger.Warningf("cluster %q: %s", clusterObj.Name, msg)
+			}
+			return false, nil
+		}
+		// the warning is cleared first, the retirement is started by the next reconcile
+		if setDestructiveBlocked(&r.ClusterController.context, clusterObj, false,
+			fmt.Sprintf("retirement of chunkserver %s is confirmed", name)) {
+			return true, nil
+		}
+		if !disruptionAllowed(spec, fmt.Sprintf("migration of the copysets of chunkserver %q to retire it", name)) {
+			return false, nil
+		}
+		node, err := clientset.CoreV1().Nodes().Get(record.NodeName, metav1.GetOptions{})
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to get node %q", record.NodeName)
+		}
+		nodeIP, err := k8sutil.NodeAddress(node, &clusterObj.Spec.Network)
+		if err != nil {
+			return false, err
+		}
+
+		job, err := chunkservers.RunRetireJob(record, nodeIP)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to retire chunkserver %q", name)
+		}
+
+		msg := fmt.Sprintf("device %s is removed, its copysets are being migrated by job %s", record.DeviceName, job.Name)
+		setChunkServersState(clusterObj, record.NodeName, []string{name}, curvev1.ChunkServerStateRetiring, msg)
+		logger.Infof("chunkserver %q of cluster %q is retiring because %s", name, clusterObj.Name, msg)
+		return true, k8sutil.UpdateStatus(r.Client, namespacedName, clusterObj)
+	}
+
+	// 2. wait for the migration
+	if job.Status.Succeeded == 0 {
+		logger.Infof("waiting for the copysets of chunkserver %q to be migrated by job %s", name, job.Name)
+		return true, nil
+	}
+
+	// 3. remove the chunkserver
+	if err := chunkservers.RemoveChunkServer(record); err != nil {
+		return false, errors.Wrapf(err, "failed to remove chunkserver %q", name)
+	}
+	removeChunkServer(clusterObj, name)
+	logger.Infof("chunkserver %q of cluster %q has been retired and removed", name, clusterObj.Name)
+
+	return len(removed) > 1, k8sutil.UpdateStatus(r.Client, namespacedName, clusterObj)
+}
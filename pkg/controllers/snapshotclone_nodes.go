@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/snapshotclone"
+)
+
+// resolveSnapShotCloneNodes sets snapShotClone.nodes to the Ready and schedulable nodes matching
+// snapShotClone.nodeSelector. The nodes that run the snapshotclone servers are put first in the order of the
+// servers, so that a server is only moved if its node stops matching
+func resolveSnapShotCloneNodes(clientset kubernetes.Interface, namespace string, spec *curvev1.CurveClusterSpec) error {
+	if !spec.SnapShotClone.Enable || spec.SnapShotClone.NodeSelector == nil {
+		return nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(spec.SnapShotClone.NodeSelector)
+	if err != nil {
+		return errors.Wrap(err, "invalid snapshotclone node selector")
+	}
+	nodes, err := clientset.CoreV1().Nodes().List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return errors.Wrap(err, "failed to list snapshotclone nodes")
+	}
+
+	var matched []string
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if ready, _ := nodeReadyCondition(node); ready && !node.Spec.Unschedulable {
+			matched = append(matched, node.Name)
+		}
+	}
+	if len(matched) == 0 {
+		return errors.New("no Ready node matches snapshotclone node selector")
+	}
+	sort.Strings(matched)
+
+	deployments, err := clientset.AppsV1().Deployments(namespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=%s", snapshotclone.AppName),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to list snapshotclone deployments")
+	}
+	// the deployments are named by the index of the servers
+	sort.Slice(deployments.Items, func(i, j int) bool {
+		return deployments.Items[i].Name < deployments.Items[j].Name
+	})
+
+	var snapNodes []string
+	for _, d := range deployments.Items {
+		nodeName := d.Spec.Template.Spec.NodeName
+		if contains(matched, nodeName) && !contains(snapNodes, nodeName) {
+			snapNodes = append(snapNodes, nodeName)
+		}
+	}
+	for _, nodeName := range matched {
+		if !contains(snapNodes, nodeName) {
+			snapNodes = append(snapNodes, nodeName)
+		}
+	}
+	if !reflect.DeepEqual(snapNodes, spec.SnapShotClone.Nodes) {
+		logger.Infof("snapshotclone nodes of cluster in namespace %q resolved by node selector: %v", namespace, snapNodes)
+	}
+	spec.SnapShotClone.Nodes = snapNodes
+	return nil
+}
@@ -0,0 +1,266 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver"
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/curvetool"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+	"github.com/opencurve/curve-operator/pkg/mds"
+)
+
+// staleTopologyPollInterval is the interval to check the topology for the stale chunkservers and servers
+const staleTopologyPollInterval = 5 * time.Minute
+
+// the actions taken on a stale chunkserver shown in the status
+const (
+	staleActionRetired  = "Retired"
+	staleActionPendding = "Pendding"
+	staleActionBlocked  = "Blocked"
+	staleActionManual   = "Manual"
+)
+
+var staleTopologyEntries = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "curve_stale_topology_entries",
+	Help: "Number of the stale chunkservers and servers in the topology of curve cluster",
+}, []string{"namespace", "cluster", "kind"})
+
+func init() {
+	metrics.Registry.MustRegister(staleTopologyEntries)
+}
+
+// StaleTopologyReconciler checks the topology for the chunkservers and the servers left by the renamed or removed
+// nodes, such as the chunkservers that registered again with new ids after their nodes were renamed. After the
+// confirmation, the offline stale chunkservers without copysets are retired and the ones with copysets are set
+// pendding to migrate them. The online ones and the stale servers can't be removed by curve_ops_tool, they are
+// reported by the StaleTopology warning for manual action. The topology is polled, the cluster is only reconciled
+// again by the change of its spec.
+type StaleTopologyReconciler struct {
+	Client client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+
+	context clusterd.Context
+}
+
+func NewStaleTopologyReconciler(
+	client client.Client,
+	log logr.Logger,
+	scheme *runtime.Scheme,
+	context clusterd.Context,
+) *StaleTopologyReconciler {
+	context.Client = client
+
+	return &StaleTopologyReconciler{
+		Client:  client,
+		Log:     log,
+		Scheme:  scheme,
+		context: context,
+	}
+}
+
+func (r *StaleTopologyReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("curvecluster", req.NamespacedName)
+
+	clusterObj := &curvev1.CurveCluster{}
+	err := r.Client.Get(ctx, req.NamespacedName, clusterObj)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			r.deleteMetrics(req.NamespacedName)
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, errors.Wrapf(err, "failed to get curvecluster %q", req.NamespacedName)
+	}
+	if clusterObj.Spec == nil || !clusterObj.GetDeletionTimestamp().IsZero() {
+		r.deleteMetrics(req.NamespacedName)
+		return reconcile.Result{}, nil
+	}
+	// the chunkservers are registered during provisioning, the topology is checked after the cluster is ready
+	if clusterObj.Status.Phase != curvev1.ClusterPhaseReady {
+		return reconcile.Result{RequeueAfter: staleTopologyPollInterval}, nil
+	}
+
+	hostIPs, err := r.topologyHosts(clusterObj.Namespace)
+	if err != nil {
+		log.Info("failed to get the hosts of topology", "error", err.Error())
+		return reconcile.Result{RequeueAfter: staleTopologyPollInterval}, nil
+	}
+	clusterInfo, err := config.GetClusterInfo(&r.context, clusterObj.Namespace)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	tool := curvetool.New(curvetool.NewPodExecutor(&r.context, clusterObj.Namespace, fmt.Sprintf("app=%s", mds.AppName)),
+		"-mdsAddr="+clusterInfo.MdsAddr)
+	chunkservers, err := tool.ChunkServerList()
+	if err != nil {
+		log.Info("failed to list the chunkservers", "error", err.Error())
+		return reconcile.Result{RequeueAfter: staleTopologyPollInterval}, nil
+	}
+	servers, err := tool.ServerList()
+	if err != nil {
+		log.Info("failed to list the servers", "error", err.Error())
+		return reconcile.Result{RequeueAfter: staleTopologyPollInterval}, nil
+	}
+
+	staleChunkServers, staleServers := mds.FindStaleTopology(chunkservers, servers, hostIPs)
+	staleTopologyEntries.WithLabelValues(req.Namespace, req.Name, "chunkserver").Set(float64(len(staleChunkServers)))
+	staleTopologyEntries.WithLabelValues(req.Namespace, req.Name, "server").Set(float64(len(staleServers)))
+
+	status := &curvev1.StaleTopologyStatus{LastCheckTime: metav1.Now()}
+	var manual []string
+	for _, cs := range staleChunkServers {
+		action, err := r.remediate(tool, clusterObj, cs)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		address := fmt.Sprintf("%s:%d", cs.HostIP, cs.Port)
+		status.ChunkServers = append(status.ChunkServers, curvev1.StaleChunkServerStatus{
+			ID: cs.ID, Address: address, Reason: cs.Reason, Action: action,
+		})
+		switch action {
+		case staleActionManual:
+			manual = append(manual, fmt.Sprintf("chunkserver %d %s is online, stop it and retire it by curve_ops_tool", cs.ID, address))
+		case staleActionBlocked:
+			operation := fmt.Sprintf("retirement of stale chunkserver %d %s", cs.ID, address)
+			if cs.CopysetNum > 0 {
+				operation = fmt.Sprintf("migration of the %d copysets of stale chunkserver %d %s", cs.CopysetNum, cs.ID, address)
+			}
+			manual = append(manual, confirmationMessage(clusterObj, operation))
+		}
+	}
+	for _, server := range staleServers {
+		entry := fmt.Sprintf("%d %s %s", server.ID, server.HostName, server.InternalIP)
+		status.Servers = append(status.Servers, entry)
+		manual = append(manual, fmt.Sprintf("server %s is not in topology.json, remove it from the topology manually", entry))
+	}
+
+	if len(status.ChunkServers) == 0 && len(status.Servers) == 0 {
+		status = nil
+	}
+	if !staleTopologyChanged(clusterObj.Status.StaleTopology, status) {
+		return reconcile.Result{RequeueAfter: staleTopologyPollInterval}, nil
+	}
+	clusterObj.Status.StaleTopology = status
+	if err := k8sutil.UpdateStatus(r.Client, req.NamespacedName, clusterObj); err != nil {
+		return reconcile.Result{}, err
+	}
+	if len(manual) > 0 {
+		msg := strings.Join(manual, "; ")
+		logger.Warningf("cluster %q: stale topology needs manual action: %s", clusterObj.Name, msg)
+		k8sutil.SetWarning(ctx, &r.context, req.NamespacedName, curvev1.ConditionTypeStaleTopology, true,
+			curvev1.ConditionManualActionRequiredReason, msg)
+	} else {
+		k8sutil.SetWarning(ctx, &r.context, req.NamespacedName, curvev1.ConditionTypeStaleTopology, false,
+			curvev1.ConditionStaleTopologyRemovedReason, "no stale topology needs manual action")
+	}
+
+	return reconcile.Result{RequeueAfter: staleTopologyPollInterval}, nil
+}
+
+// remediate retires the offline stale chunkserver if it has no copyset, or sets it pendding to migrate its copysets
+// if the maintenance window is open. Both are done only if it's confirmed, as a chunkserver is taken as stale by
+// topology.json that may be wrong. It returns the action taken on the chunkserver.
+func (r *StaleTopologyReconciler) remediate(tool *curvetool.Tool, clusterObj *curvev1.CurveCluster, cs mds.StaleChunkServer) (string, error) {
+	// an online chunkserver is still served by a daemon, such as a chunkserver on a node whose ip is changed
+	if cs.OnlineState == curvetool.ChunkServerOnline {
+		return staleActionManual, nil
+	}
+	// the pendding chunkserver was confirmed to migrate its copysets, it's retired when they are all migrated
+	pendding := cs.RWStatus == curvetool.ChunkServerPendding
+	if !pendding && !destructiveConfirmed(clusterObj) {
+		return staleActionBlocked, nil
+	}
+	if cs.CopysetNum == 0 {
+		if err := tool.SetChunkServerStatus(cs.ID, strings.ToLower(curvetool.ChunkServerRetired)); err != nil {
+			return "", err
+		}
+		logger.Infof("cluster %q: retired stale chunkserver %d %s:%d (%s)", clusterObj.Name, cs.ID, cs.HostIP, cs.Port, cs.Reason)
+		return staleActionRetired, nil
+	}
+	if pendding {
+		return staleActionPendding, nil
+	}
+	if !disruptionAllowed(clusterObj.Spec, fmt.Sprintf("migration of the copysets of stale chunkserver %d", cs.ID)) {
+		return staleActionBlocked, nil
+	}
+	if err := tool.SetChunkServerStatus(cs.ID, strings.ToLower(curvetool.ChunkServerPendding)); err != nil {
+		return "", err
+	}
+	logger.Infof("cluster %q: set stale chunkserver %d %s:%d (%s) pendding to migrate its %d copysets",
+		clusterObj.Name, cs.ID, cs.HostIP, cs.Port, cs.Reason, cs.CopysetNum)
+	return staleActionPendding, nil
+}
+
+// topologyHosts returns the internal ips of the servers of topology.json, they are the hosts that the chunkservers
+// are expected on
+func (r *StaleTopologyReconciler) topologyHosts(namespace string) (map[string]bool, error) {
+	cm, err := r.context.Clientset.CoreV1().ConfigMaps(namespace).Get(config.TopoJsonConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get configmap %q", config.TopoJsonConfigMapName)
+	}
+	topology := &chunkserver.CurveClusterTopo{}
+	if err := json.Unmarshal([]byte(cm.Data[config.TopoJsonConfigmapDataKey]), topology); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse configmap %q", config.TopoJsonConfigMapName)
+	}
+	// all the chunkservers would be stale by an empty topology
+	if len(topology.Servers) == 0 {
+		return nil, errors.Errorf("no server in configmap %q", config.TopoJsonConfigMapName)
+	}
+	hostIPs := map[string]bool{}
+	for _, server := range topology.Servers {
+		hostIPs[server.InternalIp] = true
+	}
+	return hostIPs, nil
+}
+
+func (r *StaleTopologyReconciler) deleteMetrics(namespacedName types.NamespacedName) {
+	staleTopologyEntries.DeleteLabelValues(namespacedName.Namespace, namespacedName.Name, "chunkserver")
+	staleTopologyEntries.DeleteLabelValues(namespacedName.Namespace, namespacedName.Name, "server")
+}
+
+// staleTopologyChanged returns true if the stale chunkservers, their actions or the stale servers changed. The
+// status is not updated on every poll because the update of status triggers the reconcile of cluster
+func staleTopologyChanged(old, new *curvev1.StaleTopologyStatus) bool {
+	if old == nil || new == nil {
+		return old != new
+	}
+	if len(old.ChunkServers) != len(new.ChunkServers) || strings.Join(old.Servers, "\n") != strings.Join(new.Servers, "\n") {
+		return true
+	}
+	for i := range old.ChunkServers {
+		if old.ChunkServers[i] != new.ChunkServers[i] {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *StaleTopologyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&curvev1.CurveCluster{}).
+		Named("staletopology").
+		// the updates of status and annotations are picked up by the next poll
+		WithEventFilter(predicate.GenerationChangedPredicate{}).
+		Complete(r)
+}
@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// resolveStorageNodes sets storage.nodes to the Ready and schedulable nodes matching storage.nodeSelector.
+// The nodes that stop matching but still run chunkservers are kept, as the copysets on them must be
+// migrated before the chunkservers are removed
+func resolveStorageNodes(clientset kubernetes.Interface, namespace string, spec *curvev1.CurveClusterSpec) error {
+	if spec.Storage.NodeSelector == nil || spec.Storage.UseSelectedNodes {
+		return nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(spec.Storage.NodeSelector)
+	if err != nil {
+		return errors.Wrap(err, "invalid storage node selector")
+	}
+	nodes, err := clientset.CoreV1().Nodes().List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return errors.Wrap(err, "failed to list storage nodes")
+	}
+
+	matched := map[string]bool{}
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if ready, _ := nodeReadyCondition(node); ready && !node.Spec.Unschedulable {
+			matched[node.Name] = true
+		}
+	}
+
+	deployments, err := clientset.AppsV1().Deployments(namespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=%s", chunkserver.AppName),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to list chunkserver deployments")
+	}
+	for _, d := range deployments.Items {
+		nodeName := d.Spec.Template.Spec.NodeName
+		if nodeName != "" && !matched[nodeName] {
+			logger.Warningf("node %q does not match storage node selector but runs chunkserver %q, remove the chunkserver manually after its copysets migrated", nodeName, d.Name)
+			matched[nodeName] = true
+		}
+	}
+
+	storageNodes := make([]string, 0, len(matched))
+	for nodeName := range matched {
+		storageNodes = append(storageNodes, nodeName)
+	}
+	sort.Strings(storageNodes)
+	if !reflect.DeepEqual(storageNodes, spec.Storage.Nodes) {
+		logger.Infof("storage nodes of cluster in namespace %q resolved by node selector: %v", namespace, storageNodes)
+	}
+	spec.Storage.Nodes = storageNodes
+	return nil
+}
+
+// storageNodesHandler enqueues the clusters that select storage or snapshotclone nodes by labels, or wait for the
+// node to join as a pending storage node, when the labels or the ready condition of a node changed
+func storageNodesHandler(c client.Client) handler.EventHandler {
+	enqueue := func(q workqueue.RateLimitingInterface, nodeName string) {
+		clusters := &curvev1.CurveClusterList{}
+		if err := c.List(context.Background(), clusters); err != nil {
+			logger.Errorf("failed to list curveclusters for node change. %v", err)
+			return
+		}
+		for _, clusterObj := range clusters.Items {
+			if clusterObj.Spec != nil && (clusterObj.Spec.Storage.NodeSelector != nil || clusterObj.Spec.SnapShotClone.NodeSelector != nil ||
+				contains(clusterObj.Status.PendingStorageNodes, nodeName)) {
+				q.Add(reconcile.Request{NamespacedName: types.NamespacedName{Namespace: clusterObj.Namespace, Name: clusterObj.Name}})
+			}
+		}
+	}
+
+	return handler.Funcs{
+		CreateFunc: func(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+			enqueue(q, e.Meta.GetName())
+		},
+		UpdateFunc: func(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+			oldNode, ok := e.ObjectOld.(*v1.Node)
+			if !ok {
+				return
+			}
+			newNode, ok := e.ObjectNew.(*v1.Node)
+			if !ok {
+				return
+			}
+			oldReady, _ := nodeReadyCondition(oldNode)
+			newReady, _ := nodeReadyCondition(newNode)
+			if oldReady != newReady || oldNode.Spec.Unschedulable != newNode.Spec.Unschedulable ||
+				!reflect.DeepEqual(oldNode.Labels, newNode.Labels) {
+				enqueue(q, newNode.Name)
+			}
+		},
+	}
+}
+
+// joinPendingNodes starts the chunkservers on the pending storage nodes that become Ready and schedulable, the
+// devices on them are formatted and the chunkservers join the pools
+func (c *cluster) joinPendingNodes(pending []string) error {
+	if len(pending) == 0 {
+		return nil
+	}
+	validNodes, err := k8sutil.GetValidNodes(c.context, pending)
+	if err != nil {
+		return err
+	}
+	if len(validNodes) == 0 {
+		return nil
+	}
+
+	var names []string
+	for _, node := range validNodes {
+		names = append(names, node.Name)
+	}
+	logger.Infof("pending storage nodes %v become ready, starting chunkservers on them", names)
+	nodeNameIP, err := k8sutil.GetNodeInfoMap(c.Spec, c.context.Clientset)
+	if err != nil {
+		return errors.Wrap(err, "failed get all nodes specified in spec nodes")
+	}
+	// the daemons after chunkserver are not started yet if the cluster was waiting for storage.minReadyNodes
+	started := false
+	for _, appName := range daemonStartOrder {
+		started = started || appName == chunkserver.AppName
+		if !started {
+			continue
+		}
+		if err := c.startDaemon(appName, nodeNameIP); err != nil {
+			return errors.Wrapf(err, "failed to start %s", appName)
+		}
+	}
+	return nil
+}
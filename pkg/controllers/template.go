@@ -129,6 +129,7 @@ func (c *cluster) createConfigMap(configMapName string, configMapDataKey string,
 		Data: configMapData,
 	}
 
+	k8sutil.InjectMetadata(*c.Spec, "", cm)
 	err := c.ownerInfo.SetControllerReference(cm)
 	if err != nil {
 		return errors.Wrapf(err, "failed to set owner reference to configmap %q", configMapName)
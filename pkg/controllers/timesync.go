@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+const (
+	TimeSyncAppName       = "curve-time-sync"
+	timeSyncJobNameFormat = "curve-time-sync-%s"
+	timeSyncTimeout       = 2 * time.Minute
+	timeSyncInterval      = 5 * time.Second
+
+	defaultMaxSkewMilliseconds = 500
+)
+
+// timeSyncScript reports the offset of the node clock to its NTP source in seconds by chrony or ntpd of the
+// node, 'unsynchronized' if the clock is not synchronized, or 'unknown' if neither of them is found
+const timeSyncScript = `
+report() {
+  echo "$@" | tee /dev/termination-log
+}
+
+if out=$(nsenter -t 1 -m -- chronyc -c tracking 2>/dev/null) && [ -n "$out" ]; then
+  # the 5th field is the offset of system time and the last one is the leap status
+  if [ "$(echo "$out" | awk -F, '{print $NF}')" == "Not synchronised" ]; then
+    report unsynchronized
+  else
+    report offset $(echo "$out" | awk -F, '{print $5}')
+  fi
+elif out=$(nsenter -t 1 -m -- ntpq -c "rv 0 offset" 2>/dev/null) && echo "$out" | grep -q "offset="; then
+  # ntpq prints the offset in milliseconds
+  report offset $(echo "$out" | sed -n 's/.*offset=\([-0-9.]*\).*/\1/p' | awk '{print $1 / 1000}')
+elif [ "$(nsenter -t 1 -m -- timedatectl show -p NTPSynchronized --value 2>/dev/null)" == "no" ]; then
+  report unsynchronized
+else
+  report unknown
+fi
+`
+
+// checkTimeSync runs a job on each node of the cluster to read its clock offset, and sets the ClockSkew
+// condition of the cluster. The cluster is created anyway, the condition is only a warning.
+func (c *ClusterController) checkTimeSync(cluster *cluster) {
+	resolved, err := k8sutil.ResolveNodeNames(c.context.Clientset, k8sutil.MergeNodeNames(cluster.Spec.DaemonNodes(), cluster.Spec.StorageNodes()))
+	if err != nil {
+		logger.Warningf("failed to resolve nodes to check time sync. %v", err)
+		return
+	}
+	nodes := map[string]bool{}
+	for _, nodeName := range resolved {
+		nodes[nodeName] = true
+	}
+
+	jobs := map[string]*batch.Job{}
+	for nodeName := range nodes {
+		job, err := cluster.makeTimeSyncJob(nodeName)
+		if err != nil {
+			logger.Warningf("failed to make time sync job on node %q. %v", nodeName, err)
+			continue
+		}
+		if err := k8sutil.RunReplaceableJob(context.TODO(), c.context.Clientset, job, true); err != nil {
+			logger.Warningf("failed to run time sync job %s. %v", job.Name, err)
+			continue
+		}
+		jobs[nodeName] = job
+	}
+
+	results := map[string]string{}
+	for nodeName := range nodes {
+		results[nodeName] = "unknown"
+		if job, ok := jobs[nodeName]; ok {
+			result, err := c.waitForTimeSyncJob(job)
+			if err != nil {
+				logger.Warningf("failed to check time sync on node %q. %v", nodeName, err)
+				continue
+			}
+			results[nodeName] = result
+		}
+	}
+
+	maxSkew := cluster.Spec.TimeSync.MaxSkewMilliseconds
+	if maxSkew == 0 {
+		maxSkew = defaultMaxSkewMilliseconds
+	}
+	skewed, message := clockSkew(results, time.Duration(maxSkew)*time.Millisecond)
+	reason := curvev1.ConditionClockSynchronizedReason
+	if skewed {
+		reason = curvev1.ConditionClockSkewedReason
+		logger.Warningf("clocks of the nodes of cluster %q are skewed, %s", cluster.NamespacedName, message)
+	}
+	k8sutil.SetWarning(context.TODO(), &c.context, cluster.NamespacedName, curvev1.ConditionTypeClockSkew, skewed, reason, message)
+}
+
+// clockSkew returns true with the reason if the skew between the clock offsets of the nodes exceeds maxSkew,
+// or some node is not synchronized or its offset is unknown
+func clockSkew(results map[string]string, maxSkew time.Duration) (bool, string) {
+	var unsynchronized, unknown []string
+	minNode, maxNode := "", ""
+	minOffset, maxOffset := math.Inf(1), math.Inf(-1)
+	for nodeName, result := range results {
+		fields := strings.Fields(result)
+		if len(fields) == 2 && fields[0] == "offset" {
+			if offset, err := strconv.ParseFloat(fields[1], 64); err == nil {
+				if offset < minOffset {
+					minNode, minOffset = nodeName, offset
+				}
+				if offset > maxOffset {
+					maxNode, maxOffset = nodeName, offset
+				}
+				continue
+			}
+		}
+		if result == "unsynchronized" {
+			unsynchronized = append(unsynchronized, nodeName)
+		} else {
+			unknown = append(unknown, nodeName)
+		}
+	}
+	sort.Strings(unsynchronized)
+	sort.Strings(unknown)
+
+	var problems []string
+	skew := time.Duration(0)
+	if minNode != "" {
+		skew = time.Duration((maxOffset - minOffset) * float64(time.Second))
+		if skew > maxSkew {
+			problems = append(problems, fmt.Sprintf("clock skew between nodes %s and %s is %s, more than %s",
+				maxNode, minNode, skew.Round(time.Millisecond), maxSkew))
+		}
+	}
+	if len(unsynchronized) > 0 {
+		problems = append(problems, fmt.Sprintf("clocks of nodes %s are not synchronized", strings.Join(unsynchronized, ", ")))
+	}
+	if len(unknown) > 0 {
+		problems = append(problems, fmt.Sprintf("clock offsets of nodes %s are unknown, chrony or ntpd is not found", strings.Join(unknown, ", ")))
+	}
+	if len(problems) > 0 {
+		return true, strings.Join(problems, "; ")
+	}
+	return false, fmt.Sprintf("clock skew between nodes is %s", skew.Round(time.Millisecond))
+}
+
+// waitForTimeSyncJob returns the result reported by the job
+func (c *ClusterController) waitForTimeSyncJob(job *batch.Job) (string, error) {
+	err := wait.PollImmediate(timeSyncInterval, timeSyncTimeout, func() (bool, error) {
+		j, err := c.context.Clientset.BatchV1().Jobs(job.Namespace).Get(job.Name, metav1.GetOptions{})
+		if err != nil {
+			logger.Warningf("failed to get time sync job %s. %v", job.Name, err)
+			return false, nil
+		}
+		return j.Status.Succeeded > 0 || j.Status.Failed > 0, nil
+	})
+	if err != nil {
+		return "", errors.Errorf("time sync job %s is not finished in %s", job.Name, timeSyncTimeout)
+	}
+
+	message, err := k8sutil.GetJobTerminationMessage(c.context.Clientset, job)
+	if err != nil {
+		return "", err
+	}
+	if message == "" {
+		return "", errors.Errorf("time sync job %s reported nothing, see the logs of its pod", job.Name)
+	}
+	return strings.TrimSpace(message), nil
+}
+
+func (c *cluster) makeTimeSyncJob(nodeName string) (*batch.Job, error) {
+	jobName := k8sutil.TruncateNodeNameForJob(timeSyncJobNameFormat, nodeName)
+	labels := map[string]string{
+		"app":           TimeSyncAppName,
+		"curve_cluster": c.NameSpace,
+	}
+	backoffLimit := int32(0)
+
+	podSpec := v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   jobName,
+			Labels: labels,
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:            "time-sync",
+					Command:         []string{"/bin/bash"},
+					Args:            []string{"-c", timeSyncScript},
+					Image:           c.Spec.CurveVersion.Image,
+					ImagePullPolicy: c.Spec.CurveVersion.ImagePullPolicy,
+					SecurityContext: k8sutil.PrivilegedContext(true),
+				},
+			},
+			NodeName:      nodeName,
+			RestartPolicy: v1.RestartPolicyNever,
+			HostPID:       true,
+		},
+	}
+	k8sutil.InjectEnv(&podSpec.Spec, *c.Spec, "")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, *c.Spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, *c.Spec)
+	k8sutil.InjectDNS(&podSpec.Spec, *c.Spec, "")
+
+	job := &batch.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jobName,
+			Namespace: c.NameSpace,
+			Labels:    labels,
+		},
+		Spec: batch.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template:     podSpec,
+		},
+	}
+
+	k8sutil.InjectMetadata(*c.Spec, "", job, &job.Spec.Template)
+	err := c.ownerInfo.SetControllerReference(job)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to set owner reference to time sync job %q", job.Name)
+	}
+
+	return job, nil
+}
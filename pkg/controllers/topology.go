@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/etcd"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+	"github.com/opencurve/curve-operator/pkg/mds"
+	"github.com/opencurve/curve-operator/pkg/snapshotclone"
+)
+
+const (
+	// TopologyExportConfigMapName is the configmap that the topology is exported to
+	TopologyExportConfigMapName = "curve-topology-export"
+
+	// maxTopologyExportSize keeps the export under the size limit of configmap, the rendered config that doesn't
+	// fit is omitted
+	maxTopologyExportSize = 900 * 1024
+)
+
+// topologyConfigMaps are the configmaps that reproduce the layout of the cluster, they are exported first and
+// never omitted
+var topologyConfigMaps = []string{
+	config.TopoJsonConfigMapName,
+	chunkserver.InventoryConfigMapName,
+	config.ClusterInfoConfigMapName,
+}
+
+// reconcileTopologyExport writes the topology.json, the chunkserver inventory, the cluster info and the configmaps
+// rendered for the running daemons into a configmap if the export-topology annotation is set to a new value. The
+// data of the configmaps are keyed by '<configmap>.<key>', the configmap can be saved by kubectl and created in
+// the namespace of another cluster for spec.topologyImport.
+func (r *CurveClusterReconciler) reconcileTopologyExport(clusterObj *curvev1.CurveCluster, ownerInfo *k8sutil.OwnerInfo) error {
+	request := clusterObj.Annotations[curvev1.ExportTopologyAnnotation]
+	if request == "" {
+		return nil
+	}
+	namespacedName := types.NamespacedName{Namespace: clusterObj.Namespace, Name: clusterObj.Name}
+	latest := &curvev1.CurveCluster{}
+	if err := r.Client.Get(context.TODO(), namespacedName, latest); err != nil {
+		return errors.Wrapf(err, "failed to get cluster %v", namespacedName)
+	}
+	if latest.Status.TopologyExport != nil && latest.Status.TopologyExport.Request == request {
+		return nil
+	}
+
+	clusterContext := r.ClusterController.context
+	pods, err := clusterContext.Clientset.CoreV1().Pods(clusterObj.Namespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app in (%s,%s,%s,%s),curve_cluster=%s",
+			etcd.AppName, mds.AppName, chunkserver.AppName, snapshotclone.AppName, clusterObj.Namespace),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to list the pods of daemons")
+	}
+	rendered := map[string]bool{}
+	for i := range pods.Items {
+		for _, f := range podConfigFiles(&pods.Items[i]) {
+			rendered[f.configMap] = true
+		}
+	}
+	for _, name := range topologyConfigMaps {
+		delete(rendered, name)
+	}
+	var names []string
+	for name := range rendered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	data := map[string]string{}
+	size := 0
+	var omitted []string
+	for i, name := range append(append([]string{}, topologyConfigMaps...), names...) {
+		cm, err := clusterContext.Clientset.CoreV1().ConfigMaps(clusterObj.Namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			// the topology.json is not created until the chunkservers are started
+			if kerrors.IsNotFound(err) {
+				continue
+			}
+			return errors.Wrapf(err, "failed to get configmap %q to export", name)
+		}
+		cmSize := 0
+		for _, value := range cm.Data {
+			cmSize += len(value)
+		}
+		if i >= len(topologyConfigMaps) && size+cmSize > maxTopologyExportSize {
+			omitted = append(omitted, name)
+			continue
+		}
+		for key, value := range cm.Data {
+			data[name+"."+key] = value
+		}
+		size += cmSize
+	}
+
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        TopologyExportConfigMapName,
+			Namespace:   clusterObj.Namespace,
+			Annotations: map[string]string{curvev1.ExportTopologyAnnotation: request},
+		},
+		Data: data,
+	}
+	k8sutil.InjectMetadata(*clusterObj.Spec, "", cm)
+	if err := ownerInfo.SetControllerReference(cm); err != nil {
+		return errors.Wrapf(err, "failed to set owner reference to configmap %q", cm.Name)
+	}
+	if err := k8sutil.Apply(clusterContext.Client, cm); err != nil {
+		return errors.Wrapf(err, "failed to write configmap %q", cm.Name)
+	}
+	logger.Infof("topology of cluster %q is exported to configmap %q, %d configmaps are omitted", clusterObj.Name, cm.Name, len(omitted))
+
+	latest.Status.TopologyExport = &curvev1.TopologyExportStatus{
+		Request:      request,
+		ConfigMap:    cm.Name,
+		ExportedTime: metav1.Now(),
+		Omitted:      omitted,
+	}
+	return k8sutil.UpdateStatus(r.Client, namespacedName, latest)
+}
+
+// reconcileTopologyImport reserves the bindings of the chunkservers in the exported topology of spec.topologyImport
+// before any device is formatted or adopted. It returns false if the topology is not imported, the reconcile is not
+// continued then. The import is not retried until spec.topologyImport changes unless the configmap is not found.
+// A cluster that has formatted devices keeps its layout, the import is reported in status and skipped.
+func (r *CurveClusterReconciler) reconcileTopologyImport(clusterObj *curvev1.CurveCluster) (bool, error) {
+	topologyImport := clusterObj.Spec.TopologyImport
+	if topologyImport == nil {
+		return true, nil
+	}
+	status := clusterObj.Status.TopologyImport
+	if status != nil && status.ConfigMap == topologyImport.ConfigMap && status.ImportedTime != nil {
+		return true, nil
+	}
+
+	clusterContext := r.ClusterController.context
+	namespacedName := types.NamespacedName{Namespace: clusterObj.Namespace, Name: clusterObj.Name}
+	ownerInfo := k8sutil.NewOwnerInfo(clusterObj, r.Scheme)
+	dataDir, logDir, confDir := config.HostDaemonDirs(clusterObj.Spec)
+	chunkservers := chunkserver.New(clusterContext, namespacedName, *clusterObj.Spec, ownerInfo, dataDir, logDir, confDir)
+	formatted, err := chunkservers.HasFormattedDevices()
+	if err != nil {
+		return false, err
+	}
+	if formatted {
+		message := "the topology is not imported since the cluster has formatted devices"
+		if status != nil && status.ConfigMap == topologyImport.ConfigMap && status.Message == message {
+			return true, nil
+		}
+		logger.Warningf("%s, cluster %q keeps its layout", message, clusterObj.Name)
+		return true, r.setTopologyImportStatus(clusterObj, &curvev1.TopologyImportStatus{ConfigMap: topologyImport.ConfigMap, Message: message})
+	}
+	if status != nil && status.ConfigMap == topologyImport.ConfigMap && status.Message != "" {
+		return false, nil
+	}
+
+	cm, err := clusterContext.Clientset.CoreV1().ConfigMaps(clusterObj.Namespace).Get(topologyImport.ConfigMap, metav1.GetOptions{})
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get configmap %q of the exported topology", topologyImport.ConfigMap)
+	}
+	data, ok := cm.Data[chunkserver.InventoryExportKey]
+	if !ok {
+		message := fmt.Sprintf("configmap %q has no key %s, it's not an exported topology", cm.Name, chunkserver.InventoryExportKey)
+		logger.Errorf("failed to import topology of cluster %q. %s", clusterObj.Name, message)
+		return false, r.setTopologyImportStatus(clusterObj, &curvev1.TopologyImportStatus{ConfigMap: cm.Name, Message: message})
+	}
+	imported, err := chunkservers.ImportInventory(data)
+	if err != nil {
+		logger.Errorf("failed to import topology of cluster %q. %v", clusterObj.Name, err)
+		return false, r.setTopologyImportStatus(clusterObj, &curvev1.TopologyImportStatus{ConfigMap: cm.Name, Message: err.Error()})
+	}
+
+	now := metav1.Now()
+	logger.Infof("the bindings of %d chunkservers are imported from configmap %q for cluster %q", imported, cm.Name, clusterObj.Name)
+	return true, r.setTopologyImportStatus(clusterObj, &curvev1.TopologyImportStatus{
+		ConfigMap:    cm.Name,
+		ImportedTime: &now,
+		ChunkServers: imported,
+	})
+}
+
+func (r *CurveClusterReconciler) setTopologyImportStatus(clusterObj *curvev1.CurveCluster, status *curvev1.TopologyImportStatus) error {
+	clusterObj.Status.TopologyImport = status
+	return k8sutil.UpdateStatus(r.Client, types.NamespacedName{Namespace: clusterObj.Namespace, Name: clusterObj.Name}, clusterObj)
+}
@@ -0,0 +1,330 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver"
+	"github.com/opencurve/curve-operator/pkg/etcd"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+	"github.com/opencurve/curve-operator/pkg/mds"
+	"github.com/opencurve/curve-operator/pkg/notify"
+	"github.com/opencurve/curve-operator/pkg/snapshotclone"
+	"github.com/opencurve/curve-operator/pkg/tools"
+	"github.com/opencurve/curve-operator/pkg/version"
+)
+
+const (
+	upgradeCheckTimeout  = 5 * time.Minute
+	upgradeCheckInterval = 5 * time.Second
+)
+
+// updateImage rolls all daemons to the new curve image in the order etcd, mds, chunkserver, snapshotclone and tools.
+// The upgrade is blocked if the upgrade check fails unless skipCheck is true, and paused if the canary chunkservers
+// degraded until the canary is disabled or the image is reverted, which rolls the canaries back. A rolloutPause is
+// returned while the canaries are soaking.
+func (c *cluster) updateImage(spec *curvev1.CurveClusterSpec, skipCheck bool) error {
+	oldImage, newImage := c.Spec.CurveVersion.Image, spec.CurveVersion.Image
+	upgrade, err := c.upgradeStatus()
+	if err != nil {
+		return err
+	}
+	var soakingImage string
+	if upgrade.Canary != nil {
+		soakingImage = upgrade.Canary.Image
+	}
+	if oldImage == newImage {
+		// the canaries of the paused upgrade or of the one being soaked are rolled back
+		rolledImage := c.pausedImage
+		if rolledImage == "" && soakingImage != oldImage {
+			rolledImage = soakingImage
+		}
+		if rolledImage != "" {
+			logger.Infof("rolling back the daemons of the upgrade from %q to %q", rolledImage, oldImage)
+			if _, err := c.rollImage(c.Spec.CurveVersion, []string{rolledImage}, false); err != nil {
+				return errors.Wrap(err, "failed to roll back the paused upgrade")
+			}
+			if err := c.setPausedImage(""); err != nil {
+				return err
+			}
+		}
+		// the blocked upgrade is reverted
+		if c.blockedImage != "" {
+			if err := c.setBlockedImage(""); err != nil {
+				return err
+			}
+			k8sutil.SetWarning(context.TODO(), &c.context, c.NamespacedName, curvev1.ConditionTypeUpgradeBlocked, false,
+				curvev1.ConditionUpgradeAllowedReason, fmt.Sprintf("curve image is %s", oldImage))
+		}
+		return nil
+	}
+	canary := c.Spec.UpdateStrategy.Canary.Enable
+	if canary && c.pausedImage == newImage {
+		return nil
+	}
+	if !disruptionAllowed(spec, fmt.Sprintf("upgrade to %q", newImage)) {
+		return nil
+	}
+	logger.Infof("curve image changed from %q to %q", oldImage, newImage)
+
+	if skipCheck {
+		logger.Warningf("upgrade check of cluster %q is skipped by annotation %q", c.NamespacedName, version.SkipVersionCheckAnnotation)
+	} else if c.allowedImage != newImage {
+		allowed, err := c.checkUpgrade(spec)
+		if err != nil || !allowed {
+			return err
+		}
+	}
+
+	// the daemons rolled to the image of the paused upgrade or the canaries of another image are rolled to the new
+	// image too
+	degradation, err := c.rollImage(spec.CurveVersion, []string{oldImage, c.pausedImage, soakingImage}, canary)
+	if err != nil {
+		return err
+	}
+	if degradation != "" {
+		if err := c.setPausedImage(newImage); err != nil {
+			return err
+		}
+		message := fmt.Sprintf("upgrade to %s is paused, %s. Disable updateStrategy.canary to continue or revert the image to roll back",
+			newImage, degradation)
+		logger.Errorf("upgrade of cluster %q is paused. %s", c.NamespacedName, message)
+		k8sutil.SetWarning(context.TODO(), &c.context, c.NamespacedName, curvev1.ConditionTypeUpgradeBlocked, true,
+			curvev1.ConditionCanaryDegradedReason, message)
+		return nil
+	}
+	if c.pausedImage != "" {
+		if err := c.setPausedImage(""); err != nil {
+			return err
+		}
+		k8sutil.SetWarning(context.TODO(), &c.context, c.NamespacedName, curvev1.ConditionTypeUpgradeBlocked, false,
+			curvev1.ConditionUpgradeAllowedReason, fmt.Sprintf("curve image is %s", newImage))
+	}
+	c.Spec.CurveVersion = spec.CurveVersion
+	if err := c.recordRolledImage(); err != nil {
+		return err
+	}
+	notify.Notify(c.context.Clientset, c.NamespacedName, spec, curvev1.NotificationEventUpgradeFinished,
+		fmt.Sprintf("curve image is upgraded from %s to %s", oldImage, newImage))
+	return nil
+}
+
+// rollImage rolls the containers of the old images of all daemons to the image of curveVersion. The chunkservers are
+// rolled by canary if it's true, the others are not rolled and the degradation of the canaries is returned if they
+// degraded.
+func (c *cluster) rollImage(curveVersion curvev1.CurveVersionSpec, oldImages []string, canary bool) (string, error) {
+	appNames := []string{etcd.AppName, mds.AppName, chunkserver.AppName}
+	if c.Spec.SnapShotClone.Enable {
+		appNames = append(appNames, snapshotclone.AppName)
+	}
+	if c.Spec.Tools.Enable {
+		appNames = append(appNames, tools.AppName)
+	}
+	update := func(d *appsv1.Deployment) error {
+		setImage(d, oldImages, curveVersion.Image)
+		return nil
+	}
+
+	for _, appName := range appNames {
+		var err error
+		switch {
+		case appName == etcd.AppName && c.Spec.Etcd.StatefulSet != nil:
+			// the etcd StatefulSet rolls its members one by one
+			newSpec := c.Spec.DeepCopy()
+			newSpec.CurveVersion = curveVersion
+			err = etcd.New(c.context, c.NamespacedName, *newSpec, c.ownerInfo, c.dataDirHostPath, c.logDirHostPath, c.confDirHostPath).Start(nil)
+		case appName == chunkserver.AppName && canary:
+			var degradation string
+			degradation, err = c.rollChunkserversByCanary(curveVersion.Image, update)
+			if err == nil && degradation != "" {
+				return degradation, nil
+			}
+		default:
+			err = c.rollDaemon(appName, update)
+		}
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to update image of %s", appName)
+		}
+	}
+	return "", nil
+}
+
+// setImage replaces the old curve images of the containers, other images of sidecars are kept
+func setImage(d *appsv1.Deployment, oldImages []string, newImage string) {
+	podSpec := &d.Spec.Template.Spec
+	for _, containers := range [][]v1.Container{podSpec.InitContainers, podSpec.Containers} {
+		for i := range containers {
+			for _, oldImage := range oldImages {
+				if oldImage != "" && containers[i].Image == oldImage {
+					containers[i].Image = newImage
+				}
+			}
+		}
+	}
+}
+
+// setBlockedImage sets the curve image that the cluster can't be upgraded to, it's kept in the upgrade status so that
+// the image is still blocked after the operator restarts
+func (c *cluster) setBlockedImage(image string) error {
+	if err := c.updateUpgradeStatus(func(status *curvev1.UpgradeStatus) {
+		status.BlockedImage = image
+	}); err != nil {
+		return errors.Wrap(err, "failed to record blocked image")
+	}
+	c.blockedImage = image
+	return nil
+}
+
+// setPausedImage sets the curve image that the upgrade to is paused, it's kept in the upgrade status so that the
+// upgrade is still paused after the operator restarts. The soak of the canaries is over by the pause or the
+// rollback. The canaries are rolled back to the old image by the cluster
+// initialized after the restart, and not rolled again until the canary is disabled or the image is reverted.
+func (c *cluster) setPausedImage(image string) error {
+	if err := c.updateUpgradeStatus(func(status *curvev1.UpgradeStatus) {
+		status.PausedImage = image
+		status.Canary = nil
+	}); err != nil {
+		return errors.Wrap(err, "failed to record paused image")
+	}
+	c.pausedImage = image
+	return nil
+}
+
+// recordRolledImage records the curve image of the spec as the image that all the daemons are rolled to, the cluster
+// initialized after the operator restarts deploys the daemons with it and rolls them to the image of the spec by
+// updateImage
+func (c *cluster) recordRolledImage() error {
+	if err := c.updateUpgradeStatus(func(status *curvev1.UpgradeStatus) {
+		status.Image = c.Spec.CurveVersion.Image
+		status.Canary = nil
+	}); err != nil {
+		return errors.Wrap(err, "failed to record rolled image")
+	}
+	return nil
+}
+
+// upgradeStatus returns the upgrade status of the cluster, it's empty if not recorded
+func (c *cluster) upgradeStatus() (*curvev1.UpgradeStatus, error) {
+	clusterObj := &curvev1.CurveCluster{}
+	if err := c.context.Client.Get(context.TODO(), c.NamespacedName, clusterObj); err != nil {
+		return nil, errors.Wrapf(err, "failed to get curvecluster %q", c.NamespacedName)
+	}
+	if clusterObj.Status.Upgrade == nil {
+		return &curvev1.UpgradeStatus{}, nil
+	}
+	return clusterObj.Status.Upgrade, nil
+}
+
+// updateUpgradeStatus updates the upgrade status of the cluster by update, the status is not written if unchanged
+func (c *cluster) updateUpgradeStatus(update func(*curvev1.UpgradeStatus)) error {
+	clusterObj := &curvev1.CurveCluster{}
+	if err := c.context.Client.Get(context.TODO(), c.NamespacedName, clusterObj); err != nil {
+		return errors.Wrapf(err, "failed to get curvecluster %q", c.NamespacedName)
+	}
+	status := clusterObj.Status.Upgrade.DeepCopy()
+	if status == nil {
+		status = &curvev1.UpgradeStatus{}
+	}
+	update(status)
+	if reflect.DeepEqual(status, clusterObj.Status.Upgrade) {
+		return nil
+	}
+	clusterObj.Status.Upgrade = status
+	return k8sutil.UpdateStatus(c.context.Client, c.NamespacedName, clusterObj)
+}
+
+// checkUpgrade runs the upgrade check job with the new image to get the versions of the running daemons, and returns
+// false if they can't be upgraded to the new image. The UpgradeBlocked condition of the cluster is set by the result,
+// an incompatible image is not checked again until the image is changed.
+func (c *cluster) checkUpgrade(spec *curvev1.CurveClusterSpec) (bool, error) {
+	newImage := spec.CurveVersion.Image
+	if c.blockedImage == newImage {
+		return false, nil
+	}
+
+	running, err := c.runUpgradeCheck(spec)
+	if err != nil {
+		k8sutil.SetWarning(context.TODO(), &c.context, c.NamespacedName, curvev1.ConditionTypeUpgradeBlocked, true,
+			curvev1.ConditionUpgradeCheckFailedReason, err.Error())
+		return false, errors.Wrap(err, "upgrade check failed")
+	}
+	// the versions are unknown if curve_ops_tool doesn't report them
+	if len(running) == 0 {
+		running = []string{version.CurveVersionFromImage(c.Spec.CurveVersion.Image)}
+	}
+
+	if err := version.CheckUpgrade(running, newImage); err != nil {
+		if err := c.setBlockedImage(newImage); err != nil {
+			return false, err
+		}
+		logger.Errorf("upgrade of cluster %q to %q is blocked. %v", c.NamespacedName, newImage, err)
+		k8sutil.SetWarning(context.TODO(), &c.context, c.NamespacedName, curvev1.ConditionTypeUpgradeBlocked, true,
+			curvev1.ConditionIncompatibleVersionReason, err.Error())
+		return false, nil
+	}
+
+	if err := c.setBlockedImage(""); err != nil {
+		return false, err
+	}
+	c.allowedImage = newImage
+	message := fmt.Sprintf("curve %s can be upgraded to %s", strings.Join(running, ", "), version.CurveVersionFromImage(newImage))
+	logger.Info(message)
+	k8sutil.SetWarning(context.TODO(), &c.context, c.NamespacedName, curvev1.ConditionTypeUpgradeBlocked, false,
+		curvev1.ConditionUpgradeAllowedReason, message)
+	return true, nil
+}
+
+// runUpgradeCheck runs the upgrade check job and returns the versions reported by it
+func (c *cluster) runUpgradeCheck(spec *curvev1.CurveClusterSpec) ([]string, error) {
+	newSpec := c.Spec.DeepCopy()
+	newSpec.CurveVersion = spec.CurveVersion
+	job, err := tools.New(c.context, c.NamespacedName, *newSpec, c.ownerInfo).MakeUpgradeCheckJob()
+	if err != nil {
+		return nil, err
+	}
+	if err := k8sutil.RunReplaceableJob(context.TODO(), c.context.Clientset, job, true); err != nil {
+		return nil, errors.Wrapf(err, "failed to run upgrade check job %s", job.Name)
+	}
+	logger.Infof("created upgrade check job %s with image %q", job.Name, spec.CurveVersion.Image)
+
+	var finished *batch.Job
+	err = wait.PollImmediate(upgradeCheckInterval, upgradeCheckTimeout, func() (bool, error) {
+		j, err := c.context.Clientset.BatchV1().Jobs(job.Namespace).Get(job.Name, metav1.GetOptions{})
+		if err != nil {
+			logger.Warningf("failed to get upgrade check job %s. %v", job.Name, err)
+			return false, nil
+		}
+		if j.Status.Succeeded > 0 || j.Status.Failed > 0 {
+			finished = j
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, errors.Errorf("upgrade check job %s is not finished in %s", job.Name, upgradeCheckTimeout)
+	}
+
+	message, err := k8sutil.GetJobTerminationMessage(c.context.Clientset, job)
+	if err != nil {
+		return nil, err
+	}
+	message = strings.TrimSpace(message)
+	if finished.Status.Succeeded == 0 {
+		if message == "" {
+			message = fmt.Sprintf("upgrade check job %s failed, see the logs of its pod", job.Name)
+		}
+		return nil, errors.New(message)
+	}
+	return tools.ParseUpgradeCheckVersions(message)
+}
@@ -0,0 +1,246 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	batch "k8s.io/api/batch/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+	"github.com/opencurve/curve-operator/pkg/tools"
+)
+
+const (
+	// userSyncInterval is the interval to update the usage of the user
+	userSyncInterval = 10 * time.Minute
+	// userRetryInterval is the interval to retry the user job that failed
+	userRetryInterval = time.Minute
+)
+
+// CurveUserReconciler creates the directory of the CurveUser in its cluster and updates the usage of its volumes
+// by a job running the curve client periodically, and deletes the directory by a job when the CurveUser is
+// deleted with delete policy Delete.
+type CurveUserReconciler struct {
+	Client client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+
+	context clusterd.Context
+}
+
+func NewCurveUserReconciler(
+	client client.Client,
+	log logr.Logger,
+	scheme *runtime.Scheme,
+	context clusterd.Context,
+) *CurveUserReconciler {
+	context.Client = client
+
+	return &CurveUserReconciler{
+		Client:  client,
+		Log:     log,
+		Scheme:  scheme,
+		context: context,
+	}
+}
+
+// +kubebuilder:rbac:groups=operator.curve.io,resources=curveusers,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=operator.curve.io,resources=curveusers/status,verbs=get;update;patch
+
+func (r *CurveUserReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("curveuser", req.NamespacedName)
+
+	user := &curvev1.CurveUser{}
+	err := r.Client.Get(ctx, req.NamespacedName, user)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, errors.Wrapf(err, "failed to get curveuser %q", req.NamespacedName)
+	}
+
+	finalizer := buildFinalizerName("CurveUser")
+	action := tools.UserJobSyncAction
+	if !user.GetDeletionTimestamp().IsZero() {
+		if !contains(user.Finalizers, finalizer) {
+			return reconcile.Result{}, nil
+		}
+		// the directory is gone with the cluster
+		if user.Spec.DeletePolicy != curvev1.UserDeletePolicyDelete || !clusterExists(r.Client, user.Namespace, user.Spec.Cluster) {
+			return reconcile.Result{}, RemoveFinalizerWithName(ctx, r.Client, user, req.NamespacedName, finalizer)
+		}
+		action = tools.UserJobDeleteAction
+	} else if !contains(user.Finalizers, finalizer) {
+		user.Finalizers = append(user.Finalizers, finalizer)
+		if err := r.Client.Update(ctx, user); err != nil {
+			return reconcile.Result{}, errors.Wrapf(err, "failed to add finalizer %q on %q", finalizer, user.Name)
+		}
+	}
+
+	job, err := r.context.Clientset.BatchV1().Jobs(user.Namespace).Get(tools.UserJobName(user.Name), metav1.GetOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to get job of curveuser %q", req.NamespacedName)
+	}
+	// the job of the current action and spec is waited to finish, or it's run again
+	if err != nil || job.Labels[tools.UserJobActionLabel] != action || tools.UserJobGeneration(job) != user.Generation {
+		if err := r.runJob(user, action); err != nil {
+			log.Info("failed to run user job", "action", action, "error", err.Error())
+			return reconcile.Result{RequeueAfter: userRetryInterval}, r.setStatus(user, curvev1.UserPhaseFailed, err.Error())
+		}
+		if action == tools.UserJobDeleteAction {
+			return reconcile.Result{}, r.setStatus(user, curvev1.UserPhaseDeleting, "")
+		}
+		return reconcile.Result{}, nil
+	}
+	finished := jobFinishedTime(job)
+	if finished == nil {
+		return reconcile.Result{}, nil
+	}
+	if action == tools.UserJobDeleteAction && job.Status.Succeeded > 0 {
+		log.Info("directory of user is deleted", "user", user.GetOwner())
+		return reconcile.Result{}, RemoveFinalizerWithName(ctx, r.Client, user, req.NamespacedName, finalizer)
+	}
+
+	// the finished job is kept until it's run again for the next sync or retry
+	interval := userSyncInterval
+	if job.Status.Succeeded == 0 {
+		interval = userRetryInterval
+	}
+	if user.Status.LastSyncTime == nil || user.Status.LastSyncTime.Before(finished) {
+		if err := r.updateStatus(user, job, action, finished); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+	if elapsed := time.Since(finished.Time); elapsed < interval {
+		return reconcile.Result{RequeueAfter: interval - elapsed}, nil
+	}
+	if err := r.runJob(user, action); err != nil {
+		log.Info("failed to run user job", "action", action, "error", err.Error())
+		return reconcile.Result{RequeueAfter: userRetryInterval}, r.setStatus(user, curvev1.UserPhaseFailed, err.Error())
+	}
+	return reconcile.Result{}, nil
+}
+
+// updateStatus updates the status of the user by the result of the finished job
+func (r *CurveUserReconciler) updateStatus(user *curvev1.CurveUser, job *batch.Job, action string, finished *metav1.Time) error {
+	log := r.Log.WithValues("curveuser", types.NamespacedName{Namespace: user.Namespace, Name: user.Name})
+	user.Status.LastSyncTime = finished
+
+	message, err := k8sutil.GetJobTerminationMessage(r.context.Clientset, job)
+	if err != nil {
+		return err
+	}
+	message = strings.TrimSpace(message)
+	if job.Status.Succeeded == 0 {
+		if message == "" {
+			message = fmt.Sprintf("%s job %s of curveuser failed, see the logs of its pod", action, job.Name)
+		}
+		log.Info("user job failed", "action", action, "message", message)
+		return r.setStatus(user, curvev1.UserPhaseFailed, message)
+	}
+
+	volumes, bytes, err := tools.ParseUserUsage(message)
+	if err != nil {
+		return r.setStatus(user, curvev1.UserPhaseFailed, err.Error())
+	}
+	user.Status.Volumes = volumes
+	user.Status.ProvisionedBytes = bytes
+	exceeded, message := quotaExceeded(user.Spec.Quota, volumes, bytes)
+	user.Status.QuotaExceeded = exceeded
+	if exceeded {
+		log.Info("volumes of user exceed the quota", "user", user.GetOwner(), "message", message)
+	}
+	return r.setStatus(user, curvev1.UserPhaseReady, message)
+}
+
+// jobFinishedTime returns the time that the job completed or failed, nil if it's not finished
+func jobFinishedTime(job *batch.Job) *metav1.Time {
+	for _, condition := range job.Status.Conditions {
+		if (condition.Type == batch.JobComplete || condition.Type == batch.JobFailed) && condition.Status == "True" {
+			finished := condition.LastTransitionTime
+			return &finished
+		}
+	}
+	return nil
+}
+
+// runJob runs the job of the action of the user, the cluster must be ready
+func (r *CurveUserReconciler) runJob(user *curvev1.CurveUser, action string) error {
+	clusterObj := &curvev1.CurveCluster{}
+	namespacedName := types.NamespacedName{Namespace: user.Namespace, Name: user.Spec.Cluster}
+	if err := r.Client.Get(context.TODO(), namespacedName, clusterObj); err != nil {
+		return errors.Wrapf(err, "failed to get curvecluster %q", user.Spec.Cluster)
+	}
+	if clusterObj.Spec == nil || clusterObj.Status.Phase != curvev1.ConditionTypeClusterReady {
+		return errors.Errorf("curvecluster %q is not ready", user.Spec.Cluster)
+	}
+
+	cluster := tools.New(r.context, namespacedName, *clusterObj.Spec, k8sutil.NewOwnerInfo(user, r.Scheme))
+	job, err := cluster.MakeUserJob(user.Name, user.GetOwner(), action, user.Generation, user.Spec.PasswordSecret)
+	if err != nil {
+		return err
+	}
+	if err := k8sutil.RunReplaceableJob(context.TODO(), r.context.Clientset, job, true); err != nil {
+		return errors.Wrapf(err, "failed to run %s job %s of curveuser", action, job.Name)
+	}
+	logger.Infof("created %s job %s of curveuser %q", action, job.Name, user.Name)
+	return nil
+}
+
+// clusterExists returns true if the cluster exists and is not being deleted
+func clusterExists(c client.Client, namespace, name string) bool {
+	clusterObj := &curvev1.CurveCluster{}
+	err := c.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, clusterObj)
+	return err == nil && clusterObj.GetDeletionTimestamp().IsZero()
+}
+
+// quotaExceeded returns true with the reason if the volumes of the user exceed the quota
+func quotaExceeded(quota *curvev1.UserQuotaSpec, volumes int, bytes int64) (bool, string) {
+	if quota == nil {
+		return false, ""
+	}
+	var reasons []string
+	if quota.MaxVolumes > 0 && volumes > quota.MaxVolumes {
+		reasons = append(reasons, fmt.Sprintf("%d volumes are more than %d", volumes, quota.MaxVolumes))
+	}
+	if quota.MaxCapacity != nil && !quota.MaxCapacity.IsZero() && bytes > quota.MaxCapacity.Value() {
+		reasons = append(reasons, fmt.Sprintf("volumes of %s are more than %s",
+			resource.NewQuantity(bytes, resource.BinarySI), quota.MaxCapacity))
+	}
+	if len(reasons) == 0 {
+		return false, ""
+	}
+	return true, "quota exceeded, " + strings.Join(reasons, ", ")
+}
+
+func (r *CurveUserReconciler) setStatus(user *curvev1.CurveUser, phase curvev1.UserPhase, message string) error {
+	user.Status.Phase = phase
+	user.Status.Message = message
+	user.Status.ObservedGeneration = user.Generation
+	if err := r.Client.Status().Update(context.TODO(), user); err != nil {
+		return errors.Wrapf(err, "failed to update status of curveuser %q", fmt.Sprintf("%s/%s", user.Namespace, user.Name))
+	}
+	return nil
+}
+
+func (r *CurveUserReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&curvev1.CurveUser{}).
+		Owns(&batch.Job{}).
+		Complete(r)
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/daemon"
+	"github.com/opencurve/curve-operator/pkg/etcd"
+)
+
+const (
+	versionRequestTimeout = 3 * time.Second
+	// unknownVersion is recorded for the daemon whose version is not exported and whose image has no tag
+	unknownVersion = "unknown"
+	// mixedVersionsWarnAfter is how long the components may run mixed versions before it's warned, such as an
+	// upgrade that is stuck halfway
+	mixedVersionsWarnAfter = 30 * time.Minute
+)
+
+// setVersions sets the versions of the running daemons of each component in the status of the cluster, and the
+// components that run mixed versions. The version is queried from the dummy port of the daemon, the tag of its
+// image is used for etcd or if the query failed. It returns true if the versions changed.
+func setVersions(clientset kubernetes.Interface, clusterObj *curvev1.CurveCluster) (bool, error) {
+	client := &http.Client{Timeout: versionRequestTimeout}
+	status := &curvev1.VersionsStatus{}
+	for _, c := range imageComponents {
+		pods, err := clientset.CoreV1().Pods(clusterObj.Namespace).List(metav1.ListOptions{LabelSelector: "app=" + c.appName})
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to list pods of %s", c.appName)
+		}
+		daemons := map[string]int{}
+		for _, pod := range pods.Items {
+			if pod.Status.Phase != v1.PodRunning || len(pod.Spec.Containers) == 0 {
+				continue
+			}
+			version := podVersion(client, c.appName, &pod)
+			if version == "" {
+				version = unknownVersion
+			}
+			daemons[version]++
+		}
+		for version, n := range daemons {
+			status.Components = append(status.Components, curvev1.ComponentVersionStatus{Component: c.component, Version: version, Daemons: n})
+		}
+		if len(daemons) > 1 {
+			status.MixedComponents = append(status.MixedComponents, c.component)
+		}
+	}
+	sort.Slice(status.Components, func(i, j int) bool {
+		if status.Components[i].Component != status.Components[j].Component {
+			return status.Components[i].Component < status.Components[j].Component
+		}
+		return status.Components[i].Version < status.Components[j].Version
+	})
+	sort.Strings(status.MixedComponents)
+
+	old := clusterObj.Status.Versions
+	if len(status.MixedComponents) > 0 {
+		if old != nil && old.MixedSince != nil {
+			status.MixedSince = old.MixedSince
+		} else {
+			now := metav1.Now()
+			status.MixedSince = &now
+		}
+		if time.Since(status.MixedSince.Time) > mixedVersionsWarnAfter {
+			logger.Warningf("components %v of cluster %q have been running mixed versions since %s",
+				status.MixedComponents, clusterObj.Name, status.MixedSince.Format(time.RFC3339))
+		}
+	}
+
+	if old != nil && equality.Semantic.DeepEqual(old.Components, status.Components) &&
+		equality.Semantic.DeepEqual(old.MixedComponents, status.MixedComponents) && old.MixedSince.Equal(status.MixedSince) {
+		return false, nil
+	}
+	if len(status.Components) == 0 && old == nil {
+		return false, nil
+	}
+	status.LastUpdateTime = metav1.Now()
+	clusterObj.Status.Versions = status
+	return true, nil
+}
+
+// podVersion returns the version of the daemon in the pod, the daemons run in host network and export their
+// versions on the dummy ports, or the listen port for chunkserver
+func podVersion(client *http.Client, appName string, pod *v1.Pod) string {
+	container := &pod.Spec.Containers[0]
+	imageVersion := daemon.ImageVersion(container.Image)
+	if appName == etcd.AppName || pod.Status.HostIP == "" {
+		return imageVersion
+	}
+	port := containerPort(container, "dummy-port")
+	if port == 0 {
+		port = containerPort(container, "listen-port")
+	}
+	if port == 0 {
+		return imageVersion
+	}
+	version, err := daemon.GetVersion(client, fmt.Sprintf("%s:%d", pod.Status.HostIP, port))
+	if err != nil {
+		logger.Infof("failed to get version of %q, using the tag of its image. %v", pod.Name, err)
+		return imageVersion
+	}
+	return version
+}
+
+func containerPort(container *v1.Container, name string) int32 {
+	for _, p := range container.Ports {
+		if p.Name == name {
+			return p.ContainerPort
+		}
+	}
+	return 0
+}
@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"time"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// disruptionAllowed tells whether the disruptive action can run now by spec.maintenanceWindow, the action is
+// deferred to the next window otherwise. The invalid window is reported by the validation of the spec.
+func disruptionAllowed(spec *curvev1.CurveClusterSpec, action string) bool {
+	open, next, err := k8sutil.MaintenanceWindowOpen(spec.MaintenanceWindow, time.Now())
+	if err != nil {
+		logger.Warningf("%s is deferred because the maintenance window is invalid: %v", action, err)
+		return false
+	}
+	if !open {
+		logger.Infof("%s is deferred to the maintenance window at %s", action, next.Format(time.RFC3339))
+	}
+	return open
+}
+
+// untilMaintenanceWindow returns how long it's until the next maintenance window opens, it's 0 if there is no
+// window or it's open now
+func untilMaintenanceWindow(spec *curvev1.CurveClusterSpec) time.Duration {
+	now := time.Now()
+	open, next, err := k8sutil.MaintenanceWindowOpen(spec.MaintenanceWindow, now)
+	if err != nil || open {
+		return 0
+	}
+	return next.Sub(now)
+}
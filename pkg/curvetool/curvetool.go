@@ -0,0 +1,133 @@
+package curvetool
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/coreos/pkg/capnslog"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// ToolPath is where curve_ops_tool is in curve image
+const ToolPath = "/curvebs/tools/sbin/curve_ops_tool"
+
+var logger = capnslog.NewPackageLogger("github.com/opencurve/curve-operator", "curvetool")
+
+// Executor runs curve_ops_tool with the args and returns its output. The tool exits with non-zero if the cluster
+// is not healthy, so the output is returned with the error to be parsed anyway.
+type Executor interface {
+	Execute(args ...string) (string, error)
+}
+
+// PodExecutor runs curve_ops_tool by exec in a running pod of the selector, such as the tools pod or the mds pods
+type PodExecutor struct {
+	context   *clusterd.Context
+	namespace string
+	selector  string
+}
+
+func NewPodExecutor(context *clusterd.Context, namespace, selector string) *PodExecutor {
+	return &PodExecutor{
+		context:   context,
+		namespace: namespace,
+		selector:  selector,
+	}
+}
+
+func (e *PodExecutor) Execute(args ...string) (string, error) {
+	pods, err := e.context.Clientset.CoreV1().Pods(e.namespace).List(metav1.ListOptions{LabelSelector: e.selector})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to list pods %q", e.selector)
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase != v1.PodRunning || !pod.DeletionTimestamp.IsZero() {
+			continue
+		}
+		logger.Debugf("running curve_ops_tool %v in pod %s/%s", args, pod.Namespace, pod.Name)
+		return k8sutil.ExecInPod(e.context, pod, append([]string{ToolPath}, args...))
+	}
+	return "", errors.Errorf("no running pod %q", e.selector)
+}
+
+// Tool runs the commands of curve_ops_tool by the executor and parses their output. The flags are appended to
+// every command, such as -mdsAddr if tools.conf is not mounted in the pod.
+type Tool struct {
+	executor Executor
+	flags    []string
+}
+
+func New(executor Executor, flags ...string) *Tool {
+	return &Tool{
+		executor: executor,
+		flags:    flags,
+	}
+}
+
+// run runs the command and parses its output, the error of the command is returned only if the output is not parsed
+func (t *Tool) run(command string, parse func(string) error) error {
+	output, err := t.executor.Execute(append([]string{command}, t.flags...)...)
+	if perr := parse(output); perr != nil {
+		if err != nil {
+			return errors.Wrapf(err, "failed to run %s", command)
+		}
+		return errors.Wrapf(perr, "unexpected output of %s", command)
+	}
+	return nil
+}
+
+// ChunkServerList returns the chunkservers in topology by chunkserver-list
+func (t *Tool) ChunkServerList() ([]ChunkServer, error) {
+	var chunkservers []ChunkServer
+	err := t.run("chunkserver-list", func(output string) (err error) {
+		chunkservers, err = ParseChunkServerList(output)
+		return err
+	})
+	return chunkservers, err
+}
+
+// ServerList returns the servers in topology by server-list
+func (t *Tool) ServerList() ([]Server, error) {
+	var servers []Server
+	err := t.run("server-list", func(output string) (err error) {
+		servers, err = ParseServerList(output)
+		return err
+	})
+	return servers, err
+}
+
+// SetChunkServerStatus sets the rw status of the chunkserver in topology by set-chunkserver, the status is
+// readwrite, pendding or retired. A pendding chunkserver has its copysets migrated to the others.
+func (t *Tool) SetChunkServerStatus(id int, status string) error {
+	args := append([]string{"set-chunkserver", fmt.Sprintf("-chunkserver_id=%d", id), "-chunkserver_status=" + status}, t.flags...)
+	output, err := t.executor.Execute(args...)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set chunkserver %d %s: %s", id, status, strings.TrimSpace(output))
+	}
+	return nil
+}
+
+// CopysetsStatus returns the number of the copysets and the unhealthy ones by copysets-status
+func (t *Tool) CopysetsStatus() (*CopysetsStatus, error) {
+	var status *CopysetsStatus
+	err := t.run("copysets-status", func(output string) (err error) {
+		status, err = ParseCopysetsStatus(output)
+		return err
+	})
+	return status, err
+}
+
+// Space returns the physical and logical space of the cluster by space
+func (t *Tool) Space() (*Space, error) {
+	var space *Space
+	err := t.run("space", func(output string) (err error) {
+		space, err = ParseSpace(output)
+		return err
+	})
+	return space, err
+}
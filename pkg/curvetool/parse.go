@@ -0,0 +1,243 @@
+package curvetool
+
+import (
+	"bufio"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// chunkserver-list prints a line of every chunkserver, such as 'chunkServerID = 1, diskType = nvme,
+	// hostIP = 10.0.0.1, port = 8200, rwStatus = READWRITE, diskState = DISKNORMAL, onlineState = ONLINE,
+	// copysetNum = 100, mountPoint = local:///curvebs/chunkserver/data, diskCapacity = 100 GB, diskUsed = 10 GB'
+	chunkServerLine = regexp.MustCompile(`^chunkServerID = \d+,`)
+	// server-list prints a line of every server, such as 'serverID = 1, hostName = node1, internalIP = 10.0.0.1,
+	// internalPort = 0, externalIP = 10.0.0.1, externalPort = 0, zoneID = 1, physicalPoolID = 1'
+	serverLine = regexp.MustCompile(`^serverID = \d+,`)
+	// copysets-status prints 'total copysets: 300, unhealthy copysets: 0, unhealthy_ratio: 0%'
+	copysetsStatusLine = regexp.MustCompile(`total copysets: (\d+), unhealthy copysets: (\d+)`)
+	// space prints 'physical: total = 300GB, used = 20GB(6.67%), left = 280GB(93.33%)' and
+	// 'logical: total = 100GB, used = 10GB(10.00%, can be recycled = 0GB(0.00%)), left = 90GB(90.00%), ...'
+	spaceLine = regexp.MustCompile(`^(physical|logical): total = (\d+) ?([KMGTP]?B), used = (\d+) ?([KMGTP]?B)`)
+)
+
+const (
+	ChunkServerOnline   = "ONLINE"
+	ChunkServerOffline  = "OFFLINE"
+	ChunkServerUnstable = "UNSTABLE"
+
+	ChunkServerReadWrite = "READWRITE"
+	ChunkServerPendding  = "PENDDING"
+	ChunkServerRetired   = "RETIRED"
+)
+
+// ChunkServer is a chunkserver in topology of chunkserver-list
+type ChunkServer struct {
+	ID          int
+	DiskType    string
+	HostIP      string
+	Port        int
+	RWStatus    string
+	DiskState   string
+	OnlineState string
+	CopysetNum  int
+	MountPoint  string
+	// the capacity and the used of the disk are printed in GiB
+	DiskCapacityBytes int64
+	DiskUsedBytes     int64
+}
+
+// Server is a server in topology of server-list, a host of chunkservers
+type Server struct {
+	ID             int
+	HostName       string
+	InternalIP     string
+	ExternalIP     string
+	ZoneID         int
+	PhysicalPoolID int
+}
+
+// CopysetsStatus is the number of all the copysets of the cluster and the unhealthy ones
+type CopysetsStatus struct {
+	Total     int
+	Unhealthy int
+}
+
+// Space is the space of the cluster, the physical one is of the disks of the chunkservers and the logical one is
+// of the volumes
+type Space struct {
+	PhysicalTotalBytes int64
+	PhysicalUsedBytes  int64
+	LogicalTotalBytes  int64
+	LogicalUsedBytes   int64
+}
+
+// ParseChunkServerList parses the output of chunkserver-list, the lines of other messages are skipped
+func ParseChunkServerList(output string) ([]ChunkServer, error) {
+	chunkservers := []ChunkServer{}
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !chunkServerLine.MatchString(line) {
+			continue
+		}
+		fields := parseFields(line)
+		cs := ChunkServer{
+			DiskType:    fields["diskType"],
+			HostIP:      fields["hostIP"],
+			RWStatus:    fields["rwStatus"],
+			DiskState:   fields["diskState"],
+			OnlineState: fields["onlineState"],
+			MountPoint:  fields["mountPoint"],
+		}
+		var err error
+		if cs.ID, err = strconv.Atoi(fields["chunkServerID"]); err != nil {
+			return nil, errors.Wrapf(err, "invalid chunkServerID of %q", line)
+		}
+		if cs.Port, err = strconv.Atoi(fields["port"]); err != nil {
+			return nil, errors.Wrapf(err, "invalid port of %q", line)
+		}
+		// the fields are not printed by old versions
+		if v, ok := fields["copysetNum"]; ok {
+			if cs.CopysetNum, err = strconv.Atoi(v); err != nil {
+				return nil, errors.Wrapf(err, "invalid copysetNum of %q", line)
+			}
+		}
+		if cs.DiskCapacityBytes, err = parseSize(fields["diskCapacity"]); err != nil {
+			return nil, errors.Wrapf(err, "invalid diskCapacity of %q", line)
+		}
+		if cs.DiskUsedBytes, err = parseSize(fields["diskUsed"]); err != nil {
+			return nil, errors.Wrapf(err, "invalid diskUsed of %q", line)
+		}
+		chunkservers = append(chunkservers, cs)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read output of chunkserver-list")
+	}
+	return chunkservers, nil
+}
+
+// ParseServerList parses the output of server-list, the lines of other messages are skipped
+func ParseServerList(output string) ([]Server, error) {
+	servers := []Server{}
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !serverLine.MatchString(line) {
+			continue
+		}
+		fields := parseFields(line)
+		server := Server{
+			HostName:   fields["hostName"],
+			InternalIP: fields["internalIP"],
+			ExternalIP: fields["externalIP"],
+		}
+		var err error
+		if server.ID, err = strconv.Atoi(fields["serverID"]); err != nil {
+			return nil, errors.Wrapf(err, "invalid serverID of %q", line)
+		}
+		if server.ZoneID, err = strconv.Atoi(fields["zoneID"]); err != nil {
+			return nil, errors.Wrapf(err, "invalid zoneID of %q", line)
+		}
+		if server.PhysicalPoolID, err = strconv.Atoi(fields["physicalPoolID"]); err != nil {
+			return nil, errors.Wrapf(err, "invalid physicalPoolID of %q", line)
+		}
+		servers = append(servers, server)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read output of server-list")
+	}
+	return servers, nil
+}
+
+// ParseCopysetsStatus parses the output of copysets-status
+func ParseCopysetsStatus(output string) (*CopysetsStatus, error) {
+	match := copysetsStatusLine.FindStringSubmatch(output)
+	if match == nil {
+		return nil, errors.Errorf("no copysets status in %q", output)
+	}
+	total, _ := strconv.Atoi(match[1])
+	unhealthy, _ := strconv.Atoi(match[2])
+	return &CopysetsStatus{Total: total, Unhealthy: unhealthy}, nil
+}
+
+// ParseSpace parses the output of space, both the physical and the logical space must be printed
+func ParseSpace(output string) (*Space, error) {
+	space := &Space{}
+	found := map[string]bool{}
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		match := spaceLine.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if match == nil {
+			continue
+		}
+		total, err := parseSize(match[2] + match[3])
+		if err != nil {
+			return nil, err
+		}
+		used, err := parseSize(match[4] + match[5])
+		if err != nil {
+			return nil, err
+		}
+		if match[1] == "physical" {
+			space.PhysicalTotalBytes, space.PhysicalUsedBytes = total, used
+		} else {
+			space.LogicalTotalBytes, space.LogicalUsedBytes = total, used
+		}
+		found[match[1]] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read output of space")
+	}
+	if !found["physical"] || !found["logical"] {
+		return nil, errors.Errorf("no space in %q", output)
+	}
+	return space, nil
+}
+
+// parseFields parses the 'key = value' fields separated by commas
+func parseFields(line string) map[string]string {
+	fields := map[string]string{}
+	for _, field := range strings.Split(line, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		fields[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return fields
+}
+
+var sizeUnits = map[string]int64{
+	"B":  1,
+	"KB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+	"TB": 1 << 40,
+	"PB": 1 << 50,
+}
+
+// parseSize parses the size of the tool such as '100 GB' or '100GB' to bytes, the units are of 1024, it's 0 if
+// the size is empty
+func parseSize(size string) (int64, error) {
+	size = strings.TrimSpace(size)
+	if size == "" {
+		return 0, nil
+	}
+	i := strings.IndexFunc(size, func(r rune) bool { return r < '0' || r > '9' })
+	if i <= 0 {
+		return 0, errors.Errorf("invalid size %q", size)
+	}
+	unit, ok := sizeUnits[strings.TrimSpace(size[i:])]
+	if !ok {
+		return 0, errors.Errorf("invalid unit of size %q", size)
+	}
+	n, err := strconv.ParseInt(size[:i], 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid size %q", size)
+	}
+	return n * unit, nil
+}
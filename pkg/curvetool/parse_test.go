@@ -0,0 +1,95 @@
+package curvetool
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseChunkServerList(t *testing.T) {
+	output := `curve chunkserver list, num = 2
+chunkServerID = 1, diskType = nvme, hostIP = 10.0.0.1, port = 8200, rwStatus = READWRITE, diskState = DISKNORMAL, onlineState = ONLINE, copysetNum = 100, mountPoint = local:///curvebs/chunkserver/data, diskCapacity = 100 GB, diskUsed = 10 GB
+chunkServerID = 2, diskType = nvme, hostIP = 10.0.0.2, port = 8201, rwStatus = PENDDING, diskState = DISKERROR, onlineState = OFFLINE, mountPoint = local:///curvebs/chunkserver/data, diskCapacity = 0 GB, diskUsed = 0 GB
+`
+	chunkservers, err := ParseChunkServerList(output)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []ChunkServer{
+		{
+			ID: 1, DiskType: "nvme", HostIP: "10.0.0.1", Port: 8200, RWStatus: ChunkServerReadWrite,
+			DiskState: "DISKNORMAL", OnlineState: ChunkServerOnline, CopysetNum: 100,
+			MountPoint: "local:///curvebs/chunkserver/data", DiskCapacityBytes: 100 << 30, DiskUsedBytes: 10 << 30,
+		},
+		{
+			ID: 2, DiskType: "nvme", HostIP: "10.0.0.2", Port: 8201, RWStatus: ChunkServerPendding,
+			DiskState: "DISKERROR", OnlineState: ChunkServerOffline, MountPoint: "local:///curvebs/chunkserver/data",
+		},
+	}
+	if !reflect.DeepEqual(chunkservers, expected) {
+		t.Errorf("expected %+v, got %+v", expected, chunkservers)
+	}
+
+	if _, err := ParseChunkServerList("chunkServerID = 1, port = http\n"); err == nil {
+		t.Error("expected error of invalid port")
+	}
+}
+
+func TestParseServerList(t *testing.T) {
+	output := `curve server list, num = 2
+serverID = 1, hostName = node1, internalIP = 10.0.0.1, internalPort = 0, externalIP = 10.0.0.1, externalPort = 0, zoneID = 1, physicalPoolID = 1
+serverID = 4, hostName = node1-renamed, internalIP = 10.0.0.11, internalPort = 0, externalIP = 10.0.0.11, externalPort = 0, zoneID = 1, physicalPoolID = 1
+`
+	servers, err := ParseServerList(output)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []Server{
+		{ID: 1, HostName: "node1", InternalIP: "10.0.0.1", ExternalIP: "10.0.0.1", ZoneID: 1, PhysicalPoolID: 1},
+		{ID: 4, HostName: "node1-renamed", InternalIP: "10.0.0.11", ExternalIP: "10.0.0.11", ZoneID: 1, PhysicalPoolID: 1},
+	}
+	if !reflect.DeepEqual(servers, expected) {
+		t.Errorf("expected %+v, got %+v", expected, servers)
+	}
+
+	if _, err := ParseServerList("serverID = 1, zoneID = zone1\n"); err == nil {
+		t.Error("expected error of invalid zoneID")
+	}
+}
+
+func TestParseCopysetsStatus(t *testing.T) {
+	status, err := ParseCopysetsStatus("total copysets: 300, unhealthy copysets: 3, unhealthy_ratio: 1%\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *status != (CopysetsStatus{Total: 300, Unhealthy: 3}) {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	if _, err := ParseCopysetsStatus("connect to mds failed\n"); err == nil {
+		t.Error("expected error without copysets status")
+	}
+}
+
+func TestParseSpace(t *testing.T) {
+	output := `Space info:
+physical: total = 300GB, used = 20GB(6.67%), left = 280GB(93.33%)
+logical: total = 100GB, used = 10GB(10.00%, can be recycled = 0GB(0.00%)), left = 90GB(90.00%), allocated = 5GB(5.00%)
+`
+	space, err := ParseSpace(output)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := Space{
+		PhysicalTotalBytes: 300 << 30,
+		PhysicalUsedBytes:  20 << 30,
+		LogicalTotalBytes:  100 << 30,
+		LogicalUsedBytes:   10 << 30,
+	}
+	if *space != expected {
+		t.Errorf("expected %+v, got %+v", expected, *space)
+	}
+
+	if _, err := ParseSpace("physical: total = 300GB, used = 20GB(6.67%)\n"); err == nil {
+		t.Error("expected error without logical space")
+	}
+}
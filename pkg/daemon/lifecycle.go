@@ -0,0 +1,45 @@
+package daemon
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+// preStopScript flushes the dirty pages to the disks, then stops the daemon of the pid file in $1 by SIGTERM and
+// waits for it to quit. The daemon is PID 1 of the container if there is no pid file.
+var preStopScript = `
+sync
+pid=1
+if [ -n "$1" ]; then
+  pid=$(cat "$1" 2>/dev/null)
+fi
+if [ -n "$pid" ]; then
+  kill -TERM "$pid"
+  # the container is killed when the termination grace period ends
+  while kill -0 "$pid" 2>/dev/null; do
+    sleep 1
+  done
+fi
+sync
+`
+
+// PreStopHook returns the lifecycle of the daemon container that stops the daemon gracefully before the pod is
+// killed, such as when the node is drained. pidFile is the pid file of the daemon, or empty if it's PID 1.
+func PreStopHook(pidFile string) *v1.Lifecycle {
+	return &v1.Lifecycle{
+		PreStop: &v1.Handler{
+			Exec: &v1.ExecAction{
+				Command: []string{"/bin/bash", "-c", preStopScript, "prestop", pidFile},
+			},
+		},
+	}
+}
+
+// TerminationGracePeriod returns the termination grace period of the daemon pods in seconds, which is
+// defaultSeconds if seconds is not set
+func TerminationGracePeriod(seconds, defaultSeconds int) *int64 {
+	if seconds <= 0 {
+		seconds = defaultSeconds
+	}
+	period := int64(seconds)
+	return &period
+}
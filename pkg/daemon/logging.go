@@ -0,0 +1,59 @@
+package daemon
+
+import (
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+const defaultLogMaxAgeDays = 7
+
+// logRotateScript removes the log files that are not modified for more than max_age_days every hour,
+// hidden files are kept because they are not logs
+var logRotateScript = `
+log_dir=$1
+max_age_days=$2
+
+while true; do
+  find ${log_dir} -type f ! -name '.*' -mtime +${max_age_days} -print -delete
+  sleep 3600
+done
+`
+
+// LoggingEnv returns the environments to configure glog of curve daemons(mds, chunkserver and snapshotclone)
+func LoggingEnv(logging curvev1.LoggingSpec) []v1.EnvVar {
+	env := []v1.EnvVar{}
+	if logging.ToStdout {
+		env = append(env, v1.EnvVar{Name: "GLOG_logtostderr", Value: "true"})
+	}
+	if logging.Rotate.Enable && logging.Rotate.MaxSizeMB > 0 {
+		env = append(env, v1.EnvVar{Name: "GLOG_max_log_size", Value: strconv.Itoa(logging.Rotate.MaxSizeMB)})
+	}
+	return env
+}
+
+// LogRotateContainers returns the sidecar to remove the old log files in logDir which is mounted from 'log-volume',
+// nothing is returned if the rotation is not enabled
+func LogRotateContainers(logging curvev1.LoggingSpec, logDir string, image string, pullPolicy v1.PullPolicy) []v1.Container {
+	if !logging.Rotate.Enable {
+		return nil
+	}
+
+	maxAgeDays := logging.Rotate.MaxAgeDays
+	if maxAgeDays == 0 {
+		maxAgeDays = defaultLogMaxAgeDays
+	}
+
+	return []v1.Container{
+		{
+			Name:            "log-rotate",
+			Command:         []string{"/bin/bash"},
+			Args:            []string{"-c", logRotateScript, "log-rotate", logDir, strconv.Itoa(maxAgeDays)},
+			Image:           image,
+			ImagePullPolicy: pullPolicy,
+			VolumeMounts:    []v1.VolumeMount{{Name: "log-volume", MountPath: logDir}},
+		},
+	}
+}
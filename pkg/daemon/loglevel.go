@@ -0,0 +1,57 @@
+package daemon
+
+import (
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	glogVerbosityEnv   = "GLOG_v"
+	glogMinLogLevelEnv = "GLOG_minloglevel"
+
+	// etcdLogLevelKey is the key of log level in etcd.conf
+	etcdLogLevelKey = "log-level"
+)
+
+// LogLevelEnv returns the glog environments of the log level for mds, chunkserver and snapshotclone,
+// nothing is returned if level is empty so that the daemon uses its default level
+func LogLevelEnv(level string) []v1.EnvVar {
+	switch level {
+	case "debug":
+		return []v1.EnvVar{{Name: glogMinLogLevelEnv, Value: "0"}, {Name: glogVerbosityEnv, Value: "6"}}
+	case "info":
+		return []v1.EnvVar{{Name: glogMinLogLevelEnv, Value: "0"}}
+	case "warn":
+		return []v1.EnvVar{{Name: glogMinLogLevelEnv, Value: "1"}}
+	case "error":
+		return []v1.EnvVar{{Name: glogMinLogLevelEnv, Value: "2"}}
+	}
+	return nil
+}
+
+// SetLogLevelEnv replaces the glog environments of the log level in container
+func SetLogLevelEnv(container *v1.Container, level string) {
+	env := []v1.EnvVar{}
+	for _, e := range container.Env {
+		if e.Name != glogVerbosityEnv && e.Name != glogMinLogLevelEnv {
+			env = append(env, e)
+		}
+	}
+	container.Env = append(env, LogLevelEnv(level)...)
+}
+
+// SetEtcdLogLevel sets the log level in etcd.conf, the line is removed if level is empty
+func SetEtcdLogLevel(conf string, level string) string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(conf, "\n"), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), etcdLogLevelKey+":") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if level != "" {
+		lines = append(lines, etcdLogLevelKey+": "+level)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,57 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// versionVar is the bvar that mds, chunkserver and snapshotclone export their version by, such as
+// 'curve_version : 1.2.5'
+const versionVar = "curve_version"
+
+// GetVersion returns the version exported by the bvar of the daemon on the addr ip:port of its dummy server
+func GetVersion(client *http.Client, addr string) (string, error) {
+	url := fmt.Sprintf("http://%s/vars/%s", addr, versionVar)
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get %s", url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("failed to get %s, status %s", url, resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read %s", url)
+	}
+	return parseVersion(string(body))
+}
+
+// parseVersion returns the version in the bvar printed as 'curve_version : 1.2.5'
+func parseVersion(body string) (string, error) {
+	for _, line := range strings.Split(body, "\n") {
+		kv := strings.SplitN(line, " : ", 2)
+		if len(kv) == 2 && strings.TrimSpace(kv[0]) == versionVar && strings.TrimSpace(kv[1]) != "" {
+			return strings.TrimSpace(kv[1]), nil
+		}
+	}
+	return "", errors.Errorf("no %s is exported", versionVar)
+}
+
+// ImageVersion returns the tag of the image as its version, it's empty if the image is referred by digest or
+// has no tag
+func ImageVersion(image string) string {
+	if strings.Contains(image, "@") {
+		return ""
+	}
+	// the registry may have a port, the tag is after the last '/'
+	name := image[strings.LastIndex(image, "/")+1:]
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[i+1:]
+	}
+	return ""
+}
@@ -0,0 +1,35 @@
+package daemon
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+// waitForEndpointsScript waits until any of the endpoints 'ip:port,ip:port' accepts tcp connection
+var waitForEndpointsScript = `
+endpoints=$1
+
+while true; do
+  for endpoint in ${endpoints//,/ }; do
+    host=${endpoint%:*}
+    port=${endpoint##*:}
+    if timeout 3 bash -c "echo > /dev/tcp/${host}/${port}" 2>/dev/null; then
+      echo "${endpoint} is ready"
+      exit 0
+    fi
+  done
+  echo "waiting for ${endpoints}"
+  sleep 2
+done
+`
+
+// WaitForEndpointsInitContainer returns an init container that blocks the daemon from starting until the
+// daemon it depends on is serving, so a restarted pod waits for its dependency by itself.
+func WaitForEndpointsInitContainer(name string, endpoints string, image string, pullPolicy v1.PullPolicy) v1.Container {
+	return v1.Container{
+		Name:            name,
+		Command:         []string{"/bin/bash"},
+		Args:            []string{"-c", waitForEndpointsScript, name, endpoints},
+		Image:           image,
+		ImagePullPolicy: pullPolicy,
+	}
+}
@@ -0,0 +1,281 @@
+package dashboard
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/coreos/pkg/capnslog"
+	"github.com/pkg/errors"
+	apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+const (
+	AppName       = "curve-dashboard"
+	ConfigMapName = "curve-dashboard-conf"
+
+	// CredentialsSecretName is the secret of the admin login created if spec.dashboard.credentialsSecret is not set
+	CredentialsSecretName = "curve-dashboard-credentials"
+
+	configDataKey   = "pigeon.yaml"
+	configMountPath = "/curve-manager/conf/pigeon.yaml"
+	dataDir         = "/curve-manager/db"
+	logDir          = "/curve-manager/logs"
+)
+
+type Cluster struct {
+	context        clusterd.Context
+	namespacedName types.NamespacedName
+	spec           curvev1.CurveClusterSpec
+	ownerInfo      *k8sutil.OwnerInfo
+}
+
+var logger = capnslog.NewPackageLogger("github.com/opencurve/curve-operator", "dashboard")
+
+func New(context clusterd.Context,
+	namespacedName types.NamespacedName,
+	spec curvev1.CurveClusterSpec,
+	ownerInfo *k8sutil.OwnerInfo) *Cluster {
+	return &Cluster{
+		context:        context,
+		namespacedName: namespacedName,
+		spec:           spec,
+		ownerInfo:      ownerInfo,
+	}
+}
+
+// Reconcile deploys the dashboard wired to the mds, snapshotclone and etcd of the cluster if it's enabled,
+// otherwise deletes it. The credentials secret is kept so that the password survives enabling it again.
+func (c *Cluster) Reconcile() error {
+	namespace := c.namespacedName.Namespace
+	if !c.spec.Dashboard.Enable {
+		k8sutil.ExpectDelete("Deployment", namespace, AppName)
+		err := c.context.Clientset.AppsV1().Deployments(namespace).Delete(AppName, &metav1.DeleteOptions{})
+		if err != nil && !kerrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete dashboard deployment %q", AppName)
+		}
+		k8sutil.ExpectDelete("ConfigMap", namespace, ConfigMapName)
+		err = c.context.Clientset.CoreV1().ConfigMaps(namespace).Delete(ConfigMapName, &metav1.DeleteOptions{})
+		if err != nil && !kerrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete dashboard configmap %q", ConfigMapName)
+		}
+		return k8sutil.DeleteExposure(&c.context, AppName, namespace)
+	}
+
+	logger.Info("starting dashboard")
+	secretName, err := c.createCredentialsSecret()
+	if err != nil {
+		return err
+	}
+	if err := c.createConfigMap(); err != nil {
+		return err
+	}
+
+	d, err := c.makeDeployment(secretName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dashboard Deployment %q object", AppName)
+	}
+	if _, err := k8sutil.CreateOrUpdateDeployment(&c.context, d); err != nil {
+		return errors.Wrapf(err, "failed to create dashboard deployment %q in cluster", AppName)
+	}
+
+	exposure := c.spec.Dashboard.Exposure
+	if exposure == nil {
+		exposure = &curvev1.ExposureSpec{}
+	}
+	return k8sutil.ApplyExposure(&c.context, c.spec, c.ownerInfo, k8sutil.ExposedService{
+		Name:      AppName,
+		Namespace: namespace,
+		Selector:  c.getPodLabels(),
+		Port:      c.port(),
+	}, exposure)
+}
+
+// createCredentialsSecret returns the secret of the admin login, the default secret with a random password is
+// created if the secret is not given in spec. The password of an existing secret is never changed.
+func (c *Cluster) createCredentialsSecret() (string, error) {
+	if c.spec.Dashboard.CredentialsSecret != "" {
+		return c.spec.Dashboard.CredentialsSecret, nil
+	}
+
+	namespace := c.namespacedName.Namespace
+	_, err := c.context.Clientset.CoreV1().Secrets(namespace).Get(CredentialsSecretName, metav1.GetOptions{})
+	if err == nil {
+		return CredentialsSecretName, nil
+	}
+	if !kerrors.IsNotFound(err) {
+		return "", errors.Wrapf(err, "failed to get secret %q", CredentialsSecretName)
+	}
+
+	password := make([]byte, 16)
+	if _, err := rand.Read(password); err != nil {
+		return "", errors.Wrap(err, "failed to generate the password of dashboard")
+	}
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      CredentialsSecretName,
+			Namespace: namespace,
+		},
+		StringData: map[string]string{
+			"username": "admin",
+			"password": hex.EncodeToString(password),
+		},
+	}
+	k8sutil.InjectMetadata(c.spec, "", secret)
+	if err := c.ownerInfo.SetControllerReference(secret); err != nil {
+		return "", errors.Wrapf(err, "failed to set owner reference to secret %q", CredentialsSecretName)
+	}
+	if _, err := c.context.Clientset.CoreV1().Secrets(namespace).Create(secret); err != nil && !kerrors.IsAlreadyExists(err) {
+		return "", errors.Wrapf(err, "failed to create secret %q", CredentialsSecretName)
+	}
+	logger.Infof("the admin password of dashboard is generated in secret %q", CredentialsSecretName)
+	return CredentialsSecretName, nil
+}
+
+// createConfigMap renders the config of the dashboard with the endpoints of the cluster
+func (c *Cluster) createConfigMap() error {
+	clusterInfo, err := config.GetClusterInfo(&c.context, c.namespacedName.Namespace)
+	if err != nil {
+		return err
+	}
+
+	data := fmt.Sprintf(`servers:
+  - name: curvebs-dashboard
+    config:
+      listen.address: 0.0.0.0:%d
+      access.api.enable_check: true
+      access.login.expire_seconds: 1800
+      system.log.path: %s
+      system.db.sqlite.url: file://%s/curvebs.db
+      mds.address: %s
+      snapshot.clone.address: %s
+      etcd.address: %s
+`, c.port(), logDir, dataDir, clusterInfo.MdsAddr, clusterInfo.SnapShotCloneAddr, clusterInfo.EtcdAddr)
+
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ConfigMapName,
+			Namespace: c.namespacedName.Namespace,
+		},
+		Data: map[string]string{configDataKey: data},
+	}
+	k8sutil.InjectMetadata(c.spec, "", cm)
+	if err := c.ownerInfo.SetControllerReference(cm); err != nil {
+		return errors.Wrapf(err, "failed to set owner reference to dashboard configmap %q", ConfigMapName)
+	}
+	if err := k8sutil.Apply(c.context.Client, cm); err != nil {
+		return errors.Wrapf(err, "failed to create dashboard configmap %q", ConfigMapName)
+	}
+	return nil
+}
+
+// makeDeployment makes the deployment of the dashboard, the admin login is passed by the env of the secret
+func (c *Cluster) makeDeployment(secretName string) (*apps.Deployment, error) {
+	image := c.spec.Dashboard.Image
+	if image == "" {
+		image = config.GetOperatorSettings().DashboardImage
+	}
+	secretEnv := func(name, key string) v1.EnvVar {
+		return v1.EnvVar{Name: name, ValueFrom: &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: secretName},
+			Key:                  key,
+		}}}
+	}
+
+	podSpec := v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   AppName,
+			Labels: c.getPodLabels(),
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:            "dashboard",
+					Image:           image,
+					ImagePullPolicy: c.spec.CurveVersion.ImagePullPolicy,
+					Env: []v1.EnvVar{
+						{Name: "TZ", Value: "Asia/Hangzhou"},
+						secretEnv("CURVE_DASHBOARD_USERNAME", "username"),
+						secretEnv("CURVE_DASHBOARD_PASSWORD", "password"),
+					},
+					Ports: []v1.ContainerPort{{
+						Name:          k8sutil.ExposedPortName,
+						ContainerPort: int32(c.port()),
+						Protocol:      v1.ProtocolTCP,
+					}},
+					VolumeMounts: []v1.VolumeMount{
+						{Name: ConfigMapName, MountPath: configMountPath, SubPath: configDataKey, ReadOnly: true},
+						{Name: "data", MountPath: dataDir},
+						{Name: "logs", MountPath: logDir},
+					},
+					ReadinessProbe: k8sutil.MakeProbe(k8sutil.TCPProbeHandler(c.port()), nil, k8sutil.DefaultReadinessProbe),
+				},
+			},
+			RestartPolicy: v1.RestartPolicyAlways,
+			Volumes: []v1.Volume{
+				{
+					Name: ConfigMapName,
+					VolumeSource: v1.VolumeSource{
+						ConfigMap: &v1.ConfigMapVolumeSource{
+							LocalObjectReference: v1.LocalObjectReference{Name: ConfigMapName},
+							Items:                []v1.KeyToPath{{Key: configDataKey, Path: configDataKey}},
+						},
+					},
+				},
+				{Name: "data", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
+				{Name: "logs", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
+			},
+		},
+	}
+	k8sutil.InjectEnv(&podSpec.Spec, c.spec, "")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, c.spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, c.spec)
+
+	replicas := int32(1)
+	d := &apps.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      AppName,
+			Namespace: c.namespacedName.Namespace,
+			Labels:    c.getPodLabels(),
+		},
+		Spec: apps.DeploymentSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: c.getPodLabels(),
+			},
+			Template: podSpec,
+			Replicas: &replicas,
+			Strategy: apps.DeploymentStrategy{
+				Type: apps.RecreateDeploymentStrategyType,
+			},
+		},
+	}
+
+	k8sutil.InjectMetadata(c.spec, "", d, &d.Spec.Template)
+	if err := c.ownerInfo.SetControllerReference(d); err != nil {
+		return nil, errors.Wrapf(err, "failed to set owner reference to dashboard deployment %q", d.Name)
+	}
+	return d, nil
+}
+
+func (c *Cluster) port() int {
+	if c.spec.Dashboard.Port == 0 {
+		return config.GetOperatorSettings().DashboardPort
+	}
+	return c.spec.Dashboard.Port
+}
+
+func (c *Cluster) getPodLabels() map[string]string {
+	return map[string]string{
+		"app":           AppName,
+		"curve_cluster": c.namespacedName.Namespace,
+	}
+}
@@ -0,0 +1,179 @@
+package etcd
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+	batch "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+const (
+	// BackupAppName is the app label and the name of the etcd snapshot cron job
+	BackupAppName = "curve-etcd-backup"
+
+	backupVolumeName       = "snapshots"
+	backupMountPath        = "/curvebs/etcd/snapshots"
+	defaultBackupSchedule  = "0 0 * * *"
+	defaultBackupRetention = 7
+	defaultS3Region        = "us-east-1"
+)
+
+// ReconcileBackup creates the cron job that saves the snapshots of etcd if etcd.backup is enabled, or deletes it.
+// The cron job is applied again when the etcd endpoints change.
+func (c *Cluster) ReconcileBackup() error {
+	backup := c.spec.Etcd.Backup
+	if !backup.Enable {
+		propagation := metav1.DeletePropagationBackground
+		err := c.context.Clientset.BatchV1beta1().CronJobs(c.namespacedName.Namespace).Delete(BackupAppName, &metav1.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil && !kerrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete etcd backup cron job %s", BackupAppName)
+		}
+		return nil
+	}
+	if (backup.PersistentVolumeClaim == "") == (backup.S3 == nil) {
+		return errors.New("one of etcd.backup.persistentVolumeClaim and etcd.backup.s3 must be set")
+	}
+
+	info, err := config.GetClusterInfo(&c.context, c.namespacedName.Namespace)
+	if err != nil {
+		return err
+	}
+	cronJob, err := c.makeBackupCronJob(info.EtcdAddr)
+	if err != nil {
+		return err
+	}
+	if err := k8sutil.Apply(c.context.Client, cronJob); err != nil {
+		return errors.Wrapf(err, "failed to apply etcd backup cron job %s", cronJob.Name)
+	}
+	return nil
+}
+
+// makeBackupCronJob makes the cron job whose init container saves a snapshot from the etcd endpoints, and whose
+// container uploads it to S3 and prunes the old snapshots
+func (c *Cluster) makeBackupCronJob(endpoints string) (*batchv1beta1.CronJob, error) {
+	backup := c.spec.Etcd.Backup
+	labels := map[string]string{
+		"app":           BackupAppName,
+		"curve_cluster": c.namespacedName.Namespace,
+	}
+
+	schedule := backup.Schedule
+	if schedule == "" {
+		schedule = defaultBackupSchedule
+	}
+	retention := backup.Retention
+	if retention == 0 {
+		retention = defaultBackupRetention
+	}
+	image := backup.Image
+	if image == "" {
+		image = c.spec.CurveVersion.Image
+	}
+
+	// the snapshots are saved in the volume claim, or in an empty dir before they are uploaded to S3
+	volume := v1.Volume{Name: backupVolumeName, VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}}
+	var env []v1.EnvVar
+	var envFrom []v1.EnvFromSource
+	if backup.PersistentVolumeClaim != "" {
+		volume.VolumeSource = v1.VolumeSource{
+			PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: backup.PersistentVolumeClaim},
+		}
+	} else {
+		prefix := backup.S3.Prefix
+		if prefix == "" {
+			prefix = c.namespacedName.Namespace + "/"
+		}
+		region := backup.S3.Region
+		if region == "" {
+			region = defaultS3Region
+		}
+		env = []v1.EnvVar{
+			{Name: "S3_ENDPOINT", Value: backup.S3.Endpoint},
+			{Name: "S3_BUCKET", Value: backup.S3.Bucket},
+			{Name: "S3_PREFIX", Value: prefix},
+			{Name: "S3_REGION", Value: region},
+		}
+		envFrom = []v1.EnvFromSource{
+			{SecretRef: &v1.SecretEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: backup.S3.SecretName}}},
+		}
+	}
+	volumeMounts := []v1.VolumeMount{{Name: backupVolumeName, MountPath: backupMountPath}}
+
+	backoffLimit := int32(2)
+	historyLimit := int32(1)
+
+	podSpec := v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: labels,
+		},
+		Spec: v1.PodSpec{
+			InitContainers: []v1.Container{
+				{
+					Name:            "snapshot",
+					Command:         []string{"/bin/bash"},
+					Args:            []string{"-c", saveSnapshot, "snapshot", endpoints, backupMountPath},
+					Image:           c.spec.CurveVersion.Image,
+					ImagePullPolicy: c.spec.CurveVersion.ImagePullPolicy,
+					VolumeMounts:    volumeMounts,
+				},
+			},
+			Containers: []v1.Container{
+				{
+					Name:            "prune",
+					Command:         []string{"/bin/bash"},
+					Args:            []string{"-c", pruneSnapshots, "prune", backupMountPath, strconv.Itoa(retention)},
+					Image:           image,
+					ImagePullPolicy: c.spec.CurveVersion.ImagePullPolicy,
+					VolumeMounts:    volumeMounts,
+					Env:             env,
+					EnvFrom:         envFrom,
+				},
+			},
+			RestartPolicy:     v1.RestartPolicyNever,
+			Volumes:           []v1.Volume{volume},
+			PriorityClassName: c.spec.PriorityClassName("etcd"),
+		},
+	}
+	k8sutil.InjectEnv(&podSpec.Spec, c.spec, "etcd")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, c.spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, c.spec)
+	k8sutil.InjectDNS(&podSpec.Spec, c.spec, "etcd")
+
+	cronJob := &batchv1beta1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      BackupAppName,
+			Namespace: c.namespacedName.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1beta1.CronJobSpec{
+			Schedule:                   schedule,
+			ConcurrencyPolicy:          batchv1beta1.ForbidConcurrent,
+			SuccessfulJobsHistoryLimit: &historyLimit,
+			FailedJobsHistoryLimit:     &historyLimit,
+			JobTemplate: batchv1beta1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: batch.JobSpec{
+					BackoffLimit: &backoffLimit,
+					Template:     podSpec,
+				},
+			},
+		},
+	}
+
+	k8sutil.InjectMetadata(c.spec, "etcd", cronJob, &cronJob.Spec.JobTemplate, &cronJob.Spec.JobTemplate.Spec.Template)
+	err := c.ownerInfo.SetControllerReference(cronJob)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to set owner reference to etcd backup cron job %q", cronJob.Name)
+	}
+
+	return cronJob, nil
+}
@@ -11,7 +11,6 @@ import (
 	"github.com/coreos/pkg/capnslog"
 	"github.com/pkg/errors"
 	appsv1 "k8s.io/api/apps/v1"
-	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 
 	curvev1 "github.com/opencurve/curve-operator/api/v1"
@@ -54,41 +53,50 @@ func New(context clusterd.Context, namespacedName types.NamespacedName, spec cur
 	}
 }
 
-// Start begins the process of running a cluster of curve etcds.
-func (c *Cluster) Start(nodeNameIP map[string]string) error {
-	var etcdEndpoints string
-	var clusterEtcdAddr string
-
-	for _, ipAddr := range nodeNameIP {
-		etcdEndpoints = fmt.Sprint(etcdEndpoints, ipAddr, ":", c.spec.Etcd.PeerPort, ",")
-		clusterEtcdAddr = fmt.Sprint(clusterEtcdAddr, ipAddr, ":", c.spec.Etcd.ClientPort, ",")
+// Endpoints returns the peer and client addresses of etcd members on the nodes, or the addresses of the
+// members of the etcd StatefulSet
+func Endpoints(spec curvev1.CurveClusterSpec, namespace string, nodeNameIP map[string]string) (string, string) {
+	if spec.Etcd.StatefulSet != nil {
+		return statefulSetEndpoints(spec, namespace, int(spec.Etcd.StatefulSet.GetReplicas()))
 	}
-	etcdEndpoints = strings.TrimRight(etcdEndpoints, ",")
-	clusterEtcdAddr = strings.TrimRight(clusterEtcdAddr, ",")
+	var peerAddr, clientAddr string
+	for _, nodeName := range spec.EtcdNodes() {
+		if _, ok := nodeNameIP[nodeName]; !ok {
+			continue
+		}
+		peerAddr = fmt.Sprint(peerAddr, nodeNameIP[nodeName], ":", spec.Etcd.PeerPort, ",")
+		clientAddr = fmt.Sprint(clientAddr, nodeNameIP[nodeName], ":", spec.Etcd.ClientPort, ",")
+	}
+	return strings.TrimRight(peerAddr, ","), strings.TrimRight(clientAddr, ",")
+}
 
-	// Create etcd override configmap
-	err := c.createOverrideConfigMap(etcdEndpoints, clusterEtcdAddr)
-	if err != nil {
-		return errors.Wrap(err, "failed to create etcd override configmap")
+// Start begins the process of running a cluster of curve etcds.
+func (c *Cluster) Start(nodeNameIP map[string]string) error {
+	if c.spec.Etcd.StatefulSet != nil {
+		return c.startStatefulSet()
 	}
 
 	// reorder the nodeNameIP according to the order of nodes spec defined by the user
-	// nodes:
+	// etcd.nodes(or nodes if not set):
 	// - node1 - curve-etcd-a
 	// - node2  - curve-etcd-b
 	// - node3 - curve-etcd-c
 	nodeNamesOrdered := make([]string, 0)
-	for _, n := range c.spec.Nodes {
-		for nodeName := range nodeNameIP {
-			if n == nodeName {
-				nodeNamesOrdered = append(nodeNamesOrdered, nodeName)
-			}
+	for _, n := range c.spec.EtcdNodes() {
+		if _, ok := nodeNameIP[n]; ok {
+			nodeNamesOrdered = append(nodeNamesOrdered, n)
 		}
 	}
 
 	// Won't appear generally
 	if len(nodeNamesOrdered) != 3 {
-		return errors.New("Nodes spec field is not 3")
+		return errors.New("etcd nodes count is not 3")
+	}
+
+	etcdEndpoints, clusterEtcdAddr := Endpoints(c.spec, c.namespacedName.Namespace, nodeNameIP)
+	err := c.recordEndpoints(etcdEndpoints, clusterEtcdAddr)
+	if err != nil {
+		return err
 	}
 
 	hostId := 0
@@ -146,19 +154,12 @@ func (c *Cluster) Start(nodeNameIP map[string]string) error {
 			return errors.Wrap(err, "failed to create etcd Deployment")
 		}
 
-		newDeployment, err := c.context.Clientset.AppsV1().Deployments(c.namespacedName.Namespace).Create(d)
+		// the deployment is only updated when the rendered spec or config changed to avoid restarting pods
+		newDeployment, err := k8sutil.CreateOrUpdateDeployment(&c.context, d)
 		if err != nil {
-			if !kerrors.IsAlreadyExists(err) {
-				return errors.Wrapf(err, "failed to create etcd deployment %s", resourceName)
-			}
-			logger.Infof("deployment for etcd %s already exists. updating if needed", resourceName)
-
-			// TODO:Update the daemon Deployment
-			// if err := updateDeploymentAndWait(c.context, c.clusterInfo, d, config.MgrType, mgrConfig.DaemonID, c.spec.SkipUpgradeChecks, false); err != nil {
-			// 	logger.Errorf("failed to update mgr deployment %q. %v", resourceName, err)
-			// }
-		} else {
-			logger.Infof("Deployment %s has been created , waiting for startup", newDeployment.GetName())
+			return errors.Wrapf(err, "failed to create etcd deployment %s", resourceName)
+		}
+		if newDeployment != nil {
 			deploymentsToWaitFor = append(deploymentsToWaitFor, newDeployment)
 		}
 		// update condition type and phase etc.
@@ -169,6 +170,6 @@ func (c *Cluster) Start(nodeNameIP map[string]string) error {
 		deploymentsToWaitFor); err != nil {
 		return err
 	}
-	k8sutil.UpdateCondition(context.TODO(), &c.context, c.namespacedName, curvev1.ConditionTypeEtcdReady, curvev1.ConditionTrue, curvev1.ConditionEtcdClusterCreatedReason, "Etcd cluster has been created")
+	k8sutil.SetReady(context.TODO(), &c.context, c.namespacedName, curvev1.ConditionTypeEtcdReady, curvev1.ConditionEtcdClusterCreatedReason, "Etcd cluster has been created")
 	return nil
 }
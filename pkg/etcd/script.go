@@ -0,0 +1,112 @@
+package etcd
+
+// startMember is the script that starts a member of the etcd StatefulSet, the pod name is the member name.
+// A member without data joins the running cluster and replaces its old self that lost the data, or
+// bootstraps the cluster with all replicas if no member is running.
+//
+// $1 client port, $2 peer port, $3 replicas, $4 the DNS name of the headless service, $5 log level
+const startMember = `
+set -o pipefail
+client_port=$1 peer_port=$2 replicas=$3 domain=$4 log_level=$5
+etcd=/curvebs/etcd/sbin/etcd
+etcdctl=/curvebs/etcd/sbin/etcdctl
+data_dir=/curvebs/etcd/data
+export ETCDCTL_API=3
+
+name=$POD_NAME
+peer_url=http://$name.$domain:$peer_port
+client_url=http://$name.$domain:$client_port
+
+endpoints="" initial_cluster=""
+for ((i = 0; i < replicas; i++)); do
+  member=${name%-*}-$i
+  endpoints="$endpoints,http://$member.$domain:$client_port"
+  initial_cluster="$initial_cluster,$member=http://$member.$domain:$peer_port"
+done
+endpoints=${endpoints#,} initial_cluster=${initial_cluster#,}
+state=new
+
+if [ ! -d $data_dir/member ] && members=$($etcdctl --endpoints=$endpoints member list 2>/dev/null); then
+  # the line of a member is "id, status, name, peer urls, client urls, is learner",
+  # the name is empty if the member has not started
+  line=$(echo "$members" | awk -F', ' -v url=$peer_url '$4 == url')
+  id=$(echo "$line" | awk -F', ' '{print $1}')
+  started=$(echo "$line" | awk -F', ' '{print $3}')
+  if [ -n "$id" ] && [ -n "$started" ]; then
+    echo "member $name lost its data, replacing member $id"
+    $etcdctl --endpoints=$endpoints member remove $id || exit 1
+    id=""
+  fi
+  if [ -z "$id" ]; then
+    echo "adding member $name to the cluster"
+    $etcdctl --endpoints=$endpoints member add $name --peer-urls=$peer_url || exit 1
+  fi
+  members=$($etcdctl --endpoints=$endpoints member list) || exit 1
+  # a member that has not started has no name, all members are named by the host of their peer urls
+  initial_cluster=$(echo "$members" | awk -F', ' '{ split($4, host, "[/.]"); printf "%s=%s,", host[3], $4 }')
+  initial_cluster=${initial_cluster%,}
+  state=existing
+fi
+
+echo "starting member $name of $initial_cluster, state $state"
+exec $etcd --name=$name --data-dir=$data_dir \
+  --listen-peer-urls=http://0.0.0.0:$peer_port --listen-client-urls=http://0.0.0.0:$client_port \
+  --initial-advertise-peer-urls=$peer_url --advertise-client-urls=$client_url \
+  --initial-cluster=$initial_cluster --initial-cluster-state=$state --initial-cluster-token=curve-etcd \
+  ${log_level:+--log-level=$log_level}
+`
+
+// removeMember is the script that removes the member named $2 from the cluster at endpoints $1
+const removeMember = `
+export ETCDCTL_API=3
+etcdctl=/curvebs/etcd/sbin/etcdctl
+id=$($etcdctl --endpoints=$1 member list | awk -F', ' -v name=$2 '$3 == name {print $1}') || exit 1
+if [ -n "$id" ]; then
+  $etcdctl --endpoints=$1 member remove $id
+fi
+`
+
+// saveSnapshot is the script that saves a snapshot of etcd to the directory $2 by the first healthy one of
+// the comma separated client addresses $1
+const saveSnapshot = `
+export ETCDCTL_API=3
+etcdctl=/curvebs/etcd/sbin/etcdctl
+file=$2/etcd-snapshot-$(date -u +%Y%m%d%H%M%S).db
+for endpoint in ${1//,/ }; do
+  if $etcdctl --endpoints=http://$endpoint snapshot save $file; then
+    echo "saved snapshot $file from $endpoint"
+    exit 0
+  fi
+  rm -f $file $file.part
+done
+echo "failed to save snapshot from any of $1"
+exit 1
+`
+
+// pruneSnapshots is the script that keeps the newest $2 snapshots in the directory $1, or uploads the
+// snapshots in $1 to the S3 bucket $S3_BUCKET and keeps the newest $2 snapshots in the bucket
+const pruneSnapshots = `
+dir=$1 retention=$2
+if [ -z "$S3_BUCKET" ]; then
+  ls -1 $dir | grep '^etcd-snapshot-.*\.db$' | sort -r | tail -n +$((retention + 1)) | while read name; do
+    rm -f $dir/$name && echo "pruned snapshot $name"
+  done
+  exit 0
+fi
+
+s3() {
+  curl -sSf --aws-sigv4 "aws:amz:$S3_REGION:s3" --user "$AWS_ACCESS_KEY_ID:$AWS_SECRET_ACCESS_KEY" "$@"
+}
+url=${S3_ENDPOINT%/}/$S3_BUCKET
+for file in $dir/etcd-snapshot-*.db; do
+  s3 -T $file "$url/$S3_PREFIX$(basename $file)" || exit 1
+  echo "uploaded snapshot $(basename $file) to $S3_BUCKET/$S3_PREFIX"
+done
+
+listing=$(s3 "$url?list-type=2&prefix=$S3_PREFIX") || exit 1
+echo "$listing" | grep -o '<Key>[^<]*</Key>' | sed 's/<\/\?Key>//g' | grep 'etcd-snapshot-.*\.db$' |
+  sort -r | tail -n +$((retention + 1)) | while read key; do
+  s3 -X DELETE "$url/$key" && echo "pruned snapshot $key"
+done
+exit 0
+`
@@ -12,43 +12,19 @@ import (
 
 	"github.com/opencurve/curve-operator/pkg/config"
 	"github.com/opencurve/curve-operator/pkg/daemon"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
 )
 
-// createOverrideConfigMap create configMap override to record the endpoints of etcd for mds use
-func (c *Cluster) createOverrideConfigMap(etcd_endpoints string, clusterEtcdAddr string) error {
-	etcdConfigMapData := map[string]string{
-		config.EtcdOvverideConfigMapDataKey: etcd_endpoints,
-		config.ClusterEtcdAddr:              clusterEtcdAddr,
-	}
-
-	// etcd-endpoints-override configmap only has one "etcdEndpoints" key that the value is etcd cluster endpoints
-	overrideCM := &v1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      config.EtcdOverrideConfigMapName,
-			Namespace: c.namespacedName.Namespace,
-		},
-		Data: etcdConfigMapData,
-	}
-	err := c.ownerInfo.SetControllerReference(overrideCM)
+// recordEndpoints records the endpoints of etcd in cluster info for mds use
+func (c *Cluster) recordEndpoints(etcdPeerAddr string, clusterEtcdAddr string) error {
+	err := config.UpdateClusterInfo(&c.context, c.namespacedName.Namespace, c.ownerInfo, c.spec, func(info *config.ClusterInfo) {
+		info.EtcdPeerAddr = etcdPeerAddr
+		info.EtcdAddr = clusterEtcdAddr
+	})
 	if err != nil {
-		return errors.Wrapf(err, "failed to set owner reference to etcd override configmap %q", config.EtcdConfigMapName)
+		return errors.Wrap(err, "failed to record etcd endpoints")
 	}
-
-	_, err = c.context.Clientset.CoreV1().ConfigMaps(c.namespacedName.Namespace).Create(overrideCM)
-	if err != nil {
-		if !kerrors.IsAlreadyExists(err) {
-			return errors.Wrapf(err, "failed to create override configmap %s", c.namespacedName.Namespace)
-		}
-		logger.Infof("ConfigMap for override etcd endpoints %s already exists. updating if needed", config.EtcdOverrideConfigMapName)
-
-		// TODO:Update the daemon Deployment
-		// if err := updateDeploymentAndWait(c.context, c.clusterInfo, d, config.MgrType, mgrConfig.DaemonID, c.spec.SkipUpgradeChecks, false); err != nil {
-		// 	logger.Errorf("failed to update mgr deployment %q. %v", resourceName, err)
-		// }
-	} else {
-		logger.Infof("ConfigMap %s for override etcd endpoints has been created", config.EtcdOverrideConfigMapName)
-	}
-
+	logger.Infof("etcd endpoints %s have been recorded", clusterEtcdAddr)
 	return nil
 }
 
@@ -77,7 +53,7 @@ func (c *Cluster) createEtcdConfigMap(etcdConfig *etcdConfig) error {
 
 	// 4. create curve-etcd-conf-[a,b,...] configmap for each one deployment
 	etcdConfigMapData := map[string]string{
-		config.EtcdConfigMapDataKey: EtcdConfigTemp,
+		config.EtcdConfigMapDataKey: daemon.SetEtcdLogLevel(EtcdConfigTemp, c.spec.Etcd.LogLevel),
 	}
 
 	cm := &v1.ConfigMap{
@@ -88,14 +64,15 @@ func (c *Cluster) createEtcdConfigMap(etcdConfig *etcdConfig) error {
 		Data: etcdConfigMapData,
 	}
 
+	k8sutil.InjectMetadata(c.spec, "etcd", cm)
 	err = c.ownerInfo.SetControllerReference(cm)
 	if err != nil {
 		return errors.Wrapf(err, "failed to set owner reference for etcd configmap [ %v ]", etcdConfig.CurrentConfigMapName)
 	}
 
 	// 5. create etcd configmap in cluster
-	_, err = c.context.Clientset.CoreV1().ConfigMaps(c.namespacedName.Namespace).Create(cm)
-	if err != nil && !kerrors.IsAlreadyExists(err) {
+	err = k8sutil.Apply(c.context.Client, cm)
+	if err != nil {
 		return errors.Wrapf(err, "failed to create etcd configmap %s", c.namespacedName.Namespace)
 	}
 
@@ -118,16 +95,21 @@ func (c *Cluster) makeDeployment(nodeName string, ip string, etcdConfig *etcdCon
 			InitContainers: []v1.Container{
 				c.makeChmodDirInitContainer(etcdConfig),
 			},
-			Containers: []v1.Container{
+			Containers: append([]v1.Container{
 				c.makeEtcdDaemonContainer(nodeName, ip, etcdConfig, etcdConfig.ClusterEtcdHttpAddr),
-			},
-			NodeName:      nodeName,
-			RestartPolicy: v1.RestartPolicyAlways,
-			HostNetwork:   true,
-			DNSPolicy:     v1.DNSClusterFirstWithHostNet,
-			Volumes:       volumes,
+			}, daemon.LogRotateContainers(c.spec.Logging, etcdConfig.DataPathMap.ContainerLogDir, c.spec.CurveVersion.Image, c.spec.CurveVersion.ImagePullPolicy)...),
+			Affinity:          k8sutil.DaemonAffinity(nodeName, AppName, c.namespacedName.Namespace),
+			RestartPolicy:     v1.RestartPolicyAlways,
+			HostNetwork:       true,
+			DNSPolicy:         v1.DNSClusterFirstWithHostNet,
+			Volumes:           volumes,
+			PriorityClassName: c.spec.PriorityClassName("etcd"),
 		},
 	}
+	k8sutil.InjectEnv(&podSpec.Spec, c.spec, "etcd")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, c.spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, c.spec)
+	k8sutil.InjectDNS(&podSpec.Spec, c.spec, "etcd")
 
 	replicas := int32(1)
 
@@ -149,6 +131,7 @@ func (c *Cluster) makeDeployment(nodeName string, ip string, etcdConfig *etcdCon
 		},
 	}
 	// set ownerReference
+	k8sutil.InjectMetadata(c.spec, "etcd", d, &d.Spec.Template)
 	err := c.ownerInfo.SetControllerReference(d)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to set owner reference to etcd deployment %q", d.Name)
@@ -217,7 +200,11 @@ func (c *Cluster) makeEtcdDaemonContainer(nodeName string, ip string, etcdConfig
 				Protocol:      v1.ProtocolTCP,
 			},
 		},
-		Env: []v1.EnvVar{{Name: "TZ", Value: "Asia/Hangzhou"}},
+		// /health fails when the cluster has no leader, so it's only used for readiness to avoid
+		// restarting all members when quorum is lost
+		LivenessProbe:  k8sutil.MakeProbe(k8sutil.TCPProbeHandler(c.spec.Etcd.ClientPort), c.spec.Etcd.LivenessProbe, k8sutil.DefaultLivenessProbe),
+		ReadinessProbe: k8sutil.MakeProbe(k8sutil.HTTPProbeHandler(c.spec.Etcd.ClientPort, "/health"), c.spec.Etcd.ReadinessProbe, k8sutil.DefaultReadinessProbe),
+		Env:            []v1.EnvVar{{Name: "TZ", Value: "Asia/Hangzhou"}},
 	}
 	return container
 }
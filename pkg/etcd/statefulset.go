@@ -0,0 +1,263 @@
+package etcd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// dataVolumeName is the name of the volume claim template of the etcd StatefulSet, the claim of a member
+// is named data-curve-etcd-<index>
+const dataVolumeName = "data"
+
+// memberName returns the name of the member at index of the etcd StatefulSet, which is also its pod name
+func memberName(index int) string {
+	return fmt.Sprintf("%s-%d", AppName, index)
+}
+
+// statefulSetEndpoints returns the peer and client addresses of the first replicas members of the etcd
+// StatefulSet, the members are resolved by the headless service
+func statefulSetEndpoints(spec curvev1.CurveClusterSpec, namespace string, replicas int) (string, string) {
+	domain := k8sutil.ServiceDNSName(AppName, namespace)
+	peerAddrs := make([]string, 0, replicas)
+	clientAddrs := make([]string, 0, replicas)
+	for i := 0; i < replicas; i++ {
+		host := fmt.Sprintf("%s.%s", memberName(i), domain)
+		peerAddrs = append(peerAddrs, fmt.Sprint(host, ":", spec.Etcd.PeerPort))
+		clientAddrs = append(clientAddrs, fmt.Sprint(host, ":", spec.Etcd.ClientPort))
+	}
+	return strings.Join(peerAddrs, ","), strings.Join(clientAddrs, ",")
+}
+
+// getStatefulSetLabels returns the labels of the etcd StatefulSet and its pods
+func (c *Cluster) getStatefulSetLabels() map[string]string {
+	return map[string]string{
+		"app":           AppName,
+		"curve_cluster": c.namespacedName.Namespace,
+	}
+}
+
+// startStatefulSet runs etcd as a StatefulSet. The members are removed one by one before the StatefulSet
+// is scaled down to keep the quorum, and the members added by scaling up join the cluster by themselves.
+func (c *Cluster) startStatefulSet() error {
+	replicas := c.spec.Etcd.StatefulSet.GetReplicas()
+
+	if err := c.createStatefulSetService(); err != nil {
+		return err
+	}
+
+	existing, err := c.context.Clientset.AppsV1().StatefulSets(c.namespacedName.Namespace).Get(AppName, metav1.GetOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to get etcd statefulset %q", AppName)
+	}
+	current := int32(0)
+	if err == nil && existing.Spec.Replicas != nil {
+		current = *existing.Spec.Replicas
+	}
+	if current > replicas {
+		if err := c.removeMembers(int(current), int(replicas)); err != nil {
+			return err
+		}
+	}
+
+	etcdEndpoints, clusterEtcdAddr := statefulSetEndpoints(c.spec, c.namespacedName.Namespace, int(replicas))
+	if err := c.recordEndpoints(etcdEndpoints, clusterEtcdAddr); err != nil {
+		return err
+	}
+
+	s, err := c.makeStatefulSet()
+	if err != nil {
+		return err
+	}
+	if err := k8sutil.Apply(c.context.Client, s); err != nil {
+		return errors.Wrap(err, "failed to create etcd statefulset")
+	}
+
+	for i := int(current) - 1; i >= int(replicas); i-- {
+		c.deleteMemberClaim(i)
+	}
+
+	logger.Info("starting etcd")
+	// the volumes of the members may be provisioned before they start
+	if err := k8sutil.WaitForStatefulSetToStart(c.context.Clientset, 3*time.Second, 5*time.Minute, s); err != nil {
+		return err
+	}
+	k8sutil.SetReady(context.TODO(), &c.context, c.namespacedName, curvev1.ConditionTypeEtcdReady, curvev1.ConditionEtcdClusterCreatedReason, "Etcd cluster has been created")
+	return nil
+}
+
+// removeMembers removes the members from index current-1 down to replicas from the cluster by the first member
+func (c *Cluster) removeMembers(current, replicas int) error {
+	pod, err := c.context.Clientset.CoreV1().Pods(c.namespacedName.Namespace).Get(memberName(0), metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get etcd pod %q to remove members", memberName(0))
+	}
+
+	_, clientAddrs := statefulSetEndpoints(c.spec, c.namespacedName.Namespace, replicas)
+	endpoints := make([]string, 0, replicas)
+	for _, addr := range strings.Split(clientAddrs, ",") {
+		endpoints = append(endpoints, "http://"+addr)
+	}
+	for i := current - 1; i >= replicas; i-- {
+		logger.Infof("removing etcd member %s", memberName(i))
+		output, err := k8sutil.ExecInPod(&c.context, pod, []string{"bash", "-c", removeMember, "--",
+			strings.Join(endpoints, ","), memberName(i)})
+		if err != nil {
+			return errors.Wrapf(err, "failed to remove etcd member %s: %s", memberName(i), output)
+		}
+	}
+	return nil
+}
+
+// deleteMemberClaim deletes the data volume claim of the removed member, it's deleted after the pod is gone
+func (c *Cluster) deleteMemberClaim(index int) {
+	name := fmt.Sprintf("%s-%s", dataVolumeName, memberName(index))
+	err := c.context.Clientset.CoreV1().PersistentVolumeClaims(c.namespacedName.Namespace).Delete(name, &metav1.DeleteOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		logger.Warningf("failed to delete volume claim %q of removed etcd member. %v", name, err)
+	}
+}
+
+// createStatefulSetService creates the headless service that gives the members of the etcd StatefulSet stable DNS names
+func (c *Cluster) createStatefulSetService() error {
+	svc := k8sutil.MakeHeadlessService(AppName, c.namespacedName.Namespace, c.getStatefulSetLabels(),
+		map[string]int{"client": c.spec.Etcd.ClientPort, "peer": c.spec.Etcd.PeerPort})
+
+	k8sutil.InjectMetadata(c.spec, "etcd", svc)
+	err := c.ownerInfo.SetControllerReference(svc)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set owner reference to etcd service %q", AppName)
+	}
+
+	err = k8sutil.Apply(c.context.Client, svc)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create etcd service %q", AppName)
+	}
+	return nil
+}
+
+// makeStatefulSet makes the etcd StatefulSet whose members keep their data in persistent volume claims
+func (c *Cluster) makeStatefulSet() (*apps.StatefulSet, error) {
+	spec := c.spec.Etcd.StatefulSet
+	replicas := spec.GetReplicas()
+	volumeMounts := []v1.VolumeMount{{Name: dataVolumeName, MountPath: ContainerDataDir}}
+	ports := []v1.ContainerPort{
+		{
+			Name:          "listen-port",
+			ContainerPort: int32(c.spec.Etcd.ClientPort),
+			Protocol:      v1.ProtocolTCP,
+		},
+		{
+			Name:          "peer-port",
+			ContainerPort: int32(c.spec.Etcd.PeerPort),
+			Protocol:      v1.ProtocolTCP,
+		},
+	}
+
+	podSpec := v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: c.getStatefulSetLabels(),
+		},
+		Spec: v1.PodSpec{
+			InitContainers: []v1.Container{
+				{
+					Name:            "chmod",
+					Command:         []string{"chmod", "700", ContainerDataDir},
+					Image:           c.spec.CurveVersion.Image,
+					ImagePullPolicy: c.spec.CurveVersion.ImagePullPolicy,
+					VolumeMounts:    volumeMounts,
+				},
+			},
+			Containers: []v1.Container{
+				{
+					Name:    "etcd",
+					Command: []string{"bash", "-c", startMember, "--"},
+					Args: []string{
+						strconv.Itoa(c.spec.Etcd.ClientPort),
+						strconv.Itoa(c.spec.Etcd.PeerPort),
+						strconv.Itoa(int(replicas)),
+						k8sutil.ServiceDNSName(AppName, c.namespacedName.Namespace),
+						c.spec.Etcd.LogLevel,
+					},
+					Image:           c.spec.CurveVersion.Image,
+					ImagePullPolicy: c.spec.CurveVersion.ImagePullPolicy,
+					VolumeMounts:    volumeMounts,
+					Ports:           ports,
+					LivenessProbe:   k8sutil.MakeProbe(k8sutil.TCPProbeHandler(c.spec.Etcd.ClientPort), c.spec.Etcd.LivenessProbe, k8sutil.DefaultLivenessProbe),
+					ReadinessProbe:  k8sutil.MakeProbe(k8sutil.HTTPProbeHandler(c.spec.Etcd.ClientPort, "/health"), c.spec.Etcd.ReadinessProbe, k8sutil.DefaultReadinessProbe),
+					Env: []v1.EnvVar{
+						{Name: "TZ", Value: "Asia/Hangzhou"},
+						{Name: "POD_NAME", ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.name"}}},
+					},
+				},
+			},
+			// the members are spread on different nodes
+			Affinity: &v1.Affinity{
+				PodAntiAffinity: &v1.PodAntiAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: []v1.PodAffinityTerm{
+						{
+							LabelSelector: &metav1.LabelSelector{MatchLabels: c.getStatefulSetLabels()},
+							TopologyKey:   v1.LabelHostname,
+						},
+					},
+				},
+			},
+			RestartPolicy:     v1.RestartPolicyAlways,
+			PriorityClassName: c.spec.PriorityClassName("etcd"),
+		},
+	}
+	k8sutil.InjectEnv(&podSpec.Spec, c.spec, "etcd")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, c.spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, c.spec)
+	k8sutil.InjectDNS(&podSpec.Spec, c.spec, "etcd")
+
+	claim := v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: dataVolumeName,
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes:      []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			StorageClassName: spec.StorageClassName,
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: spec.GetSize()},
+			},
+		},
+	}
+
+	s := &apps.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      AppName,
+			Namespace: c.namespacedName.Namespace,
+			Labels:    c.getStatefulSetLabels(),
+		},
+		Spec: apps.StatefulSetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: c.getStatefulSetLabels(),
+			},
+			ServiceName: AppName,
+			Replicas:    &replicas,
+			// the members bootstrap the cluster together
+			PodManagementPolicy:  apps.ParallelPodManagement,
+			Template:             podSpec,
+			VolumeClaimTemplates: []v1.PersistentVolumeClaim{claim},
+		},
+	}
+	k8sutil.InjectMetadata(c.spec, "etcd", s, &s.Spec.Template)
+	err := c.ownerInfo.SetControllerReference(s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to set owner reference to etcd statefulset %q", s.Name)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,38 @@
+// Package testutil holds the helpers shared by the tests of the daemons
+package testutil
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// NewVarsServer serves the bvars of a daemon on /vars until the test ends, it returns the address of the server
+func NewVarsServer(t *testing.T, vars string) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/vars" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, vars)
+	}))
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+// SplitAddr returns the host and the port of the address of the server
+func SplitAddr(t *testing.T, addr string) (string, int) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return host, p
+}
@@ -0,0 +1,85 @@
+package k8sutil
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+// DaemonAffinity returns the affinity that pins the daemon pod to the node, and forbids two pods of the same
+// daemon in one cluster to be scheduled on the same node, so that the replicas are spread across nodes for HA.
+// It's used instead of setting nodeName because nodeName skips the scheduler and the anti-affinity.
+func DaemonAffinity(nodeName string, appName string, clusterName string) *v1.Affinity {
+	return &v1.Affinity{
+		NodeAffinity: &v1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{
+				NodeSelectorTerms: []v1.NodeSelectorTerm{
+					{
+						MatchFields: []v1.NodeSelectorRequirement{
+							{
+								Key:      "metadata.name",
+								Operator: v1.NodeSelectorOpIn,
+								Values:   []string{nodeName},
+							},
+						},
+					},
+				},
+			},
+		},
+		PodAntiAffinity: &v1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []v1.PodAffinityTerm{
+				{
+					LabelSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{
+							"app":           appName,
+							"curve_cluster": clusterName,
+						},
+					},
+					TopologyKey: v1.LabelHostname,
+				},
+			},
+		},
+	}
+}
+
+// InjectArchAffinity requires the pod to run on the nodes of the architectures that the curve image supports,
+// so that the pods are not started on the incompatible nodes of a mixed cluster. The requirement is added to
+// each term of the node affinity as the terms are ORed.
+func InjectArchAffinity(podSpec *v1.PodSpec, spec curvev1.CurveClusterSpec) {
+	if len(spec.CurveVersion.Architectures) == 0 {
+		return
+	}
+	requirement := v1.NodeSelectorRequirement{
+		Key:      v1.LabelArchStable,
+		Operator: v1.NodeSelectorOpIn,
+		Values:   spec.CurveVersion.Architectures,
+	}
+
+	if podSpec.Affinity == nil {
+		podSpec.Affinity = &v1.Affinity{}
+	}
+	if podSpec.Affinity.NodeAffinity == nil {
+		podSpec.Affinity.NodeAffinity = &v1.NodeAffinity{}
+	}
+	nodeAffinity := podSpec.Affinity.NodeAffinity
+	if nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &v1.NodeSelector{}
+	}
+	selector := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if len(selector.NodeSelectorTerms) == 0 {
+		selector.NodeSelectorTerms = []v1.NodeSelectorTerm{{}}
+	}
+	for i := range selector.NodeSelectorTerms {
+		term := &selector.NodeSelectorTerms[i]
+		term.MatchExpressions = append(term.MatchExpressions, requirement)
+	}
+}
+
+// InjectSchedulerName sets spec.schedulerName to the pod, the pod is scheduled by the default scheduler if it's
+// not set. It has no effect on the pods that are bound to a node by their node name.
+func InjectSchedulerName(podSpec *v1.PodSpec, spec curvev1.CurveClusterSpec) {
+	if spec.SchedulerName != "" {
+		podSpec.SchedulerName = spec.SchedulerName
+	}
+}
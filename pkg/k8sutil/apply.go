@@ -0,0 +1,38 @@
+package k8sutil
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// FieldManager is the field manager of the objects applied by the operator
+const FieldManager = "curve-operator"
+
+// Apply creates or patches obj by server-side apply. The operator only owns the fields set in obj, so the
+// fields set by others such as ArgoCD or an autoscaler are kept, and the fields of obj win on conflicts
+func Apply(c client.Client, obj runtime.Object) error {
+	gvk, err := apiutil.GVKForObject(obj, scheme.Scheme)
+	if err != nil {
+		return errors.Wrap(err, "failed to get group version kind of object to apply")
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return errors.Wrap(err, "failed to access metadata of object to apply")
+	}
+
+	// an apply request must have apiVersion and kind, and must not have resourceVersion and managedFields
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	accessor.SetResourceVersion("")
+	accessor.SetManagedFields(nil)
+
+	if err := c.Patch(context.TODO(), obj, client.Apply, client.FieldOwner(FieldManager), client.ForceOwnership); err != nil {
+		return errors.Wrapf(err, "failed to apply %s %q", gvk.Kind, accessor.GetName())
+	}
+	return nil
+}
@@ -13,89 +13,236 @@ import (
 
 	curvev1 "github.com/opencurve/curve-operator/api/v1"
 	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/version"
 )
 
-// UpdateCondition function will export each condition into the cluster custom resource
-func UpdateCondition(ctx context.Context, c *clusterd.Context, namespaceName types.NamespacedName, conditionType curvev1.ConditionType, status curvev1.ConditionStatus, reason curvev1.ConditionReason, message string) {
+// defaultHistoryLimit is the number of the transitions kept in status.history if spec.historyLimit is not set
+const defaultHistoryLimit = 20
+
+// SetProgressing sets the condition of a step False while the step is in progress, such as FormatedReady while
+// the devices are being formatted
+func SetProgressing(ctx context.Context, c *clusterd.Context, namespaceName types.NamespacedName, conditionType curvev1.ConditionType, reason curvev1.ConditionReason, message string) {
+	updateCondition(ctx, c, namespaceName, conditionType, curvev1.ConditionFalse, reason, message)
+}
+
+// SetReady sets the condition of a step True after the step is done, or the Ready condition of the cluster
+// after it's reconciled
+func SetReady(ctx context.Context, c *clusterd.Context, namespaceName types.NamespacedName, conditionType curvev1.ConditionType, reason curvev1.ConditionReason, message string) {
+	updateCondition(ctx, c, namespaceName, conditionType, curvev1.ConditionTrue, reason, message)
+}
+
+// SetError sets the Failed condition of the cluster True with the reason of the failure
+func SetError(ctx context.Context, c *clusterd.Context, namespaceName types.NamespacedName, reason curvev1.ConditionReason, message string) {
+	updateCondition(ctx, c, namespaceName, curvev1.ConditionTypeFailure, curvev1.ConditionTrue, reason, message)
+}
+
+// SetDeleting sets the Deleting condition of the cluster True, the phase of the cluster stays Deleting afterwards
+func SetDeleting(ctx context.Context, c *clusterd.Context, namespaceName types.NamespacedName, message string) {
+	updateCondition(ctx, c, namespaceName, curvev1.ConditionTypeDeleting, curvev1.ConditionTrue, curvev1.ConditionDeletingClusterReason, message)
+}
+
+// SetWarning sets a warning condition of the cluster True if active, or False when it's gone, the phase of the
+// cluster is not changed
+func SetWarning(ctx context.Context, c *clusterd.Context, namespaceName types.NamespacedName, conditionType curvev1.ConditionType, active bool, reason curvev1.ConditionReason, message string) {
 	cluster := &curvev1.CurveCluster{}
 	if err := c.Client.Get(ctx, namespaceName, cluster); err != nil {
 		logger.Errorf("failed to get cluster %v to update the conditions. %v", namespaceName, err)
 		return
 	}
 
-	UpdateClusterCondition(c, cluster, namespaceName, conditionType, status, reason, message, false)
+	status := curvev1.ConditionFalse
+	if active {
+		status = curvev1.ConditionTrue
+	}
+	setWarningCondition(cluster, conditionType, status, reason, message)
+
+	if err := UpdateStatus(c.Client, namespaceName, cluster); err != nil {
+		logger.Errorf("failed to update cluster condition %s to %s. %v", conditionType, status, err)
+	}
 }
 
-// UpdateClusterCondition function will export each condition into the cluster custom resource
-func UpdateClusterCondition(c *clusterd.Context, cluster *curvev1.CurveCluster, namespaceName types.NamespacedName, conditionType curvev1.ConditionType, status curvev1.ConditionStatus,
-	reason curvev1.ConditionReason, message string, preserveAllConditions bool) {
+// SetPhase sets the phase of the cluster to a step that has no condition, such as CreatingPools
+func SetPhase(ctx context.Context, c *clusterd.Context, namespaceName types.NamespacedName, phase curvev1.ConditionType, message string) {
+	cluster := &curvev1.CurveCluster{}
+	if err := c.Client.Get(ctx, namespaceName, cluster); err != nil {
+		logger.Errorf("failed to get cluster %v to update the phase. %v", namespaceName, err)
+		return
+	}
+	// Once the cluster begins deleting, the phase should not revert back to any other phase
+	if cluster.Status.Phase == curvev1.ClusterPhaseDeleting {
+		return
+	}
+	if cluster.Status.Phase != phase {
+		recordTransition(cluster, curvev1.ClusterTransition{Phase: phase, Message: message})
+	}
+	cluster.Status.Phase = phase
+	cluster.Status.Message = message
+	if err := UpdateStatus(c.Client, namespaceName, cluster); err != nil {
+		logger.Errorf("failed to update cluster phase to %s. %v", phase, err)
+	}
+}
 
-	// Keep the conditions that already existed if they are in the list of long-term conditions,
-	// otherwise discard the temporary conditions
-	var currentCondition *curvev1.ClusterCondition
-	var conditions []curvev1.ClusterCondition
-	for _, condition := range cluster.Status.Conditions {
-		// Only keep conditions in the list if it's a persisted condition such as the cluster creation being completed.
-		// The transient conditions are not persisted. However, if the currently requested condition is not expected to
-		// reset the transient conditions, they are retained. For example, if the operator is checking for curve health
-		// in the middle of the reconcile, the progress condition should not be reset by the status check update.
-		if preserveAllConditions ||
-			condition.Type == curvev1.ConditionTypeEtcdReady ||
-			condition.Type == curvev1.ConditionTypeMdsReady ||
-			condition.Type == curvev1.ConditionTypeFormatedReady ||
-			condition.Type == curvev1.ConditionTypeChunkServerReady ||
-			condition.Type == curvev1.ConditionTypeSnapShotCloneReady {
-			if conditionType != condition.Type {
-				conditions = append(conditions, condition)
-				continue
-			}
-
-			// Update the existing condition with the new status
-			currentCondition = condition.DeepCopy()
-			if currentCondition.Status != status || currentCondition.Message != message {
-				// Update the last transition time since the status changed
-				currentCondition.LastTransitionTime = metav1.NewTime(time.Now())
-			}
-			currentCondition.Status = status
-			currentCondition.Reason = reason
-			currentCondition.Message = message
-		}
+// updateCondition exports the condition into the status of the cluster custom resource
+func updateCondition(ctx context.Context, c *clusterd.Context, namespaceName types.NamespacedName, conditionType curvev1.ConditionType, status curvev1.ConditionStatus, reason curvev1.ConditionReason, message string) {
+	cluster := &curvev1.CurveCluster{}
+	if err := c.Client.Get(ctx, namespaceName, cluster); err != nil {
+		logger.Errorf("failed to get cluster %v to update the conditions. %v", namespaceName, err)
+		return
 	}
 
-	// Create a new condition since not found in the existing conditions
-	if currentCondition == nil {
-		currentCondition = &curvev1.ClusterCondition{
-			Type:               conditionType,
-			Status:             status,
-			Reason:             reason,
-			Message:            message,
-			LastTransitionTime: metav1.NewTime(time.Now()),
-		}
+	setClusterCondition(cluster, conditionType, status, reason, message)
+	logger.Debugf("CurveCluster %q status: %q. %q", namespaceName.Namespace, cluster.Status.Phase, cluster.Status.Message)
+
+	if err := UpdateStatus(c.Client, namespaceName, cluster); err != nil {
+		logger.Errorf("failed to update cluster condition %s to %s. %v", conditionType, status, err)
 	}
+}
 
-	conditions = append(conditions, *currentCondition)
-	cluster.Status.Conditions = conditions
+// setClusterCondition sets the condition in the status of cluster and translates it to the phase. The conditions
+// of the steps are kept, and the others such as Failed are transient that are discarded by a new condition.
+func setClusterCondition(cluster *curvev1.CurveCluster, conditionType curvev1.ConditionType, status curvev1.ConditionStatus,
+	reason curvev1.ConditionReason, message string) {
+	previous, previousPhase := findClusterCondition(cluster, conditionType), cluster.Status.Phase
+	cluster.Status.Conditions = mergeCondition(cluster, conditionType, status, reason, message, func(t curvev1.ConditionType) bool {
+		return isStepCondition(t) || isWarningCondition(t)
+	})
 
 	// Once the cluster begins deleting, the phase should not revert back to any other phase
 	if cluster.Status.Phase != curvev1.ClusterPhaseDeleting {
-		cluster.Status.Phase = translateConditionType2Phase(conditionType)
-		cluster.Status.Message = currentCondition.Message
+		cluster.Status.Phase = translateConditionType2Phase(conditionType, status)
+		cluster.Status.Message = message
 		cluster.Status.CurveVersion.Image = cluster.Spec.CurveVersion.Image
-		logger.Debugf("CurveCluster %q status: %q. %q", namespaceName.Namespace, cluster.Status.Phase, cluster.Status.Message)
+		if conditionType == curvev1.ConditionTypeClusterReady {
+			cluster.Status.OperatorVersion = version.Version
+		}
+		// the generation is observed when the reconcile of it is finished
+		if conditionType == curvev1.ConditionTypeClusterReady || conditionType == curvev1.ConditionTypeFailure {
+			cluster.Status.ObservedGeneration = cluster.Generation
+		}
 	}
 
-	if err := UpdateStatus(c.Client, namespaceName, cluster); err != nil {
-		logger.Errorf("failed to update cluster condition to %+v. %v", *currentCondition, err)
+	if previous == nil || previous.Status != status || previous.Reason != reason || cluster.Status.Phase != previousPhase {
+		recordTransition(cluster, curvev1.ClusterTransition{
+			Phase:   cluster.Status.Phase,
+			Type:    conditionType,
+			Status:  status,
+			Reason:  reason,
+			Message: message,
+		})
+	}
+}
+
+// setWarningCondition sets the warning condition in the status of cluster and keeps all the other conditions
+func setWarningCondition(cluster *curvev1.CurveCluster, conditionType curvev1.ConditionType, status curvev1.ConditionStatus,
+	reason curvev1.ConditionReason, message string) {
+	previous := findClusterCondition(cluster, conditionType)
+	cluster.Status.Conditions = mergeCondition(cluster, conditionType, status, reason, message, func(curvev1.ConditionType) bool {
+		return true
+	})
+	// the warnings are checked by every reconcile, only their changes are recorded
+	if (previous == nil && status == curvev1.ConditionTrue) || (previous != nil && (previous.Status != status || previous.Reason != reason)) {
+		recordTransition(cluster, curvev1.ClusterTransition{
+			Phase:   cluster.Status.Phase,
+			Type:    conditionType,
+			Status:  status,
+			Reason:  reason,
+			Message: message,
+		})
+	}
+}
+
+// findClusterCondition returns a copy of the condition of the type in the status of cluster, or nil
+func findClusterCondition(cluster *curvev1.CurveCluster, conditionType curvev1.ConditionType) *curvev1.ClusterCondition {
+	for _, condition := range cluster.Status.Conditions {
+		if condition.Type == conditionType {
+			return condition.DeepCopy()
+		}
+	}
+	return nil
+}
+
+// recordTransition appends the transition to status.history, the oldest ones beyond spec.historyLimit are dropped
+func recordTransition(cluster *curvev1.CurveCluster, transition curvev1.ClusterTransition) {
+	limit := defaultHistoryLimit
+	if cluster.Spec != nil && cluster.Spec.HistoryLimit > 0 {
+		limit = cluster.Spec.HistoryLimit
+	}
+	transition.Time = metav1.NewTime(time.Now())
+	history := append(cluster.Status.History, transition)
+	if len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	cluster.Status.History = history
+}
+
+// mergeCondition returns the conditions of cluster with the condition set, the other conditions are kept if keep
+// returns true for them
+func mergeCondition(cluster *curvev1.CurveCluster, conditionType curvev1.ConditionType, status curvev1.ConditionStatus,
+	reason curvev1.ConditionReason, message string, keep func(curvev1.ConditionType) bool) []curvev1.ClusterCondition {
+	now := metav1.NewTime(time.Now())
+
+	var currentCondition *curvev1.ClusterCondition
+	var conditions []curvev1.ClusterCondition
+	for _, condition := range cluster.Status.Conditions {
+		if condition.Type == conditionType {
+			currentCondition = condition.DeepCopy()
+			continue
+		}
+		if keep(condition.Type) {
+			conditions = append(conditions, condition)
+		}
+	}
+
+	if currentCondition == nil {
+		currentCondition = &curvev1.ClusterCondition{Type: conditionType, LastTransitionTime: now}
+	} else if currentCondition.Status != status {
+		// the transition time is updated only if the status changed
+		currentCondition.LastTransitionTime = now
 	}
+	currentCondition.Status = status
+	currentCondition.Reason = reason
+	currentCondition.Message = message
+	currentCondition.ObservedGeneration = cluster.Generation
+
+	return append(conditions, *currentCondition)
+}
+
+// isWarningCondition returns true if the condition is a warning about the environment of the cluster, which is
+// kept until the check changes it and doesn't change the phase
+func isWarningCondition(conditionType curvev1.ConditionType) bool {
+	return conditionType == curvev1.ConditionTypeClockSkew
 }
 
-func translateConditionType2Phase(conditionType curvev1.ConditionType) curvev1.ConditionType {
-	if conditionType == curvev1.ConditionTypeEtcdReady ||
+// isStepCondition returns true if the condition is of a step to create the cluster, which is kept until the
+// step changes it
+func isStepCondition(conditionType curvev1.ConditionType) bool {
+	return conditionType == curvev1.ConditionTypeEtcdReady ||
 		conditionType == curvev1.ConditionTypeMdsReady ||
+		conditionType == curvev1.ConditionTypeMdsLeaderReady ||
 		conditionType == curvev1.ConditionTypeFormatedReady ||
 		conditionType == curvev1.ConditionTypeChunkServerReady ||
-		conditionType == curvev1.ConditionTypeSnapShotCloneReady {
-		return curvev1.ClusterPhasePending
+		conditionType == curvev1.ConditionTypeSnapShotCloneReady ||
+		isPoolCondition(conditionType)
+}
+
+// isPoolCondition returns true if the condition is of a step to create the pools
+func isPoolCondition(conditionType curvev1.ConditionType) bool {
+	return conditionType == curvev1.ConditionTypePhysicalPoolReady ||
+		conditionType == curvev1.ConditionTypeLogicalPoolReady
+}
+
+// translateConditionType2Phase returns the phase of the cluster after the condition is set, the devices are being
+// formatted until FormatedReady is True, the pools are being created until their conditions are True, and the
+// other steps are provisioning the daemons
+func translateConditionType2Phase(conditionType curvev1.ConditionType, status curvev1.ConditionStatus) curvev1.ConditionType {
+	if conditionType == curvev1.ConditionTypeFormatedReady && status != curvev1.ConditionTrue {
+		return curvev1.ClusterPhaseFormatting
+	}
+	if isPoolCondition(conditionType) && status != curvev1.ConditionTrue {
+		return curvev1.ClusterPhaseCreatingPools
+	}
+	if isStepCondition(conditionType) {
+		return curvev1.ClusterPhaseProvisioning
 	}
 	return conditionType
 }
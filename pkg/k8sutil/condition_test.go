@@ -0,0 +1,142 @@
+package k8sutil
+
+import (
+	"testing"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+func findCondition(cluster *curvev1.CurveCluster, conditionType curvev1.ConditionType) *curvev1.ClusterCondition {
+	for i := range cluster.Status.Conditions {
+		if cluster.Status.Conditions[i].Type == conditionType {
+			return &cluster.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+func TestSetClusterConditionPolarity(t *testing.T) {
+	cluster := &curvev1.CurveCluster{Spec: &curvev1.CurveClusterSpec{}}
+	cluster.Generation = 3
+
+	setClusterCondition(cluster, curvev1.ConditionTypeFormatedReady, curvev1.ConditionFalse, curvev1.ConditionFormatingChunkfilePoolReason, "Formatting chunkfilepool")
+	formatted := findCondition(cluster, curvev1.ConditionTypeFormatedReady)
+	if formatted == nil || formatted.Status != curvev1.ConditionFalse {
+		t.Fatalf("expected FormatedReady False while formatting, got %+v", formatted)
+	}
+	if formatted.ObservedGeneration != 3 {
+		t.Errorf("expected observedGeneration 3, got %d", formatted.ObservedGeneration)
+	}
+	if cluster.Status.Phase != curvev1.ClusterPhaseFormatting {
+		t.Errorf("expected phase Formatting, got %s", cluster.Status.Phase)
+	}
+	since := formatted.LastTransitionTime
+
+	setClusterCondition(cluster, curvev1.ConditionTypeFormatedReady, curvev1.ConditionTrue, curvev1.ConditionFormatChunkfilePoolReason, "Formating chunkfilepool successed")
+	formatted = findCondition(cluster, curvev1.ConditionTypeFormatedReady)
+	if formatted.Status != curvev1.ConditionTrue || formatted.Reason != curvev1.ConditionFormatChunkfilePoolReason {
+		t.Fatalf("expected FormatedReady True after formatting, got %+v", formatted)
+	}
+	if formatted.LastTransitionTime.Before(&since) {
+		t.Errorf("expected transition time not before %v, got %v", since, formatted.LastTransitionTime)
+	}
+	if len(cluster.Status.Conditions) != 1 {
+		t.Errorf("expected 1 condition, got %d", len(cluster.Status.Conditions))
+	}
+	if cluster.Status.Phase != curvev1.ClusterPhaseProvisioning {
+		t.Errorf("expected phase Provisioning, got %s", cluster.Status.Phase)
+	}
+	if cluster.Status.ObservedGeneration != 0 {
+		t.Errorf("expected generation not observed before reconciled, got %d", cluster.Status.ObservedGeneration)
+	}
+}
+
+func TestSetClusterConditionTransient(t *testing.T) {
+	cluster := &curvev1.CurveCluster{Spec: &curvev1.CurveClusterSpec{}}
+	cluster.Generation = 2
+
+	setClusterCondition(cluster, curvev1.ConditionTypeEtcdReady, curvev1.ConditionTrue, curvev1.ConditionEtcdClusterCreatedReason, "Etcd cluster has been created")
+	setClusterCondition(cluster, curvev1.ConditionTypeFailure, curvev1.ConditionTrue, curvev1.ConditionReconcileFailed, "failed")
+	if cluster.Status.Phase != curvev1.ClusterPhaseError {
+		t.Errorf("expected phase Failed, got %s", cluster.Status.Phase)
+	}
+	if findCondition(cluster, curvev1.ConditionTypeEtcdReady) == nil {
+		t.Errorf("expected EtcdReady kept after failure")
+	}
+
+	// the failure is discarded once the cluster is reconciled
+	setClusterCondition(cluster, curvev1.ConditionTypeClusterReady, curvev1.ConditionTrue, curvev1.ConditionReconcileSucceeded, "Reconcile curvecluster successed")
+	if findCondition(cluster, curvev1.ConditionTypeFailure) != nil {
+		t.Errorf("expected Failed discarded after reconciled, got %+v", cluster.Status.Conditions)
+	}
+	if cluster.Status.Phase != curvev1.ClusterPhaseReady {
+		t.Errorf("expected phase Ready, got %s", cluster.Status.Phase)
+	}
+	if cluster.Status.ObservedGeneration != cluster.Generation {
+		t.Errorf("expected observedGeneration %d, got %d", cluster.Generation, cluster.Status.ObservedGeneration)
+	}
+}
+
+func TestSetClusterConditionDeleting(t *testing.T) {
+	cluster := &curvev1.CurveCluster{Spec: &curvev1.CurveClusterSpec{}}
+
+	setClusterCondition(cluster, curvev1.ConditionTypeDeleting, curvev1.ConditionTrue, curvev1.ConditionDeletingClusterReason, "Reconcile curvecluster deleting")
+	setClusterCondition(cluster, curvev1.ConditionTypeFailure, curvev1.ConditionTrue, curvev1.ConditionReconcileFailed, "failed")
+	if cluster.Status.Phase != curvev1.ClusterPhaseDeleting {
+		t.Errorf("expected phase stays Deleting, got %s", cluster.Status.Phase)
+	}
+}
+
+func TestSetWarningCondition(t *testing.T) {
+	cluster := &curvev1.CurveCluster{Spec: &curvev1.CurveClusterSpec{}}
+
+	setClusterCondition(cluster, curvev1.ConditionTypeEtcdReady, curvev1.ConditionTrue, curvev1.ConditionEtcdClusterCreatedReason, "Etcd cluster has been created")
+	setWarningCondition(cluster, curvev1.ConditionTypeClockSkew, curvev1.ConditionTrue, curvev1.ConditionClockSkewedReason, "clock skew 800ms")
+	if cluster.Status.Phase != curvev1.ClusterPhaseProvisioning {
+		t.Errorf("expected phase Provisioning not changed by warning, got %s", cluster.Status.Phase)
+	}
+
+	// the warning is kept by the conditions of the steps and the failures
+	setClusterCondition(cluster, curvev1.ConditionTypeFailure, curvev1.ConditionTrue, curvev1.ConditionReconcileFailed, "failed")
+	setClusterCondition(cluster, curvev1.ConditionTypeClusterReady, curvev1.ConditionTrue, curvev1.ConditionReconcileSucceeded, "Reconcile curvecluster successed")
+	skew := findCondition(cluster, curvev1.ConditionTypeClockSkew)
+	if skew == nil || skew.Status != curvev1.ConditionTrue {
+		t.Fatalf("expected ClockSkew True kept, got %+v", cluster.Status.Conditions)
+	}
+
+	setWarningCondition(cluster, curvev1.ConditionTypeClockSkew, curvev1.ConditionFalse, curvev1.ConditionClockSynchronizedReason, "clock skew 10ms")
+	if skew := findCondition(cluster, curvev1.ConditionTypeClockSkew); skew.Status != curvev1.ConditionFalse {
+		t.Errorf("expected ClockSkew False, got %+v", skew)
+	}
+	if findCondition(cluster, curvev1.ConditionTypeClusterReady) == nil || cluster.Status.Phase != curvev1.ClusterPhaseReady {
+		t.Errorf("expected Ready kept by warning, got %+v", cluster.Status)
+	}
+}
+
+func TestSetClusterConditionHistory(t *testing.T) {
+	cluster := &curvev1.CurveCluster{Spec: &curvev1.CurveClusterSpec{HistoryLimit: 3}}
+
+	setClusterCondition(cluster, curvev1.ConditionTypeFormatedReady, curvev1.ConditionFalse, curvev1.ConditionFormatingChunkfilePoolReason, "Formatting chunkfilepool")
+	// the same condition again is not a transition
+	setClusterCondition(cluster, curvev1.ConditionTypeFormatedReady, curvev1.ConditionFalse, curvev1.ConditionFormatingChunkfilePoolReason, "Formatting chunkfilepool")
+	if len(cluster.Status.History) != 1 {
+		t.Fatalf("expected 1 transition, got %+v", cluster.Status.History)
+	}
+	if h := cluster.Status.History[0]; h.Type != curvev1.ConditionTypeFormatedReady || h.Phase != curvev1.ClusterPhaseFormatting || h.Time.IsZero() {
+		t.Errorf("expected the transition of FormatedReady in phase Formatting, got %+v", h)
+	}
+
+	setClusterCondition(cluster, curvev1.ConditionTypeFailure, curvev1.ConditionTrue, curvev1.ConditionReconcileFailed, "format job failed")
+	setClusterCondition(cluster, curvev1.ConditionTypeFormatedReady, curvev1.ConditionTrue, curvev1.ConditionFormatChunkfilePoolReason, "Formating chunkfilepool successed")
+	setClusterCondition(cluster, curvev1.ConditionTypeClusterReady, curvev1.ConditionTrue, curvev1.ConditionReconcileSucceeded, "Reconcile curvecluster successed")
+	// the oldest transitions are dropped beyond the limit, the failure is kept after its condition is discarded
+	if len(cluster.Status.History) != 3 {
+		t.Fatalf("expected 3 transitions, got %+v", cluster.Status.History)
+	}
+	if h := cluster.Status.History[0]; h.Type != curvev1.ConditionTypeFailure || h.Message != "format job failed" {
+		t.Errorf("expected the failure kept in history, got %+v", h)
+	}
+	if h := cluster.Status.History[2]; h.Phase != curvev1.ClusterPhaseReady {
+		t.Errorf("expected the last transition to Ready, got %+v", h)
+	}
+}
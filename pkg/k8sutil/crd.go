@@ -0,0 +1,144 @@
+package k8sutil
+
+import (
+	"context"
+	"io/fs"
+	"time"
+
+	"github.com/pkg/errors"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/opencurve/curve-operator/pkg/version"
+)
+
+// CRDOperatorVersionAnnotation is the version of the operator that installed the CRD, the CRD is not downgraded
+// by an older operator
+const CRDOperatorVersionAnnotation = "operator.curve.io/operator-version"
+
+// InstallCRDs creates the CRDs in the yaml files or updates them to the ones of the operator, and returns their
+// names. The versions that objects are stored in are kept unserved if the CRD drops them, the API server refuses
+// to remove them from the CRD until the objects are migrated and they are pruned from status.storedVersions. The
+// conversion webhook and the annotations set on the installed CRD such as the CA injection of cert-manager are kept.
+func InstallCRDs(c client.Client, files fs.FS) ([]string, error) {
+	paths, err := fs.Glob(files, "*/*.yaml")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list CRDs")
+	}
+
+	var names []string
+	for _, path := range paths {
+		data, err := fs.ReadFile(files, path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read CRD %s", path)
+		}
+		crd, err := parseCRD(data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse CRD %s", path)
+		}
+		// the other replicas of the operator may install it at the same time
+		if err := retry.RetryOnConflict(retry.DefaultRetry, func() error { return installCRD(c, crd.DeepCopy()) }); err != nil {
+			return nil, err
+		}
+		names = append(names, crd.Name)
+	}
+	return names, nil
+}
+
+// parseCRD returns the CRD of the yaml annotated with the version of the operator
+func parseCRD(data []byte) (*apiextensionsv1beta1.CustomResourceDefinition, error) {
+	crd := &apiextensionsv1beta1.CustomResourceDefinition{}
+	if err := yaml.Unmarshal(data, crd); err != nil {
+		return nil, err
+	}
+	if crd.Name == "" {
+		return nil, errors.New("CRD has no name")
+	}
+	if crd.Annotations == nil {
+		crd.Annotations = map[string]string{}
+	}
+	crd.Annotations[CRDOperatorVersionAnnotation] = version.Version
+	return crd, nil
+}
+
+func installCRD(c client.Client, crd *apiextensionsv1beta1.CustomResourceDefinition) error {
+	existing := &apiextensionsv1beta1.CustomResourceDefinition{}
+	err := c.Get(context.TODO(), types.NamespacedName{Name: crd.Name}, existing)
+	if kerrors.IsNotFound(err) {
+		if err := c.Create(context.TODO(), crd); err != nil {
+			return errors.Wrapf(err, "failed to create CRD %q", crd.Name)
+		}
+		logger.Infof("created CRD %q", crd.Name)
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to get CRD %q", crd.Name)
+	}
+
+	if err := version.CheckOperatorVersion(existing.Annotations[CRDOperatorVersionAnnotation]); err != nil {
+		logger.Warningf("CRD %q is not updated. %v", crd.Name, err)
+		return nil
+	}
+
+	mergeCRD(existing, crd)
+	crd.ResourceVersion = existing.ResourceVersion
+	if err := c.Update(context.TODO(), crd); err != nil {
+		return errors.Wrapf(err, "failed to update CRD %q", crd.Name)
+	}
+	logger.Infof("updated CRD %q", crd.Name)
+	return nil
+}
+
+// mergeCRD keeps the stored versions, the conversion webhook and the annotations of the installed CRD in crd
+func mergeCRD(existing, crd *apiextensionsv1beta1.CustomResourceDefinition) {
+	for _, stored := range existing.Status.StoredVersions {
+		served := false
+		for _, v := range crd.Spec.Versions {
+			if v.Name == stored {
+				served = true
+			}
+		}
+		if !served {
+			logger.Warningf("objects of CRD %q are stored in version %s that is not served anymore, it's kept in the CRD until they are migrated",
+				crd.Name, stored)
+			crd.Spec.Versions = append(crd.Spec.Versions, apiextensionsv1beta1.CustomResourceDefinitionVersion{Name: stored})
+		}
+	}
+	if existing.Spec.Conversion != nil && existing.Spec.Conversion.Strategy == apiextensionsv1beta1.WebhookConverter &&
+		(crd.Spec.Conversion == nil || crd.Spec.Conversion.Strategy == apiextensionsv1beta1.NoneConverter) {
+		crd.Spec.Conversion = existing.Spec.Conversion
+	}
+	for k, v := range existing.Annotations {
+		if _, ok := crd.Annotations[k]; !ok {
+			crd.Annotations[k] = v
+		}
+	}
+}
+
+// WaitForCRDsEstablished waits for the API server to serve the CRDs
+func WaitForCRDsEstablished(c client.Client, names []string, timeout time.Duration) error {
+	for _, name := range names {
+		err := wait.PollImmediate(time.Second, timeout, func() (bool, error) {
+			crd := &apiextensionsv1beta1.CustomResourceDefinition{}
+			if err := c.Get(context.TODO(), types.NamespacedName{Name: name}, crd); err != nil {
+				logger.Warningf("failed to get CRD %q. %v", name, err)
+				return false, nil
+			}
+			for _, condition := range crd.Status.Conditions {
+				if condition.Type == apiextensionsv1beta1.Established && condition.Status == apiextensionsv1beta1.ConditionTrue {
+					return true, nil
+				}
+			}
+			return false, nil
+		})
+		if err != nil {
+			return errors.Wrapf(err, "CRD %q is not established in %v", name, timeout)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package k8sutil
+
+import (
+	"io/fs"
+	"testing"
+
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+
+	"github.com/opencurve/curve-operator/config/crd"
+	"github.com/opencurve/curve-operator/pkg/version"
+)
+
+func TestMergeCRD(t *testing.T) {
+	paths, err := fs.Glob(crd.Bases, "*/*.yaml")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("expected the embedded CRDs, got %v %v", paths, err)
+	}
+	var clusters *apiextensionsv1beta1.CustomResourceDefinition
+	for _, path := range paths {
+		data, err := fs.ReadFile(crd.Bases, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := parseCRD(data)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", path, err)
+		}
+		if parsed.Annotations[CRDOperatorVersionAnnotation] != version.Version {
+			t.Errorf("expected CRD %s annotated with the operator version, got %v", parsed.Name, parsed.Annotations)
+		}
+		if parsed.Name == "curveclusters.operator.curve.io" {
+			clusters = parsed
+		}
+	}
+	if clusters == nil || len(clusters.Spec.Versions) != 2 {
+		t.Fatalf("expected CRD of curveclusters with v1 and v1beta1, got %+v", clusters)
+	}
+
+	// the installed CRD stores objects in a version that is dropped, and has the conversion webhook patched
+	existing := clusters.DeepCopy()
+	existing.Annotations = map[string]string{"cert-manager.io/inject-ca-from": "curvebs/serving-cert"}
+	existing.Spec.Conversion = &apiextensionsv1beta1.CustomResourceConversion{Strategy: apiextensionsv1beta1.WebhookConverter}
+	existing.Status.StoredVersions = []string{"v1alpha1", "v1"}
+	mergeCRD(existing, clusters)
+
+	last := clusters.Spec.Versions[len(clusters.Spec.Versions)-1]
+	if len(clusters.Spec.Versions) != 3 || last.Name != "v1alpha1" || last.Served || last.Storage {
+		t.Errorf("expected the stored version kept unserved, got %+v", clusters.Spec.Versions)
+	}
+	if clusters.Spec.Conversion == nil || clusters.Spec.Conversion.Strategy != apiextensionsv1beta1.WebhookConverter {
+		t.Errorf("expected the conversion webhook kept, got %+v", clusters.Spec.Conversion)
+	}
+	if clusters.Annotations["cert-manager.io/inject-ca-from"] == "" || clusters.Annotations[CRDOperatorVersionAnnotation] != version.Version {
+		t.Errorf("expected the annotations of the installed CRD kept, got %v", clusters.Annotations)
+	}
+}
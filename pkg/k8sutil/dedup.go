@@ -0,0 +1,120 @@
+package k8sutil
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// DefaultDedupInterval is how often an identical event or log is reported at most
+	DefaultDedupInterval = 10 * time.Minute
+	// dedupExpireIntervals is the number of intervals after which an entry that is not seen again is forgotten
+	dedupExpireIntervals = 6
+)
+
+type dedupEntry struct {
+	lastReported time.Time
+	lastSeen     time.Time
+	suppressed   int
+}
+
+// Deduplicator reports an identical message once per interval and counts the suppressed ones
+type Deduplicator struct {
+	interval time.Duration
+	now      func() time.Time
+
+	mutex   sync.Mutex
+	entries map[string]*dedupEntry
+}
+
+func NewDeduplicator(interval time.Duration) *Deduplicator {
+	return &Deduplicator{
+		interval: interval,
+		now:      time.Now,
+		entries:  make(map[string]*dedupEntry),
+	}
+}
+
+// Observe tells whether the message of the key should be reported now, suppressed is the number of the
+// identical messages that were not reported since the last report
+func (d *Deduplicator) Observe(key string) (report bool, suppressed int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := d.now()
+	for k, e := range d.entries {
+		if now.Sub(e.lastSeen) > d.interval*dedupExpireIntervals {
+			delete(d.entries, k)
+		}
+	}
+
+	e, ok := d.entries[key]
+	if !ok {
+		d.entries[key] = &dedupEntry{lastReported: now, lastSeen: now}
+		return true, 0
+	}
+	e.lastSeen = now
+	if now.Sub(e.lastReported) < d.interval {
+		e.suppressed++
+		return false, 0
+	}
+	suppressed = e.suppressed
+	e.lastReported, e.suppressed = now, 0
+	return true, suppressed
+}
+
+// dedupRecorder is an event recorder that records an identical event once per interval
+type dedupRecorder struct {
+	recorder record.EventRecorder
+	dedup    *Deduplicator
+}
+
+// NewDedupRecorder returns a recorder that records an identical event of an object once per interval, the
+// number of the suppressed ones is appended to the message of the next recorded one
+func NewDedupRecorder(recorder record.EventRecorder, interval time.Duration) record.EventRecorder {
+	return &dedupRecorder{recorder: recorder, dedup: NewDeduplicator(interval)}
+}
+
+// observe returns the message to record, it is empty if the event is suppressed
+func (r *dedupRecorder) observe(object runtime.Object, eventtype, reason, message string) string {
+	key := fmt.Sprintf("%s/%s/%s", eventtype, reason, message)
+	if accessor, err := meta.Accessor(object); err == nil {
+		key = fmt.Sprintf("%s/%s/%s/%s", accessor.GetUID(), accessor.GetNamespace(), accessor.GetName(), key)
+	}
+	report, suppressed := r.dedup.Observe(key)
+	if !report {
+		return ""
+	}
+	if suppressed > 0 {
+		message = fmt.Sprintf("%s (repeated %d times)", message, suppressed+1)
+	}
+	return message
+}
+
+func (r *dedupRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	if message = r.observe(object, eventtype, reason, message); message != "" {
+		r.recorder.Event(object, eventtype, reason, message)
+	}
+}
+
+func (r *dedupRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.Event(object, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (r *dedupRecorder) PastEventf(object runtime.Object, timestamp metav1.Time, eventtype, reason, messageFmt string, args ...interface{}) {
+	if message := r.observe(object, eventtype, reason, fmt.Sprintf(messageFmt, args...)); message != "" {
+		r.recorder.PastEventf(object, timestamp, eventtype, reason, "%s", message)
+	}
+}
+
+func (r *dedupRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	if message := r.observe(object, eventtype, reason, fmt.Sprintf(messageFmt, args...)); message != "" {
+		r.recorder.AnnotatedEventf(object, annotations, eventtype, reason, "%s", message)
+	}
+}
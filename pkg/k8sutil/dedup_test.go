@@ -0,0 +1,53 @@
+package k8sutil
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestDedupRecorder(t *testing.T) {
+	now := time.Date(2026, 10, 17, 2, 0, 0, 0, time.UTC)
+	fake := record.NewFakeRecorder(10)
+	recorder := NewDedupRecorder(fake, time.Minute).(*dedupRecorder)
+	recorder.dedup.now = func() time.Time { return now }
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "curvebs", Name: "prepare-chunkfile", UID: "1"}}
+	other := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "curvebs", Name: "create-pool", UID: "2"}}
+	recorder.Eventf(pod, v1.EventTypeWarning, "JobFailed", "exit code %d", 1)
+	recorder.Eventf(pod, v1.EventTypeWarning, "JobFailed", "exit code %d", 1)
+	recorder.Eventf(pod, v1.EventTypeWarning, "JobFailed", "exit code %d", 1)
+	recorder.Eventf(pod, v1.EventTypeWarning, "JobFailed", "exit code %d", 2)
+	recorder.Eventf(other, v1.EventTypeWarning, "JobFailed", "exit code %d", 1)
+	now = now.Add(time.Minute)
+	recorder.Eventf(pod, v1.EventTypeWarning, "JobFailed", "exit code %d", 1)
+
+	for _, expected := range []string{
+		"Warning JobFailed exit code 1",
+		"Warning JobFailed exit code 2",
+		"Warning JobFailed exit code 1",
+		"Warning JobFailed exit code 1 (repeated 3 times)",
+	} {
+		select {
+		case e := <-fake.Events:
+			if e != expected {
+				t.Errorf("expected event %q, got %q", expected, e)
+			}
+		default:
+			t.Fatalf("expected event %q, got none", expected)
+		}
+	}
+	if len(fake.Events) != 0 {
+		t.Errorf("expected the repeated events to be suppressed, got %q", <-fake.Events)
+	}
+
+	// the entries not seen for a while are forgotten
+	now = now.Add(time.Minute * dedupExpireIntervals * 2)
+	recorder.Eventf(other, v1.EventTypeWarning, "JobFailed", "exit code %d", 2)
+	if len(recorder.dedup.entries) != 1 {
+		t.Errorf("expected the stale entries to be pruned, got %d entries", len(recorder.dedup.entries))
+	}
+}
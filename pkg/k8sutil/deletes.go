@@ -0,0 +1,44 @@
+package k8sutil
+
+import (
+	"sync"
+	"time"
+)
+
+// expectedDeleteTTL is how long a delete recorded by ExpectDelete is kept, the record of a delete that failed or
+// found nothing to delete is forgotten after it
+const expectedDeleteTTL = 10 * time.Minute
+
+// expectedDeletes are the child resources that the operator is deleting by itself, keyed by kind/namespace/name
+var expectedDeletes = struct {
+	sync.Mutex
+	deletes map[string]time.Time
+}{deletes: map[string]time.Time{}}
+
+// ExpectDelete records that the operator deletes the object of the kind such as "Deployment", it's called before
+// the delete is issued so that the delete event is not taken as a deletion by others, which brings the cluster to
+// be created again
+func ExpectDelete(kind, namespace, name string) {
+	expectedDeletes.Lock()
+	defer expectedDeletes.Unlock()
+	now := time.Now()
+	for key, t := range expectedDeletes.deletes {
+		if now.Sub(t) > expectedDeleteTTL {
+			delete(expectedDeletes.deletes, key)
+		}
+	}
+	expectedDeletes.deletes[kind+"/"+namespace+"/"+name] = now
+}
+
+// DeleteExpected returns true if the delete of the object is recorded by ExpectDelete, the record is forgotten
+func DeleteExpected(kind, namespace, name string) bool {
+	expectedDeletes.Lock()
+	defer expectedDeletes.Unlock()
+	key := kind + "/" + namespace + "/" + name
+	t, ok := expectedDeletes.deletes[key]
+	if !ok {
+		return false
+	}
+	delete(expectedDeletes.deletes, key)
+	return time.Since(t) <= expectedDeleteTTL
+}
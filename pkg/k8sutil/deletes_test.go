@@ -0,0 +1,26 @@
+package k8sutil
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpectDelete(t *testing.T) {
+	ExpectDelete("Deployment", "curvebs", "curve-chunkserver-node1-vdb")
+	if DeleteExpected("ConfigMap", "curvebs", "curve-chunkserver-node1-vdb") {
+		t.Error("expected the delete of another kind not expected")
+	}
+	if !DeleteExpected("Deployment", "curvebs", "curve-chunkserver-node1-vdb") {
+		t.Error("expected the recorded delete expected")
+	}
+	// the record is forgotten once the delete is seen, a later delete is made by others
+	if DeleteExpected("Deployment", "curvebs", "curve-chunkserver-node1-vdb") {
+		t.Error("expected the delete expected only once")
+	}
+
+	ExpectDelete("Job", "curvebs", "prepare-chunkfile")
+	expectedDeletes.deletes["Job/curvebs/prepare-chunkfile"] = time.Now().Add(-expectedDeleteTTL - time.Minute)
+	if DeleteExpected("Job", "curvebs", "prepare-chunkfile") {
+		t.Error("expected the stale record of delete forgotten")
+	}
+}
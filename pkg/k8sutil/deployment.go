@@ -49,7 +49,7 @@ func WaitForDeploymentToStart(clientSet kubernetes.Interface, interval time.Dura
 	// wait.PollImmediate to continue to poll
 	// and the lastErr is the error of the last poll
 	var lastErr error
-	_ = wait.PollImmediate(interval, timeout, func() (bool, error) {
+	err := wait.PollImmediate(interval, timeout, func() (bool, error) {
 		deployment, err := clientSet.AppsV1().Deployments(d.GetNamespace()).
 			Get(d.GetName(), metav1.GetOptions{})
 		if err != nil {
@@ -85,6 +85,9 @@ func WaitForDeploymentToStart(clientSet kubernetes.Interface, interval time.Dura
 		}
 		return false, nil
 	})
+	if err == nil {
+		return nil
+	}
 	if lastErr != nil {
 		return errors.Wrapf(lastErr, "failed to waiting deplyoment %s to start after %vs waiting",
 			d.GetName(), timeout.Seconds())
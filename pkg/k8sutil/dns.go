@@ -0,0 +1,20 @@
+package k8sutil
+
+import (
+	v1 "k8s.io/api/core/v1"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+// InjectDNS sets the DNS policy, DNS config and host aliases of the daemon in spec to the pod, the DNS policy of
+// the pod is kept if the daemon has no DNS policy
+func InjectDNS(podSpec *v1.PodSpec, spec curvev1.CurveClusterSpec, daemon string) {
+	dns := spec.DaemonDNS(daemon)
+	if dns.DNSPolicy != "" {
+		podSpec.DNSPolicy = dns.DNSPolicy
+	}
+	if dns.DNSConfig != nil {
+		podSpec.DNSConfig = dns.DNSConfig.DeepCopy()
+	}
+	podSpec.HostAliases = append(podSpec.HostAliases, dns.HostAliases...)
+}
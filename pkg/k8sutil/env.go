@@ -0,0 +1,22 @@
+package k8sutil
+
+import (
+	v1 "k8s.io/api/core/v1"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+// InjectEnv appends the environment variables and their sources of the daemon to all the containers and
+// init containers of the pod, the variables override the ones of the same names set by the operator
+func InjectEnv(podSpec *v1.PodSpec, spec curvev1.CurveClusterSpec, daemon string) {
+	env, envFrom := spec.DaemonEnv(daemon)
+	if len(env) == 0 && len(envFrom) == 0 {
+		return
+	}
+	for _, containers := range [][]v1.Container{podSpec.InitContainers, podSpec.Containers} {
+		for i := range containers {
+			containers[i].Env = append(containers[i].Env, env...)
+			containers[i].EnvFrom = append(containers[i].EnvFrom, envFrom...)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package k8sutil
+
+import (
+	"context"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+)
+
+// ClusterErrors is implemented by the errors that know their categories, such as the errors of some nodes
+type ClusterErrors interface {
+	ClusterErrors() []curvev1.ClusterError
+}
+
+// CategorizedError is an error of a category, the errors without a category are transient
+type CategorizedError struct {
+	Category curvev1.ErrorCategory
+	Node     string
+	Err      error
+}
+
+func (e *CategorizedError) Error() string {
+	if e.Node != "" {
+		return "node " + e.Node + ": " + e.Err.Error()
+	}
+	return e.Err.Error()
+}
+
+func (e *CategorizedError) ClusterErrors() []curvev1.ClusterError {
+	return []curvev1.ClusterError{{Category: e.Category, Node: e.Node, Message: e.Err.Error()}}
+}
+
+// NewConfigError returns an error of the spec, retrying doesn't help until the spec is fixed
+func NewConfigError(err error) error {
+	return &CategorizedError{Category: curvev1.ErrorCategoryConfigError, Err: err}
+}
+
+// NewNodeError returns an error of the node
+func NewNodeError(nodeName string, err error) error {
+	return &CategorizedError{Category: curvev1.ErrorCategoryNodeFailure, Node: nodeName, Err: err}
+}
+
+// AggregateError is the errors of the steps that are not stopped by the others, such as the jobs on the nodes
+type AggregateError []error
+
+func (e AggregateError) Error() string {
+	var messages []string
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e AggregateError) ClusterErrors() []curvev1.ClusterError {
+	var errs []curvev1.ClusterError
+	for _, err := range e {
+		errs = append(errs, ToClusterErrors(err)...)
+	}
+	return errs
+}
+
+// Aggregate returns the errors as an AggregateError, nil if there is none or the error itself if there is one
+func Aggregate(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return AggregateError(errs)
+}
+
+// ToClusterErrors returns the categorized errors of err, the causes wrapped by github.com/pkg/errors are looked
+// into. It's a Transient error if none of them knows its category.
+func ToClusterErrors(err error) []curvev1.ClusterError {
+	for cause := err; cause != nil; {
+		if e, ok := cause.(ClusterErrors); ok {
+			return e.ClusterErrors()
+		}
+		causer, ok := cause.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		cause = causer.Cause()
+	}
+	return []curvev1.ClusterError{{Category: curvev1.ErrorCategoryTransient, Message: err.Error()}}
+}
+
+// IsTransient returns true if all the errors of err are Transient, which may be fixed by retrying such as the
+// configmap of a daemon that is not created yet
+func IsTransient(err error) bool {
+	for _, e := range ToClusterErrors(err) {
+		if e.Category != curvev1.ErrorCategoryTransient {
+			return false
+		}
+	}
+	return true
+}
+
+// SetErrors records the categorized errors of err in status.errors of the cluster, they are cleared if err is nil.
+// An error that was recorded by the last reconcile is counted instead of added again.
+func SetErrors(ctx context.Context, c *clusterd.Context, namespaceName types.NamespacedName, err error) {
+	var errs []curvev1.ClusterError
+	if err != nil {
+		errs = ToClusterErrors(err)
+	}
+
+	cluster := &curvev1.CurveCluster{}
+	if err := c.Client.Get(ctx, namespaceName, cluster); err != nil {
+		logger.Errorf("failed to get cluster %v to update the errors. %v", namespaceName, err)
+		return
+	}
+	if len(errs) == 0 && len(cluster.Status.Errors) == 0 {
+		return
+	}
+	cluster.Status.Errors = countErrors(cluster.Status.Errors, errs, metav1.Now())
+	if err := UpdateStatus(c.Client, namespaceName, cluster); err != nil {
+		logger.Errorf("failed to update cluster errors. %v", err)
+	}
+}
+
+// countErrors sets the count of the errors by the recorded ones, the recorded errors not in errs are dropped
+func countErrors(recorded, errs []curvev1.ClusterError, now metav1.Time) []curvev1.ClusterError {
+	for i := range errs {
+		errs[i].Count = 1
+		errs[i].LastSeen = now
+		for _, r := range recorded {
+			if r.Category == errs[i].Category && r.Node == errs[i].Node && r.Message == errs[i].Message {
+				errs[i].Count = r.Count + 1
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,78 @@
+package k8sutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+func TestToClusterErrors(t *testing.T) {
+	errs := ToClusterErrors(errors.New("connection refused"))
+	if len(errs) != 1 || errs[0].Category != curvev1.ErrorCategoryTransient {
+		t.Fatalf("expected an uncategorized error to be Transient, got %+v", errs)
+	}
+
+	errs = ToClusterErrors(errors.Wrap(NewConfigError(errors.New("mds nodes count 2 is less than 3, cannot start cluster")), "failed to validate"))
+	if len(errs) != 1 || errs[0].Category != curvev1.ErrorCategoryConfigError {
+		t.Fatalf("expected a wrapped ConfigError, got %+v", errs)
+	}
+
+	aggregated := Aggregate([]error{
+		NewNodeError("node1", errors.New("failed to create job")),
+		errors.Wrap(NewNodeError("node2", errors.New("failed to create job")), "failed to provision"),
+	})
+	errs = ToClusterErrors(errors.Wrap(aggregated, "failed to provision some devices"))
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors of the aggregate, got %+v", errs)
+	}
+	for i, node := range []string{"node1", "node2"} {
+		if errs[i].Category != curvev1.ErrorCategoryNodeFailure || errs[i].Node != node {
+			t.Errorf("expected NodeFailure of %s, got %+v", node, errs[i])
+		}
+	}
+
+	if Aggregate(nil) != nil {
+		t.Errorf("expected no error of an empty aggregate")
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	if !IsTransient(errors.Wrap(errors.New(`configmaps "curve-etcd-conf" not found`), "failed to get cluster info")) {
+		t.Errorf("expected an uncategorized error to be transient")
+	}
+	if IsTransient(NewConfigError(errors.New("mds nodes count 2 is less than 3, cannot start cluster"))) {
+		t.Errorf("expected a ConfigError not to be transient")
+	}
+	if IsTransient(Aggregate([]error{errors.New("timeout"), NewNodeError("node1", errors.New("failed to create job"))})) {
+		t.Errorf("expected an aggregate with a NodeFailure not to be transient")
+	}
+}
+
+func TestCountErrors(t *testing.T) {
+	first := metav1.NewTime(time.Date(2026, 10, 17, 2, 0, 0, 0, time.UTC))
+	recorded := countErrors(nil, []curvev1.ClusterError{
+		{Category: curvev1.ErrorCategoryNodeFailure, Node: "node1", Message: "failed to create job"},
+		{Category: curvev1.ErrorCategoryConfigError, Message: "mds nodes count 2 is less than 3, cannot start cluster"},
+	}, first)
+	for _, e := range recorded {
+		if e.Count != 1 || !e.LastSeen.Equal(&first) {
+			t.Fatalf("expected the new errors to be counted once, got %+v", e)
+		}
+	}
+
+	now := metav1.NewTime(first.Add(time.Minute))
+	errs := countErrors(recorded, []curvev1.ClusterError{
+		{Category: curvev1.ErrorCategoryNodeFailure, Node: "node1", Message: "failed to create job"},
+		{Category: curvev1.ErrorCategoryNodeFailure, Node: "node2", Message: "failed to create job"},
+	}, now)
+	if len(errs) != 2 || errs[0].Count != 2 || errs[1].Count != 1 {
+		t.Fatalf("expected the repeated error to be counted, got %+v", errs)
+	}
+	if !errs[0].LastSeen.Equal(&now) {
+		t.Errorf("expected the repeated error last seen at %v, got %v", now, errs[0].LastSeen)
+	}
+}
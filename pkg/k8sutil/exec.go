@@ -0,0 +1,42 @@
+package k8sutil
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+)
+
+// ExecInPod runs the command in the first container of the pod and returns its stdout
+func ExecInPod(c *clusterd.Context, pod *v1.Pod, command []string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	req := c.Clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(pod.Name).
+		Namespace(pod.Namespace).
+		SubResource("exec")
+	req.VersionedParams(&v1.PodExecOptions{
+		Container: pod.Spec.Containers[0].Name,
+		Command:   command,
+		Stdout:    true,
+		Stderr:    true,
+	}, scheme.ParameterCodec)
+
+	exec, err := remotecommand.NewSPDYExecutor(c.KubeConfig, "POST", req.URL())
+	if err != nil {
+		return "", errors.Wrap(err, "failed to init executor")
+	}
+	err = exec.Stream(remotecommand.StreamOptions{
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	if err != nil {
+		return stdout.String(), errors.Wrapf(err, "failed to exec %v in pod %s, stderr: %s", command, pod.Name, stderr.String())
+	}
+
+	return stdout.String(), nil
+}
@@ -0,0 +1,202 @@
+package k8sutil
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+)
+
+const (
+	// ExposedPortName is the name of the port of an exposed service
+	ExposedPortName = "http"
+
+	// ingressClassAnnotation selects the ingress controller, IngressClassName is not in networking/v1beta1
+	ingressClassAnnotation = "kubernetes.io/ingress.class"
+)
+
+var routeGVK = schema.GroupVersionKind{Group: "route.openshift.io", Version: "v1", Kind: "Route"}
+
+// ExposedService is the http endpoint of a component that is exposed by the service, ingress and route of the
+// same name
+type ExposedService struct {
+	Name      string
+	Namespace string
+	// Daemon is the daemon whose annotations and labels are injected to the objects
+	Daemon   string
+	Selector map[string]string
+	Port     int
+}
+
+type exposureObject interface {
+	metav1.Object
+	runtime.Object
+}
+
+// ApplyExposure applies the service of svc, and the ingress and route of it if they are set in exposure. The ones
+// that are not set are deleted.
+func ApplyExposure(c *clusterd.Context, spec curvev1.CurveClusterSpec, ownerInfo *OwnerInfo, svc ExposedService, exposure *curvev1.ExposureSpec) error {
+	apply := func(obj exposureObject) error {
+		InjectMetadata(spec, svc.Daemon, obj)
+		if err := ownerInfo.SetControllerReference(obj); err != nil {
+			return errors.Wrapf(err, "failed to set owner reference to %q", obj.GetName())
+		}
+		return Apply(c.Client, obj)
+	}
+
+	if err := apply(makeExposedService(svc, exposure)); err != nil {
+		return err
+	}
+
+	if exposure.Ingress != nil {
+		if err := apply(makeExposedIngress(svc, exposure.Ingress)); err != nil {
+			return err
+		}
+	} else if err := deleteExposed(c, svc.Name, svc.Namespace, &networkingv1beta1.Ingress{}); err != nil {
+		return err
+	}
+
+	if exposure.Route == nil {
+		return deleteExposed(c, svc.Name, svc.Namespace, newRoute())
+	}
+	err := apply(makeExposedRoute(svc, exposure.Route))
+	if meta.IsNoMatchError(errors.Cause(err)) {
+		logger.Warningf("Route is not supported, the route of %q is only served on openshift", svc.Name)
+		return nil
+	}
+	return err
+}
+
+// DeleteExposure deletes the service, ingress and route of the exposed service
+func DeleteExposure(c *clusterd.Context, name, namespace string) error {
+	return deleteExposed(c, name, namespace, &v1.Service{}, &networkingv1beta1.Ingress{}, newRoute())
+}
+
+// deleteExposed deletes the objects of the exposed service, the objects are filled by name and namespace
+func deleteExposed(c *clusterd.Context, name, namespace string, objs ...exposureObject) error {
+	for _, obj := range objs {
+		obj.SetNamespace(namespace)
+		obj.SetName(name)
+		if _, ok := obj.(*v1.Service); ok {
+			ExpectDelete("Service", namespace, name)
+		}
+		err := c.Client.Delete(context.TODO(), obj)
+		if err != nil && !kerrors.IsNotFound(err) && !meta.IsNoMatchError(err) {
+			return errors.Wrapf(err, "failed to delete %T %q", obj, name)
+		}
+	}
+	return nil
+}
+
+func makeExposedService(svc ExposedService, exposure *curvev1.ExposureSpec) *v1.Service {
+	serviceType := exposure.ServiceType
+	if serviceType == "" {
+		serviceType = v1.ServiceTypeClusterIP
+	}
+	port := v1.ServicePort{
+		Name:       ExposedPortName,
+		Port:       int32(svc.Port),
+		TargetPort: intstr.FromInt(svc.Port),
+		Protocol:   v1.ProtocolTCP,
+	}
+	if serviceType != v1.ServiceTypeClusterIP {
+		port.NodePort = exposure.NodePort
+	}
+
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        svc.Name,
+			Namespace:   svc.Namespace,
+			Labels:      svc.Selector,
+			Annotations: exposure.ServiceAnnotations,
+		},
+		Spec: v1.ServiceSpec{
+			Type:     serviceType,
+			Selector: svc.Selector,
+			Ports:    []v1.ServicePort{port},
+		},
+	}
+}
+
+func makeExposedIngress(svc ExposedService, spec *curvev1.IngressSpec) *networkingv1beta1.Ingress {
+	annotations := map[string]string{}
+	for k, v := range spec.Annotations {
+		annotations[k] = v
+	}
+	if spec.IngressClassName != "" {
+		annotations[ingressClassAnnotation] = spec.IngressClassName
+	}
+
+	ingress := &networkingv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        svc.Name,
+			Namespace:   svc.Namespace,
+			Annotations: annotations,
+		},
+		Spec: networkingv1beta1.IngressSpec{
+			Rules: []networkingv1beta1.IngressRule{{
+				Host: spec.Host,
+				IngressRuleValue: networkingv1beta1.IngressRuleValue{
+					HTTP: &networkingv1beta1.HTTPIngressRuleValue{
+						Paths: []networkingv1beta1.HTTPIngressPath{{
+							Path: "/",
+							Backend: networkingv1beta1.IngressBackend{
+								ServiceName: svc.Name,
+								ServicePort: intstr.FromString(ExposedPortName),
+							},
+						}},
+					},
+				},
+			}},
+		},
+	}
+	if spec.TLSSecretName != "" {
+		ingress.Spec.TLS = []networkingv1beta1.IngressTLS{{Hosts: []string{spec.Host}, SecretName: spec.TLSSecretName}}
+	}
+	return ingress
+}
+
+func makeExposedRoute(svc ExposedService, spec *curvev1.RouteSpec) *unstructured.Unstructured {
+	routeSpec := map[string]interface{}{
+		"to": map[string]interface{}{
+			"kind": "Service",
+			"name": svc.Name,
+		},
+		"port": map[string]interface{}{
+			"targetPort": ExposedPortName,
+		},
+	}
+	if spec.Host != "" {
+		routeSpec["host"] = spec.Host
+	}
+	if spec.TLSTermination != "" {
+		routeSpec["tls"] = map[string]interface{}{
+			"termination":                   spec.TLSTermination,
+			"insecureEdgeTerminationPolicy": "Redirect",
+		}
+	}
+
+	route := newRoute()
+	route.SetNamespace(svc.Namespace)
+	route.SetName(svc.Name)
+	route.Object["spec"] = routeSpec
+	return route
+}
+
+// newRoute returns an openshift route without name and spec
+func newRoute() *unstructured.Unstructured {
+	route := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	route.SetGroupVersionKind(routeGVK)
+	return route
+}
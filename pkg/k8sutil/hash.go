@@ -0,0 +1,154 @@
+package k8sutil
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+)
+
+const (
+	// SpecHashAnnotation is the hash of the deployment spec rendered by the operator, the deployment
+	// is updated only when it changes
+	SpecHashAnnotation = "operator.curve.io/spec-hash"
+	// ConfigHashAnnotation is the hash of the configmaps mounted by the pod, it's set on the pod template
+	// so that a change of the config restarts the pods
+	ConfigHashAnnotation = "operator.curve.io/config-hash"
+
+	// UpdateTimeout is the time to wait for an updated deployment to restart
+	UpdateTimeout = 5 * time.Minute
+)
+
+// hashObject returns the hash of the json encoding of obj
+func hashObject(obj interface{}) (string, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal object to hash")
+	}
+	return Hash(string(data)), nil
+}
+
+// SetConfigHash sets the hash of the data of configmaps mounted by the pod template on it
+func SetConfigHash(clientset kubernetes.Interface, namespace string, template *v1.PodTemplateSpec) error {
+	hash, err := ConfigHash(clientset, namespace, &template.Spec)
+	if err != nil {
+		return err
+	}
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[ConfigHashAnnotation] = hash
+	return nil
+}
+
+// ConfigHash returns the hash of the data of configmaps mounted by the pod
+func ConfigHash(clientset kubernetes.Interface, namespace string, spec *v1.PodSpec) (string, error) {
+	var names []string
+	for _, volume := range spec.Volumes {
+		if volume.ConfigMap != nil {
+			names = append(names, volume.ConfigMap.Name)
+		}
+	}
+	sort.Strings(names)
+
+	data := make([]map[string]string, 0, len(names))
+	for _, name := range names {
+		cm, err := clientset.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			if kerrors.IsNotFound(err) {
+				// optional configmap
+				continue
+			}
+			return "", errors.Wrapf(err, "failed to get configmap %q to hash", name)
+		}
+		data = append(data, cm.Data)
+	}
+
+	return hashObject(data)
+}
+
+// SetSpecHash sets the hash of the deployment spec on the deployment, it must be called after the pod
+// template is complete
+func SetSpecHash(d *appsv1.Deployment) error {
+	hash, err := hashObject(d.Spec)
+	if err != nil {
+		return err
+	}
+	if d.Annotations == nil {
+		d.Annotations = map[string]string{}
+	}
+	d.Annotations[SpecHashAnnotation] = hash
+	return nil
+}
+
+// DeploymentChanged returns whether the rendered deployment d differs from the existing one
+func DeploymentChanged(existing, d *appsv1.Deployment) bool {
+	return existing.Annotations[SpecHashAnnotation] != d.Annotations[SpecHashAnnotation]
+}
+
+// CreateOrGetDeployment sets the config and spec hash of d and applies it if it's not created yet,
+// the existing deployment is returned otherwise
+func CreateOrGetDeployment(c *clusterd.Context, d *appsv1.Deployment) (*appsv1.Deployment, bool, error) {
+	if err := SetConfigHash(c.Clientset, d.Namespace, &d.Spec.Template); err != nil {
+		return nil, false, err
+	}
+	if err := SetSpecHash(d); err != nil {
+		return nil, false, err
+	}
+
+	existing, err := c.Clientset.AppsV1().Deployments(d.Namespace).Get(d.Name, metav1.GetOptions{})
+	if err == nil {
+		return existing, false, nil
+	}
+	if !kerrors.IsNotFound(err) {
+		return nil, false, errors.Wrapf(err, "failed to get deployment %q", d.Name)
+	}
+	if err := Apply(c.Client, d); err != nil {
+		return nil, false, err
+	}
+	return d, true, nil
+}
+
+// UpdateDeployment applies the rendered deployment to replace the fields owned by the operator
+func UpdateDeployment(c *clusterd.Context, d *appsv1.Deployment) (*appsv1.Deployment, error) {
+	if err := Apply(c.Client, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// CreateOrUpdateDeployment creates the deployment or updates it if the rendered spec or config changed,
+// an updated deployment is waited to restart before returning, so the pods of a daemon are restarted one
+// by one. It returns the created deployment that is not waited
+func CreateOrUpdateDeployment(c *clusterd.Context, d *appsv1.Deployment) (*appsv1.Deployment, error) {
+	existing, created, err := CreateOrGetDeployment(c, d)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		logger.Infof("deployment %s has been created, waiting for startup", d.Name)
+		return existing, nil
+	}
+	if !DeploymentChanged(existing, d) {
+		logger.Infof("deployment %s is up to date", d.Name)
+		return nil, nil
+	}
+
+	logger.Infof("deployment %s changed, updating it", d.Name)
+	updated, err := UpdateDeployment(c, d)
+	if err != nil {
+		return nil, err
+	}
+	if err := WaitForDeploymentToStart(c.Clientset, 3*time.Second, UpdateTimeout, updated); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
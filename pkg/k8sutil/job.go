@@ -6,6 +6,7 @@ import (
 	"time"
 
 	batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -47,6 +48,7 @@ func DeleteBatchJob(ctx context.Context, clientset kubernetes.Interface, namespa
 	propagation := metav1.DeletePropagationForeground
 	gracePeriod := int64(0)
 	options := &metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod, PropagationPolicy: &propagation}
+	ExpectDelete("Job", namespace, name)
 	if err := clientset.BatchV1().Jobs(namespace).Delete(name, options); err != nil {
 		if errors.IsNotFound(err) {
 			return nil
@@ -104,3 +106,36 @@ func CheckJobStatus(ctx context.Context, clientSet kubernetes.Interface, ticker
 		}
 	}
 }
+
+// GetJobTerminationMessage returns the termination message of the container of the job pod
+func GetJobTerminationMessage(clientset kubernetes.Interface, job *batch.Job) (string, error) {
+	pods, err := clientset.CoreV1().Pods(job.Namespace).List(metav1.ListOptions{
+		LabelSelector: "job-name=" + job.Name,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list pods of job %s. %+v", job.Name, err)
+	}
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Terminated != nil && status.State.Terminated.Message != "" {
+				return status.State.Terminated.Message, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// GetContainerLogs returns the last lines of the log of the container, the log of the previous
+// terminated container is returned if previous is true
+func GetContainerLogs(clientset kubernetes.Interface, namespace, podName, container string, previous bool, tailLines int64) (string, error) {
+	options := &v1.PodLogOptions{
+		Container: container,
+		Previous:  previous,
+		TailLines: &tailLines,
+	}
+	logs, err := clientset.CoreV1().Pods(namespace).GetLogs(podName, options).Do().Raw()
+	if err != nil {
+		return "", fmt.Errorf("failed to get logs of container %s of pod %s. %+v", container, podName, err)
+	}
+	return string(logs), nil
+}
@@ -0,0 +1,40 @@
+package k8sutil
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+// ClusterLabel is the label of the resources of a cluster, its value is the namespace of the cluster
+const ClusterLabel = "curve_cluster"
+
+// InjectMetadata adds the annotations and labels of the daemon to the objects, such as a deployment and its pod
+// template. The annotations and labels set by the operator are kept, the selectors depend on them. The objects
+// in a namespace are labeled by ClusterLabel to be swept when they are not wanted any more, the pod templates
+// are not touched to keep the pods running.
+func InjectMetadata(spec curvev1.CurveClusterSpec, daemon string, objs ...metav1.Object) {
+	annotations, labels := spec.DaemonMetadata(daemon)
+	for _, obj := range objs {
+		obj.SetAnnotations(mergeMetadata(obj.GetAnnotations(), annotations))
+		obj.SetLabels(mergeMetadata(obj.GetLabels(), labels))
+		if obj.GetNamespace() != "" {
+			obj.SetLabels(mergeMetadata(obj.GetLabels(), map[string]string{ClusterLabel: obj.GetNamespace()}))
+		}
+	}
+}
+
+// mergeMetadata returns the copy of current with the keys of extra that don't exist in current
+func mergeMetadata(current, extra map[string]string) map[string]string {
+	if len(extra) == 0 {
+		return current
+	}
+	merged := make(map[string]string, len(current)+len(extra))
+	for k, v := range extra {
+		merged[k] = v
+	}
+	for k, v := range current {
+		merged[k] = v
+	}
+	return merged
+}
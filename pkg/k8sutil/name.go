@@ -1,11 +1,39 @@
 package k8sutil
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
 
 const (
 	maxPerChar = 26
+
+	// MaxJobNameLength keeps the names of jobs short enough for the names of their pods, which are truncated 10
+	// more characters by k8s 1.22
+	MaxJobNameLength = validation.DNS1035LabelMaxLength - 10
+
+	// nameHashLength is the length of the hash that the truncated names end with
+	nameHashLength = 8
 )
 
+// ResourceName joins the parts by '-' into the name of a resource in maxLength. The name that is longer is
+// truncated and ends with a hash of the whole name instead, so the same parts always get the same name and the
+// different names that are truncated to the same prefix don't collide. The names in the limit are not changed.
+func ResourceName(maxLength int, parts ...string) string {
+	name := strings.Join(parts, "-")
+	if len(name) <= maxLength {
+		return name
+	}
+	return strings.TrimRight(name[:maxLength-nameHashLength-1], "-.") + "-" + Hash(name)[:nameHashLength]
+}
+
+// LabelValue returns the value in the length limit of label values like ResourceName, such as a long node name
+func LabelValue(value string) string {
+	return ResourceName(validation.LabelValueMaxLength, value)
+}
+
 // IndexToName converts an index to a daemon name based on as few letters of the alphabet as possible.
 // For example:
 //
@@ -0,0 +1,46 @@
+package k8sutil
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestResourceName(t *testing.T) {
+	if name := ResourceName(63, "prepare-chunkfile", "node1", "sdb"); name != "prepare-chunkfile-node1-sdb" {
+		t.Errorf("name in the limit is changed to %q", name)
+	}
+
+	longNode := strings.Repeat("node", 20)
+	a := ResourceName(MaxJobNameLength, "prepare-chunkfile", longNode, "nvme0n1")
+	b := ResourceName(MaxJobNameLength, "prepare-chunkfile", longNode, "nvme1n1")
+	if len(a) > MaxJobNameLength || len(b) > MaxJobNameLength {
+		t.Errorf("names %q and %q are longer than %d", a, b, MaxJobNameLength)
+	}
+	if a == b {
+		t.Errorf("names of different devices collide as %q", a)
+	}
+	if again := ResourceName(MaxJobNameLength, "prepare-chunkfile", longNode, "nvme0n1"); again != a {
+		t.Errorf("name is not deterministic, %q and %q", a, again)
+	}
+	if errs := validation.IsDNS1123Label(a); len(errs) > 0 {
+		t.Errorf("name %q is invalid: %v", a, errs)
+	}
+
+	// the truncated name doesn't end with a separator before the hash
+	name := ResourceName(20, "chunkserver-node1", "sdb-long-device")
+	if strings.Contains(name, "--") || len(name) > 20 {
+		t.Errorf("truncated name %q is invalid", name)
+	}
+}
+
+func TestLabelValue(t *testing.T) {
+	if value := LabelValue("node1"); value != "node1" {
+		t.Errorf("short value is changed to %q", value)
+	}
+	value := LabelValue(strings.Repeat("a.b-", 30) + "node")
+	if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+		t.Errorf("value %q is invalid: %v", value, errs)
+	}
+}
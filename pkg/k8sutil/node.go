@@ -5,10 +5,13 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"net"
+	"strings"
 
 	"github.com/coreos/pkg/capnslog"
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/kubernetes"
@@ -19,24 +22,293 @@ import (
 
 var logger = capnslog.NewPackageLogger("github.com/opencurve/curve-operator", "k8sutil")
 
-// getNodeInfoMap get node ip by node name that user specified and return a mapping of nodeName:nodeIP
+// getNodeInfoMap get node ip by node name that user specified for all daemons and chunkservers
+// and return a mapping of nodeName:nodeIP. The ip is recorded under both the specified name
+// and the name of the node resource. The nodes are listed once for all the names.
 func GetNodeInfoMap(c *curvev1.CurveClusterSpec, clientset kubernetes.Interface) (map[string]string, error) {
 	nodeNameIP := make(map[string]string)
 
-	for _, nodeName := range c.Nodes {
-		n, err := clientset.CoreV1().Nodes().Get(nodeName, metav1.GetOptions{})
+	resolved, err := resolveNodes(clientset, MergeNodeNames(c.DaemonNodes(), c.StorageNodes()))
+	if err != nil {
+		return nil, err
+	}
+
+	for specName, n := range resolved {
+		address, err := NodeAddress(n, &c.Network)
+		if err != nil {
+			return nil, err
+		}
+		nodeNameIP[specName] = address
+		nodeNameIP[n.Name] = address
+	}
+
+	return nodeNameIP, nil
+}
+
+// NodeAddressProvider provides the address of a node used for the daemons on it, ok is false if it has no
+// address for the node and the next provider is asked
+type NodeAddressProvider interface {
+	NodeAddress(node *v1.Node) (address string, ok bool, err error)
+}
+
+// NodeAddressProviders returns the providers asked in order for the address of a node by network, the override of
+// network.nodeAddresses, the curve.opencurve.io/ip annotation of the node and the node addresses
+func NodeAddressProviders(network *curvev1.NetworkSpec) ([]NodeAddressProvider, error) {
+	var nets []*net.IPNet
+	for _, cidr := range network.CIDRs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid network cidr %q", cidr)
+		}
+		nets = append(nets, ipNet)
+	}
+	return []NodeAddressProvider{
+		specAddressProvider{addresses: network.NodeAddresses},
+		annotationAddressProvider{},
+		&statusAddressProvider{network: network, nets: nets},
+	}, nil
+}
+
+// NodeAddress returns the address of the node used for the daemons on it by the first of NodeAddressProviders that
+// has one. The nodes without override use the first address of the preferred type in the CIDRs of network, or the
+// first address of the other type if there is none.
+func NodeAddress(node *v1.Node, network *curvev1.NetworkSpec) (string, error) {
+	providers, err := NodeAddressProviders(network)
+	if err != nil {
+		return "", err
+	}
+	for _, provider := range providers {
+		address, ok, err := provider.NodeAddress(node)
 		if err != nil {
-			return nil, errors.Wrapf(err, "failed to find node %s from cluster", nodeName)
+			return "", err
 		}
+		if ok {
+			return address, nil
+		}
+	}
+	if len(network.CIDRs) > 0 {
+		return "", errors.Errorf("node %q has no ip in networks %s", node.Name, strings.Join(network.CIDRs, ", "))
+	}
+	return "", errors.Errorf("node %q has no internal or external ip, set it by annotation %s or network.nodeAddresses",
+		node.Name, curvev1.NodeIPAnnotation)
+}
+
+// specAddressProvider provides the addresses of network.nodeAddresses, which are keyed by the node name or its
+// hostname label
+type specAddressProvider struct {
+	addresses map[string]string
+}
+
+func (p specAddressProvider) NodeAddress(node *v1.Node) (string, bool, error) {
+	address, ok := p.addresses[node.Name]
+	if !ok {
+		address, ok = p.addresses[node.Labels[v1.LabelHostname]]
+	}
+	if !ok {
+		return "", false, nil
+	}
+	if net.ParseIP(address) == nil {
+		return "", false, NewConfigError(errors.Errorf("invalid ip %q of node %q in network.nodeAddresses", address, node.Name))
+	}
+	return address, true, nil
+}
+
+// annotationAddressProvider provides the address of the curve.opencurve.io/ip annotation of the node
+type annotationAddressProvider struct{}
+
+func (annotationAddressProvider) NodeAddress(node *v1.Node) (string, bool, error) {
+	address, ok := node.Annotations[curvev1.NodeIPAnnotation]
+	if !ok {
+		return "", false, nil
+	}
+	if net.ParseIP(address) == nil {
+		return "", false, NewConfigError(errors.Errorf("invalid ip %q in annotation %s of node %q",
+			address, curvev1.NodeIPAnnotation, node.Name))
+	}
+	return address, true, nil
+}
+
+// statusAddressProvider provides the internal or external ip of the node addresses in the networks
+type statusAddressProvider struct {
+	network *curvev1.NetworkSpec
+	nets    []*net.IPNet
+}
 
-		for _, address := range n.Status.Addresses {
-			if address.Type == "InternalIP" {
-				nodeNameIP[n.Name] = address.Address
+func (p *statusAddressProvider) NodeAddress(node *v1.Node) (string, bool, error) {
+	preferred := v1.NodeInternalIP
+	if p.network.PreferredAddressType != "" {
+		preferred = p.network.PreferredAddressType
+	}
+	other := v1.NodeExternalIP
+	if preferred == v1.NodeExternalIP {
+		other = v1.NodeInternalIP
+	}
+
+	for _, addressType := range []v1.NodeAddressType{preferred, other} {
+		for _, address := range node.Status.Addresses {
+			if address.Type == addressType && inNetworks(address.Address, p.nets) {
+				return address.Address, true, nil
 			}
 		}
 	}
+	return "", false, nil
+}
 
-	return nodeNameIP, nil
+// inNetworks returns true if the address is in any of the networks, or no network is specified
+func inNetworks(address string, nets []*net.IPNet) bool {
+	if len(nets) == 0 {
+		return true
+	}
+	ip := net.ParseIP(address)
+	for _, n := range nets {
+		if ip != nil && n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckNodeArchitectures returns an error listing the specified nodes whose architecture is not one of the
+// architectures, any architecture is allowed if there is none
+func CheckNodeArchitectures(clientset kubernetes.Interface, names []string, architectures []string) error {
+	if len(architectures) == 0 {
+		return nil
+	}
+	nodes, err := resolveNodes(clientset, names)
+	if err != nil {
+		return err
+	}
+
+	allowed := map[string]bool{}
+	for _, arch := range architectures {
+		allowed[arch] = true
+	}
+	var incompatible []string
+	for _, name := range names {
+		arch := nodes[name].Status.NodeInfo.Architecture
+		if !allowed[arch] {
+			incompatible = append(incompatible, fmt.Sprintf("%s(%s)", name, arch))
+		}
+	}
+	if len(incompatible) > 0 {
+		return errors.Errorf("architectures of nodes %s are not supported by the image, supported are %s",
+			strings.Join(incompatible, ", "), strings.Join(architectures, ", "))
+	}
+	return nil
+}
+
+// UnknownNodesError is returned if some of the specified nodes match no node in the cluster.
+type UnknownNodesError struct {
+	Names []string
+}
+
+func (e *UnknownNodesError) Error() string {
+	return fmt.Sprintf("unknown nodes %s, a node must be specified by its name, %s label or ip",
+		strings.Join(e.Names, ", "), v1.LabelHostname)
+}
+
+// ClusterErrors returns a ConfigError, the nodes must be fixed in spec
+func (e *UnknownNodesError) ClusterErrors() []curvev1.ClusterError {
+	return []curvev1.ClusterError{{Category: curvev1.ErrorCategoryConfigError, Message: e.Error()}}
+}
+
+// ResolveNodeNames maps each of the specified names to the name of the node resource. A name matches
+// a node by the node name, the hostname label or the internal, external or annotated ip, in that order. An
+// UnknownNodesError lists the names that match no node.
+func ResolveNodeNames(clientset kubernetes.Interface, names []string) (map[string]string, error) {
+	nodes, err := resolveNodes(clientset, names)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := map[string]string{}
+	for name, node := range nodes {
+		resolved[name] = node.Name
+	}
+	return resolved, nil
+}
+
+// resolveNodes maps each of the specified names to the node resource like ResolveNodeNames
+func resolveNodes(clientset kubernetes.Interface, names []string) (map[string]*v1.Node, error) {
+	if nodeCache, ok := clusterd.CachedNodes(clientset); ok {
+		return resolveCachedNodes(nodeCache, names)
+	}
+	nodes, err := clientset.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list nodes")
+	}
+
+	byName := map[string]*v1.Node{}
+	byHostname := map[string]*v1.Node{}
+	byIP := map[string]*v1.Node{}
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		byName[node.Name] = node
+		if hostname, ok := node.Labels[v1.LabelHostname]; ok {
+			byHostname[hostname] = node
+		}
+		for _, address := range node.Status.Addresses {
+			if address.Type == v1.NodeInternalIP || address.Type == v1.NodeExternalIP {
+				byIP[address.Address] = node
+			}
+		}
+		if address, ok := node.Annotations[curvev1.NodeIPAnnotation]; ok {
+			byIP[address] = node
+		}
+	}
+
+	resolved := map[string]*v1.Node{}
+	var unknown []string
+	for _, name := range names {
+		switch {
+		case byName[name] != nil:
+			resolved[name] = byName[name]
+		case byHostname[name] != nil:
+			resolved[name] = byHostname[name]
+		case byIP[name] != nil:
+			resolved[name] = byIP[name]
+		default:
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, &UnknownNodesError{Names: unknown}
+	}
+
+	return resolved, nil
+}
+
+// resolveCachedNodes maps the names to the nodes by the indexes of the node cache like resolveNodes
+func resolveCachedNodes(nodeCache *clusterd.NodeCache, names []string) (map[string]*v1.Node, error) {
+	resolved := map[string]*v1.Node{}
+	var unknown []string
+	for _, name := range names {
+		node, err := nodeCache.Get(name)
+		if err == nil {
+			resolved[name] = node
+			continue
+		}
+		if !kerrors.IsNotFound(err) {
+			return nil, errors.Wrapf(err, "failed to get node %s", name)
+		}
+		for _, index := range []string{clusterd.NodeHostnameIndex, clusterd.NodeAddressIndex} {
+			nodes, err := nodeCache.ByIndex(index, name)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to look up node %s", name)
+			}
+			if len(nodes) > 0 {
+				resolved[name] = &nodes[0]
+				break
+			}
+		}
+		if resolved[name] == nil {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, &UnknownNodesError{Names: unknown}
+	}
+	return resolved, nil
 }
 
 // GetNodeHostNames returns the name of the node resource mapped to their hostname label.
@@ -55,11 +327,15 @@ func GetNodeHostNames(clientset kubernetes.Interface) (map[string]string, error)
 	return nodeMap, nil
 }
 
-// GetValidNodes returns all nodes that are ready and is schedulable
+// GetValidNodes returns all nodes that are ready and is schedulable, the nodes that don't exist yet are not valid
 func GetValidNodes(c clusterd.Context, storageNodes []string) ([]v1.Node, error) {
 	nodes := []v1.Node{}
 	for _, curveNode := range storageNodes {
 		n, err := c.Clientset.CoreV1().Nodes().Get(curveNode, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			logger.Warningf("node %v is not found", curveNode)
+			continue
+		}
 		if err != nil {
 			logger.Errorf("failed to get node %v info", curveNode)
 			return nil, errors.Wrap(err, "failed to get node info by node name")
@@ -82,28 +358,32 @@ func GetValidNodes(c clusterd.Context, storageNodes []string) ([]v1.Node, error)
 }
 
 func GetValidDaemonHosts(c clusterd.Context, curveCluster *curvev1.CurveCluster) ([]v1.Node, error) {
-	daemonHosts := curveCluster.Spec.Nodes
+	daemonHosts := curveCluster.Spec.DaemonNodes()
 	validDaemonHosts, err := GetValidNodes(c, daemonHosts)
 	return validDaemonHosts, err
 }
 
 func GetValidChunkserverHosts(c clusterd.Context, curveCluster *curvev1.CurveCluster) ([]v1.Node, error) {
-	if !curveCluster.Spec.Storage.UseSelectedNodes {
-		chunkserverHosts := curveCluster.Spec.Storage.Nodes
-		validNodes, err := GetValidNodes(c, chunkserverHosts)
-		return validNodes, err
-	}
-	// useSelectedNodes == true
-	var chunkserverHosts []string
-
-	for _, s := range curveCluster.Spec.Storage.SelectedNodes {
-		chunkserverHosts = append(chunkserverHosts, s.Node)
-	}
+	chunkserverHosts := curveCluster.Spec.StorageNodes()
 	valiedChunkHosts, err := GetValidNodes(c, chunkserverHosts)
-
 	return valiedChunkHosts, err
 }
 
+// MergeNodeNames merges the node names without duplicates
+func MergeNodeNames(nodeLists ...[]string) []string {
+	var nodes []string
+	tmpMap := make(map[string]struct{})
+	for _, list := range nodeLists {
+		for _, n := range list {
+			if _, ok := tmpMap[n]; !ok {
+				tmpMap[n] = struct{}{}
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	return nodes
+}
+
 func MergeNodesOfDaemonAndChunk(daemonHosts []v1.Node, chunkserverHosts []v1.Node) []v1.Node {
 	var nodes []v1.Node
 	nodes = append(nodes, daemonHosts...)
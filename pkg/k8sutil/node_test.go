@@ -0,0 +1,109 @@
+package k8sutil
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+)
+
+func TestNodeAddress(t *testing.T) {
+	node := func(annotation string, addresses ...v1.NodeAddress) *v1.Node {
+		n := &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: map[string]string{v1.LabelHostname: "host1"}},
+			Status:     v1.NodeStatus{Addresses: addresses},
+		}
+		if annotation != "" {
+			n.Annotations = map[string]string{curvev1.NodeIPAnnotation: annotation}
+		}
+		return n
+	}
+	internal := v1.NodeAddress{Type: v1.NodeInternalIP, Address: "10.0.0.1"}
+	external := v1.NodeAddress{Type: v1.NodeExternalIP, Address: "192.168.0.1"}
+	hostname := v1.NodeAddress{Type: v1.NodeHostName, Address: "host1"}
+
+	tests := []struct {
+		name    string
+		node    *v1.Node
+		network curvev1.NetworkSpec
+		want    string
+		wantErr bool
+	}{
+		{name: "internal ip", node: node("", external, internal), want: "10.0.0.1"},
+		{name: "preferred external ip", node: node("", internal, external),
+			network: curvev1.NetworkSpec{PreferredAddressType: v1.NodeExternalIP}, want: "192.168.0.1"},
+		{name: "ip in cidrs", node: node("", internal, external),
+			network: curvev1.NetworkSpec{CIDRs: []string{"192.168.0.0/24"}}, want: "192.168.0.1"},
+		{name: "annotation", node: node("10.0.1.1", hostname), want: "10.0.1.1"},
+		{name: "annotation overrides addresses", node: node("10.0.1.1", internal), want: "10.0.1.1"},
+		{name: "spec by hostname overrides annotation", node: node("10.0.1.1", internal),
+			network: curvev1.NetworkSpec{NodeAddresses: map[string]string{"host1": "10.0.2.1"}}, want: "10.0.2.1"},
+		{name: "invalid annotation", node: node("host1", internal), wantErr: true},
+		{name: "invalid spec", node: node("", internal),
+			network: curvev1.NetworkSpec{NodeAddresses: map[string]string{"node1": "host1"}}, wantErr: true},
+		{name: "hostname only", node: node("", hostname), wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := NodeAddress(tt.node, &tt.network)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected address %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestResolveCachedNodes(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node1.example.com", Labels: map[string]string{v1.LabelHostname: "node1"}},
+			Status:     v1.NodeStatus{Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.1"}}},
+		},
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node2", Annotations: map[string]string{curvev1.NodeIPAnnotation: "10.0.1.2"}},
+		},
+	)
+	nodeCache := clusterd.NewNodeCache(clientset)
+	stop := make(chan struct{})
+	defer close(stop)
+	if err := nodeCache.Start(stop); err != nil {
+		t.Fatal(err)
+	}
+	cached := nodeCache.Clientset(clientset)
+	clientset.ClearActions()
+
+	resolved, err := ResolveNodeNames(cached, []string{"node1", "10.0.0.1", "node2", "10.0.1.2", "node1.example.com"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{"node1": "node1.example.com", "10.0.0.1": "node1.example.com", "node2": "node2",
+		"10.0.1.2": "node2", "node1.example.com": "node1.example.com"}
+	if !reflect.DeepEqual(resolved, expected) {
+		t.Errorf("expected %v, got %v", expected, resolved)
+	}
+	if _, err := ResolveNodeNames(cached, []string{"node3"}); err == nil {
+		t.Error("expected error of unknown node")
+	}
+
+	nodes, err := cached.CoreV1().Nodes().List(metav1.ListOptions{LabelSelector: v1.LabelHostname + "=node1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(nodes.Items) != 1 || nodes.Items[0].Name != "node1.example.com" {
+		t.Errorf("expected node1.example.com by hostname, got %v", nodes.Items)
+	}
+	if _, err := cached.CoreV1().Nodes().Get("node3", metav1.GetOptions{}); !kerrors.IsNotFound(err) {
+		t.Errorf("expected NotFound of node3, got %v", err)
+	}
+	if actions := clientset.Actions(); len(actions) != 0 {
+		t.Errorf("expected the nodes looked up in the cache, got requests %v", actions)
+	}
+}
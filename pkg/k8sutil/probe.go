@@ -0,0 +1,77 @@
+package k8sutil
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+var (
+	// DefaultLivenessProbe gives the daemon enough time to load its data before it's restarted
+	DefaultLivenessProbe = curvev1.ProbeSpec{
+		InitialDelaySeconds: 60,
+		PeriodSeconds:       10,
+		TimeoutSeconds:      5,
+		FailureThreshold:    6,
+	}
+	// DefaultReadinessProbe marks the daemon ready as soon as its port is serving
+	DefaultReadinessProbe = curvev1.ProbeSpec{
+		InitialDelaySeconds: 5,
+		PeriodSeconds:       10,
+		TimeoutSeconds:      5,
+		FailureThreshold:    3,
+	}
+)
+
+// TCPProbeHandler checks whether the port is listening
+func TCPProbeHandler(port int) v1.Handler {
+	return v1.Handler{
+		TCPSocket: &v1.TCPSocketAction{
+			Port: intstr.FromInt(port),
+		},
+	}
+}
+
+// HTTPProbeHandler checks whether the http path of the port returns success
+func HTTPProbeHandler(port int, path string) v1.Handler {
+	return v1.Handler{
+		HTTPGet: &v1.HTTPGetAction{
+			Path:   path,
+			Port:   intstr.FromInt(port),
+			Scheme: v1.URISchemeHTTP,
+		},
+	}
+}
+
+// MakeProbe makes a probe with the handler, the fields that not set in spec are set to the default,
+// and nil is returned if the probe is disabled.
+func MakeProbe(handler v1.Handler, spec *curvev1.ProbeSpec, defaults curvev1.ProbeSpec) *v1.Probe {
+	if spec == nil {
+		spec = &curvev1.ProbeSpec{}
+	}
+	if spec.Disabled {
+		return nil
+	}
+
+	probe := &v1.Probe{
+		Handler:             handler,
+		InitialDelaySeconds: defaults.InitialDelaySeconds,
+		PeriodSeconds:       defaults.PeriodSeconds,
+		TimeoutSeconds:      defaults.TimeoutSeconds,
+		FailureThreshold:    defaults.FailureThreshold,
+	}
+	if spec.InitialDelaySeconds > 0 {
+		probe.InitialDelaySeconds = spec.InitialDelaySeconds
+	}
+	if spec.PeriodSeconds > 0 {
+		probe.PeriodSeconds = spec.PeriodSeconds
+	}
+	if spec.TimeoutSeconds > 0 {
+		probe.TimeoutSeconds = spec.TimeoutSeconds
+	}
+	if spec.FailureThreshold > 0 {
+		probe.FailureThreshold = spec.FailureThreshold
+	}
+	return probe
+}
@@ -0,0 +1,62 @@
+package k8sutil
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// ServiceDNSName returns the cluster DNS name of the service
+func ServiceDNSName(name, namespace string) string {
+	return fmt.Sprintf("%s.%s.svc", name, namespace)
+}
+
+// DaemonServiceAddrs returns the comma separated addresses of the services of count daemons named
+// appName-a, appName-b, ...
+func DaemonServiceAddrs(appName, namespace string, count int, port int) string {
+	addrs := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("%s-%s", appName, IndexToName(i))
+		addrs = append(addrs, fmt.Sprintf("%s:%d", ServiceDNSName(name, namespace), port))
+	}
+	return strings.Join(addrs, ",")
+}
+
+// MakeHeadlessService makes a headless service that selects the pod of one daemon, the DNS name of it
+// resolves to the node IP as the daemons run in host network
+func MakeHeadlessService(name, namespace string, selector map[string]string, ports map[string]int) *v1.Service {
+	names := make([]string, 0, len(ports))
+	for portName := range ports {
+		names = append(names, portName)
+	}
+	sort.Strings(names)
+
+	servicePorts := make([]v1.ServicePort, 0, len(ports))
+	for _, portName := range names {
+		servicePorts = append(servicePorts, v1.ServicePort{
+			Name:       portName,
+			Port:       int32(ports[portName]),
+			TargetPort: intstr.FromInt(ports[portName]),
+			Protocol:   v1.ProtocolTCP,
+		})
+	}
+
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    selector,
+		},
+		Spec: v1.ServiceSpec{
+			ClusterIP: v1.ClusterIPNone,
+			Selector:  selector,
+			Ports:     servicePorts,
+			// the daemons find each other before they are ready
+			PublishNotReadyAddresses: true,
+		},
+	}
+}
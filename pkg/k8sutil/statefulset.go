@@ -0,0 +1,46 @@
+package k8sutil
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// WaitForStatefulSetToStart waits for all replicas of the statefulset to be updated and ready, and returns an
+// error if they are not ready before timeout
+func WaitForStatefulSetToStart(clientSet kubernetes.Interface, interval time.Duration,
+	timeout time.Duration, s *appsv1.StatefulSet) error {
+	var lastErr error
+	err := wait.PollImmediate(interval, timeout, func() (bool, error) {
+		statefulSet, err := clientSet.AppsV1().StatefulSets(s.GetNamespace()).Get(s.GetName(), metav1.GetOptions{})
+		if err != nil {
+			logger.Errorf("failed to get statefulset %s in cluster: %s", s.GetName(), err.Error())
+			lastErr = err
+			return false, nil
+		}
+		lastErr = nil
+		status := statefulSet.Status
+		if status.ObservedGeneration >= statefulSet.Generation &&
+			status.UpdatedReplicas == *statefulSet.Spec.Replicas &&
+			status.ReadyReplicas == *statefulSet.Spec.Replicas {
+			logger.Infof("statefulset %s has been started", statefulSet.Name)
+			return true, nil
+		}
+		logger.Infof("statefulset %s is starting, Generation: %d, ObservedGeneration: %d, UpdatedReplicas: %d,"+
+			" ReadyReplicas: %d", statefulSet.Name, statefulSet.GetGeneration(), status.ObservedGeneration,
+			status.UpdatedReplicas, status.ReadyReplicas)
+		return false, nil
+	})
+	if err == nil {
+		return nil
+	}
+	if lastErr != nil {
+		return errors.Wrapf(lastErr, "failed to wait for statefulset %s to start after %vs waiting",
+			s.GetName(), timeout.Seconds())
+	}
+	return errors.Errorf("failed to wait for statefulset %s to start after %vs waiting", s.GetName(), timeout.Seconds())
+}
@@ -0,0 +1,176 @@
+package k8sutil
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+const (
+	defaultWindowMinutes = 240
+	// maxWindowSearchDays is how far the next window is searched for
+	maxWindowSearchDays = 366 * 5
+)
+
+// cronSchedule is a parsed cron schedule of five fields, each field is the bitset of the values it matches
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny tell whether the day of month and day of week are "*", a day matches either of them if
+	// both are restricted
+	domAny, dowAny bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// parseCronSchedule parses the standard cron schedule with the fields of minute, hour, day of month, month and
+// day of week, each field is "*" or a list of values, ranges and steps such as "1,3-5,*/10"
+func parseCronSchedule(schedule string) (*cronSchedule, error) {
+	fields := strings.Fields(schedule)
+	if len(fields) != len(cronFields) {
+		return nil, errors.Errorf("cron schedule %q should have %d fields", schedule, len(cronFields))
+	}
+
+	bits := make([]uint64, len(cronFields))
+	for i, field := range fields {
+		for _, part := range strings.Split(field, ",") {
+			b, err := parseCronPart(part, cronFields[i])
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid cron schedule %q", schedule)
+			}
+			bits[i] |= b
+		}
+	}
+	// both 0 and 7 are sunday
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+	return &cronSchedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		domAny: fields[2] == "*",
+		dowAny: fields[4] == "*",
+	}, nil
+}
+
+func parseCronPart(part string, field cronField) (uint64, error) {
+	rangePart, step := part, 1
+	if i := strings.Index(part, "/"); i >= 0 {
+		var err error
+		rangePart = part[:i]
+		step, err = strconv.Atoi(part[i+1:])
+		if err != nil || step <= 0 {
+			return 0, errors.Errorf("invalid step %q of %s", part[i+1:], field.name)
+		}
+	}
+
+	low, high := field.min, field.max
+	if rangePart != "*" {
+		bounds := strings.SplitN(rangePart, "-", 2)
+		var err error
+		if low, err = strconv.Atoi(bounds[0]); err != nil {
+			return 0, errors.Errorf("invalid value %q of %s", bounds[0], field.name)
+		}
+		high = low
+		if len(bounds) == 2 {
+			if high, err = strconv.Atoi(bounds[1]); err != nil {
+				return 0, errors.Errorf("invalid value %q of %s", bounds[1], field.name)
+			}
+		} else if step > 1 {
+			high = field.max
+		}
+	}
+	if low < field.min || high > field.max || low > high {
+		return 0, errors.Errorf("%q of %s is out of range [%d, %d]", rangePart, field.name, field.min, field.max)
+	}
+
+	var bits uint64
+	for v := low; v <= high; v += step {
+		bits |= 1 << uint(v)
+	}
+	return bits, nil
+}
+
+func (s *cronSchedule) matchDay(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+func (s *cronSchedule) match(t time.Time) bool {
+	return s.minute&(1<<uint(t.Minute())) != 0 && s.hour&(1<<uint(t.Hour())) != 0 &&
+		s.month&(1<<uint(t.Month())) != 0 && s.matchDay(t)
+}
+
+// next returns the first time not before t that matches the schedule
+func (s *cronSchedule) next(t time.Time) (time.Time, bool) {
+	t = t.Truncate(time.Minute)
+	end := t.AddDate(0, 0, maxWindowSearchDays)
+	for t.Before(end) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ValidateMaintenanceWindow checks that the schedule of the maintenance window is valid and ever opens
+func ValidateMaintenanceWindow(window *curvev1.MaintenanceWindowSpec) error {
+	_, _, err := MaintenanceWindowOpen(window, time.Now())
+	return err
+}
+
+// MaintenanceWindowOpen returns whether the maintenance window is open at now, and the start of the next window
+// if it's closed. It's always open if the window is not set.
+func MaintenanceWindowOpen(window *curvev1.MaintenanceWindowSpec, now time.Time) (bool, time.Time, error) {
+	if window == nil {
+		return true, time.Time{}, nil
+	}
+	schedule, err := parseCronSchedule(window.Schedule)
+	if err != nil {
+		return false, time.Time{}, NewConfigError(errors.Wrap(err, "failed to parse maintenanceWindow.schedule"))
+	}
+	minutes := window.WindowMinutes
+	if minutes <= 0 {
+		minutes = defaultWindowMinutes
+	}
+
+	now = now.UTC().Truncate(time.Minute)
+	start, ok := schedule.next(now.Add(-time.Duration(minutes-1) * time.Minute))
+	if !ok {
+		return false, time.Time{}, NewConfigError(errors.Errorf("maintenanceWindow.schedule %q never opens", window.Schedule))
+	}
+	if !start.After(now) {
+		return true, time.Time{}, nil
+	}
+	return false, start, nil
+}
@@ -0,0 +1,67 @@
+package k8sutil
+
+import (
+	"testing"
+	"time"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+func TestMaintenanceWindowOpen(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	// 2:00 every Saturday for 2 hours, 2026-10-17 is a Saturday
+	window := &curvev1.MaintenanceWindowSpec{Schedule: "0 2 * * 6", WindowMinutes: 120}
+	for _, c := range []struct {
+		now  string
+		open bool
+		next string
+	}{
+		{"2026-10-17 01:59", false, "2026-10-17 02:00"},
+		{"2026-10-17 02:00", true, ""},
+		{"2026-10-17 03:59", true, ""},
+		{"2026-10-17 04:00", false, "2026-10-24 02:00"},
+		{"2026-10-20 12:30", false, "2026-10-24 02:00"},
+	} {
+		open, next, err := MaintenanceWindowOpen(window, at(c.now))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if open != c.open {
+			t.Errorf("at %s: expected open %v, got %v", c.now, c.open, open)
+		}
+		if !c.open && !next.Equal(at(c.next)) {
+			t.Errorf("at %s: expected the next window at %s, got %s", c.now, c.next, next)
+		}
+	}
+
+	// the day matches either the day of month or the day of week if both are restricted
+	window = &curvev1.MaintenanceWindowSpec{Schedule: "*/30 22-23 1,15 * 0"}
+	for now, open := range map[string]bool{
+		"2026-10-15 22:31": true,
+		"2026-10-18 23:59": true,
+		"2026-10-19 03:29": true,
+		"2026-10-19 03:30": false,
+		"2026-10-16 12:00": false,
+	} {
+		if got, _, err := MaintenanceWindowOpen(window, at(now)); err != nil || got != open {
+			t.Errorf("at %s: expected open %v, got %v %v", now, open, got, err)
+		}
+	}
+
+	if open, _, err := MaintenanceWindowOpen(nil, time.Now()); !open || err != nil {
+		t.Errorf("expected no window to be always open, got %v %v", open, err)
+	}
+	for _, schedule := range []string{"0 2 * *", "60 2 * * *", "0 2 * * 1-8", "0 2 */0 * *", "0 0 30 2 *"} {
+		errs := ToClusterErrors(ValidateMaintenanceWindow(&curvev1.MaintenanceWindowSpec{Schedule: schedule}))
+		if len(errs) != 1 || errs[0].Category != curvev1.ErrorCategoryConfigError {
+			t.Errorf("expected a config error of schedule %q, got %+v", schedule, errs)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package mds
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/curvetool"
+)
+
+var (
+	// the topology metrics are exported by the leader of mds only, such as
+	// 'topology_metric_physicalPool_pool1_diskCapacity : 3221225472000', they are keyed by name in the bvars
+	physicalPoolMetric = regexp.MustCompile(`^topology_metric_physicalPool_(\S+)_(diskCapacity|diskUsed)$`)
+	logicalPoolMetric  = regexp.MustCompile(`^topology_metric_logicalPool_(\S+)_(chunkSizeTotalBytes|chunkSizeUsedBytes)$`)
+)
+
+// PoolCapacity is the capacity of a logical pool
+type PoolCapacity struct {
+	Name       string
+	TotalBytes int64
+	UsedBytes  int64
+}
+
+// Capacity is the capacity of the cluster, the total and used bytes are of the physical pools
+type Capacity struct {
+	TotalBytes int64
+	UsedBytes  int64
+	Pools      []PoolCapacity
+}
+
+// GetCapacity gets the capacity from the metrics of mds leader by the dummy port
+func GetCapacity(clientset kubernetes.Interface, namespace string, dummyPort int) (*Capacity, error) {
+	client, err := NewClientForPods(clientset, namespace, dummyPort)
+	if err != nil {
+		return nil, err
+	}
+	return client.Capacity()
+}
+
+// CopysetsHealth is the number of all the copysets of the cluster and the unhealthy ones
+type CopysetsHealth struct {
+	Total     int
+	Unhealthy int
+}
+
+// GetCopysetsHealth gets the number of copysets and the unhealthy ones by curve_ops_tool in a running mds pod
+func GetCopysetsHealth(c *clusterd.Context, namespace, mdsAddr string) (*CopysetsHealth, error) {
+	executor := curvetool.NewPodExecutor(c, namespace, fmt.Sprintf("app=%s", AppName))
+	status, err := curvetool.New(executor, "-mdsAddr="+mdsAddr).CopysetsStatus()
+	if err != nil {
+		return nil, err
+	}
+	return &CopysetsHealth{Total: status.Total, Unhealthy: status.Unhealthy}, nil
+}
+
+// capacityFromVars returns nil if the topology metrics are not exported, the topology is not created yet
+func capacityFromVars(vars map[string]string) *Capacity {
+	found := false
+	capacity := &Capacity{}
+	pools := map[string]*PoolCapacity{}
+	for name, v := range vars {
+		if match := physicalPoolMetric.FindStringSubmatch(name); match != nil {
+			value, _ := strconv.ParseInt(v, 10, 64)
+			if match[2] == "diskCapacity" {
+				capacity.TotalBytes += value
+			} else {
+				capacity.UsedBytes += value
+			}
+			found = true
+		} else if match := logicalPoolMetric.FindStringSubmatch(name); match != nil {
+			pool, ok := pools[match[1]]
+			if !ok {
+				pool = &PoolCapacity{Name: match[1]}
+				pools[match[1]] = pool
+			}
+			value, _ := strconv.ParseInt(v, 10, 64)
+			if match[2] == "chunkSizeTotalBytes" {
+				pool.TotalBytes = value
+			} else {
+				pool.UsedBytes = value
+			}
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	for _, pool := range pools {
+		capacity.Pools = append(capacity.Pools, *pool)
+	}
+	sort.Slice(capacity.Pools, func(i, j int) bool {
+		return capacity.Pools[i].Name < capacity.Pools[j].Name
+	})
+
+	return capacity
+}
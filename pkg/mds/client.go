@@ -0,0 +1,270 @@
+package mds
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	clientRequestTimeout = 10 * time.Second
+
+	// mds exports its role by the bvar 'mds_status : leader' or 'mds_status : follower'
+	statusVar    = "mds_status"
+	statusLeader = "leader"
+)
+
+var (
+	// the topology metrics are exported by the leader of mds only, such as 'topology_metric_chunkserver_1_copysetNum : 100'
+	chunkServerMetric = regexp.MustCompile(`^topology_metric_chunkserver_(\d+)_(\w+)$`)
+	poolMetric        = regexp.MustCompile(`^topology_metric_logicalPool_(\S+)_(serverNum|chunkServerNum|onlineChunkServerNum|copysetNum)$`)
+)
+
+// Client queries the mds by the bvars of brpc on their dummy ports, so the status and the topology are got without
+// exec sessions or jobs
+type Client struct {
+	addrs  []string
+	client *http.Client
+}
+
+// NewClient returns a client of the mds of the dummy addresses ip:port
+func NewClient(addrs []string) *Client {
+	return &Client{
+		addrs:  addrs,
+		client: &http.Client{Timeout: clientRequestTimeout},
+	}
+}
+
+// NewClientForPods returns a client of the running mds pods of the namespace, mds runs in host network
+func NewClientForPods(clientset kubernetes.Interface, namespace string, dummyPort int) (*Client, error) {
+	pods, err := clientset.CoreV1().Pods(namespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=%s", AppName),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list mds pods")
+	}
+	var addrs []string
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != v1.PodRunning || pod.Status.HostIP == "" {
+			continue
+		}
+		addrs = append(addrs, fmt.Sprintf("%s:%d", pod.Status.HostIP, dummyPort))
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("no running mds pod")
+	}
+	return NewClient(addrs), nil
+}
+
+// PoolTopology is the topology of a logical pool
+type PoolTopology struct {
+	Name                 string
+	ServerNum            int
+	ChunkServerNum       int
+	OnlineChunkServerNum int
+	CopysetNum           int
+}
+
+// ChunkServerTopology is the copysets and the disk of a chunkserver in topology
+type ChunkServerTopology struct {
+	ID                int
+	CopysetNum        int
+	LeaderNum         int
+	DiskCapacityBytes int64
+	DiskUsedBytes     int64
+}
+
+// Topology is the logical pools and the chunkservers of the cluster
+type Topology struct {
+	Pools        []PoolTopology
+	ChunkServers []ChunkServerTopology
+}
+
+// Leader returns the dummy address of the leader of mds
+func (c *Client) Leader() (string, error) {
+	addr, _, err := c.leaderVars()
+	return addr, err
+}
+
+// WaitForLeader polls the running mds pods of the namespace by their dummy ports until one of them reports that it's
+// the leader, and returns its dummy address. The last failure is returned if no leader is elected in timeout.
+func WaitForLeader(clientset kubernetes.Interface, namespace string, dummyPort int, interval, timeout time.Duration) (string, error) {
+	var leader string
+	var lastErr error
+	err := wait.PollImmediate(interval, timeout, func() (bool, error) {
+		client, err := NewClientForPods(clientset, namespace, dummyPort)
+		if err != nil {
+			lastErr = err
+			return false, nil
+		}
+		if leader, err = client.Leader(); err != nil {
+			lastErr = err
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		if lastErr != nil {
+			return "", errors.Wrapf(lastErr, "mds leader is not elected in %s", timeout)
+		}
+		return "", errors.Errorf("mds leader is not elected in %s", timeout)
+	}
+	return leader, nil
+}
+
+// Capacity returns the capacity from the metrics of the leader of mds
+func (c *Client) Capacity() (*Capacity, error) {
+	_, vars, err := c.leaderVars()
+	if err != nil {
+		return nil, err
+	}
+	capacity := capacityFromVars(vars)
+	if capacity == nil {
+		return nil, errors.New("mds leader reports no topology metrics")
+	}
+	return capacity, nil
+}
+
+// Topology returns the logical pools and the chunkservers from the metrics of the leader of mds
+func (c *Client) Topology() (*Topology, error) {
+	_, vars, err := c.leaderVars()
+	if err != nil {
+		return nil, err
+	}
+	return topologyFromVars(vars), nil
+}
+
+// leaderVars returns the address and the bvars of the leader of mds. The old versions don't export mds_status,
+// the one that exports the topology metrics is the leader then.
+func (c *Client) leaderVars() (string, map[string]string, error) {
+	var lastErr error
+	for _, addr := range c.addrs {
+		vars, err := c.vars(addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if status, ok := vars[statusVar]; ok {
+			if status == statusLeader {
+				return addr, vars, nil
+			}
+			continue
+		}
+		for name := range vars {
+			if strings.HasPrefix(name, "topology_metric_") {
+				return addr, vars, nil
+			}
+		}
+	}
+	if lastErr != nil {
+		return "", nil, lastErr
+	}
+	return "", nil, errors.New("no mds leader is found")
+}
+
+// vars returns all the bvars of the mds
+func (c *Client) vars(addr string) (map[string]string, error) {
+	url := fmt.Sprintf("http://%s/vars", addr)
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get %s", url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("failed to get %s, status %s", url, resp.Status)
+	}
+	vars, err := parseVars(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", url)
+	}
+	return vars, nil
+}
+
+// parseVars parses the bvars printed as 'name : value' per line
+func parseVars(r io.Reader) (map[string]string, error) {
+	vars := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	// the values of some bvars are long
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), " : ", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		vars[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+func topologyFromVars(vars map[string]string) *Topology {
+	pools := map[string]*PoolTopology{}
+	chunkservers := map[int]*ChunkServerTopology{}
+	for name, value := range vars {
+		if match := poolMetric.FindStringSubmatch(name); match != nil {
+			pool, ok := pools[match[1]]
+			if !ok {
+				pool = &PoolTopology{Name: match[1]}
+				pools[match[1]] = pool
+			}
+			n, _ := strconv.Atoi(value)
+			switch match[2] {
+			case "serverNum":
+				pool.ServerNum = n
+			case "chunkServerNum":
+				pool.ChunkServerNum = n
+			case "onlineChunkServerNum":
+				pool.OnlineChunkServerNum = n
+			case "copysetNum":
+				pool.CopysetNum = n
+			}
+		} else if match := chunkServerMetric.FindStringSubmatch(name); match != nil {
+			id, _ := strconv.Atoi(match[1])
+			cs, ok := chunkservers[id]
+			if !ok {
+				cs = &ChunkServerTopology{ID: id}
+				chunkservers[id] = cs
+			}
+			n, _ := strconv.ParseInt(value, 10, 64)
+			switch match[2] {
+			case "copysetNum":
+				cs.CopysetNum = int(n)
+			case "leaderNum":
+				cs.LeaderNum = int(n)
+			case "diskCapacity":
+				cs.DiskCapacityBytes = n
+			case "diskUsed":
+				cs.DiskUsedBytes = n
+			}
+		}
+	}
+
+	topology := &Topology{}
+	for _, pool := range pools {
+		topology.Pools = append(topology.Pools, *pool)
+	}
+	sort.Slice(topology.Pools, func(i, j int) bool {
+		return topology.Pools[i].Name < topology.Pools[j].Name
+	})
+	for _, cs := range chunkservers {
+		topology.ChunkServers = append(topology.ChunkServers, *cs)
+	}
+	sort.Slice(topology.ChunkServers, func(i, j int) bool {
+		return topology.ChunkServers[i].ID < topology.ChunkServers[j].ID
+	})
+	return topology
+}
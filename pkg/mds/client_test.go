@@ -0,0 +1,105 @@
+package mds
+
+import (
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/opencurve/curve-operator/pkg/internal/testutil"
+)
+
+func TestClient(t *testing.T) {
+	follower := testutil.NewVarsServer(t, "mds_status : follower\ncurve_version : 1.2.5\n")
+	leader := testutil.NewVarsServer(t, `mds_status : leader
+topology_metric_physicalPool_pool1_diskCapacity : 3000
+topology_metric_physicalPool_pool1_diskUsed : 300
+topology_metric_logicalPool_logical_pool1_chunkSizeTotalBytes : 1000
+topology_metric_logicalPool_logical_pool1_chunkSizeUsedBytes : 100
+topology_metric_logicalPool_logical_pool1_serverNum : 3
+topology_metric_logicalPool_logical_pool1_chunkServerNum : 6
+topology_metric_logicalPool_logical_pool1_onlineChunkServerNum : 5
+topology_metric_logicalPool_logical_pool1_copysetNum : 200
+topology_metric_chunkserver_2_copysetNum : 100
+topology_metric_chunkserver_2_leaderNum : 30
+topology_metric_chunkserver_1_copysetNum : 100
+topology_metric_chunkserver_1_diskCapacity : 500
+topology_metric_chunkserver_1_diskUsed : 50
+`)
+	client := NewClient([]string{follower, leader})
+
+	addr, err := client.Leader()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if addr != leader {
+		t.Errorf("expected leader %s, got %s", leader, addr)
+	}
+
+	capacity, err := client.Capacity()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedCapacity := &Capacity{TotalBytes: 3000, UsedBytes: 300,
+		Pools: []PoolCapacity{{Name: "logical_pool1", TotalBytes: 1000, UsedBytes: 100}}}
+	if !reflect.DeepEqual(capacity, expectedCapacity) {
+		t.Errorf("expected capacity %+v, got %+v", expectedCapacity, capacity)
+	}
+
+	topology, err := client.Topology()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedTopology := &Topology{
+		Pools: []PoolTopology{{Name: "logical_pool1", ServerNum: 3, ChunkServerNum: 6, OnlineChunkServerNum: 5, CopysetNum: 200}},
+		ChunkServers: []ChunkServerTopology{
+			{ID: 1, CopysetNum: 100, DiskCapacityBytes: 500, DiskUsedBytes: 50},
+			{ID: 2, CopysetNum: 100, LeaderNum: 30},
+		},
+	}
+	if !reflect.DeepEqual(topology, expectedTopology) {
+		t.Errorf("expected topology %+v, got %+v", expectedTopology, topology)
+	}
+
+	// the leader without topology has no capacity
+	if _, err := NewClient([]string{testutil.NewVarsServer(t, "mds_status : leader\n")}).Capacity(); err == nil {
+		t.Error("expected error without topology metrics")
+	}
+	if _, err := NewClient([]string{follower}).Leader(); err == nil {
+		t.Error("expected error without leader")
+	}
+}
+
+func TestWaitForLeader(t *testing.T) {
+	addr := testutil.NewVarsServer(t, "mds_status : leader\n")
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dummyPort, _ := strconv.Atoi(port)
+
+	clientset := fake.NewSimpleClientset()
+	if _, err := WaitForLeader(clientset, "curve", dummyPort, 10*time.Millisecond, 50*time.Millisecond); err == nil {
+		t.Error("expected error without running mds pod")
+	}
+
+	_, err = clientset.CoreV1().Pods("curve").Create(&v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "curve-mds-a", Namespace: "curve", Labels: map[string]string{"app": AppName}},
+		Status:     v1.PodStatus{Phase: v1.PodRunning, HostIP: host},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leader, err := WaitForLeader(clientset, "curve", dummyPort, 10*time.Millisecond, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if leader != addr {
+		t.Errorf("expected leader %s, got %s", addr, leader)
+	}
+}
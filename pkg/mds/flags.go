@@ -0,0 +1,163 @@
+package mds
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+const (
+	// FlagsConfigMapName is the configmap of the flagfile of all mds
+	FlagsConfigMapName = "curve-mds-flags"
+	// FlagsConfigMapDataKey is the key of the flagfile in the configmap
+	FlagsConfigMapDataKey = "mds.flags"
+	// FlagsMountPathDir is the directory that the flagfile is mounted on
+	FlagsMountPathDir = "/curvebs/mds/flags"
+
+	flagsVolumeName    = "mds-flags"
+	flagRequestTimeout = 10 * time.Second
+)
+
+// renderFlags renders the flags to a flagfile of gflags, one '--name=value' per line sorted by name
+func renderFlags(flags map[string]string) string {
+	var names []string
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "--%s=%s\n", name, flags[name])
+	}
+	return b.String()
+}
+
+// createFlagsConfigMap creates or updates the configmap of the flagfile by spec.mds.flags
+func (c *Cluster) createFlagsConfigMap() error {
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      FlagsConfigMapName,
+			Namespace: c.namespacedName.Namespace,
+		},
+		Data: map[string]string{
+			FlagsConfigMapDataKey: renderFlags(c.spec.Mds.Flags),
+		},
+	}
+
+	k8sutil.InjectMetadata(c.spec, "mds", cm)
+	err := c.ownerInfo.SetControllerReference(cm)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set owner reference to mds configmap %q", FlagsConfigMapName)
+	}
+	if err := k8sutil.Apply(c.context.Client, cm); err != nil {
+		return errors.Wrapf(err, "failed to apply mds configmap %q", FlagsConfigMapName)
+	}
+	return nil
+}
+
+// UpdateFlags updates the flagfile to spec.mds.flags, the running mds reads it when it restarts
+func (c *Cluster) UpdateFlags() error {
+	return c.createFlagsConfigMap()
+}
+
+// SetFlagFile mounts the flagfile to the mds container of the pod template and passes it by --flagfile, it does
+// nothing if it's set already. The configmap is mounted by a projected volume that is not counted in the config
+// hash, so the flags hot-reloaded by the admin interface of mds don't restart it.
+func SetFlagFile(template *v1.PodTemplateSpec) {
+	for _, volume := range template.Spec.Volumes {
+		if volume.Name == flagsVolumeName {
+			return
+		}
+	}
+
+	mode := int32(0644)
+	template.Spec.Volumes = append(template.Spec.Volumes, v1.Volume{
+		Name: flagsVolumeName,
+		VolumeSource: v1.VolumeSource{
+			Projected: &v1.ProjectedVolumeSource{
+				Sources: []v1.VolumeProjection{
+					{
+						ConfigMap: &v1.ConfigMapProjection{
+							LocalObjectReference: v1.LocalObjectReference{Name: FlagsConfigMapName},
+							Items:                []v1.KeyToPath{{Key: FlagsConfigMapDataKey, Path: FlagsConfigMapDataKey, Mode: &mode}},
+						},
+					},
+				},
+			},
+		},
+	})
+	// the first container is the daemon, the others are sidecars
+	container := &template.Spec.Containers[0]
+	container.VolumeMounts = append(container.VolumeMounts, v1.VolumeMount{
+		Name:      flagsVolumeName,
+		ReadOnly:  true,
+		MountPath: FlagsMountPathDir,
+	})
+	container.Args = append(container.Args, fmt.Sprintf("--flagfile=%s", path.Join(FlagsMountPathDir, FlagsConfigMapDataKey)))
+}
+
+// SetFlag sets the flag of all the running mds by the flags service of brpc on the dummy port. It returns false
+// without error if the flag is not reloadable on any mds, the flag must be applied by restarting mds then.
+func SetFlag(clientset kubernetes.Interface, namespace string, dummyPort int, name, value string) (bool, error) {
+	pods, err := clientset.CoreV1().Pods(namespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=%s", AppName),
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "failed to list mds pods")
+	}
+
+	client := &http.Client{Timeout: flagRequestTimeout}
+	reloaded := false
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != v1.PodRunning || pod.Status.HostIP == "" {
+			continue
+		}
+		// mds runs in host network
+		u := fmt.Sprintf("http://%s:%d/flags/%s?setvalue=%s", pod.Status.HostIP, dummyPort, url.PathEscape(name), url.QueryEscape(value))
+		ok, err := setFlag(client, u)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			logger.Infof("flag %q of mds %q is not reloadable", name, pod.Name)
+			return false, nil
+		}
+		reloaded = true
+	}
+	if !reloaded {
+		return false, errors.New("no mds is running to set the flag")
+	}
+	return true, nil
+}
+
+// setFlag returns false if the flag is refused by brpc, because it's not reloadable or the value is refused by
+// its validator
+func setFlag(client *http.Client, u string) (bool, error) {
+	resp, err := client.Get(u)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get %s", u)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusForbidden:
+		// brpc refuses the flags without validator, which are not reloadable
+		return false, nil
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	return false, errors.Errorf("failed to get %s, status %s: %s", u, resp.Status, strings.TrimSpace(string(body)))
+}
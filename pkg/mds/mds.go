@@ -10,8 +10,6 @@ import (
 	"github.com/coreos/pkg/capnslog"
 	"github.com/pkg/errors"
 	appsv1 "k8s.io/api/apps/v1"
-	kerrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 
 	curvev1 "github.com/opencurve/curve-operator/api/v1"
@@ -28,6 +26,10 @@ const (
 	Prefix           = "/curvebs/mds"
 	ContainerDataDir = "/curvebs/mds/data"
 	ContainerLogDir  = "/curvebs/mds/logs"
+
+	// defaultTerminationGracePeriodSeconds is the termination grace period if mds.terminationGracePeriodSeconds
+	// is not set
+	defaultTerminationGracePeriodSeconds = 30
 )
 
 type Cluster struct {
@@ -62,38 +64,37 @@ func New(context clusterd.Context,
 
 // Start Curve mds daemon
 func (c *Cluster) Start(nodeNameIP map[string]string) error {
-	// check if the etcd override configmap exist
-	overrideCM, err := c.context.Clientset.CoreV1().ConfigMaps(c.namespacedName.Namespace).Get(config.EtcdOverrideConfigMapName, metav1.GetOptions{})
-	if err != nil {
-		return errors.Wrapf(err, "failed to get %s configmap from cluster", config.EtcdOverrideConfigMapName)
-	}
-
-	// get etcd endpoints from key of "clusterEtcdAddr" of etcd-endpoints-override
-	clusterEtcdAddr := overrideCM.Data[config.ClusterEtcdAddr]
-
-	// create mds override configmap to record mds endpoints
-	err = c.createOverrideMdsCM(nodeNameIP)
+	// get etcd endpoints recorded by etcd
+	clusterInfo, err := config.GetClusterInfo(&c.context, c.namespacedName.Namespace)
 	if err != nil {
 		return err
 	}
+	clusterEtcdAddr := clusterInfo.EtcdAddr
 
 	// reorder the nodeNameIP according to the order of nodes spec defined by the user
-	// nodes:
+	// mds.nodes(or nodes if not set):
 	// - node1 - curve-mds-a
 	// - node2  - curve-mds-b
 	// - node3 - curve-mds-c
-
 	nodeNamesOrdered := make([]string, 0)
-	for _, n := range c.spec.Nodes {
-		for nodeName := range nodeNameIP {
-			if n == nodeName {
-				nodeNamesOrdered = append(nodeNamesOrdered, nodeName)
-			}
+	for _, n := range c.spec.MdsNodes() {
+		if _, ok := nodeNameIP[n]; ok {
+			nodeNamesOrdered = append(nodeNamesOrdered, n)
 		}
 	}
 
 	if len(nodeNamesOrdered) != 3 {
-		return errors.New("Nodes spec field is not 3")
+		return errors.New("mds nodes count is not 3")
+	}
+
+	err = c.recordEndpoints(nodeNameIP)
+	if err != nil {
+		return err
+	}
+
+	// the flagfile is shared by all mds
+	if err := c.createFlagsConfigMap(); err != nil {
+		return err
 	}
 
 	daemonID := 0
@@ -132,25 +133,23 @@ func (c *Cluster) Start(nodeNameIP map[string]string) error {
 			return errors.Wrapf(err, "failed to create mds configmap %q", config.MdsConfigMapName)
 		}
 
+		err = c.createService(mdsConfig)
+		if err != nil {
+			return err
+		}
+
 		// make mds deployment
 		d, err := c.makeDeployment(nodeName, nodeNameIP[nodeName], mdsConfig)
 		if err != nil {
-			return errors.Wrapf(err, "failed to create mds Deployment %q", mdsConfig.ResourceName)
+			return errors.Wrapf(err, "failed to create mds Deployment %q", resourceName)
 		}
 
-		newDeployment, err := c.context.Clientset.AppsV1().Deployments(c.namespacedName.Namespace).Create(d)
+		// the deployment is only updated when the rendered spec or config changed to avoid restarting pods
+		newDeployment, err := k8sutil.CreateOrUpdateDeployment(&c.context, d)
 		if err != nil {
-			if !kerrors.IsAlreadyExists(err) {
-				return errors.Wrapf(err, "failed to create mds deployment %s", resourceName)
-			}
-			logger.Infof("deployment for mds %s already exists. updating if needed", resourceName)
-
-			// TODO:Update the daemon Deployment
-			// if err := updateDeploymentAndWait(c.context, c.clusterInfo, d, config.MgrType, mgrConfig.DaemonID, c.spec.SkipUpgradeChecks, false); err != nil {
-			// 	logger.Errorf("failed to update mgr deployment %q. %v", resourceName, err)
-			// }
-		} else {
-			logger.Infof("Deployment %s has been created , waiting for startup", newDeployment.GetName())
+			return errors.Wrapf(err, "failed to create mds deployment %s", resourceName)
+		}
+		if newDeployment != nil {
 			deploymentsToWaitFor = append(deploymentsToWaitFor, newDeployment)
 		}
 		// update condition type and phase etc.
@@ -162,7 +161,7 @@ func (c *Cluster) Start(nodeNameIP map[string]string) error {
 		return err
 	}
 
-	k8sutil.UpdateCondition(context.TODO(), &c.context, c.namespacedName, curvev1.ConditionTypeMdsReady, curvev1.ConditionTrue, curvev1.ConditionMdsClusterCreatedReason, "MDS cluster has been created")
+	k8sutil.SetReady(context.TODO(), &c.context, c.namespacedName, curvev1.ConditionTypeMdsReady, curvev1.ConditionMdsClusterCreatedReason, "MDS cluster has been created")
 
 	return nil
 }
@@ -0,0 +1,25 @@
+package mds
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// createService creates the headless service of the mds to give it a stable DNS name
+func (c *Cluster) createService(mdsConfig *mdsConfig) error {
+	svc := k8sutil.MakeHeadlessService(mdsConfig.ResourceName, c.namespacedName.Namespace, c.getPodLabels(mdsConfig),
+		map[string]int{"mds": c.spec.Mds.Port, "dummy": c.spec.Mds.DummyPort})
+
+	k8sutil.InjectMetadata(c.spec, "mds", svc)
+	err := c.ownerInfo.SetControllerReference(svc)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set owner reference to mds service %q", mdsConfig.ResourceName)
+	}
+
+	err = k8sutil.Apply(c.context.Client, svc)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create mds service %q", mdsConfig.ResourceName)
+	}
+	return nil
+}
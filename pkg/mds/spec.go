@@ -11,52 +11,43 @@ import (
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
 	"github.com/opencurve/curve-operator/pkg/config"
 	"github.com/opencurve/curve-operator/pkg/daemon"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
 )
 
-// createOverrideMdsCM create mds-endpoints-override configmap to record mds endpoints
-func (c *Cluster) createOverrideMdsCM(nodeNameIP map[string]string) error {
-	var mds_endpoints string
-	for _, ipAddr := range nodeNameIP {
-		mds_endpoints = fmt.Sprint(mds_endpoints, ipAddr, ":", c.spec.Mds.Port, ",")
+// Endpoints returns the addresses of mds on the nodes, they are DNS names of the services if
+// network.useServiceDNS is set
+func Endpoints(spec curvev1.CurveClusterSpec, namespace string, nodeNameIP map[string]string) string {
+	var mdsNodes []string
+	for _, nodeName := range spec.MdsNodes() {
+		if _, ok := nodeNameIP[nodeName]; ok {
+			mdsNodes = append(mdsNodes, nodeName)
+		}
 	}
-	mds_endpoints = strings.TrimRight(mds_endpoints, ",")
-
-	mdsConfigMapData := map[string]string{
-		config.MdsOvverideConfigMapDataKey: mds_endpoints,
+	if spec.Network.UseServiceDNS {
+		return k8sutil.DaemonServiceAddrs(AppName, namespace, len(mdsNodes), spec.Mds.Port)
 	}
 
-	// create mds override configMap to record the endpoints of etcd
-	// mds-endpoints-override configmap only has one "mdsEndpoints" key that the value is mds cluster endpoints
-	mdsOverrideCM := &v1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      config.MdsOverrideConfigMapName,
-			Namespace: c.namespacedName.Namespace,
-		},
-		Data: mdsConfigMapData,
+	var mds_endpoints string
+	for _, nodeName := range mdsNodes {
+		mds_endpoints = fmt.Sprint(mds_endpoints, nodeNameIP[nodeName], ":", spec.Mds.Port, ",")
 	}
+	return strings.TrimRight(mds_endpoints, ",")
+}
 
-	err := c.ownerInfo.SetControllerReference(mdsOverrideCM)
-	if err != nil {
-		return errors.Wrapf(err, "failed to set owner reference to mds override configmap %q", config.MdsOverrideConfigMapName)
-	}
+// recordEndpoints records the endpoints of mds in cluster info for chunkserver and snapshotclone use
+func (c *Cluster) recordEndpoints(nodeNameIP map[string]string) error {
+	mds_endpoints := Endpoints(c.spec, c.namespacedName.Namespace, nodeNameIP)
 
-	_, err = c.context.Clientset.CoreV1().ConfigMaps(c.namespacedName.Namespace).Create(mdsOverrideCM)
+	err := config.UpdateClusterInfo(&c.context, c.namespacedName.Namespace, c.ownerInfo, c.spec, func(info *config.ClusterInfo) {
+		info.MdsAddr = mds_endpoints
+	})
 	if err != nil {
-		if !kerrors.IsAlreadyExists(err) {
-			return errors.Wrapf(err, "failed to create override configmap %s", c.namespacedName.Namespace)
-		}
-		logger.Infof("ConfigMap for override mds endpoints %s already exists. updating if needed", config.MdsOverrideConfigMapName)
-
-		// TODO:Update the daemon Deployment
-		// if err := updateDeploymentAndWait(c.context, c.clusterInfo, d, config.MgrType, mgrConfig.DaemonID, c.spec.SkipUpgradeChecks, false); err != nil {
-		// 	logger.Errorf("failed to update mgr deployment %q. %v", resourceName, err)
-		// }
-	} else {
-		logger.Infof("ConfigMap %s for override mds endpoints has been created", config.MdsOverrideConfigMapName)
+		return errors.Wrap(err, "failed to record mds endpoints")
 	}
-
+	logger.Infof("mds endpoints %s have been recorded", mds_endpoints)
 	return nil
 }
 
@@ -96,14 +87,15 @@ func (c *Cluster) createMdsConfigMap(mdsConfig *mdsConfig) error {
 		Data: mdsConfigMapData,
 	}
 
+	k8sutil.InjectMetadata(c.spec, "mds", cm)
 	err = c.ownerInfo.SetControllerReference(cm)
 	if err != nil {
 		return errors.Wrapf(err, "failed to set owner reference to mds configmap %q", config.MdsConfigMapName)
 	}
 
 	// 5. create mds configmap in cluster
-	_, err = c.context.Clientset.CoreV1().ConfigMaps(c.namespacedName.Namespace).Create(cm)
-	if err != nil && !kerrors.IsAlreadyExists(err) {
+	err = k8sutil.Apply(c.context.Client, cm)
+	if err != nil {
 		return errors.Wrapf(err, "failed to create mds configmap %s", c.namespacedName.Namespace)
 	}
 
@@ -123,16 +115,27 @@ func (c *Cluster) makeDeployment(nodeName string, nodeIP string, mdsConfig *mdsC
 			Labels: c.getPodLabels(mdsConfig),
 		},
 		Spec: v1.PodSpec{
-			Containers: []v1.Container{
-				c.makeMdsDaemonContainer(nodeIP, mdsConfig),
+			InitContainers: []v1.Container{
+				daemon.WaitForEndpointsInitContainer("wait-etcd", mdsConfig.ClusterEtcdAddr, c.spec.CurveVersion.Image, c.spec.CurveVersion.ImagePullPolicy),
 			},
-			NodeName:      nodeName,
-			RestartPolicy: v1.RestartPolicyAlways,
-			HostNetwork:   true,
-			DNSPolicy:     v1.DNSClusterFirstWithHostNet,
-			Volumes:       volumes,
+			Containers: append([]v1.Container{
+				c.makeMdsDaemonContainer(nodeIP, mdsConfig),
+			}, daemon.LogRotateContainers(c.spec.Logging, mdsConfig.DataPathMap.ContainerLogDir, c.spec.CurveVersion.Image, c.spec.CurveVersion.ImagePullPolicy)...),
+			Affinity:          k8sutil.DaemonAffinity(nodeName, AppName, c.namespacedName.Namespace),
+			RestartPolicy:     v1.RestartPolicyAlways,
+			HostNetwork:       true,
+			DNSPolicy:         v1.DNSClusterFirstWithHostNet,
+			Volumes:           volumes,
+			PriorityClassName: c.spec.PriorityClassName("mds"),
+			TerminationGracePeriodSeconds: daemon.TerminationGracePeriod(c.spec.Mds.TerminationGracePeriodSeconds,
+				defaultTerminationGracePeriodSeconds),
 		},
 	}
+	k8sutil.InjectEnv(&podSpec.Spec, c.spec, "mds")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, c.spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, c.spec)
+	k8sutil.InjectDNS(&podSpec.Spec, c.spec, "mds")
+	SetFlagFile(&podSpec)
 
 	replicas := int32(1)
 
@@ -155,6 +158,7 @@ func (c *Cluster) makeDeployment(nodeName string, nodeIP string, mdsConfig *mdsC
 	}
 
 	// set ownerReference
+	k8sutil.InjectMetadata(c.spec, "mds", d, &d.Spec.Template)
 	err := c.ownerInfo.SetControllerReference(d)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to set owner reference to mon deployment %q", d.Name)
@@ -193,7 +197,11 @@ func (c *Cluster) makeMdsDaemonContainer(nodeIP string, mdsConfig *mdsConfig) v1
 				Protocol:      v1.ProtocolTCP,
 			},
 		},
-		Env: []v1.EnvVar{{Name: "TZ", Value: "Asia/Hangzhou"}},
+		// the listen port is only served by the leader, so the dummy port is checked
+		LivenessProbe:  k8sutil.MakeProbe(k8sutil.TCPProbeHandler(c.spec.Mds.DummyPort), c.spec.Mds.LivenessProbe, k8sutil.DefaultLivenessProbe),
+		ReadinessProbe: k8sutil.MakeProbe(k8sutil.TCPProbeHandler(c.spec.Mds.DummyPort), c.spec.Mds.ReadinessProbe, k8sutil.DefaultReadinessProbe),
+		Env:            append(append([]v1.EnvVar{{Name: "TZ", Value: "Asia/Hangzhou"}}, daemon.LoggingEnv(c.spec.Logging)...), daemon.LogLevelEnv(c.spec.Mds.LogLevel)...),
+		Lifecycle:      daemon.PreStopHook(""),
 	}
 
 	return container
@@ -0,0 +1,81 @@
+package mds
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/opencurve/curve-operator/pkg/curvetool"
+)
+
+const (
+	// StaleDuplicateReason is the reason of a chunkserver that has the same address as a newer one, such as the
+	// chunkserver registered again after its node is renamed
+	StaleDuplicateReason = "Duplicate"
+	// StaleHostReason is the reason of a chunkserver or a server whose host ip is not in topology anymore
+	StaleHostReason = "HostRemoved"
+)
+
+// StaleChunkServer is a chunkserver left in topology that no chunkserver daemon serves anymore
+type StaleChunkServer struct {
+	curvetool.ChunkServer
+	Reason string
+}
+
+// StaleServer is a server left in topology whose host is not in topology.json anymore
+type StaleServer struct {
+	curvetool.Server
+	Reason string
+}
+
+// FindStaleTopology returns the stale chunkservers and servers in topology, sorted by id. The hostIPs are the
+// internal ips of the servers of topology.json. Of the chunkservers on the same ip:port the online one is the
+// current one, or the one of the largest id if none is online, the others are duplicates. The retired chunkservers
+// are skipped because they are removed from topology already.
+func FindStaleTopology(chunkservers []curvetool.ChunkServer, servers []curvetool.Server, hostIPs map[string]bool) ([]StaleChunkServer, []StaleServer) {
+	current := map[string]curvetool.ChunkServer{}
+	for _, cs := range chunkservers {
+		if cs.RWStatus == curvetool.ChunkServerRetired {
+			continue
+		}
+		addr := fmt.Sprintf("%s:%d", cs.HostIP, cs.Port)
+		last, ok := current[addr]
+		if !ok || newerChunkServer(cs, last) {
+			current[addr] = cs
+		}
+	}
+
+	var staleChunkServers []StaleChunkServer
+	for _, cs := range chunkservers {
+		if cs.RWStatus == curvetool.ChunkServerRetired {
+			continue
+		}
+		if !hostIPs[cs.HostIP] {
+			staleChunkServers = append(staleChunkServers, StaleChunkServer{ChunkServer: cs, Reason: StaleHostReason})
+		} else if current[fmt.Sprintf("%s:%d", cs.HostIP, cs.Port)].ID != cs.ID {
+			staleChunkServers = append(staleChunkServers, StaleChunkServer{ChunkServer: cs, Reason: StaleDuplicateReason})
+		}
+	}
+	sort.Slice(staleChunkServers, func(i, j int) bool {
+		return staleChunkServers[i].ID < staleChunkServers[j].ID
+	})
+
+	var staleServers []StaleServer
+	for _, server := range servers {
+		if !hostIPs[server.InternalIP] {
+			staleServers = append(staleServers, StaleServer{Server: server, Reason: StaleHostReason})
+		}
+	}
+	sort.Slice(staleServers, func(i, j int) bool {
+		return staleServers[i].ID < staleServers[j].ID
+	})
+	return staleChunkServers, staleServers
+}
+
+// newerChunkServer returns true if the chunkserver a is the current one rather than b on the same address
+func newerChunkServer(a, b curvetool.ChunkServer) bool {
+	aOnline, bOnline := a.OnlineState == curvetool.ChunkServerOnline, b.OnlineState == curvetool.ChunkServerOnline
+	if aOnline != bOnline {
+		return aOnline
+	}
+	return a.ID > b.ID
+}
@@ -0,0 +1,41 @@
+package mds
+
+import (
+	"testing"
+
+	"github.com/opencurve/curve-operator/pkg/curvetool"
+)
+
+func TestFindStaleTopology(t *testing.T) {
+	hostIPs := map[string]bool{"10.0.0.1": true, "10.0.0.2": true}
+	chunkservers := []curvetool.ChunkServer{
+		// registered again after the node is renamed, the offline one is stale
+		{ID: 1, HostIP: "10.0.0.1", Port: 8200, OnlineState: curvetool.ChunkServerOffline},
+		{ID: 5, HostIP: "10.0.0.1", Port: 8200, OnlineState: curvetool.ChunkServerOnline},
+		// neither is online, the older one is stale
+		{ID: 2, HostIP: "10.0.0.2", Port: 8200, OnlineState: curvetool.ChunkServerOffline},
+		{ID: 6, HostIP: "10.0.0.2", Port: 8200, OnlineState: curvetool.ChunkServerOffline},
+		{ID: 3, HostIP: "10.0.0.3", Port: 8200, OnlineState: curvetool.ChunkServerOffline},
+		{ID: 4, HostIP: "10.0.0.2", Port: 8201, RWStatus: curvetool.ChunkServerRetired, OnlineState: curvetool.ChunkServerOffline},
+		{ID: 7, HostIP: "10.0.0.2", Port: 8201, OnlineState: curvetool.ChunkServerOnline},
+	}
+	servers := []curvetool.Server{
+		{ID: 1, HostName: "node1", InternalIP: "10.0.0.1"},
+		{ID: 2, HostName: "node2", InternalIP: "10.0.0.2"},
+		{ID: 3, HostName: "node3", InternalIP: "10.0.0.3"},
+	}
+
+	staleChunkServers, staleServers := FindStaleTopology(chunkservers, servers, hostIPs)
+	expected := map[int]string{1: StaleDuplicateReason, 2: StaleDuplicateReason, 3: StaleHostReason}
+	if len(staleChunkServers) != len(expected) {
+		t.Fatalf("expected stale chunkservers %v, got %+v", expected, staleChunkServers)
+	}
+	for _, cs := range staleChunkServers {
+		if expected[cs.ID] != cs.Reason {
+			t.Errorf("expected chunkserver %d %q, got %q", cs.ID, expected[cs.ID], cs.Reason)
+		}
+	}
+	if len(staleServers) != 1 || staleServers[0].ID != 3 || staleServers[0].Reason != StaleHostReason {
+		t.Errorf("expected stale server 3, got %+v", staleServers)
+	}
+}
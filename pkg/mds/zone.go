@@ -0,0 +1,117 @@
+package mds
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/curvetool"
+)
+
+// zoneImbalancePercent is the percent of the capacity of the largest zone of a physical pool that the other zones
+// are expected to have at least, the space of the larger zones can't be all used by the copysets spread over zones
+const zoneImbalancePercent = 80
+
+// HostZone is the physical pool and the zone of a host in topology
+type HostZone struct {
+	PhysicalPool string
+	Zone         string
+}
+
+// ZoneCapacity is the capacity of the chunkservers of a zone and the copyset replicas on them
+type ZoneCapacity struct {
+	PhysicalPool string
+	Name         string
+	ChunkServers int
+	TotalBytes   int64
+	UsedBytes    int64
+	Replicas     int
+}
+
+// ListChunkServers lists the chunkservers in topology by curve_ops_tool in a running mds pod
+func ListChunkServers(c *clusterd.Context, namespace, mdsAddr string) ([]curvetool.ChunkServer, error) {
+	executor := curvetool.NewPodExecutor(c, namespace, fmt.Sprintf("app=%s", AppName))
+	return curvetool.New(executor, "-mdsAddr="+mdsAddr).ChunkServerList()
+}
+
+// ZoneDistribution sums the capacity and the copyset replicas of the chunkservers by the zones of their hosts,
+// sorted by physical pool and zone. The retired chunkservers and the ones on the hosts not in topology are skipped.
+func ZoneDistribution(chunkservers []curvetool.ChunkServer, hostZones map[string]HostZone) []ZoneCapacity {
+	zones := map[HostZone]*ZoneCapacity{}
+	for _, cs := range chunkservers {
+		hostZone, ok := hostZones[cs.HostIP]
+		if !ok || cs.RWStatus == curvetool.ChunkServerRetired {
+			continue
+		}
+		zone, ok := zones[hostZone]
+		if !ok {
+			zone = &ZoneCapacity{PhysicalPool: hostZone.PhysicalPool, Name: hostZone.Zone}
+			zones[hostZone] = zone
+		}
+		zone.ChunkServers++
+		zone.TotalBytes += cs.DiskCapacityBytes
+		zone.UsedBytes += cs.DiskUsedBytes
+		zone.Replicas += cs.CopysetNum
+	}
+
+	var list []ZoneCapacity
+	for _, zone := range zones {
+		list = append(list, *zone)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].PhysicalPool != list[j].PhysicalPool {
+			return list[i].PhysicalPool < list[j].PhysicalPool
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// ZoneLossWarnings returns the warnings of the physical pools that can't keep the replicas of their copysets if a
+// zone is lost, and of the zones much smaller than the others. replicas is the most replicas of the logical pools
+// of each physical pool. The replicas of a copyset are in different zones, so the copysets of a lost zone are
+// recovered only in the zones that have no replica of them, with the free space of the other zones.
+func ZoneLossWarnings(zones []ZoneCapacity, replicas map[string]int) []string {
+	pools := map[string][]ZoneCapacity{}
+	var names []string
+	for _, zone := range zones {
+		if _, ok := pools[zone.PhysicalPool]; !ok {
+			names = append(names, zone.PhysicalPool)
+		}
+		pools[zone.PhysicalPool] = append(pools[zone.PhysicalPool], zone)
+	}
+
+	var warnings []string
+	for _, name := range names {
+		poolZones := pools[name]
+		if r := replicas[name]; r > 0 && len(poolZones) <= r {
+			warnings = append(warnings, fmt.Sprintf("physical pool %s has %d zones for %d replicas, the copysets of a lost zone can't be recovered to %d replicas",
+				name, len(poolZones), r, r))
+		} else {
+			var free int64
+			for _, zone := range poolZones {
+				free += zone.TotalBytes - zone.UsedBytes
+			}
+			for _, zone := range poolZones {
+				if othersFree := free - (zone.TotalBytes - zone.UsedBytes); zone.UsedBytes > othersFree {
+					warnings = append(warnings, fmt.Sprintf("losing zone %s of physical pool %s leaves %d bytes free in the other zones for its %d bytes used",
+						zone.Name, name, othersFree, zone.UsedBytes))
+				}
+			}
+		}
+
+		largest := poolZones[0]
+		for _, zone := range poolZones {
+			if zone.TotalBytes > largest.TotalBytes {
+				largest = zone
+			}
+		}
+		for _, zone := range poolZones {
+			if zone.TotalBytes*100 < largest.TotalBytes*zoneImbalancePercent {
+				warnings = append(warnings, fmt.Sprintf("zone %s of physical pool %s has %d%% of the capacity of zone %s",
+					zone.Name, name, zone.TotalBytes*100/largest.TotalBytes, largest.Name))
+			}
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,58 @@
+package mds
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/opencurve/curve-operator/pkg/curvetool"
+)
+
+func TestZoneDistribution(t *testing.T) {
+	hostZones := map[string]HostZone{
+		"10.0.0.1": {PhysicalPool: "pool1", Zone: "zone1"},
+		"10.0.0.2": {PhysicalPool: "pool1", Zone: "zone2"},
+		"10.0.0.3": {PhysicalPool: "pool1", Zone: "zone3"},
+	}
+	chunkservers := []curvetool.ChunkServer{
+		{HostIP: "10.0.0.1", CopysetNum: 100, DiskCapacityBytes: 1000, DiskUsedBytes: 100},
+		{HostIP: "10.0.0.1", CopysetNum: 100, DiskCapacityBytes: 1000, DiskUsedBytes: 100},
+		{HostIP: "10.0.0.2", CopysetNum: 200, DiskCapacityBytes: 2000, DiskUsedBytes: 200},
+		{HostIP: "10.0.0.3", CopysetNum: 200, DiskCapacityBytes: 1000, DiskUsedBytes: 200},
+		{HostIP: "10.0.0.3", RWStatus: curvetool.ChunkServerRetired, DiskCapacityBytes: 1000},
+		{HostIP: "10.0.0.4", CopysetNum: 100, DiskCapacityBytes: 1000},
+	}
+	zones := ZoneDistribution(chunkservers, hostZones)
+	expected := []ZoneCapacity{
+		{PhysicalPool: "pool1", Name: "zone1", ChunkServers: 2, TotalBytes: 2000, UsedBytes: 200, Replicas: 200},
+		{PhysicalPool: "pool1", Name: "zone2", ChunkServers: 1, TotalBytes: 2000, UsedBytes: 200, Replicas: 200},
+		{PhysicalPool: "pool1", Name: "zone3", ChunkServers: 1, TotalBytes: 1000, UsedBytes: 200, Replicas: 200},
+	}
+	if !reflect.DeepEqual(zones, expected) {
+		t.Fatalf("expected zones %+v, got %+v", expected, zones)
+	}
+
+	// a lost zone of three can't be recovered for three replicas, and zone3 is half of the others
+	warnings := ZoneLossWarnings(zones, map[string]int{"pool1": 3})
+	if len(warnings) != 2 {
+		t.Fatalf("expected the warnings of the zones and the imbalance, got %v", warnings)
+	}
+
+	// the fourth zone keeps the replicas of a lost zone while the others have the free space for its used space
+	zones = []ZoneCapacity{
+		{PhysicalPool: "pool1", Name: "zone1", TotalBytes: 2000, UsedBytes: 1500},
+		{PhysicalPool: "pool1", Name: "zone2", TotalBytes: 2000, UsedBytes: 1500},
+		{PhysicalPool: "pool1", Name: "zone3", TotalBytes: 2000, UsedBytes: 1500},
+		{PhysicalPool: "pool1", Name: "zone4", TotalBytes: 2000, UsedBytes: 1500},
+	}
+	if warnings := ZoneLossWarnings(zones, map[string]int{"pool1": 3}); len(warnings) != 0 {
+		t.Errorf("expected no warning, got %v", warnings)
+	}
+	// the copysets of a lost zone don't fit in the others when all zones are 80% used
+	for i := range zones {
+		zones[i].UsedBytes = 1600
+	}
+	warnings = ZoneLossWarnings(zones, map[string]int{"pool1": 3})
+	if len(warnings) != 4 || warnings[0] != "losing zone zone1 of physical pool pool1 leaves 1200 bytes free in the other zones for its 1600 bytes used" {
+		t.Errorf("expected the warnings of all zones, got %v", warnings)
+	}
+}
@@ -0,0 +1,176 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/coreos/pkg/capnslog"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/chunkserver"
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/etcd"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+const (
+	// PrometheusRuleName is the name of the PrometheusRule of the cluster alerts
+	PrometheusRuleName = "curve-alerts"
+
+	defaultChunkServerDownMinutes = 5
+	defaultPoolUsagePercent       = 85
+)
+
+var prometheusRuleGVK = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "PrometheusRule"}
+
+var logger = capnslog.NewPackageLogger("github.com/opencurve/curve-operator", "monitoring")
+
+type Cluster struct {
+	context        clusterd.Context
+	namespacedName types.NamespacedName
+	spec           curvev1.CurveClusterSpec
+	ownerInfo      *k8sutil.OwnerInfo
+}
+
+func New(context clusterd.Context,
+	namespacedName types.NamespacedName,
+	spec curvev1.CurveClusterSpec,
+	ownerInfo *k8sutil.OwnerInfo) *Cluster {
+	return &Cluster{
+		context:        context,
+		namespacedName: namespacedName,
+		spec:           spec,
+		ownerInfo:      ownerInfo,
+	}
+}
+
+// ReconcileAlerts applies the PrometheusRule of the alerts if they are enabled, otherwise deletes it.
+// It's skipped if the prometheus operator is not installed.
+func (c *Cluster) ReconcileAlerts() error {
+	if !c.spec.Monitoring.Alerts.Enable {
+		rule := &unstructured.Unstructured{}
+		rule.SetGroupVersionKind(prometheusRuleGVK)
+		rule.SetNamespace(c.namespacedName.Namespace)
+		rule.SetName(PrometheusRuleName)
+		err := c.context.Client.Delete(context.TODO(), rule)
+		if err != nil && !kerrors.IsNotFound(err) && !meta.IsNoMatchError(err) {
+			return errors.Wrapf(err, "failed to delete PrometheusRule %q", PrometheusRuleName)
+		}
+		return nil
+	}
+
+	rule, err := c.makePrometheusRule()
+	if err != nil {
+		return err
+	}
+	err = k8sutil.Apply(c.context.Client, rule)
+	if meta.IsNoMatchError(errors.Cause(err)) {
+		logger.Warningf("PrometheusRule is not supported, the prometheus operator must be installed for the alerts")
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to apply PrometheusRule %q", PrometheusRuleName)
+	}
+	logger.Infof("PrometheusRule %q has been applied", PrometheusRuleName)
+
+	return nil
+}
+
+func (c *Cluster) makePrometheusRule() (*unstructured.Unstructured, error) {
+	alerts := c.spec.Monitoring.Alerts
+	downMinutes := alerts.ChunkServerDownMinutes
+	if downMinutes == 0 {
+		downMinutes = defaultChunkServerDownMinutes
+	}
+	usagePercent := alerts.PoolUsagePercent
+	if usagePercent == 0 {
+		usagePercent = defaultPoolUsagePercent
+	}
+
+	namespace := c.namespacedName.Namespace
+	cluster := fmt.Sprintf(`namespace="%s",cluster="%s"`, namespace, c.namespacedName.Name)
+
+	// the quorum is at risk if one more member down loses it
+	etcdMembers := c.spec.EtcdMembers()
+	etcdAtRisk := etcdMembers - (etcdMembers-1)/2
+	if etcdAtRisk >= etcdMembers {
+		etcdAtRisk = etcdMembers - 1
+	}
+	etcdAvailable := fmt.Sprintf(`sum(kube_deployment_status_replicas_available{namespace="%s",deployment=~"%s-.+"})`, namespace, etcd.AppName)
+	if c.spec.Etcd.StatefulSet != nil {
+		etcdAvailable = fmt.Sprintf(`sum(kube_statefulset_status_replicas_ready{namespace="%s",statefulset="%s"})`, namespace, etcd.AppName)
+	}
+
+	rules := []interface{}{
+		alertRule("CurveChunkServerDown",
+			fmt.Sprintf(`kube_deployment_status_replicas_available{namespace="%s",deployment=~"%s-.+"} == 0`, namespace, chunkserver.AppName),
+			fmt.Sprintf("%dm", downMinutes), "critical",
+			"Chunkserver {{ $labels.deployment }} is down",
+			fmt.Sprintf("Chunkserver {{ $labels.deployment }} of curve cluster %s has been unavailable for more than %d minutes.", c.namespacedName, downMinutes)),
+		alertRule("CurveEtcdQuorumAtRisk",
+			fmt.Sprintf(`%s <= %d`, etcdAvailable, etcdAtRisk),
+			"1m", "critical",
+			"Etcd quorum is at risk",
+			fmt.Sprintf("Only {{ $value }} of %d etcd members of curve cluster %s are available.", etcdMembers, c.namespacedName)),
+		alertRule("CurveCopysetsUnhealthy",
+			fmt.Sprintf(`curve_copysets_unhealthy{%s} > 0`, cluster),
+			"5m", "warning",
+			"Copysets are unhealthy",
+			fmt.Sprintf("{{ $value }} copysets of curve cluster %s are unhealthy.", c.namespacedName)),
+		alertRule("CurvePoolNearlyFull",
+			fmt.Sprintf(`curve_pool_capacity_used_bytes{%s} / curve_pool_capacity_total_bytes{%s} * 100 > %d`, cluster, cluster, usagePercent),
+			"5m", "warning",
+			"Logical pool {{ $labels.pool }} is nearly full",
+			fmt.Sprintf("Logical pool {{ $labels.pool }} of curve cluster %s is {{ $value | humanize }}%% used.", c.namespacedName)),
+		alertRule("CurveZoneLossAtRisk",
+			fmt.Sprintf(`curve_zone_warnings{%s} > 0`, cluster),
+			"30m", "warning",
+			"Zones are not balanced for a zone loss",
+			fmt.Sprintf("{{ $value }} zone warnings of curve cluster %s, see status.capacity.zoneWarnings.", c.namespacedName)),
+	}
+
+	rule := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"groups": []interface{}{
+					map[string]interface{}{
+						"name":  "curve.rules",
+						"rules": rules,
+					},
+				},
+			},
+		},
+	}
+	rule.SetGroupVersionKind(prometheusRuleGVK)
+	rule.SetNamespace(namespace)
+	rule.SetName(PrometheusRuleName)
+	rule.SetLabels(alerts.Labels)
+
+	err := c.ownerInfo.SetControllerReference(rule)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to set owner reference to PrometheusRule %q", PrometheusRuleName)
+	}
+
+	return rule, nil
+}
+
+func alertRule(name, expr, duration, severity, summary, description string) map[string]interface{} {
+	return map[string]interface{}{
+		"alert": name,
+		"expr":  expr,
+		"for":   duration,
+		"labels": map[string]interface{}{
+			"severity": severity,
+		},
+		"annotations": map[string]interface{}{
+			"summary":     summary,
+			"description": description,
+		},
+	}
+}
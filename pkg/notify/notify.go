@@ -0,0 +1,118 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coreos/pkg/capnslog"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+const webhookTimeout = 10 * time.Second
+
+var logger = capnslog.NewPackageLogger("github.com/opencurve/curve-operator", "notify")
+
+// client posts the events to the webhooks
+var client = &http.Client{Timeout: webhookTimeout}
+
+// Event is a major event of the cluster posted to the webhooks in the json format
+type Event struct {
+	Event     curvev1.NotificationEvent `json:"event"`
+	Cluster   string                    `json:"cluster"`
+	Namespace string                    `json:"namespace"`
+	Message   string                    `json:"message"`
+	Time      time.Time                 `json:"time"`
+}
+
+// slackPayload is the payload of the incoming webhooks of Slack
+type slackPayload struct {
+	Text string `json:"text"`
+}
+
+// Notify posts the event of the cluster to the webhooks of spec.notifications that subscribe it in the background,
+// the failures are logged only and don't fail the reconcile
+func Notify(clientset kubernetes.Interface, cluster types.NamespacedName, spec *curvev1.CurveClusterSpec,
+	event curvev1.NotificationEvent, message string) {
+	if spec == nil || len(spec.Notifications.Webhooks) == 0 {
+		return
+	}
+	e := Event{
+		Event:     event,
+		Cluster:   cluster.Name,
+		Namespace: cluster.Namespace,
+		Message:   message,
+		Time:      time.Now(),
+	}
+	webhooks := spec.Notifications.DeepCopy().Webhooks
+	go func() {
+		for i := range webhooks {
+			if !webhooks[i].Subscribed(event) {
+				continue
+			}
+			if err := send(clientset, &webhooks[i], e); err != nil {
+				logger.Errorf("failed to notify %s of cluster %q. %v", event, cluster.String(), err)
+			}
+		}
+	}()
+}
+
+// send posts the event to the webhook in its format
+func send(clientset kubernetes.Interface, webhook *curvev1.NotificationWebhookSpec, e Event) error {
+	url, err := webhookURL(clientset, e.Namespace, webhook)
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+	switch webhook.Format {
+	case curvev1.NotificationFormatSlack:
+		body, err = json.Marshal(slackPayload{Text: fmt.Sprintf("[%s/%s] %s: %s", e.Namespace, e.Cluster, e.Event, e.Message)})
+	case curvev1.NotificationFormatJSON, "":
+		body, err = json.Marshal(e)
+	default:
+		return errors.Errorf("unknown format %q of webhook", webhook.Format)
+	}
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// the url may be a credential, it's not logged
+		return errors.Wrap(err, "failed to post the webhook")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("failed to post the webhook, status %s", resp.Status)
+	}
+	return nil
+}
+
+// webhookURL returns the url of the webhook, which is read from the secret if urlSecret is set
+func webhookURL(clientset kubernetes.Interface, namespace string, webhook *curvev1.NotificationWebhookSpec) (string, error) {
+	selector := webhook.URLSecret
+	if selector == nil {
+		if webhook.URL == "" {
+			return "", errors.New("neither url nor urlSecret of webhook is set")
+		}
+		return webhook.URL, nil
+	}
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(selector.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get secret %q of the webhook url", selector.Name)
+	}
+	url, ok := secret.Data[selector.Key]
+	if !ok {
+		return "", errors.Errorf("key %q is not found in secret %q", selector.Key, selector.Name)
+	}
+	return strings.TrimSpace(string(url)), nil
+}
@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+func TestNotify(t *testing.T) {
+	posted := make(chan map[string]interface{}, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		payload := map[string]interface{}{}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("unexpected payload %q", body)
+		}
+		payload["path"] = r.URL.Path
+		posted <- payload
+	}))
+	defer server.Close()
+
+	clientset := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "curvebs", Name: "slack"},
+		Data:       map[string][]byte{"url": []byte(server.URL + "/slack\n")},
+	})
+	spec := &curvev1.CurveClusterSpec{Notifications: curvev1.NotificationsSpec{Webhooks: []curvev1.NotificationWebhookSpec{
+		{URL: server.URL + "/json"},
+		{
+			URLSecret: &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "slack"}, Key: "url"},
+			Format:    curvev1.NotificationFormatSlack,
+			Events:    []curvev1.NotificationEvent{curvev1.NotificationEventChunkServerOffline},
+		},
+	}}}
+	cluster := types.NamespacedName{Namespace: "curvebs", Name: "my-cluster"}
+
+	receive := func() map[string]interface{} {
+		select {
+		case payload := <-posted:
+			return payload
+		case <-time.After(5 * time.Second):
+			t.Fatal("no notification is posted")
+			return nil
+		}
+	}
+
+	Notify(clientset, cluster, spec, curvev1.NotificationEventClusterReady, "cluster is ready")
+	payload := receive()
+	if payload["path"] != "/json" || payload["event"] != "ClusterReady" || payload["cluster"] != "my-cluster" ||
+		payload["namespace"] != "curvebs" || payload["message"] != "cluster is ready" {
+		t.Errorf("unexpected payload %v", payload)
+	}
+
+	// the slack webhook subscribes ChunkServerOffline only
+	Notify(clientset, cluster, spec, curvev1.NotificationEventChunkServerOffline, "node-1 is NotReady")
+	for i := 0; i < 2; i++ {
+		payload := receive()
+		switch payload["path"] {
+		case "/json":
+			if payload["event"] != "ChunkServerOffline" {
+				t.Errorf("unexpected payload %v", payload)
+			}
+		case "/slack":
+			if payload["text"] != "[curvebs/my-cluster] ChunkServerOffline: node-1 is NotReady" {
+				t.Errorf("unexpected payload %v", payload)
+			}
+		default:
+			t.Errorf("unexpected payload %v", payload)
+		}
+	}
+	select {
+	case payload := <-posted:
+		t.Errorf("unexpected notification %v", payload)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
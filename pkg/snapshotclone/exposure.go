@@ -0,0 +1,24 @@
+package snapshotclone
+
+import (
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// APIServiceName is the name of the service, ingress and route of the http api of snapshotclone
+const APIServiceName = "curve-snapshotclone-api"
+
+// reconcileExposure exposes the nginx proxy of the snapshotclones, which forwards the requests of the http api
+// to the leader, if spec.snapShotClone.exposure is set. Otherwise the service, ingress and route are deleted.
+func (c *Cluster) reconcileExposure() error {
+	exposure := c.spec.SnapShotClone.Exposure
+	if exposure == nil {
+		return k8sutil.DeleteExposure(&c.context, APIServiceName, c.namespacedName.Namespace)
+	}
+	return k8sutil.ApplyExposure(&c.context, c.spec, c.ownerInfo, k8sutil.ExposedService{
+		Name:      APIServiceName,
+		Namespace: c.namespacedName.Namespace,
+		Daemon:    "snapshotclone",
+		Selector:  map[string]string{"app": AppName, "curve_cluster": c.namespacedName.Namespace},
+		Port:      c.spec.SnapShotClone.ProxyPort,
+	}, exposure)
+}
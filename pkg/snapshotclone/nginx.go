@@ -7,6 +7,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
 )
 
 func (c *Cluster) createNginxConfigMap(snapConfig *snapConfig) error {
@@ -39,6 +40,7 @@ func (c *Cluster) createNginxConfigMap(snapConfig *snapConfig) error {
 		Data: nginxConfigMap,
 	}
 
+	k8sutil.InjectMetadata(c.spec, "snapshotclone", cm)
 	err = c.ownerInfo.SetControllerReference(cm)
 	if err != nil {
 		return errors.Wrapf(err, "failed to set owner reference to nginx.conf configmap %q", config.NginxConfigMapName)
@@ -48,8 +50,8 @@ func (c *Cluster) createNginxConfigMap(snapConfig *snapConfig) error {
 	// log.Infof("namespace=%v", c.namespacedName.Namespace)
 
 	// create nginx configmap in cluster
-	_, err = c.context.Clientset.CoreV1().ConfigMaps(c.namespacedName.Namespace).Create(cm)
-	if err != nil && !kerrors.IsAlreadyExists(err) {
+	err = k8sutil.Apply(c.context.Client, cm)
+	if err != nil {
 		return errors.Wrapf(err, "failed to create nginx configmap %s", c.namespacedName.Namespace)
 	}
 
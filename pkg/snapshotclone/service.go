@@ -0,0 +1,29 @@
+package snapshotclone
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// createService creates the headless service of the snapshotclone to give it a stable DNS name
+func (c *Cluster) createService(snapConfig *snapConfig) error {
+	svc := k8sutil.MakeHeadlessService(snapConfig.ResourceName, c.namespacedName.Namespace, c.getPodLabels(snapConfig),
+		map[string]int{
+			"snapshotclone": c.spec.SnapShotClone.Port,
+			"dummy":         c.spec.SnapShotClone.DummyPort,
+			"proxy":         c.spec.SnapShotClone.ProxyPort,
+		})
+
+	k8sutil.InjectMetadata(c.spec, "snapshotclone", svc)
+	err := c.ownerInfo.SetControllerReference(svc)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set owner reference to snapshotclone service %q", snapConfig.ResourceName)
+	}
+
+	err = k8sutil.Apply(c.context.Client, svc)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create snapshotclone service %q", snapConfig.ResourceName)
+	}
+	return nil
+}
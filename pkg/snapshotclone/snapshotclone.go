@@ -5,13 +5,13 @@ import (
 	"fmt"
 	"path"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/coreos/pkg/capnslog"
 	"github.com/pkg/errors"
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
-	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 
@@ -65,19 +65,13 @@ func New(context clusterd.Context,
 func (c *Cluster) Start(nodeNameIP map[string]string) error {
 	logger.Info("starting snapshotclone server")
 
-	// get clusterEtcdAddr
-	etcdOverrideCM, err := c.context.Clientset.CoreV1().ConfigMaps(c.namespacedName.Namespace).Get(config.EtcdOverrideConfigMapName, metav1.GetOptions{})
+	// get clusterEtcdAddr and clusterMdsAddr
+	clusterInfo, err := config.GetClusterInfo(&c.context, c.namespacedName.Namespace)
 	if err != nil {
-		return errors.Wrapf(err, "failed to get %s configmap from cluster", config.EtcdOverrideConfigMapName)
-	}
-	clusterEtcdAddr := etcdOverrideCM.Data[config.ClusterEtcdAddr]
-
-	// get clusterMdsAddr
-	mdsOverrideCM, err := c.context.Clientset.CoreV1().ConfigMaps(c.namespacedName.Namespace).Get(config.MdsOverrideConfigMapName, metav1.GetOptions{})
-	if err != nil {
-		return errors.Wrap(err, "failed to get mds override endoints configmap")
+		return err
 	}
-	clusterMdsAddr := mdsOverrideCM.Data[config.MdsOvverideConfigMapDataKey]
+	clusterEtcdAddr := clusterInfo.EtcdAddr
+	clusterMdsAddr := clusterInfo.MdsAddr
 
 	err = c.createStartSnapConfigMap()
 	if err != nil {
@@ -85,22 +79,15 @@ func (c *Cluster) Start(nodeNameIP map[string]string) error {
 	}
 
 	// reorder the nodeNameIP according to the order of nodes spec defined by the user
-	// nodes:
+	// snapShotClone.nodes(or nodes if not set):
 	// - node1 - curve-snap-a
 	// - node2  - curve-snap-b
 	// - node3 - curve-snap-c
-	nodeNamesOrdered := make([]string, 0)
-	for _, n := range c.spec.Nodes {
-		for nodeName := range nodeNameIP {
-			if n == nodeName {
-				nodeNamesOrdered = append(nodeNamesOrdered, nodeName)
-			}
-		}
-	}
+	nodeNamesOrdered := ServerNodes(c.spec, nodeNameIP)
 
-	if len(nodeNamesOrdered) != 3 {
-		logger.Errorf("Nodes spec field is not 3, current nodes number is %d", len(nodeNamesOrdered))
-		return errors.New("Nodes spec field is not 3")
+	if replicas := c.spec.SnapShotCloneReplicas(); len(nodeNamesOrdered) != replicas {
+		logger.Errorf("snapshotclone nodes count is less than replicas %d, current nodes number is %d", replicas, len(nodeNamesOrdered))
+		return errors.Errorf("snapshotclone nodes count %d is less than replicas %d", len(nodeNamesOrdered), replicas)
 	}
 
 	daemonID := 0
@@ -140,25 +127,23 @@ func (c *Cluster) Start(nodeNameIP map[string]string) error {
 			return errors.Wrap(err, "failed to prepare all ConfigMaps of snapshotclone")
 		}
 
+		err = c.createService(snapConfig)
+		if err != nil {
+			return err
+		}
+
 		// make snapshotclone deployment
 		d, err := c.makeDeployment(nodeName, nodeNameIP[nodeName], snapConfig)
 		if err != nil {
 			return errors.Wrapf(err, "failed to create snapshotclone Deployment %q object", snapConfig.ResourceName)
 		}
 
-		newDeployment, err := c.context.Clientset.AppsV1().Deployments(c.namespacedName.Namespace).Create(d)
+		// the deployment is only updated when the rendered spec or config changed to avoid restarting pods
+		newDeployment, err := k8sutil.CreateOrUpdateDeployment(&c.context, d)
 		if err != nil {
-			if !kerrors.IsAlreadyExists(err) {
-				return errors.Wrapf(err, "failed to create snapshotclone deployment %q in cluster", snapConfig.ResourceName)
-			}
-			logger.Infof("deployment %v for snapshotclone already exists. updating if needed", snapConfig.ResourceName)
-
-			// TODO:Update the daemon Deployment
-			// if err := updateDeploymentAndWait(c.context, c.clusterInfo, d, config.MgrType, mgrConfig.DaemonID, c.spec.SkipUpgradeChecks, false); err != nil {
-			// 	logger.Errorf("failed to update mgr deployment %q. %v", resourceName, err)
-			// }
-		} else {
-			logger.Infof("Deployment %q has been created, waiting for startup", newDeployment.GetName())
+			return errors.Wrapf(err, "failed to create snapshotclone deployment %s", snapConfig.ResourceName)
+		}
+		if newDeployment != nil {
 			deploymentsToWaitFor = append(deploymentsToWaitFor, newDeployment)
 		}
 		// update condition type and phase etc.
@@ -169,7 +154,14 @@ func (c *Cluster) Start(nodeNameIP map[string]string) error {
 		deploymentsToWaitFor); err != nil {
 		return err
 	}
-	k8sutil.UpdateCondition(context.TODO(), &c.context, c.namespacedName, curvev1.ConditionTypeSnapShotCloneReady, curvev1.ConditionTrue, curvev1.ConditionSnapShotCloneClusterCreatedReason, "Snapshotclone cluster has been created")
+
+	if err := c.recordEndpoints(nodeNameIP); err != nil {
+		return err
+	}
+	if err := c.reconcileExposure(); err != nil {
+		return errors.Wrap(err, "failed to expose the http api of snapshotclone")
+	}
+	k8sutil.SetReady(context.TODO(), &c.context, c.namespacedName, curvev1.ConditionTypeSnapShotCloneReady, curvev1.ConditionSnapShotCloneClusterCreatedReason, "Snapshotclone cluster has been created")
 
 	return nil
 }
@@ -187,14 +179,71 @@ func (c *Cluster) createStartSnapConfigMap() error {
 		Data: startSnapShotConfigMap,
 	}
 
+	k8sutil.InjectMetadata(c.spec, "snapshotclone", cm)
 	err := c.ownerInfo.SetControllerReference(cm)
 	if err != nil {
 		return errors.Wrapf(err, "failed to set owner reference to start_snapshot.sh configmap %q", config.StartSnapConfigMap)
 	}
 	// create nginx configmap in cluster
-	_, err = c.context.Clientset.CoreV1().ConfigMaps(c.namespacedName.Namespace).Create(cm)
-	if err != nil && !kerrors.IsAlreadyExists(err) {
+	err = k8sutil.Apply(c.context.Client, cm)
+	if err != nil {
 		return errors.Wrapf(err, "failed to create start snapshotclone configmap %s", c.namespacedName.Namespace)
 	}
 	return nil
 }
+
+// ServerNodes returns the nodes of the snapshotclone servers in order, they are the first replicas of the nodes
+// of snapshotclone that have ips
+func ServerNodes(spec curvev1.CurveClusterSpec, nodeNameIP map[string]string) []string {
+	nodes := make([]string, 0)
+	for _, nodeName := range spec.SnapShotCloneNodes() {
+		if len(nodes) == spec.SnapShotCloneReplicas() {
+			break
+		}
+		if _, ok := nodeNameIP[nodeName]; ok {
+			nodes = append(nodes, nodeName)
+		}
+	}
+	return nodes
+}
+
+// WantedResource returns true if the deployment or the configmap of the name belongs to a snapshotclone server
+// of spec, the ones of the servers beyond replicas are not wanted
+func WantedResource(spec *curvev1.CurveClusterSpec, name string) bool {
+	if !spec.SnapShotClone.Enable {
+		return false
+	}
+	for i := 0; i < spec.SnapShotCloneReplicas(); i++ {
+		daemonID := k8sutil.IndexToName(i)
+		if name == fmt.Sprintf("%s-%s", AppName, daemonID) || name == fmt.Sprintf("%s-%s", ConfigMapNamePrefix, daemonID) {
+			return true
+		}
+	}
+	return false
+}
+
+// Endpoints returns the addresses of snapshotclone on the nodes, they are DNS names of the services if
+// network.useServiceDNS is set
+func Endpoints(spec curvev1.CurveClusterSpec, namespace string, nodeNameIP map[string]string) string {
+	addrs := make([]string, 0)
+	for _, nodeName := range ServerNodes(spec, nodeNameIP) {
+		addrs = append(addrs, fmt.Sprintf("%s:%d", nodeNameIP[nodeName], spec.SnapShotClone.Port))
+	}
+	if spec.Network.UseServiceDNS {
+		return k8sutil.DaemonServiceAddrs(AppName, namespace, len(addrs), spec.SnapShotClone.Port)
+	}
+	return strings.Join(addrs, ",")
+}
+
+// recordEndpoints records the endpoints of snapshotclone in cluster info
+func (c *Cluster) recordEndpoints(nodeNameIP map[string]string) error {
+	snapAddr := Endpoints(c.spec, c.namespacedName.Namespace, nodeNameIP)
+
+	err := config.UpdateClusterInfo(&c.context, c.namespacedName.Namespace, c.ownerInfo, c.spec, func(info *config.ClusterInfo) {
+		info.SnapShotCloneAddr = snapAddr
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to record snapshotclone endpoints")
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package snapshotclone
+
+import (
+	"reflect"
+	"testing"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+func TestServerNodes(t *testing.T) {
+	spec := curvev1.CurveClusterSpec{
+		Nodes:         []string{"node1", "node2", "node3", "node4"},
+		SnapShotClone: curvev1.SnapShotCloneSpec{Enable: true, Port: 5555},
+	}
+	nodeNameIP := map[string]string{"node1": "10.0.0.1", "node2": "10.0.0.2", "node3": "10.0.0.3", "node4": "10.0.0.4"}
+
+	// the servers run on the first 3 nodes by default
+	if nodes := ServerNodes(spec, nodeNameIP); !reflect.DeepEqual(nodes, []string{"node1", "node2", "node3"}) {
+		t.Errorf("expected the first 3 nodes, got %v", nodes)
+	}
+
+	// the nodes without ip are skipped
+	spec.SnapShotClone.Replicas = 2
+	delete(nodeNameIP, "node1")
+	if nodes := ServerNodes(spec, nodeNameIP); !reflect.DeepEqual(nodes, []string{"node2", "node3"}) {
+		t.Errorf("expected the first 2 nodes with ip, got %v", nodes)
+	}
+	if addrs := Endpoints(spec, "curve", nodeNameIP); addrs != "10.0.0.2:5555,10.0.0.3:5555" {
+		t.Errorf("expected the endpoints of the servers, got %s", addrs)
+	}
+
+	// the resources of the servers beyond replicas are not wanted
+	for name, wanted := range map[string]bool{
+		AppName + "-a":             true,
+		AppName + "-b":             true,
+		AppName + "-c":             false,
+		ConfigMapNamePrefix + "-b": true,
+		ConfigMapNamePrefix + "-c": false,
+	} {
+		if WantedResource(&spec, name) != wanted {
+			t.Errorf("expected %s wanted %t", name, wanted)
+		}
+	}
+}
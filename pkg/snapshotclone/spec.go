@@ -11,6 +11,8 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/daemon"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
 )
 
 // prepareConfigMap
@@ -81,14 +83,15 @@ func (c *Cluster) createSnapClientConfigMap(snapConfig *snapConfig) error {
 		Data: snapClientConfigMap,
 	}
 
+	k8sutil.InjectMetadata(c.spec, "snapshotclone", cm)
 	err = c.ownerInfo.SetControllerReference(cm)
 	if err != nil {
 		return errors.Wrapf(err, "failed to set owner reference to snap_client.conf configmap %q", config.SnapClientConfigMapName)
 	}
 
 	// Create cs_client configmap in cluster
-	_, err = c.context.Clientset.CoreV1().ConfigMaps(c.namespacedName.Namespace).Create(cm)
-	if err != nil && !kerrors.IsAlreadyExists(err) {
+	err = k8sutil.Apply(c.context.Client, cm)
+	if err != nil {
 		return errors.Wrapf(err, "failed to create snap_client configmap %s", c.namespacedName.Namespace)
 	}
 
@@ -127,15 +130,16 @@ func (c *Cluster) createSnapShotCloneConfigMap(snapConfig *snapConfig) error {
 		Data: snapCloneConfigMap,
 	}
 
+	k8sutil.InjectMetadata(c.spec, "snapshotclone", cm)
 	err = c.ownerInfo.SetControllerReference(cm)
 	if err != nil {
 		return errors.Wrapf(err, "failed to set owner reference to snapshotclone.conf configmap %q", config.SnapShotCloneConfigMapName)
 	}
 
-	// Create cs_client configmap in cluster
-	_, err = c.context.Clientset.CoreV1().ConfigMaps(c.namespacedName.Namespace).Create(cm)
-	if err != nil && !kerrors.IsAlreadyExists(err) {
-		return errors.Wrapf(err, "failed to create snap_client configmap %s", c.namespacedName.Namespace)
+	// Create snapshotclone configmap in cluster
+	err = k8sutil.Apply(c.context.Client, cm)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create snapshotclone configmap %s", c.namespacedName.Namespace)
 	}
 
 	return nil
@@ -157,20 +161,29 @@ func (c *Cluster) makeDeployment(nodeName string, nodeIP string, snapConfig *sna
 			Labels: c.getPodLabels(snapConfig),
 		},
 		Spec: v1.PodSpec{
-			Containers: []v1.Container{
-				c.makeSnapshotDaemonContainer(nodeIP, snapConfig),
+			InitContainers: []v1.Container{
+				daemon.WaitForEndpointsInitContainer("wait-etcd", snapConfig.ClusterEtcdAddr, c.spec.CurveVersion.Image, c.spec.CurveVersion.ImagePullPolicy),
+				daemon.WaitForEndpointsInitContainer("wait-mds", snapConfig.ClusterMdsAddr, c.spec.CurveVersion.Image, c.spec.CurveVersion.ImagePullPolicy),
 			},
-			NodeName:      nodeName,
-			RestartPolicy: v1.RestartPolicyAlways,
-			HostNetwork:   true,
-			DNSPolicy:     v1.DNSClusterFirstWithHostNet,
-			Volumes:       volumes,
+			Containers: append([]v1.Container{
+				c.makeSnapshotDaemonContainer(nodeIP, snapConfig),
+			}, daemon.LogRotateContainers(c.spec.Logging, snapConfig.DataPathMap.ContainerLogDir, c.spec.CurveVersion.Image, c.spec.CurveVersion.ImagePullPolicy)...),
+			NodeName:          nodeName,
+			RestartPolicy:     v1.RestartPolicyAlways,
+			HostNetwork:       true,
+			DNSPolicy:         v1.DNSClusterFirstWithHostNet,
+			Volumes:           volumes,
+			PriorityClassName: c.spec.PriorityClassName("snapshotclone"),
 			SecurityContext: &v1.PodSecurityContext{
 				RunAsUser:    &runAsUser,
 				RunAsNonRoot: &runAsNonRoot,
 			},
 		},
 	}
+	k8sutil.InjectEnv(&podSpec.Spec, c.spec, "snapshotclone")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, c.spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, c.spec)
+	k8sutil.InjectDNS(&podSpec.Spec, c.spec, "snapshotclone")
 
 	replicas := int32(1)
 
@@ -193,6 +206,7 @@ func (c *Cluster) makeDeployment(nodeName string, nodeIP string, snapConfig *sna
 	}
 
 	// set ownerReference
+	k8sutil.InjectMetadata(c.spec, "snapshotclone", d, &d.Spec.Template)
 	err := c.ownerInfo.SetControllerReference(d)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to set owner reference to chunkserver deployment %q", d.Name)
@@ -251,7 +265,7 @@ func (c *Cluster) makeSnapshotDaemonContainer(nodeIP string, snapConfig *snapCon
 				Protocol:      v1.ProtocolTCP,
 			},
 		},
-		Env: []v1.EnvVar{{Name: "TZ", Value: "Asia/Hangzhou"}},
+		Env: append(append([]v1.EnvVar{{Name: "TZ", Value: "Asia/Hangzhou"}}, daemon.LoggingEnv(c.spec.Logging)...), daemon.LogLevelEnv(c.spec.SnapShotClone.LogLevel)...),
 	}
 
 	return container
@@ -0,0 +1,48 @@
+package snapshotclone
+
+import (
+	"strings"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+// MissingS3ConfigError is returned if snapshotclone is enabled without the s3 settings it stores the snapshots
+// with, the snapshotcloneserver keeps crashing without them.
+type MissingS3ConfigError struct {
+	Fields []string
+}
+
+func (e *MissingS3ConfigError) Error() string {
+	return "snapshotclone is enabled but " + strings.Join(e.Fields, ", ") + " not set"
+}
+
+// ClusterErrors returns a ConfigError, the s3 settings must be set in spec
+func (e *MissingS3ConfigError) ClusterErrors() []curvev1.ClusterError {
+	return []curvev1.ClusterError{{Category: curvev1.ErrorCategoryConfigError, Message: e.Error()}}
+}
+
+// ValidateS3Config checks the s3 settings required by snapshotclone are all set if it's enabled
+func ValidateS3Config(spec *curvev1.CurveClusterSpec) error {
+	if !spec.SnapShotClone.Enable {
+		return nil
+	}
+	s3 := spec.SnapShotClone.S3Config
+	var missing []string
+	for _, field := range []struct {
+		name  string
+		value string
+	}{
+		{"ak", s3.AK},
+		{"sk", s3.SK},
+		{"nosAddress", s3.NosAddress},
+		{"bucketName", s3.SnapShotBucketName},
+	} {
+		if strings.TrimSpace(field.value) == "" {
+			missing = append(missing, "spec.snapShotClone.s3Config."+field.name)
+		}
+	}
+	if len(missing) > 0 {
+		return &MissingS3ConfigError{Fields: missing}
+	}
+	return nil
+}
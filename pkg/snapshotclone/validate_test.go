@@ -0,0 +1,32 @@
+package snapshotclone
+
+import (
+	"reflect"
+	"testing"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+func TestValidateS3Config(t *testing.T) {
+	spec := &curvev1.CurveClusterSpec{}
+	if err := ValidateS3Config(spec); err != nil {
+		t.Errorf("expected no error if snapshotclone is disabled, got %v", err)
+	}
+
+	spec.SnapShotClone.Enable = true
+	spec.SnapShotClone.S3Config = curvev1.S3ConfigSpec{AK: "ak", NosAddress: "http://127.0.0.1:9000"}
+	err, ok := ValidateS3Config(spec).(*MissingS3ConfigError)
+	if !ok {
+		t.Fatalf("expected MissingS3ConfigError, got %v", err)
+	}
+	expected := []string{"spec.snapShotClone.s3Config.sk", "spec.snapShotClone.s3Config.bucketName"}
+	if !reflect.DeepEqual(err.Fields, expected) {
+		t.Errorf("expected missing fields %v, got %v", expected, err.Fields)
+	}
+
+	spec.SnapShotClone.S3Config.SK = "sk"
+	spec.SnapShotClone.S3Config.SnapShotBucketName = "curve"
+	if err := ValidateS3Config(spec); err != nil {
+		t.Errorf("expected no error with all s3 settings, got %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// AdoptDiscoveryAppName is the app label of the job that lists the chunkservers of the cluster to adopt
+const AdoptDiscoveryAppName = "curve-adopt-discovery"
+
+// AdoptDiscoveryJobName returns the name of the job that lists the chunkservers of the mds of spec.adoption, a new
+// job is run when they change
+func (c *Cluster) AdoptDiscoveryJobName() string {
+	return AdoptDiscoveryAppName + "-" + k8sutil.Hash(strings.Join(c.spec.Adoption.MdsEndpoints, ","))[:10]
+}
+
+// MakeAdoptDiscoveryJob makes the job that prints the chunkservers in the topology of the existing cluster of
+// spec.adoption by curve_ops_tool, tools.conf is not created before the cluster is adopted so the mds are given
+// by the flag
+func (c *Cluster) MakeAdoptDiscoveryJob() (*batch.Job, error) {
+	labels := map[string]string{
+		"app":           AdoptDiscoveryAppName,
+		"curve_cluster": c.namespacedName.Namespace,
+	}
+	backoffLimit := int32(0)
+
+	podSpec := v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   AdoptDiscoveryAppName,
+			Labels: labels,
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:            "discovery",
+					Command:         []string{toolsBinDir + "/curve_ops_tool"},
+					Args:            []string{"chunkserver-list", "-mdsAddr=" + strings.Join(c.spec.Adoption.MdsEndpoints, ",")},
+					WorkingDir:      toolsBinDir,
+					Image:           c.spec.CurveVersion.Image,
+					ImagePullPolicy: c.spec.CurveVersion.ImagePullPolicy,
+				},
+			},
+			RestartPolicy: v1.RestartPolicyNever,
+			HostNetwork:   true,
+			DNSPolicy:     v1.DNSClusterFirstWithHostNet,
+		},
+	}
+	k8sutil.InjectEnv(&podSpec.Spec, c.spec, "")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, c.spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, c.spec)
+	k8sutil.InjectDNS(&podSpec.Spec, c.spec, "")
+
+	job := &batch.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      c.AdoptDiscoveryJobName(),
+			Namespace: c.namespacedName.Namespace,
+			Labels:    labels,
+		},
+		Spec: batch.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template:     podSpec,
+		},
+	}
+
+	k8sutil.InjectMetadata(c.spec, "", job, &job.Spec.Template)
+	err := c.ownerInfo.SetControllerReference(job)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to set owner reference to adopt discovery job %q", job.Name)
+	}
+
+	return job, nil
+}
@@ -0,0 +1,165 @@
+package tools
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	batch "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+const (
+	// ScrubAppName is the app label and the name of the scrub cron job
+	ScrubAppName = "curve-scrub"
+
+	defaultScrubSchedule      = "0 2 * * *"
+	defaultScrubWindowMinutes = 240
+	defaultScrubLogicalPoolID = 1
+	// scrubDeadlineMarginSeconds is the time more than the window for the job to turn off the scan
+	scrubDeadlineMarginSeconds = 300
+)
+
+// scrubScript turns on the scan of the logical pools $1 for $2 minutes, the scan is turned off when the window
+// ends or the job is terminated by its deadline
+const scrubScript = `
+pools="$1"
+failed=0
+set_scan() {
+  for pool in $pools; do
+    if ! curve_ops_tool set-scan-state -lpid=$pool -scan=$1; then
+      echo "failed to set scan state of logical pool $pool to $1"
+      failed=1
+    fi
+  done
+}
+stop() {
+  set_scan false
+  exit $failed
+}
+trap stop TERM INT
+
+set_scan true
+echo "scan of logical pools $pools is turned on for $2 minutes"
+sleep $(($2 * 60)) &
+wait $!
+stop
+`
+
+// ReconcileScrub creates the cron job that scrubs the copysets in the windows of maintenance.scrub if it's
+// enabled, or deletes it
+func (c *Cluster) ReconcileScrub() error {
+	if !c.spec.Maintenance.Scrub.Enable {
+		propagation := metav1.DeletePropagationBackground
+		err := c.context.Clientset.BatchV1beta1().CronJobs(c.namespacedName.Namespace).Delete(ScrubAppName, &metav1.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil && !kerrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete scrub cron job %s", ScrubAppName)
+		}
+		return nil
+	}
+
+	cronJob, err := c.makeScrubCronJob()
+	if err != nil {
+		return err
+	}
+	if err := k8sutil.Apply(c.context.Client, cronJob); err != nil {
+		return errors.Wrapf(err, "failed to apply scrub cron job %s", cronJob.Name)
+	}
+	return nil
+}
+
+func (c *Cluster) makeScrubCronJob() (*batchv1beta1.CronJob, error) {
+	scrub := c.spec.Maintenance.Scrub
+	labels := map[string]string{
+		"app":           ScrubAppName,
+		"curve_cluster": c.namespacedName.Namespace,
+	}
+
+	schedule := scrub.Schedule
+	if schedule == "" {
+		schedule = defaultScrubSchedule
+	}
+	window := scrub.WindowMinutes
+	if window == 0 {
+		window = defaultScrubWindowMinutes
+	}
+	var pools []string
+	for _, id := range scrub.LogicalPoolIDs {
+		pools = append(pools, strconv.Itoa(id))
+	}
+	if len(pools) == 0 {
+		pools = []string{strconv.Itoa(defaultScrubLogicalPoolID)}
+	}
+
+	volumes, mounts := c.toolsVolumesAndMounts()
+	backoffLimit := int32(0)
+	historyLimit := int32(1)
+	deadline := int64(window*60 + scrubDeadlineMarginSeconds)
+
+	podSpec := v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: labels,
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:            "scrub",
+					Command:         []string{"/bin/bash"},
+					Args:            []string{"-c", scrubScript, "scrub", strings.Join(pools, " "), strconv.Itoa(window)},
+					WorkingDir:      toolsBinDir,
+					Image:           c.spec.CurveVersion.Image,
+					ImagePullPolicy: c.spec.CurveVersion.ImagePullPolicy,
+					Env: []v1.EnvVar{
+						{Name: "PATH", Value: toolsBinDir + ":/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"},
+					},
+					VolumeMounts: mounts,
+				},
+			},
+			RestartPolicy: v1.RestartPolicyNever,
+			HostNetwork:   true,
+			DNSPolicy:     v1.DNSClusterFirstWithHostNet,
+			Volumes:       volumes,
+		},
+	}
+	k8sutil.InjectEnv(&podSpec.Spec, c.spec, "")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, c.spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, c.spec)
+	k8sutil.InjectDNS(&podSpec.Spec, c.spec, "")
+
+	cronJob := &batchv1beta1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ScrubAppName,
+			Namespace: c.namespacedName.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1beta1.CronJobSpec{
+			Schedule:                   schedule,
+			ConcurrencyPolicy:          batchv1beta1.ForbidConcurrent,
+			SuccessfulJobsHistoryLimit: &historyLimit,
+			FailedJobsHistoryLimit:     &historyLimit,
+			JobTemplate: batchv1beta1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: batch.JobSpec{
+					BackoffLimit:          &backoffLimit,
+					ActiveDeadlineSeconds: &deadline,
+					Template:              podSpec,
+				},
+			},
+		},
+	}
+
+	k8sutil.InjectMetadata(c.spec, "", cronJob, &cronJob.Spec.JobTemplate, &cronJob.Spec.JobTemplate.Spec.Template)
+	err := c.ownerInfo.SetControllerReference(cronJob)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to set owner reference to scrub cron job %q", cronJob.Name)
+	}
+
+	return cronJob, nil
+}
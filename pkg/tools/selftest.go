@@ -0,0 +1,245 @@
+package tools
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	batch "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+const (
+	// SelfTestAppName is the app label and the name of the self test cron job
+	SelfTestAppName = "curve-selftest"
+
+	defaultSelfTestSchedule       = "30 * * * *"
+	defaultSelfTestTimeoutSeconds = 600
+
+	// nbdBinDir is where curve-nbd is in curve image
+	nbdBinDir = "/curvebs/nbd/sbin"
+)
+
+// selfTestScript creates the test volume of 10GB which is the minimum size of curve, writes random data to it by
+// curve-nbd and reads them back, snapshots it by the snapshotclone of $1 if it's set, then deletes them. The last
+// line of the termination log is either "passed <steps>" or "failed <step>: <error>".
+const selfTestScript = `
+snapshot_addr=${1%%,*}
+volume=/curve-selftest
+user=curve
+steps=""
+device=""
+snapshot=""
+
+cleanup() {
+  if [ -n "$device" ]; then
+    curve-nbd unmap "$device" > /dev/null 2>&1
+    device=""
+  fi
+  if [ -n "$snapshot" ]; then
+    curl -s "http://$snapshot_addr/SnapshotCloneService?Action=DeleteSnapshot&Version=0.0.6&User=$user&File=$volume&UUID=$snapshot" > /dev/null
+    snapshot=""
+  fi
+  curve delete --user $user --filename $volume > /dev/null 2>&1
+}
+fail() {
+  echo "failed $1: $2" | tee /dev/termination-log
+  cleanup
+  exit 1
+}
+pass() {
+  steps="$steps $1"
+}
+trap 'fail timeout "the test is terminated by its deadline"' TERM INT
+
+# the volume left by a test that was killed
+cleanup
+
+if ! out=$(curve create --user $user --filename $volume --length 10 2>&1); then
+  fail create "$out"
+fi
+pass create
+
+modprobe nbd > /dev/null 2>&1
+if ! device=$(curve-nbd map "cbd:pool/${volume}_${user}_" 2>&1); then
+  out=$device
+  device=""
+  fail map "$out"
+fi
+dd if=/dev/urandom of=/tmp/data bs=1M count=4 status=none
+if ! out=$(dd if=/tmp/data of="$device" bs=1M count=4 oflag=direct status=none 2>&1); then
+  fail write "$out"
+fi
+pass write
+if ! out=$(dd if="$device" of=/tmp/read bs=1M count=4 iflag=direct status=none 2>&1); then
+  fail read "$out"
+fi
+if ! cmp -s /tmp/data /tmp/read; then
+  fail read "the data read back differ from the data written"
+fi
+pass read
+curve-nbd unmap "$device" > /dev/null 2>&1
+device=""
+
+if [ -n "$snapshot_addr" ]; then
+  out=$(curl -s "http://$snapshot_addr/SnapshotCloneService?Action=CreateSnapshot&Version=0.0.6&User=$user&File=$volume&Name=selftest")
+  snapshot=$(echo "$out" | sed -n 's/.*"UUID" *: *"\([^"]*\)".*/\1/p')
+  if [ -z "$snapshot" ]; then
+    fail snapshot "$out"
+  fi
+  while true; do
+    out=$(curl -s "http://$snapshot_addr/SnapshotCloneService?Action=GetFileSnapshotInfo&Version=0.0.6&User=$user&File=$volume&UUID=$snapshot")
+    status=$(echo "$out" | sed -n 's/.*"Status" *: *\([0-9]*\).*/\1/p')
+    case "$status" in
+    0) break ;;
+    1|2) sleep 5 ;;
+    *) fail snapshot "$out" ;;
+    esac
+  done
+  pass snapshot
+fi
+
+cleanup
+echo "passed$steps" | tee /dev/termination-log
+`
+
+// selfTestResult matches the result reported by the self test job
+var selfTestResult = regexp.MustCompile(`^(passed|failed) ?(.*)$`)
+
+// ReconcileSelfTest creates the cron job that tests the storage path on the schedule of maintenance.selfTest if
+// it's enabled, or deletes it. snapshotAddr is the addresses of snapshotclone, the snapshot is skipped if it's empty.
+func (c *Cluster) ReconcileSelfTest(snapshotAddr string) error {
+	if !c.spec.Maintenance.SelfTest.Enable {
+		propagation := metav1.DeletePropagationBackground
+		err := c.context.Clientset.BatchV1beta1().CronJobs(c.namespacedName.Namespace).Delete(SelfTestAppName, &metav1.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil && !kerrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete self test cron job %s", SelfTestAppName)
+		}
+		return nil
+	}
+
+	cronJob, err := c.makeSelfTestCronJob(snapshotAddr)
+	if err != nil {
+		return err
+	}
+	if err := k8sutil.Apply(c.context.Client, cronJob); err != nil {
+		return errors.Wrapf(err, "failed to apply self test cron job %s", cronJob.Name)
+	}
+	return nil
+}
+
+func (c *Cluster) makeSelfTestCronJob(snapshotAddr string) (*batchv1beta1.CronJob, error) {
+	selfTest := c.spec.Maintenance.SelfTest
+	labels := map[string]string{
+		"app":           SelfTestAppName,
+		"curve_cluster": c.namespacedName.Namespace,
+	}
+
+	schedule := selfTest.Schedule
+	if schedule == "" {
+		schedule = defaultSelfTestSchedule
+	}
+	timeout := selfTest.TimeoutSeconds
+	if timeout == 0 {
+		timeout = defaultSelfTestTimeoutSeconds
+	}
+
+	volumes, mounts := c.toolsVolumesAndMounts()
+	// curve-nbd needs the nbd devices and modules of the host
+	volumes = append(volumes,
+		v1.Volume{Name: "devices", VolumeSource: v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: config.GetOperatorSettings().HostDevDir}}},
+		v1.Volume{Name: "modules", VolumeSource: v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: config.GetOperatorSettings().HostModulesDir}}})
+	mounts = append(mounts,
+		v1.VolumeMount{Name: "devices", MountPath: "/dev"},
+		v1.VolumeMount{Name: "modules", MountPath: "/lib/modules", ReadOnly: true})
+	privileged := true
+	backoffLimit := int32(0)
+	historyLimit := int32(1)
+	deadline := int64(timeout)
+
+	podSpec := v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: labels,
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:            "selftest",
+					Command:         []string{"/bin/bash"},
+					Args:            []string{"-c", selfTestScript, "selftest", snapshotAddr},
+					WorkingDir:      toolsBinDir,
+					Image:           c.spec.CurveVersion.Image,
+					ImagePullPolicy: c.spec.CurveVersion.ImagePullPolicy,
+					Env: []v1.EnvVar{
+						{Name: "PATH", Value: toolsBinDir + ":" + nbdBinDir + ":/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"},
+					},
+					VolumeMounts: mounts,
+					SecurityContext: &v1.SecurityContext{
+						Privileged: &privileged,
+					},
+				},
+			},
+			RestartPolicy: v1.RestartPolicyNever,
+			HostNetwork:   true,
+			DNSPolicy:     v1.DNSClusterFirstWithHostNet,
+			Volumes:       volumes,
+		},
+	}
+	k8sutil.InjectEnv(&podSpec.Spec, c.spec, "")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, c.spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, c.spec)
+	k8sutil.InjectDNS(&podSpec.Spec, c.spec, "")
+
+	cronJob := &batchv1beta1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      SelfTestAppName,
+			Namespace: c.namespacedName.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1beta1.CronJobSpec{
+			Schedule:                   schedule,
+			ConcurrencyPolicy:          batchv1beta1.ForbidConcurrent,
+			SuccessfulJobsHistoryLimit: &historyLimit,
+			FailedJobsHistoryLimit:     &historyLimit,
+			JobTemplate: batchv1beta1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: batch.JobSpec{
+					BackoffLimit:          &backoffLimit,
+					ActiveDeadlineSeconds: &deadline,
+					Template:              podSpec,
+				},
+			},
+		},
+	}
+
+	k8sutil.InjectMetadata(c.spec, "", cronJob, &cronJob.Spec.JobTemplate, &cronJob.Spec.JobTemplate.Spec.Template)
+	err := c.ownerInfo.SetControllerReference(cronJob)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to set owner reference to self test cron job %q", cronJob.Name)
+	}
+
+	return cronJob, nil
+}
+
+// ParseSelfTestResult returns the result and the message in the termination message of the self test job, the
+// test failed if it reported nothing such as when it was killed
+func ParseSelfTestResult(message string) (curvev1.SelfTestResult, string) {
+	lines := strings.Split(strings.TrimSpace(message), "\n")
+	match := selfTestResult.FindStringSubmatch(strings.TrimSpace(lines[len(lines)-1]))
+	if match == nil {
+		return curvev1.SelfTestFailed, "the test reported nothing"
+	}
+	if match[1] == "passed" {
+		return curvev1.SelfTestPassed, strings.TrimSpace(match[2])
+	}
+	return curvev1.SelfTestFailed, match[2]
+}
@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// ExecInToolsPod runs the command in a running tools pod of the cluster, the curve client in it talks to the
+// mds by client.conf
+func ExecInToolsPod(c *clusterd.Context, namespace string, command []string) (string, error) {
+	pods, err := c.Clientset.CoreV1().Pods(namespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=%s", AppName),
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to list tools pods")
+	}
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase == v1.PodRunning {
+			return k8sutil.ExecInPod(c, pod, command)
+		}
+	}
+
+	return "", errors.New("no running tools pod, spec.tools.enable of the cluster must be true")
+}
+
+// UpdateThrottle sets a throttle limit of the volume by 'curve update_throttle', the limit is removed if it's 0
+func UpdateThrottle(c *clusterd.Context, namespace, user, password, volume string, limit curvev1.ThrottleLimit) error {
+	command := []string{"curve", "update_throttle",
+		"--user", user,
+		"--filename", volume,
+		"--type", string(limit.Type),
+		"--limit", strconv.FormatInt(limit.Limit, 10),
+	}
+	if limit.Burst > 0 {
+		command = append(command, "--burst", strconv.FormatInt(limit.Burst, 10))
+	}
+	if limit.BurstLength > 0 {
+		command = append(command, "--burstLength", strconv.FormatInt(limit.BurstLength, 10))
+	}
+	if password != "" {
+		command = append(command, "--password", password)
+	}
+
+	if _, err := ExecInToolsPod(c, namespace, command); err != nil {
+		return errors.Wrapf(err, "failed to update throttle %s of volume %s", limit.Type, volume)
+	}
+	return nil
+}
+
+// ListVolumes lists the volumes of the user under the root directory by 'curve list'
+func ListVolumes(c *clusterd.Context, namespace, user, password string) ([]string, error) {
+	command := []string{"curve", "list", "--user", user, "--dirname", "/"}
+	if password != "" {
+		command = append(command, "--password", password)
+	}
+
+	output, err := ExecInToolsPod(c, namespace, command)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list volumes of user %s", user)
+	}
+	return parseVolumes(output), nil
+}
+
+// parseVolumes parses the file names printed one per line by 'curve list' to the paths of the volumes
+func parseVolumes(output string) []string {
+	var volumes []string
+	for _, line := range strings.Split(output, "\n") {
+		name := strings.TrimSpace(line)
+		if name == "" || strings.ContainsAny(name, " \t") {
+			continue
+		}
+		if !strings.HasPrefix(name, "/") {
+			name = "/" + name
+		}
+		volumes = append(volumes, name)
+	}
+	return volumes
+}
@@ -0,0 +1,172 @@
+package tools
+
+import (
+	"path"
+
+	"github.com/coreos/pkg/capnslog"
+	"github.com/pkg/errors"
+	apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/clusterd"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+const (
+	AppName = "curve-tools"
+
+	// toolsBinDir is where curve_ops_tool is in curve image
+	toolsBinDir = "/curvebs/tools/sbin"
+
+	// clientConfigFile is the default config file of curve client
+	clientConfigFile = "client.conf"
+)
+
+type Cluster struct {
+	context        clusterd.Context
+	namespacedName types.NamespacedName
+	spec           curvev1.CurveClusterSpec
+	ownerInfo      *k8sutil.OwnerInfo
+}
+
+var logger = capnslog.NewPackageLogger("github.com/opencurve/curve-operator", "tools")
+
+func New(context clusterd.Context,
+	namespacedName types.NamespacedName,
+	spec curvev1.CurveClusterSpec,
+	ownerInfo *k8sutil.OwnerInfo) *Cluster {
+	return &Cluster{
+		context:        context,
+		namespacedName: namespacedName,
+		spec:           spec,
+		ownerInfo:      ownerInfo,
+	}
+}
+
+// Start creates the tools deployment, tools.conf and cs_client.conf configmaps must be created before
+func (c *Cluster) Start() error {
+	logger.Info("starting tools pod")
+
+	d, err := c.makeDeployment()
+	if err != nil {
+		return errors.Wrapf(err, "failed to create tools Deployment %q object", AppName)
+	}
+
+	_, err = k8sutil.CreateOrUpdateDeployment(&c.context, d)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create tools deployment %q in cluster", AppName)
+	}
+
+	return nil
+}
+
+// makeDeployment make the deployment of tools pod that sleeps forever for kubectl exec
+func (c *Cluster) makeDeployment() (*apps.Deployment, error) {
+	volumes, mounts := c.toolsVolumesAndMounts()
+
+	podSpec := v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   AppName,
+			Labels: c.getPodLabels(),
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:            "tools",
+					Command:         []string{"/bin/bash"},
+					Args:            []string{"-c", "trap 'exit 0' TERM; while true; do sleep 3600 & wait $!; done"},
+					WorkingDir:      toolsBinDir,
+					Image:           c.spec.CurveVersion.Image,
+					ImagePullPolicy: c.spec.CurveVersion.ImagePullPolicy,
+					Env: []v1.EnvVar{
+						{Name: "TZ", Value: "Asia/Hangzhou"},
+						{Name: "PATH", Value: toolsBinDir + ":/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"},
+					},
+					VolumeMounts: mounts,
+				},
+			},
+			RestartPolicy: v1.RestartPolicyAlways,
+			HostNetwork:   true,
+			DNSPolicy:     v1.DNSClusterFirstWithHostNet,
+			Volumes:       volumes,
+		},
+	}
+	k8sutil.InjectEnv(&podSpec.Spec, c.spec, "")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, c.spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, c.spec)
+	k8sutil.InjectDNS(&podSpec.Spec, c.spec, "")
+
+	replicas := int32(1)
+
+	d := &apps.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      AppName,
+			Namespace: c.namespacedName.Namespace,
+			Labels:    c.getPodLabels(),
+		},
+		Spec: apps.DeploymentSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: c.getPodLabels(),
+			},
+			Template: podSpec,
+			Replicas: &replicas,
+			Strategy: apps.DeploymentStrategy{
+				Type: apps.RecreateDeploymentStrategyType,
+			},
+		},
+	}
+
+	// set ownerReference
+	k8sutil.InjectMetadata(c.spec, "", d, &d.Spec.Template)
+	err := c.ownerInfo.SetControllerReference(d)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to set owner reference to tools deployment %q", d.Name)
+	}
+
+	return d, nil
+}
+
+// toolsVolumesAndMounts mounts tools.conf for curve_ops_tool and cs_client.conf as client.conf for curve client
+func (c *Cluster) toolsVolumesAndMounts() ([]v1.Volume, []v1.VolumeMount) {
+	mode := int32(0644)
+	files := []struct {
+		configMapName string
+		dataKey       string
+		fileName      string
+	}{
+		{config.ToolsConfigMapName, config.ToolsConfigMapDataKey, config.ToolsConfigMapDataKey},
+		{config.CSClientConfigMapName, config.CSClientConfigMapDataKey, clientConfigFile},
+	}
+
+	vols := []v1.Volume{}
+	mounts := []v1.VolumeMount{}
+	for _, f := range files {
+		vols = append(vols, v1.Volume{
+			Name: f.configMapName,
+			VolumeSource: v1.VolumeSource{
+				ConfigMap: &v1.ConfigMapVolumeSource{
+					LocalObjectReference: v1.LocalObjectReference{Name: f.configMapName},
+					Items:                []v1.KeyToPath{{Key: f.dataKey, Path: f.dataKey, Mode: &mode}},
+				},
+			},
+		})
+		mounts = append(mounts, v1.VolumeMount{
+			Name:      f.configMapName,
+			ReadOnly:  true,
+			MountPath: path.Join(config.ToolsConfigMapMountPathDir, f.fileName),
+			SubPath:   f.dataKey,
+		})
+	}
+	return vols, mounts
+}
+
+func (c *Cluster) getPodLabels() map[string]string {
+	return map[string]string{
+		"app":           AppName,
+		"curve_cluster": c.namespacedName.Namespace,
+	}
+}
@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+const (
+	// UpgradeCheckAppName is the app label and the name of the upgrade check job
+	UpgradeCheckAppName = "curve-upgrade-check"
+
+	upgradeCheckReportPrefix = "versions"
+)
+
+// upgradeCheckScript reports the versions of the running mds and chunkservers by curve_ops_tool of the target image,
+// which must be able to talk to the running cluster
+const upgradeCheckScript = `
+report() {
+  echo "$@" | tee /dev/termination-log
+}
+
+if ! out=$(curve_ops_tool mds-status 2>&1 && curve_ops_tool chunkserver-status 2>&1); then
+  echo "$out"
+  report "failed to get the status of the cluster by curve_ops_tool of the target image"
+  exit 1
+fi
+echo "$out"
+versions=$(echo "$out" | grep -i "version" | grep -oE '[0-9]+\.[0-9]+\.[0-9]+' | sort -u | tr '\n' ' ')
+report "versions ${versions}"
+`
+
+// MakeUpgradeCheckJob makes the job that reports the versions of the running daemons by the image of the spec, the
+// spec of the tools cluster must be the one to upgrade to
+func (c *Cluster) MakeUpgradeCheckJob() (*batch.Job, error) {
+	labels := map[string]string{
+		"app":           UpgradeCheckAppName,
+		"curve_cluster": c.namespacedName.Namespace,
+	}
+	volumes, mounts := c.toolsVolumesAndMounts()
+	backoffLimit := int32(0)
+
+	podSpec := v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   UpgradeCheckAppName,
+			Labels: labels,
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:            "upgrade-check",
+					Command:         []string{"/bin/bash"},
+					Args:            []string{"-c", upgradeCheckScript},
+					WorkingDir:      toolsBinDir,
+					Image:           c.spec.CurveVersion.Image,
+					ImagePullPolicy: c.spec.CurveVersion.ImagePullPolicy,
+					Env: []v1.EnvVar{
+						{Name: "PATH", Value: toolsBinDir + ":/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"},
+					},
+					VolumeMounts: mounts,
+				},
+			},
+			RestartPolicy: v1.RestartPolicyNever,
+			HostNetwork:   true,
+			DNSPolicy:     v1.DNSClusterFirstWithHostNet,
+			Volumes:       volumes,
+		},
+	}
+	k8sutil.InjectEnv(&podSpec.Spec, c.spec, "")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, c.spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, c.spec)
+	k8sutil.InjectDNS(&podSpec.Spec, c.spec, "")
+
+	job := &batch.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      UpgradeCheckAppName,
+			Namespace: c.namespacedName.Namespace,
+			Labels:    labels,
+		},
+		Spec: batch.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template:     podSpec,
+		},
+	}
+
+	k8sutil.InjectMetadata(c.spec, "", job, &job.Spec.Template)
+	err := c.ownerInfo.SetControllerReference(job)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to set owner reference to upgrade check job %q", job.Name)
+	}
+
+	return job, nil
+}
+
+// ParseUpgradeCheckVersions parses the versions reported by the upgrade check job, it's empty if curve_ops_tool
+// doesn't report the versions
+func ParseUpgradeCheckVersions(message string) ([]string, error) {
+	fields := strings.Fields(message)
+	if len(fields) == 0 || fields[0] != upgradeCheckReportPrefix {
+		return nil, errors.Errorf("unexpected versions reported by upgrade check job: %s", message)
+	}
+	return fields[1:], nil
+}
@@ -0,0 +1,163 @@
+package tools
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/pkg/errors"
+	batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+const (
+	UserJobAppName         = "curve-user"
+	UserJobActionLabel     = "curve_user_action"
+	UserJobSyncAction      = "sync"
+	UserJobDeleteAction    = "delete"
+	userJobNameFormat      = "curve-user-%s"
+	userJobGenerationLabel = "curve_user_generation"
+	userJobPasswordEnv     = "CURVE_PASSWORD"
+)
+
+// userScript creates the directory /<owner> of the user $1 and reports the number and the total size in GB of
+// the volumes in it for action sync, or deletes the directory for action delete
+const userScript = `
+report() {
+  echo "$@" | tee /dev/termination-log
+}
+
+user=$1
+dir="/$1"
+args="--user $user"
+if [ -n "$CURVE_PASSWORD" ]; then
+  args="$args --password $CURVE_PASSWORD"
+fi
+
+case "$2" in
+sync)
+  if ! curve list $args --dirname "$dir" > /dev/null 2>&1; then
+    if ! curve mkdir $args --dirname "$dir"; then
+      report "failed to create directory $dir of user $user"
+      exit 1
+    fi
+  fi
+  if ! volumes=$(curve list $args --dirname "$dir"); then
+    report "failed to list volumes in directory $dir of user $user"
+    exit 1
+  fi
+  count=$(echo "$volumes" | grep -c . || true)
+  size=$(curve_ops_tool file-size -fileName="$dir" | sed -n 's/.*size: *\([0-9]*\) *GB.*/\1/p' | head -n 1)
+  report "volumes $count size ${size:-0}"
+  ;;
+delete)
+  if ! curve list $args --dirname "$dir" > /dev/null 2>&1; then
+    report "directory $dir of user $user is not found"
+    exit 0
+  fi
+  if ! curve rmdir $args --dirname "$dir"; then
+    report "failed to delete directory $dir of user $user, the volumes in it must be deleted first"
+    exit 1
+  fi
+  report "directory $dir of user $user is deleted"
+  ;;
+esac
+`
+
+// userUsage matches the usage reported by the sync action of the user job
+var userUsage = regexp.MustCompile(`^volumes (\d+) size (\d+)$`)
+
+// UserJobName returns the name of the job of the CurveUser
+func UserJobName(name string) string {
+	return k8sutil.TruncateNodeNameForJob(userJobNameFormat, name)
+}
+
+// MakeUserJob makes the job that runs the action of the user by curve client, the owner info of the tools cluster
+// must be the one of the CurveUser
+func (c *Cluster) MakeUserJob(name, owner, action string, generation int64, passwordSecret *v1.SecretKeySelector) (*batch.Job, error) {
+	jobName := UserJobName(name)
+	labels := map[string]string{
+		"app":                  UserJobAppName,
+		"curve_cluster":        c.namespacedName.Namespace,
+		UserJobActionLabel:     action,
+		userJobGenerationLabel: strconv.FormatInt(generation, 10),
+	}
+	volumes, mounts := c.toolsVolumesAndMounts()
+	backoffLimit := int32(0)
+
+	container := v1.Container{
+		Name:            "user",
+		Command:         []string{"/bin/bash"},
+		Args:            []string{"-c", userScript, "user", owner, action},
+		WorkingDir:      toolsBinDir,
+		Image:           c.spec.CurveVersion.Image,
+		ImagePullPolicy: c.spec.CurveVersion.ImagePullPolicy,
+		Env: []v1.EnvVar{
+			{Name: "PATH", Value: toolsBinDir + ":/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"},
+		},
+		VolumeMounts: mounts,
+	}
+	if passwordSecret != nil {
+		container.Env = append(container.Env, v1.EnvVar{
+			Name:      userJobPasswordEnv,
+			ValueFrom: &v1.EnvVarSource{SecretKeyRef: passwordSecret},
+		})
+	}
+
+	podSpec := v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   jobName,
+			Labels: labels,
+		},
+		Spec: v1.PodSpec{
+			Containers:    []v1.Container{container},
+			RestartPolicy: v1.RestartPolicyNever,
+			HostNetwork:   true,
+			DNSPolicy:     v1.DNSClusterFirstWithHostNet,
+			Volumes:       volumes,
+		},
+	}
+	k8sutil.InjectEnv(&podSpec.Spec, c.spec, "")
+	k8sutil.InjectArchAffinity(&podSpec.Spec, c.spec)
+	k8sutil.InjectSchedulerName(&podSpec.Spec, c.spec)
+	k8sutil.InjectDNS(&podSpec.Spec, c.spec, "")
+
+	job := &batch.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jobName,
+			Namespace: c.namespacedName.Namespace,
+			Labels:    labels,
+		},
+		Spec: batch.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template:     podSpec,
+		},
+	}
+
+	k8sutil.InjectMetadata(c.spec, "", job, &job.Spec.Template)
+	err := c.ownerInfo.SetControllerReference(job)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to set owner reference to user job %q", job.Name)
+	}
+
+	return job, nil
+}
+
+// UserJobGeneration returns the generation of the CurveUser that the job is made for
+func UserJobGeneration(job *batch.Job) int64 {
+	generation, _ := strconv.ParseInt(job.Labels[userJobGenerationLabel], 10, 64)
+	return generation
+}
+
+// ParseUserUsage parses the number and the total size in bytes of the volumes reported by the sync action
+func ParseUserUsage(message string) (int, int64, error) {
+	match := userUsage.FindStringSubmatch(message)
+	if match == nil {
+		return 0, 0, errors.Errorf("unexpected usage reported by user job: %s", message)
+	}
+	volumes, _ := strconv.Atoi(match[1])
+	sizeGB, _ := strconv.ParseInt(match[2], 10, 64)
+	return volumes, sizeGB << 30, nil
+}
@@ -0,0 +1,145 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/config"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+const (
+	// ImageVerifyAppName is the app label of the jobs that verify the signatures of the images
+	ImageVerifyAppName = "curve-image-verify"
+
+	cosignPublicKeyKey   = "cosign.pub"
+	cosignPublicKeyDir   = "/etc/cosign"
+	imageVerifyVolume    = "cosign-public-key"
+	imageVerifyJobFormat = "curve-image-verify-%s"
+)
+
+// ImageVerificationError is returned if the signature of the image is not verified by the public key, the
+// workloads are not created or updated with the image until it's fixed
+type ImageVerificationError struct {
+	Image   string
+	Message string
+}
+
+func (e *ImageVerificationError) Error() string {
+	return fmt.Sprintf("signature of image %q is not verified: %s", e.Image, e.Message)
+}
+
+// ClusterErrors returns a ConfigError, the image or the public key must be changed
+func (e *ImageVerificationError) ClusterErrors() []curvev1.ClusterError {
+	return []curvev1.ClusterError{{Category: curvev1.ErrorCategoryConfigError, Message: e.Error()}}
+}
+
+// ImageVerifyJobName returns the name of the job that verifies the image by the public key of the spec, a new job
+// is run when either of them changes
+func (c *Cluster) ImageVerifyJobName(image string) string {
+	return fmt.Sprintf(imageVerifyJobFormat, k8sutil.Hash(image + "/" + c.spec.CurveVersion.Verification.PublicKeySecret)[:10])
+}
+
+// MakeImageVerifyJob makes the job that verifies the signature of the image by cosign with the public key of
+// curveVersion.verification, the payloads of the verified signatures are printed in json
+func (c *Cluster) MakeImageVerifyJob(image string) (*batch.Job, error) {
+	verification := c.spec.CurveVersion.Verification
+	labels := map[string]string{
+		"app":           ImageVerifyAppName,
+		"curve_cluster": c.namespacedName.Namespace,
+	}
+	cosignImage := verification.Image
+	if cosignImage == "" {
+		cosignImage = config.GetOperatorSettings().CosignImage
+	}
+	backoffLimit := int32(0)
+
+	podSpec := v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: labels,
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:            "cosign",
+					Args:            []string{"verify", "--key", cosignPublicKeyDir + "/" + cosignPublicKeyKey, "--output", "json", image},
+					Image:           cosignImage,
+					ImagePullPolicy: c.spec.CurveVersion.ImagePullPolicy,
+					VolumeMounts: []v1.VolumeMount{
+						{Name: imageVerifyVolume, MountPath: cosignPublicKeyDir, ReadOnly: true},
+					},
+				},
+			},
+			RestartPolicy: v1.RestartPolicyNever,
+			Volumes: []v1.Volume{
+				{
+					Name: imageVerifyVolume,
+					VolumeSource: v1.VolumeSource{
+						Secret: &v1.SecretVolumeSource{
+							SecretName: verification.PublicKeySecret,
+							Items:      []v1.KeyToPath{{Key: cosignPublicKeyKey, Path: cosignPublicKeyKey}},
+						},
+					},
+				},
+			},
+		},
+	}
+	// the registry may be reached by the proxy of spec.env
+	k8sutil.InjectEnv(&podSpec.Spec, c.spec, "")
+	k8sutil.InjectSchedulerName(&podSpec.Spec, c.spec)
+	k8sutil.InjectDNS(&podSpec.Spec, c.spec, "")
+
+	job := &batch.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      c.ImageVerifyJobName(image),
+			Namespace: c.namespacedName.Namespace,
+			Labels:    labels,
+		},
+		Spec: batch.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template:     podSpec,
+		},
+	}
+
+	k8sutil.InjectMetadata(c.spec, "", job, &job.Spec.Template)
+	err := c.ownerInfo.SetControllerReference(job)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to set owner reference to image verify job %q", job.Name)
+	}
+
+	return job, nil
+}
+
+// ParseVerifiedDigest returns the digest of the image in the payloads of the signatures printed by cosign verify,
+// the logs of cosign are skipped
+func ParseVerifiedDigest(logs string) (string, error) {
+	for _, line := range strings.Split(logs, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "[") {
+			continue
+		}
+		var payloads []struct {
+			Critical struct {
+				Image struct {
+					Digest string `json:"docker-manifest-digest"`
+				} `json:"image"`
+			} `json:"critical"`
+		}
+		if err := json.Unmarshal([]byte(line), &payloads); err != nil {
+			continue
+		}
+		for _, payload := range payloads {
+			if payload.Critical.Image.Digest != "" {
+				return payload.Critical.Image.Digest, nil
+			}
+		}
+	}
+	return "", errors.New("no digest of the verified signatures in the output of cosign")
+}
@@ -0,0 +1,159 @@
+package validation
+
+import (
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+// StableDeviceDir is the directory of the links to the block devices that are kept by udev, only the ones under
+// by-id and by-path are stable since the others change when the device is formatted
+const StableDeviceDir = "/dev/disk/"
+
+// ValidateStorage checks the nodes and the devices of storage. The storage nodes matched by storage.nodeSelector
+// are resolved by the operator, the devices are checked without them if they are not resolved yet.
+func ValidateStorage(spec *curvev1.CurveClusterSpec) error {
+	storage := &spec.Storage
+	if !storage.UseSelectedNodes && (len(storage.Devices) == 0 || (len(storage.Nodes) == 0 && storage.NodeSelector == nil)) {
+		return errors.New("useSelectedNodes is set to false but storage.nodes, storage.nodeSelector or storage.devices is not specified")
+	}
+	if storage.UseSelectedNodes && len(storage.SelectedNodes) == 0 {
+		return errors.New("useSelectedNodes is set to true but selectedNodes is not specified")
+	}
+
+	nodes := spec.StorageNodes()
+	if len(nodes) == 0 {
+		// the devices of storage.devices are the same on all the nodes matched by the selector
+		nodes = []string{""}
+	}
+	for _, nodeName := range nodes {
+		devices := storage.NodeDevices(nodeName)
+		if len(devices) == 0 {
+			return errors.Errorf("no device specified on storage node %q", nodeName)
+		}
+		for _, device := range devices {
+			if err := validateDevice(storage, nodeName, device, devices); err != nil {
+				return err
+			}
+		}
+		if err := validateOvercommit(storage, nodeName); err != nil {
+			return err
+		}
+	}
+	return validateIOLimits(storage.IO)
+}
+
+// validateDevice checks the device of the node, the nodeDevices are all the devices of the node
+func validateDevice(storage *curvev1.StorageScopeSpec, nodeName string, device curvev1.DevicesSpec, nodeDevices []curvev1.DevicesSpec) error {
+	if device.IsPath() && !path.IsAbs(device.Name) {
+		return errors.Errorf("device %q is type of path but not an absolute directory", device.Name)
+	}
+	if device.IsLoop() && (device.Size == nil || device.Size.Sign() <= 0 || strings.Contains(device.Name, "/")) {
+		return errors.Errorf("device %q is type of loop but has no size or is not a file name", device.Name)
+	}
+	if storage.IsSPDK() && (device.IsPath() || device.IsLoop() || device.MountPath == "") {
+		return errors.Errorf("device %q must be a block device with mountPath to keep metadata for spdk engine", device.Name)
+	}
+	if err := validatePoolSize(storage, nodeName, device); err != nil {
+		return err
+	}
+	if err := validateStableName(device); err != nil {
+		return err
+	}
+	if err := validateEncryption(storage, device); err != nil {
+		return err
+	}
+	return validateCacheDevice(storage, device, nodeDevices)
+}
+
+// validatePoolSize returns an error if the chunk file pool of the device on the node is less than
+// storage.minPoolSize, the device without capacity is not checked
+func validatePoolSize(storage *curvev1.StorageScopeSpec, nodeName string, device curvev1.DevicesSpec) error {
+	min := storage.MinPoolSize
+	if min == nil || device.Capacity == nil {
+		return nil
+	}
+	if device.PoolBytes() < min.Value() {
+		return errors.Errorf("chunk file pool of device %q on node %q is %d bytes by percentage %d, less than minPoolSize %s",
+			device.Name, nodeName, device.PoolBytes(), device.Percentage, min.String())
+	}
+	return nil
+}
+
+// validateOvercommit returns an error if a device of the node is over the limit of storage.reservedPercentage and
+// storage.overcommitPolicy is Reject
+func validateOvercommit(storage *curvev1.StorageScopeSpec, nodeName string) error {
+	if storage.GetOvercommitPolicy() != curvev1.OvercommitReject {
+		return nil
+	}
+	if devices := storage.OvercommittedDevices(nodeName); len(devices) > 0 {
+		device := devices[0]
+		return errors.Errorf("percentage %d of device %q on node %q leaves less than reservedPercentage %d, lower it or set overcommitPolicy",
+			device.Percentage, device.Name, nodeName, storage.ReservedPercentage)
+	}
+	return nil
+}
+
+// validateStableName checks that the block device given by a link under /dev/disk is stable
+func validateStableName(device curvev1.DevicesSpec) error {
+	if device.IsPath() || device.IsLoop() || !strings.HasPrefix(device.Name, StableDeviceDir) {
+		return nil
+	}
+	if !strings.HasPrefix(device.Name, StableDeviceDir+"by-id/") && !strings.HasPrefix(device.Name, StableDeviceDir+"by-path/") {
+		return errors.Errorf("device %q must be a link under %sby-id or %sby-path, the other links change when it's formatted",
+			device.Name, StableDeviceDir, StableDeviceDir)
+	}
+	return nil
+}
+
+// validateEncryption returns an error if the device is encrypted but is not a block device with a key secret
+func validateEncryption(storage *curvev1.StorageScopeSpec, device curvev1.DevicesSpec) error {
+	if !device.Encrypted {
+		return nil
+	}
+	if device.IsPath() || device.IsLoop() || storage.IsSPDK() {
+		return errors.Errorf("device %q can't be encrypted, only block device of the chunkserver engine can be encrypted", device.Name)
+	}
+	if device.KeySecret == nil || device.KeySecret.Name == "" || device.KeySecret.Key == "" {
+		return errors.Errorf("encrypted device %q has no keySecret", device.Name)
+	}
+	return nil
+}
+
+// validateCacheDevice returns an error if the device has a cache device but is not a block device of the chunkserver
+// engine, or the cache device is used by another device on the node
+func validateCacheDevice(storage *curvev1.StorageScopeSpec, device curvev1.DevicesSpec, nodeDevices []curvev1.DevicesSpec) error {
+	if device.CacheDevice == "" {
+		return nil
+	}
+	if device.IsPath() || device.IsLoop() || storage.IsSPDK() {
+		return errors.Errorf("device %q can't be cached, only block device of the chunkserver engine can be cached", device.Name)
+	}
+	// the encrypted device is opened by the name of the bcache device that may change after the node reboots
+	if device.Encrypted {
+		return errors.Errorf("device %q can't be both encrypted and cached", device.Name)
+	}
+	for _, other := range nodeDevices {
+		if other.Name == device.CacheDevice || (other.Name != device.Name && other.CacheDevice == device.CacheDevice) {
+			return errors.Errorf("cache device %q of device %q is used by device %q", device.CacheDevice, device.Name, other.Name)
+		}
+	}
+	return nil
+}
+
+// validateIOLimits checks the rates of storage.io
+func validateIOLimits(io curvev1.IOLimitsSpec) error {
+	for name, rate := range map[string]*resource.Quantity{
+		"readBytesPerSecond":  io.ReadBytesPerSecond,
+		"writeBytesPerSecond": io.WriteBytesPerSecond,
+	} {
+		if rate != nil && rate.Sign() <= 0 {
+			return errors.Errorf("storage.io.%s must be positive, got %s", name, rate.String())
+		}
+	}
+	return nil
+}
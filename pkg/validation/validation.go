@@ -0,0 +1,139 @@
+// Package validation checks the spec of CurveCluster by the rules that don't need the objects in the k8s cluster,
+// so the same rules are run by the operator before it deploys the cluster, by the validating webhook before the
+// spec is accepted and by 'curvectl validate' before the manifest is applied.
+package validation
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+	"github.com/opencurve/curve-operator/pkg/snapshotclone"
+	"github.com/opencurve/curve-operator/pkg/version"
+)
+
+// ValidateCluster returns the errors of the spec of the cluster, nil if it's valid
+func ValidateCluster(cluster *curvev1.CurveCluster) []error {
+	spec := cluster.Spec
+	if spec == nil {
+		return []error{errors.New("spec is not set")}
+	}
+	var errs []error
+	for _, validate := range []func(*curvev1.CurveClusterSpec) error{
+		ValidateDaemonNodes,
+		ValidateStorage,
+		ValidatePriorityClasses,
+		snapshotclone.ValidateS3Config,
+		func(spec *curvev1.CurveClusterSpec) error {
+			return k8sutil.ValidateMaintenanceWindow(spec.MaintenanceWindow)
+		},
+		func(spec *curvev1.CurveClusterSpec) error {
+			if spec.CurveVersion.ToolsImage == "" {
+				return nil
+			}
+			return version.CheckToolsImage(spec.CurveVersion.ToolsImage, spec.CurveVersion.Image)
+		},
+		func(spec *curvev1.CurveClusterSpec) error {
+			if spec.Adoption == nil {
+				return nil
+			}
+			return ValidateAdoption(spec)
+		},
+	} {
+		if err := validate(spec); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// ValidateDaemonNodes checks the nodes of etcd and mds are 3 different nodes, and the snapshotclone nodes are enough
+// for its replicas. The snapshotclone nodes matched by snapShotClone.nodeSelector are not checked until they are
+// resolved by the operator.
+func ValidateDaemonNodes(spec *curvev1.CurveClusterSpec) error {
+	// Assert the node num of each daemon is 3, the nodes of daemon default to spec.nodes
+	daemonNodes := map[string][]string{
+		"mds": spec.MdsNodes(),
+	}
+	// the etcd StatefulSet is not bound to nodes
+	if spec.Etcd.StatefulSet == nil {
+		daemonNodes["etcd"] = spec.EtcdNodes()
+	}
+	for _, daemon := range []string{"etcd", "mds"} {
+		nodes, ok := daemonNodes[daemon]
+		if !ok {
+			continue
+		}
+		nodesNum := len(nodes)
+		if nodesNum < 3 {
+			return errors.Errorf("%s nodes count %d is less than 3, cannot start cluster", daemon, nodesNum)
+		} else if nodesNum > 3 {
+			return errors.Errorf("%s nodes count %d is more than 3, only 3 nodes are supported now", daemon, nodesNum)
+		}
+		// replicas of a daemon on the same node can't be scheduled because of the anti-affinity
+		if len(k8sutil.MergeNodeNames(nodes)) != nodesNum {
+			return errors.Errorf("%s nodes %v contain duplicate node, each replica must be on a different node", daemon, nodes)
+		}
+	}
+	// snapshotclone runs on the first nodes by its replicas
+	if spec.SnapShotClone.Enable && (spec.SnapShotClone.NodeSelector == nil || len(spec.SnapShotClone.Nodes) > 0) {
+		nodes := spec.SnapShotCloneNodes()
+		if replicas := spec.SnapShotCloneReplicas(); len(nodes) < replicas {
+			return errors.Errorf("snapshotclone nodes count %d is less than its replicas %d, cannot start cluster", len(nodes), replicas)
+		}
+		if len(k8sutil.MergeNodeNames(nodes)) != len(nodes) {
+			return errors.Errorf("snapshotclone nodes %v contain duplicate node, each replica must be on a different node", nodes)
+		}
+	}
+	return nil
+}
+
+// ValidatePriorityClasses checks the daemons of priorityClassNames are known, the priority classes are checked to
+// exist by the operator
+func ValidatePriorityClasses(spec *curvev1.CurveClusterSpec) error {
+	daemons := map[string]bool{curvev1.PriorityClassNameAll: true, "etcd": true, "mds": true, "chunkserver": true, "snapshotclone": true}
+	for daemon, name := range spec.PriorityClassNames {
+		if !daemons[daemon] {
+			return errors.Errorf("unknown daemon %q of priority class %q", daemon, name)
+		}
+	}
+	return nil
+}
+
+// ValidateAdoption checks that the endpoints of the cluster to adopt are on the ports of the spec, which the pods
+// of the operator replacing them listen on
+func ValidateAdoption(spec *curvev1.CurveClusterSpec) error {
+	adoption := spec.Adoption
+	if len(adoption.EtcdEndpoints) == 0 || len(adoption.MdsEndpoints) == 0 {
+		return errors.New("etcdEndpoints and mdsEndpoints of adoption must be set")
+	}
+	check := func(endpoints []string, port int, daemon string) error {
+		for _, endpoint := range endpoints {
+			_, p, err := net.SplitHostPort(endpoint)
+			if err != nil {
+				return errors.Wrapf(err, "invalid %s endpoint %q of adoption", daemon, endpoint)
+			}
+			if p != strconv.Itoa(port) {
+				return errors.Errorf("%s endpoint %q of adoption is not on port %d of the spec", daemon, endpoint, port)
+			}
+		}
+		return nil
+	}
+	if err := check(adoption.EtcdEndpoints, spec.Etcd.ClientPort, "etcd"); err != nil {
+		return err
+	}
+	if err := check(adoption.MdsEndpoints, spec.Mds.Port, "mds"); err != nil {
+		return err
+	}
+	nodes := map[string]bool{}
+	for _, host := range adoption.Hosts {
+		if nodes[host.NodeName] {
+			return errors.Errorf("node %q is mapped by more than one host in adoption", host.NodeName)
+		}
+		nodes[host.NodeName] = true
+	}
+	return nil
+}
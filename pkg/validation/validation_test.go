@@ -0,0 +1,146 @@
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/yaml"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+)
+
+func testCluster() *curvev1.CurveCluster {
+	return &curvev1.CurveCluster{
+		TypeMeta:   metav1.TypeMeta{APIVersion: curvev1.GroupVersion.String(), Kind: "CurveCluster"},
+		ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "curvebs"},
+		Spec: &curvev1.CurveClusterSpec{
+			Nodes: []string{"node1", "node2", "node3"},
+			Storage: curvev1.StorageScopeSpec{
+				Nodes:   []string{"node1", "node2", "node3"},
+				Devices: []curvev1.DevicesSpec{{Name: "/dev/vdb", MountPath: "/data/chunkserver0", Percentage: 80}},
+			},
+		},
+	}
+}
+
+func TestValidateSamples(t *testing.T) {
+	for _, file := range []string{"cluster.yaml", "cluster-clound.yaml"} {
+		data, err := ioutil.ReadFile("../../config/samples/" + file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cluster := &curvev1.CurveCluster{}
+		if err := yaml.UnmarshalStrict(data, cluster); err != nil {
+			t.Fatalf("failed to parse sample %s: %v", file, err)
+		}
+		if errs := ValidateCluster(cluster); len(errs) > 0 {
+			t.Errorf("expected sample %s valid, got %v", file, errs)
+		}
+	}
+}
+
+func TestValidateCluster(t *testing.T) {
+	if errs := ValidateCluster(testCluster()); len(errs) > 0 {
+		t.Fatalf("expected the cluster valid, got %v", errs)
+	}
+
+	// the errors of all the parts of the spec are returned
+	cluster := testCluster()
+	cluster.Spec.Nodes = []string{"node1", "node1", "node2"}
+	cluster.Spec.PriorityClassNames = map[string]string{"metaserver": "high"}
+	size := resource.MustParse("0")
+	cluster.Spec.Storage.Devices = append(cluster.Spec.Storage.Devices,
+		curvev1.DevicesSpec{Name: "chunkserver1", Type: curvev1.DeviceTypeLoop, Size: &size})
+	errs := ValidateCluster(cluster)
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %v", errs)
+	}
+	for i, expected := range []string{"duplicate node", "is type of loop", "unknown daemon"} {
+		if !strings.Contains(errs[i].Error(), expected) {
+			t.Errorf("expected error %q, got %v", expected, errs[i])
+		}
+	}
+
+	if errs := ValidateCluster(&curvev1.CurveCluster{}); len(errs) != 1 {
+		t.Errorf("expected the cluster without spec invalid, got %v", errs)
+	}
+}
+
+func TestValidateStorage(t *testing.T) {
+	// the nodes matched by the selector are resolved by the operator, the devices are checked without them
+	spec := testCluster().Spec
+	spec.Storage.Nodes = nil
+	spec.Storage.NodeSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"curve.io/storage": "true"}}
+	if err := ValidateStorage(spec); err != nil {
+		t.Errorf("expected the storage of node selector valid, got %v", err)
+	}
+	spec.Storage.Devices[0].Encrypted = true
+	if err := ValidateStorage(spec); err == nil || !strings.Contains(err.Error(), "keySecret") {
+		t.Errorf("expected the encrypted device without keySecret refused, got %v", err)
+	}
+
+	spec = testCluster().Spec
+	spec.Storage.Nodes = nil
+	if err := ValidateStorage(spec); err == nil {
+		t.Error("expected the storage without nodes refused")
+	}
+
+	spec = testCluster().Spec
+	spec.Storage.ReservedPercentage = 30
+	spec.Storage.OvercommitPolicy = curvev1.OvercommitReject
+	if err := ValidateStorage(spec); err == nil || !strings.Contains(err.Error(), "reservedPercentage") {
+		t.Errorf("expected the overcommitted device refused, got %v", err)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := curvev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &Webhook{}
+	if err := w.InjectDecoder(decoder); err != nil {
+		t.Fatal(err)
+	}
+	request := func(operation admissionv1beta1.Operation, cluster, old *curvev1.CurveCluster) admission.Request {
+		req := admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{Operation: operation}}
+		req.Object.Raw, _ = json.Marshal(cluster)
+		if old != nil {
+			req.OldObject.Raw, _ = json.Marshal(old)
+		}
+		return req
+	}
+
+	valid := testCluster()
+	invalid := testCluster()
+	invalid.Spec.Nodes = invalid.Spec.Nodes[:2]
+	if resp := w.Handle(context.TODO(), request(admissionv1beta1.Create, valid, nil)); !resp.Allowed {
+		t.Errorf("expected the valid cluster allowed, got %+v", resp.Result)
+	}
+	resp := w.Handle(context.TODO(), request(admissionv1beta1.Create, invalid, nil))
+	if resp.Allowed || !strings.Contains(string(resp.Result.Reason), "etcd nodes") {
+		t.Errorf("expected the invalid cluster denied, got %+v", resp.Result)
+	}
+	if resp := w.Handle(context.TODO(), request(admissionv1beta1.Update, invalid, valid)); resp.Allowed {
+		t.Errorf("expected the spec updated to invalid denied, got %+v", resp.Result)
+	}
+
+	// the invalid cluster accepted before the webhook is enabled can still be annotated and deleted
+	annotated := invalid.DeepCopy()
+	annotated.Annotations = map[string]string{curvev1.PauseReconcileAnnotation: "true"}
+	if resp := w.Handle(context.TODO(), request(admissionv1beta1.Update, annotated, invalid)); !resp.Allowed {
+		t.Errorf("expected the update without the change of spec allowed, got %+v", resp.Result)
+	}
+}
@@ -0,0 +1,59 @@
+package validation
+
+import (
+	"context"
+	"net/http"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	curvev1 "github.com/opencurve/curve-operator/api/v1"
+	"github.com/opencurve/curve-operator/pkg/k8sutil"
+)
+
+// WebhookPath is the path that the validating webhook of CurveCluster is served on
+const WebhookPath = "/validate-operator-curve-io-v1-curvecluster"
+
+// +kubebuilder:webhook:path=/validate-operator-curve-io-v1-curvecluster,mutating=false,failurePolicy=fail,groups=operator.curve.io,resources=curveclusters,verbs=create;update,versions=v1,name=vcurvecluster.kb.io
+
+// Webhook is the validating admission webhook of CurveCluster, it denies the spec that ValidateCluster returns
+// errors of. It's registered to the webhook server of the manager by WebhookPath, the decoder is injected by the
+// server.
+type Webhook struct {
+	decoder *admission.Decoder
+}
+
+var _ admission.Handler = &Webhook{}
+var _ admission.DecoderInjector = &Webhook{}
+
+// InjectDecoder injects the decoder of the scheme of the manager
+func (w *Webhook) InjectDecoder(d *admission.Decoder) error {
+	w.decoder = d
+	return nil
+}
+
+// Handle validates the CurveCluster created or updated. An update that doesn't change the spec is allowed, such as
+// the annotations and the finalizers updated by the operator and curvectl on a cluster that is invalid already.
+func (w *Webhook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1beta1.Create && req.Operation != admissionv1beta1.Update {
+		return admission.Allowed("")
+	}
+	cluster := &curvev1.CurveCluster{}
+	if err := w.decoder.Decode(req, cluster); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if req.Operation == admissionv1beta1.Update {
+		old := &curvev1.CurveCluster{}
+		if err := w.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if !cluster.GetDeletionTimestamp().IsZero() || equality.Semantic.DeepEqual(old.Spec, cluster.Spec) {
+			return admission.Allowed("")
+		}
+	}
+	if err := k8sutil.Aggregate(ValidateCluster(cluster)); err != nil {
+		return admission.Denied(err.Error())
+	}
+	return admission.Allowed("")
+}
@@ -0,0 +1,178 @@
+package version
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Version is the version of curve-operator, it's set by '-ldflags "-X"' when building
+var Version = "v1.0.0"
+
+const (
+	// SkipVersionCheckAnnotation is set to "true" on CurveCluster to reconcile it even if the version check failed,
+	// and to upgrade it without the upgrade check
+	SkipVersionCheckAnnotation = "operator.curve.io/skip-version-check"
+
+	// MinCurveVersion and MaxCurveVersion are the range [MinCurveVersion, MaxCurveVersion) of curve
+	// version that supported by this operator
+	MinCurveVersion = "v1.2.0"
+	MaxCurveVersion = "v1.3.0"
+)
+
+// semVersion is 'major.minor.patch' of a version
+type semVersion [3]int
+
+// parse parses a version such as 'v1.2', 'v1.2.5' or 'v1.2.5-rc0', the pre-release suffix is ignored
+func parse(v string) (semVersion, error) {
+	var ver semVersion
+	s := strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return ver, errors.Errorf("invalid version %q", v)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return ver, errors.Errorf("invalid version %q", v)
+		}
+		ver[i] = n
+	}
+	return ver, nil
+}
+
+// compare returns -1, 0 or 1 if a is less than, equal to or greater than b
+func (a semVersion) compare(b semVersion) int {
+	for i := range a {
+		if a[i] < b[i] {
+			return -1
+		}
+		if a[i] > b[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+// CurveVersionFromImage returns the tag of the curve image such as 'v1.2' of 'opencurvedocker/curvebs:v1.2'
+func CurveVersionFromImage(image string) string {
+	// the registry may contain a port, so only the part after the last '/' is checked
+	name := image[strings.LastIndex(image, "/")+1:]
+	name = strings.Split(name, "@")[0]
+	i := strings.LastIndex(name, ":")
+	if i < 0 {
+		return ""
+	}
+	return name[i+1:]
+}
+
+// checkImageDigest returns an error if the image is pinned by a malformed digest, which must be sha256
+func checkImageDigest(image string) error {
+	name := image[strings.LastIndex(image, "/")+1:]
+	i := strings.Index(name, "@")
+	if i < 0 {
+		return nil
+	}
+	digest := name[i+1:]
+	if !strings.HasPrefix(digest, "sha256:") || len(digest) != len("sha256:")+64 ||
+		strings.Trim(digest[len("sha256:"):], "0123456789abcdef") != "" {
+		return errors.Errorf("digest of image %q must be sha256:<64 hex digits>", image)
+	}
+	return nil
+}
+
+// CheckCurveImage returns an error if the version of curve image is not in the supported range. The image can be
+// pinned by digest with its version tag such as 'opencurvedocker/curvebs:v1.2@sha256:<digest>'
+func CheckCurveImage(image string) error {
+	if err := checkImageDigest(image); err != nil {
+		return err
+	}
+	tag := CurveVersionFromImage(image)
+	v, err := parse(tag)
+	if err != nil {
+		return errors.Errorf("failed to get curve version from image %q, the tag must be a version such as 'v1.2'", image)
+	}
+
+	min, _ := parse(MinCurveVersion)
+	max, _ := parse(MaxCurveVersion)
+	if v.compare(min) < 0 || v.compare(max) >= 0 {
+		return errors.Errorf("curve version %q of image %q is not supported, supported range is [%s, %s)", tag, image, MinCurveVersion, MaxCurveVersion)
+	}
+	return nil
+}
+
+// CheckToolsImage returns an error if the digest of the tools image is malformed, or its tag is a version of another
+// minor version than the curve image. The image pinned by digest only is not checked for its version.
+func CheckToolsImage(toolsImage, curveImage string) error {
+	if err := checkImageDigest(toolsImage); err != nil {
+		return err
+	}
+	name := toolsImage[strings.LastIndex(toolsImage, "/")+1:]
+	name = strings.Split(name, "@")[0]
+	if name == "" || strings.HasPrefix(name, ":") {
+		return errors.Errorf("invalid tools image %q", toolsImage)
+	}
+
+	tag := CurveVersionFromImage(toolsImage)
+	v, err := parse(tag)
+	if err != nil {
+		return nil
+	}
+	curve, err := parse(CurveVersionFromImage(curveImage))
+	if err == nil && (v[0] != curve[0] || v[1] != curve[1]) {
+		return errors.Errorf("version %q of tools image %q doesn't match curve image %q", tag, toolsImage, curveImage)
+	}
+	return nil
+}
+
+// CheckOperatorVersion returns an error if the cluster has been reconciled by a newer operator
+func CheckOperatorVersion(reconciledBy string) error {
+	// the cluster has never been reconciled successfully
+	if reconciledBy == "" {
+		return nil
+	}
+
+	current, err := parse(Version)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse version of curve-operator")
+	}
+	last, err := parse(reconciledBy)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse operator version %q recorded in cluster status", reconciledBy)
+	}
+	if last.compare(current) > 0 {
+		return errors.Errorf("cluster has been reconciled by a newer curve-operator %s, current is %s", reconciledBy, Version)
+	}
+	return nil
+}
+
+// CheckUpgrade returns an error if the cluster running the versions can't be upgraded to the curve version of image.
+// Downgrades, changes of the major version and jumps of more than one minor version are refused, the data written by
+// a newer version may not be read by an older one and the metadata is migrated one minor version at a time.
+func CheckUpgrade(running []string, image string) error {
+	tag := CurveVersionFromImage(image)
+	target, err := parse(tag)
+	if err != nil {
+		return errors.Errorf("failed to get curve version from image %q, the tag must be a version such as 'v1.2'", image)
+	}
+
+	for _, r := range running {
+		v, err := parse(r)
+		if err != nil {
+			return errors.Wrap(err, "failed to parse running curve version")
+		}
+		switch {
+		case target.compare(v) < 0:
+			return errors.Errorf("downgrade from curve %s to %s is not supported", r, tag)
+		case target[0] != v[0]:
+			return errors.Errorf("upgrade from curve %s to %s changes the major version, which is not supported", r, tag)
+		case target[1] > v[1]+1:
+			return errors.Errorf("upgrade from curve %s to %s skips minor versions, upgrade to v%d.%d first", r, tag, v[0], v[1]+1)
+		}
+	}
+	return nil
+}